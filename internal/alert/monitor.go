@@ -0,0 +1,257 @@
+// internal/alert/monitor.go
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// Alert states.
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+const (
+	// evaluateInterval is how often rules are checked, so alerts resolve
+	// even when traffic stops.
+	evaluateInterval = time.Second
+	// maxSamples bounds the requests kept for the longest window; the
+	// oldest are dropped beyond it.
+	maxSamples = 50000
+	// maxPending bounds the events waiting for the webhook.
+	maxPending = 64
+)
+
+// Event reports a rule starting or stopping firing. It is also the JSON body
+// posted to the alert webhook.
+type Event struct {
+	Rule   string    `json:"rule"`
+	State  string    `json:"state"`
+	Value  string    `json:"value"`
+	Source string    `json:"source,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Firing reports whether the event starts an alert.
+func (e Event) Firing() bool {
+	return e.State == StateFiring
+}
+
+// Options configures where alerts go besides the log.
+type Options struct {
+	Logger *zap.Logger
+	// WebhookURL receives each Event as a JSON POST.
+	WebhookURL string
+	// Source identifies this session to the webhook, e.g. the hostname.
+	Source string
+	// OnChange is called with every Event, e.g. to show a TUI banner.
+	OnChange func(Event)
+}
+
+// Monitor checks rules against completed requests and reports when they
+// start and stop firing. A nil Monitor does nothing, so callers needn't check
+// whether alerts are configured.
+type Monitor struct {
+	rules  []Rule
+	opts   Options
+	window time.Duration
+	client *http.Client
+
+	mu      sync.Mutex
+	samples []sample
+	firing  map[string]bool
+
+	// pending feeds the webhook sender, which posts events in order.
+	pending   chan Event
+	sent      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMonitor starts checking rules until Close, or returns nil when there
+// are none.
+func NewMonitor(rules []Rule, opts Options) *Monitor {
+	if len(rules) == 0 {
+		return nil
+	}
+	m := &Monitor{
+		rules:  rules,
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+		firing: make(map[string]bool),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	for _, rule := range rules {
+		m.window = max(m.window, rule.Window)
+	}
+	if opts.WebhookURL != "" {
+		m.pending = make(chan Event, maxPending)
+		m.sent = make(chan struct{})
+		go m.deliver()
+	}
+	go m.run()
+	return m
+}
+
+// Request records a completed request. Imported requests are ignored. Its
+// signature matches proxy.Server.AddListener.
+func (m *Monitor) Request(entry model.RequestLog) {
+	if m == nil || entry.Imported {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, sample{at: time.Now(), status: entry.StatusCode, duration: entry.Duration})
+	if len(m.samples) > maxSamples {
+		m.samples = m.samples[len(m.samples)-maxSamples:]
+	}
+}
+
+// Close stops checking rules and waits for webhook deliveries in flight.
+func (m *Monitor) Close() {
+	if m == nil {
+		return
+	}
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		<-m.done
+		if m.pending != nil {
+			close(m.pending)
+			<-m.sent
+		}
+	})
+}
+
+func (m *Monitor) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(evaluateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			m.evaluate(now)
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// evaluate checks every rule at now and reports the ones that changed.
+func (m *Monitor) evaluate(now time.Time) {
+	var changed []Event
+	m.mu.Lock()
+	// Samples are in arrival order, so the expired ones are a prefix.
+	expired := 0
+	for expired < len(m.samples) && now.Sub(m.samples[expired].at) > m.window {
+		expired++
+	}
+	m.samples = m.samples[expired:]
+
+	for _, rule := range m.rules {
+		start := len(m.samples)
+		for start > 0 && now.Sub(m.samples[start-1].at) <= rule.Window {
+			start--
+		}
+		value := rule.value(m.samples[start:])
+		firing := rule.breached(value)
+		if firing == m.firing[rule.Expr] {
+			continue
+		}
+		m.firing[rule.Expr] = firing
+		state := StateResolved
+		if firing {
+			state = StateFiring
+		}
+		changed = append(changed, Event{Rule: rule.Expr, State: state, Value: rule.format(value), Source: m.opts.Source, Time: now.UTC()})
+	}
+	m.mu.Unlock()
+
+	for _, event := range changed {
+		m.report(event)
+	}
+}
+
+// report logs event and passes it on to OnChange and the webhook.
+func (m *Monitor) report(event Event) {
+	if m.opts.Logger != nil {
+		fields := []zap.Field{
+			logging.Component("alert"),
+			zap.String("rule", event.Rule),
+			zap.String("value", event.Value),
+		}
+		if event.Firing() {
+			m.opts.Logger.Warn("Alert firing", fields...)
+		} else {
+			m.opts.Logger.Info("Alert resolved", fields...)
+		}
+	}
+	if m.opts.OnChange != nil {
+		m.opts.OnChange(event)
+	}
+	if m.pending != nil {
+		select {
+		case m.pending <- event:
+		default:
+			m.warn("Alert webhook is falling behind, dropped an alert", event, nil)
+		}
+	}
+}
+
+// deliver posts pending events until Close.
+func (m *Monitor) deliver() {
+	defer close(m.sent)
+	for event := range m.pending {
+		if err := m.post(event); err != nil {
+			m.warn("Failed to send alert webhook", event, err)
+		}
+	}
+}
+
+func (m *Monitor) warn(message string, event Event, err error) {
+	if m.opts.Logger == nil {
+		return
+	}
+	fields := []zap.Field{
+		logging.Component("alert"),
+		logging.URL(m.opts.WebhookURL),
+		zap.String("rule", event.Rule),
+	}
+	if err != nil {
+		fields = append(fields, logging.Error(err))
+	}
+	m.opts.Logger.Warn(message, fields...)
+}
+
+func (m *Monitor) post(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, m.opts.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "portal")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", m.opts.WebhookURL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestMonitorFiresAndResolves(t *testing.T) {
+	var mu sync.Mutex
+	var posted []Event
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		mu.Lock()
+		posted = append(posted, event)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	rule, err := ParseRule("5xx > 1 in 1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []Event
+	monitor := NewMonitor([]Rule{rule}, Options{
+		Logger:     zap.NewNop(),
+		WebhookURL: receiver.URL,
+		Source:     "dev-box",
+		OnChange:   func(e Event) { changes = append(changes, e) },
+	})
+	// Stop the background ticker so only the explicit evaluations below
+	// run.
+	close(monitor.stop)
+	<-monitor.done
+	monitor.stop = make(chan struct{})
+	monitor.done = make(chan struct{})
+	close(monitor.done)
+
+	monitor.Request(model.RequestLog{StatusCode: 500})
+	monitor.Request(model.RequestLog{StatusCode: 502, Imported: true})
+	monitor.evaluate(time.Now())
+	if len(changes) != 0 {
+		t.Fatalf("expected no alert at the threshold, got %+v", changes)
+	}
+
+	monitor.Request(model.RequestLog{StatusCode: 503})
+	monitor.evaluate(time.Now())
+	monitor.evaluate(time.Now())
+	if len(changes) != 1 || !changes[0].Firing() || changes[0].Value != "2" {
+		t.Fatalf("expected one firing alert, got %+v", changes)
+	}
+
+	monitor.evaluate(time.Now().Add(2 * time.Minute))
+	if len(changes) != 2 || changes[1].State != StateResolved || changes[1].Value != "0" {
+		t.Fatalf("expected the alert to resolve once the errors age out, got %+v", changes)
+	}
+
+	monitor.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(posted) != 2 || posted[0].Rule != "5xx > 1 in 1m" || posted[0].Source != "dev-box" || posted[1].State != StateResolved {
+		t.Fatalf("unexpected webhook events %+v", posted)
+	}
+}
+
+func TestNilMonitor(t *testing.T) {
+	monitor := NewMonitor(nil, Options{})
+	if monitor != nil {
+		t.Fatal("expected no monitor without rules")
+	}
+	monitor.Request(model.RequestLog{StatusCode: 500})
+	monitor.Close()
+}
@@ -0,0 +1,177 @@
+// internal/alert/rule.go
+package alert
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultWindow is the window of a rule written without "in <window>".
+const DefaultWindow = time.Minute
+
+// Metrics a rule can watch besides status codes ("502") and status classes
+// ("5xx").
+const (
+	MetricRequests  = "requests"
+	MetricErrorRate = "error-rate"
+)
+
+// latencyMetrics are the response time percentiles a rule can watch.
+var latencyMetrics = map[string]int{"p50": 50, "p90": 90, "p95": 95, "p99": 99}
+
+// Rule is a threshold on recent traffic, written as
+// "<metric> <op> <threshold> [in <window>]", for example "5xx > 5 in 1m",
+// "p90 > 2s" or "error-rate >= 10% in 5m".
+type Rule struct {
+	// Expr is the rule as written; it names the alert.
+	Expr   string
+	Window time.Duration
+
+	metric    string // MetricRequests, MetricErrorRate, "status", "class" or a latency percentile
+	status    int    // the code for "status", the leading digit for "class"
+	inclusive bool   // >= rather than >
+	threshold float64
+}
+
+// ParseRule parses a rule expression.
+func ParseRule(expr string) (Rule, error) {
+	rule := Rule{Expr: strings.TrimSpace(expr), Window: DefaultWindow}
+	fields := strings.Fields(expr)
+	if len(fields) != 3 && (len(fields) != 5 || fields[3] != "in") {
+		return Rule{}, fmt.Errorf("invalid alert %q: want \"<metric> > <threshold> [in <window>]\"", expr)
+	}
+	metric, op, threshold := fields[0], fields[1], fields[2]
+
+	switch op {
+	case ">":
+	case ">=":
+		rule.inclusive = true
+	default:
+		return Rule{}, fmt.Errorf("invalid alert %q: operator must be > or >=", expr)
+	}
+
+	if len(fields) == 5 {
+		window, err := time.ParseDuration(fields[4])
+		if err != nil || window <= 0 {
+			return Rule{}, fmt.Errorf("invalid alert %q: window must be a positive duration such as 1m", expr)
+		}
+		rule.Window = window
+	}
+
+	var err error
+	switch {
+	case metric == MetricRequests:
+		rule.metric = metric
+		rule.threshold, err = parseCount(threshold)
+	case metric == MetricErrorRate:
+		rule.metric = metric
+		percent, ok := strings.CutSuffix(threshold, "%")
+		if !ok {
+			return Rule{}, fmt.Errorf("invalid alert %q: error-rate threshold must be a percentage such as 10%%", expr)
+		}
+		rule.threshold, err = strconv.ParseFloat(percent, 64)
+	case latencyMetrics[metric] > 0:
+		rule.metric = metric
+		var limit time.Duration
+		limit, err = time.ParseDuration(threshold)
+		rule.threshold = float64(limit) / float64(time.Millisecond)
+	case len(metric) == 3 && metric[0] >= '1' && metric[0] <= '5' && strings.EqualFold(metric[1:], "xx"):
+		rule.metric = "class"
+		rule.status = int(metric[0] - '0')
+		rule.threshold, err = parseCount(threshold)
+	default:
+		code, convErr := strconv.Atoi(metric)
+		if convErr != nil || code < 100 || code > 599 {
+			return Rule{}, fmt.Errorf("invalid alert %q: metric must be a status code, a class such as 5xx, requests, error-rate or one of %s", expr, strings.Join(latencyNames(), ", "))
+		}
+		rule.metric = "status"
+		rule.status = code
+		rule.threshold, err = parseCount(threshold)
+	}
+	if err != nil || rule.threshold < 0 {
+		return Rule{}, fmt.Errorf("invalid alert %q: bad threshold %q", expr, threshold)
+	}
+	return rule, nil
+}
+
+func parseCount(value string) (float64, error) {
+	n, err := strconv.Atoi(value)
+	return float64(n), err
+}
+
+func latencyNames() []string {
+	names := make([]string, 0, len(latencyMetrics))
+	for name := range latencyMetrics {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// sample is what a rule needs from one completed request.
+type sample struct {
+	at       time.Time
+	status   int
+	duration time.Duration
+}
+
+// value measures the rule's metric over samples, which all fall in its
+// window.
+func (r Rule) value(samples []sample) float64 {
+	count := func(match func(sample) bool) float64 {
+		n := 0
+		for _, s := range samples {
+			if match(s) {
+				n++
+			}
+		}
+		return float64(n)
+	}
+
+	switch r.metric {
+	case MetricRequests:
+		return float64(len(samples))
+	case MetricErrorRate:
+		if len(samples) == 0 {
+			return 0
+		}
+		return count(func(s sample) bool { return s.status >= 500 }) / float64(len(samples)) * 100
+	case "class":
+		return count(func(s sample) bool { return s.status/100 == r.status })
+	case "status":
+		return count(func(s sample) bool { return s.status == r.status })
+	default:
+		if len(samples) == 0 {
+			return 0
+		}
+		durations := make([]time.Duration, len(samples))
+		for i, s := range samples {
+			durations[i] = s.duration
+		}
+		slices.Sort(durations)
+		return float64(durations[len(durations)*latencyMetrics[r.metric]/100]) / float64(time.Millisecond)
+	}
+}
+
+// breached reports whether value crosses the threshold.
+func (r Rule) breached(value float64) bool {
+	if r.inclusive {
+		return value >= r.threshold
+	}
+	return value > r.threshold
+}
+
+// format renders a value of the rule's metric for people.
+func (r Rule) format(value float64) string {
+	switch {
+	case r.metric == MetricErrorRate:
+		return fmt.Sprintf("%.1f%%", value)
+	case latencyMetrics[r.metric] > 0:
+		return time.Duration(value * float64(time.Millisecond)).Round(time.Millisecond).String()
+	default:
+		return strconv.Itoa(int(value))
+	}
+}
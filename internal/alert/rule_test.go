@@ -0,0 +1,66 @@
+package alert
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	for _, tc := range []struct {
+		expr      string
+		metric    string
+		threshold float64
+		window    time.Duration
+	}{
+		{"5xx > 5 in 1m", "class", 5, time.Minute},
+		{"p90 > 2s", "p90", 2000, DefaultWindow},
+		{"error-rate >= 10% in 5m", MetricErrorRate, 10, 5 * time.Minute},
+		{"502 > 0 in 30s", "status", 0, 30 * time.Second},
+		{"requests > 100 in 10s", MetricRequests, 100, 10 * time.Second},
+	} {
+		rule, err := ParseRule(tc.expr)
+		if err != nil {
+			t.Fatalf("ParseRule(%q): %v", tc.expr, err)
+		}
+		if rule.metric != tc.metric || rule.threshold != tc.threshold || rule.Window != tc.window || rule.Expr != tc.expr {
+			t.Fatalf("ParseRule(%q) = %+v", tc.expr, rule)
+		}
+	}
+
+	for _, expr := range []string{"", "5xx > five", "p90 > 2", "error-rate > 10", "6xx > 1", "999 > 1", "5xx < 1", "5xx > 1 in forever", "5xx > 1 during 1m"} {
+		if _, err := ParseRule(expr); err == nil {
+			t.Fatalf("expected ParseRule(%q) to fail", expr)
+		}
+	}
+}
+
+func TestRuleValue(t *testing.T) {
+	samples := []sample{
+		{status: 200, duration: 10 * time.Millisecond},
+		{status: 200, duration: 20 * time.Millisecond},
+		{status: 502, duration: 30 * time.Millisecond},
+		{status: 503, duration: 2 * time.Second},
+	}
+	for _, tc := range []struct {
+		expr  string
+		value string
+		fires bool
+	}{
+		{"5xx > 1", "2", true},
+		{"502 > 1", "1", false},
+		{"502 >= 1", "1", true},
+		{"error-rate > 50%", "50.0%", false},
+		{"p50 > 25ms", "30ms", true},
+		{"p90 > 2s", "2s", false},
+		{"requests > 3", "4", true},
+	} {
+		rule, err := ParseRule(tc.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := rule.value(samples)
+		if got := rule.format(value); got != tc.value || rule.breached(value) != tc.fires {
+			t.Fatalf("%s: got %s (firing %t), want %s (firing %t)", tc.expr, got, rule.breached(value), tc.value, tc.fires)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// internal/annotations/annotations.go
+package annotations
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+const (
+	// maxNoteLength bounds a note, in characters.
+	maxNoteLength = 2000
+	// maxTags bounds the tags on one annotation, and maxTagLength each tag.
+	maxTags      = 16
+	maxTagLength = 32
+	// subscriberBuffer is how many changes a subscriber may fall behind by
+	// before it is disconnected.
+	subscriberBuffer = 64
+)
+
+// Store keeps the web UI's annotations in memory, one per request and
+// viewer, and streams every change to subscribers. Once more than
+// maxRequests requests are annotated, those annotated longest ago are forgotten, so
+// the store stays bounded like the request history. It is safe for
+// concurrent use.
+type Store struct {
+	mu          sync.Mutex
+	maxRequests int
+	annotations map[string][]model.Annotation
+	// order holds the annotated request IDs, first annotated first.
+	order []string
+	subs  map[chan model.Annotation]struct{}
+}
+
+// New returns an empty store that annotates at most maxRequests requests.
+func New(maxRequests int) *Store {
+	return &Store{
+		maxRequests: maxRequests,
+		annotations: make(map[string][]model.Annotation),
+		subs:        make(map[chan model.Annotation]struct{}),
+	}
+}
+
+// List returns every annotation, least recently updated first.
+func (s *Store) List() []model.Annotation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []model.Annotation
+	for _, id := range s.order {
+		list = append(list, s.annotations[id]...)
+	}
+	slices.SortStableFunc(list, func(a, b model.Annotation) int {
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	})
+	return list
+}
+
+// Put replaces the author's annotation on a request, or removes it when
+// the annotation is empty, and returns it as stored. Tags are trimmed,
+// lowercased and deduplicated.
+func (s *Store) Put(annotation model.Annotation) (model.Annotation, error) {
+	annotation.Note = strings.TrimSpace(annotation.Note)
+	var tags []string
+	for _, tag := range annotation.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	annotation.Tags = tags
+	switch {
+	case annotation.RequestID == "":
+		return model.Annotation{}, fmt.Errorf("request ID is required")
+	case utf8.RuneCountInString(annotation.Note) > maxNoteLength:
+		return model.Annotation{}, fmt.Errorf("note must be at most %d characters", maxNoteLength)
+	case len(tags) > maxTags:
+		return model.Annotation{}, fmt.Errorf("at most %d tags are allowed", maxTags)
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > maxTagLength || strings.ContainsFunc(tag, unicode.IsSpace) {
+			return model.Annotation{}, fmt.Errorf("tag %q must be one word of at most %d characters", tag, maxTagLength)
+		}
+	}
+	annotation.UpdatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := annotation.Author.Key()
+	existing := s.annotations[annotation.RequestID]
+	existing = slices.DeleteFunc(slices.Clone(existing), func(a model.Annotation) bool {
+		return a.Author.Key() == key
+	})
+	if !annotation.Empty() {
+		existing = append(existing, annotation)
+	}
+	s.set(annotation.RequestID, existing)
+	s.publish(annotation)
+	return annotation, nil
+}
+
+// set stores the annotations on a request, keeping order and the bound.
+// Callers hold mu.
+func (s *Store) set(id string, annotations []model.Annotation) {
+	_, known := s.annotations[id]
+	switch {
+	case len(annotations) == 0:
+		delete(s.annotations, id)
+		s.order = slices.DeleteFunc(s.order, func(o string) bool { return o == id })
+		return
+	case !known:
+		s.order = append(s.order, id)
+	}
+	s.annotations[id] = annotations
+	for s.maxRequests > 0 && len(s.order) > s.maxRequests {
+		delete(s.annotations, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// Clear forgets every annotation, for when the request history is cleared.
+// Subscribers aren't told; their clients reload with the history.
+func (s *Store) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.annotations = make(map[string][]model.Annotation)
+	s.order = nil
+}
+
+// Subscribe registers a viewer and returns a channel of annotation changes,
+// which is closed when the viewer falls behind or cancel is called. A
+// removed annotation is sent empty.
+func (s *Store) Subscribe() (<-chan model.Annotation, func()) {
+	ch := make(chan model.Annotation, subscriberBuffer)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[ch]; ok {
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// publish sends a change to every subscriber, dropping those that cannot
+// keep up rather than blocking the viewer who made it. Callers hold mu.
+func (s *Store) publish(annotation model.Annotation) {
+	for ch := range s.subs {
+		select {
+		case ch <- annotation:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package annotations
+
+import (
+	"testing"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestStoreKeepsOneAnnotationPerViewer(t *testing.T) {
+	store := New(10)
+	alice := model.Identity{Address: "100.64.0.2", Login: "alice@example.com"}
+	bob := model.Identity{Address: "100.64.0.3", Device: "ci-runner", Tags: []string{"tag:ci"}}
+
+	changes, cancel := store.Subscribe()
+	defer cancel()
+
+	if _, err := store.Put(model.Annotation{RequestID: "req_1", Author: alice, Note: " first ", Tags: []string{"Billing", "billing", " "}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put(model.Annotation{RequestID: "req_1", Author: alice, Note: "second", Starred: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put(model.Annotation{RequestID: "req_1", Author: bob, Tags: []string{"flaky"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	list := store.List()
+	if len(list) != 2 || list[0].Author.Login != "alice@example.com" || list[0].Note != "second" || !list[0].Starred || list[1].Author.Device != "ci-runner" {
+		t.Fatalf("unexpected annotations %+v", list)
+	}
+	if first := <-changes; first.Note != "first" || len(first.Tags) != 1 || first.Tags[0] != "billing" {
+		t.Fatalf("expected a normalised first change, got %+v", first)
+	}
+
+	if _, err := store.Put(model.Annotation{RequestID: "req_1", Author: alice}); err != nil {
+		t.Fatal(err)
+	}
+	if list := store.List(); len(list) != 1 || list[0].Author.Device != "ci-runner" {
+		t.Fatalf("expected an empty annotation to remove alice's, got %+v", list)
+	}
+}
+
+func TestStoreRejectsInvalidAnnotations(t *testing.T) {
+	store := New(10)
+	for _, annotation := range []model.Annotation{
+		{Note: "no request"},
+		{RequestID: "req_1", Tags: []string{"two words"}},
+		{RequestID: "req_1", Tags: []string{"a-very-long-tag-that-goes-on-and-on-forever"}},
+	} {
+		if _, err := store.Put(annotation); err == nil {
+			t.Fatalf("expected %+v to be rejected", annotation)
+		}
+	}
+}
+
+func TestStoreForgetsOldestAnnotatedRequests(t *testing.T) {
+	store := New(2)
+	for _, id := range []string{"req_1", "req_2", "req_3"} {
+		store.Put(model.Annotation{RequestID: id, Starred: true})
+	}
+	list := store.List()
+	if len(list) != 2 || list[0].RequestID != "req_2" || list[1].RequestID != "req_3" {
+		t.Fatalf("expected the oldest annotated request to be forgotten, got %+v", list)
+	}
+
+	store.Clear()
+	if len(store.List()) != 0 {
+		t.Fatal("expected Clear to forget every annotation")
+	}
+}
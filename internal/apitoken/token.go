@@ -0,0 +1,222 @@
+// internal/apitoken/token.go
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scopes grant access to groups of mutating API endpoints.
+const (
+	// ScopeRequests allows clearing captured requests.
+	ScopeRequests = "requests"
+	// ScopeMock allows editing mock rules and resetting scenarios and resources.
+	ScopeMock = "mock"
+	// ScopeAdmin grants every scope.
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists every valid scope.
+var Scopes = []string{ScopeRequests, ScopeMock, ScopeAdmin}
+
+// tokenPrefix marks portal API tokens so they are recognisable in logs and
+// secret scanners.
+const tokenPrefix = "portal_"
+
+// ErrTokenNotFound is returned when revoking an unknown token ID.
+var ErrTokenNotFound = errors.New("token not found")
+
+// Token is a stored API token. Only the SHA-256 hash of the secret is kept.
+type Token struct {
+	ID        string    `yaml:"id"`
+	Name      string    `yaml:"name"`
+	Hash      string    `yaml:"hash"`
+	Scopes    []string  `yaml:"scopes"`
+	CreatedAt time.Time `yaml:"created_at"`
+}
+
+// Allows reports whether the token grants scope.
+func (t Token) Allows(scope string) bool {
+	return slices.Contains(t.Scopes, ScopeAdmin) || slices.Contains(t.Scopes, scope)
+}
+
+// Store holds API tokens. It is safe for concurrent use.
+type Store struct {
+	mu     sync.RWMutex
+	tokens []Token
+}
+
+type file struct {
+	Tokens []Token `yaml:"tokens"`
+}
+
+// DefaultPath returns the token file location (~/.portal/tokens.yml).
+func DefaultPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".portal", "tokens.yml"), nil
+}
+
+// Load reads the token file at path. A missing file is an empty store.
+func Load(path string) (*Store, error) {
+	s := &Store{}
+	if err := s.Reload(path); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload replaces the tokens with the contents of path, so revocations take
+// effect without a restart.
+func (s *Store) Reload(path string) error {
+	tokens, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = tokens
+	return nil
+}
+
+func readFile(path string) ([]Token, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file %s: %w", path, err)
+	}
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse token file %s: %w", path, err)
+	}
+	return f.Tokens, nil
+}
+
+// Save writes the tokens to path, readable only by the current user.
+func (s *Store) Save(path string) error {
+	s.mu.RLock()
+	data, err := yaml.Marshal(file{Tokens: s.tokens})
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode tokens: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create token directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write token file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Create adds a token with the given scopes and returns its secret, which is
+// not stored and cannot be shown again.
+func (s *Store) Create(name string, scopes []string) (string, Token, error) {
+	if len(scopes) == 0 {
+		return "", Token{}, fmt.Errorf("at least one scope is required (%s)", strings.Join(Scopes, ", "))
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return "", Token{}, fmt.Errorf("invalid scope %q: must be one of %s", scope, strings.Join(Scopes, ", "))
+		}
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", Token{}, err
+	}
+	id, err := randomHex(4)
+	if err != nil {
+		return "", Token{}, err
+	}
+	plaintext := tokenPrefix + secret
+	token := Token{
+		ID:        "tok_" + id,
+		Name:      name,
+		Hash:      hash(plaintext),
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = append(s.tokens, token)
+	return plaintext, token, nil
+}
+
+// Revoke removes the token with the given ID.
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, token := range s.tokens {
+		if token.ID == id {
+			s.tokens = slices.Delete(s.tokens, i, i+1)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrTokenNotFound, id)
+}
+
+// List returns a copy of the stored tokens.
+func (s *Store) List() []Token {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.tokens)
+}
+
+// Enabled reports whether any tokens exist. Token checks are only enforced
+// once at least one token has been created.
+func (s *Store) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.tokens) > 0
+}
+
+// Verify returns the token matching plaintext.
+func (s *Store) Verify(plaintext string) (Token, bool) {
+	if !strings.HasPrefix(plaintext, tokenPrefix) {
+		return Token{}, false
+	}
+	sum := []byte(hash(plaintext))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, token := range s.tokens {
+		if subtle.ConstantTimeCompare(sum, []byte(token.Hash)) == 1 {
+			return token, true
+		}
+	}
+	return Token{}, false
+}
+
+func hash(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,96 @@
+package apitoken
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateVerifyAndRevoke(t *testing.T) {
+	store := &Store{}
+	if store.Enabled() {
+		t.Fatalf("expected empty store to be disabled")
+	}
+
+	secret, token, err := store.Create("ci", []string{ScopeMock})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(secret, "portal_") || strings.Contains(token.Hash, secret) {
+		t.Fatalf("unexpected secret %q / hash %q", secret, token.Hash)
+	}
+
+	got, ok := store.Verify(secret)
+	if !ok || got.ID != token.ID {
+		t.Fatalf("expected secret to verify as %s, got %+v ok=%t", token.ID, got, ok)
+	}
+	if !got.Allows(ScopeMock) || got.Allows(ScopeRequests) {
+		t.Fatalf("unexpected scopes %v", got.Scopes)
+	}
+	if _, ok := store.Verify(secret + "x"); ok {
+		t.Fatalf("expected wrong secret to fail")
+	}
+
+	if err := store.Revoke(token.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, ok := store.Verify(secret); ok {
+		t.Fatalf("expected revoked token to fail")
+	}
+	if err := store.Revoke(token.ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("expected ErrTokenNotFound, got %v", err)
+	}
+}
+
+func TestCreateRejectsUnknownScope(t *testing.T) {
+	store := &Store{}
+	if _, _, err := store.Create("x", []string{"shutdown"}); err == nil {
+		t.Fatalf("expected invalid scope error")
+	}
+	if _, _, err := store.Create("x", nil); err == nil {
+		t.Fatalf("expected missing scope error")
+	}
+}
+
+func TestAdminAllowsEveryScope(t *testing.T) {
+	token := Token{Scopes: []string{ScopeAdmin}}
+	if !token.Allows(ScopeRequests) || !token.Allows(ScopeMock) {
+		t.Fatalf("expected admin to allow every scope")
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.yml")
+
+	missing, err := Load(path)
+	if err != nil || missing.Enabled() {
+		t.Fatalf("expected missing file to load empty, got %v", err)
+	}
+
+	store := &Store{}
+	secret, _, err := store.Create("laptop", []string{ScopeRequests})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := store.Save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Fatalf("token file must not contain the secret")
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if _, ok := loaded.Verify(secret); !ok {
+		t.Fatalf("expected loaded store to verify secret")
+	}
+}
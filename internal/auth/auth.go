@@ -0,0 +1,112 @@
+// internal/auth/auth.go
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ErrNoCredentials is returned by Authenticate when a request carries no
+// credentials at all, as opposed to ones that don't check out.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Provider names accepted in the auth middleware's config.
+const (
+	ProviderToken  = "token"
+	ProviderOIDC   = "oidc"
+	ProviderGitHub = "github"
+)
+
+// Providers lists the built-in providers.
+var Providers = []string{ProviderToken, ProviderOIDC, ProviderGitHub}
+
+const (
+	// PathPrefix holds the endpoints providers serve themselves, such as an
+	// OAuth callback. Requests under it never reach the target.
+	PathPrefix = "/__portal/auth/"
+	// UserHeader tells the target who the request was authenticated as. A
+	// value sent by the client is always replaced.
+	UserHeader = "X-Portal-User"
+)
+
+// Provider decides who a request was made by, so the exposed service can
+// require login without changes to the service itself.
+type Provider interface {
+	// Authenticate returns the user r was made by, or an error saying why
+	// it isn't authenticated.
+	Authenticate(r *http.Request) (string, error)
+	// Challenge answers a request that failed Authenticate, with a 401 or
+	// a redirect into a login flow.
+	Challenge(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Middleware returns middleware that only lets requests Provider
+// authenticates through, passing the user on in UserHeader in place of
+// portal's own credentials. Providers that are also an http.Handler serve
+// the requests under PathPrefix.
+func Middleware(provider Provider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, PathPrefix) {
+				if h, ok := provider.(http.Handler); ok {
+					h.ServeHTTP(w, r)
+					return
+				}
+				http.NotFound(w, r)
+				return
+			}
+			user, err := provider.Authenticate(r)
+			if err != nil {
+				provider.Challenge(w, r, err)
+				return
+			}
+			stripCredentials(provider, r)
+			r.Header.Set(UserHeader, user)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// stripCredentials removes portal's own credentials from an authenticated
+// request, so the target never sees them: the bearer token the token and
+// OIDC providers check, and the login cookies.
+func stripCredentials(provider Provider, r *http.Request) {
+	switch provider.(type) {
+	case *Tokens, *OIDC:
+		r.Header.Del("Authorization")
+	}
+	cookies := r.Cookies()
+	if !slices.ContainsFunc(cookies, isPortalCookie) {
+		return
+	}
+	r.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if !isPortalCookie(cookie) {
+			r.AddCookie(cookie)
+		}
+	}
+}
+
+func isPortalCookie(cookie *http.Cookie) bool {
+	return cookie.Name == sessionCookie || cookie.Name == stateCookie
+}
+
+// bearerChallenge answers 401 with a Bearer challenge carrying err.
+func bearerChallenge(w http.ResponseWriter, err error) {
+	challenge := `Bearer realm="portal"`
+	if err != nil && !errors.Is(err, ErrNoCredentials) {
+		challenge += `, error="invalid_token", error_description="` + strings.ReplaceAll(err.Error(), `"`, `'`) + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// requestScheme returns the scheme the client used to reach portal.
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// serve runs r through the auth middleware for provider and returns the
+// response along with the user the target saw, if it was reached.
+func serve(provider Provider, r *http.Request) (*httptest.ResponseRecorder, string) {
+	var user string
+	handler := Middleware(provider)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user = r.Header.Get(UserHeader)
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	return rr, user
+}
+
+func TestTokens(t *testing.T) {
+	provider := NewTokens(map[string]string{"alice": "s3cret", "bob": "hunter2"})
+
+	for _, tc := range []struct {
+		authorization string
+		wantUser      string
+		wantChallenge string
+	}{
+		{authorization: "Bearer hunter2", wantUser: "bob"},
+		{authorization: "", wantChallenge: `Bearer realm="portal"`},
+		{authorization: "Bearer nope", wantChallenge: `Bearer realm="portal", error="invalid_token", error_description="unknown token"`},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(UserHeader, "mallory")
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		rr, user := serve(provider, req)
+		if user != tc.wantUser {
+			t.Fatalf("%q: expected user %q, got %q", tc.authorization, tc.wantUser, user)
+		}
+		if tc.wantChallenge != "" && (rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") != tc.wantChallenge) {
+			t.Fatalf("%q: expected 401 with %s, got %d %q", tc.authorization, tc.wantChallenge, rr.Code, rr.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestMiddlewareStripsPortalCredentials(t *testing.T) {
+	var seen *http.Request
+	target := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = r })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer hunter2")
+	req.Header.Set("Cookie", "app=1; portal_session=forged")
+	Middleware(NewTokens(map[string]string{"bob": "hunter2"}))(target).ServeHTTP(httptest.NewRecorder(), req)
+	if seen == nil || seen.Header.Get("Authorization") != "" || seen.Header.Get("Cookie") != "app=1" {
+		t.Fatalf("expected the target not to see the portal token or cookie, got %v", seen)
+	}
+
+	github := NewGitHub(GitHubConfig{ClientID: "Iv1.abc", ClientSecret: "s3cret"})
+	seen = nil
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer app-token")
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: github.sign(sessionCookie, "octocat", time.Hour)})
+	req.AddCookie(&http.Cookie{Name: "app", Value: "1"})
+	Middleware(github)(target).ServeHTTP(httptest.NewRecorder(), req)
+	if seen == nil || seen.Header.Get("Cookie") != "app=1" || seen.Header.Get(UserHeader) != "octocat" {
+		t.Fatalf("expected the target not to see the session cookie, got %v", seen)
+	}
+	if seen.Header.Get("Authorization") != "Bearer app-token" {
+		t.Fatalf("expected the app's own Authorization header to pass with GitHub login, got %q", seen.Header.Get("Authorization"))
+	}
+}
+
+func TestOIDC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issuer string
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+		case "/keys":
+			json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+				{"kty": "RSA", "kid": "k1", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer idp.Close()
+	issuer = idp.URL
+
+	sign := func(claims map[string]any) string {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1"})
+		body, _ := json.Marshal(claims)
+		signed := b64(header) + "." + b64(body)
+		digest := sha256.Sum256([]byte(signed))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed + "." + b64(sig)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := map[string]any{"iss": issuer, "aud": []string{"portal-demo"}, "sub": "1234", "email": "dev@example.com", "exp": exp}
+	with := func(key string, value any) map[string]any {
+		claims := map[string]any{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	provider := NewOIDC(OIDCConfig{Issuer: issuer + "/", Audience: "portal-demo"})
+	for _, tc := range []struct {
+		name     string
+		token    string
+		wantUser string
+		wantErr  string
+	}{
+		{name: "valid", token: sign(valid), wantUser: "dev@example.com"},
+		{name: "subject", token: sign(with("email", nil)), wantUser: "1234"},
+		{name: "issuer", token: sign(with("iss", "https://evil.example.com")), wantErr: "issued by"},
+		{name: "audience", token: sign(with("aud", "other")), wantErr: `not issued to "portal-demo"`},
+		{name: "expired", token: sign(with("exp", time.Now().Add(-time.Minute).Unix())), wantErr: "expired"},
+		{name: "no expiry", token: sign(with("exp", nil)), wantErr: "no expiry"},
+		{name: "not before", token: sign(with("nbf", exp)), wantErr: "not valid yet"},
+		{name: "tampered", token: sign(valid)[:20] + "x" + sign(valid)[21:], wantErr: "invalid"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		user, err := provider.Authenticate(req)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("%s: expected error containing %q, got %q %v", tc.name, tc.wantErr, user, err)
+			}
+			continue
+		}
+		if err != nil || user != tc.wantUser {
+			t.Fatalf("%s: expected user %q, got %q %v", tc.name, tc.wantUser, user, err)
+		}
+	}
+}
+
+func TestGitHubLogin(t *testing.T) {
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login/oauth/access_token":
+			r.ParseForm()
+			if r.Form.Get("client_secret") != "s3cret" || r.Form.Get("code") == "bad" {
+				json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "gho_" + r.Form.Get("code")})
+		case "/user":
+			login := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer gho_")
+			json.NewEncoder(w).Encode(map[string]string{"login": login})
+		}
+	}))
+	defer github.Close()
+
+	provider := NewGitHub(GitHubConfig{ClientID: "Iv1.abc", ClientSecret: "s3cret", Users: []string{"OctoCat"}})
+	provider.authorizeURL = github.URL + "/login/oauth/authorize"
+	provider.tokenURL = github.URL + "/login/oauth/access_token"
+	provider.userURL = github.URL + "/user"
+
+	// A browser is sent to GitHub with a state it must bring back.
+	req := httptest.NewRequest(http.MethodGet, "https://demo.example.ts.net/dashboard?tab=2", nil)
+	req.Header.Set("Accept", "text/html")
+	rr, _ := serve(provider, req)
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected a redirect to log in, got %d", rr.Code)
+	}
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Query().Get("redirect_uri") != "https://demo.example.ts.net/__portal/auth/callback" || location.Query().Get("client_id") != "Iv1.abc" {
+		t.Fatalf("unexpected authorize URL %s", location)
+	}
+	state := location.Query().Get("state")
+	stateCookies := rr.Result().Cookies()
+
+	callback := func(code, state string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "https://demo.example.ts.net"+CallbackPath+"?code="+code+"&state="+state, nil)
+		for _, cookie := range stateCookies {
+			req.AddCookie(cookie)
+		}
+		rr, _ := serve(provider, req)
+		return rr
+	}
+	if rr := callback("octocat", "forged"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a mismatched state to be rejected, got %d", rr.Code)
+	}
+	if rr := callback("bad", state); rr.Code != http.StatusBadGateway {
+		t.Fatalf("expected a failed exchange to be reported, got %d", rr.Code)
+	}
+	if rr := callback("someone", state); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a user not in the list to be refused, got %d", rr.Code)
+	}
+	rr = callback("octocat", state)
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/dashboard?tab=2" {
+		t.Fatalf("expected a redirect back to the page, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	var session *http.Cookie
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			session = cookie
+		}
+	}
+	if session == nil || !session.HttpOnly || !session.Secure {
+		t.Fatalf("expected a secure session cookie, got %+v", rr.Result().Cookies())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	req.AddCookie(session)
+	if rr, user := serve(provider, req); rr.Code != http.StatusOK || user != "octocat" {
+		t.Fatalf("expected the session to authenticate octocat, got %d %q", rr.Code, user)
+	}
+
+	// The state cookie is signed too, but can't be passed off as a session.
+	for _, cookie := range stateCookies {
+		req = httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: cookie.Value})
+		if rr, user := serve(provider, req); rr.Code != http.StatusUnauthorized || user != "" {
+			t.Fatalf("expected a state cookie to be refused as a session, got %d %q", rr.Code, user)
+		}
+	}
+
+	provider.now = func() time.Time { return time.Now().Add(sessionTTL + time.Minute) }
+	req = httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+	req.AddCookie(session)
+	if rr, _ := serve(provider, req); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected an expired session to be refused, got %d", rr.Code)
+	}
+}
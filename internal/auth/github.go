@@ -0,0 +1,272 @@
+// internal/auth/github.go
+package auth
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// CallbackPath is where GitHub sends visitors back after they log in.
+	// It must be the OAuth app's authorization callback URL.
+	CallbackPath = PathPrefix + "callback"
+	// LogoutPath clears the visitor's session.
+	LogoutPath = PathPrefix + "logout"
+
+	sessionCookie = "portal_session"
+	stateCookie   = "portal_oauth_state"
+	// sessionTTL is how long a login lasts, and stateTTL how long a visitor
+	// has to finish logging in.
+	sessionTTL = 24 * time.Hour
+	stateTTL   = 10 * time.Minute
+)
+
+// GitHubConfig configures a GitHub provider.
+type GitHubConfig struct {
+	// ClientID and ClientSecret are the GitHub OAuth app's credentials.
+	ClientID     string
+	ClientSecret string
+	// Users limits access to these GitHub logins. Empty lets in anyone
+	// with a GitHub account.
+	Users []string
+}
+
+// GitHub sends browsers through GitHub's OAuth login and remembers who
+// they are in a signed session cookie. Sessions are signed with a key made
+// at startup, so visitors log in again after portal restarts.
+type GitHub struct {
+	config GitHubConfig
+	key    []byte
+	client *http.Client
+	now    func() time.Time
+
+	authorizeURL string
+	tokenURL     string
+	userURL      string
+}
+
+// NewGitHub returns a GitHub provider.
+func NewGitHub(config GitHubConfig) *GitHub {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &GitHub{
+		config:       config,
+		key:          key,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		now:          time.Now,
+		authorizeURL: "https://github.com/login/oauth/authorize",
+		tokenURL:     "https://github.com/login/oauth/access_token",
+		userURL:      "https://api.github.com/user",
+	}
+}
+
+// Authenticate implements Provider.
+func (p *GitHub) Authenticate(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", ErrNoCredentials
+	}
+	value, err := p.verify(sessionCookie, cookie.Value)
+	if err != nil {
+		return "", fmt.Errorf("invalid session: %w", err)
+	}
+	return value, nil
+}
+
+// Challenge implements Provider. Browsers loading a page are sent to log
+// in and brought back to it afterwards; anything else gets a 401.
+func (p *GitHub) Challenge(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Method != http.MethodGet || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	state := base64.RawURLEncoding.EncodeToString(nonce)
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    p.sign(stateCookie, state+"|"+r.URL.RequestURI(), stateTTL),
+		Path:     PathPrefix,
+		MaxAge:   int(stateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   requestScheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	query := url.Values{
+		"client_id":    {p.config.ClientID},
+		"redirect_uri": {p.redirectURI(r)},
+		"state":        {state},
+		"allow_signup": {"false"},
+	}
+	http.Redirect(w, r, p.authorizeURL+"?"+query.Encode(), http.StatusFound)
+}
+
+// ServeHTTP serves the OAuth callback and logout endpoints.
+func (p *GitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case CallbackPath:
+		p.callback(w, r)
+	case LogoutPath:
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+		http.Redirect(w, r, "/", http.StatusFound)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// callback finishes a login: it checks the state matches the visitor's
+// cookie, exchanges the code for a token, looks up the GitHub login and,
+// if it is allowed in, starts a session.
+func (p *GitHub) callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		http.Error(w, "GitHub login failed: "+cmp.Or(query.Get("error_description"), reason), http.StatusUnauthorized)
+		return
+	}
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil {
+		http.Error(w, "Login expired, try again", http.StatusBadRequest)
+		return
+	}
+	signed, err := p.verify(stateCookie, cookie.Value)
+	state, returnTo, _ := strings.Cut(signed, "|")
+	if err != nil || query.Get("state") == "" || !hmac.Equal([]byte(state), []byte(query.Get("state"))) {
+		http.Error(w, "Login state mismatch, try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: PathPrefix, MaxAge: -1})
+
+	login, err := p.exchange(r, query.Get("code"))
+	if err != nil {
+		http.Error(w, "GitHub login failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if len(p.config.Users) > 0 && !slices.ContainsFunc(p.config.Users, func(user string) bool {
+		return strings.EqualFold(user, login)
+	}) {
+		http.Error(w, fmt.Sprintf("GitHub user %s is not allowed", login), http.StatusForbidden)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    p.sign(sessionCookie, login, sessionTTL),
+		Path:     "/",
+		MaxAge:   int(sessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   requestScheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	// Only same-site paths are followed, so the state cookie can't be used
+	// to send visitors elsewhere.
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") {
+		returnTo = "/"
+	}
+	http.Redirect(w, r, returnTo, http.StatusFound)
+}
+
+// exchange trades an authorization code for an access token and returns
+// the login of the GitHub user it belongs to.
+func (p *GitHub) exchange(r *http.Request, code string) (string, error) {
+	form := url.Values{
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURI(r)},
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.getJSON(req, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("no access token: %s", cmp.Or(token.ErrorDescription, token.Error, "empty response"))
+	}
+
+	req, err = http.NewRequestWithContext(r.Context(), http.MethodGet, p.userURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := p.getJSON(req, &user); err != nil {
+		return "", err
+	}
+	if user.Login == "" {
+		return "", errors.New("no login in GitHub user")
+	}
+	return user.Login, nil
+}
+
+func (p *GitHub) getJSON(req *http.Request, v any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// redirectURI is the callback URL on the host the visitor used.
+func (p *GitHub) redirectURI(r *http.Request) string {
+	return requestScheme(r) + "://" + r.Host + CallbackPath
+}
+
+// sign returns value with an expiry and an HMAC, for the named cookie. The
+// name is part of what's signed, so one cookie can't stand in for another.
+func (p *GitHub) sign(name, value string, ttl time.Duration) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value)) + "." +
+		strconv.FormatInt(p.now().Add(ttl).Unix(), 10)
+	return payload + "." + p.mac(name, payload)
+}
+
+// verify returns the value in the named signed cookie if it hasn't expired.
+func (p *GitHub) verify(name, signed string) (string, error) {
+	i := strings.LastIndex(signed, ".")
+	if i < 0 || !hmac.Equal([]byte(signed[i+1:]), []byte(p.mac(name, signed[:i]))) {
+		return "", errors.New("bad signature")
+	}
+	encoded, expiry, _ := strings.Cut(signed[:i], ".")
+	seconds, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || p.now().After(time.Unix(seconds, 0)) {
+		return "", errors.New("expired")
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func (p *GitHub) mac(name, payload string) string {
+	h := hmac.New(sha256.New, p.key)
+	h.Write([]byte(name + "=" + payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,152 @@
+// internal/auth/oidc.go
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/jwt"
+)
+
+// OIDCConfig configures an OIDC provider.
+type OIDCConfig struct {
+	// Issuer is the expected iss claim. Unless JWKSURL is set, signing keys
+	// are found through the issuer's discovery document.
+	Issuer string
+	// Audience, when set, must be one of the token's aud values, normally
+	// the client ID the tokens were issued to.
+	Audience string
+	JWKSURL  string
+}
+
+// OIDC authenticates requests carrying an ID token from an OpenID Connect
+// issuer as a bearer token. The token's signature, issuer, audience and
+// lifetime are checked, and the user is its email claim, or its subject
+// when it has none.
+type OIDC struct {
+	config OIDCConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu   sync.Mutex
+	keys *jwt.KeySet
+}
+
+// NewOIDC returns an OIDC provider. Nothing is fetched until the first
+// token is checked.
+func NewOIDC(config OIDCConfig) *OIDC {
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	p := &OIDC{config: config, client: &http.Client{Timeout: 10 * time.Second}, now: time.Now}
+	if config.JWKSURL != "" {
+		p.keys = jwt.NewKeySet(config.JWKSURL)
+	}
+	return p
+}
+
+// Authenticate implements Provider.
+func (p *OIDC) Authenticate(r *http.Request) (string, error) {
+	token, ok := jwt.BearerToken(r.Header.Get("Authorization"))
+	if !ok {
+		return "", ErrNoCredentials
+	}
+	keys, err := p.keySet(r.Context())
+	if err != nil {
+		return "", err
+	}
+	if err := keys.Verify(r.Context(), token); err != nil {
+		return "", fmt.Errorf("invalid signature: %w", err)
+	}
+	info, err := jwt.Decode(token, p.now())
+	if err != nil {
+		return "", err
+	}
+
+	claims := info.Claims
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != p.config.Issuer {
+		return "", fmt.Errorf("token issued by %q, not %q", iss, p.config.Issuer)
+	}
+	if p.config.Audience != "" && !hasAudience(claims["aud"], p.config.Audience) {
+		return "", fmt.Errorf("token not issued to %q", p.config.Audience)
+	}
+	switch {
+	case info.ExpiresAt == nil:
+		return "", errors.New("token has no expiry")
+	case info.Expired:
+		return "", errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(json.Number); ok {
+		if seconds, err := nbf.Int64(); err == nil && p.now().Before(time.Unix(seconds, 0)) {
+			return "", errors.New("token not valid yet")
+		}
+	}
+
+	if email, _ := claims["email"].(string); email != "" {
+		return email, nil
+	}
+	if sub, _ := claims["sub"].(string); sub != "" {
+		return sub, nil
+	}
+	return "", errors.New("token has no subject")
+}
+
+// Challenge implements Provider.
+func (p *OIDC) Challenge(w http.ResponseWriter, r *http.Request, err error) {
+	bearerChallenge(w, err)
+}
+
+// keySet returns the issuer's signing keys, looking up where they are
+// published on first use. A failed lookup is retried on the next request.
+func (p *OIDC) keySet(ctx context.Context) (*jwt.KeySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil {
+		return p.keys, nil
+	}
+
+	url := p.config.Issuer + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OIDC discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch OIDC discovery document: %s returned %s", url, resp.Status)
+	}
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, fmt.Errorf("invalid OIDC discovery document: %w", err)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document at %s has no jwks_uri", url)
+	}
+	p.keys = jwt.NewKeySet(discovery.JWKSURI)
+	return p.keys, nil
+}
+
+// hasAudience reports whether an aud claim, a string or a list of them,
+// includes audience.
+func hasAudience(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, v := range aud {
+			if v == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
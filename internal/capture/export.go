@@ -0,0 +1,124 @@
+// internal/capture/export.go
+package capture
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// Export formats.
+const (
+	// FormatNDJSON writes one JSON request per line, as a capture file does.
+	FormatNDJSON = "ndjson"
+	// FormatCSV writes a header row and one row per request.
+	FormatCSV = "csv"
+)
+
+// DefaultCSVColumns are the columns of a CSV export when none are chosen.
+var DefaultCSVColumns = []string{"timestamp", "method", "url", "status", "duration_ms", "request_size", "response_size", "client"}
+
+// csvColumns formats each exportable column of a request.
+var csvColumns = map[string]func(model.RequestLog) string{
+	"id":        func(e model.RequestLog) string { return e.ID },
+	"timestamp": func(e model.RequestLog) string { return e.Timestamp.UTC().Format(time.RFC3339Nano) },
+	"method":    func(e model.RequestLog) string { return e.Method },
+	"url":       func(e model.RequestLog) string { return e.URL },
+	"status":    func(e model.RequestLog) string { return strconv.Itoa(e.Response.StatusCode) },
+	"duration_ms": func(e model.RequestLog) string {
+		return strconv.FormatFloat(float64(e.Duration)/float64(time.Millisecond), 'f', 3, 64)
+	},
+	"request_size":          func(e model.RequestLog) string { return strconv.FormatInt(e.Size, 10) },
+	"response_size":         func(e model.RequestLog) string { return strconv.FormatInt(e.Response.Size, 10) },
+	"remote_addr":           func(e model.RequestLog) string { return e.RemoteAddr },
+	"client":                exportClient,
+	"user_agent":            func(e model.RequestLog) string { return e.UserAgent },
+	"content_type":          func(e model.RequestLog) string { return e.ContentType },
+	"response_content_type": func(e model.RequestLog) string { return e.Response.Headers.Get("Content-Type") },
+	"request_body":          func(e model.RequestLog) string { return e.Body },
+	"response_body":         func(e model.RequestLog) string { return e.Response.Body },
+	"expose":                func(e model.RequestLog) string { return e.Expose },
+	"replay_of":             func(e model.RequestLog) string { return e.ReplayOf },
+	"duplicate_of":          func(e model.RequestLog) string { return e.DuplicateOf },
+}
+
+// CSVColumns lists the columns a CSV export can include, sorted.
+func CSVColumns() []string {
+	names := make([]string, 0, len(csvColumns))
+	for name := range csvColumns {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ParseCSVColumns parses a comma-separated column list, returning
+// DefaultCSVColumns for an empty one.
+func ParseCSVColumns(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return DefaultCSVColumns, nil
+	}
+	var columns []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := csvColumns[name]; !ok {
+			return nil, fmt.Errorf("unknown column %q (available: %s)", name, strings.Join(CSVColumns(), ", "))
+		}
+		columns = append(columns, name)
+	}
+	return columns, nil
+}
+
+// Export writes entries to w in format. columns choose the CSV columns and
+// are ignored for NDJSON.
+func Export(w io.Writer, format string, entries []model.RequestLog, columns []string) error {
+	switch format {
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, entry := range entries {
+			if err := enc.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns); err != nil {
+			return err
+		}
+		row := make([]string, len(columns))
+		for _, entry := range entries {
+			for i, name := range columns {
+				row[i] = csvColumns[name](entry)
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown export format %q: must be %s or %s", format, FormatNDJSON, FormatCSV)
+}
+
+// exportClient names who sent the request: the tailnet login, else the
+// device, else the address.
+func exportClient(e model.RequestLog) string {
+	if e.Identity == nil {
+		return ""
+	}
+	switch {
+	case e.Identity.Login != "":
+		return e.Identity.Login
+	case e.Identity.Device != "":
+		return e.Identity.Device
+	}
+	return e.Identity.Address
+}
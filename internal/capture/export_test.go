@@ -0,0 +1,69 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestExport(t *testing.T) {
+	entries := []model.RequestLog{
+		{
+			ID:        "req_1",
+			Timestamp: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+			Method:    "POST",
+			URL:       "/hooks?a=1,2",
+			Body:      "line one\nline \"two\"",
+			Duration:  1500 * time.Microsecond,
+			Response:  model.ResponseLog{StatusCode: 201, Size: 12},
+			Identity:  &model.Identity{Address: "100.64.0.2", Login: "alice@example.com"},
+		},
+		{ID: "req_2", Method: "GET", URL: "/b", Response: model.ResponseLog{StatusCode: 404}},
+	}
+
+	var ndjson bytes.Buffer
+	if err := Export(&ndjson, FormatNDJSON, entries, nil); err != nil {
+		t.Fatalf("ndjson: %v", err)
+	}
+	parsed, err := Parse(ndjson.Bytes())
+	if err != nil || len(parsed) != 2 || parsed[0].Body != entries[0].Body {
+		t.Fatalf("expected the NDJSON to read back as a capture file, got %+v (%v)", parsed, err)
+	}
+
+	columns, err := ParseCSVColumns("timestamp,method,url,status,duration_ms,client,request_body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := Export(&out, FormatCSV, entries, columns); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("expected valid CSV, got %v", err)
+	}
+	want := [][]string{
+		columns,
+		{"2025-03-01T12:00:00Z", "POST", "/hooks?a=1,2", "201", "1.500", "alice@example.com", "line one\nline \"two\""},
+		{"0001-01-01T00:00:00Z", "GET", "/b", "404", "0.000", "", ""},
+	}
+	for i := range want {
+		if !slices.Equal(rows[i], want[i]) {
+			t.Fatalf("row %d: expected %q, got %q", i, want[i], rows[i])
+		}
+	}
+
+	if columns, err := ParseCSVColumns(" "); err != nil || !slices.Equal(columns, DefaultCSVColumns) {
+		t.Fatalf("expected the default columns, got %v (%v)", columns, err)
+	}
+	if _, err := ParseCSVColumns("method,colour"); err == nil {
+		t.Fatal("expected an unknown column to fail")
+	}
+	if err := Export(&out, "har", entries, nil); err == nil {
+		t.Fatal("expected an unknown format to fail")
+	}
+}
@@ -0,0 +1,161 @@
+// internal/capture/file_sink.go
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+const (
+	// DefaultMaxFileBytes is the size at which a capture file is rotated when
+	// no explicit limit is configured.
+	DefaultMaxFileBytes int64 = 100 * 1024 * 1024
+
+	// DefaultMaxBackups is the number of rotated capture files kept on disk.
+	DefaultMaxBackups = 5
+)
+
+// FileSink appends every captured request as a JSON line to a file on disk.
+// Each entry is written straight to the file so that a crash never loses more
+// than the request currently being written.
+type FileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileSink opens (or creates) the capture file at path in append mode.
+// Files are rotated once they grow past maxBytes; a non-positive maxBytes uses
+// DefaultMaxFileBytes.
+func NewFileSink(path string, maxBytes int64) (*FileSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("capture file path is required")
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxFileBytes
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create capture directory: %w", err)
+		}
+	}
+
+	sink := &FileSink{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: DefaultMaxBackups,
+	}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// Path returns the active capture file path.
+func (s *FileSink) Path() string {
+	return s.path
+}
+
+// Write appends the request log as a single JSON line, rotating the file first
+// when the entry would push it past the configured size.
+func (s *FileSink) Write(entry model.RequestLog) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode capture entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("capture file %s is closed", s.path)
+	}
+
+	if s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write capture entry: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the capture file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	syncErr := s.file.Sync()
+	closeErr := s.file.Close()
+	s.file = nil
+	if syncErr != nil {
+		return syncErr
+	}
+	return closeErr
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat capture file: %w", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// rotate shifts capture.jsonl -> capture.jsonl.1 -> capture.jsonl.2 ... and
+// drops anything beyond maxBackups. Callers must hold s.mu.
+func (s *FileSink) rotate() error {
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to flush capture file: %w", err)
+	}
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close capture file: %w", err)
+	}
+	s.file = nil
+
+	_ = os.Remove(backupPath(s.path, s.maxBackups))
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		from := backupPath(s.path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, backupPath(s.path, i+1)); err != nil {
+				return fmt.Errorf("failed to rotate capture file: %w", err)
+			}
+		}
+	}
+	if err := os.Rename(s.path, backupPath(s.path, 1)); err != nil {
+		return fmt.Errorf("failed to rotate capture file: %w", err)
+	}
+
+	return s.open()
+}
+
+func backupPath(path string, index int) string {
+	return fmt.Sprintf("%s.%d", path, index)
+}
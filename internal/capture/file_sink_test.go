@@ -0,0 +1,84 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestFileSinkAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	sink, err := NewFileSink(path, 0)
+	if err != nil {
+		t.Fatalf("new sink: %v", err)
+	}
+
+	for _, id := range []string{"req_1", "req_2"} {
+		if err := sink.Write(model.RequestLog{ID: id, Method: "POST", Body: `{"hello":"world"}`}); err != nil {
+			t.Fatalf("write %s: %v", id, err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	entries := readCaptureFile(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[1].ID != "req_2" || entries[1].Body != `{"hello":"world"}` {
+		t.Fatalf("unexpected second entry: %+v", entries[1])
+	}
+}
+
+func TestFileSinkRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	sink, err := NewFileSink(path, 200)
+	if err != nil {
+		t.Fatalf("new sink: %v", err)
+	}
+	defer sink.Close()
+
+	body := strings.Repeat("x", 120)
+	for i := 0; i < 3; i++ {
+		if err := sink.Write(model.RequestLog{ID: "req", Body: body}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("expected first backup file: %v", err)
+	}
+	if _, err := os.Stat(path + ".2"); err != nil {
+		t.Fatalf("expected second backup file: %v", err)
+	}
+	if entries := readCaptureFile(t, path); len(entries) != 1 {
+		t.Fatalf("expected active file to hold 1 entry after rotation, got %d", len(entries))
+	}
+}
+
+func readCaptureFile(t *testing.T, path string) []model.RequestLog {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open capture file: %v", err)
+	}
+	defer file.Close()
+
+	var entries []model.RequestLog
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry model.RequestLog
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("decode line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
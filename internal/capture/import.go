@@ -0,0 +1,195 @@
+// internal/capture/import.go
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// maxImportLineBytes bounds a single capture-file line; captured bodies can
+// make lines much longer than bufio's default.
+const maxImportLineBytes = 64 * 1024 * 1024
+
+// ReadFile loads requests from a HAR archive or a capture file written by
+// --capture-file. The format is detected from the content, not the extension.
+func ReadFile(path string) ([]model.RequestLog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	entries, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// Parse decodes a HAR archive or JSON-lines capture data.
+func Parse(data []byte) ([]model.RequestLog, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+	if isHAR(trimmed) {
+		return parseHAR(trimmed)
+	}
+	return parseJSONLines(trimmed)
+}
+
+func isHAR(data []byte) bool {
+	var probe struct {
+		Log *json.RawMessage `json:"log"`
+	}
+	return json.Unmarshal(data, &probe) == nil && probe.Log != nil
+}
+
+func parseJSONLines(data []byte) ([]model.RequestLog, error) {
+	var entries []model.RequestLog
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var entry model.RequestLog
+		if err := json.Unmarshal(text, &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+}
+
+type harRequest struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Headers  []harHeader `json:"headers"`
+	BodySize int64       `json:"bodySize"`
+	PostData *struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	} `json:"postData"`
+}
+
+type harResponse struct {
+	Status  int         `json:"status"`
+	Headers []harHeader `json:"headers"`
+	Content struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Encoding string `json:"encoding"`
+	} `json:"content"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func parseHAR(data []byte) ([]model.RequestLog, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %w", err)
+	}
+
+	entries := make([]model.RequestLog, 0, len(har.Log.Entries))
+	for i, e := range har.Log.Entries {
+		headers := harHeaders(e.Request.Headers)
+		target := e.Request.URL
+		if u, err := url.Parse(e.Request.URL); err == nil && u.Host != "" {
+			target = u.RequestURI()
+			if headers.Get("Host") == "" {
+				headers = append(headers, model.Header{Name: "Host", Value: u.Host})
+			}
+		}
+
+		entry := model.RequestLog{
+			ID:        fmt.Sprintf("har_%d", i+1),
+			Timestamp: e.StartedDateTime,
+			Method:    e.Request.Method,
+			URL:       target,
+			Headers:   headers,
+			Duration:  time.Duration(e.Time * float64(time.Millisecond)),
+			UserAgent: headers.Get("User-Agent"),
+			Size:      max(e.Request.BodySize, 0),
+			Response: model.ResponseLog{
+				StatusCode: e.Response.Status,
+				Headers:    harHeaders(e.Response.Headers),
+				Body:       harContent(e.Response.Content.Text, e.Response.Content.Encoding),
+				Size:       max(e.Response.Content.Size, 0),
+			},
+			StatusCode:  e.Response.Status,
+			ContentType: headers.Get("Content-Type"),
+		}
+		if !utf8.ValidString(entry.Response.Body) {
+			// Binary content decoded from base64 is kept as bytes for
+			// the hex viewers, as captured binary bodies are.
+			entry.Response.BodyBytes = []byte(entry.Response.Body)
+			entry.Response.Body = ""
+		}
+		if e.Request.PostData != nil {
+			entry.Body = e.Request.PostData.Text
+			if entry.ContentType == "" {
+				entry.ContentType = e.Request.PostData.MimeType
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// harHeaders converts HAR header lists the same way captured headers are
+// stored: canonical names in sorted order, repeated names kept as separate
+// entries. HTTP/2 pseudo-headers are dropped.
+func harHeaders(list []harHeader) model.Headers {
+	headers := make(http.Header, len(list))
+	for _, h := range list {
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		headers.Add(h.Name, h.Value)
+	}
+	return model.HeadersFrom(headers)
+}
+
+func harContent(text, encoding string) string {
+	if encoding != "base64" {
+		return text
+	}
+	decoded, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return text
+	}
+	return string(decoded)
+}
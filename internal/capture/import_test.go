@@ -0,0 +1,66 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadFileCaptureJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	data := `{"id":"req_1","method":"POST","url":"/hook","body":"{}","status_code":201}
+
+{"id":"req_2","method":"GET","url":"/health","status_code":200}
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(entries) != 2 || entries[0].URL != "/hook" || entries[1].StatusCode != 200 {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+}
+
+func TestParseHAR(t *testing.T) {
+	har := `{"log":{"version":"1.2","entries":[{
+		"startedDateTime":"2024-05-01T10:00:00Z",
+		"time":12.5,
+		"request":{"method":"POST","url":"https://example.ts.net/webhooks?x=1",
+			"headers":[{"name":":authority","value":"example.ts.net"},{"name":"content-type","value":"application/json"},{"name":"X-Tag","value":"a"},{"name":"x-tag","value":"b"}],
+			"bodySize":7,"postData":{"mimeType":"application/json","text":"{\"a\":1}"}},
+		"response":{"status":202,"headers":[{"name":"Content-Type","value":"text/plain"}],
+			"content":{"size":2,"mimeType":"text/plain","text":"b2s=","encoding":"base64"}}
+	}]}}`
+
+	entries, err := Parse([]byte(har))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Method != "POST" || entry.URL != "/webhooks?x=1" || entry.Headers.Get("Host") != "example.ts.net" {
+		t.Fatalf("unexpected request %+v", entry)
+	}
+	if tags := entry.Headers.Values("X-Tag"); len(tags) != 2 || tags[0] != "a" || tags[1] != "b" || entry.ContentType != "application/json" || entry.Body != `{"a":1}` {
+		t.Fatalf("unexpected headers/body %+v", entry)
+	}
+	if entry.Headers.Get(":authority") != "" {
+		t.Fatalf("expected pseudo-headers to be dropped")
+	}
+	if entry.StatusCode != 202 || entry.Response.Body != "ok" || entry.Duration != 12500*time.Microsecond {
+		t.Fatalf("unexpected response %+v", entry.Response)
+	}
+}
+
+func TestParseRejectsInvalidLines(t *testing.T) {
+	if _, err := Parse([]byte("{\"id\":\"ok\"}\nnot json\n")); err == nil {
+		t.Fatalf("expected invalid line error")
+	}
+}
@@ -0,0 +1,376 @@
+// internal/capture/spool.go
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// DefaultSpoolInterval is how often changed history is written to the spool.
+const DefaultSpoolInterval = 2 * time.Second
+
+// DefaultSpoolMaxBytes bounds the spool when no explicit limit is
+// configured. Past it, the oldest requests are left out.
+const DefaultSpoolMaxBytes int64 = 100 * 1024 * 1024
+
+// DefaultSavedSessions is how many set-aside sessions SaveSpooledSession
+// keeps.
+const DefaultSavedSessions = 5
+
+// spoolPrefix and spoolSuffix frame the PID in spool file names.
+const (
+	spoolPrefix = "session-"
+	spoolSuffix = ".jsonl"
+)
+
+// Spool keeps a copy of the in-memory request history on disk, so a session
+// survives portal crashing or being killed. New requests are appended to the
+// file; it is only rewritten when requests have been cleared, or evicted
+// from the history often enough that most of the file is stale. The file is
+// removed on a clean Close; a file left behind belongs to a session that
+// ended abnormally.
+type Spool struct {
+	path     string
+	history  func() []model.RequestLog
+	logger   *zap.Logger
+	interval time.Duration
+	maxBytes int64
+
+	dirty atomic.Bool
+	// lastID, lines and size describe the file as last written; they are
+	// used by the run goroutine only.
+	lastID string
+	lines  int
+	size   int64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// DefaultSpoolDir is where spool files are kept: portal/spool in the user's
+// cache directory.
+func DefaultSpoolDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the cache directory: %w", err)
+	}
+	return filepath.Join(dir, "portal", "spool"), nil
+}
+
+// NewSpool starts writing history to a file of this process's own in dir,
+// every interval while it changes. A non-positive interval uses
+// DefaultSpoolInterval, and a non-positive maxBytes DefaultSpoolMaxBytes.
+func NewSpool(dir string, history func() []model.RequestLog, interval time.Duration, maxBytes int64, logger *zap.Logger) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	if interval <= 0 {
+		interval = DefaultSpoolInterval
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultSpoolMaxBytes
+	}
+	s := &Spool{
+		path:     filepath.Join(dir, spoolPrefix+strconv.Itoa(os.Getpid())+spoolSuffix),
+		history:  history,
+		logger:   logger,
+		interval: interval,
+		maxBytes: maxBytes,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Path returns the spool file path.
+func (s *Spool) Path() string {
+	return s.path
+}
+
+// Request marks the history as changed. Its signature matches
+// proxy.Server.AddListener.
+func (s *Spool) Request(model.RequestLog) {
+	s.dirty.Store(true)
+}
+
+// Close stops spooling and removes the spool file, as the session ended
+// cleanly. It is safe to call on a nil Spool.
+func (s *Spool) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.closeOnce.Do(func() { close(s.stop) })
+	<-s.done
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove spool file: %w", err)
+	}
+	return nil
+}
+
+func (s *Spool) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		}
+		entries := s.history()
+		// Imports and clearing the history don't notify listeners, so a
+		// different newest request counts as a change too.
+		if !s.dirty.Swap(false) && lastID(entries) == s.lastID {
+			continue
+		}
+		if err := s.sync(entries); err != nil {
+			s.logger.Warn("Failed to write the capture spool",
+				logging.Component("capture_spool"),
+				zap.String("path", s.path),
+				logging.Error(err),
+			)
+			// Start over with a full rewrite on the next tick.
+			s.lastID = ""
+		}
+	}
+}
+
+func lastID(entries []model.RequestLog) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	return entries[len(entries)-1].ID
+}
+
+// sync brings the spool file up to date with entries, appending those newer
+// than the last one written when it can.
+func (s *Spool) sync(entries []model.RequestLog) error {
+	if len(entries) == 0 {
+		s.lastID, s.lines, s.size = "", 0, 0
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	next := -1
+	if s.lastID != "" {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].ID == s.lastID {
+				next = i + 1
+				break
+			}
+		}
+	}
+	// The history was cleared, or so much has been evicted that most of the
+	// file no longer is in it.
+	if next < 0 || s.lines+len(entries)-next > 2*len(entries) {
+		return s.rewrite(entries)
+	}
+	if next == len(entries) {
+		return nil
+	}
+
+	data, err := encodeLines(entries[next:])
+	if err != nil {
+		return err
+	}
+	if s.size+int64(len(data)) > s.maxBytes {
+		return s.rewrite(entries)
+	}
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	s.lastID = lastID(entries)
+	s.lines += len(entries) - next
+	s.size += int64(len(data))
+	return nil
+}
+
+// rewrite replaces the spool file with the newest entries that fit in
+// maxBytes, through a temporary file so a crash mid-write leaves the
+// previous spool intact.
+func (s *Spool) rewrite(entries []model.RequestLog) error {
+	var lines [][]byte
+	var size int64
+	for i := len(entries) - 1; i >= 0; i-- {
+		line, err := encodeLines(entries[i : i+1])
+		if err != nil {
+			return err
+		}
+		if size+int64(len(line)) > s.maxBytes {
+			break
+		}
+		lines = append(lines, line)
+		size += int64(len(line))
+	}
+	slices.Reverse(lines)
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".spool-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	out := bufio.NewWriter(tmp)
+	for _, line := range lines {
+		if _, err := out.Write(line); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := out.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.lastID = lastID(entries)
+	s.lines = len(lines)
+	s.size = size
+	return nil
+}
+
+func encodeLines(entries []model.RequestLog) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadSpool loads the requests in a spool file. A request that was being
+// appended when the session ended is left out.
+func ReadSpool(path string) ([]model.RequestLog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	entries, err := parseJSONLines(data[:bytes.LastIndexByte(data, '\n')+1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// SpooledSession is a spool file left behind by a session that did not
+// shut down cleanly.
+type SpooledSession struct {
+	Path string
+	// SavedAt is when the spool was last written, shortly before the
+	// session ended.
+	SavedAt time.Time
+}
+
+// FindSpooledSessions lists the spool files in dir whose process is no
+// longer running, oldest first.
+func FindSpooledSessions(dir string) ([]SpooledSession, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []SpooledSession
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, spoolPrefix) || !strings.HasSuffix(name, spoolSuffix) {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, spoolPrefix), spoolSuffix))
+		if err != nil || pid == os.Getpid() || ProcessAlive(pid) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, SpooledSession{Path: filepath.Join(dir, name), SavedAt: info.ModTime()})
+	}
+	slices.SortFunc(sessions, func(a, b SpooledSession) int {
+		return a.SavedAt.Compare(b.SavedAt)
+	})
+	return sessions, nil
+}
+
+// SaveSpooledSession moves a crashed session's spool into dir/saved, so it
+// isn't offered for recovery again but can still be imported, and removes
+// all but the newest DefaultSavedSessions saved there. It returns the new
+// path.
+func SaveSpooledSession(dir string, session SpooledSession) (string, error) {
+	savedDir := filepath.Join(dir, "saved")
+	if err := os.MkdirAll(savedDir, 0o700); err != nil {
+		return "", err
+	}
+	// The random suffix keeps sessions that ended in the same second apart;
+	// the placeholder file reserves the name until the rename replaces it.
+	placeholder, err := os.CreateTemp(savedDir, spoolPrefix+session.SavedAt.Format("20060102-150405")+"-*"+spoolSuffix)
+	if err != nil {
+		return "", err
+	}
+	saved := placeholder.Name()
+	placeholder.Close()
+	if err := os.Rename(session.Path, saved); err != nil {
+		os.Remove(saved)
+		return "", err
+	}
+
+	entries, err := os.ReadDir(savedDir)
+	if err != nil {
+		return saved, nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, spoolPrefix) && strings.HasSuffix(name, spoolSuffix) {
+			names = append(names, name)
+		}
+	}
+	// The names sort by when the session ended.
+	slices.Sort(names)
+	for _, name := range names[:max(len(names)-DefaultSavedSessions, 0)] {
+		os.Remove(filepath.Join(savedDir, name))
+	}
+	return saved, nil
+}
@@ -0,0 +1,238 @@
+package capture
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestSpoolKeepsHistoryUntilCleanClose(t *testing.T) {
+	dir := t.TempDir()
+	var mu sync.Mutex
+	var history []model.RequestLog
+	spool, err := NewSpool(dir, func() []model.RequestLog {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]model.RequestLog(nil), history...)
+	}, 10*time.Millisecond, 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := model.RequestLog{ID: "req_1", Method: "POST", URL: "/hook", Body: `{"event":"push"}`}
+	mu.Lock()
+	history = append(history, entry)
+	mu.Unlock()
+	spool.Request(entry)
+
+	var spooled []model.RequestLog
+	deadline := time.Now().Add(2 * time.Second)
+	for len(spooled) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		spooled, _ = ReadFile(spool.Path())
+	}
+	if len(spooled) != 1 || spooled[0].Body != entry.Body {
+		t.Fatalf("expected the history in the spool, got %+v", spooled)
+	}
+
+	// The spool of a running session isn't offered for recovery.
+	if sessions, err := FindSpooledSessions(dir); err != nil || len(sessions) != 0 {
+		t.Fatalf("expected no crashed sessions, got %+v %v", sessions, err)
+	}
+
+	if err := spool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(spool.Path()); !os.IsNotExist(err) {
+		t.Fatalf("expected a clean close to remove the spool, got %v", err)
+	}
+}
+
+func TestFindSpooledSessionsSkipsRunningProcesses(t *testing.T) {
+	dir := t.TempDir()
+	// PIDs this large are never assigned, so the session is over.
+	crashed := filepath.Join(dir, "session-999999999.jsonl")
+	running := filepath.Join(dir, fmt.Sprintf("session-%d.jsonl", os.Getppid()))
+	for _, path := range []string{crashed, running, filepath.Join(dir, "notes.txt")} {
+		if err := os.WriteFile(path, []byte(`{"id":"req_1"}`+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := FindSpooledSessions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Path != crashed || sessions[0].SavedAt.IsZero() {
+		t.Fatalf("expected only the crashed session, got %+v", sessions)
+	}
+
+	if sessions, err := FindSpooledSessions(filepath.Join(dir, "missing")); err != nil || sessions != nil {
+		t.Fatalf("expected nothing for a missing directory, got %+v %v", sessions, err)
+	}
+}
+
+func TestSpoolAppendsAndCompacts(t *testing.T) {
+	dir := t.TempDir()
+	spool := &Spool{path: filepath.Join(dir, "session-1.jsonl"), maxBytes: DefaultSpoolMaxBytes}
+	history := []model.RequestLog{{ID: "req_1"}, {ID: "req_2"}}
+	if err := spool.sync(history); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(spool.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history = append(history, model.RequestLog{ID: "req_3"})
+	if err := spool.sync(history); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat(spool.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A rewrite renames a new file into place.
+	if !os.SameFile(before, after) {
+		t.Fatal("expected a new request to be appended, not the spool rewritten")
+	}
+	assertSpooled(t, spool.Path(), "req_1", "req_2", "req_3")
+
+	// Once most of the file has been evicted from the history, it is
+	// compacted down to the history.
+	history = []model.RequestLog{{ID: "req_3"}, {ID: "req_4"}}
+	if err := spool.sync(history); err != nil {
+		t.Fatal(err)
+	}
+	assertSpooled(t, spool.Path(), "req_1", "req_2", "req_3", "req_4")
+	history = []model.RequestLog{{ID: "req_4"}, {ID: "req_5"}}
+	if err := spool.sync(history); err != nil {
+		t.Fatal(err)
+	}
+	assertSpooled(t, spool.Path(), "req_4", "req_5")
+
+	// So does clearing it.
+	history = []model.RequestLog{{ID: "req_6"}}
+	if err := spool.sync(history); err != nil {
+		t.Fatal(err)
+	}
+	assertSpooled(t, spool.Path(), "req_6")
+
+	if err := spool.sync(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(spool.Path()); !os.IsNotExist(err) {
+		t.Fatalf("expected a cleared history to remove the spool, got %v", err)
+	}
+}
+
+func TestSpoolKeepsNewestWithinMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	spool := &Spool{path: filepath.Join(dir, "session-1.jsonl"), maxBytes: 300}
+	var history []model.RequestLog
+	for i := range 10 {
+		history = append(history, model.RequestLog{ID: fmt.Sprintf("req_%d", i), Body: "0123456789"})
+		if err := spool.sync(history); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := os.Stat(spool.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 300 {
+		t.Fatalf("expected the spool within 300 bytes, got %d", info.Size())
+	}
+	entries, err := ReadSpool(spool.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || len(entries) == len(history) || entries[len(entries)-1].ID != "req_9" {
+		t.Fatalf("expected only the newest requests, got %+v", entries)
+	}
+}
+
+func TestReadSpoolSkipsTornRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session-1.jsonl")
+	if err := os.WriteFile(path, []byte(`{"id":"req_1"}`+"\n"+`{"id":"req_2","bo`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	assertSpooled(t, path, "req_1")
+}
+
+func TestSaveSpooledSessionKeepsNewest(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	var saved string
+	for i := range DefaultSavedSessions + 2 {
+		path := filepath.Join(dir, fmt.Sprintf("session-%d.jsonl", 999999990+i))
+		if err := os.WriteFile(path, []byte(`{"id":"req_1"}`+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		saved, err = SaveSpooledSession(dir, SpooledSession{Path: path, SavedAt: start.Add(time.Duration(i) * time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if name := filepath.Base(saved); filepath.Dir(saved) != filepath.Join(dir, "saved") || !strings.HasPrefix(name, "session-20261016-090600-") {
+		t.Fatalf("expected the newest session in saved, got %s", saved)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "saved"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != DefaultSavedSessions || !strings.HasPrefix(entries[0].Name(), "session-20261016-090200-") {
+		t.Fatalf("expected the %d newest sessions, got %v", DefaultSavedSessions, entries)
+	}
+}
+
+func TestSaveSpooledSessionKeepsSessionsFromTheSameSecond(t *testing.T) {
+	dir := t.TempDir()
+	savedAt := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	saved := map[string]string{}
+	for _, id := range []string{"req_1", "req_2"} {
+		path := filepath.Join(dir, "session-"+id+".jsonl")
+		if err := os.WriteFile(path, []byte(`{"id":"`+id+`"}`+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := SaveSpooledSession(dir, SpooledSession{Path: path, SavedAt: savedAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved[id] = got
+	}
+
+	if saved["req_1"] == saved["req_2"] {
+		t.Fatalf("expected separate files, got %s twice", saved["req_1"])
+	}
+	for id, path := range saved {
+		assertSpooled(t, path, id)
+	}
+}
+
+func assertSpooled(t *testing.T, path string, ids ...string) {
+	t.Helper()
+	entries, err := ReadSpool(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.ID)
+	}
+	if !slices.Equal(got, ids) {
+		t.Fatalf("expected %v in the spool, got %v", ids, got)
+	}
+}
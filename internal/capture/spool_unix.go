@@ -0,0 +1,14 @@
+//go:build !windows
+
+package capture
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ProcessAlive reports whether a process with pid is running.
+func ProcessAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
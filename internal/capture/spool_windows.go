@@ -0,0 +1,16 @@
+//go:build windows
+
+package capture
+
+import "os"
+
+// ProcessAlive reports whether a process with pid is running. On Windows
+// FindProcess fails when there is no such process.
+func ProcessAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
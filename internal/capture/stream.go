@@ -0,0 +1,31 @@
+// internal/capture/stream.go
+package capture
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// Stream writes every completed request as a JSON line to w, in the same
+// format as a capture file, for piping into jq or other tools. It is safe
+// for concurrent use.
+type Stream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewStream returns a stream writing to w.
+func NewStream(w io.Writer) *Stream {
+	return &Stream{enc: json.NewEncoder(w)}
+}
+
+// Request writes entry as one line. Its signature matches
+// proxy.Server.AddListener.
+func (s *Stream) Request(entry model.RequestLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.enc.Encode(entry)
+}
@@ -0,0 +1,26 @@
+package capture
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestStreamWritesOneLinePerRequest(t *testing.T) {
+	var buf bytes.Buffer
+	stream := NewStream(&buf)
+	stream.Request(model.RequestLog{ID: "req_1", Method: "POST", URL: "/a", Body: "line one\nline two"})
+	stream.Request(model.RequestLog{ID: "req_2", Method: "GET", URL: "/b"})
+
+	entries, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != 2 {
+		t.Fatalf("expected 2 lines, got %d", lines)
+	}
+	if len(entries) != 2 || entries[0].Body != "line one\nline two" || entries[1].ID != "req_2" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+}
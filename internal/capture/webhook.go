@@ -0,0 +1,228 @@
+// internal/capture/webhook.go
+package capture
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+const (
+	// DefaultWebhookBatch is how many requests are sent per POST when no
+	// batch size is configured.
+	DefaultWebhookBatch = 20
+	// DefaultWebhookInterval is how often queued requests are sent when a
+	// batch hasn't filled up.
+	DefaultWebhookInterval = 5 * time.Second
+
+	// SignatureHeader carries the hex HMAC-SHA256 of the body, prefixed
+	// with "sha256=", when a secret is configured.
+	SignatureHeader = "X-Portal-Signature"
+
+	// webhookAttempts is how many times a batch is sent before it is
+	// dropped.
+	webhookAttempts = 4
+	// maxWebhookQueue bounds the requests waiting to be sent while the
+	// endpoint is slow or down; the oldest are dropped beyond it.
+	maxWebhookQueue = 5000
+)
+
+// WebhookConfig configures where and how captured requests are forwarded.
+type WebhookConfig struct {
+	URL string
+	// Secret signs each body with HMAC-SHA256 in SignatureHeader.
+	Secret string
+	// Source identifies this session to the receiver, e.g. the hostname.
+	Source    string
+	BatchSize int
+	Interval  time.Duration
+}
+
+// webhookBatch is the JSON body of each POST.
+type webhookBatch struct {
+	Source   string             `json:"source,omitempty"`
+	SentAt   time.Time          `json:"sent_at"`
+	Requests []model.RequestLog `json:"requests"`
+}
+
+// Webhook forwards captured requests to an external endpoint in batches, so
+// a central service can collect captures from many sessions. Batches that
+// fail with a network error, 429 or 5xx are retried with backoff before
+// being dropped.
+type Webhook struct {
+	config     WebhookConfig
+	client     *http.Client
+	logger     *zap.Logger
+	retryDelay time.Duration
+
+	mu      sync.Mutex
+	queue   []model.RequestLog
+	dropped int
+
+	flush     chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewWebhook starts forwarding requests passed to Request until Close.
+func NewWebhook(config WebhookConfig, logger *zap.Logger) *Webhook {
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultWebhookBatch
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultWebhookInterval
+	}
+	w := &Webhook{
+		config:     config,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+		retryDelay: time.Second,
+		flush:      make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Request queues a captured request. Its signature matches
+// proxy.Server.AddListener.
+func (w *Webhook) Request(entry model.RequestLog) {
+	w.mu.Lock()
+	w.queue = append(w.queue, entry)
+	if len(w.queue) > maxWebhookQueue {
+		w.queue = w.queue[1:]
+		w.dropped++
+	}
+	full := len(w.queue) >= w.config.BatchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close sends whatever is still queued and stops forwarding.
+func (w *Webhook) Close() {
+	w.closeOnce.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+func (w *Webhook) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.flush:
+		case <-w.stop:
+			w.sendQueued()
+			return
+		}
+		w.sendQueued()
+	}
+}
+
+// sendQueued sends the queue a batch at a time.
+func (w *Webhook) sendQueued() {
+	for {
+		w.mu.Lock()
+		n := min(len(w.queue), w.config.BatchSize)
+		batch := w.queue[:n:n]
+		w.queue = w.queue[n:]
+		dropped := w.dropped
+		w.dropped = 0
+		w.mu.Unlock()
+
+		if dropped > 0 {
+			w.logger.Warn("Capture webhook queue full, dropped oldest requests",
+				logging.Component("capture_webhook"),
+				zap.Int("dropped", dropped),
+			)
+		}
+		if n == 0 {
+			return
+		}
+		if err := w.deliver(batch); err != nil {
+			w.logger.Warn("Failed to forward captured requests",
+				logging.Component("capture_webhook"),
+				logging.URL(w.config.URL),
+				zap.Int("requests", n),
+				logging.Error(err),
+			)
+		}
+	}
+}
+
+// deliver posts one batch, retrying temporary failures.
+func (w *Webhook) deliver(batch []model.RequestLog) error {
+	body, err := json.Marshal(webhookBatch{
+		Source:   w.config.Source,
+		SentAt:   time.Now().UTC(),
+		Requests: batch,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode captured requests: %w", err)
+	}
+
+	delay := w.retryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(body)
+		if err == nil || !retry || attempt == webhookAttempts {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post sends body once and reports whether a failure is worth retrying.
+func (w *Webhook) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "portal")
+	if w.config.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.config.Secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("%s returned %s", w.config.URL, resp.Status)
+}
+
+// Sign returns the SignatureHeader value for body, so receivers can check
+// it with hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,103 @@
+package capture
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestWebhookBatchesAndSigns(t *testing.T) {
+	var mu sync.Mutex
+	var batches []webhookBatch
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get(SignatureHeader); got != Sign("s3cret", body) {
+			t.Errorf("unexpected signature %q", got)
+		}
+		var batch webhookBatch
+		if err := json.Unmarshal(body, &batch); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	webhook := NewWebhook(WebhookConfig{
+		URL:       receiver.URL,
+		Secret:    "s3cret",
+		Source:    "dev-box",
+		BatchSize: 2,
+		Interval:  time.Hour,
+	}, zap.NewNop())
+	for _, id := range []string{"req_1", "req_2", "req_3"} {
+		webhook.Request(model.RequestLog{ID: id, Method: "POST", URL: "/hook"})
+	}
+	webhook.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 2 || len(batches[0].Requests) != 2 || len(batches[1].Requests) != 1 {
+		t.Fatalf("expected batches of 2 and 1, got %+v", batches)
+	}
+	if batches[0].Source != "dev-box" || batches[1].Requests[0].ID != "req_3" {
+		t.Fatalf("unexpected batches %+v", batches)
+	}
+}
+
+func TestWebhookRetriesServerErrors(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	webhook := NewWebhook(WebhookConfig{URL: receiver.URL, Interval: time.Hour}, zap.NewNop())
+	webhook.retryDelay = time.Millisecond
+	webhook.Request(model.RequestLog{ID: "req_1"})
+	webhook.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer receiver.Close()
+
+	webhook := NewWebhook(WebhookConfig{URL: receiver.URL, Interval: time.Hour}, zap.NewNop())
+	webhook.retryDelay = time.Millisecond
+	webhook.Request(model.RequestLog{ID: "req_1"})
+	webhook.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 1 {
+		t.Fatalf("expected a single attempt, got %d", attempts)
+	}
+}
@@ -0,0 +1,252 @@
+// internal/chaos/chaos.go
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRuleNotFound is returned when updating or deleting an unknown rule.
+var ErrRuleNotFound = errors.New("chaos rule not found")
+
+// Rule injects a failure into a share of matching requests, written as
+// space-separated terms in any order, for example "5% POST /payments 502" or
+// "header:X-Debug=slow +2s". Requests match when they use Method, are under
+// Path and carry Header (with Value, when one is given). An unset percentage
+// is 100%. A rule adds Latency before the request is served, answers with
+// Status instead of serving it, or both.
+type Rule struct {
+	ID      string        `json:"id"`
+	Expr    string        `json:"expr"`
+	Percent float64       `json:"percent"`
+	Method  string        `json:"method,omitempty"`
+	Path    string        `json:"path,omitempty"`
+	Header  string        `json:"header,omitempty"`
+	Value   string        `json:"value,omitempty"`
+	Status  int           `json:"status,omitempty"`
+	Latency time.Duration `json:"latency,omitempty"`
+	// Injected counts the requests the rule has fired on.
+	Injected int64 `json:"injected"`
+}
+
+// ParseRule parses a rule expression.
+func ParseRule(expr string) (Rule, error) {
+	rule := Rule{Expr: strings.Join(strings.Fields(expr), " "), Percent: 100}
+	fail := func(format string, args ...any) (Rule, error) {
+		return Rule{}, fmt.Errorf("invalid chaos rule %q: %s", expr, fmt.Sprintf(format, args...))
+	}
+	seen := map[string]bool{}
+	for _, term := range strings.Fields(expr) {
+		var kind string
+		switch {
+		case strings.HasSuffix(term, "%"):
+			kind = "percentage"
+			percent, err := strconv.ParseFloat(strings.TrimSuffix(term, "%"), 64)
+			if err != nil || percent <= 0 || percent > 100 {
+				return fail("percentage must be above 0%% and at most 100%%")
+			}
+			rule.Percent = percent
+		case strings.HasPrefix(term, "/"):
+			kind = "path"
+			rule.Path = term
+		case strings.HasPrefix(term, "+"):
+			kind = "latency"
+			latency, err := time.ParseDuration(term[1:])
+			if err != nil || latency <= 0 {
+				return fail("latency must be a positive duration such as +2s")
+			}
+			rule.Latency = latency
+		case strings.HasPrefix(strings.ToLower(term), "header:"):
+			kind = "header"
+			name, value, _ := strings.Cut(term[len("header:"):], "=")
+			if name == "" {
+				return fail("header must be header:Name or header:Name=value")
+			}
+			rule.Header, rule.Value = http.CanonicalHeaderKey(name), value
+		case len(term) == 3 && isDigits(term):
+			kind = "status"
+			rule.Status, _ = strconv.Atoi(term)
+			if rule.Status < 400 || rule.Status > 599 {
+				return fail("status must be a 4xx or 5xx code")
+			}
+		case isLetters(term):
+			kind = "method"
+			rule.Method = strings.ToUpper(term)
+		default:
+			return fail("unknown term %q", term)
+		}
+		if seen[kind] {
+			return fail("more than one %s", kind)
+		}
+		seen[kind] = true
+	}
+	if rule.Status == 0 && rule.Latency == 0 {
+		return fail("want a status such as 502 or a latency such as +2s")
+	}
+	return rule, nil
+}
+
+func isDigits(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }) < 0
+}
+
+func isLetters(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z')
+	}) < 0
+}
+
+// matches reports whether r is one of the requests the rule targets.
+func (r Rule) matches(req *http.Request) bool {
+	if r.Method != "" && r.Method != req.Method {
+		return false
+	}
+	if prefix := strings.TrimSuffix(r.Path, "/"); prefix != "" && req.URL.Path != prefix && !strings.HasPrefix(req.URL.Path, prefix+"/") {
+		return false
+	}
+	if r.Header == "" {
+		return true
+	}
+	values := req.Header.Values(r.Header)
+	if r.Value == "" {
+		return len(values) > 0
+	}
+	return slices.Contains(values, r.Value)
+}
+
+// Fault is what the rules chose to do to one request.
+type Fault struct {
+	// Rules lists the IDs of the rules that fired.
+	Rules   []string
+	Latency time.Duration
+	// Status is the response to send instead of serving the request, or
+	// zero to serve it.
+	Status int
+}
+
+// Injected reports whether any rule fired.
+func (f Fault) Injected() bool {
+	return len(f.Rules) > 0
+}
+
+// Engine holds the chaos rules, which can be changed while requests are
+// served. A nil Engine injects nothing.
+type Engine struct {
+	mu    sync.Mutex
+	rules []Rule
+	seq   int
+	// roll returns a number in [0, 100) that a rule's percentage is
+	// compared against.
+	roll func() float64
+}
+
+// NewEngine returns an engine with rules, numbering those without an ID.
+func NewEngine(rules []Rule) *Engine {
+	e := &Engine{roll: func() float64 { return rand.Float64() * 100 }}
+	for _, rule := range rules {
+		if rule.ID == "" {
+			rule.ID = e.nextIDLocked()
+		}
+		e.rules = append(e.rules, rule)
+	}
+	return e
+}
+
+// Rules returns a copy of the rules in evaluation order.
+func (e *Engine) Rules() []Rule {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.rules)
+}
+
+// Add parses expr and appends it as a new rule.
+func (e *Engine) Add(expr string) (Rule, error) {
+	rule, err := ParseRule(expr)
+	if err != nil {
+		return Rule{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rule.ID = e.nextIDLocked()
+	e.rules = append(e.rules, rule)
+	return rule, nil
+}
+
+// Update replaces the rule with the given ID, keeping its position.
+func (e *Engine) Update(id, expr string) (Rule, error) {
+	rule, err := ParseRule(expr)
+	if err != nil {
+		return Rule{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	i := e.indexLocked(id)
+	if i < 0 {
+		return Rule{}, ErrRuleNotFound
+	}
+	rule.ID = id
+	e.rules[i] = rule
+	return rule, nil
+}
+
+// Delete removes the rule with the given ID.
+func (e *Engine) Delete(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	i := e.indexLocked(id)
+	if i < 0 {
+		return ErrRuleNotFound
+	}
+	e.rules = slices.Delete(e.rules, i, i+1)
+	return nil
+}
+
+// Decide picks the fault for r. Each matching rule fires on its share of
+// requests; the latencies of those that fire add up, and the first that
+// fires with a status fails the request, so later rules aren't consulted.
+func (e *Engine) Decide(r *http.Request) Fault {
+	var fault Fault
+	if e == nil {
+		return fault
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if !rule.matches(r) || e.roll() >= rule.Percent {
+			continue
+		}
+		rule.Injected++
+		fault.Rules = append(fault.Rules, rule.ID)
+		fault.Latency += rule.Latency
+		if rule.Status != 0 {
+			fault.Status = rule.Status
+			break
+		}
+	}
+	return fault
+}
+
+func (e *Engine) indexLocked(id string) int {
+	return slices.IndexFunc(e.rules, func(rule Rule) bool { return rule.ID == id })
+}
+
+func (e *Engine) nextIDLocked() string {
+	for {
+		e.seq++
+		id := fmt.Sprintf("chaos_%d", e.seq)
+		if e.indexLocked(id) < 0 {
+			return id
+		}
+	}
+}
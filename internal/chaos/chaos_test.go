@@ -0,0 +1,103 @@
+package chaos
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("5%  post /payments 502")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Percent != 5 || rule.Method != "POST" || rule.Path != "/payments" || rule.Status != 502 || rule.Latency != 0 || rule.Expr != "5% post /payments 502" {
+		t.Fatalf("unexpected rule %+v", rule)
+	}
+
+	rule, err = ParseRule("header:x-debug=slow +2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Percent != 100 || rule.Header != "X-Debug" || rule.Value != "slow" || rule.Latency != 2*time.Second || rule.Status != 0 {
+		t.Fatalf("unexpected rule %+v", rule)
+	}
+
+	for _, expr := range []string{
+		"",
+		"/payments",
+		"0% 502",
+		"150% 502",
+		"200",
+		"+0s",
+		"502 503",
+		"header: 502",
+		"POST /a /b 502",
+		"POST-ish 502",
+	} {
+		if _, err := ParseRule(expr); err == nil {
+			t.Fatalf("expected %q to be rejected", expr)
+		}
+	}
+}
+
+func TestEngineDecide(t *testing.T) {
+	var rules []Rule
+	for _, expr := range []string{"header:X-Debug=slow +2s", "50% POST /payments 502", "/payments +100ms 503"} {
+		rule, err := ParseRule(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+	engine := NewEngine(rules)
+	roll := 0.0
+	engine.roll = func() float64 { return roll }
+
+	get := httptest.NewRequest(http.MethodGet, "/payments/42", nil)
+	get.Header.Set("X-Debug", "slow")
+	if fault := engine.Decide(get); fault.Latency != 2100*time.Millisecond || fault.Status != 503 || len(fault.Rules) != 2 {
+		t.Fatalf("expected latencies to add up before the failing rule, got %+v", fault)
+	}
+
+	post := httptest.NewRequest(http.MethodPost, "/payments", nil)
+	if fault := engine.Decide(post); fault.Status != 502 || fault.Latency != 0 || fault.Rules[0] != "chaos_2" {
+		t.Fatalf("expected the first failing rule to win, got %+v", fault)
+	}
+	roll = 75
+	if fault := engine.Decide(post); fault.Status != 503 || fault.Rules[0] != "chaos_3" {
+		t.Fatalf("expected the 50%% rule not to fire on a high roll, got %+v", fault)
+	}
+
+	other := httptest.NewRequest(http.MethodGet, "/paymentsx", nil)
+	if fault := engine.Decide(other); fault.Injected() {
+		t.Fatalf("expected no fault outside the path, got %+v", fault)
+	}
+
+	if got := engine.Rules(); got[1].Injected != 1 || got[2].Injected != 2 {
+		t.Fatalf("unexpected injected counts %+v", got)
+	}
+
+	if _, err := engine.Update("chaos_2", "POST /payments 500"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Delete("chaos_1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Delete("chaos_1"); err != ErrRuleNotFound {
+		t.Fatalf("expected ErrRuleNotFound, got %v", err)
+	}
+	added, err := engine.Add("GET 429")
+	if err != nil || added.ID != "chaos_4" {
+		t.Fatalf("unexpected added rule %+v, %v", added, err)
+	}
+	if got := engine.Rules(); len(got) != 3 || got[0].Status != 500 {
+		t.Fatalf("unexpected rules %+v", got)
+	}
+
+	var none *Engine
+	if none.Decide(post).Injected() || none.Rules() != nil {
+		t.Fatal("expected a nil engine to inject nothing")
+	}
+}
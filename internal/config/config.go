@@ -1,17 +1,32 @@
 package config
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"net"
 	"net/netip"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"tailscale.com/tailcfg"
+
+	"github.com/jaxxstorm/portal/internal/alert"
+	"github.com/jaxxstorm/portal/internal/capture"
+	"github.com/jaxxstorm/portal/internal/chaos"
+	"github.com/jaxxstorm/portal/internal/expect"
+	"github.com/jaxxstorm/portal/internal/middleware"
+	"github.com/jaxxstorm/portal/internal/sshtunnel"
 )
 
 const (
@@ -24,14 +39,91 @@ const (
 	legacyListenModeKey    = "tsnet-listen-mode"
 	serviceNameKey         = "service-name"
 	legacyServiceNameKey   = "tsnet-service-name"
+
+	// CommandInit runs the interactive first-run setup wizard.
+	CommandInit = "init"
+	OutputText  = "text"
+	OutputJSON  = "json"
+
+	// DefaultBind keeps the local servers behind tailscale serve off the
+	// LAN.
+	DefaultBind = "127.0.0.1"
+
+	// DefaultUIPath is where --ui-path without a value mounts the web UI.
+	DefaultUIPath = "/__portal/ui"
+
+	// CommandDoctor runs the Tailscale/Funnel prerequisite diagnostics.
+	CommandDoctor = "doctor"
+
+	// Token subcommands manage API tokens for the web UI API.
+	CommandTokenCreate = "token create"
+	CommandTokenList   = "token list"
+	CommandTokenRevoke = "token revoke"
+
+	// CommandSessionsList lists the session manifests of past runs.
+	CommandSessionsList = "sessions list"
+
+	// CommandImport loads a HAR or capture file into a running instance.
+	CommandImport = "import"
+
+	// CommandExport writes a running instance's request history as NDJSON
+	// or CSV.
+	CommandExport = "export"
+
+	// CommandExpect waits for a running instance to capture matching
+	// requests and reports the result as JUnit XML.
+	CommandExpect = "expect"
+
+	// CommandAttach shows the TUI of an instance serving the control API.
+	CommandAttach = "attach"
+
+	// CommandWire prints the transcript of a --wire-capture file.
+	CommandWire = "wire"
+
+	// CommandRun starts the target as a child process and exposes it.
+	CommandRun = "run"
+
+	// Events --notify can raise desktop notifications for.
+	NotifyFirstRequest = "first-request"
+	NotifyUpstream5xx  = "upstream-5xx"
+	NotifyReady        = "ready"
+	NotifyUpstreamDown = "upstream-down"
+	notifyAll          = "all"
+
+	// Upstream quirks --upstream-quirk can enable.
+	QuirkHTTP10           = "http1.0"
+	QuirkNoExpectContinue = "no-expect-continue"
+	QuirkNoChunked        = "no-chunked"
+	QuirkLenientHeaders   = "lenient-headers"
+
+	// When `portal run` restarts its command.
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+
+	// What --unmatched does with requests no mount matches.
+	UnmatchedTarget   = "target"
+	UnmatchedNotFound = "404"
+	UnmatchedRedirect = "redirect"
+	UnmatchedMount    = "mount"
 )
 
+// NotifyEvents lists the events --notify accepts, besides "all".
+var NotifyEvents = []string{NotifyFirstRequest, NotifyUpstream5xx, NotifyReady, NotifyUpstreamDown}
+
+// UpstreamQuirks lists the quirks --upstream-quirk accepts.
+var UpstreamQuirks = []string{QuirkHTTP10, QuirkNoExpectContinue, QuirkNoChunked, QuirkLenientHeaders}
+
+// RestartPolicies lists the values --restart accepts.
+var RestartPolicies = []string{RestartNever, RestartOnFailure, RestartAlways}
+
 // Config holds the parsed and validated configuration
 type Config struct {
 	Port             int
 	TailscaleName    string
 	Funnel           bool
 	FunnelAllowlist  []netip.Prefix
+	AllowMethods     []string
 	Verbose          bool
 	JSON             bool
 	LogFile          string
@@ -43,11 +135,347 @@ type Config struct {
 	NoTUI            bool
 	NoUI             bool
 	UIPort           int
+	UIPath           string
+	GRPCSocket       string
+	Bind             string
 	Version          bool
 	Mock             bool
 	CleanupServe     bool
+	DryRun           bool
 	TSNetListenMode  string
 	TSNetServiceName string
+	CaptureFile      string
+	CaptureMaxSize   int
+	Output           string
+	MockConfig       string
+	// Deterministic pins mock timestamps and request IDs and seeds random
+	// template values, latencies and injected errors.
+	Deterministic bool
+	Record        string
+	Playback      string
+	// ProjectConfig is the .portal.yaml merged from the working directory,
+	// empty when there was none.
+	ProjectConfig string
+
+	// CORS policy applied to served responses; disabled when CORSOrigins is
+	// empty.
+	CORSOrigins     []string
+	CORSMethods     []string
+	CORSCredentials bool
+
+	// PreserveHost forwards the client's Host header to the target;
+	// UpstreamHost replaces it with a fixed value. By default the target
+	// sees its own address.
+	PreserveHost bool
+	UpstreamHost string
+
+	// ForwardedHeader adds an RFC 7239 Forwarded header to proxied requests.
+	ForwardedHeader bool
+
+	// RewriteCookies makes the target's cookies work through the portal URL;
+	// CookieSameSite ("None", "Lax" or "Strict") also replaces their
+	// SameSite attribute and implies RewriteCookies.
+	RewriteCookies bool
+	CookieSameSite string
+
+	// NoRewriteLocation passes the target's absolute redirects to its own
+	// address through unchanged instead of pointing them at the portal URL.
+	NoRewriteLocation bool
+
+	// RewriteHTML points absolute URLs to the target in HTML responses, such
+	// as asset links built from localhost, at the portal URL.
+	RewriteHTML bool
+
+	// Mounts route path prefixes to other upstreams, set with repeated
+	// --mount /api=localhost:3000/api flags.
+	Mounts []Mount
+	// Unmatched is what happens to requests no mount matches, set with
+	// --unmatched. After Load its Action is always set.
+	Unmatched Unmatched
+
+	// Quotas limit how many requests each matching client may send per
+	// period, set with repeated --quota tag:ci=1000/h flags. The first
+	// matching quota applies.
+	Quotas []Quota
+
+	// Exclude keeps matching requests out of the history, stats and logs,
+	// set with repeated --exclude /healthz or --exclude 'GET /assets/*'
+	// flags.
+	Exclude []Exclude
+
+	// Chaos injects latency and failures into matching requests, set with
+	// repeated --chaos "5% POST /payments 502" flags or the config file's
+	// chaos list.
+	Chaos []chaos.Rule
+
+	// Exposes run an extra tsnet node per entry, each proxying to its own
+	// target, set with repeated --expose api=3000 flags. Requests from all
+	// nodes share one capture pipeline.
+	Exposes []Expose
+
+	// Middleware is the ordered chain from the config file's middleware
+	// list, run around every request.
+	Middleware []middleware.Spec
+
+	// TargetHost is a tailnet peer to proxy to instead of localhost, set with
+	// --target host:port. Port then holds the peer's port.
+	TargetHost string
+
+	// LocalTargetHost is the address the local target listens on, e.g.
+	// "127.0.0.1" or "::1", set with --target-host. When empty, portal tries
+	// both loopback addresses.
+	LocalTargetHost string
+
+	// FunnelPaths are path prefixes exposed publicly through Funnel on port
+	// 443 while everything else stays on the tailnet-only serve port.
+	FunnelPaths []string
+	// ScannerStats counts Funnel requests that look like internet scanner
+	// traffic in stats, and ScannerBan refuses every later request from
+	// their source for the rest of the session.
+	ScannerStats bool
+	ScannerBan   bool
+	// GeoIPDBs are MaxMind DB files (GeoLite2 Country, City or ASN) used
+	// to look up where public clients are.
+	GeoIPDBs []string
+	// WireCapture is a file the exact bytes exchanged with clients are
+	// recorded to.
+	WireCapture string
+
+	// SSH exposes the proxy on a bastion through a reverse SSH tunnel
+	// (user@host[:port]) instead of Tailscale. SSHRemotePort is the port
+	// opened on the bastion, zero letting the server pick, and SSHIdentity
+	// an optional private key.
+	SSH           string
+	SSHRemotePort int
+	SSHIdentity   string
+
+	// Request limits for the proxy listener. Zero disables a limit.
+	MaxBodySize       int64
+	MaxHeaderSize     int
+	MaxHeaders        int
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// Upstream transport tuning. Zero keeps the net/http default.
+	UpstreamMaxIdleConns     int
+	UpstreamIdleTimeout      time.Duration
+	UpstreamDialTimeout      time.Duration
+	UpstreamDisableKeepAlive bool
+
+	// Upstream request timeouts: the whole exchange, the wait for response
+	// headers and the gap between body reads. TimeoutRoutes, from the config
+	// file's upstream-timeouts list, override them per path and method.
+	UpstreamTimeout             time.Duration
+	UpstreamHeaderTimeout       time.Duration
+	UpstreamResponseIdleTimeout time.Duration
+	TimeoutRoutes               []TimeoutRoute
+
+	// UpstreamQuirks work around legacy targets. QuirkRoutes, from the config
+	// file's upstream-quirks list, replace them per path and method.
+	UpstreamQuirks []string
+	QuirkRoutes    []QuirkRoute
+
+	// Stream writes every completed request as a JSON line to stdout.
+	Stream bool
+
+	// StatusEndpoint serves /__portal/status on the exposed service.
+	StatusEndpoint bool
+
+	// SensitivePaths are path prefixes or globs whose requests are flagged
+	// in the TUI; with ApproveSensitive they wait for the operator to allow
+	// them, for up to ApprovalTimeout.
+	SensitivePaths   []string
+	ApproveSensitive bool
+	ApprovalTimeout  time.Duration
+
+	// DetectDuplicates marks repeated webhook deliveries seen within
+	// DuplicateWindow; SkipDuplicates also answers them with 200 without
+	// forwarding. DuplicateHeaders are extra delivery ID headers.
+	DetectDuplicates bool
+	SkipDuplicates   bool
+	DuplicateWindow  time.Duration
+	DuplicateHeaders []string
+
+	// ExpiresIn and MaxRequests stop portal once the time or request budget
+	// is used up. Zero means no limit.
+	ExpiresIn   time.Duration
+	MaxRequests int
+
+	// Pprof serves Go runtime profiles at /debug/pprof/ on the web UI.
+	Pprof bool
+
+	// OAuthCallback is the path portal answers OAuth redirects on itself,
+	// capturing the code and state. With OAuthTokenURL the code is exchanged
+	// for tokens using the client credentials and optional PKCE verifier;
+	// OAuthRedirectURI overrides the redirect_uri sent with it.
+	OAuthCallback     string
+	OAuthTokenURL     string
+	OAuthClientID     string
+	OAuthClientSecret string
+	OAuthCodeVerifier string
+	OAuthRedirectURI  string
+
+	// JWKSURL verifies the signatures of bearer JWTs shown in request
+	// details. Without it tokens are decoded but left unverified.
+	JWKSURL string
+
+	// Notify lists the events that raise a desktop notification.
+	Notify []string
+
+	// CaptureWebhook POSTs captured requests in batches of up to
+	// CaptureWebhookBatch, at least every CaptureWebhookInterval, to this
+	// URL. CaptureWebhookSecret signs each batch with HMAC-SHA256.
+	CaptureWebhook         string
+	CaptureWebhookSecret   string
+	CaptureWebhookBatch    int
+	CaptureWebhookInterval time.Duration
+
+	// Alerts, from the config file's alerts list, are raised as TUI banners
+	// and log warnings, and POSTed to AlertWebhook when set.
+	Alerts       []alert.Rule
+	AlertWebhook string
+
+	// ShareTerminal streams a read-only copy of the TUI to the web UI at
+	// /ui/term so tailnet peers can watch along.
+	ShareTerminal bool
+
+	// TUIRefresh is how often the TUI polls for changed stats; LowPower
+	// polls at most every 10s and redraws less often, for battery life.
+	TUIRefresh time.Duration
+	LowPower   bool
+
+	// WaitForTarget starts serving before the target port is listening and
+	// begins forwarding once it comes up. A zero timeout waits indefinitely.
+	WaitForTarget        bool
+	WaitForTargetTimeout time.Duration
+
+	// Command is the subcommand that was invoked, or empty for the default
+	// proxy/mock run.
+	Command string
+
+	// TokenName, TokenScopes and TokenID are the arguments to the token
+	// subcommands.
+	TokenName   string
+	TokenScopes []string
+	TokenID     string
+
+	// ImportFile, ImportURL and ImportToken are the arguments to the import
+	// subcommand. ImportURL is the running instance's web UI.
+	ImportFile  string
+	ImportURL   string
+	ImportToken string
+
+	// ExportFile, ExportURL, ExportFormat and ExportColumns are the
+	// arguments to the export subcommand. An empty ExportFile writes to
+	// stdout; ExportColumns only applies to CSV.
+	ExportFile    string
+	ExportURL     string
+	ExportFormat  string
+	ExportColumns []string
+
+	// Expect, ExpectURL and ExpectJUnit are the arguments to the expect
+	// subcommand. An empty ExpectJUnit writes the report to stdout.
+	Expect      expect.Expectation
+	ExpectURL   string
+	ExpectJUnit string
+
+	// AttachSocket is the control socket the attach subcommand connects to.
+	AttachSocket string
+
+	// WireFile is the capture file the wire subcommand prints, limited to
+	// connection WireConn when it is set.
+	WireFile string
+	WireConn uint64
+
+	// RunCommand is the command `portal run` starts and supervises, and
+	// Restart says when it is started again after exiting.
+	RunCommand []string
+	Restart    string
+	// DetectPort is set for `portal run` without a port; the target port is
+	// found from the sockets the command listens on.
+	DetectPort bool
+	// Namespace mounts the service at /<namespace> and the web UI at
+	// /<namespace>/ui on a tailscaled node shared with other developers.
+	Namespace string
+	// NoRequestBody and NoResponseBody keep that direction's bodies out of
+	// captured requests, e.g. when responses carry personal data.
+	NoRequestBody  bool
+	NoResponseBody bool
+	// Spool keeps an on-disk copy of the request history, so a crashed
+	// session can be recovered on the next start. SpoolMaxSize bounds it in
+	// megabytes; zero means capture.DefaultSpoolMaxBytes.
+	Spool        bool
+	SpoolMaxSize int
+	// TSNetVerbose includes tsnet's backend logs, which --verbose also
+	// turns on along with portal's own debug logs.
+	TSNetVerbose bool
+	// FailFast exits at the first setup or runtime failure that leaves
+	// portal unable to serve, instead of running on with the failure shown.
+	FailFast bool
+	// NoSessionManifest skips writing the signed record of what this run
+	// exposed and who connected.
+	NoSessionManifest bool
+}
+
+// Mount routes requests under Path to Target, replacing the prefix with
+// Target's path.
+type Mount struct {
+	Path   string
+	Target *url.URL
+}
+
+// Unmatched answers requests that match no mount. Location is the redirect
+// URL for UnmatchedRedirect and the mount path for UnmatchedMount.
+type Unmatched struct {
+	Action   string
+	Location string
+}
+
+// Exclude matches requests to keep out of the history by Path, a prefix or
+// a glob, and Method when set.
+type Exclude struct {
+	Method string
+	Path   string
+}
+
+// String formats the rule as --exclude takes it, e.g. "GET /healthz".
+func (e Exclude) String() string {
+	if e.Method == "" {
+		return e.Path
+	}
+	return e.Method + " " + e.Path
+}
+
+// Quota allows each client matched by Match Limit requests per Period. Match
+// is a tailnet login, a tag such as "tag:ci", or "*" for every client.
+type Quota struct {
+	Match  string
+	Limit  int
+	Period time.Duration
+}
+
+// String formats the quota as --quota takes it, e.g. "tag:ci=1000/h".
+func (q Quota) String() string {
+	period := q.Period.String()
+	switch q.Period {
+	case time.Second:
+		period = "s"
+	case time.Minute:
+		period = "m"
+	case time.Hour:
+		period = "h"
+	case 24 * time.Hour:
+		period = "d"
+	}
+	return fmt.Sprintf("%s=%d/%s", q.Match, q.Limit, period)
+}
+
+// Expose serves Target on its own tsnet node with hostname Name.
+type Expose struct {
+	Name   string
+	Target *url.URL
 }
 
 // Parse parses command line arguments and returns a validated configuration
@@ -62,6 +490,14 @@ func ParseArgs(args []string) (*Config, error) {
 	if err := configureViper(v); err != nil {
 		return nil, err
 	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine working directory: %w", err)
+	}
+	projectConfig, err := mergeProjectConfig(v, workDir)
+	if err != nil {
+		return nil, err
+	}
 
 	state := &parseState{}
 	cmd, err := newRootCommand(v, state)
@@ -73,11 +509,12 @@ func ParseArgs(args []string) (*Config, error) {
 	cmd.SilenceErrors = true
 	cmd.SilenceUsage = true
 
-	if err := cmd.Execute(); err != nil {
+	executed, err := cmd.ExecuteC()
+	if err != nil {
 		return nil, err
 	}
 
-	if helpRequested(cmd, args) {
+	if helpRequested(executed, args) || !executed.Runnable() {
 		return nil, pflag.ErrHelp
 	}
 
@@ -111,6 +548,84 @@ func ParseArgs(args []string) (*Config, error) {
 		serviceName = "svc:portal"
 	}
 
+	waitForTarget, waitForTargetTimeout, err := parseWaitForTarget(v.GetString("wait-for-target"))
+	if err != nil {
+		return nil, err
+	}
+
+	mounts, err := parseMounts(normalizeList(v.Get("mount")))
+	if err != nil {
+		return nil, err
+	}
+	unmatched, err := parseUnmatched(v.GetString("unmatched"))
+	if err != nil {
+		return nil, err
+	}
+	quotas, err := parseQuotas(normalizeList(v.Get("quota")))
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := parseExcludes(normalizeList(v.Get("exclude")))
+	if err != nil {
+		return nil, err
+	}
+	var chaosRules []chaos.Rule
+	for _, expr := range normalizeList(v.Get("chaos")) {
+		rule, err := chaos.ParseRule(expr)
+		if err != nil {
+			return nil, err
+		}
+		chaosRules = append(chaosRules, rule)
+	}
+	exposes, err := parseExposes(normalizeList(v.Get("expose")))
+	if err != nil {
+		return nil, err
+	}
+	timeoutRoutes, err := parseTimeoutRoutes(v)
+	if err != nil {
+		return nil, err
+	}
+	upstreamQuirks := normalizeList(v.Get("upstream-quirk"))
+	if err := validateQuirks("--upstream-quirk", upstreamQuirks); err != nil {
+		return nil, err
+	}
+	quirkRoutes, err := parseQuirkRoutes(v)
+	if err != nil {
+		return nil, err
+	}
+	var middlewares []middleware.Spec
+	if err := v.UnmarshalKey("middleware", &middlewares); err != nil {
+		return nil, fmt.Errorf("invalid middleware config: %w", err)
+	}
+	for _, spec := range middlewares {
+		if err := spec.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	alerts, err := parseAlerts(v)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBodySize, err := parseByteSize("max-body-size", v.GetString("max-body-size"))
+	if err != nil {
+		return nil, err
+	}
+	maxHeaderSize, err := parseByteSize("max-header-size", v.GetString("max-header-size"))
+	if err != nil {
+		return nil, err
+	}
+
+	funnelPaths, err := parseFunnelPaths(normalizeList(v.Get("funnel-path")))
+	if err != nil {
+		return nil, err
+	}
+
+	sensitivePaths, err := parseSensitivePaths(normalizeList(v.Get("sensitive-path")))
+	if err != nil {
+		return nil, err
+	}
+
 	funnelAllowlist, err := parseFunnelAllowlist(normalizeList(v.Get("funnel-allowlist")))
 	if err != nil {
 		return nil, err
@@ -121,22 +636,157 @@ func ParseArgs(args []string) (*Config, error) {
 		TailscaleName:    deviceName,
 		Funnel:           v.GetBool("funnel"),
 		FunnelAllowlist:  funnelAllowlist,
+		AllowMethods:     normalizeList(v.Get("allow-methods")),
 		Verbose:          v.GetBool("verbose"),
 		JSON:             v.GetBool("json"),
 		LogFile:          v.GetString("log-file"),
 		AuthKey:          v.GetString("auth-key"),
 		ForceTsnet:       v.GetBool("force-tsnet"),
 		SetPath:          v.GetString("set-path"),
+		Namespace:        strings.TrimSpace(v.GetString("namespace")),
 		ServePort:        v.GetInt("serve-port"),
 		UseHTTPS:         v.GetBool("use-https"),
 		NoTUI:            v.GetBool("no-tui"),
 		NoUI:             v.GetBool("no-ui"),
 		UIPort:           v.GetInt("ui-port"),
+		UIPath:           strings.TrimSpace(v.GetString("ui-path")),
+		GRPCSocket:       strings.TrimSpace(v.GetString("grpc-socket")),
+		Bind:             strings.TrimSpace(v.GetString("bind")),
 		Version:          v.GetBool("version"),
 		Mock:             v.GetBool("mock"),
 		CleanupServe:     v.GetBool("cleanup-serve"),
+		DryRun:           v.GetBool("dry-run"),
 		TSNetListenMode:  listenMode,
 		TSNetServiceName: serviceName,
+		CaptureFile:      strings.TrimSpace(v.GetString("capture-file")),
+		CaptureMaxSize:   v.GetInt("capture-max-size"),
+		NoRequestBody:    v.GetBool("no-request-body"),
+		NoResponseBody:   v.GetBool("no-response-body"),
+		Spool:            v.GetBool("spool"),
+		SpoolMaxSize:     v.GetInt("spool-max-size"),
+		TSNetVerbose:     v.GetBool("tsnet-verbose"),
+		FailFast:         v.GetBool("fail-fast"),
+		Output:           strings.ToLower(strings.TrimSpace(v.GetString("output"))),
+		MockConfig:       strings.TrimSpace(v.GetString("mock-config")),
+		Deterministic:    v.GetBool("deterministic"),
+		Record:           strings.TrimSpace(v.GetString("record")),
+		Playback:         strings.TrimSpace(v.GetString("playback")),
+		ProjectConfig:    projectConfig,
+		Command:          state.command,
+		TokenName:        state.tokenName,
+		TokenScopes:      state.tokenScopes,
+		TokenID:          state.tokenID,
+		ImportFile:       state.importFile,
+		ImportURL:        strings.TrimRight(state.importURL, "/"),
+		ImportToken:      cmp.Or(state.importToken, os.Getenv("PORTAL_TOKEN")),
+		ExportFile:       state.exportFile,
+		ExportURL:        strings.TrimRight(state.exportURL, "/"),
+		ExportFormat:     state.exportFormat,
+		ExportColumns:    state.exportColumns,
+		Expect:           state.expect,
+		ExpectURL:        strings.TrimRight(state.expectURL, "/"),
+		ExpectJUnit:      state.expectJUnit,
+		AttachSocket:     state.attachSocket,
+		WireFile:         state.wireFile,
+		WireConn:         state.wireConn,
+		RunCommand:       state.runCommand,
+		Restart:          strings.ToLower(strings.TrimSpace(v.GetString("restart"))),
+
+		NoSessionManifest: v.GetBool("no-session-manifest"),
+
+		CORSOrigins:     normalizeList(v.Get("cors-origins")),
+		CORSMethods:     normalizeList(v.Get("cors-methods")),
+		CORSCredentials: v.GetBool("cors-credentials"),
+
+		PreserveHost: v.GetBool("preserve-host"),
+		UpstreamHost: strings.TrimSpace(v.GetString("upstream-host")),
+
+		ForwardedHeader: v.GetBool("forwarded-header"),
+
+		RewriteCookies: v.GetBool("rewrite-cookies"),
+		CookieSameSite: strings.TrimSpace(v.GetString("cookie-samesite")),
+
+		NoRewriteLocation: v.GetBool("no-rewrite-location"),
+		RewriteHTML:       v.GetBool("rewrite-html"),
+
+		Mounts:     mounts,
+		Unmatched:  unmatched,
+		Exposes:    exposes,
+		Middleware: middlewares,
+
+		Quotas:  quotas,
+		Exclude: exclude,
+		Chaos:   chaosRules,
+
+		FunnelPaths:  funnelPaths,
+		ScannerStats: v.GetBool("scanner-stats"),
+		ScannerBan:   v.GetBool("scanner-ban"),
+		GeoIPDBs:     normalizeList(v.Get("geoip-db")),
+		WireCapture:  strings.TrimSpace(v.GetString("wire-capture")),
+
+		SSH:           strings.TrimSpace(v.GetString("ssh")),
+		SSHRemotePort: v.GetInt("ssh-remote-port"),
+		SSHIdentity:   v.GetString("ssh-identity"),
+
+		MaxBodySize:       maxBodySize,
+		MaxHeaderSize:     int(maxHeaderSize),
+		MaxHeaders:        v.GetInt("max-headers"),
+		ReadHeaderTimeout: v.GetDuration("read-header-timeout"),
+		ReadTimeout:       v.GetDuration("read-timeout"),
+		WriteTimeout:      v.GetDuration("write-timeout"),
+		IdleTimeout:       v.GetDuration("idle-timeout"),
+
+		UpstreamMaxIdleConns:     v.GetInt("upstream-max-idle-conns"),
+		UpstreamIdleTimeout:      v.GetDuration("upstream-idle-timeout"),
+		UpstreamDialTimeout:      v.GetDuration("upstream-dial-timeout"),
+		UpstreamDisableKeepAlive: v.GetBool("upstream-disable-keepalive"),
+
+		UpstreamTimeout:             v.GetDuration("upstream-timeout"),
+		UpstreamHeaderTimeout:       v.GetDuration("upstream-header-timeout"),
+		UpstreamResponseIdleTimeout: v.GetDuration("upstream-response-idle-timeout"),
+		TimeoutRoutes:               timeoutRoutes,
+
+		UpstreamQuirks: upstreamQuirks,
+		QuirkRoutes:    quirkRoutes,
+
+		ShareTerminal:  v.GetBool("share-terminal"),
+		TUIRefresh:     v.GetDuration("tui-refresh"),
+		LowPower:       v.GetBool("low-power"),
+		StatusEndpoint: v.GetBool("status-endpoint"),
+		Stream:         v.GetBool("stream"),
+		Pprof:          v.GetBool("pprof"),
+		ExpiresIn:      v.GetDuration("expires-in"),
+
+		SensitivePaths:   sensitivePaths,
+		ApproveSensitive: v.GetBool("approve-sensitive"),
+		ApprovalTimeout:  v.GetDuration("approval-timeout"),
+		MaxRequests:      v.GetInt("max-requests"),
+		JWKSURL:          strings.TrimSpace(v.GetString("jwks-url")),
+
+		OAuthCallback:     strings.TrimSpace(v.GetString("oauth-callback")),
+		OAuthTokenURL:     strings.TrimSpace(v.GetString("oauth-token-url")),
+		OAuthClientID:     v.GetString("oauth-client-id"),
+		OAuthClientSecret: v.GetString("oauth-client-secret"),
+		OAuthCodeVerifier: v.GetString("oauth-code-verifier"),
+		OAuthRedirectURI:  strings.TrimSpace(v.GetString("oauth-redirect-uri")),
+
+		DetectDuplicates: v.GetBool("detect-duplicates") || v.GetBool("skip-duplicates"),
+		SkipDuplicates:   v.GetBool("skip-duplicates"),
+		DuplicateWindow:  v.GetDuration("duplicate-window"),
+		DuplicateHeaders: normalizeList(v.Get("duplicate-header")),
+
+		WaitForTarget:        waitForTarget,
+		WaitForTargetTimeout: waitForTargetTimeout,
+
+		Notify: normalizeList(v.Get("notify")),
+
+		CaptureWebhook:         strings.TrimSpace(v.GetString("capture-webhook")),
+		CaptureWebhookSecret:   v.GetString("capture-webhook-secret"),
+		CaptureWebhookBatch:    v.GetInt("capture-webhook-batch"),
+		CaptureWebhookInterval: v.GetDuration("capture-webhook-interval"),
+
+		Alerts:       alerts,
+		AlertWebhook: strings.TrimSpace(v.GetString("alert-webhook")),
 	}
 
 	// Handle version flag
@@ -149,19 +799,361 @@ func ParseArgs(args []string) (*Config, error) {
 		return cfg, nil
 	}
 
+	// Subcommands handle their own validation; run is validated like a
+	// plain run.
+	if cfg.Command != "" && cfg.Command != CommandRun {
+		cfg.applyAutoConfiguration()
+		return cfg, nil
+	}
+
+	if cfg.Record != "" && cfg.Playback != "" {
+		return nil, fmt.Errorf("cannot combine --record and --playback")
+	}
+	if cfg.Record != "" && cfg.Mock {
+		return nil, fmt.Errorf("--record proxies to a real target and cannot be combined with --mock")
+	}
+	// Playback serves recorded responses without an upstream, so it runs as
+	// a mock backend.
+	if cfg.Playback != "" {
+		cfg.Mock = true
+	}
+
+	if target := strings.TrimSpace(v.GetString("target")); target != "" {
+		if cfg.Mock {
+			return nil, fmt.Errorf("cannot combine --target and --mock")
+		}
+		if cfg.Port != 0 {
+			return nil, fmt.Errorf("cannot specify both port and --target%s", usageSuffix)
+		}
+		host, port, err := parseTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TargetHost = host
+		cfg.Port = port
+	}
+
+	if host := strings.TrimSpace(v.GetString("target-host")); host != "" {
+		if cfg.TargetHost != "" {
+			return nil, fmt.Errorf("--target-host sets where the local target listens and cannot be combined with --target")
+		}
+		host, err := parseTargetHost(host)
+		if err != nil {
+			return nil, err
+		}
+		cfg.LocalTargetHost = host
+	}
+
+	if cfg.Command == CommandRun {
+		switch {
+		case cfg.Mock:
+			return nil, fmt.Errorf("portal run exposes the command it starts and cannot be combined with --mock")
+		case cfg.TargetHost != "":
+			return nil, fmt.Errorf("portal run exposes the command it starts and cannot be combined with --target")
+		case !slices.Contains(RestartPolicies, cfg.Restart):
+			return nil, fmt.Errorf("invalid --restart %q: must be one of %s", cfg.Restart, strings.Join(RestartPolicies, ", "))
+		}
+		// The command is only just starting, so portal waits for its port,
+		// finding it first when none was given.
+		cfg.WaitForTarget = true
+		cfg.DetectPort = cfg.Port == 0
+	}
+
+	// An OAuth callback needs nothing behind it, so without a target the
+	// other paths get mock responses.
+	if cfg.OAuthCallback != "" && cfg.Port == 0 && !cfg.DetectPort && len(cfg.Mounts) == 0 && len(cfg.Exposes) == 0 {
+		cfg.Mock = true
+	}
+
 	// Validate arguments
 	if cfg.Mock && cfg.Port != 0 {
 		return nil, fmt.Errorf("cannot specify both port and --mock flag%s", usageSuffix)
 	}
 
-	if !cfg.Mock && cfg.Port == 0 {
+	if cfg.Mock && len(cfg.Mounts) > 0 {
+		return nil, fmt.Errorf("cannot combine --mount and --mock")
+	}
+
+	if cfg.Mock && len(cfg.Exposes) > 0 {
+		return nil, fmt.Errorf("cannot combine --expose and --mock")
+	}
+
+	if !cfg.Mock && cfg.Port == 0 && !cfg.DetectPort && len(cfg.Mounts) == 0 && len(cfg.Exposes) == 0 {
 		return nil, fmt.Errorf("port argument is required (or use --mock for testing mode)%s", usageSuffix)
 	}
 
+	if err := validateUnmatched(cfg); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Bind {
+	case "", "localhost":
+		cfg.Bind = DefaultBind
+	default:
+		// tailscale serve forwards to 127.0.0.1, so the servers must
+		// listen there or on every interface.
+		ip := net.ParseIP(cfg.Bind)
+		if ip == nil || !(ip.Equal(net.IPv4(127, 0, 0, 1)) || ip.IsUnspecified()) {
+			return nil, fmt.Errorf("invalid bind %q: must be 127.0.0.1, or 0.0.0.0 or :: to listen on all interfaces", cfg.Bind)
+		}
+	}
+
 	if cfg.Port < 0 {
 		return nil, fmt.Errorf("port must be a positive integer")
 	}
 
+	if slices.Contains(cfg.Notify, notifyAll) {
+		cfg.Notify = slices.Clone(NotifyEvents)
+	}
+	for _, event := range cfg.Notify {
+		if !slices.Contains(NotifyEvents, event) {
+			return nil, fmt.Errorf("invalid --notify event %q: must be one of %s, or all", event, strings.Join(NotifyEvents, ", "))
+		}
+	}
+
+	switch cfg.Output {
+	case "":
+		cfg.Output = OutputText
+	case OutputText, OutputJSON:
+	default:
+		return nil, fmt.Errorf("invalid output %q: must be %q or %q", cfg.Output, OutputText, OutputJSON)
+	}
+
+	if cfg.Stream && cfg.Output == OutputJSON {
+		return nil, fmt.Errorf("--stream and --output=json both write to stdout; use one of them")
+	}
+
+	if cfg.MockConfig != "" && !cfg.Mock {
+		return nil, fmt.Errorf("--mock-config requires --mock")
+	}
+	if cfg.Deterministic && !cfg.Mock {
+		return nil, fmt.Errorf("--deterministic requires --mock")
+	}
+
+	if cfg.PreserveHost && cfg.UpstreamHost != "" {
+		return nil, fmt.Errorf("cannot combine --preserve-host and --upstream-host")
+	}
+	if strings.ContainsAny(cfg.UpstreamHost, "/ ") {
+		return nil, fmt.Errorf("invalid upstream-host %q: must be a host name with an optional port", cfg.UpstreamHost)
+	}
+
+	if cfg.CookieSameSite != "" {
+		sameSite, ok := cookieSameSite[strings.ToLower(cfg.CookieSameSite)]
+		if !ok {
+			return nil, fmt.Errorf("invalid --cookie-samesite %q: must be none, lax or strict", cfg.CookieSameSite)
+		}
+		if sameSite == "None" && !cfg.UseHTTPS && !cfg.Funnel {
+			return nil, fmt.Errorf("--cookie-samesite none requires HTTPS: browsers reject SameSite=None cookies without Secure")
+		}
+		cfg.CookieSameSite = sameSite
+		cfg.RewriteCookies = true
+	}
+
+	if len(cfg.CORSOrigins) == 0 && (len(cfg.CORSMethods) > 0 || cfg.CORSCredentials) {
+		return nil, fmt.Errorf("--cors-methods and --cors-credentials require --cors-origins")
+	}
+	// The origin is echoed back, so with credentials any site could make
+	// requests carrying the user's cookies and read the answers.
+	if cfg.CORSCredentials && slices.Contains(cfg.CORSOrigins, "*") {
+		return nil, fmt.Errorf("--cors-credentials cannot be used with --cors-origins '*'; list the allowed origins")
+	}
+	for i, method := range cfg.CORSMethods {
+		cfg.CORSMethods[i] = strings.ToUpper(method)
+	}
+
+	for i, method := range cfg.AllowMethods {
+		method = strings.ToUpper(method)
+		if !httpMethod.MatchString(method) {
+			return nil, fmt.Errorf("invalid --allow-methods entry %q: must be an HTTP method such as POST", method)
+		}
+		cfg.AllowMethods[i] = method
+	}
+
+	if len(cfg.FunnelPaths) > 0 {
+		switch {
+		case cfg.Funnel:
+			return nil, fmt.Errorf("cannot combine --funnel-path and --funnel: --funnel already exposes every path")
+		case cfg.AuthKey != "" || cfg.ForceTsnet:
+			return nil, fmt.Errorf("--funnel-path requires the local Tailscale daemon and cannot be used with --auth-key or --force-tsnet")
+		case cfg.TSNetListenMode == TSNetListenModeService:
+			return nil, fmt.Errorf("--funnel-path cannot be combined with listen-mode=service")
+		case cfg.GetServePort() == 443:
+			return nil, fmt.Errorf("--funnel-path serves public paths on port 443; choose another --serve-port for the private listener, e.g. --serve-port 8443")
+		}
+	}
+
+	if cfg.Namespace != "" {
+		switch {
+		case !validNamespace.MatchString(cfg.Namespace):
+			return nil, fmt.Errorf("invalid --namespace %q: use letters, digits, '.', '_' and '-', starting with a letter or digit", cfg.Namespace)
+		case cfg.SetPath != "":
+			return nil, fmt.Errorf("--namespace sets the serve path and cannot be combined with --set-path")
+		case cfg.Funnel || len(cfg.FunnelPaths) > 0:
+			return nil, fmt.Errorf("--namespace shares the serve port with other developers, and Funnel would make all of their paths public; it cannot be combined with --funnel or --funnel-path")
+		case cfg.AuthKey != "" || cfg.ForceTsnet || len(cfg.Exposes) > 0 || cfg.SSH != "":
+			return nil, fmt.Errorf("--namespace shares the local Tailscale daemon and cannot be used with --auth-key, --force-tsnet, --expose or --ssh")
+		case cfg.TSNetListenMode == TSNetListenModeService:
+			return nil, fmt.Errorf("--namespace cannot be combined with listen-mode=service")
+		}
+		cfg.SetPath = "/" + cfg.Namespace
+	}
+
+	if len(cfg.Exposes) > 0 {
+		switch {
+		case cfg.SSH != "":
+			return nil, fmt.Errorf("--expose runs tsnet nodes and cannot be combined with --ssh")
+		case cfg.TSNetListenMode == TSNetListenModeService:
+			return nil, fmt.Errorf("--expose cannot be combined with listen-mode=service")
+		case len(cfg.FunnelPaths) > 0:
+			return nil, fmt.Errorf("--funnel-path requires the local Tailscale daemon and cannot be combined with --expose")
+		}
+		for _, expose := range cfg.Exposes {
+			if strings.EqualFold(expose.Name, cfg.TailscaleName) {
+				return nil, fmt.Errorf("--expose %s uses the device name; pick another name or --device-name", expose.Name)
+			}
+		}
+		// Each hostname needs its own node, which the local daemon can't
+		// provide.
+		cfg.ForceTsnet = true
+	}
+
+	if cfg.SSH != "" {
+		if _, _, err := sshtunnel.ParseTarget(cfg.SSH); err != nil {
+			return nil, err
+		}
+		switch {
+		case cfg.Funnel || len(cfg.FunnelPaths) > 0:
+			return nil, fmt.Errorf("--ssh exposes the proxy on the bastion and cannot be combined with --funnel or --funnel-path")
+		case cfg.AuthKey != "" || cfg.ForceTsnet || cfg.TSNetListenMode == TSNetListenModeService:
+			return nil, fmt.Errorf("--ssh replaces Tailscale and cannot be used with --auth-key, --force-tsnet or listen-mode=service")
+		case cfg.TargetHost != "":
+			return nil, fmt.Errorf("--target dials tailnet peers and cannot be combined with --ssh")
+		}
+	} else if cfg.SSHRemotePort != 0 || cfg.SSHIdentity != "" {
+		return nil, fmt.Errorf("--ssh-remote-port and --ssh-identity require --ssh")
+	}
+	if cfg.UIPath != "" {
+		if cfg.UIPath, err = parseUIPath(cfg.UIPath); err != nil {
+			return nil, err
+		}
+		switch {
+		case cfg.NoUI:
+			return nil, fmt.Errorf("--ui-path cannot be combined with --no-ui")
+		case cfg.Namespace != "":
+			return nil, fmt.Errorf("--namespace already serves the web UI under /%s/ui and cannot be combined with --ui-path", cfg.Namespace)
+		case cfg.SSH != "":
+			return nil, fmt.Errorf("--ui-path cannot be combined with --ssh")
+		case cfg.TSNetListenMode == TSNetListenModeService:
+			return nil, fmt.Errorf("--ui-path cannot be combined with listen-mode=service")
+		case cfg.UIPath == cfg.GetSetPath():
+			return nil, fmt.Errorf("--ui-path %s is the service's --set-path", cfg.UIPath)
+		}
+	}
+	if cfg.DryRun && (cfg.SSH != "" || cfg.AuthKey != "" || cfg.ForceTsnet) {
+		return nil, fmt.Errorf("--dry-run plans changes to the local Tailscale daemon and cannot be used with --ssh, --auth-key, --force-tsnet or --expose")
+	}
+	if cfg.SSHRemotePort < 0 || cfg.SSHRemotePort > 65535 {
+		return nil, fmt.Errorf("ssh-remote-port must be between 0 and 65535")
+	}
+
+	if cfg.ShareTerminal && (cfg.NoTUI || cfg.NoUI || cfg.Output == OutputJSON || cfg.Stream) {
+		return nil, fmt.Errorf("--share-terminal requires the TUI and web UI (remove --no-tui, --no-ui, --output=json and --stream)")
+	}
+
+	if cfg.ApproveSensitive {
+		if len(cfg.SensitivePaths) == 0 {
+			return nil, fmt.Errorf("--approve-sensitive requires at least one --sensitive-path")
+		}
+		if cfg.NoTUI || cfg.Output == OutputJSON || cfg.Stream {
+			return nil, fmt.Errorf("--approve-sensitive prompts in the TUI and cannot be used with --no-tui, --output=json or --stream")
+		}
+	}
+	if cfg.ApprovalTimeout <= 0 {
+		return nil, fmt.Errorf("approval-timeout must be positive")
+	}
+
+	if cfg.DuplicateWindow <= 0 {
+		return nil, fmt.Errorf("duplicate-window must be positive")
+	}
+
+	if cfg.ExpiresIn < 0 {
+		return nil, fmt.Errorf("expires-in must not be negative")
+	}
+	if cfg.MaxRequests < 0 {
+		return nil, fmt.Errorf("max-requests must be zero or a positive number")
+	}
+
+	if cfg.OAuthCallback == "" {
+		if cfg.OAuthTokenURL != "" || cfg.OAuthClientID != "" || cfg.OAuthClientSecret != "" || cfg.OAuthCodeVerifier != "" || cfg.OAuthRedirectURI != "" {
+			return nil, fmt.Errorf("--oauth-token-url and the other --oauth-* options require --oauth-callback")
+		}
+	} else if !strings.HasPrefix(cfg.OAuthCallback, "/") || strings.ContainsAny(cfg.OAuthCallback, "?# ") {
+		return nil, fmt.Errorf("invalid oauth-callback %q: must be a path starting with /", cfg.OAuthCallback)
+	}
+	if cfg.OAuthTokenURL != "" {
+		u, err := url.Parse(cfg.OAuthTokenURL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid oauth-token-url %q: must be an http or https URL", cfg.OAuthTokenURL)
+		}
+	} else if cfg.OAuthClientID != "" || cfg.OAuthClientSecret != "" || cfg.OAuthCodeVerifier != "" || cfg.OAuthRedirectURI != "" {
+		return nil, fmt.Errorf("--oauth-client-id, --oauth-client-secret, --oauth-code-verifier and --oauth-redirect-uri require --oauth-token-url")
+	}
+
+	if cfg.JWKSURL != "" {
+		u, err := url.Parse(cfg.JWKSURL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid jwks-url %q: must be an http or https URL", cfg.JWKSURL)
+		}
+	}
+
+	if cfg.Pprof && cfg.NoUI {
+		return nil, fmt.Errorf("--pprof is served by the web UI and cannot be used with --no-ui")
+	}
+
+	if cfg.MaxHeaders < 0 {
+		return nil, fmt.Errorf("max-headers must be zero or a positive number")
+	}
+	if cfg.ReadHeaderTimeout < 0 || cfg.ReadTimeout < 0 || cfg.WriteTimeout < 0 || cfg.IdleTimeout < 0 {
+		return nil, fmt.Errorf("timeouts must not be negative")
+	}
+	if cfg.UpstreamMaxIdleConns < 0 {
+		return nil, fmt.Errorf("upstream-max-idle-conns must be zero or a positive number")
+	}
+	if cfg.UpstreamIdleTimeout < 0 || cfg.UpstreamDialTimeout < 0 ||
+		cfg.UpstreamTimeout < 0 || cfg.UpstreamHeaderTimeout < 0 || cfg.UpstreamResponseIdleTimeout < 0 {
+		return nil, fmt.Errorf("upstream timeouts must not be negative")
+	}
+
+	if cfg.CaptureMaxSize < 0 {
+		return nil, fmt.Errorf("capture-max-size must be zero or a positive number of megabytes")
+	}
+	if cfg.SpoolMaxSize < 0 {
+		return nil, fmt.Errorf("spool-max-size must be zero or a positive number of megabytes")
+	}
+	if cfg.CaptureWebhook != "" {
+		u, err := url.Parse(cfg.CaptureWebhook)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid capture-webhook %q: must be an http or https URL", cfg.CaptureWebhook)
+		}
+	} else if cfg.CaptureWebhookSecret != "" {
+		return nil, fmt.Errorf("--capture-webhook-secret requires --capture-webhook")
+	}
+	if cfg.CaptureWebhookBatch < 1 || cfg.CaptureWebhookInterval <= 0 {
+		return nil, fmt.Errorf("capture-webhook-batch and capture-webhook-interval must be positive")
+	}
+	if cfg.TUIRefresh <= 0 {
+		return nil, fmt.Errorf("tui-refresh must be positive")
+	}
+	if cfg.AlertWebhook != "" {
+		u, err := url.Parse(cfg.AlertWebhook)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid alert-webhook %q: must be an http or https URL", cfg.AlertWebhook)
+		}
+		if len(cfg.Alerts) == 0 {
+			return nil, fmt.Errorf("--alert-webhook requires alerts in the config file")
+		}
+	}
+
 	// Auto-configure options
 	cfg.applyAutoConfiguration()
 	if err := cfg.validateTSNetServiceConfig(); err != nil {
@@ -177,6 +1169,10 @@ func (c *Config) applyAutoConfiguration() {
 	if c.Funnel {
 		c.UseHTTPS = true
 	}
+	// Machine-readable output is written to stdout, which the TUI would own.
+	if c.Output == OutputJSON || c.Stream {
+		c.NoTUI = true
+	}
 }
 
 // GetSetPath returns the mount path with default fallback
@@ -187,6 +1183,15 @@ func (c *Config) GetSetPath() string {
 	return c.SetPath
 }
 
+// UIMountPath is where the web UI is mounted on the serve port, or "" when
+// it gets a Tailscale port of its own.
+func (c *Config) UIMountPath() string {
+	if c.Namespace != "" {
+		return "/" + c.Namespace + "/ui"
+	}
+	return c.UIPath
+}
+
 // GetServePort returns the serve port with protocol-based defaults
 func (c *Config) GetServePort() int {
 	if c.ServePort == 0 {
@@ -203,16 +1208,24 @@ func (c *Config) GetServePort() int {
 	return c.ServePort
 }
 
-// HasFunnelAllowlist reports whether Funnel allowlist enforcement is active.
+// HasFunnelAllowlist reports whether Funnel allowlist enforcement is active,
+// with --funnel or for the public paths of --funnel-path.
 func (c *Config) HasFunnelAllowlist() bool {
-	return c.Funnel && len(c.FunnelAllowlist) > 0
+	return (c.Funnel || len(c.FunnelPaths) > 0) && len(c.FunnelAllowlist) > 0
 }
 
 // UseFunnelProxyProtocol reports whether Funnel traffic should use PROXY v2.
-// We only enable this for root-path serving because TCP forwarding does not
-// support mount-point routing semantics from serve web handlers.
+// We only enable this for root-path serving with --funnel because TCP
+// forwarding does not support mount-point routing semantics from serve web
+// handlers, which --funnel-path relies on.
 func (c *Config) UseFunnelProxyProtocol() bool {
-	return c.HasFunnelAllowlist() && c.GetSetPath() == "/"
+	return c.Funnel && c.HasFunnelAllowlist() && c.GetSetPath() == "/"
+}
+
+// LocalAddr is the listen address for a local server on port, honouring
+// --bind.
+func (c *Config) LocalAddr(port int) string {
+	return net.JoinHostPort(cmp.Or(c.Bind, DefaultBind), strconv.Itoa(port))
 }
 
 // EffectiveTSNetListenMode returns the runtime tsnet listen mode once
@@ -230,20 +1243,54 @@ func (c *Config) IsServiceMode() bool {
 	return c.EffectiveTSNetListenMode() == TSNetListenModeService
 }
 
-const usageSuffix = "\nUsage: portal <port> [flags]     (proxy mode)\n       portal --mock [flags]     (mock/testing mode)\n       portal --version\n       portal --cleanup-serve"
+const usageSuffix = "\nUsage: portal <port> [flags]     (proxy mode)\n       portal --target host:port [flags]     (tailnet peer)\n       portal run [port] [flags] -- <command>     (start and supervise the target)\n       portal --mock [flags]     (mock/testing mode)\n       portal --oauth-callback [flags]     (OAuth redirect testing)\n       portal --version\n       portal --cleanup-serve\n       portal init\n       portal doctor [port]\n       portal token create|list|revoke"
 
 type parseState struct {
 	port    int
 	portSet bool
+	command string
+
+	tokenName   string
+	tokenScopes []string
+	tokenID     string
+
+	importFile  string
+	importURL   string
+	importToken string
+
+	exportFile    string
+	exportURL     string
+	exportFormat  string
+	exportColumns []string
+
+	expect      expect.Expectation
+	expectURL   string
+	expectJUnit string
+
+	attachSocket string
+
+	wireFile string
+	wireConn uint64
+
+	runCommand []string
 }
 
-func configureViper(v *viper.Viper) error {
+// DefaultConfigPath returns the location of the user config file
+// (~/.portal/config.yml).
+func DefaultConfigPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return fmt.Errorf("failed to determine home directory: %w", err)
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".portal", "config.yml"), nil
+}
+
+func configureViper(v *viper.Viper) error {
+	configPath, err := DefaultConfigPath()
+	if err != nil {
+		return err
 	}
 
-	configPath := filepath.Join(homeDir, ".portal", "config.yml")
 	v.SetConfigFile(configPath)
 	v.SetConfigType("yaml")
 	v.SetEnvPrefix("PORTAL")
@@ -264,26 +1311,175 @@ func configureViper(v *viper.Viper) error {
 	return nil
 }
 
+func (s *parseState) setPortArg(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	port, err := strconv.Atoi(args[0])
+	if err != nil || port <= 0 {
+		return fmt.Errorf("invalid port %q: must be a positive integer", args[0])
+	}
+
+	s.port = port
+	s.portSet = true
+	return nil
+}
+
 func newRootCommand(v *viper.Viper, state *parseState) (*cobra.Command, error) {
 	cmd := &cobra.Command{
 		Use:   "portal [port]",
 		Short: "Expose local services over Tailscale",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
-				return nil
-			}
+			return state.setPortArg(args)
+		},
+	}
+	cmd.CompletionOptions.DisableDefaultCmd = true
+	cmd.AddCommand(&cobra.Command{
+		Use:   "init",
+		Short: "Interactively check Tailscale prerequisites and write ~/.portal/config.yml",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state.command = CommandInit
+			return nil
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "doctor [port]",
+		Short: "Check Tailscale, HTTPS, Funnel and local port prerequisites",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state.command = CommandDoctor
+			return state.setPortArg(args)
+		},
+	})
 
-			port, err := strconv.Atoi(args[0])
-			if err != nil || port <= 0 {
-				return fmt.Errorf("invalid port %q: must be a positive integer", args[0])
+	cmd.AddCommand(newTokenCommand(state))
+
+	sessions := &cobra.Command{
+		Use:   "sessions",
+		Short: "Review the manifests of past sessions",
+	}
+	sessions.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List past sessions: what was exposed, when and who connected",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state.command = CommandSessionsList
+			return nil
+		},
+	})
+	cmd.AddCommand(sessions)
+
+	importCmd := &cobra.Command{
+		Use:   "import <session.har|capture.jsonl>",
+		Short: "Load a HAR archive or capture file into a running portal for inspection and replay",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state.command = CommandImport
+			state.importFile = args[0]
+			return nil
+		},
+	}
+	importCmd.Flags().StringVar(&state.importURL, "ui-url", "http://127.0.0.1:4040", "Web UI address of the running portal")
+	importCmd.Flags().StringVar(&state.importToken, "token", "", "API token with the requests scope (default: $PORTAL_TOKEN)")
+	cmd.AddCommand(importCmd)
+
+	var exportColumns string
+	exportCmd := &cobra.Command{
+		Use:   "export [file]",
+		Short: "Write a running portal's request history as NDJSON or CSV (to stdout without a file)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state.command = CommandExport
+			if len(args) == 1 {
+				state.exportFile = args[0]
 			}
+			state.exportFormat = strings.ToLower(strings.TrimSpace(state.exportFormat))
+			switch state.exportFormat {
+			case capture.FormatNDJSON:
+				if exportColumns != "" {
+					return fmt.Errorf("--columns only applies to --format csv")
+				}
+			case capture.FormatCSV:
+				columns, err := capture.ParseCSVColumns(exportColumns)
+				if err != nil {
+					return fmt.Errorf("invalid --columns: %w", err)
+				}
+				state.exportColumns = columns
+			default:
+				return fmt.Errorf("invalid --format %q: must be %s or %s", state.exportFormat, capture.FormatNDJSON, capture.FormatCSV)
+			}
+			return nil
+		},
+	}
+	exportCmd.Flags().StringVar(&state.exportURL, "ui-url", "http://127.0.0.1:4040", "Web UI address of the running portal")
+	exportCmd.Flags().StringVar(&state.exportFormat, "format", capture.FormatNDJSON, "Output format: ndjson or csv")
+	exportCmd.Flags().StringVar(&exportColumns, "columns", "", "Comma-separated CSV columns (default: "+strings.Join(capture.DefaultCSVColumns, ",")+")")
+	cmd.AddCommand(exportCmd)
+
+	expectCmd := &cobra.Command{
+		Use:   "expect",
+		Short: "Wait for a running portal to capture matching requests and exit non-zero if it doesn't",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state.command = CommandExpect
+			return state.expect.Validate()
+		},
+	}
+	expectCmd.Flags().StringVar(&state.expectURL, "ui-url", "http://127.0.0.1:4040", "Web UI address of the running portal")
+	expectCmd.Flags().IntVar(&state.expect.Count, "count", 1, "Number of matching requests to wait for")
+	expectCmd.Flags().StringVar(&state.expect.Path, "path", "", "Only count requests under this path")
+	expectCmd.Flags().StringVar(&state.expect.Method, "method", "", "Only count requests with this method")
+	expectCmd.Flags().StringVar(&state.expect.Status, "status", "", "Only count responses with this status code or class, such as 200 or 2xx")
+	expectCmd.Flags().DurationVar(&state.expect.Timeout, "timeout", expect.DefaultTimeout, "How long to wait for the matching requests")
+	expectCmd.Flags().BoolVar(&state.expect.New, "new", false, "Only count requests captured after expect starts")
+	expectCmd.Flags().StringVar(&state.expectJUnit, "junit", "", "Write the JUnit XML report to this file instead of stdout")
+	cmd.AddCommand(expectCmd)
 
-			state.port = port
-			state.portSet = true
+	attachCmd := &cobra.Command{
+		Use:   "attach <socket>",
+		Short: "Show the TUI of a portal started with --grpc-socket, e.g. one running in tmux",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state.command = CommandAttach
+			state.attachSocket = args[0]
 			return nil
 		},
 	}
+	cmd.AddCommand(attachCmd)
+
+	wireCmd := &cobra.Command{
+		Use:   "wire <file>",
+		Short: "Print the bytes recorded by --wire-capture, with line endings and framing shown as sent",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state.command = CommandWire
+			state.wireFile = args[0]
+			return nil
+		},
+	}
+	wireCmd.Flags().Uint64Var(&state.wireConn, "conn", 0, "Only print this connection")
+	cmd.AddCommand(wireCmd)
+
+	runCmd := &cobra.Command{
+		Use:   "run [port] [flags] -- <command> [args...]",
+		Short: "Start a dev server, expose its port once it opens and restart it if it exits",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dash := cmd.ArgsLenAtDash()
+			if dash < 0 || dash == len(args) {
+				return fmt.Errorf("missing command: portal run [port] [flags] -- <command> [args...]")
+			}
+			if dash > 1 {
+				return fmt.Errorf("unexpected arguments %q before --", args[1:dash])
+			}
+			state.command = CommandRun
+			state.runCommand = args[dash:]
+			return state.setPortArg(args[:dash])
+		},
+	}
+	cmd.AddCommand(runCmd)
 
 	flags := cmd.Flags()
 	flags.StringP(deviceNameKey, "n", "", "Tailscale device name (only used with tsnet mode) (default: portal)")
@@ -294,19 +1490,112 @@ func newRootCommand(v *viper.Viper, state *parseState) (*cobra.Command, error) {
 	flags.String("log-file", "", "Log file path (optional)")
 	flags.String("auth-key", "", "Tailscale auth key to create separate tsnet device")
 	flags.Bool("force-tsnet", false, "Force tsnet mode even if local Tailscale is available")
+	flags.Bool("tsnet-verbose", false, "Include tsnet's backend logs (shown with 't' in the TUI)")
 	flags.String("set-path", "", "Set custom path for serve (default: /)")
+	flags.String("namespace", "", "Serve under /<namespace>/ and the web UI under /<namespace>/ui/ on a shared machine, e.g. your username")
 	flags.Int("serve-port", 0, "Tailscale serve port (default: 80 for HTTP, 443 for HTTPS)")
 	flags.Bool("use-https", false, "Use HTTPS instead of HTTP for Tailscale serve")
 	flags.Bool("no-tui", false, "Disable TUI and use simple console output")
 	flags.Bool("no-ui", false, "Disable web UI dashboard")
 	flags.Int("ui-port", 0, "Custom port for web UI (default: 4040 or next available)")
+	flags.String("ui-path", "", "Serve the web UI under this path on the service's serve port instead of a Tailscale port of its own (--ui-path alone uses "+DefaultUIPath+")")
+	flags.Lookup("ui-path").NoOptDefVal = DefaultUIPath
+	flags.String("grpc-socket", "", "Serve the gRPC control API on this unix socket")
+	flags.String("bind", DefaultBind, "Address the local proxy and web UI servers listen on (0.0.0.0 for all interfaces)")
 	flags.Bool("version", false, "Show version information")
 	flags.BoolP("mock", "m", false, "Enable mock/testing mode (no backing server required)")
 	flags.Bool("cleanup-serve", false, "Clear all Tailscale serve configurations and exit")
+	flags.Bool("dry-run", false, "Print the Tailscale serve changes portal would make, without making them, and exit")
 	flags.String(listenModeKey, "", "Listen mode: listener or service (default: listener; service mode requires tag-based identity)")
 	flags.String(serviceNameKey, "", "Service name used when listen-mode=service (default: svc:portal; requires tagged host identity)")
 	flags.String(legacyListenModeKey, "", "Deprecated alias for --listen-mode")
 	flags.String(legacyServiceNameKey, "", "Deprecated alias for --service-name")
+	flags.String("capture-file", "", "Append every captured request (with bodies) as JSON lines to this file")
+	flags.Int("capture-max-size", 0, "Rotate the capture file once it exceeds this size in megabytes (default: 100)")
+	flags.Bool("no-request-body", false, "Don't capture request bodies (headers and sizes are still kept)")
+	flags.Bool("no-response-body", false, "Don't capture response bodies (headers and sizes are still kept)")
+	flags.Bool("spool", false, "Keep a copy of the request history on disk to recover it after a crash")
+	flags.Int("spool-max-size", 0, "Leave the oldest requests out of the spool past this size in megabytes (default: 100)")
+	flags.String("capture-webhook", "", "POST captured requests as JSON batches to this URL")
+	flags.String("capture-webhook-secret", "", "Sign capture webhook batches with HMAC-SHA256 in X-Portal-Signature (or PORTAL_CAPTURE_WEBHOOK_SECRET)")
+	flags.Int("capture-webhook-batch", 20, "Maximum requests per capture webhook POST")
+	flags.Duration("capture-webhook-interval", 5*time.Second, "Send queued requests to the capture webhook at least this often")
+	flags.String("alert-webhook", "", "POST alerts from the config file's alerts list to this URL as they fire and resolve")
+	flags.String("mock-config", "", "YAML file with per-path mock latency and error profiles (requires --mock)")
+	flags.Bool("deterministic", false, "Fix mock timestamps and request IDs and seed random template values, for snapshot tests (requires --mock)")
+	flags.String("record", "", "Proxy to the target and record responses (keyed by method, path and body hash) to this file")
+	flags.String("playback", "", "Serve responses recorded with --record from this file without an upstream (implies --mock)")
+	flags.String("wait-for-target", "", "Start before the target port is listening and forward once it comes up; optional timeout, e.g. --wait-for-target=2m")
+	flags.Lookup("wait-for-target").NoOptDefVal = "0"
+	flags.Bool("no-session-manifest", false, "Don't write a signed manifest of what was exposed and who connected to ~/.portal/sessions on exit")
+	flags.Bool("fail-fast", false, "Exit as soon as setup fails or the target stays unreachable, with an exit code for the cause")
+	flags.StringSlice("allow-methods", nil, "Only accept these HTTP methods, answering others with 405, e.g. --allow-methods POST (GET also allows HEAD)")
+	flags.StringSlice("cors-origins", nil, "Allow cross-origin browser requests from these origins (comma-separated, or * for any)")
+	flags.StringSlice("cors-methods", nil, "Methods allowed in CORS preflight responses (default: GET,POST,PUT,PATCH,DELETE,OPTIONS)")
+	flags.Bool("cors-credentials", false, "Allow credentialed (cookie/Authorization) cross-origin requests")
+	flags.Bool("preserve-host", false, "Forward the client's Host header to the target instead of localhost:<port>")
+	flags.String("upstream-host", "", "Host header to send to the target, e.g. example.local")
+	flags.Bool("forwarded-header", false, "Add an RFC 7239 Forwarded header to proxied requests")
+	flags.Bool("rewrite-cookies", false, "Rewrite the target's cookies to work through the portal URL: drop Domain and add Secure over HTTPS")
+	flags.String("cookie-samesite", "", "Set the SameSite attribute of the target's cookies: none, lax or strict (implies --rewrite-cookies)")
+	flags.Bool("rewrite-html", false, "Rewrite absolute URLs to the target in HTML responses (e.g. <base href> and asset links to localhost) to the portal URL")
+	flags.Bool("no-rewrite-location", false, "Pass redirects to the target's own address (e.g. http://localhost:3000/login) through instead of rewriting them to the portal URL")
+	flags.StringArray("expose", nil, "Serve a target on its own tsnet node, e.g. --expose api=3000 for api.<tailnet>.ts.net (repeatable)")
+	flags.StringArray("mount", nil, "Route a path prefix to another upstream, e.g. --mount /api=localhost:3000/api (repeatable)")
+	flags.String("unmatched", "", "What to do with requests no --mount matches: target, 404, redirect:<url> or mount:/path (default: target with a port argument, otherwise 404)")
+	flags.StringArray("exclude", nil, "Keep requests out of the history, stats and logs, e.g. --exclude /healthz --exclude 'GET /assets/*' (a path prefix or glob, optionally after a method; repeatable)")
+	flags.StringArray("chaos", nil, "Inject latency or failures into matching requests, e.g. --chaos '5% POST /payments 502' --chaos 'header:X-Debug=slow +2s' (repeatable)")
+	flags.StringArray("quota", nil, "Limit each matching client to N requests per period, e.g. --quota tag:ci=1000/h --quota '*=100/h' (a login, tag:<name> or *; repeatable, first match applies)")
+	flags.String("target", "", "Proxy to a service on another tailnet machine (host:port) instead of a local port")
+	flags.String("target-host", "", "Address the local target listens on, e.g. 127.0.0.1 or ::1 (default: try both)")
+	flags.StringArray("funnel-path", nil, "Expose only this path prefix publicly via Funnel on 443, keeping the rest tailnet-only (repeatable)")
+	flags.Bool("scanner-stats", false, "Count Funnel requests from internet scanners in stats (they are tagged either way)")
+	flags.Bool("scanner-ban", false, "Refuse every later Funnel request from the source of a scanner request for the rest of the session")
+	flags.StringArray("geoip-db", nil, "Look up the country and network of public clients in this MaxMind DB file, e.g. GeoLite2-Country.mmdb (repeatable)")
+	flags.String("wire-capture", "", "Record the exact bytes exchanged with clients to this file; print it with portal wire")
+	flags.String("max-body-size", "0", "Reject request bodies larger than this with 413, e.g. 10MB (0 disables)")
+	flags.String("max-header-size", "1MB", "Reject requests whose headers exceed this size with 431 (0 disables)")
+	flags.Int("max-headers", 0, "Reject requests with more than this many header fields with 431 (0 disables)")
+	flags.Duration("read-header-timeout", 10*time.Second, "Close connections that don't send request headers within this time (slowloris protection)")
+	flags.Duration("read-timeout", 0, "Maximum time to read a whole request including the body (0 disables)")
+	flags.Duration("write-timeout", 0, "Maximum time to write a response (0 disables; streaming responses need this off)")
+	flags.Duration("idle-timeout", 120*time.Second, "Close idle keep-alive connections after this time")
+	flags.Int("upstream-max-idle-conns", 32, "Keep-alive connections kept open to the target (net/http defaults to 2)")
+	flags.Duration("upstream-idle-timeout", 90*time.Second, "Close idle keep-alive connections to the target after this time")
+	flags.Duration("upstream-dial-timeout", 10*time.Second, "Give up connecting to the target after this time")
+	flags.Duration("upstream-timeout", 0, "Answer 504 when the target takes longer than this for a whole response (default: no limit)")
+	flags.Duration("upstream-header-timeout", 0, "Answer 504 when the target sends no response headers within this time (default: no limit)")
+	flags.Duration("upstream-response-idle-timeout", 0, "Abort a response when the target sends no data for this long (default: no limit)")
+	flags.Bool("upstream-disable-keepalive", false, "Open a new connection to the target for every request")
+	flags.StringSlice("upstream-quirk", nil, "Work around legacy targets: http1.0, no-expect-continue, no-chunked, lenient-headers (comma-separated)")
+	flags.Bool("status-endpoint", false, "Serve uptime, upstream health and version as JSON at /__portal/status (not logged)")
+	flags.StringArray("sensitive-path", nil, "Flag requests to this path prefix or glob in the TUI, e.g. /admin or /users/*/delete (repeatable)")
+	flags.Bool("approve-sensitive", false, "Hold requests to sensitive paths until approved in the TUI")
+	flags.Duration("approval-timeout", 30*time.Second, "Deny a held sensitive request if it isn't approved within this time")
+	flags.Bool("detect-duplicates", false, "Mark repeated webhook deliveries (same delivery ID header or body) in the request list")
+	flags.Bool("skip-duplicates", false, "Answer repeated webhook deliveries with 200 without forwarding them (implies --detect-duplicates)")
+	flags.Duration("duplicate-window", 10*time.Minute, "How long a webhook delivery is remembered for duplicate detection")
+	flags.StringSlice("duplicate-header", nil, "Extra header that identifies a webhook delivery, checked before the built-in ones (repeatable)")
+	flags.Duration("expires-in", 0, "Stop serving and exit after this long, e.g. 30m")
+	flags.Int("max-requests", 0, "Stop serving and exit after this many requests")
+	flags.String("ssh", "", "Expose the proxy on a bastion through a reverse SSH tunnel (user@host[:port]) instead of Tailscale")
+	flags.Int("ssh-remote-port", 0, "Port to open on the SSH bastion (default: assigned by the server)")
+	flags.String("ssh-identity", "", "Private key for --ssh (default: ssh-agent, then ~/.ssh/id_ed25519, id_ecdsa, id_rsa)")
+	flags.String("oauth-callback", "", "Answer OAuth redirects on this path and show the code and state (default path /callback)")
+	flags.Lookup("oauth-callback").NoOptDefVal = "/callback"
+	flags.String("oauth-token-url", "", "Exchange captured OAuth codes for tokens at this token endpoint (requires --oauth-callback)")
+	flags.String("oauth-client-id", "", "Client ID sent with the OAuth code exchange")
+	flags.String("oauth-client-secret", "", "Client secret for the OAuth code exchange (or PORTAL_OAUTH_CLIENT_SECRET)")
+	flags.String("oauth-code-verifier", "", "PKCE code verifier sent with the OAuth code exchange")
+	flags.String("oauth-redirect-uri", "", "redirect_uri sent with the OAuth code exchange (default: the callback URL)")
+	flags.String("jwks-url", "", "JWKS URL used to verify bearer JWTs shown in request details")
+	flags.StringSlice("notify", nil, "Show desktop notifications for these events: first-request, upstream-5xx, ready, upstream-down, or all (comma-separated)")
+	flags.Bool("pprof", false, "Serve Go runtime profiles at /debug/pprof/ on the web UI")
+	flags.Bool("share-terminal", false, "Share a read-only view of the TUI with tailnet peers at /ui/term on the web UI")
+	flags.Duration("tui-refresh", time.Second, "How often the TUI checks for changed stats; it redraws only when something changed")
+	flags.Bool("low-power", false, "Refresh the TUI at most every 10s and redraw less often, to save battery while waiting")
+	flags.Bool("stream", false, "Write every completed request as a JSON line to stdout (implies --no-tui; logs go to stderr)")
+	flags.String("output", OutputText, "Stdout format: text, or json for JSON-lines lifecycle events (implies --no-tui; logs go to stderr)")
 	_ = flags.MarkDeprecated(legacyTailscaleNameKey, "use --device-name instead")
 	_ = flags.MarkDeprecated(legacyListenModeKey, "use --listen-mode instead")
 	_ = flags.MarkDeprecated(legacyServiceNameKey, "use --service-name instead")
@@ -320,24 +1609,128 @@ func newRootCommand(v *viper.Viper, state *parseState) (*cobra.Command, error) {
 		legacyTailscaleNameKey,
 		"funnel",
 		"funnel-allowlist",
+		"allow-methods",
 		"verbose",
 		"json",
 		"log-file",
 		"auth-key",
 		"force-tsnet",
+		"tsnet-verbose",
 		"set-path",
+		"namespace",
 		"serve-port",
 		"use-https",
 		"no-tui",
 		"no-ui",
 		"ui-port",
+		"ui-path",
+		"grpc-socket",
+		"bind",
 		"version",
 		"mock",
 		"cleanup-serve",
+		"dry-run",
 		listenModeKey,
 		serviceNameKey,
 		legacyListenModeKey,
 		legacyServiceNameKey,
+		"capture-file",
+		"capture-max-size",
+		"no-request-body",
+		"no-response-body",
+		"spool",
+		"spool-max-size",
+		"no-session-manifest",
+		"capture-webhook",
+		"capture-webhook-secret",
+		"capture-webhook-batch",
+		"capture-webhook-interval",
+		"alert-webhook",
+		"output",
+		"wait-for-target",
+		"fail-fast",
+		"mock-config",
+		"deterministic",
+		"record",
+		"playback",
+		"cors-origins",
+		"cors-methods",
+		"cors-credentials",
+		"preserve-host",
+		"upstream-host",
+		"forwarded-header",
+		"rewrite-cookies",
+		"cookie-samesite",
+		"no-rewrite-location",
+		"rewrite-html",
+		"target",
+		"target-host",
+		"mount",
+		"unmatched",
+		"quota",
+		"chaos",
+		"exclude",
+		"expose",
+		"share-terminal",
+		"tui-refresh",
+		"low-power",
+		"funnel-path",
+		"scanner-stats",
+		"scanner-ban",
+		"geoip-db",
+		"wire-capture",
+		"max-body-size",
+		"max-header-size",
+		"max-headers",
+		"read-header-timeout",
+		"read-timeout",
+		"write-timeout",
+		"idle-timeout",
+		"status-endpoint",
+		"upstream-max-idle-conns",
+		"upstream-idle-timeout",
+		"upstream-dial-timeout",
+		"upstream-timeout",
+		"upstream-header-timeout",
+		"upstream-response-idle-timeout",
+		"upstream-disable-keepalive",
+		"upstream-quirk",
+		"pprof",
+		"expires-in",
+		"max-requests",
+		"sensitive-path",
+		"approve-sensitive",
+		"approval-timeout",
+		"detect-duplicates",
+		"skip-duplicates",
+		"duplicate-window",
+		"duplicate-header",
+		"stream",
+		"ssh",
+		"ssh-remote-port",
+		"ssh-identity",
+		"jwks-url",
+		"oauth-callback",
+		"oauth-token-url",
+		"oauth-client-id",
+		"oauth-client-secret",
+		"oauth-code-verifier",
+		"oauth-redirect-uri",
+		"notify",
+		"restart",
+	}
+
+	// portal attach shows the same TUI, so it takes the flags tuning it.
+	for _, key := range []string{"tui-refresh", "low-power"} {
+		attachCmd.Flags().AddFlag(flags.Lookup(key))
+	}
+
+	// portal run takes every flag a plain run does, bound to the same keys.
+	runFlags := runCmd.Flags()
+	runFlags.AddFlagSet(flags)
+	runFlags.String("restart", RestartOnFailure, "Restart the command when it exits: never, on-failure or always")
+	if err := v.BindPFlag("restart", runFlags.Lookup("restart")); err != nil {
+		return nil, fmt.Errorf("failed to bind flag restart: %w", err)
 	}
 
 	for _, key := range keys {
@@ -354,6 +1747,45 @@ func newRootCommand(v *viper.Viper, state *parseState) (*cobra.Command, error) {
 	return cmd, nil
 }
 
+func newTokenCommand(state *parseState) *cobra.Command {
+	token := &cobra.Command{
+		Use:   "token",
+		Short: "Manage API tokens required for mutating web UI API calls",
+	}
+
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API token and print it once",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state.command = CommandTokenCreate
+			return nil
+		},
+	}
+	create.Flags().StringVar(&state.tokenName, "name", "", "Label to identify the token")
+	create.Flags().StringSliceVar(&state.tokenScopes, "scope", []string{"admin"}, "Scopes to grant: requests, mock, admin (comma-separated)")
+
+	token.AddCommand(create, &cobra.Command{
+		Use:   "list",
+		Short: "List API tokens",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state.command = CommandTokenList
+			return nil
+		},
+	}, &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke an API token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state.command = CommandTokenRevoke
+			state.tokenID = args[0]
+			return nil
+		},
+	})
+	return token
+}
+
 func helpRequested(cmd *cobra.Command, args []string) bool {
 	help, err := cmd.Flags().GetBool("help")
 	if err == nil && help {
@@ -393,6 +1825,441 @@ func normalizeList(value any) []string {
 	return normalized
 }
 
+// TimeoutRoute overrides the upstream timeouts for requests under Path, and
+// only for Methods when set. Zero timeouts keep the global value.
+type TimeoutRoute struct {
+	Path          string        `mapstructure:"path"`
+	Methods       []string      `mapstructure:"methods"`
+	Timeout       time.Duration `mapstructure:"timeout"`
+	HeaderTimeout time.Duration `mapstructure:"header-timeout"`
+	IdleTimeout   time.Duration `mapstructure:"idle-timeout"`
+}
+
+// parseTimeoutRoutes reads the config file's upstream-timeouts list.
+func parseTimeoutRoutes(v *viper.Viper) ([]TimeoutRoute, error) {
+	var routes []TimeoutRoute
+	if err := v.UnmarshalKey("upstream-timeouts", &routes); err != nil {
+		return nil, fmt.Errorf("invalid upstream-timeouts: %w", err)
+	}
+	for i, route := range routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("invalid upstream-timeouts path %q: must start with /", route.Path)
+		}
+		if route.Timeout < 0 || route.HeaderTimeout < 0 || route.IdleTimeout < 0 {
+			return nil, fmt.Errorf("upstream-timeouts for %s must not be negative", route.Path)
+		}
+		if route.Timeout == 0 && route.HeaderTimeout == 0 && route.IdleTimeout == 0 {
+			return nil, fmt.Errorf("upstream-timeouts for %s sets no timeout", route.Path)
+		}
+		for j, method := range route.Methods {
+			routes[i].Methods[j] = strings.ToUpper(strings.TrimSpace(method))
+		}
+	}
+	return routes, nil
+}
+
+// parseAlerts reads the config file's alerts list of rule expressions.
+func parseAlerts(v *viper.Viper) ([]alert.Rule, error) {
+	var exprs []string
+	if err := v.UnmarshalKey("alerts", &exprs); err != nil {
+		return nil, fmt.Errorf("invalid alerts: %w", err)
+	}
+	rules := make([]alert.Rule, 0, len(exprs))
+	for _, expr := range exprs {
+		rule, err := alert.ParseRule(expr)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// QuirkRoute replaces the upstream quirks for requests under Path, and only
+// for Methods when set. An empty Quirks list turns them all off.
+type QuirkRoute struct {
+	Path    string   `mapstructure:"path"`
+	Methods []string `mapstructure:"methods"`
+	Quirks  []string `mapstructure:"quirks"`
+}
+
+// parseQuirkRoutes reads the config file's upstream-quirks list.
+func parseQuirkRoutes(v *viper.Viper) ([]QuirkRoute, error) {
+	var routes []QuirkRoute
+	if err := v.UnmarshalKey("upstream-quirks", &routes); err != nil {
+		return nil, fmt.Errorf("invalid upstream-quirks: %w", err)
+	}
+	for i, route := range routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return nil, fmt.Errorf("invalid upstream-quirks path %q: must start with /", route.Path)
+		}
+		if err := validateQuirks("upstream-quirks for "+route.Path, route.Quirks); err != nil {
+			return nil, err
+		}
+		for j, method := range route.Methods {
+			routes[i].Methods[j] = strings.ToUpper(strings.TrimSpace(method))
+		}
+	}
+	return routes, nil
+}
+
+// validateQuirks checks that every quirk is one of UpstreamQuirks.
+func validateQuirks(source string, quirks []string) error {
+	for _, quirk := range quirks {
+		if !slices.Contains(UpstreamQuirks, quirk) {
+			return fmt.Errorf("invalid %s quirk %q: must be one of %s", source, quirk, strings.Join(UpstreamQuirks, ", "))
+		}
+	}
+	return nil
+}
+
+// parseMounts parses "/path=target" entries. target is a port, host:port or
+// URL, optionally with a base path: "4000", "localhost:3000/api" or
+// "http://127.0.0.1:8000/v1".
+func parseMounts(entries []string) ([]Mount, error) {
+	mounts := make([]Mount, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		mountPath, target, ok := strings.Cut(entry, "=")
+		mountPath = strings.TrimSuffix(strings.TrimSpace(mountPath), "/")
+		target = strings.TrimSpace(target)
+		if !ok || target == "" {
+			return nil, fmt.Errorf("invalid mount %q: must be /path=host:port[/base]", entry)
+		}
+		if !strings.HasPrefix(mountPath, "/") {
+			return nil, fmt.Errorf("invalid mount %q: path must start with / and must not be the root (use the port argument for /)", entry)
+		}
+		if seen[mountPath] {
+			return nil, fmt.Errorf("mount path %s is declared more than once", mountPath)
+		}
+		seen[mountPath] = true
+
+		u, err := parseUpstream(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mount %q: %w", entry, err)
+		}
+		mounts = append(mounts, Mount{Path: mountPath, Target: u})
+	}
+	return mounts, nil
+}
+
+// parseExcludes parses "[METHOD ]path" entries, e.g. "/healthz" or
+// "GET /assets/*.js". path is a prefix, or a glob when it has *, ? or [.
+func parseExcludes(entries []string) ([]Exclude, error) {
+	excludes := make([]Exclude, 0, len(entries))
+	for _, entry := range entries {
+		var exclude Exclude
+		fields := strings.Fields(entry)
+		switch len(fields) {
+		case 1:
+			exclude.Path = fields[0]
+		case 2:
+			exclude.Method, exclude.Path = strings.ToUpper(fields[0]), fields[1]
+			if !httpMethod.MatchString(exclude.Method) {
+				return nil, fmt.Errorf("invalid exclude %q: %s is not an HTTP method", entry, fields[0])
+			}
+		default:
+			return nil, fmt.Errorf("invalid exclude %q: must be [METHOD ]/path, e.g. /healthz or 'GET /assets/*'", entry)
+		}
+		if !strings.HasPrefix(exclude.Path, "/") {
+			return nil, fmt.Errorf("invalid exclude %q: path must start with /", entry)
+		}
+		if _, err := path.Match(exclude.Path, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude %q: %w", entry, err)
+		}
+		excludes = append(excludes, exclude)
+	}
+	return excludes, nil
+}
+
+// parseQuotas parses "match=limit/period" entries, e.g. "tag:ci=1000/h" or
+// "alice@example.com=10/m". The period is s, m, h, d or a duration such as
+// 10m.
+func parseQuotas(entries []string) ([]Quota, error) {
+	quotas := make([]Quota, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		match, limit, ok := strings.Cut(entry, "=")
+		match = strings.TrimSpace(match)
+		limitText, periodText, hasPeriod := strings.Cut(strings.TrimSpace(limit), "/")
+		if !ok || !hasPeriod {
+			return nil, fmt.Errorf("invalid quota %q: must be match=limit/period, e.g. tag:ci=1000/h", entry)
+		}
+		if match != "*" && !strings.Contains(match, "@") && (!strings.HasPrefix(match, "tag:") || match == "tag:") {
+			return nil, fmt.Errorf("invalid quota %q: must match a login (user@example.com), a tag (tag:ci) or *", entry)
+		}
+		if seen[match] {
+			return nil, fmt.Errorf("quota for %s is declared more than once", match)
+		}
+		seen[match] = true
+
+		quota := Quota{Match: match}
+		var err error
+		quota.Limit, err = strconv.Atoi(strings.TrimSpace(limitText))
+		if err != nil || quota.Limit <= 0 {
+			return nil, fmt.Errorf("invalid quota %q: limit must be a positive number of requests", entry)
+		}
+		quota.Period, ok = parseQuotaPeriod(periodText)
+		if !ok {
+			return nil, fmt.Errorf("invalid quota %q: period must be s, m, h, d or a duration such as 10m", entry)
+		}
+		quotas = append(quotas, quota)
+	}
+	return quotas, nil
+}
+
+func parseQuotaPeriod(value string) (time.Duration, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "s":
+		return time.Second, true
+	case "m":
+		return time.Minute, true
+	case "h":
+		return time.Hour, true
+	case "d":
+		return 24 * time.Hour, true
+	}
+	period, err := time.ParseDuration(value)
+	return period, err == nil && period > 0
+}
+
+// validateUnmatched checks --unmatched against the mounts and target, and
+// fills in the default action.
+func validateUnmatched(cfg *Config) error {
+	hasTarget := cfg.Port != 0 || cfg.DetectPort
+	switch cfg.Unmatched.Action {
+	case "":
+		cfg.Unmatched.Action = UnmatchedNotFound
+		if hasTarget {
+			cfg.Unmatched.Action = UnmatchedTarget
+		}
+		return nil
+	case UnmatchedTarget:
+		if !hasTarget {
+			return fmt.Errorf("--unmatched target needs a port argument to forward to")
+		}
+	case UnmatchedMount:
+		if !slices.ContainsFunc(cfg.Mounts, func(m Mount) bool { return m.Path == cfg.Unmatched.Location }) {
+			return fmt.Errorf("--unmatched mount:%s names no --mount path", cfg.Unmatched.Location)
+		}
+	}
+	if len(cfg.Mounts) == 0 {
+		return fmt.Errorf("--unmatched only applies with --mount")
+	}
+	return nil
+}
+
+// parseUnmatched parses the --unmatched value: "target", "404",
+// "redirect:<url or /path>" or "mount:/path". An empty value leaves the
+// action to be defaulted once the target is known.
+func parseUnmatched(value string) (Unmatched, error) {
+	value = strings.TrimSpace(value)
+	action, location, _ := strings.Cut(value, ":")
+	action = strings.ToLower(strings.TrimSpace(action))
+	location = strings.TrimSpace(location)
+	switch action {
+	case "":
+		return Unmatched{}, nil
+	case UnmatchedTarget, UnmatchedNotFound:
+		if location == "" {
+			return Unmatched{Action: action}, nil
+		}
+	case UnmatchedRedirect:
+		if u, err := url.Parse(location); err == nil && (strings.HasPrefix(location, "/") || (u.Scheme != "" && u.Host != "")) {
+			return Unmatched{Action: action, Location: location}, nil
+		}
+		return Unmatched{}, fmt.Errorf("invalid unmatched %q: redirect needs a URL or a path starting with /", value)
+	case UnmatchedMount:
+		location = strings.TrimSuffix(location, "/")
+		if strings.HasPrefix(location, "/") {
+			return Unmatched{Action: action, Location: location}, nil
+		}
+		return Unmatched{}, fmt.Errorf("invalid unmatched %q: mount needs a mount path, e.g. mount:/api", value)
+	}
+	return Unmatched{}, fmt.Errorf("invalid unmatched %q: must be target, 404, redirect:<url> or mount:/path", value)
+}
+
+// parseExposes parses "name=target" entries. name becomes the node's
+// hostname, so it must be a DNS label; target is as for mounts.
+func parseExposes(entries []string) ([]Expose, error) {
+	exposes := make([]Expose, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		name, target, ok := strings.Cut(entry, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		target = strings.TrimSpace(target)
+		if !ok || name == "" || target == "" {
+			return nil, fmt.Errorf("invalid expose %q: must be name=host:port[/base]", entry)
+		}
+		if !dnsLabel.MatchString(name) {
+			return nil, fmt.Errorf("invalid expose %q: name must be letters, digits and hyphens, usable as a hostname", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("expose name %s is declared more than once", name)
+		}
+		seen[name] = true
+
+		u, err := parseUpstream(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expose %q: %w", entry, err)
+		}
+		exposes = append(exposes, Expose{Name: name, Target: u})
+	}
+	return exposes, nil
+}
+
+var dnsLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// parseUpstream parses a port, host:port or URL, optionally with a base
+// path, into an upstream URL.
+func parseUpstream(target string) (*url.URL, error) {
+	if _, err := strconv.Atoi(target); err == nil {
+		target = "localhost:" + target
+	}
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.New("target must be host:port[/base]")
+	}
+	if u.Scheme == "http" && u.Port() == "" {
+		return nil, errors.New("target must include a port")
+	}
+	return u, nil
+}
+
+// parseFunnelPaths validates --funnel-path prefixes. The root is rejected
+// because --funnel already covers it.
+func parseFunnelPaths(entries []string) ([]string, error) {
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.HasPrefix(entry, "/") {
+			return nil, fmt.Errorf("invalid funnel-path %q: must start with /", entry)
+		}
+		cleaned := path.Clean(entry)
+		if cleaned == "/" {
+			return nil, fmt.Errorf("invalid funnel-path %q: use --funnel to expose every path", entry)
+		}
+		if slices.Contains(paths, cleaned) {
+			return nil, fmt.Errorf("funnel-path %s is declared more than once", cleaned)
+		}
+		paths = append(paths, cleaned)
+	}
+	return paths, nil
+}
+
+// parseUIPath validates a --ui-path, which can't be the root: the service
+// is served there.
+func parseUIPath(entry string) (string, error) {
+	if !strings.HasPrefix(entry, "/") {
+		return "", fmt.Errorf("invalid ui-path %q: must start with /", entry)
+	}
+	cleaned := path.Clean(entry)
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid ui-path %q: the service is served at /", entry)
+	}
+	return cleaned, nil
+}
+
+// parseSensitivePaths validates --sensitive-path prefixes and globs.
+func parseSensitivePaths(entries []string) ([]string, error) {
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.HasPrefix(entry, "/") {
+			return nil, fmt.Errorf("invalid sensitive-path %q: must start with /", entry)
+		}
+		if _, err := path.Match(entry, ""); err != nil {
+			return nil, fmt.Errorf("invalid sensitive-path %q: %w", entry, err)
+		}
+		if !slices.Contains(paths, entry) {
+			paths = append(paths, entry)
+		}
+	}
+	return paths, nil
+}
+
+// parseTarget splits a --target value of the form host:port.
+func parseTarget(raw string) (string, int, error) {
+	host, portText, err := net.SplitHostPort(raw)
+	if err != nil || host == "" {
+		return "", 0, fmt.Errorf("invalid target %q: must be host:port, e.g. build-box:3000", raw)
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid target %q: port must be between 1 and 65535", raw)
+	}
+	return host, port, nil
+}
+
+// httpMethod matches an HTTP method name, e.g. POST or PROPFIND.
+var httpMethod = regexp.MustCompile(`^[A-Z][A-Z-]*$`)
+
+// cookieSameSite maps --cookie-samesite values to the attribute's spelling.
+var cookieSameSite = map[string]string{"none": "None", "lax": "Lax", "strict": "Strict"}
+
+// validNamespace matches a --namespace, which becomes one path segment.
+var validNamespace = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// parseTargetHost validates a --target-host: an IP address, optionally in
+// brackets, or a hostname without a port.
+func parseTargetHost(raw string) (string, error) {
+	host := strings.TrimSuffix(strings.TrimPrefix(raw, "["), "]")
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+	if strings.ContainsAny(host, ":/[] ") {
+		return "", fmt.Errorf("invalid target-host %q: must be an IP address or hostname without a port, e.g. 127.0.0.1 or ::1", raw)
+	}
+	return host, nil
+}
+
+// parseByteSize parses a size such as "512", "64KB", "10MB" or "1GB"
+// (binary multiples). Zero or empty disables the limit.
+func parseByteSize(name, raw string) (int64, error) {
+	raw = strings.ToUpper(strings.TrimSpace(raw))
+	if raw == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(raw, unit.suffix) {
+			raw = strings.TrimSpace(strings.TrimSuffix(raw, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid %s %q: use a size such as 512KB or 10MB", name, raw)
+	}
+	return value * multiplier, nil
+}
+
+// parseWaitForTarget accepts an empty value (disabled), "true" or "0" (wait
+// without a timeout), or a Go duration used as the timeout.
+func parseWaitForTarget(raw string) (bool, time.Duration, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	switch raw {
+	case "", "false":
+		return false, 0, nil
+	case "true", "0":
+		return true, 0, nil
+	}
+
+	timeout, err := time.ParseDuration(raw)
+	if err != nil || timeout < 0 {
+		return false, 0, fmt.Errorf("invalid wait-for-target %q: must be a duration such as 30s or 2m", raw)
+	}
+	return true, timeout, nil
+}
+
 func parseFunnelAllowlist(entries []string) ([]netip.Prefix, error) {
 	parsed := make([]netip.Prefix, 0, len(entries))
 	for _, entry := range entries {
@@ -8,6 +8,7 @@ import (
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/pflag"
 )
@@ -561,6 +562,21 @@ func TestUseFunnelProxyProtocolDisabledForNonRootSetPath(t *testing.T) {
 	}
 }
 
+func TestFunnelPathsAllowlistWithoutProxyProtocol(t *testing.T) {
+	t.Setenv("PORTAL_FUNNEL_ALLOWLIST", "203.0.113.10")
+
+	cfg, err := ParseArgs([]string{"8080", "--funnel-path", "/webhooks"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.HasFunnelAllowlist() {
+		t.Fatal("expected the allowlist to apply to the public paths")
+	}
+	if cfg.UseFunnelProxyProtocol() {
+		t.Fatal("expected no PROXY protocol, as --funnel-path uses serve web handlers")
+	}
+}
+
 func TestParseArgsTailnetDefaultFromCLI(t *testing.T) {
 	cfg, err := ParseArgs([]string{"8080"})
 	if err != nil {
@@ -659,3 +675,1337 @@ func funnelAllowlistStrings(prefixes []netip.Prefix) []string {
 	}
 	return result
 }
+
+func TestParseArgsInitSubcommand(t *testing.T) {
+	cfg, err := ParseArgs([]string{"init"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Command != CommandInit {
+		t.Fatalf("expected command %q, got %q", CommandInit, cfg.Command)
+	}
+
+	if _, err := ParseArgs([]string{"init", "--help"}); !errors.Is(err, pflag.ErrHelp) {
+		t.Fatalf("expected help error for init --help, got %v", err)
+	}
+}
+
+func TestParseArgsDoctorSubcommand(t *testing.T) {
+	cfg, err := ParseArgs([]string{"doctor", "3000"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Command != CommandDoctor {
+		t.Fatalf("expected command %q, got %q", CommandDoctor, cfg.Command)
+	}
+	if cfg.Port != 3000 {
+		t.Fatalf("expected port 3000, got %d", cfg.Port)
+	}
+
+	if _, err := ParseArgs([]string{"doctor", "abc"}); err == nil {
+		t.Fatalf("expected invalid port error")
+	}
+}
+
+func TestParseArgsOutputJSONImpliesNoTUI(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--output", "json"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Output != OutputJSON {
+		t.Fatalf("expected output json, got %q", cfg.Output)
+	}
+	if !cfg.NoTUI {
+		t.Fatalf("expected --output json to disable the TUI")
+	}
+
+	if _, err := ParseArgs([]string{"8080", "--output", "yaml"}); err == nil {
+		t.Fatalf("expected invalid output error")
+	}
+}
+
+func TestParseArgsStreamImpliesNoTUI(t *testing.T) {
+	cfg, err := ParseArgs([]string{"3000", "--stream"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.Stream || !cfg.NoTUI {
+		t.Fatalf("expected --stream to disable the TUI, got stream=%t no-tui=%t", cfg.Stream, cfg.NoTUI)
+	}
+
+	for _, args := range [][]string{
+		{"3000", "--stream", "--output", "json"},
+		{"3000", "--stream", "--share-terminal"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseArgsSSH(t *testing.T) {
+	cfg, err := ParseArgs([]string{"3000", "--ssh", "deploy@bastion:2222", "--ssh-remote-port", "8080"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.SSH != "deploy@bastion:2222" || cfg.SSHRemotePort != 8080 {
+		t.Fatalf("unexpected ssh config %q port=%d", cfg.SSH, cfg.SSHRemotePort)
+	}
+
+	for _, args := range [][]string{
+		{"3000", "--ssh", "bastion"},
+		{"3000", "--ssh", "deploy@bastion", "--funnel"},
+		{"3000", "--ssh", "deploy@bastion", "--force-tsnet"},
+		{"3000", "--ssh", "deploy@bastion", "--ssh-remote-port", "70000"},
+		{"3000", "--ssh-identity", "/tmp/key"},
+		{"--target", "build-box:3000", "--ssh", "deploy@bastion"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseArgsBind(t *testing.T) {
+	cfg, err := ParseArgs([]string{"3000"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Bind != DefaultBind || cfg.LocalAddr(4040) != "127.0.0.1:4040" {
+		t.Fatalf("expected loopback by default, got %q %q", cfg.Bind, cfg.LocalAddr(4040))
+	}
+
+	cfg, err = ParseArgs([]string{"3000", "--bind", "::"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.LocalAddr(4040) != "[::]:4040" {
+		t.Fatalf("unexpected listen address %q", cfg.LocalAddr(4040))
+	}
+
+	for _, bind := range []string{"192.168.1.10", "127.0.0.2", "example.com"} {
+		if _, err := ParseArgs([]string{"3000", "--bind", bind}); err == nil {
+			t.Fatalf("expected error for --bind %s", bind)
+		}
+	}
+}
+
+func TestParseArgsOAuthCallback(t *testing.T) {
+	cfg, err := ParseArgs([]string{"--oauth-callback"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.OAuthCallback != "/callback" || !cfg.Mock {
+		t.Fatalf("expected default callback path in mock mode, got %q mock=%t", cfg.OAuthCallback, cfg.Mock)
+	}
+
+	cfg, err = ParseArgs([]string{"3000", "--oauth-callback=/auth/return", "--oauth-token-url", "https://idp.example.com/token", "--oauth-client-id", "app"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.OAuthCallback != "/auth/return" || cfg.Mock || cfg.Port != 3000 || cfg.OAuthTokenURL != "https://idp.example.com/token" || cfg.OAuthClientID != "app" {
+		t.Fatalf("unexpected oauth config %+v", cfg)
+	}
+
+	for _, args := range [][]string{
+		{"3000", "--oauth-token-url", "https://idp.example.com/token"},
+		{"--oauth-callback=auth"},
+		{"--oauth-callback", "--oauth-client-id", "app"},
+		{"--oauth-callback", "--oauth-token-url", "idp.example.com/token"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseArgsJWKSURL(t *testing.T) {
+	cfg, err := ParseArgs([]string{"3000", "--jwks-url", "https://issuer.example.com/.well-known/jwks.json"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.JWKSURL != "https://issuer.example.com/.well-known/jwks.json" {
+		t.Fatalf("unexpected jwks url %q", cfg.JWKSURL)
+	}
+
+	for _, raw := range []string{"issuer.example.com/jwks.json", "ftp://issuer.example.com/jwks.json"} {
+		if _, err := ParseArgs([]string{"3000", "--jwks-url", raw}); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestParseArgsWaitForTarget(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--wait-for-target"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.WaitForTarget || cfg.WaitForTargetTimeout != 0 {
+		t.Fatalf("expected indefinite wait, got wait=%t timeout=%s", cfg.WaitForTarget, cfg.WaitForTargetTimeout)
+	}
+
+	cfg, err = ParseArgs([]string{"8080", "--wait-for-target=2m"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.WaitForTarget || cfg.WaitForTargetTimeout.Minutes() != 2 {
+		t.Fatalf("expected 2m timeout, got wait=%t timeout=%s", cfg.WaitForTarget, cfg.WaitForTargetTimeout)
+	}
+
+	if _, err := ParseArgs([]string{"8080", "--wait-for-target=soon"}); err == nil {
+		t.Fatalf("expected invalid wait-for-target error")
+	}
+}
+
+func TestParseArgsRecordAndPlayback(t *testing.T) {
+	cfg, err := ParseArgs([]string{"--playback", "api.json"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.Mock || cfg.Playback != "api.json" {
+		t.Fatalf("expected playback to imply mock mode, got mock=%t playback=%q", cfg.Mock, cfg.Playback)
+	}
+
+	cfg, err = ParseArgs([]string{"8080", "--record", "api.json"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Mock || cfg.Record != "api.json" {
+		t.Fatalf("expected proxy mode with recording, got mock=%t record=%q", cfg.Mock, cfg.Record)
+	}
+
+	if _, err := ParseArgs([]string{"--mock", "--record", "api.json"}); err == nil {
+		t.Fatalf("expected --record with --mock to fail")
+	}
+	if _, err := ParseArgs([]string{"--playback", "a.json", "--record", "b.json"}); err == nil {
+		t.Fatalf("expected --record with --playback to fail")
+	}
+}
+
+func TestParseArgsDeterministic(t *testing.T) {
+	cfg, err := ParseArgs([]string{"--mock", "--deterministic"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.Deterministic {
+		t.Fatalf("expected deterministic mock mode")
+	}
+	if _, err := ParseArgs([]string{"--playback", "api.json", "--deterministic"}); err != nil {
+		t.Fatalf("expected --deterministic with --playback to work, got %v", err)
+	}
+	if _, err := ParseArgs([]string{"8080", "--deterministic"}); err == nil {
+		t.Fatalf("expected --deterministic without --mock to fail")
+	}
+}
+
+func TestParseArgsGRPCSocket(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--grpc-socket", " /tmp/portal.sock "})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.GRPCSocket != "/tmp/portal.sock" {
+		t.Fatalf("unexpected socket %q", cfg.GRPCSocket)
+	}
+}
+
+func TestParseArgsCORS(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--cors-origins", "https://a.example,https://b.example", "--cors-methods", "get,post", "--cors-credentials"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.CORSOrigins) != 2 || cfg.CORSOrigins[1] != "https://b.example" {
+		t.Fatalf("unexpected origins: %v", cfg.CORSOrigins)
+	}
+	if len(cfg.CORSMethods) != 2 || cfg.CORSMethods[0] != "GET" || !cfg.CORSCredentials {
+		t.Fatalf("unexpected methods/credentials: %v %t", cfg.CORSMethods, cfg.CORSCredentials)
+	}
+
+	if _, err := ParseArgs([]string{"8080", "--cors-credentials"}); err == nil {
+		t.Fatalf("expected --cors-credentials without origins to fail")
+	}
+	if _, err := ParseArgs([]string{"8080", "--cors-origins", "https://a.example,*", "--cors-credentials"}); err == nil {
+		t.Fatalf("expected --cors-credentials with any origin to fail")
+	}
+}
+
+func TestParseArgsHostHeaderOptions(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--upstream-host", "example.local"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.UpstreamHost != "example.local" || cfg.PreserveHost {
+		t.Fatalf("unexpected host options: %+v", cfg)
+	}
+
+	if _, err := ParseArgs([]string{"8080", "--preserve-host", "--upstream-host", "example.local"}); err == nil {
+		t.Fatalf("expected --preserve-host with --upstream-host to fail")
+	}
+	if _, err := ParseArgs([]string{"8080", "--upstream-host", "http://example.local/"}); err == nil {
+		t.Fatalf("expected URL upstream-host to fail")
+	}
+}
+
+func TestParseArgsCookieRewrite(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--rewrite-cookies"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.RewriteCookies || cfg.CookieSameSite != "" {
+		t.Fatalf("unexpected cookie options: %+v", cfg)
+	}
+
+	cfg, err = ParseArgs([]string{"8080", "--use-https", "--cookie-samesite", "NONE"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.RewriteCookies || cfg.CookieSameSite != "None" {
+		t.Fatalf("expected --cookie-samesite to imply rewriting, got %+v", cfg)
+	}
+
+	if _, err := ParseArgs([]string{"8080", "--cookie-samesite", "none"}); err == nil {
+		t.Fatalf("expected SameSite=None without HTTPS to fail")
+	}
+	if _, err := ParseArgs([]string{"8080", "--cookie-samesite", "sometimes"}); err == nil {
+		t.Fatalf("expected an unknown SameSite value to fail")
+	}
+}
+
+func TestParseArgsRemoteTarget(t *testing.T) {
+	cfg, err := ParseArgs([]string{"--target", "build-box:3000"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.TargetHost != "build-box" || cfg.Port != 3000 {
+		t.Fatalf("expected build-box:3000, got %q:%d", cfg.TargetHost, cfg.Port)
+	}
+
+	for _, args := range [][]string{
+		{"8080", "--target", "build-box:3000"},
+		{"--mock", "--target", "build-box:3000"},
+		{"--target", "build-box"},
+		{"--target", "build-box:0"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParseArgsTargetHost(t *testing.T) {
+	for arg, want := range map[string]string{
+		"::1":                  "::1",
+		"[::1]":                "::1",
+		"127.0.0.1":            "127.0.0.1",
+		"host.docker.internal": "host.docker.internal",
+	} {
+		cfg, err := ParseArgs([]string{"3000", "--target-host", arg})
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", arg, err)
+		}
+		if cfg.LocalTargetHost != want || cfg.TargetHost != "" {
+			t.Fatalf("%s: expected local host %q, got %q (peer %q)", arg, want, cfg.LocalTargetHost, cfg.TargetHost)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"3000", "--target-host", "localhost:3000"},
+		{"3000", "--target-host", "http://localhost"},
+		{"--target", "build-box:3000", "--target-host", "::1"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParseArgsRun(t *testing.T) {
+	cfg, err := ParseArgs([]string{"run", "3000", "--funnel", "--restart", "always", "--", "npm", "run", "dev", "--port", "3000"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Command != CommandRun || cfg.Port != 3000 || !cfg.Funnel || cfg.Restart != RestartAlways {
+		t.Fatalf("unexpected run config: command=%q port=%d funnel=%t restart=%q", cfg.Command, cfg.Port, cfg.Funnel, cfg.Restart)
+	}
+	if got := strings.Join(cfg.RunCommand, " "); got != "npm run dev --port 3000" {
+		t.Fatalf("expected the command after --, got %q", got)
+	}
+	if !cfg.WaitForTarget {
+		t.Fatal("expected run to wait for the command's port")
+	}
+
+	cfg, err = ParseArgs([]string{"run", "8080", "--", "./server"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Restart != RestartOnFailure {
+		t.Fatalf("expected restart to default to on-failure, got %q", cfg.Restart)
+	}
+
+	if cfg.DetectPort {
+		t.Fatal("expected no port detection with a port")
+	}
+
+	cfg, err = ParseArgs([]string{"run", "--", "npm", "start"})
+	if err != nil {
+		t.Fatalf("expected run without a port to detect it, got %v", err)
+	}
+	if !cfg.DetectPort || cfg.Port != 0 {
+		t.Fatalf("expected port detection, got detect=%t port=%d", cfg.DetectPort, cfg.Port)
+	}
+
+	for _, args := range [][]string{
+		{"run", "3000"},
+		{"run", "3000", "--"},
+		{"run", "3000", "4000", "--", "npm", "start"},
+		{"run", "3000", "--mock", "--", "npm", "start"},
+		{"run", "--target", "build-box:3000", "--", "npm", "start"},
+		{"run", "3000", "--restart", "sometimes", "--", "npm", "start"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParseArgsMounts(t *testing.T) {
+	cfg, err := ParseArgs([]string{"--mount", "/api=localhost:3000/api", "--mount", "/docs/=4000"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.Mounts) != 2 || cfg.Port != 0 {
+		t.Fatalf("expected two mounts without a port, got %+v port=%d", cfg.Mounts, cfg.Port)
+	}
+	if cfg.Mounts[0].Path != "/api" || cfg.Mounts[0].Target.String() != "http://localhost:3000/api" {
+		t.Fatalf("unexpected first mount: %s=%s", cfg.Mounts[0].Path, cfg.Mounts[0].Target)
+	}
+	if cfg.Mounts[1].Path != "/docs" || cfg.Mounts[1].Target.String() != "http://localhost:4000" {
+		t.Fatalf("unexpected second mount: %s=%s", cfg.Mounts[1].Path, cfg.Mounts[1].Target)
+	}
+
+	for _, args := range [][]string{
+		{"--mount", "api=localhost:3000"},
+		{"--mount", "/=localhost:3000"},
+		{"--mount", "/api=localhost"},
+		{"--mount", "/api=1", "--mount", "/api=2"},
+		{"--mock", "--mount", "/api=3000"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParseArgsAllowMethods(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--allow-methods", "post, get"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Equal(cfg.AllowMethods, []string{"POST", "GET"}) {
+		t.Fatalf("expected upper-cased methods, got %v", cfg.AllowMethods)
+	}
+	if _, err := ParseArgs([]string{"8080", "--allow-methods", "POST /hook"}); err == nil {
+		t.Fatal("expected an invalid method to fail")
+	}
+}
+
+func TestParseArgsQuotas(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--quota", "tag:ci=1000/h", "--quota", "alice@example.com=10/30s", "--quota", "*=100/d"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := []Quota{
+		{Match: "tag:ci", Limit: 1000, Period: time.Hour},
+		{Match: "alice@example.com", Limit: 10, Period: 30 * time.Second},
+		{Match: "*", Limit: 100, Period: 24 * time.Hour},
+	}
+	if !slices.Equal(cfg.Quotas, want) {
+		t.Fatalf("expected %+v, got %+v", want, cfg.Quotas)
+	}
+	if got := cfg.Quotas[0].String(); got != "tag:ci=1000/h" {
+		t.Fatalf("unexpected quota string %q", got)
+	}
+
+	for _, quota := range []string{"tag:ci", "tag:ci=1000", "alice=10/h", "tag:=10/h", "*=0/h", "*=10/week", "*=10/-1m"} {
+		if _, err := ParseArgs([]string{"8080", "--quota", quota}); err == nil {
+			t.Fatalf("expected --quota %s to fail", quota)
+		}
+	}
+	if _, err := ParseArgs([]string{"8080", "--quota", "*=1/h", "--quota", "*=2/h"}); err == nil {
+		t.Fatal("expected a repeated quota to fail")
+	}
+}
+
+func TestParseArgsExclude(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--exclude", "/healthz", "--exclude", "get /assets/*.js"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := []Exclude{{Path: "/healthz"}, {Method: "GET", Path: "/assets/*.js"}}
+	if !slices.Equal(cfg.Exclude, want) {
+		t.Fatalf("expected %+v, got %+v", want, cfg.Exclude)
+	}
+
+	for _, exclude := range []string{"healthz", "GET", "GET /a /b", "G3T /healthz", "/assets/[a"} {
+		if _, err := ParseArgs([]string{"8080", "--exclude", exclude}); err == nil {
+			t.Fatalf("expected --exclude %q to fail", exclude)
+		}
+	}
+}
+
+func TestParseArgsUIPath(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--ui-path"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.UIMountPath() != DefaultUIPath {
+		t.Fatalf("expected the default UI path, got %q", cfg.UIMountPath())
+	}
+	cfg, err = ParseArgs([]string{"8080", "--ui-path=/debug/ui/"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.UIMountPath() != "/debug/ui" {
+		t.Fatalf("expected a cleaned UI path, got %q", cfg.UIMountPath())
+	}
+	cfg, err = ParseArgs([]string{"8080", "--namespace", "alice"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.UIMountPath() != "/alice/ui" {
+		t.Fatalf("expected the namespace's UI path, got %q", cfg.UIMountPath())
+	}
+	// tsnet serves the UI from the proxy itself.
+	if _, err := ParseArgs([]string{"8080", "--ui-path", "--force-tsnet"}); err != nil {
+		t.Fatalf("expected --ui-path to work with tsnet, got %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"8080", "--ui-path=ui"},
+		{"8080", "--ui-path=/"},
+		{"8080", "--ui-path", "--no-ui"},
+		{"8080", "--ui-path", "--namespace", "alice"},
+		{"8080", "--ui-path", "--ssh", "bastion"},
+		{"8080", "--ui-path=/api", "--set-path", "/api"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParseArgsDryRun(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--dry-run", "--funnel"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.DryRun {
+		t.Fatal("expected --dry-run to be set")
+	}
+	for _, args := range [][]string{
+		{"8080", "--dry-run", "--force-tsnet"},
+		{"8080", "--dry-run", "--ssh", "dev@bastion.example.com"},
+		{"8080", "--dry-run", "--expose", "docs=3000"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParseArgsUnmatched(t *testing.T) {
+	cfg, err := ParseArgs([]string{"--mount", "/api=3000"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Unmatched.Action != UnmatchedNotFound {
+		t.Fatalf("expected 404 without a port, got %+v", cfg.Unmatched)
+	}
+	cfg, err = ParseArgs([]string{"8080", "--mount", "/api=3000"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Unmatched.Action != UnmatchedTarget {
+		t.Fatalf("expected the port argument by default, got %+v", cfg.Unmatched)
+	}
+
+	for value, want := range map[string]Unmatched{
+		"redirect:https://example.com/docs": {Action: UnmatchedRedirect, Location: "https://example.com/docs"},
+		"redirect:/api/":                    {Action: UnmatchedRedirect, Location: "/api/"},
+		"mount:/api/":                       {Action: UnmatchedMount, Location: "/api"},
+		"404":                               {Action: UnmatchedNotFound},
+	} {
+		cfg, err := ParseArgs([]string{"8080", "--mount", "/api=3000", "--unmatched", value})
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", value, err)
+		}
+		if cfg.Unmatched != want {
+			t.Fatalf("%s: expected %+v, got %+v", value, want, cfg.Unmatched)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"8080", "--unmatched", "404"},
+		{"--mount", "/api=3000", "--unmatched", "target"},
+		{"--mount", "/api=3000", "--unmatched", "mount:/docs"},
+		{"--mount", "/api=3000", "--unmatched", "redirect:example.com"},
+		{"--mount", "/api=3000", "--unmatched", "teapot"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParseArgsExposes(t *testing.T) {
+	cfg, err := ParseArgs([]string{"--expose", "api=3000", "--expose", "Docs=localhost:8080/docs"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.Exposes) != 2 || cfg.Port != 0 || !cfg.ForceTsnet {
+		t.Fatalf("expected two exposes on tsnet without a port, got %+v port=%d force_tsnet=%t", cfg.Exposes, cfg.Port, cfg.ForceTsnet)
+	}
+	if cfg.Exposes[0].Name != "api" || cfg.Exposes[0].Target.String() != "http://localhost:3000" {
+		t.Fatalf("unexpected first expose: %s=%s", cfg.Exposes[0].Name, cfg.Exposes[0].Target)
+	}
+	if cfg.Exposes[1].Name != "docs" || cfg.Exposes[1].Target.String() != "http://localhost:8080/docs" {
+		t.Fatalf("unexpected second expose: %s=%s", cfg.Exposes[1].Name, cfg.Exposes[1].Target)
+	}
+
+	for _, args := range [][]string{
+		{"--expose", "3000"},
+		{"--expose", "my_api=3000"},
+		{"--expose", "api=localhost"},
+		{"--expose", "api=1", "--expose", "API=2"},
+		{"--mock", "--expose", "api=3000"},
+		{"--expose", "portal=3000", "--device-name", "portal"},
+		{"--expose", "api=3000", "--ssh", "bastion.example.com"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParseArgsSessionsList(t *testing.T) {
+	cfg, err := ParseArgs([]string{"sessions", "list"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Command != CommandSessionsList {
+		t.Fatalf("expected sessions list, got %q", cfg.Command)
+	}
+	if _, err := ParseArgs([]string{"sessions", "list", "extra"}); err == nil {
+		t.Fatal("expected extra arguments to fail")
+	}
+}
+
+func TestParseArgsTokenSubcommands(t *testing.T) {
+	cfg, err := ParseArgs([]string{"token", "create", "--name", "ci", "--scope", "mock,requests"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Command != CommandTokenCreate || cfg.TokenName != "ci" {
+		t.Fatalf("unexpected token create config: %+v", cfg)
+	}
+	if len(cfg.TokenScopes) != 2 || cfg.TokenScopes[0] != "mock" || cfg.TokenScopes[1] != "requests" {
+		t.Fatalf("unexpected scopes %v", cfg.TokenScopes)
+	}
+
+	cfg, err = ParseArgs([]string{"token", "create"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.TokenScopes) != 1 || cfg.TokenScopes[0] != "admin" {
+		t.Fatalf("expected admin scope by default, got %v", cfg.TokenScopes)
+	}
+
+	cfg, err = ParseArgs([]string{"token", "revoke", "tok_1234"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Command != CommandTokenRevoke || cfg.TokenID != "tok_1234" {
+		t.Fatalf("unexpected token revoke config: %+v", cfg)
+	}
+
+	if _, err := ParseArgs([]string{"token", "revoke"}); err == nil {
+		t.Fatalf("expected missing id error")
+	}
+	if _, err := ParseArgs([]string{"token"}); !errors.Is(err, pflag.ErrHelp) {
+		t.Fatalf("expected help for bare token command, got %v", err)
+	}
+}
+
+func TestParseArgsUpstreamTransport(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.UpstreamMaxIdleConns != 32 || cfg.UpstreamIdleTimeout != 90*time.Second || cfg.UpstreamDialTimeout != 10*time.Second || cfg.UpstreamDisableKeepAlive {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+
+	cfg, err = ParseArgs([]string{"8080", "--upstream-max-idle-conns", "100", "--upstream-dial-timeout", "2s", "--upstream-disable-keepalive"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.UpstreamMaxIdleConns != 100 || cfg.UpstreamDialTimeout != 2*time.Second || !cfg.UpstreamDisableKeepAlive {
+		t.Fatalf("unexpected transport settings: %+v", cfg)
+	}
+
+	for _, args := range [][]string{
+		{"8080", "--upstream-max-idle-conns", "-1"},
+		{"8080", "--upstream-idle-timeout", "-1s"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseArgsSensitivePaths(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--sensitive-path", "/admin", "--sensitive-path", "/users/*/delete", "--approve-sensitive"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.SensitivePaths) != 2 || !cfg.ApproveSensitive || cfg.ApprovalTimeout != 30*time.Second {
+		t.Fatalf("unexpected sensitive config: %+v", cfg)
+	}
+
+	for _, args := range [][]string{
+		{"8080", "--sensitive-path", "admin"},
+		{"8080", "--sensitive-path", "/[admin"},
+		{"8080", "--approve-sensitive"},
+		{"8080", "--sensitive-path", "/admin", "--approve-sensitive", "--no-tui"},
+		{"8080", "--approval-timeout", "0s"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseArgsExpiry(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--expires-in", "30m", "--max-requests", "5"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.ExpiresIn != 30*time.Minute || cfg.MaxRequests != 5 {
+		t.Fatalf("unexpected expiry settings: %s %d", cfg.ExpiresIn, cfg.MaxRequests)
+	}
+	for _, args := range [][]string{
+		{"8080", "--expires-in", "-1m"},
+		{"8080", "--max-requests", "-1"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseArgsPprofRequiresUI(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--pprof"})
+	if err != nil || !cfg.Pprof {
+		t.Fatalf("expected pprof to be enabled, got %v", err)
+	}
+	if _, err := ParseArgs([]string{"8080", "--pprof", "--no-ui"}); err == nil {
+		t.Fatalf("expected --pprof with --no-ui to fail")
+	}
+}
+
+func TestParseArgsImportSubcommand(t *testing.T) {
+	t.Setenv("PORTAL_TOKEN", "portal_env")
+
+	cfg, err := ParseArgs([]string{"import", "session.har"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Command != CommandImport || cfg.ImportFile != "session.har" {
+		t.Fatalf("unexpected import config: %+v", cfg)
+	}
+	if cfg.ImportURL != "http://127.0.0.1:4040" || cfg.ImportToken != "portal_env" {
+		t.Fatalf("unexpected import defaults: url=%q token=%q", cfg.ImportURL, cfg.ImportToken)
+	}
+
+	cfg, err = ParseArgs([]string{"import", "capture.jsonl", "--ui-url", "http://127.0.0.1:4041/", "--token", "portal_flag"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.ImportURL != "http://127.0.0.1:4041" || cfg.ImportToken != "portal_flag" {
+		t.Fatalf("unexpected import flags: url=%q token=%q", cfg.ImportURL, cfg.ImportToken)
+	}
+
+	if _, err := ParseArgs([]string{"import"}); err == nil {
+		t.Fatalf("expected missing file error")
+	}
+}
+
+func TestParseArgsExportSubcommand(t *testing.T) {
+	cfg, err := ParseArgs([]string{"export"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Command != CommandExport || cfg.ExportFile != "" || cfg.ExportFormat != "ndjson" || cfg.ExportURL != "http://127.0.0.1:4040" {
+		t.Fatalf("unexpected export defaults: %+v", cfg)
+	}
+
+	cfg, err = ParseArgs([]string{"export", "requests.csv", "--format", "CSV", "--columns", "method, url,status"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.ExportFile != "requests.csv" || cfg.ExportFormat != "csv" || !slices.Equal(cfg.ExportColumns, []string{"method", "url", "status"}) {
+		t.Fatalf("unexpected export flags: %+v", cfg)
+	}
+
+	for _, args := range [][]string{
+		{"export", "--format", "har"},
+		{"export", "--format", "csv", "--columns", "method,colour"},
+		{"export", "--columns", "method"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParseArgsExpectSubcommand(t *testing.T) {
+	cfg, err := ParseArgs([]string{"expect"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Command != CommandExpect || cfg.Expect.Count != 1 || cfg.Expect.Timeout != time.Minute || cfg.ExpectURL != "http://127.0.0.1:4040" || cfg.ExpectJUnit != "" {
+		t.Fatalf("unexpected expect defaults: %+v", cfg)
+	}
+
+	cfg, err = ParseArgs([]string{"expect", "--count", "3", "--path", "/hooks", "--method", "post", "--status", "2XX", "--timeout", "2m", "--new", "--junit", "report.xml", "--ui-url", "http://127.0.0.1:5050/"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	e := cfg.Expect
+	if e.Count != 3 || e.Path != "/hooks" || e.Method != "POST" || e.Status != "2xx" || e.Timeout != 2*time.Minute || !e.New {
+		t.Fatalf("unexpected expectation: %+v", e)
+	}
+	if cfg.ExpectJUnit != "report.xml" || cfg.ExpectURL != "http://127.0.0.1:5050" {
+		t.Fatalf("unexpected expect flags: %+v", cfg)
+	}
+
+	for _, args := range [][]string{
+		{"expect", "--count", "0"},
+		{"expect", "--status", "ok"},
+		{"expect", "--path", "hooks"},
+		{"expect", "extra"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParseArgsAttachSubcommand(t *testing.T) {
+	cfg, err := ParseArgs([]string{"attach", "/tmp/portal.sock"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Command != CommandAttach || cfg.AttachSocket != "/tmp/portal.sock" {
+		t.Fatalf("unexpected attach config: %+v", cfg)
+	}
+
+	for _, args := range [][]string{{"attach"}, {"attach", "a.sock", "b.sock"}} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParseArgsScanners(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--funnel"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.ScannerStats || cfg.ScannerBan {
+		t.Fatalf("expected scanners to be left out of stats and not banned by default, got %+v", cfg)
+	}
+	cfg, err = ParseArgs([]string{"8080", "--funnel", "--scanner-stats", "--scanner-ban"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.ScannerStats || !cfg.ScannerBan {
+		t.Fatalf("expected scanner flags to be set, got stats=%t ban=%t", cfg.ScannerStats, cfg.ScannerBan)
+	}
+}
+
+func TestParseArgsGeoIPDB(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--funnel", "--geoip-db", "GeoLite2-Country.mmdb", "--geoip-db", "GeoLite2-ASN.mmdb"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.GeoIPDBs) != 2 || cfg.GeoIPDBs[1] != "GeoLite2-ASN.mmdb" {
+		t.Fatalf("expected both GeoIP databases, got %v", cfg.GeoIPDBs)
+	}
+}
+
+func TestParseArgsWire(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--wire-capture", "portal.wire"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.WireCapture != "portal.wire" {
+		t.Fatalf("expected the wire capture file, got %q", cfg.WireCapture)
+	}
+
+	cfg, err = ParseArgs([]string{"wire", "portal.wire", "--conn", "3"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Command != CommandWire || cfg.WireFile != "portal.wire" || cfg.WireConn != 3 {
+		t.Fatalf("unexpected wire config: %+v", cfg)
+	}
+}
+
+func TestParseArgsTUIRefresh(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.TUIRefresh != time.Second || cfg.LowPower {
+		t.Fatalf("expected a 1s refresh by default, got %v low-power=%t", cfg.TUIRefresh, cfg.LowPower)
+	}
+
+	cfg, err = ParseArgs([]string{"attach", "/tmp/portal.sock", "--tui-refresh", "5s", "--low-power"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.TUIRefresh != 5*time.Second || !cfg.LowPower {
+		t.Fatalf("expected attach to take the TUI flags, got %v low-power=%t", cfg.TUIRefresh, cfg.LowPower)
+	}
+
+	if _, err := ParseArgs([]string{"8080", "--tui-refresh", "0s"}); err == nil {
+		t.Fatal("expected a zero refresh to be rejected")
+	}
+}
+
+func TestParseArgsFunnelPaths(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--funnel-path", "/webhooks/", "--funnel-path", "/public"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.FunnelPaths) != 2 || cfg.FunnelPaths[0] != "/webhooks" || cfg.FunnelPaths[1] != "/public" {
+		t.Fatalf("unexpected funnel paths %v", cfg.FunnelPaths)
+	}
+	if cfg.Funnel || cfg.GetServePort() != 80 {
+		t.Fatalf("expected the main serve to stay private on 80, got funnel=%t port=%d", cfg.Funnel, cfg.GetServePort())
+	}
+
+	for _, args := range [][]string{
+		{"8080", "--funnel-path", "webhooks"},
+		{"8080", "--funnel-path", "/"},
+		{"8080", "--funnel-path", "/a", "--funnel-path", "/a/"},
+		{"8080", "--funnel-path", "/a", "--funnel"},
+		{"8080", "--funnel-path", "/a", "--use-https"},
+		{"8080", "--funnel-path", "/a", "--force-tsnet"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+
+	if _, err := ParseArgs([]string{"8080", "--funnel-path", "/a", "--use-https", "--serve-port", "8443"}); err != nil {
+		t.Fatalf("expected private HTTPS on another port to be allowed, got %v", err)
+	}
+}
+
+func TestParseArgsBodyCapture(t *testing.T) {
+	t.Setenv("PORTAL_NO_RESPONSE_BODY", "true")
+
+	cfg, err := ParseArgs([]string{"8080", "--no-request-body"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.NoRequestBody || !cfg.NoResponseBody {
+		t.Fatalf("expected both directions off, got request=%t response=%t", cfg.NoRequestBody, cfg.NoResponseBody)
+	}
+}
+
+func TestParseArgsSpool(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Spool {
+		t.Fatal("expected the spool to be off by default")
+	}
+
+	t.Setenv("PORTAL_SPOOL_MAX_SIZE", "20")
+	cfg, err = ParseArgs([]string{"8080", "--spool"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.Spool || cfg.SpoolMaxSize != 20 {
+		t.Fatalf("expected a 20MB spool, got spool=%t max=%d", cfg.Spool, cfg.SpoolMaxSize)
+	}
+
+	if _, err := ParseArgs([]string{"8080", "--spool", "--spool-max-size", "-1"}); err == nil {
+		t.Fatal("expected a negative spool size to be rejected")
+	}
+}
+
+func TestParseArgsNamespace(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080", "--namespace", "alice"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Namespace != "alice" || cfg.GetSetPath() != "/alice" {
+		t.Fatalf("expected the service under /alice, got namespace=%q path=%q", cfg.Namespace, cfg.GetSetPath())
+	}
+
+	for _, args := range [][]string{
+		{"8080", "--namespace", "a/b"},
+		{"8080", "--namespace", "-alice"},
+		{"8080", "--namespace", "alice", "--set-path", "/api"},
+		{"8080", "--namespace", "alice", "--funnel"},
+		{"8080", "--namespace", "alice", "--funnel-path", "/hooks"},
+		{"8080", "--namespace", "alice", "--force-tsnet"},
+		{"8080", "--namespace", "alice", "--ssh", "bastion.example.com"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseArgsRequestLimits(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.MaxBodySize != 0 || cfg.MaxHeaderSize != 1<<20 || cfg.ReadHeaderTimeout != 10*time.Second || cfg.IdleTimeout != 120*time.Second {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+
+	cfg, err = ParseArgs([]string{"8080", "--max-body-size", "10MB", "--max-header-size", "64kb", "--max-headers", "50", "--read-timeout", "30s"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.MaxBodySize != 10<<20 || cfg.MaxHeaderSize != 64<<10 || cfg.MaxHeaders != 50 || cfg.ReadTimeout != 30*time.Second {
+		t.Fatalf("unexpected limits: %+v", cfg)
+	}
+
+	for _, args := range [][]string{
+		{"8080", "--max-body-size", "ten"},
+		{"8080", "--max-body-size", "-1MB"},
+		{"8080", "--max-headers", "-1"},
+		{"8080", "--idle-timeout", "-1s"},
+	} {
+		if _, err := ParseArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParseArgsProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	project := `port: 5173
+set-path: /app
+device-name: team-app
+`
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte(project), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+
+	cfg, err := ParseArgs(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Port != 5173 || cfg.SetPath != "/app" || cfg.TailscaleName != "team-app" {
+		t.Fatalf("expected project settings, got port=%d path=%q name=%q", cfg.Port, cfg.SetPath, cfg.TailscaleName)
+	}
+	if cfg.ProjectConfig != filepath.Join(dir, ProjectConfigFile) {
+		t.Fatalf("expected project config path, got %q", cfg.ProjectConfig)
+	}
+
+	cfg, err = ParseArgs([]string{"9000"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.Port != 9000 {
+		t.Fatalf("expected port argument to override project config, got %d", cfg.Port)
+	}
+}
+
+func TestParseArgsProjectConfigMockRules(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, ProjectConfigFile)
+
+	if err := os.WriteFile(path, []byte("mock: true\nmock-config: mocks.yaml\n"), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+	cfg, err := ParseArgs(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.MockConfig != filepath.Join(dir, "mocks.yaml") {
+		t.Fatalf("expected mock-config relative to the project, got %q", cfg.MockConfig)
+	}
+
+	inline := `mock: true
+rules:
+  - path: /health
+    status: 200
+`
+	if err := os.WriteFile(path, []byte(inline), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+	cfg, err = ParseArgs(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.Mock || cfg.MockConfig != path {
+		t.Fatalf("expected project file to be the mock config, got mock=%t config=%q", cfg.Mock, cfg.MockConfig)
+	}
+}
+
+func TestParseArgsDuplicates(t *testing.T) {
+	cfg, err := ParseArgs([]string{"8080"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.DetectDuplicates || cfg.DuplicateWindow != 10*time.Minute {
+		t.Fatalf("unexpected duplicate defaults: %t %s", cfg.DetectDuplicates, cfg.DuplicateWindow)
+	}
+
+	cfg, err = ParseArgs([]string{"8080", "--skip-duplicates", "--duplicate-window", "1m", "--duplicate-header", "X-Event-Id"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !cfg.DetectDuplicates || !cfg.SkipDuplicates || cfg.DuplicateWindow != time.Minute || !slices.Equal(cfg.DuplicateHeaders, []string{"X-Event-Id"}) {
+		t.Fatalf("unexpected duplicate config: %+v", cfg)
+	}
+
+	if _, err := ParseArgs([]string{"8080", "--duplicate-window", "0s"}); err == nil {
+		t.Fatalf("expected error for zero duplicate window")
+	}
+}
+
+func TestParseArgsNotify(t *testing.T) {
+	cfg, err := ParseArgs([]string{"3000", "--notify", "first-request,upstream-down"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := []string{NotifyFirstRequest, NotifyUpstreamDown}; !slices.Equal(cfg.Notify, want) {
+		t.Fatalf("expected notify events %v, got %v", want, cfg.Notify)
+	}
+
+	cfg, err = ParseArgs([]string{"3000", "--notify", "all"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Equal(cfg.Notify, NotifyEvents) {
+		t.Fatalf("expected all notify events, got %v", cfg.Notify)
+	}
+
+	if _, err := ParseArgs([]string{"3000", "--notify", "sometimes"}); err == nil || !strings.Contains(err.Error(), `invalid --notify event "sometimes"`) {
+		t.Fatalf("expected invalid event error, got %v", err)
+	}
+}
+
+func TestParseArgsCaptureWebhook(t *testing.T) {
+	cfg, err := ParseArgs([]string{"3000", "--capture-webhook", "https://captures.example.com/ingest", "--capture-webhook-secret", "s3cret"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.CaptureWebhook != "https://captures.example.com/ingest" || cfg.CaptureWebhookSecret != "s3cret" {
+		t.Fatalf("unexpected capture webhook config %q %q", cfg.CaptureWebhook, cfg.CaptureWebhookSecret)
+	}
+	if cfg.CaptureWebhookBatch != 20 || cfg.CaptureWebhookInterval != 5*time.Second {
+		t.Fatalf("expected default batching, got %d every %s", cfg.CaptureWebhookBatch, cfg.CaptureWebhookInterval)
+	}
+
+	if _, err := ParseArgs([]string{"3000", "--capture-webhook", "captures.example.com"}); err == nil || !strings.Contains(err.Error(), "invalid capture-webhook") {
+		t.Fatalf("expected invalid URL error, got %v", err)
+	}
+	if _, err := ParseArgs([]string{"3000", "--capture-webhook-secret", "s3cret"}); err == nil || !strings.Contains(err.Error(), "requires --capture-webhook") {
+		t.Fatalf("expected missing webhook error, got %v", err)
+	}
+}
+
+func TestParseArgsMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	project := `middleware:
+  - name: request-id
+  - name: basic-auth
+    paths: [/admin]
+    username: dev
+    password: s3cret
+  - name: timeout
+    timeout: 5s
+`
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte(project), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+
+	cfg, err := ParseArgs([]string{"3000"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.Middleware) != 3 {
+		t.Fatalf("expected three middlewares, got %+v", cfg.Middleware)
+	}
+	auth := cfg.Middleware[1]
+	if auth.Name != "basic-auth" || auth.Username != "dev" || len(auth.Paths) != 1 || auth.Paths[0] != "/admin" {
+		t.Fatalf("unexpected basic-auth spec %+v", auth)
+	}
+	if cfg.Middleware[2].Timeout != 5*time.Second {
+		t.Fatalf("expected a 5s timeout, got %s", cfg.Middleware[2].Timeout)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte(`middleware:
+  - name: auth
+    provider: github
+    client-id: Iv1.abc
+    client-secret: s3cret
+    users: [octocat]
+`), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+	cfg, err = ParseArgs([]string{"3000"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if spec := cfg.Middleware[0]; spec.Provider != "github" || spec.ClientID != "Iv1.abc" || spec.ClientSecret != "s3cret" || len(spec.Users) != 1 {
+		t.Fatalf("unexpected auth spec %+v", spec)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte("middleware:\n  - name: gzip\n"), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+	if _, err := ParseArgs([]string{"3000"}); err == nil || !strings.Contains(err.Error(), `unknown middleware "gzip"`) {
+		t.Fatalf("expected unknown middleware error, got %v", err)
+	}
+}
+
+func TestParseArgsChaos(t *testing.T) {
+	cfg, err := ParseArgs([]string{"3000", "--chaos", "5% POST /payments 502", "--chaos", "header:X-Debug=slow +2s"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.Chaos) != 2 || cfg.Chaos[0].Percent != 5 || cfg.Chaos[0].Status != 502 || cfg.Chaos[1].Latency != 2*time.Second {
+		t.Fatalf("unexpected chaos rules %+v", cfg.Chaos)
+	}
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte("chaos:\n  - 10% /search 503\n"), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+	cfg, err = ParseArgs([]string{"3000"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.Chaos) != 1 || cfg.Chaos[0].Path != "/search" || cfg.Chaos[0].Status != 503 {
+		t.Fatalf("unexpected chaos rules from the config file %+v", cfg.Chaos)
+	}
+
+	if _, err := ParseArgs([]string{"3000", "--chaos", "POST /payments"}); err == nil {
+		t.Fatal("expected a chaos rule without a status or latency to fail")
+	}
+}
+
+func TestParseArgsUpstreamTimeouts(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	project := `upstream-timeouts:
+  - path: /reports
+    methods: [post]
+    timeout: 2m
+    idle-timeout: 10s
+`
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte(project), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+
+	cfg, err := ParseArgs([]string{"3000", "--upstream-timeout", "30s", "--upstream-header-timeout", "5s"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if cfg.UpstreamTimeout != 30*time.Second || cfg.UpstreamHeaderTimeout != 5*time.Second || cfg.UpstreamResponseIdleTimeout != 0 {
+		t.Fatalf("unexpected global timeouts %s %s %s", cfg.UpstreamTimeout, cfg.UpstreamHeaderTimeout, cfg.UpstreamResponseIdleTimeout)
+	}
+	if len(cfg.TimeoutRoutes) != 1 {
+		t.Fatalf("expected one timeout route, got %+v", cfg.TimeoutRoutes)
+	}
+	route := cfg.TimeoutRoutes[0]
+	if route.Path != "/reports" || !slices.Equal(route.Methods, []string{"POST"}) || route.Timeout != 2*time.Minute || route.IdleTimeout != 10*time.Second || route.HeaderTimeout != 0 {
+		t.Fatalf("unexpected timeout route %+v", route)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte("upstream-timeouts:\n  - path: /reports\n"), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+	if _, err := ParseArgs([]string{"3000"}); err == nil || !strings.Contains(err.Error(), "sets no timeout") {
+		t.Fatalf("expected missing timeout error, got %v", err)
+	}
+}
+
+func TestParseArgsUpstreamQuirks(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	project := `upstream-quirks:
+  - path: /cgi-bin
+    methods: [get]
+    quirks: [http1.0, lenient-headers]
+`
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte(project), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+
+	cfg, err := ParseArgs([]string{"3000", "--upstream-quirk", "no-chunked,no-expect-continue"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !slices.Equal(cfg.UpstreamQuirks, []string{QuirkNoChunked, QuirkNoExpectContinue}) {
+		t.Fatalf("unexpected quirks %v", cfg.UpstreamQuirks)
+	}
+	if len(cfg.QuirkRoutes) != 1 {
+		t.Fatalf("expected one quirk route, got %+v", cfg.QuirkRoutes)
+	}
+	route := cfg.QuirkRoutes[0]
+	if route.Path != "/cgi-bin" || !slices.Equal(route.Methods, []string{"GET"}) || !slices.Equal(route.Quirks, []string{QuirkHTTP10, QuirkLenientHeaders}) {
+		t.Fatalf("unexpected quirk route %+v", route)
+	}
+
+	if _, err := ParseArgs([]string{"3000", "--upstream-quirk", "http2"}); err == nil || !strings.Contains(err.Error(), "must be one of") {
+		t.Fatalf("expected unknown quirk error, got %v", err)
+	}
+}
+
+func TestParseArgsAlerts(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	project := `alerts:
+  - 5xx > 5 in 1m
+  - p90 > 2s
+`
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte(project), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+
+	cfg, err := ParseArgs([]string{"3000", "--alert-webhook", "https://hooks.example.com/alerts"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.Alerts) != 2 || cfg.Alerts[0].Expr != "5xx > 5 in 1m" || cfg.Alerts[1].Window != time.Minute {
+		t.Fatalf("unexpected alerts %+v", cfg.Alerts)
+	}
+	if cfg.AlertWebhook != "https://hooks.example.com/alerts" {
+		t.Fatalf("unexpected alert webhook %q", cfg.AlertWebhook)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte("alerts:\n  - p90 > slow\n"), 0o644); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+	if _, err := ParseArgs([]string{"3000"}); err == nil || !strings.Contains(err.Error(), "bad threshold") {
+		t.Fatalf("expected invalid alert error, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFile is the per-project config file loaded from the working
+// directory. It is meant to be committed so everyone on a repo shares the same
+// tunnel settings.
+const ProjectConfigFile = ".portal.yaml"
+
+// mockSectionKeys are the mock config sections that may be written inline in
+// a project config file instead of in a separate --mock-config file.
+var mockSectionKeys = []string{"profiles", "rules", "scenarios", "resources"}
+
+// mergeProjectConfig layers ProjectConfigFile from dir over the user config.
+// Flags and environment variables still win. It returns the path of the file
+// that was merged, or "" when there is none.
+func mergeProjectConfig(v *viper.Viper, dir string) (string, error) {
+	path := filepath.Join(dir, ProjectConfigFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read project config %s: %w", path, err)
+	}
+
+	settings := map[string]any{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return "", fmt.Errorf("failed to parse project config %s: %w", path, err)
+	}
+
+	// A relative mock-config is relative to the project, not to wherever
+	// portal happens to be started from. Inline mock sections make the project
+	// file its own mock config.
+	if mockConfig, ok := settings["mock-config"].(string); ok && mockConfig != "" && !filepath.IsAbs(mockConfig) {
+		settings["mock-config"] = filepath.Join(dir, mockConfig)
+	} else if !ok {
+		for _, key := range mockSectionKeys {
+			if _, inline := settings[key]; inline {
+				settings["mock-config"] = path
+				break
+			}
+		}
+	}
+
+	if err := v.MergeConfigMap(settings); err != nil {
+		return "", fmt.Errorf("failed to merge project config %s: %w", path, err)
+	}
+	return path, nil
+}
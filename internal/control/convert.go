@@ -0,0 +1,209 @@
+// internal/control/convert.go
+package control
+
+import (
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/stats"
+	"github.com/jaxxstorm/portal/internal/tui"
+	"github.com/jaxxstorm/portal/pkg/controlpb"
+)
+
+// requestToProto converts a captured request.
+func requestToProto(entry model.RequestLog) *controlpb.Request {
+	return &controlpb.Request{
+		Id:            entry.ID,
+		Timestamp:     timestamppb.New(entry.Timestamp),
+		Method:        entry.Method,
+		Url:           entry.URL,
+		RemoteAddr:    entry.RemoteAddr,
+		Headers:       headersToProto(entry.Headers),
+		Body:          entry.Body,
+		BodyBytes:     entry.BodyBytes,
+		BodyTruncated: entry.BodyTruncated,
+		BodyOmitted:   entry.BodyOmitted,
+		UserAgent:     entry.UserAgent,
+		ContentType:   entry.ContentType,
+		Size:          entry.Size,
+		Duration:      durationpb.New(entry.Duration),
+		Response: &controlpb.Response{
+			StatusCode:    int32(entry.Response.StatusCode),
+			Headers:       headersToProto(entry.Response.Headers),
+			Body:          entry.Response.Body,
+			BodyBytes:     entry.Response.BodyBytes,
+			BodyTruncated: entry.Response.BodyTruncated,
+			BodyOmitted:   entry.Response.BodyOmitted,
+			Size:          entry.Response.Size,
+		},
+		ReplayOf:    entry.ReplayOf,
+		DuplicateOf: entry.DuplicateOf,
+	}
+}
+
+// headersToProto converts headers, keeping an entry per value of a
+// repeated header.
+func headersToProto(headers model.Headers) []*controlpb.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	converted := make([]*controlpb.Header, len(headers))
+	for i, header := range headers {
+		converted[i] = &controlpb.Header{Name: header.Name, Value: header.Value}
+	}
+	return converted
+}
+
+func headersFromProto(headers []*controlpb.Header) model.Headers {
+	if len(headers) == 0 {
+		return nil
+	}
+	converted := make(model.Headers, len(headers))
+	for i, header := range headers {
+		converted[i] = model.Header{Name: header.GetName(), Value: header.GetValue()}
+	}
+	return converted
+}
+
+func stateToProto(state model.ServerState) *controlpb.State {
+	return &controlpb.State{
+		Mode:      state.Mode,
+		TargetUrl: state.TargetURL,
+		Version:   state.Version,
+		StartedAt: timestamppb.New(state.StartedAt),
+		Endpoint: &controlpb.Endpoint{
+			Readiness:   state.Endpoint.Readiness,
+			Mode:        state.Endpoint.Mode,
+			Exposure:    state.Endpoint.Exposure,
+			ServiceUrl:  state.Endpoint.ServiceURL,
+			WebUiStatus: state.Endpoint.WebUIStatus,
+			WebUiUrl:    state.Endpoint.WebUIURL,
+			Upstream:    state.Endpoint.Upstream,
+		},
+		Capture: captureToProto(state.Capture),
+	}
+}
+
+func captureToProto(state model.CaptureState) *controlpb.CaptureState {
+	return &controlpb.CaptureState{
+		Paused:         state.Paused,
+		Skipped:        state.Skipped,
+		RequestBodies:  state.RequestBodies,
+		ResponseBodies: state.ResponseBodies,
+	}
+}
+
+func statsToProto(snapshot stats.StatsSnapshot) *controlpb.Stats {
+	return &controlpb.Stats{
+		StartedAt:          timestamppb.New(snapshot.StartedAt),
+		TakenAt:            timestamppb.New(snapshot.TakenAt),
+		TotalConnections:   int64(snapshot.TotalConnections),
+		OpenConnections:    int64(snapshot.OpenConnections),
+		AvgResponseTime_1M: snapshot.AvgResponseTime1m,
+		AvgResponseTime_5M: snapshot.AvgResponseTime5m,
+		P50ResponseTime:    snapshot.P50ResponseTime,
+		P90ResponseTime:    snapshot.P90ResponseTime,
+		LimitViolations:    counts(snapshot.Violations),
+		UpstreamFailures:   counts(snapshot.UpstreamFailures),
+		UnmatchedRoutes:    counts(snapshot.UnmatchedRoutes),
+	}
+}
+
+func counts(m map[string]int) map[string]int64 {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]int64, len(m))
+	for key, n := range m {
+		out[key] = int64(n)
+	}
+	return out
+}
+
+func logToProto(msg tui.LogMsg) *controlpb.LogEntry {
+	entry := &controlpb.LogEntry{
+		Level:   msg.Level,
+		Message: msg.Message,
+		Time:    timestamppb.New(msg.Time),
+		Stack:   msg.Stack,
+	}
+	for _, field := range msg.Fields {
+		entry.Fields = append(entry.Fields, &controlpb.LogField{Key: field.Key, Value: field.Value})
+	}
+	return entry
+}
+
+func requestFromProto(request *controlpb.Request) model.RequestLog {
+	response := request.GetResponse()
+	return model.RequestLog{
+		ID:            request.GetId(),
+		Timestamp:     request.GetTimestamp().AsTime().Local(),
+		Method:        request.GetMethod(),
+		URL:           request.GetUrl(),
+		RemoteAddr:    request.GetRemoteAddr(),
+		Headers:       headersFromProto(request.GetHeaders()),
+		Body:          request.GetBody(),
+		BodyBytes:     request.GetBodyBytes(),
+		BodyTruncated: request.GetBodyTruncated(),
+		BodyOmitted:   request.GetBodyOmitted(),
+		UserAgent:     request.GetUserAgent(),
+		ContentType:   request.GetContentType(),
+		Size:          request.GetSize(),
+		Duration:      request.GetDuration().AsDuration(),
+		StatusCode:    int(response.GetStatusCode()),
+		Response: model.ResponseLog{
+			StatusCode:    int(response.GetStatusCode()),
+			Headers:       headersFromProto(response.GetHeaders()),
+			Body:          response.GetBody(),
+			BodyBytes:     response.GetBodyBytes(),
+			BodyTruncated: response.GetBodyTruncated(),
+			BodyOmitted:   response.GetBodyOmitted(),
+			Size:          response.GetSize(),
+		},
+		ReplayOf:    request.GetReplayOf(),
+		DuplicateOf: request.GetDuplicateOf(),
+	}
+}
+
+func stateFromProto(state *controlpb.State) model.ServerState {
+	endpoint := state.GetEndpoint()
+	return model.ServerState{
+		Mode:      state.GetMode(),
+		TargetURL: state.GetTargetUrl(),
+		Version:   state.GetVersion(),
+		StartedAt: state.GetStartedAt().AsTime(),
+		Endpoint: model.EndpointState{
+			Readiness:   endpoint.GetReadiness(),
+			Mode:        endpoint.GetMode(),
+			Exposure:    endpoint.GetExposure(),
+			ServiceURL:  endpoint.GetServiceUrl(),
+			WebUIStatus: endpoint.GetWebUiStatus(),
+			WebUIURL:    endpoint.GetWebUiUrl(),
+			Upstream:    endpoint.GetUpstream(),
+		},
+		Capture: captureFromProto(state.GetCapture()),
+	}
+}
+
+func captureFromProto(state *controlpb.CaptureState) model.CaptureState {
+	return model.CaptureState{
+		Paused:         state.GetPaused(),
+		Skipped:        state.GetSkipped(),
+		RequestBodies:  state.GetRequestBodies(),
+		ResponseBodies: state.GetResponseBodies(),
+	}
+}
+
+func logFromProto(entry *controlpb.LogEntry) tui.LogMsg {
+	msg := tui.LogMsg{
+		Level:   entry.GetLevel(),
+		Message: entry.GetMessage(),
+		Time:    entry.GetTime().AsTime().Local(),
+		Stack:   entry.GetStack(),
+	}
+	for _, field := range entry.GetFields() {
+		msg.Fields = append(msg.Fields, tui.LogField{Key: field.GetKey(), Value: field.GetValue()})
+	}
+	return msg
+}
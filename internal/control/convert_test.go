@@ -0,0 +1,39 @@
+package control
+
+import (
+	"slices"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/pkg/controlpb"
+)
+
+func TestRequestProtoKeepsRepeatedHeaders(t *testing.T) {
+	cookies := model.Headers{
+		{Name: "Set-Cookie", Value: "a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT"},
+		{Name: "Set-Cookie", Value: "b=2"},
+	}
+	entry := model.RequestLog{
+		ID:       "req_1",
+		Headers:  model.Headers{{Name: "X-Forwarded-For", Value: "203.0.113.7, 10.0.0.1"}, {Name: "X-Forwarded-For", Value: "100.64.0.1"}},
+		Response: model.ResponseLog{StatusCode: 200, Headers: cookies},
+	}
+
+	data, err := proto.Marshal(requestToProto(entry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded controlpb.Request
+	if err := proto.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	got := requestFromProto(&decoded)
+	if !slices.Equal(got.Response.Headers, cookies) {
+		t.Fatalf("expected both cookies kept apart, got %v", got.Response.Headers)
+	}
+	if !slices.Equal(got.Headers, entry.Headers) {
+		t.Fatalf("expected both X-Forwarded-For values kept apart, got %v", got.Headers)
+	}
+}
@@ -0,0 +1,91 @@
+// internal/control/hub.go
+package control
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// hub fans values out to streaming subscribers. A subscriber that falls
+// watchBuffer values behind is dropped rather than holding up the publisher,
+// and the last keep values are replayed to new subscribers.
+type hub[T any] struct {
+	keep int
+
+	mu          sync.Mutex
+	subscribers map[chan T]struct{}
+	history     []T
+}
+
+func (h *hub[T]) publish(value T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.keep > 0 {
+		if len(h.history) == h.keep {
+			h.history = append(h.history[:0], h.history[1:]...)
+		}
+		h.history = append(h.history, value)
+	}
+	for subscriber := range h.subscribers {
+		select {
+		case subscriber <- value:
+		default:
+			delete(h.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+func (h *hub[T]) subscribe() chan T {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	subscriber := make(chan T, watchBuffer)
+	for _, value := range h.history {
+		subscriber <- value
+	}
+	if h.subscribers == nil {
+		h.subscribers = make(map[chan T]struct{})
+	}
+	h.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+func (h *hub[T]) unsubscribe(subscriber chan T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[subscriber]; ok {
+		delete(h.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// active reports whether anyone is subscribed, so publishers can skip
+// building values nobody will read.
+func (h *hub[T]) active() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers) > 0
+}
+
+// stream sends values from h with send until ctx is done or the subscriber
+// falls too far behind.
+func (h *hub[T]) stream(ctx context.Context, send func(T) error) error {
+	subscriber := h.subscribe()
+	defer h.unsubscribe(subscriber)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case value, ok := <-subscriber:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell too far behind")
+			}
+			if err := send(value); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// internal/control/remote.go
+package control
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/tui"
+	"github.com/jaxxstorm/portal/pkg/controlpb"
+)
+
+// remoteTimeout bounds each call the attached TUI makes, so a portal that
+// stops answering can't freeze it.
+const remoteTimeout = 5 * time.Second
+
+// refreshInterval is how often the stats and state are fetched, matching the
+// TUI's own refresh.
+const refreshInterval = time.Second
+
+// Remote is a running portal reached through its control socket. It
+// implements the TUI's provider interfaces, so "portal attach" shows the
+// same dashboard as the process itself.
+type Remote struct {
+	conn   *grpc.ClientConn
+	client controlpb.ControlClient
+
+	mu    sync.RWMutex
+	state model.ServerState
+	stats *controlpb.Stats
+}
+
+// Dial connects to the portal serving the control API on socket and fetches
+// its state, so an unreachable portal is reported straight away.
+func Dial(ctx context.Context, socket string) (*Remote, error) {
+	path, err := filepath.Abs(socket)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.NewClient("unix://"+path, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	r := &Remote{conn: conn, client: controlpb.NewControlClient(conn)}
+	if err := r.refresh(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not reach portal at %s (is it running with --grpc-socket?): %w", socket, err)
+	}
+	return r, nil
+}
+
+// Close closes the connection.
+func (r *Remote) Close() error {
+	return r.conn.Close()
+}
+
+// Run feeds the TUI through send: the latest captured request, then new
+// requests and application logs as they happen, with the stats and state
+// refreshed every second. It returns when ctx is done or the connection is
+// lost.
+func (r *Remote) Run(ctx context.Context, send func(tea.Msg)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	latest, err := r.client.ListRequests(ctx, &controlpb.ListRequestsRequest{Limit: 1})
+	if err != nil {
+		return err
+	}
+	for _, request := range latest.GetRequests() {
+		send(tui.RequestMsg{Log: requestFromProto(request)})
+	}
+
+	requests, err := r.client.WatchRequests(ctx, &controlpb.WatchRequestsRequest{})
+	if err != nil {
+		return err
+	}
+	logs, err := r.client.WatchLogs(ctx, &controlpb.WatchLogsRequest{})
+	if err != nil {
+		return err
+	}
+
+	errs := make(chan error, 3)
+	go func() {
+		for {
+			request, err := requests.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			send(tui.RequestMsg{Log: requestFromProto(request)})
+		}
+	}()
+	go func() {
+		for {
+			entry, err := logs.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			send(logFromProto(entry))
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			case <-ticker.C:
+				if err := r.refresh(ctx); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errs:
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("lost connection to portal: %w", err)
+	}
+}
+
+func (r *Remote) refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, remoteTimeout)
+	defer cancel()
+	state, err := r.client.GetState(ctx, &controlpb.GetStateRequest{})
+	if err != nil {
+		return err
+	}
+	stats, err := r.client.GetStats(ctx, &controlpb.GetStatsRequest{})
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = stateFromProto(state)
+	r.stats = stats
+	return nil
+}
+
+// GetStats returns the stats fetched by the last refresh.
+func (r *Remote) GetStats() (ttl, opn int, rt1, rt5, p50, p90 float64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s := r.stats
+	return int(s.GetTotalConnections()), int(s.GetOpenConnections()), s.GetAvgResponseTime_1M(), s.GetAvgResponseTime_5M(), s.GetP50ResponseTime(), s.GetP90ResponseTime()
+}
+
+// GetEndpointState returns the endpoint fetched by the last refresh.
+func (r *Remote) GetEndpointState() model.EndpointState {
+	return r.State().Endpoint
+}
+
+// State returns the state fetched by the last refresh.
+func (r *Remote) State() model.ServerState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.state
+}
+
+// CaptureState returns the capture settings fetched by the last refresh or
+// change.
+func (r *Remote) CaptureState() model.CaptureState {
+	return r.State().Capture
+}
+
+// SetCapturePaused pauses or resumes capture on the remote portal.
+func (r *Remote) SetCapturePaused(paused bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+	capture, err := r.client.SetCapture(ctx, &controlpb.SetCaptureRequest{Paused: &paused})
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state.Capture = captureFromProto(capture)
+}
+
+// ResetStats starts a new stats period on the remote portal.
+func (r *Remote) ResetStats() {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+	stats, err := r.client.ResetStats(ctx, &controlpb.ResetStatsRequest{})
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats = stats
+}
+
+// Replay replays a captured request on the remote portal.
+func (r *Remote) Replay(ctx context.Context, id string, edit model.ReplayRequest) (model.RequestLog, error) {
+	request, err := r.client.ReplayRequest(ctx, &controlpb.ReplayRequestRequest{
+		Id:      id,
+		Method:  edit.Method,
+		Url:     edit.URL,
+		Headers: headersToProto(edit.Headers),
+		Body:    edit.Body,
+	})
+	if err != nil {
+		return model.RequestLog{}, err
+	}
+	return requestFromProto(request), nil
+}
@@ -0,0 +1,114 @@
+package control
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/stats"
+	"github.com/jaxxstorm/portal/internal/tui"
+)
+
+type statefulProvider struct {
+	stubProvider
+}
+
+func (p *statefulProvider) State() model.ServerState {
+	return model.ServerState{
+		Mode:     "proxy",
+		Endpoint: model.EndpointState{Readiness: model.EndpointReadinessReady, ServiceURL: "https://app.example.ts.net"},
+		Capture:  p.CaptureState(),
+	}
+}
+
+func (p *statefulProvider) StatsSnapshot() stats.StatsSnapshot {
+	return stats.StatsSnapshot{TotalConnections: 7, OpenConnections: 2, P90ResponseTime: 12.5}
+}
+
+func (p *statefulProvider) ResetStats() {}
+
+func TestRemoteFeedsTUI(t *testing.T) {
+	provider := &statefulProvider{}
+	provider.logs = []model.RequestLog{{ID: "req_old", Method: "GET", URL: "/old"}}
+	server, socket := serveControl(t, provider)
+	server.TeeLogger(zaptest.NewLogger(t)).Info("Proxy ready", zap.String("target", "localhost:3000"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	remote, err := Dial(ctx, socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+
+	if ttl, opn, _, _, _, p90 := remote.GetStats(); ttl != 7 || opn != 2 || p90 != 12.5 {
+		t.Fatalf("unexpected stats %d %d %v", ttl, opn, p90)
+	}
+	if endpoint := remote.GetEndpointState(); endpoint.ServiceURL != "https://app.example.ts.net" {
+		t.Fatalf("unexpected endpoint %+v", endpoint)
+	}
+
+	var mu sync.Mutex
+	var requests []string
+	var logs []tui.LogMsg
+	send := func(msg tea.Msg) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch msg := msg.(type) {
+		case tui.RequestMsg:
+			requests = append(requests, msg.Log.ID)
+		case tui.LogMsg:
+			logs = append(logs, msg)
+		}
+	}
+	done := make(chan error, 1)
+	go func() { done <- remote.Run(ctx, send) }()
+
+	received := func(n int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(requests) >= n && len(logs) >= 1
+	}
+	// The latest request and log history arrive first; then keep capturing
+	// until the request stream is registered and sees one.
+	deadline := time.Now().Add(3 * time.Second)
+	for !received(1) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for !received(2) && time.Now().Before(deadline) {
+		provider.record(model.RequestLog{ID: "req_new", Method: "POST", URL: "/new"})
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) < 2 || requests[0] != "req_old" || requests[1] != "req_new" {
+		t.Fatalf("expected the latest request then new ones, got %v", requests)
+	}
+	if len(logs) == 0 || logs[0].Message != "Proxy ready" || len(logs[0].Fields) != 1 || logs[0].Fields[0].Value != "localhost:3000" {
+		t.Fatalf("expected the earlier log entry, got %+v", logs)
+	}
+
+	remote.SetCapturePaused(true)
+	if !provider.CaptureState().Paused {
+		t.Fatal("expected capture to be paused remotely")
+	}
+}
+
+func TestDialUnreachable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := Dial(ctx, "/nonexistent/portal.sock"); err == nil {
+		t.Fatal("expected an error for a missing socket")
+	}
+}
@@ -0,0 +1,297 @@
+// internal/control/server.go
+
+// Package control serves the control API over gRPC on a local socket, for
+// tools that manage a running portal with typed clients. The API is defined
+// in proto/portal/control/v1/control.proto and mirrors the web UI's REST API.
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/stats"
+	"github.com/jaxxstorm/portal/internal/tui"
+	"github.com/jaxxstorm/portal/pkg/controlpb"
+)
+
+// watchBuffer is how many requests a WatchRequests stream may fall behind
+// before it is ended.
+const watchBuffer = 256
+
+// logHistory is how many recent log entries a new WatchLogs stream starts
+// with.
+const logHistory = 100
+
+// defaultSearchLimit caps searches without a limit, as GET /api/search does.
+const defaultSearchLimit = 100
+
+// Provider is what the control API manages; *proxy.Server implements it
+// along with the optional interfaces below.
+type Provider interface {
+	GetRequestLogs() []model.RequestLog
+	ClearRequestLogs()
+	AddListener(func(model.RequestLog))
+}
+
+// StateProvider reports the server's mode, target and URLs.
+type StateProvider interface {
+	State() model.ServerState
+}
+
+// SearchProvider searches captured bodies.
+type SearchProvider interface {
+	SearchRequests(query string, limit int) []model.RequestLog
+}
+
+// Replayer sends a captured request again.
+type Replayer interface {
+	Replay(ctx context.Context, id string, edit model.ReplayRequest) (model.RequestLog, error)
+}
+
+// StatsSnapshotter reports stats for the current period and starts new ones.
+type StatsSnapshotter interface {
+	StatsSnapshot() stats.StatsSnapshot
+	ResetStats()
+}
+
+// CaptureController pauses capture and turns body capture on or off.
+type CaptureController interface {
+	SetCapturePaused(paused bool)
+	SetBodyCapture(request, response bool)
+	CaptureState() model.CaptureState
+}
+
+// Server implements controlpb.ControlServer for a Provider.
+type Server struct {
+	controlpb.UnimplementedControlServer
+
+	provider Provider
+	requests hub[*controlpb.Request]
+	logs     hub[*controlpb.LogEntry]
+}
+
+// NewServer returns a control server for provider. It listens for captured
+// requests from then on, to stream them to WatchRequests callers.
+func NewServer(provider Provider) *Server {
+	s := &Server{
+		provider: provider,
+		logs:     hub[*controlpb.LogEntry]{keep: logHistory},
+	}
+	provider.AddListener(func(entry model.RequestLog) {
+		if s.requests.active() {
+			s.requests.publish(requestToProto(entry))
+		}
+	})
+	return s
+}
+
+// Listen creates the unix socket at path, replacing a stale one left by an
+// earlier run, and makes it accessible to the current user only. The socket
+// is created in a directory only the current user can enter and moved to
+// path once its permissions are set, so other users can't connect while it
+// still has the umask's. Closing the listener removes the socket.
+func Listen(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s is in use by another portal", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".portal-control-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "control.sock")
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	// The socket is removed from path on Close instead.
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &socketListener{Listener: listener, path: path}, nil
+}
+
+// socketListener removes its socket when closed.
+type socketListener struct {
+	net.Listener
+	path      string
+	closeOnce sync.Once
+}
+
+func (l *socketListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() { os.Remove(l.path) })
+	return err
+}
+
+// Serve serves the control API on listener until ctx is done.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := grpc.NewServer()
+	controlpb.RegisterControlServer(server, s)
+	go func() {
+		<-ctx.Done()
+		server.Stop()
+	}()
+	err := server.Serve(listener)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) GetState(context.Context, *controlpb.GetStateRequest) (*controlpb.State, error) {
+	provider, ok := s.provider.(StateProvider)
+	if !ok {
+		return nil, status.Error(codes.Unavailable, "state not available")
+	}
+	return stateToProto(provider.State()), nil
+}
+
+func (s *Server) ListRequests(_ context.Context, req *controlpb.ListRequestsRequest) (*controlpb.ListRequestsResponse, error) {
+	var entries []model.RequestLog
+	if req.GetQuery() != "" {
+		provider, ok := s.provider.(SearchProvider)
+		if !ok {
+			return nil, status.Error(codes.Unavailable, "search not available")
+		}
+		limit := int(req.GetLimit())
+		if limit <= 0 {
+			limit = defaultSearchLimit
+		}
+		entries = provider.SearchRequests(req.GetQuery(), limit)
+	} else {
+		entries = s.provider.GetRequestLogs()
+		if limit := int(req.GetLimit()); limit > 0 && len(entries) > limit {
+			entries = entries[len(entries)-limit:]
+		}
+	}
+	resp := &controlpb.ListRequestsResponse{Requests: make([]*controlpb.Request, 0, len(entries))}
+	for _, entry := range entries {
+		resp.Requests = append(resp.Requests, requestToProto(entry))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetRequest(_ context.Context, req *controlpb.GetRequestRequest) (*controlpb.Request, error) {
+	entries := s.provider.GetRequestLogs()
+	i := slices.IndexFunc(entries, func(entry model.RequestLog) bool { return entry.ID == req.GetId() })
+	if i < 0 {
+		return nil, status.Errorf(codes.NotFound, "request not found: %s", req.GetId())
+	}
+	return requestToProto(entries[i]), nil
+}
+
+func (s *Server) ClearRequests(context.Context, *controlpb.ClearRequestsRequest) (*controlpb.ClearRequestsResponse, error) {
+	s.provider.ClearRequestLogs()
+	return &controlpb.ClearRequestsResponse{}, nil
+}
+
+func (s *Server) ReplayRequest(ctx context.Context, req *controlpb.ReplayRequestRequest) (*controlpb.Request, error) {
+	replayer, ok := s.provider.(Replayer)
+	if !ok {
+		return nil, status.Error(codes.Unavailable, "replay not available")
+	}
+	edit := model.ReplayRequest{
+		Method:  req.GetMethod(),
+		URL:     req.GetUrl(),
+		Headers: headersFromProto(req.GetHeaders()),
+		Body:    req.Body,
+	}
+	entry, err := replayer.Replay(ctx, req.GetId(), edit)
+	switch {
+	case errors.Is(err, model.ErrRequestNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return requestToProto(entry), nil
+}
+
+func (s *Server) GetStats(context.Context, *controlpb.GetStatsRequest) (*controlpb.Stats, error) {
+	provider, ok := s.provider.(StatsSnapshotter)
+	if !ok {
+		return nil, status.Error(codes.Unavailable, "stats not available")
+	}
+	return statsToProto(provider.StatsSnapshot()), nil
+}
+
+func (s *Server) ResetStats(context.Context, *controlpb.ResetStatsRequest) (*controlpb.Stats, error) {
+	provider, ok := s.provider.(StatsSnapshotter)
+	if !ok {
+		return nil, status.Error(codes.Unavailable, "stats not available")
+	}
+	provider.ResetStats()
+	return statsToProto(provider.StatsSnapshot()), nil
+}
+
+func (s *Server) SetCapture(_ context.Context, req *controlpb.SetCaptureRequest) (*controlpb.CaptureState, error) {
+	controller, ok := s.provider.(CaptureController)
+	if !ok {
+		return nil, status.Error(codes.Unavailable, "capture control not available")
+	}
+	if req.Paused != nil {
+		controller.SetCapturePaused(req.GetPaused())
+	}
+	if req.RequestBodies != nil || req.ResponseBodies != nil {
+		state := controller.CaptureState()
+		request, response := state.RequestBodies, state.ResponseBodies
+		if req.RequestBodies != nil {
+			request = req.GetRequestBodies()
+		}
+		if req.ResponseBodies != nil {
+			response = req.GetResponseBodies()
+		}
+		controller.SetBodyCapture(request, response)
+	}
+	return captureToProto(controller.CaptureState()), nil
+}
+
+func (s *Server) WatchRequests(_ *controlpb.WatchRequestsRequest, stream grpc.ServerStreamingServer[controlpb.Request]) error {
+	return s.requests.stream(stream.Context(), stream.Send)
+}
+
+func (s *Server) WatchLogs(_ *controlpb.WatchLogsRequest, stream grpc.ServerStreamingServer[controlpb.LogEntry]) error {
+	return s.logs.stream(stream.Context(), stream.Send)
+}
+
+// TeeLogger returns logger with its entries also streamed to WatchLogs
+// clients. It returns logger unchanged on a nil Server.
+func (s *Server) TeeLogger(logger *zap.Logger) *zap.Logger {
+	if s == nil {
+		return logger
+	}
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, tui.NewZapCore(s.publishLog, core))
+	}))
+}
+
+func (s *Server) publishLog(msg tea.Msg) {
+	if msg, ok := msg.(tui.LogMsg); ok {
+		s.logs.publish(logToProto(msg))
+	}
+}
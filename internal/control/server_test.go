@@ -0,0 +1,247 @@
+package control
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/pkg/controlpb"
+)
+
+type stubProvider struct {
+	mu        sync.Mutex
+	logs      []model.RequestLog
+	listeners []func(model.RequestLog)
+	capture   model.CaptureState
+}
+
+func (p *stubProvider) GetRequestLogs() []model.RequestLog {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]model.RequestLog(nil), p.logs...)
+}
+
+func (p *stubProvider) ClearRequestLogs() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logs = nil
+}
+
+func (p *stubProvider) AddListener(listener func(model.RequestLog)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listeners = append(p.listeners, listener)
+}
+
+func (p *stubProvider) record(entry model.RequestLog) {
+	p.mu.Lock()
+	p.logs = append(p.logs, entry)
+	listeners := p.listeners
+	p.mu.Unlock()
+	for _, listener := range listeners {
+		listener(entry)
+	}
+}
+
+func (p *stubProvider) SetCapturePaused(paused bool) { p.capture.Paused = paused }
+
+func (p *stubProvider) SetBodyCapture(request, response bool) {
+	p.capture.RequestBodies, p.capture.ResponseBodies = request, response
+}
+
+func (p *stubProvider) CaptureState() model.CaptureState { return p.capture }
+
+func dialControl(t *testing.T, provider Provider) controlpb.ControlClient {
+	t.Helper()
+	_, socket := serveControl(t, provider)
+	conn, err := grpc.NewClient("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return controlpb.NewControlClient(conn)
+}
+
+// serveControl serves provider on a fresh socket until the test ends.
+func serveControl(t *testing.T, provider Provider) (*Server, string) {
+	t.Helper()
+	// Unix socket paths are limited to about 100 bytes, which t.TempDir
+	// can exceed.
+	dir, err := os.MkdirTemp("", "portal-control")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "control.sock")
+
+	listener, err := Listen(socket)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected a private socket, got %v %v", info, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	server := NewServer(provider)
+	go func() { served <- server.Serve(ctx, listener) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+	return server, socket
+}
+
+func TestControlRequests(t *testing.T) {
+	provider := &stubProvider{logs: []model.RequestLog{
+		{ID: "req_1", Method: "GET", URL: "/a", Response: model.ResponseLog{StatusCode: 200}},
+		{ID: "req_2", Method: "POST", URL: "/b", Body: `{"x":1}`, Response: model.ResponseLog{StatusCode: 201}},
+	}}
+	client := dialControl(t, provider)
+	ctx := context.Background()
+
+	list, err := client.ListRequests(ctx, &controlpb.ListRequestsRequest{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GetRequests()) != 1 || list.GetRequests()[0].GetId() != "req_2" {
+		t.Fatalf("expected the newest request, got %v", list.GetRequests())
+	}
+
+	request, err := client.GetRequest(ctx, &controlpb.GetRequestRequest{Id: "req_2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.GetMethod() != "POST" || request.GetBody() != `{"x":1}` || request.GetResponse().GetStatusCode() != 201 {
+		t.Fatalf("unexpected request %v", request)
+	}
+
+	_, err = client.GetRequest(ctx, &controlpb.GetRequestRequest{Id: "req_9"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	if _, err := client.ClearRequests(ctx, &controlpb.ClearRequestsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.GetRequestLogs()) != 0 {
+		t.Fatal("expected the history to be cleared")
+	}
+
+	// The stub has no stats, state, search or replay.
+	if _, err := client.GetStats(ctx, &controlpb.GetStatsRequest{}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+	if _, err := client.ListRequests(ctx, &controlpb.ListRequestsRequest{Query: "x"}); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+}
+
+func TestControlSetCapture(t *testing.T) {
+	provider := &stubProvider{capture: model.CaptureState{RequestBodies: true, ResponseBodies: true}}
+	client := dialControl(t, provider)
+
+	state, err := client.SetCapture(context.Background(), &controlpb.SetCaptureRequest{Paused: proto.Bool(true), ResponseBodies: proto.Bool(false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.GetPaused() || !state.GetRequestBodies() || state.GetResponseBodies() {
+		t.Fatalf("unexpected capture state %v", state)
+	}
+}
+
+func TestControlWatchRequests(t *testing.T) {
+	provider := &stubProvider{}
+	client := dialControl(t, provider)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.WatchRequests(ctx, &controlpb.WatchRequestsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep capturing until the stream is registered and sees one.
+	go func() {
+		for i := 0; ctx.Err() == nil; i++ {
+			provider.record(model.RequestLog{ID: "req_live", Method: "GET", URL: "/live"})
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+	request, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.GetId() != "req_live" || request.GetUrl() != "/live" {
+		t.Fatalf("unexpected streamed request %v", request)
+	}
+}
+
+func TestListenRefusesSocketInUse(t *testing.T) {
+	dir, err := os.MkdirTemp("", "portal-control")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "control.sock")
+
+	listener, err := Listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if _, err := Listen(socket); err == nil {
+		t.Fatal("expected a second listener on the same socket to fail")
+	}
+}
+
+func TestListenCreatesPrivateSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "portal-control")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "control.sock")
+
+	listener, err := Listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected a socket only the owner can use, got %v", info.Mode())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the socket left in the directory, got %v", entries)
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("expected the socket to accept connections at its path, got %v", err)
+	}
+	conn.Close()
+
+	listener.Close()
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Fatalf("expected closing the listener to remove the socket, got %v", err)
+	}
+}
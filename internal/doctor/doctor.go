@@ -0,0 +1,218 @@
+// internal/doctor/doctor.go
+package doctor
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/httputil"
+	"github.com/jaxxstorm/portal/internal/tailscale"
+)
+
+// Status is the outcome of a single diagnostic check.
+type Status int
+
+const (
+	StatusPass Status = iota
+	StatusWarn
+	StatusFail
+	StatusSkip
+)
+
+func (s Status) icon() string {
+	switch s {
+	case StatusPass:
+		return "✓"
+	case StatusWarn:
+		return "!"
+	case StatusFail:
+		return "✗"
+	default:
+		return "-"
+	}
+}
+
+// Result describes a check and, when it did not pass, how to fix it.
+type Result struct {
+	Name        string
+	Status      Status
+	Detail      string
+	Remediation string
+}
+
+// Tailnet is the subset of the Tailscale client used by the checks.
+type Tailnet interface {
+	Capabilities(ctx context.Context) (*tailscale.Capabilities, error)
+	ServePortsInUse(ctx context.Context) ([]uint16, error)
+}
+
+// Options selects which prerequisites are required rather than advisory.
+type Options struct {
+	// TargetPort is the local port to probe; zero skips the reachability check.
+	TargetPort int
+	// TargetHost is the address the target listens on; empty tries both
+	// loopback addresses.
+	TargetHost string
+	// ServePort is the tailnet port portal intends to serve on.
+	ServePort int
+	Funnel    bool
+	UseHTTPS  bool
+	// DialTimeout bounds the local reachability probe (default 2s).
+	DialTimeout time.Duration
+}
+
+// Run executes every check in order. Checks that depend on tailscaled are
+// skipped when the daemon cannot be reached.
+func Run(ctx context.Context, tailnet Tailnet, opts Options) []Result {
+	var results []Result
+
+	caps, err := tailnet.Capabilities(ctx)
+	if err != nil {
+		results = append(results, Result{
+			Name:        "tailscaled",
+			Status:      StatusFail,
+			Detail:      err.Error(),
+			Remediation: "Start Tailscale and log in (https://tailscale.com/download), or use --auth-key/--force-tsnet to run a separate tsnet device.",
+		})
+		for _, name := range []string{"HTTPS certificates", "Funnel", "MagicDNS", "Serve port"} {
+			results = append(results, Result{Name: name, Status: StatusSkip, Detail: "tailscaled not reachable"})
+		}
+	} else {
+		results = append(results,
+			checkDaemon(caps),
+			checkHTTPS(caps, opts),
+			checkFunnel(caps, opts),
+			checkMagicDNS(caps),
+			checkServePort(ctx, tailnet, opts),
+		)
+	}
+
+	results = append(results, checkTarget(opts))
+	return results
+}
+
+// Failed reports whether any result is a hard failure.
+func Failed(results []Result) bool {
+	return slices.ContainsFunc(results, func(r Result) bool { return r.Status == StatusFail })
+}
+
+// Print writes a human-readable report.
+func Print(w io.Writer, results []Result) {
+	for _, r := range results {
+		fmt.Fprintf(w, "  %s %-18s %s\n", r.Status.icon(), r.Name, r.Detail)
+		if r.Remediation != "" && (r.Status == StatusFail || r.Status == StatusWarn) {
+			fmt.Fprintf(w, "    → %s\n", r.Remediation)
+		}
+	}
+}
+
+func checkDaemon(caps *tailscale.Capabilities) Result {
+	if caps.BackendState != "" && caps.BackendState != "Running" {
+		return Result{
+			Name:        "tailscaled",
+			Status:      StatusFail,
+			Detail:      fmt.Sprintf("version %s, backend state %s", caps.Version, caps.BackendState),
+			Remediation: "Run `tailscale up` to connect this node to your tailnet.",
+		}
+	}
+	return Result{
+		Name:   "tailscaled",
+		Status: StatusPass,
+		Detail: fmt.Sprintf("version %s, node %s", caps.Version, caps.DNSName),
+	}
+}
+
+func checkHTTPS(caps *tailscale.Capabilities, opts Options) Result {
+	if caps.HTTPS {
+		return Result{Name: "HTTPS certificates", Status: StatusPass, Detail: "enabled"}
+	}
+	status := StatusWarn
+	if opts.UseHTTPS || opts.Funnel {
+		status = StatusFail
+	}
+	return Result{
+		Name:        "HTTPS certificates",
+		Status:      status,
+		Detail:      "not enabled for this tailnet",
+		Remediation: "Enable HTTPS under DNS settings in the admin console: https://login.tailscale.com/admin/dns",
+	}
+}
+
+func checkFunnel(caps *tailscale.Capabilities, opts Options) Result {
+	if caps.Funnel {
+		return Result{Name: "Funnel", Status: StatusPass, Detail: "node attribute granted"}
+	}
+	status := StatusWarn
+	if opts.Funnel {
+		status = StatusFail
+	}
+	return Result{
+		Name:        "Funnel",
+		Status:      status,
+		Detail:      "funnel node attribute missing",
+		Remediation: "Add a nodeAttrs entry granting \"funnel\" to this node in your tailnet policy: https://tailscale.com/kb/1223/funnel",
+	}
+}
+
+func checkMagicDNS(caps *tailscale.Capabilities) Result {
+	if caps.MagicDNS {
+		return Result{Name: "MagicDNS", Status: StatusPass, Detail: "enabled"}
+	}
+	return Result{
+		Name:        "MagicDNS",
+		Status:      StatusWarn,
+		Detail:      "disabled",
+		Remediation: "Enable MagicDNS in the admin console so the service URL resolves: https://login.tailscale.com/admin/dns",
+	}
+}
+
+func checkServePort(ctx context.Context, tailnet Tailnet, opts Options) Result {
+	ports, err := tailnet.ServePortsInUse(ctx)
+	if err != nil {
+		return Result{Name: "Serve port", Status: StatusWarn, Detail: err.Error()}
+	}
+	if opts.ServePort > 0 && slices.Contains(ports, uint16(opts.ServePort)) {
+		return Result{
+			Name:        "Serve port",
+			Status:      StatusFail,
+			Detail:      fmt.Sprintf("port %d already has a serve handler (in use: %v)", opts.ServePort, ports),
+			Remediation: "Choose another --serve-port, or clear stale handlers with `portal --cleanup-serve` (or `tailscale serve reset`).",
+		}
+	}
+	detail := "no existing serve handlers"
+	if len(ports) > 0 {
+		detail = fmt.Sprintf("port %d free (in use: %v)", opts.ServePort, ports)
+	}
+	return Result{Name: "Serve port", Status: StatusPass, Detail: detail}
+}
+
+func checkTarget(opts Options) Result {
+	if opts.TargetPort == 0 {
+		return Result{Name: "Local target", Status: StatusSkip, Detail: "no port given (run `portal doctor <port>` to check)"}
+	}
+
+	timeout := opts.DialTimeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	dialer := &httputil.LoopbackDialer{Host: opts.TargetHost}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	addr, err := dialer.Probe(ctx, strconv.Itoa(opts.TargetPort))
+	if err != nil {
+		target := net.JoinHostPort(cmp.Or(opts.TargetHost, "localhost"), strconv.Itoa(opts.TargetPort))
+		return Result{
+			Name:        "Local target",
+			Status:      StatusFail,
+			Detail:      fmt.Sprintf("%s is not accepting connections", target),
+			Remediation: "Start your dev server first, or use `portal --mock` to test without a backend.",
+		}
+	}
+	return Result{Name: "Local target", Status: StatusPass, Detail: fmt.Sprintf("%s is reachable", addr)}
+}
@@ -0,0 +1,100 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jaxxstorm/portal/internal/tailscale"
+)
+
+type stubTailnet struct {
+	caps    *tailscale.Capabilities
+	capsErr error
+	ports   []uint16
+}
+
+func (s stubTailnet) Capabilities(context.Context) (*tailscale.Capabilities, error) {
+	return s.caps, s.capsErr
+}
+
+func (s stubTailnet) ServePortsInUse(context.Context) ([]uint16, error) {
+	return s.ports, nil
+}
+
+func resultByName(t *testing.T, results []Result, name string) Result {
+	t.Helper()
+	for _, r := range results {
+		if r.Name == name {
+			return r
+		}
+	}
+	t.Fatalf("missing result %q", name)
+	return Result{}
+}
+
+func TestRunHealthyNode(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	results := Run(context.Background(), stubTailnet{
+		caps: &tailscale.Capabilities{
+			Version:      "1.94.1",
+			BackendState: "Running",
+			HTTPS:        true,
+			Funnel:       true,
+			MagicDNS:     true,
+		},
+		ports: []uint16{8443},
+	}, Options{
+		TargetPort: listener.Addr().(*net.TCPAddr).Port,
+		ServePort:  443,
+		Funnel:     true,
+	})
+
+	if Failed(results) {
+		var out bytes.Buffer
+		Print(&out, results)
+		t.Fatalf("expected no failures, got:\n%s", out.String())
+	}
+}
+
+func TestRunReportsMissingFunnelAndPortConflict(t *testing.T) {
+	results := Run(context.Background(), stubTailnet{
+		caps:  &tailscale.Capabilities{BackendState: "Running", HTTPS: true},
+		ports: []uint16{443},
+	}, Options{ServePort: 443, Funnel: true})
+
+	if got := resultByName(t, results, "Funnel"); got.Status != StatusFail || got.Remediation == "" {
+		t.Fatalf("expected funnel failure with remediation, got %+v", got)
+	}
+	if got := resultByName(t, results, "Serve port"); got.Status != StatusFail {
+		t.Fatalf("expected serve port conflict, got %+v", got)
+	}
+	if got := resultByName(t, results, "Local target"); got.Status != StatusSkip {
+		t.Fatalf("expected local target check to be skipped without a port, got %+v", got)
+	}
+}
+
+func TestRunSkipsTailnetChecksWithoutDaemon(t *testing.T) {
+	results := Run(context.Background(), stubTailnet{capsErr: errors.New("connection refused")}, Options{})
+
+	if got := resultByName(t, results, "tailscaled"); got.Status != StatusFail {
+		t.Fatalf("expected daemon failure, got %+v", got)
+	}
+	if got := resultByName(t, results, "HTTPS certificates"); got.Status != StatusSkip {
+		t.Fatalf("expected HTTPS check to be skipped, got %+v", got)
+	}
+
+	var out bytes.Buffer
+	Print(&out, results)
+	if !strings.Contains(out.String(), "tailscale.com/download") {
+		t.Fatalf("expected remediation text in report, got:\n%s", out.String())
+	}
+}
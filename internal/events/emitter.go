@@ -0,0 +1,123 @@
+// internal/events/emitter.go
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/startup"
+)
+
+// Event types written by the emitter.
+const (
+	TypeReady    = "ready"
+	TypeRequest  = "request"
+	TypeError    = "error"
+	TypeShutdown = "shutdown"
+	TypePlan     = "plan"
+)
+
+// Event is a single machine-readable lifecycle record. Only the fields that
+// apply to the event type are populated.
+type Event struct {
+	Type string    `json:"event"`
+	Time time.Time `json:"time"`
+
+	// ready
+	ServiceURL  string `json:"service_url,omitempty"`
+	LocalURL    string `json:"local_url,omitempty"`
+	WebUIURL    string `json:"web_ui_url,omitempty"`
+	Mode        string `json:"mode,omitempty"`
+	BackendMode string `json:"backend_mode,omitempty"`
+	Exposure    string `json:"exposure,omitempty"`
+
+	// request
+	ID         string  `json:"id,omitempty"`
+	Method     string  `json:"method,omitempty"`
+	URL        string  `json:"url,omitempty"`
+	Status     int     `json:"status,omitempty"`
+	DurationMS float64 `json:"duration_ms,omitempty"`
+	RemoteAddr string  `json:"remote_addr,omitempty"`
+	Size       int64   `json:"size,omitempty"`
+
+	// error
+	Error string `json:"error,omitempty"`
+
+	// shutdown
+	UptimeMS      int64 `json:"uptime_ms,omitempty"`
+	TotalRequests int   `json:"total_requests,omitempty"`
+
+	// plan
+	Plan *model.ServePlan `json:"plan,omitempty"`
+}
+
+// Emitter writes lifecycle events to w as JSON lines. It is safe for
+// concurrent use.
+type Emitter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+// NewEmitter returns an emitter that writes one JSON object per line to w.
+func NewEmitter(w io.Writer) *Emitter {
+	return &Emitter{enc: json.NewEncoder(w), now: time.Now}
+}
+
+// Ready announces that the service is reachable.
+func (e *Emitter) Ready(summary startup.Summary) {
+	e.emit(Event{
+		Type:        TypeReady,
+		ServiceURL:  summary.ServiceURL,
+		LocalURL:    summary.LocalURL,
+		WebUIURL:    summary.WebUIURL,
+		Mode:        summary.Mode,
+		BackendMode: summary.BackendMode,
+		Exposure:    summary.Exposure,
+	})
+}
+
+// Request summarizes a completed request. Its signature matches
+// proxy.Server.AddListener.
+func (e *Emitter) Request(entry model.RequestLog) {
+	e.emit(Event{
+		Type:       TypeRequest,
+		ID:         entry.ID,
+		Method:     entry.Method,
+		URL:        entry.URL,
+		Status:     entry.StatusCode,
+		DurationMS: float64(entry.Duration) / float64(time.Millisecond),
+		RemoteAddr: entry.RemoteAddr,
+		Size:       entry.Size,
+	})
+}
+
+// Error reports a setup or runtime failure.
+func (e *Emitter) Error(err error) {
+	e.emit(Event{Type: TypeError, Error: err.Error()})
+}
+
+// Shutdown reports that portal is exiting.
+func (e *Emitter) Shutdown(uptime time.Duration, totalRequests int) {
+	e.emit(Event{
+		Type:          TypeShutdown,
+		UptimeMS:      uptime.Milliseconds(),
+		TotalRequests: totalRequests,
+	})
+}
+
+// Plan reports what --dry-run would change.
+func (e *Emitter) Plan(plan *model.ServePlan) {
+	e.emit(Event{Type: TypePlan, Plan: plan})
+}
+
+func (e *Emitter) emit(event Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	event.Time = e.now().UTC()
+	_ = e.enc.Encode(event)
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/startup"
+)
+
+func TestEmitterWritesJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	emitter := NewEmitter(&buf)
+
+	emitter.Ready(startup.Summary{ServiceURL: "https://dev.example.ts.net", Exposure: startup.ExposureFunnel})
+	emitter.Request(model.RequestLog{ID: "req_1", Method: "GET", URL: "/health", StatusCode: 200, Duration: 1500 * time.Microsecond})
+	emitter.Error(errors.New("serve failed"))
+	emitter.Shutdown(3*time.Second, 1)
+
+	var events []Event
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("decode line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	if events[0].Type != TypeReady || events[0].ServiceURL != "https://dev.example.ts.net" {
+		t.Fatalf("unexpected ready event: %+v", events[0])
+	}
+	if events[1].Type != TypeRequest || events[1].Status != 200 || events[1].DurationMS != 1.5 {
+		t.Fatalf("unexpected request event: %+v", events[1])
+	}
+	if events[2].Type != TypeError || events[2].Error != "serve failed" {
+		t.Fatalf("unexpected error event: %+v", events[2])
+	}
+	if events[3].Type != TypeShutdown || events[3].UptimeMS != 3000 || events[3].TotalRequests != 1 {
+		t.Fatalf("unexpected shutdown event: %+v", events[3])
+	}
+}
@@ -0,0 +1,117 @@
+// internal/exitcode/exitcode.go
+
+// Package exitcode defines the codes portal exits with, so scripts can tell
+// why a run ended.
+package exitcode
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	// OK means portal ran and shut down normally.
+	OK = 0
+	// Failure is a runtime failure without a more specific code.
+	Failure = 1
+	// Config means the flags, environment or config files are invalid.
+	Config = 2
+	// TailscaleUnavailable means portal could not reach Tailscale or set up
+	// serving through it.
+	TailscaleUnavailable = 3
+	// FunnelNotPermitted means Funnel was requested but the tailnet doesn't
+	// allow it for this machine.
+	FunnelNotPermitted = 4
+	// UpstreamUnreachable means the target could not be reached.
+	UpstreamUnreachable = 5
+)
+
+// Error is a failure with the code portal should exit with.
+type Error struct {
+	Code int
+	Err  error
+}
+
+// New returns err with code attached, or nil for a nil err.
+func New(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the exit code for err: OK for nil, the code of the first
+// Error in its chain, otherwise Failure.
+func Code(err error) int {
+	if err == nil {
+		return OK
+	}
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	return Failure
+}
+
+// Recorder keeps the first failure of a run, which decides the exit code.
+// With fail-fast on it also stops the run, rather than leaving portal
+// running without being able to serve.
+type Recorder struct {
+	failFast bool
+	stop     func()
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewRecorder returns a Recorder that calls stop on failures when failFast
+// is set.
+func NewRecorder(failFast bool, stop func()) *Recorder {
+	return &Recorder{failFast: failFast, stop: stop}
+}
+
+// FailFast reports whether failures stop the run.
+func (r *Recorder) FailFast() bool {
+	return r.failFast
+}
+
+// Fail records err, tagged with code unless it already has one.
+func (r *Recorder) Fail(code int, err error) {
+	if err == nil {
+		return
+	}
+	var coded *Error
+	if !errors.As(err, &coded) {
+		err = New(code, err)
+	}
+
+	r.mu.Lock()
+	if r.err == nil {
+		r.err = err
+	}
+	r.mu.Unlock()
+
+	if r.failFast && r.stop != nil {
+		r.stop()
+	}
+}
+
+// Err returns the first recorded failure, or nil.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Code returns the exit code for the first recorded failure.
+func (r *Recorder) Code() int {
+	return Code(r.Err())
+}
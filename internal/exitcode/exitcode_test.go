@@ -0,0 +1,53 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCode(t *testing.T) {
+	tagged := New(UpstreamUnreachable, errors.New("connection refused"))
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{nil, OK},
+		{errors.New("boom"), Failure},
+		{tagged, UpstreamUnreachable},
+		{fmt.Errorf("probe failed: %w", tagged), UpstreamUnreachable},
+	} {
+		if got := Code(tc.err); got != tc.want {
+			t.Fatalf("Code(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+	if New(Config, nil) != nil {
+		t.Fatal("expected New to keep a nil error nil")
+	}
+}
+
+func TestRecorderKeepsFirstFailure(t *testing.T) {
+	stops := 0
+	recorder := NewRecorder(false, func() { stops++ })
+	if recorder.Code() != OK {
+		t.Fatalf("expected OK before any failure, got %d", recorder.Code())
+	}
+
+	recorder.Fail(TailscaleUnavailable, errors.New("tailscaled not running"))
+	recorder.Fail(Failure, New(FunnelNotPermitted, errors.New("funnel off")))
+	if recorder.Code() != TailscaleUnavailable || recorder.Err().Error() != "tailscaled not running" {
+		t.Fatalf("expected the first failure to be kept, got %d %v", recorder.Code(), recorder.Err())
+	}
+	if stops != 0 {
+		t.Fatalf("expected no stop without fail-fast, got %d", stops)
+	}
+
+	recorder = NewRecorder(true, func() { stops++ })
+	recorder.Fail(Failure, New(FunnelNotPermitted, errors.New("funnel off")))
+	if recorder.Code() != FunnelNotPermitted {
+		t.Fatalf("expected an existing code to win, got %d", recorder.Code())
+	}
+	if stops != 1 {
+		t.Fatalf("expected fail-fast to stop the run, got %d stops", stops)
+	}
+}
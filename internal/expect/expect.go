@@ -0,0 +1,277 @@
+// internal/expect/expect.go
+
+// Package expect waits for a running portal to capture requests matching an
+// expectation, for use as an assertion step in CI.
+package expect
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/httputil"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// DefaultTimeout is how long Wait waits when no timeout is set.
+const DefaultTimeout = time.Minute
+
+// pollInterval is how often the request history is fetched.
+const pollInterval = 500 * time.Millisecond
+
+// statusSpec matches a --status value: a code such as 200 or a class such
+// as 2xx.
+var statusSpec = regexp.MustCompile(`^[1-5](\d\d|xx)$`)
+
+// Expectation describes the requests to wait for.
+type Expectation struct {
+	// Count is how many matching requests are needed, at least one.
+	Count int
+	// Method, Path and Status narrow the requests that match: a method, a
+	// path prefix, and a status code or class such as 2xx. Empty matches
+	// any.
+	Method string
+	Path   string
+	Status string
+	// New only counts requests captured after Wait started.
+	New     bool
+	Timeout time.Duration
+}
+
+// Validate normalizes e and reports invalid fields.
+func (e *Expectation) Validate() error {
+	if e.Count < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+	e.Method = strings.ToUpper(strings.TrimSpace(e.Method))
+	e.Status = strings.ToLower(strings.TrimSpace(e.Status))
+	if e.Status != "" && !statusSpec.MatchString(e.Status) {
+		return fmt.Errorf("invalid --status %q: must be a code such as 200 or a class such as 2xx", e.Status)
+	}
+	if e.Path != "" && !strings.HasPrefix(e.Path, "/") {
+		return fmt.Errorf("invalid --path %q: must start with /", e.Path)
+	}
+	if e.Timeout < 0 {
+		return fmt.Errorf("--timeout must not be negative")
+	}
+	if e.Timeout == 0 {
+		e.Timeout = DefaultTimeout
+	}
+	return nil
+}
+
+// String describes e, e.g. "3 POST /hooks requests with status 200".
+func (e Expectation) String() string {
+	parts := []string{strconv.Itoa(e.Count)}
+	if e.Method != "" {
+		parts = append(parts, e.Method)
+	}
+	if e.Path != "" {
+		parts = append(parts, e.Path)
+	}
+	noun := "requests"
+	if e.Count == 1 {
+		noun = "request"
+	}
+	parts = append(parts, noun)
+	if e.Status != "" {
+		parts = append(parts, "with status "+e.Status)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Matches reports whether entry is one of the expected requests.
+func (e Expectation) Matches(entry model.RequestLog) bool {
+	if e.Method != "" && entry.Method != e.Method {
+		return false
+	}
+	if e.Path != "" && !httputil.UnderPath(requestPath(entry.URL), e.Path) {
+		return false
+	}
+	if e.Status != "" {
+		status := strconv.Itoa(cmp.Or(entry.Response.StatusCode, entry.StatusCode))
+		if strings.HasSuffix(e.Status, "xx") {
+			return status[:1] == e.Status[:1]
+		}
+		return status == e.Status
+	}
+	return true
+}
+
+// Result is the outcome of Wait.
+type Result struct {
+	Expectation Expectation
+	// Matched are the IDs of the matching requests, oldest first.
+	Matched []string
+	Elapsed time.Duration
+	// Err is set when the history couldn't be fetched.
+	Err error
+}
+
+// Met reports whether enough requests matched.
+func (r Result) Met() bool {
+	return r.Err == nil && len(r.Matched) >= r.Expectation.Count
+}
+
+// Wait polls the request history of the portal whose web UI is at uiURL
+// until e is met or its timeout passes.
+func Wait(ctx context.Context, client *http.Client, uiURL string, e Expectation) Result {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+
+	result := Result{Expectation: e}
+	var before map[string]bool
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		entries, err := fetchRequests(ctx, client, uiURL)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			result.Err = err
+		case err == nil:
+			result.Err = nil
+			if e.New && before == nil {
+				before = make(map[string]bool, len(entries))
+				for _, entry := range entries {
+					before[entry.ID] = true
+				}
+			}
+			result.Matched = result.Matched[:0]
+			for _, entry := range entries {
+				if !before[entry.ID] && e.Matches(entry) {
+					result.Matched = append(result.Matched, entry.ID)
+				}
+			}
+		}
+		if result.Met() {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			result.Elapsed = time.Since(start)
+			return result
+		case <-ticker.C:
+		}
+	}
+	result.Elapsed = time.Since(start)
+	return result
+}
+
+func fetchRequests(ctx context.Context, client *http.Client, uiURL string) ([]model.RequestLog, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uiURL+"/api/requests", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach portal at %s (is it running? set --ui-url): %w", uiURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("portal at %s answered %s", uiURL, resp.Status)
+	}
+	var entries []model.RequestLog
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to read the request history: %w", err)
+	}
+	return entries, nil
+}
+
+// Summary is a one-line description of r for people.
+func (r Result) Summary() string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("expected %s: %v", r.Expectation, r.Err)
+	case r.Met():
+		return fmt.Sprintf("saw %s in %s", r.Expectation, r.Elapsed.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("expected %s within %s, saw %d", r.Expectation, r.Expectation.Timeout, len(r.Matched))
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes r to w as a JUnit XML report with one test case.
+func WriteJUnit(w io.Writer, r Result) error {
+	seconds := strconv.FormatFloat(r.Elapsed.Seconds(), 'f', 3, 64)
+	testCase := junitCase{
+		Name:      r.Expectation.String(),
+		Classname: "portal.expect",
+		Time:      seconds,
+	}
+	if len(r.Matched) > 0 {
+		testCase.SystemOut = "matched requests: " + strings.Join(r.Matched, ", ")
+	}
+	suite := junitSuite{Name: "portal expect", Tests: 1, Time: seconds}
+	switch {
+	case r.Err != nil:
+		testCase.Error = &junitProblem{Message: r.Err.Error(), Text: r.Summary()}
+		suite.Errors = 1
+	case !r.Met():
+		testCase.Failure = &junitProblem{Message: r.Summary(), Text: r.Summary()}
+		suite.Failures = 1
+	}
+	suite.Cases = []junitCase{testCase}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// requestPath is the path of a captured URL, which may be absolute or
+// only a path and query.
+func requestPath(raw string) string {
+	if i := strings.Index(raw, "://"); i >= 0 {
+		raw = raw[i+3:]
+		if j := strings.IndexByte(raw, '/'); j >= 0 {
+			raw = raw[j:]
+		} else {
+			raw = "/"
+		}
+	}
+	if i := strings.IndexAny(raw, "?#"); i >= 0 {
+		raw = raw[:i]
+	}
+	return raw
+}
@@ -0,0 +1,164 @@
+package expect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+type fakePortal struct {
+	mu      sync.Mutex
+	entries []model.RequestLog
+}
+
+func (f *fakePortal) add(entry model.RequestLog) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries = append(f.entries, entry)
+}
+
+func (f *fakePortal) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/requests" {
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	json.NewEncoder(w).Encode(f.entries)
+}
+
+func TestValidate(t *testing.T) {
+	e := Expectation{Count: 2, Method: " post ", Status: "2XX"}
+	if err := e.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if e.Method != "POST" || e.Status != "2xx" || e.Timeout != DefaultTimeout {
+		t.Fatalf("unexpected normalized expectation %+v", e)
+	}
+
+	for _, bad := range []Expectation{
+		{Count: 0},
+		{Count: 1, Status: "2x"},
+		{Count: 1, Status: "700"},
+		{Count: 1, Path: "hooks"},
+		{Count: 1, Timeout: -time.Second},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Fatalf("expected %+v to be invalid", bad)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	e := Expectation{Count: 1, Method: "POST", Path: "/hooks", Status: "2xx"}
+	tests := []struct {
+		entry model.RequestLog
+		want  bool
+	}{
+		{model.RequestLog{Method: "POST", URL: "/hooks", StatusCode: 200}, true},
+		{model.RequestLog{Method: "POST", URL: "/hooks/github?x=1", StatusCode: 204}, true},
+		{model.RequestLog{Method: "POST", URL: "https://app.example.ts.net/hooks/a", StatusCode: 201}, true},
+		{model.RequestLog{Method: "POST", URL: "/hooksy", StatusCode: 200}, false},
+		{model.RequestLog{Method: "GET", URL: "/hooks", StatusCode: 200}, false},
+		{model.RequestLog{Method: "POST", URL: "/hooks", StatusCode: 500}, false},
+	}
+	for _, tt := range tests {
+		if got := e.Matches(tt.entry); got != tt.want {
+			t.Errorf("Matches(%s %s %d) = %t, want %t", tt.entry.Method, tt.entry.URL, tt.entry.StatusCode, got, tt.want)
+		}
+	}
+
+	exact := Expectation{Count: 1, Status: "404"}
+	if !exact.Matches(model.RequestLog{StatusCode: 404}) || exact.Matches(model.RequestLog{StatusCode: 400}) {
+		t.Fatal("expected an exact status to match only that code")
+	}
+}
+
+func TestWaitMet(t *testing.T) {
+	portal := &fakePortal{}
+	portal.add(model.RequestLog{ID: "a", Method: "POST", URL: "/hooks", StatusCode: 200})
+	server := httptest.NewServer(portal)
+	defer server.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		portal.add(model.RequestLog{ID: "b", Method: "GET", URL: "/", StatusCode: 200})
+		portal.add(model.RequestLog{ID: "c", Method: "POST", URL: "/hooks/x", StatusCode: 200})
+	}()
+
+	e := Expectation{Count: 2, Path: "/hooks", Timeout: 5 * time.Second}
+	result := Wait(context.Background(), server.Client(), server.URL, e)
+	if !result.Met() || strings.Join(result.Matched, ",") != "a,c" {
+		t.Fatalf("expected a and c to match, got %+v", result)
+	}
+}
+
+func TestWaitNewIgnoresEarlierRequests(t *testing.T) {
+	portal := &fakePortal{}
+	portal.add(model.RequestLog{ID: "old", Method: "POST", URL: "/hooks", StatusCode: 200})
+	server := httptest.NewServer(portal)
+	defer server.Close()
+
+	e := Expectation{Count: 1, Path: "/hooks", New: true, Timeout: 700 * time.Millisecond}
+	result := Wait(context.Background(), server.Client(), server.URL, e)
+	if result.Met() || len(result.Matched) != 0 {
+		t.Fatalf("expected the earlier request not to count, got %+v", result)
+	}
+	if !strings.Contains(result.Summary(), "saw 0") {
+		t.Fatalf("unexpected summary %q", result.Summary())
+	}
+}
+
+func TestWaitUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	e := Expectation{Count: 1, Timeout: 200 * time.Millisecond}
+	result := Wait(context.Background(), http.DefaultClient, url, e)
+	if result.Met() || result.Err == nil || !strings.Contains(result.Err.Error(), "could not reach portal") {
+		t.Fatalf("expected an unreachable error, got %+v", result)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	e := Expectation{Count: 3, Path: "/hooks", Status: "200", Timeout: 2 * time.Minute}
+
+	var passed bytes.Buffer
+	if err := WriteJUnit(&passed, Result{Expectation: e, Matched: []string{"a", "b", "c"}, Elapsed: 1500 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	var report junitSuites
+	if err := xml.Unmarshal(passed.Bytes(), &report); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, passed.String())
+	}
+	suite := report.Suites[0]
+	if suite.Tests != 1 || suite.Failures != 0 || suite.Time != "1.500" || suite.Cases[0].Failure != nil {
+		t.Fatalf("unexpected passing report:\n%s", passed.String())
+	}
+	if suite.Cases[0].Name != "3 /hooks requests with status 200" {
+		t.Fatalf("unexpected test case name %q", suite.Cases[0].Name)
+	}
+
+	var failed bytes.Buffer
+	if err := WriteJUnit(&failed, Result{Expectation: e, Matched: []string{"a"}, Elapsed: 2 * time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	report = junitSuites{}
+	if err := xml.Unmarshal(failed.Bytes(), &report); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, failed.String())
+	}
+	suite = report.Suites[0]
+	if suite.Failures != 1 || suite.Cases[0].Failure == nil || !strings.Contains(suite.Cases[0].Failure.Message, "within 2m0s, saw 1") {
+		t.Fatalf("unexpected failing report:\n%s", failed.String())
+	}
+}
@@ -0,0 +1,131 @@
+// internal/filters/filters.go
+package filters
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxNameLength bounds filter names, which show up in a dropdown and URLs.
+const maxNameLength = 64
+
+// ErrFilterNotFound is returned when deleting an unknown filter.
+var ErrFilterNotFound = errors.New("filter not found")
+
+// Filter is a named request filter saved from the web UI: the filter box's
+// query and the time range, in minutes (zero for all time).
+type Filter struct {
+	Name  string `yaml:"name" json:"name"`
+	Query string `yaml:"query" json:"query"`
+	Since int    `yaml:"since,omitempty" json:"since,omitempty"`
+}
+
+// Store holds saved filters and writes every change back to its file. It
+// is safe for concurrent use.
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	filters []Filter
+}
+
+type file struct {
+	Filters []Filter `yaml:"filters"`
+}
+
+// DefaultPath returns the saved filter file location
+// (~/.portal/filters.yml).
+func DefaultPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".portal", "filters.yml"), nil
+}
+
+// Load reads the filter file at path. A missing file is an empty store.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read filter file %s: %w", path, err)
+	}
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse filter file %s: %w", path, err)
+	}
+	s.filters = f.Filters
+	return s, nil
+}
+
+// List returns the saved filters, sorted by name.
+func (s *Store) List() []Filter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	filters := slices.Clone(s.filters)
+	slices.SortFunc(filters, func(a, b Filter) int { return cmp.Compare(a.Name, b.Name) })
+	return filters
+}
+
+// Put saves filter, replacing any with the same name.
+func (s *Store) Put(filter Filter) error {
+	filter.Name = strings.TrimSpace(filter.Name)
+	filter.Query = strings.TrimSpace(filter.Query)
+	switch {
+	case filter.Name == "":
+		return fmt.Errorf("filter name is required")
+	case len(filter.Name) > maxNameLength:
+		return fmt.Errorf("filter name must be at most %d characters", maxNameLength)
+	case filter.Query == "" && filter.Since == 0:
+		return fmt.Errorf("filter %q matches every request", filter.Name)
+	case filter.Since < 0:
+		return fmt.Errorf("filter %q has a negative time range", filter.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	filters := slices.Clone(s.filters)
+	if i := slices.IndexFunc(filters, func(f Filter) bool { return f.Name == filter.Name }); i >= 0 {
+		filters[i] = filter
+	} else {
+		filters = append(filters, filter)
+	}
+	return s.save(filters)
+}
+
+// Delete removes the filter called name.
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.filters, func(f Filter) bool { return f.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrFilterNotFound, name)
+	}
+	return s.save(slices.Delete(slices.Clone(s.filters), i, i+1))
+}
+
+// save writes filters to the file and keeps them once that worked.
+func (s *Store) save(filters []Filter) error {
+	data, err := yaml.Marshal(file{Filters: filters})
+	if err != nil {
+		return fmt.Errorf("failed to encode filters: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create filter directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write filter file %s: %w", s.path, err)
+	}
+	s.filters = filters
+	return nil
+}
@@ -0,0 +1,52 @@
+package filters
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorePersistsFilters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filters.yml")
+	store, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(store.List()) != 0 {
+		t.Fatalf("expected a missing file to be an empty store")
+	}
+
+	if err := store.Put(Filter{Name: "failed Stripe webhooks", Query: "path:/stripe status:>=400"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put(Filter{Name: "recent", Since: 10}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put(Filter{Name: "failed Stripe webhooks", Query: "path:/stripe status:5xx"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	got := reloaded.List()
+	if len(got) != 2 || got[0].Query != "path:/stripe status:5xx" || got[1].Since != 10 {
+		t.Fatalf("expected the saved filters back, replaced by name, got %+v", got)
+	}
+
+	if err := reloaded.Delete("recent"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := reloaded.Delete("recent"); !errors.Is(err, ErrFilterNotFound) {
+		t.Fatalf("expected ErrFilterNotFound, got %v", err)
+	}
+	for _, filter := range []Filter{{Query: "status:500"}, {Name: "everything"}, {Name: "back", Since: -1}} {
+		if err := reloaded.Put(filter); err == nil {
+			t.Fatalf("expected %+v to be rejected", filter)
+		}
+	}
+	if reloaded, err = Load(path); err != nil || len(reloaded.List()) != 1 {
+		t.Fatalf("expected one filter left on disk, got %+v (%v)", reloaded.List(), err)
+	}
+}
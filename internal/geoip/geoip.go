@@ -0,0 +1,74 @@
+// internal/geoip/geoip.go
+package geoip
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// DB looks up where addresses are in one or more MaxMind DB files, such as
+// GeoLite2-Country and GeoLite2-ASN, merging what each knows. A nil DB knows
+// nothing.
+type DB struct {
+	dbs []*mmdb
+}
+
+// Open reads the databases at paths into memory.
+func Open(paths []string) (*DB, error) {
+	db := &DB{}
+	for _, path := range paths {
+		file, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+		}
+		parsed, err := parseMMDB(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GeoIP database %s: %w", path, err)
+		}
+		db.dbs = append(db.dbs, parsed)
+	}
+	return db, nil
+}
+
+// Lookup returns the country and network of a public address, or nil when
+// no database knows it. Private, loopback and Tailscale addresses are never
+// looked up.
+func (db *DB) Lookup(addr netip.Addr) *model.GeoIP {
+	if db == nil || !addr.IsValid() || !addr.IsGlobalUnicast() || addr.IsPrivate() || tailscaleRange.Contains(addr.Unmap()) {
+		return nil
+	}
+	var geo model.GeoIP
+	for _, d := range db.dbs {
+		value, err := d.lookup(addr)
+		if err != nil {
+			continue
+		}
+		record, _ := value.(map[string]any)
+		// City and Country databases have a country, falling back to the
+		// registered country for anycast and satellite networks.
+		for _, key := range []string{"country", "registered_country"} {
+			if geo.Country != "" {
+				break
+			}
+			country, _ := record[key].(map[string]any)
+			geo.Country, _ = country["iso_code"].(string)
+			names, _ := country["names"].(map[string]any)
+			geo.CountryName, _ = names["en"].(string)
+		}
+		// ASN databases.
+		if asn, ok := record["autonomous_system_number"].(uint64); ok && geo.ASN == 0 {
+			geo.ASN = uint32(asn)
+			geo.ASOrg, _ = record["autonomous_system_organization"].(string)
+		}
+	}
+	if geo == (model.GeoIP{}) {
+		return nil
+	}
+	return &geo
+}
+
+// tailscaleRange is the CGNAT range tailnet addresses come from.
+var tailscaleRange = netip.MustParsePrefix("100.64.0.0/10")
@@ -0,0 +1,213 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// encoder writes MaxMind DB data section values. Repeated strings are
+// written as pointers to the first copy, as real databases do.
+type encoder struct {
+	buf     []byte
+	strings map[string]int
+}
+
+func (e *encoder) header(kind, size int) {
+	ctrl := kind << 5
+	if kind > 7 {
+		ctrl = 0
+	}
+	switch {
+	case size < 29:
+		e.buf = append(e.buf, byte(ctrl|size))
+	case size < 285:
+		e.buf = append(e.buf, byte(ctrl|29))
+	default:
+		e.buf = append(e.buf, byte(ctrl|30))
+	}
+	if kind > 7 {
+		e.buf = append(e.buf, byte(kind-7))
+	}
+	switch {
+	case size >= 285:
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(size-285))
+	case size >= 29:
+		e.buf = append(e.buf, byte(size-29))
+	}
+}
+
+func (e *encoder) encode(v any) {
+	switch v := v.(type) {
+	case string:
+		if offset, ok := e.strings[v]; ok {
+			e.buf = append(e.buf, byte(typePointer<<5|offset>>8), byte(offset))
+			return
+		}
+		e.strings[v] = len(e.buf)
+		e.header(typeString, len(v))
+		e.buf = append(e.buf, v...)
+	case int:
+		b := binary.BigEndian.AppendUint32(nil, uint32(v))
+		for len(b) > 0 && b[0] == 0 {
+			b = b[1:]
+		}
+		e.header(typeUint32, len(b))
+		e.buf = append(e.buf, b...)
+	case map[string]any:
+		e.header(typeMap, len(v))
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			e.encode(key)
+			e.encode(v[key])
+		}
+	}
+}
+
+type entry struct {
+	prefix string
+	record map[string]any
+}
+
+// buildMMDB writes a MaxMind DB file mapping each prefix to its record.
+func buildMMDB(t *testing.T, recordSize, ipVersion int, entries []entry) string {
+	t.Helper()
+	data := &encoder{strings: map[string]int{}}
+	// Tree records: 0 is empty, positive values are node numbers and
+	// negative ones are -(offset+1) into the data section.
+	nodes := [][2]int{{}}
+	for _, e := range entries {
+		prefix := netip.MustParsePrefix(e.prefix)
+		var bits []byte
+		length := prefix.Bits()
+		if prefix.Addr().Is4() {
+			b := prefix.Addr().As4()
+			bits = b[:]
+			if ipVersion == 6 {
+				bits = append(make([]byte, 12), bits...)
+				length += 96
+			}
+		} else {
+			b := prefix.Addr().As16()
+			bits = b[:]
+		}
+		offset := len(data.buf)
+		data.encode(e.record)
+
+		node := 0
+		for i := range length {
+			bit := int(bits[i/8]>>(7-i%8)) & 1
+			if i == length-1 {
+				nodes[node][bit] = -(offset + 1)
+				break
+			}
+			if nodes[node][bit] == 0 {
+				nodes = append(nodes, [2]int{})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	var file []byte
+	count := len(nodes)
+	value := func(r int) uint32 {
+		switch {
+		case r == 0:
+			return uint32(count)
+		case r < 0:
+			return uint32(count + dataSeparator - r - 1)
+		}
+		return uint32(r)
+	}
+	for _, n := range nodes {
+		left, right := value(n[0]), value(n[1])
+		switch recordSize {
+		case 24:
+			file = append(file, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			file = append(file, byte(left>>16), byte(left>>8), byte(left), byte(left>>24)<<4|byte(right>>24), byte(right>>16), byte(right>>8), byte(right))
+		case 32:
+			file = binary.BigEndian.AppendUint32(file, left)
+			file = binary.BigEndian.AppendUint32(file, right)
+		}
+	}
+	file = append(file, make([]byte, dataSeparator)...)
+	file = append(file, data.buf...)
+	file = append(file, metadataMarker...)
+	meta := &encoder{strings: map[string]int{}}
+	meta.encode(map[string]any{
+		"node_count":    count,
+		"record_size":   recordSize,
+		"ip_version":    ipVersion,
+		"database_type": "Test",
+	})
+	file = append(file, meta.buf...)
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLookup(t *testing.T) {
+	germany := map[string]any{"iso_code": "DE", "names": map[string]any{"en": "Germany", "de": "Deutschland"}}
+	for _, recordSize := range []int{24, 28, 32} {
+		countries := buildMMDB(t, recordSize, 6, []entry{
+			{"81.2.69.0/24", map[string]any{"country": germany}},
+			{"2a02:1234::/32", map[string]any{"registered_country": germany}},
+			{"89.160.20.0/22", map[string]any{"country": map[string]any{"iso_code": "SE", "names": map[string]any{"en": "Sweden"}}}},
+		})
+		asns := buildMMDB(t, recordSize, 4, []entry{
+			{"81.2.0.0/16", map[string]any{"autonomous_system_number": 20712, "autonomous_system_organization": "Andrews & Arnold Ltd"}},
+		})
+		db, err := Open([]string{countries, asns})
+		if err != nil {
+			t.Fatalf("record size %d: %v", recordSize, err)
+		}
+
+		for addr, want := range map[string]*model.GeoIP{
+			"81.2.69.160":        {Country: "DE", CountryName: "Germany", ASN: 20712, ASOrg: "Andrews & Arnold Ltd"},
+			"::ffff:81.2.69.160": {Country: "DE", CountryName: "Germany", ASN: 20712, ASOrg: "Andrews & Arnold Ltd"},
+			"81.2.1.1":           {ASN: 20712, ASOrg: "Andrews & Arnold Ltd"},
+			"89.160.23.255":      {Country: "SE", CountryName: "Sweden"},
+			"2a02:1234::1":       {Country: "DE", CountryName: "Germany"},
+			"8.8.8.8":            nil,
+			"192.168.1.10":       nil,
+			"100.101.102.103":    nil,
+			"127.0.0.1":          nil,
+		} {
+			got := db.Lookup(netip.MustParseAddr(addr))
+			if (got == nil) != (want == nil) || (got != nil && *got != *want) {
+				t.Fatalf("record size %d: %s: expected %+v, got %+v", recordSize, addr, want, got)
+			}
+		}
+	}
+
+	if (*DB)(nil).Lookup(netip.MustParseAddr("81.2.69.160")) != nil {
+		t.Fatal("expected a nil DB to know nothing")
+	}
+}
+
+func TestOpenRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.csv")
+	if err := os.WriteFile(path, []byte("network,country\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open([]string{path}); err == nil {
+		t.Fatal("expected a file without MaxMind DB metadata to be rejected")
+	}
+	if _, err := Open([]string{filepath.Join(t.TempDir(), "missing.mmdb")}); err == nil {
+		t.Fatal("expected a missing file to be rejected")
+	}
+}
@@ -0,0 +1,329 @@
+// internal/geoip/mmdb.go
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+)
+
+// metadataMarker precedes the metadata map at the end of a MaxMind DB file.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSeparator is the run of zero bytes between the search tree and the
+// data section.
+const dataSeparator = 16
+
+// mmdb reads a MaxMind DB file, the format of GeoLite2 and GeoIP2
+// databases: a binary search tree over address bits whose leaves point into
+// a section of typed values.
+type mmdb struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dbType     string
+	// ipv4Start is the node IPv4 lookups start from in an IPv6 tree: the
+	// one reached by following 96 zero bits.
+	ipv4Start uint
+}
+
+func parseMMDB(file []byte) (*mmdb, error) {
+	i := bytes.LastIndex(file, metadataMarker)
+	if i < 0 {
+		return nil, errors.New("not a MaxMind DB file: no metadata")
+	}
+	meta, _, err := decoder{data: file[i+len(metadataMarker):]}.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	fields, ok := meta.(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid metadata: not a map")
+	}
+	db := &mmdb{
+		nodeCount:  uintField(fields, "node_count"),
+		recordSize: uintField(fields, "record_size"),
+		ipVersion:  uintField(fields, "ip_version"),
+	}
+	db.dbType, _ = fields["database_type"].(string)
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", db.recordSize)
+	}
+	if db.ipVersion != 4 && db.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", db.ipVersion)
+	}
+
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+dataSeparator > uint(i) {
+		return nil, errors.New("invalid database: search tree is larger than the file")
+	}
+	db.tree = file[:treeSize]
+	db.data = file[treeSize+dataSeparator : i]
+
+	if db.ipVersion == 6 {
+		for n := 0; n < 96 && db.ipv4Start < db.nodeCount; n++ {
+			db.ipv4Start = db.record(db.ipv4Start, 0)
+		}
+	}
+	return db, nil
+}
+
+func uintField(fields map[string]any, name string) uint {
+	v, _ := fields[name].(uint64)
+	return uint(v)
+}
+
+// lookup returns the value stored for addr, or nil when it has none.
+func (db *mmdb) lookup(addr netip.Addr) (any, error) {
+	addr = addr.Unmap()
+	var bits []byte
+	node := uint(0)
+	switch {
+	case addr.Is4() && db.ipVersion == 6:
+		b := addr.As4()
+		bits, node = b[:], db.ipv4Start
+	case addr.Is4():
+		b := addr.As4()
+		bits = b[:]
+	case db.ipVersion == 4:
+		return nil, nil
+	default:
+		b := addr.As16()
+		bits = b[:]
+	}
+
+	for i := 0; i < len(bits)*8 && node < db.nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-i%8)) & 1
+		node = db.record(node, bit)
+	}
+	switch {
+	case node == db.nodeCount:
+		return nil, nil
+	case node < db.nodeCount:
+		return nil, errors.New("invalid database: address ended inside the search tree")
+	}
+	offset := node - db.nodeCount - dataSeparator
+	if offset >= uint(len(db.data)) {
+		return nil, errors.New("invalid database: record points past the data section")
+	}
+	value, _, err := decoder{data: db.data}.decode(offset, 0)
+	return value, err
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (db *mmdb) record(node, bit uint) uint {
+	size := db.recordSize / 4
+	b := db.tree[node*size : (node+1)*size]
+	switch db.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// Data section field types.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxDepth bounds how deeply maps and arrays may nest, so a corrupt file
+// can't recurse forever.
+const maxDepth = 32
+
+// decoder decodes values from a data section.
+type decoder struct {
+	data []byte
+}
+
+// decode returns the value at offset and the offset just past it. Pointers
+// are followed, but the returned offset is past the pointer itself.
+func (d decoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("values nested too deeply")
+	}
+	kind, size, offset, err := d.header(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if kind == typePointer {
+		value, _, err := d.decode(size, depth+1)
+		return value, offset, err
+	}
+	if kind == typeBool {
+		return size != 0, offset, nil
+	}
+
+	switch kind {
+	case typeMap:
+		m := make(map[string]any, min(size, 64))
+		for range size {
+			var key, value any
+			if key, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			if value, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			m[name] = value
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 64))
+		for range size {
+			var value any
+			if value, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.data)) || end < offset {
+		return nil, 0, errors.New("value runs past the data section")
+	}
+	b := d.data[offset:end]
+	switch kind {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes:
+		return bytes.Clone(b), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("double of size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("float of size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), end, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("unsigned integer of size %d", size)
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, end, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("int32 of size %d", size)
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), end, nil
+	case typeUint128:
+		// Left as big-endian bytes; nothing portal reads is this wide.
+		return bytes.Clone(b), end, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", kind)
+	}
+}
+
+// header reads a field's control byte and any extended type and size
+// bytes. For pointers, size is the offset pointed to.
+func (d decoder) header(offset uint) (kind, size, next uint, err error) {
+	next = offset
+	read := func(n uint) ([]byte, error) {
+		if next+n > uint(len(d.data)) {
+			return nil, errors.New("field header runs past the data section")
+		}
+		b := d.data[next : next+n]
+		next += n
+		return b, nil
+	}
+
+	b, err := read(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	ctrl := uint(b[0])
+	kind = ctrl >> 5
+	if kind == typePointer {
+		n := (ctrl>>3)&0x3 + 1
+		b, err := read(n)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		var v uint
+		if n < 4 {
+			v = ctrl & 0x7
+		}
+		for _, c := range b {
+			v = v<<8 | uint(c)
+		}
+		switch n {
+		case 2:
+			v += 2048
+		case 3:
+			v += 526336
+		}
+		return kind, v, next, nil
+	}
+	if kind == typeExtended {
+		b, err := read(1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		kind = 7 + uint(b[0])
+	}
+
+	size = ctrl & 0x1f
+	if size >= 29 {
+		b, err := read(size - 28)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		var v uint
+		for _, c := range b {
+			v = v<<8 | uint(c)
+		}
+		switch size {
+		case 29:
+			size = 29 + v
+		case 30:
+			size = 285 + v
+		default:
+			size = 65821 + v
+		}
+	}
+	return kind, size, next, nil
+}
@@ -0,0 +1,82 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+// LoopbackHosts are the addresses "localhost" is tried as, IPv4 first.
+var LoopbackHosts = []string{"127.0.0.1", "::1"}
+
+// LoopbackDialer connects to local targets. Apps often listen on only one of
+// 127.0.0.1 and ::1, and "localhost" may resolve to the other, so addresses
+// on localhost are dialled on each loopback address in turn. The one that
+// answers is tried first next time.
+type LoopbackDialer struct {
+	// Host replaces "localhost" when set, e.g. "::1" or "host.docker.internal".
+	Host string
+
+	dialer    net.Dialer
+	mu        sync.Mutex
+	preferred string
+}
+
+// DialContext dials addr, trying every loopback address for localhost.
+// Other hosts are dialled as given.
+func (d *LoopbackDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || !strings.EqualFold(host, "localhost") {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	if d.Host != "" {
+		return d.dialer.DialContext(ctx, network, net.JoinHostPort(d.Host, port))
+	}
+
+	var errs []error
+	for _, candidate := range d.candidates() {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(candidate, port))
+		if err == nil {
+			d.mu.Lock()
+			d.preferred = candidate
+			d.mu.Unlock()
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Probe checks that something listens on port and returns the address that
+// answered, e.g. "127.0.0.1:3000" or "[::1]:3000".
+func (d *LoopbackDialer) Probe(ctx context.Context, port string) (string, error) {
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort("localhost", port))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return conn.RemoteAddr().String(), nil
+}
+
+// candidates returns the loopback addresses with the last one that answered
+// first.
+func (d *LoopbackDialer) candidates() []string {
+	d.mu.Lock()
+	preferred := d.preferred
+	d.mu.Unlock()
+	if preferred == "" || preferred == LoopbackHosts[0] {
+		return LoopbackHosts
+	}
+	hosts := []string{preferred}
+	for _, host := range LoopbackHosts {
+		if host != preferred {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,29 @@
+package httputil
+
+import (
+	"path"
+	"strings"
+)
+
+// FunnelRequestHeader is set by tailscaled on requests that arrived over
+// Funnel; it strips any client-supplied value.
+const FunnelRequestHeader = "Tailscale-Funnel-Request"
+
+// UnderPath reports whether requestPath is prefix or below it. An empty
+// prefix or "/" matches every path. requestPath is cleaned first, since the
+// target may resolve "//admin" or "/x/../admin" to "/admin" and a check on
+// the raw path would let them past.
+func UnderPath(requestPath, prefix string) bool {
+	requestPath = CleanPath(requestPath)
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+}
+
+// CleanPath returns the shortest equivalent of a request path, rooted at
+// "/", with repeated slashes and "." and ".." elements resolved.
+func CleanPath(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return path.Clean(p)
+}
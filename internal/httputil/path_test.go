@@ -0,0 +1,24 @@
+package httputil
+
+import "testing"
+
+func TestUnderPath(t *testing.T) {
+	for _, tc := range []struct {
+		path, prefix string
+		want         bool
+	}{
+		{"/api", "/api", true},
+		{"/api/users", "/api/", true},
+		{"/apiary", "/api", false},
+		{"/anything", "/", true},
+		{"/anything", "", true},
+		{"//api/users", "/api", true},
+		{"/x/../api", "/api", true},
+		{"/api/../admin", "/api", false},
+		{"", "/api", false},
+	} {
+		if got := UnderPath(tc.path, tc.prefix); got != tc.want {
+			t.Fatalf("UnderPath(%q, %q): expected %t, got %t", tc.path, tc.prefix, tc.want, got)
+		}
+	}
+}
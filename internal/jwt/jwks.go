@@ -0,0 +1,276 @@
+// internal/jwt/jwks.go
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// keySetTTL is how long fetched keys are used before refetching.
+	keySetTTL = time.Hour
+	// minRefetchInterval limits refetches triggered by unknown key IDs, so
+	// tokens with made-up kids can't hammer the JWKS endpoint.
+	minRefetchInterval = time.Minute
+)
+
+// KeySet verifies JWT signatures against keys fetched from a JWKS URL. It is
+// safe for concurrent use.
+type KeySet struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewKeySet returns a key set that fetches keys from url on first use.
+func NewKeySet(url string) *KeySet {
+	return &KeySet{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Verify checks token's signature. Tokens without a kid are checked against
+// every key.
+func (k *KeySet) Verify(ctx context.Context, token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("not a JWT: expected three dot-separated parts")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("invalid JWT header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil {
+		return fmt.Errorf("invalid JWT signature encoding: %w", err)
+	}
+
+	keys, err := k.lookup(ctx, header.Kid)
+	if err != nil {
+		return err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	for _, key := range keys {
+		if err = verifySignature(header.Alg, key, signed, signature); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// lookup returns the keys to try for kid, fetching the set when it is stale
+// or doesn't know kid yet.
+func (k *KeySet) lookup(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	stale := time.Since(k.fetched) > keySetTTL
+	_, known := k.keys[kid]
+	if stale || (kid != "" && !known && time.Since(k.fetched) > minRefetchInterval) {
+		if err := k.fetch(ctx); err != nil && k.keys == nil {
+			return nil, err
+		}
+	}
+
+	if kid != "" {
+		key, ok := k.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("no key with kid %q in %s", kid, k.url)
+		}
+		return []crypto.PublicKey{key}, nil
+	}
+	keys := make([]crypto.PublicKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no usable keys in %s", k.url)
+	}
+	return keys, nil
+}
+
+// fetch replaces the keys with the current contents of the JWKS URL. The
+// caller holds mu.
+func (k *KeySet) fetch(ctx context.Context) error {
+	k.fetched = time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid JWKS URL: %w", err)
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: %s returned %s", k.url, resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("invalid JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, raw := range set.Keys {
+		// Keys of unsupported types are skipped rather than failing the
+		// whole set.
+		if key, err := raw.publicKey(); err == nil {
+			keys[raw.Kid] = key
+		}
+	}
+	k.keys = keys
+	return nil
+}
+
+// jwk is a JSON Web Key as published in a JWKS.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j jwk) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		copy(point[1+size-len(x):1+size], x)
+		copy(point[1+2*size-len(y):], y)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg[min(len(alg), 2):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+
+	switch {
+	case alg == "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(pub, signed, signature) {
+			return errors.New("signature does not match")
+		}
+		return nil
+	case hash == 0:
+		return fmt.Errorf("alg %q can't be verified with a JWKS", alg)
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not an RSA key for %s", alg)
+		}
+		if alg[:2] == "PS" {
+			err := rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+			if err != nil {
+				return errors.New("signature does not match")
+			}
+			return nil
+		}
+		if rsa.VerifyPKCS1v15(pub, hash, digest, signature) != nil {
+			return errors.New("signature does not match")
+		}
+		return nil
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not an EC key for %s", alg)
+		}
+		size := len(signature) / 2
+		if len(signature) == 0 || len(signature)%2 != 0 {
+			return errors.New("invalid ECDSA signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("signature does not match")
+		}
+		return nil
+	default:
+		return fmt.Errorf("alg %q can't be verified with a JWKS", alg)
+	}
+}
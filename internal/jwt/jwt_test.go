@@ -0,0 +1,198 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signedToken builds a compact JWT, signing it with sign.
+func signedToken(t *testing.T, header, claims map[string]any, sign func([]byte) []byte) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := b64(h) + "." + b64(c)
+	return signed + "." + b64(sign([]byte(signed)))
+}
+
+func TestBearerToken(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{"Bearer abc.def.ghi", "abc.def.ghi", true},
+		{"bearer  abc.def.ghi ", "abc.def.ghi", true},
+		{"Basic dXNlcjpwYXNz", "", false},
+		{"Bearer", "", false},
+		{"", "", false},
+	} {
+		got, ok := BearerToken(tc.header)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("BearerToken(%q) = %q, %t; want %q, %t", tc.header, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	token := signedToken(t,
+		map[string]any{"alg": "HS256", "typ": "JWT"},
+		map[string]any{"sub": "user-1", "exp": 1700000060, "id": 12345678901234567},
+		func([]byte) []byte { return []byte("sig") },
+	)
+
+	info, err := Decode(token, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Header["alg"] != "HS256" || info.Claims["sub"] != "user-1" {
+		t.Fatalf("unexpected header/claims: %v %v", info.Header, info.Claims)
+	}
+	if info.Claims["id"].(json.Number).String() != "12345678901234567" {
+		t.Fatalf("expected large numbers to keep their exact value, got %v", info.Claims["id"])
+	}
+	if info.ExpiresAt == nil || !info.ExpiresAt.Equal(now.Add(time.Minute)) || info.Expired {
+		t.Fatalf("expected unexpired token expiring in a minute, got %v expired=%t", info.ExpiresAt, info.Expired)
+	}
+	if info.Signature != model.JWTUnverified {
+		t.Fatalf("expected unverified signature, got %q", info.Signature)
+	}
+
+	info, err = Decode(token, now.Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Expired {
+		t.Fatal("expected token to be expired")
+	}
+
+	for _, bad := range []string{"opaque-token", "a.b", "!!!.e30.sig", "e30.!!!.sig"} {
+		if _, err := Decode(bad, now); err == nil {
+			t.Fatalf("expected %q to fail to decode", bad)
+		}
+	}
+}
+
+func TestKeySetVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPoint, err := ecKey.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches atomic.Int32
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecPoint[1:33]), "y": b64(ecPoint[33:])},
+			{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPub)},
+			{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		}})
+	}))
+	defer jwks.Close()
+
+	claims := map[string]any{"sub": "user-1"}
+	signRSA := func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	signEC := func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig
+	}
+	signEd := func(signed []byte) []byte { return ed25519.Sign(edKey, signed) }
+
+	keys := NewKeySet(jwks.URL)
+	ctx := context.Background()
+	for name, token := range map[string]string{
+		"RS256":  signedToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claims, signRSA),
+		"ES256":  signedToken(t, map[string]any{"alg": "ES256", "kid": "ec"}, claims, signEC),
+		"EdDSA":  signedToken(t, map[string]any{"alg": "EdDSA", "kid": "ed"}, claims, signEd),
+		"no kid": signedToken(t, map[string]any{"alg": "RS256"}, claims, signRSA),
+	} {
+		if err := keys.Verify(ctx, token); err != nil {
+			t.Fatalf("%s: expected valid signature, got %v", name, err)
+		}
+	}
+
+	tampered := signedToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, claims, signRSA)
+	parts := strings.Split(tampered, ".")
+	parts[1] = b64([]byte(`{"sub":"admin"}`))
+	if err := keys.Verify(ctx, strings.Join(parts, ".")); err == nil {
+		t.Fatal("expected tampered claims to fail verification")
+	}
+
+	if err := keys.Verify(ctx, signedToken(t, map[string]any{"alg": "HS256", "kid": "hmac"}, claims, signRSA)); err == nil {
+		t.Fatal("expected symmetric keys not to be usable")
+	}
+
+	if err := keys.Verify(ctx, signedToken(t, map[string]any{"alg": "RS256", "kid": "unknown"}, claims, signRSA)); err == nil {
+		t.Fatal("expected unknown kid to fail verification")
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("expected the key set to be fetched once, got %d fetches", got)
+	}
+}
+
+func TestKeySetVerifyFetchError(t *testing.T) {
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer jwks.Close()
+
+	token := signedToken(t, map[string]any{"alg": "RS256", "kid": "rsa"}, map[string]any{}, func([]byte) []byte { return []byte("sig") })
+	err := NewKeySet(jwks.URL).Verify(context.Background(), token)
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("expected fetch error, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+// internal/logging/console.go
+package logging
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// ANSI colors used by the console request printer.
+const (
+	ansiReset   = "\033[0m"
+	ansiDim     = "\033[2m"
+	ansiRed     = "\033[31m"
+	ansiGreen   = "\033[32m"
+	ansiYellow  = "\033[33m"
+	ansiBlue    = "\033[34m"
+	ansiMagenta = "\033[35m"
+	ansiCyan    = "\033[36m"
+)
+
+// consoleMethodWidth and consolePathWidth align the request line's columns;
+// longer values push the rest of the line out rather than being cut.
+const (
+	consoleMethodWidth = 7
+	consolePathWidth   = 40
+)
+
+// ConsoleRequests prints each completed request and its response as one
+// block, so concurrent requests don't interleave in --no-tui output.
+type ConsoleRequests struct {
+	mu    sync.Mutex
+	w     io.Writer
+	color bool
+}
+
+// NewConsoleRequests returns a printer writing to w, with ANSI colors when
+// color is set.
+func NewConsoleRequests(w io.Writer, color bool) *ConsoleRequests {
+	return &ConsoleRequests{w: w, color: color}
+}
+
+// Request prints entry. It has the signature of a proxy listener.
+func (c *ConsoleRequests) Request(entry model.RequestLog) {
+	block := c.Format(entry)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _ = io.WriteString(c.w, block)
+}
+
+// Format renders entry as a request line followed by an indented response
+// line, both tagged with the request ID.
+func (c *ConsoleRequests) Format(entry model.RequestLog) string {
+	var b strings.Builder
+
+	method := fmt.Sprintf("%-*s", consoleMethodWidth, entry.Method)
+	path := fmt.Sprintf("%-*s", consolePathWidth, entry.URL)
+	fmt.Fprintf(&b, "%s %s %s %s",
+		entry.Timestamp.Format("15:04:05.000"),
+		c.paint(ansiDim, "["+entry.ID+"]"),
+		c.paint(methodColor(entry.Method), method),
+		path,
+	)
+	if entry.RemoteAddr != "" {
+		b.WriteString(" " + c.paint(ansiDim, "from "+entry.RemoteAddr))
+	}
+	b.WriteString("\n")
+
+	indent := strings.Repeat(" ", len("15:04:05.000 ["+entry.ID+"]"))
+	status := "no response"
+	if entry.StatusCode > 0 {
+		status = fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode))
+	}
+	fmt.Fprintf(&b, "%s └─ %s %s %s",
+		indent,
+		c.paint(statusColor(entry.StatusCode), status),
+		c.paint(ansiDim, "·"),
+		entry.Duration.Round(time.Millisecond),
+	)
+	fmt.Fprintf(&b, " %s %s", c.paint(ansiDim, "·"), formatSize(entry.Response.Size))
+	if entry.DuplicateOf != "" {
+		b.WriteString(" " + c.paint(ansiYellow, "duplicate of "+entry.DuplicateOf))
+	}
+	if entry.ReplayOf != "" {
+		b.WriteString(" " + c.paint(ansiCyan, "replay of "+entry.ReplayOf))
+	}
+	b.WriteString("\n")
+
+	if oauth := entry.OAuth; oauth != nil {
+		fmt.Fprintf(&b, "%s    %s", indent, c.paint(ansiMagenta, "oauth"))
+		if oauth.Code != "" {
+			fmt.Fprintf(&b, " code=%s", oauth.Code)
+		}
+		if oauth.State != "" {
+			fmt.Fprintf(&b, " state=%s", oauth.State)
+		}
+		if oauth.Error != "" {
+			b.WriteString(" " + c.paint(ansiRed, "error="+oauth.Error))
+		}
+		if token := oauth.Token; token != nil {
+			if token.Error != "" {
+				b.WriteString(" " + c.paint(ansiRed, "token exchange failed: "+token.Error))
+			} else {
+				b.WriteString(" " + c.paint(statusColor(token.StatusCode), fmt.Sprintf("token %d", token.StatusCode)))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func (c *ConsoleRequests) paint(color, text string) string {
+	if !c.color || color == "" {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+func methodColor(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return ansiBlue
+	case http.MethodPost:
+		return ansiGreen
+	case http.MethodPut, http.MethodPatch:
+		return ansiYellow
+	case http.MethodDelete:
+		return ansiRed
+	default:
+		return ansiMagenta
+	}
+}
+
+func statusColor(code int) string {
+	switch {
+	case code >= 500 || code == 0:
+		return ansiRed
+	case code >= 400:
+		return ansiYellow
+	case code >= 300:
+		return ansiCyan
+	default:
+		return ansiGreen
+	}
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
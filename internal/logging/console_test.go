@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestConsoleRequestsFormat(t *testing.T) {
+	entry := model.RequestLog{
+		ID:         "req_1_7",
+		Timestamp:  time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC),
+		Method:     "POST",
+		URL:        "/webhooks/github",
+		RemoteAddr: "100.64.0.2:5000",
+		StatusCode: 404,
+		Duration:   12 * time.Millisecond,
+		Response:   model.ResponseLog{StatusCode: 404, Size: 2048},
+		ReplayOf:   "req_1_3",
+	}
+
+	plain := NewConsoleRequests(nil, false).Format(entry)
+	lines := strings.Split(strings.TrimSuffix(plain, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected request and response lines, got %q", plain)
+	}
+	if !strings.HasPrefix(lines[0], "09:30:00.000 [req_1_7] POST    /webhooks/github ") || !strings.HasSuffix(lines[0], "from 100.64.0.2:5000") {
+		t.Fatalf("unexpected request line %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "└─ 404 Not Found · 12ms · 2.0 KB replay of req_1_3") {
+		t.Fatalf("unexpected response line %q", lines[1])
+	}
+	if strings.Contains(plain, "\033[") {
+		t.Fatalf("expected no color codes, got %q", plain)
+	}
+
+	colored := NewConsoleRequests(nil, true).Format(entry)
+	if !strings.Contains(colored, ansiGreen+"POST   "+ansiReset) || !strings.Contains(colored, ansiYellow+"404 Not Found"+ansiReset) {
+		t.Fatalf("expected method and status colors, got %q", colored)
+	}
+}
+
+func TestConsoleRequestsFormatOAuth(t *testing.T) {
+	entry := model.RequestLog{
+		ID:         "req_1_1",
+		Method:     "GET",
+		URL:        "/callback?code=abc&state=xyz",
+		StatusCode: 200,
+		OAuth: &model.OAuthCallback{
+			Code:  "abc",
+			State: "xyz",
+			Token: &model.OAuthToken{StatusCode: 400},
+		},
+	}
+
+	plain := NewConsoleRequests(nil, false).Format(entry)
+	if !strings.Contains(plain, "oauth code=abc state=xyz token 400\n") {
+		t.Fatalf("expected oauth line, got %q", plain)
+	}
+}
+
+func TestConsoleRequestsWritesWholeBlocks(t *testing.T) {
+	var buf bytes.Buffer
+	printer := NewConsoleRequests(&buf, false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			printer.Request(model.RequestLog{ID: "req", Method: "GET", URL: "/", StatusCode: 200})
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i := 0; i < len(lines); i += 2 {
+		if !strings.Contains(lines[i], "GET") || !strings.Contains(lines[i+1], "└─ 200 OK") {
+			t.Fatalf("expected request/response pairs, got %q then %q", lines[i], lines[i+1])
+		}
+	}
+}
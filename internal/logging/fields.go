@@ -111,3 +111,7 @@ func Version(version string) zap.Field {
 func LocalPort(port int) zap.Field {
 	return zap.Int("local_port", port)
 }
+
+func RequestID(id string) zap.Field {
+	return zap.String("request_id", id)
+}
@@ -17,6 +17,9 @@ type Config struct {
 	JSON      bool
 	LogFile   string
 	TUIWriter io.Writer // Optional TUI writer for log redirection
+	// ReserveStdout keeps all log output on stderr so stdout can carry
+	// machine-readable events.
+	ReserveStdout bool
 }
 
 // SetupLogger creates and configures a zap logger based on the provided configuration
@@ -41,7 +44,11 @@ func SetupLogger(config Config) (*zap.Logger, error) {
 
 	// Configure output paths
 	if config.LogFile != "" {
-		zapConfig.OutputPaths = []string{config.LogFile, "stdout"}
+		console := "stdout"
+		if config.ReserveStdout {
+			console = "stderr"
+		}
+		zapConfig.OutputPaths = []string{config.LogFile, console}
 	}
 
 	logger, err := zapConfig.Build()
@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/auth"
+	"github.com/jaxxstorm/portal/internal/httputil"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// Middleware names accepted in a Spec.
+const (
+	NameRequestID  = "request-id"
+	NameRecovery   = "recovery"
+	NameCORS       = "cors"
+	NameAccessLog  = "access-log"
+	NameTimeout    = "timeout"
+	NameBasicAuth  = "basic-auth"
+	NameBearerAuth = "bearer-auth"
+	NameRateLimit  = "rate-limit"
+	NameAuth       = "auth"
+)
+
+// Names lists the middlewares a Spec can name.
+var Names = []string{NameRequestID, NameRecovery, NameCORS, NameAccessLog, NameTimeout, NameBasicAuth, NameBearerAuth, NameRateLimit, NameAuth}
+
+// Spec is one middleware in a configured chain. Only the settings for its
+// Name are used.
+type Spec struct {
+	Name string `mapstructure:"name"`
+	// Paths limits the middleware to requests under these path prefixes.
+	// Empty applies it to every request.
+	Paths []string `mapstructure:"paths"`
+
+	// cors
+	Origins     []string `mapstructure:"origins"`
+	Methods     []string `mapstructure:"methods"`
+	Credentials bool     `mapstructure:"credentials"`
+	// timeout
+	Timeout time.Duration `mapstructure:"timeout"`
+	// basic-auth
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// bearer-auth
+	Token string `mapstructure:"token"`
+	// rate-limit: requests per second per client, with bursts of Burst.
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+	// auth: Provider is one of auth.Providers, each with its own settings.
+	Provider     string            `mapstructure:"provider"`
+	Tokens       map[string]string `mapstructure:"tokens"`
+	Issuer       string            `mapstructure:"issuer"`
+	Audience     string            `mapstructure:"audience"`
+	JWKSURL      string            `mapstructure:"jwks-url"`
+	ClientID     string            `mapstructure:"client-id"`
+	ClientSecret string            `mapstructure:"client-secret"`
+	Users        []string          `mapstructure:"users"`
+}
+
+// Validate checks that the spec names a known middleware and has the
+// settings it needs.
+func (s Spec) Validate() error {
+	for _, path := range s.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("middleware %s: path %q must start with /", s.Name, path)
+		}
+	}
+	switch s.Name {
+	case NameRequestID, NameRecovery, NameAccessLog:
+	case NameCORS:
+		if len(s.Origins) == 0 {
+			return fmt.Errorf("middleware %s: origins are required", s.Name)
+		}
+		if s.Credentials && slices.Contains(s.Origins, "*") {
+			return fmt.Errorf("middleware %s: credentials cannot be allowed for any origin", s.Name)
+		}
+	case NameTimeout:
+		if s.Timeout <= 0 {
+			return fmt.Errorf("middleware %s: timeout must be positive", s.Name)
+		}
+	case NameBasicAuth:
+		if s.Username == "" || s.Password == "" {
+			return fmt.Errorf("middleware %s: username and password are required", s.Name)
+		}
+	case NameBearerAuth:
+		if s.Token == "" {
+			return fmt.Errorf("middleware %s: token is required", s.Name)
+		}
+	case NameRateLimit:
+		if s.Rate <= 0 {
+			return fmt.Errorf("middleware %s: rate must be positive", s.Name)
+		}
+	case NameAuth:
+		switch s.Provider {
+		case auth.ProviderToken:
+			if len(s.Tokens) == 0 {
+				return fmt.Errorf("middleware %s: tokens are required for provider %s", s.Name, s.Provider)
+			}
+			for user, token := range s.Tokens {
+				if token == "" {
+					return fmt.Errorf("middleware %s: token for %s is empty", s.Name, user)
+				}
+			}
+		case auth.ProviderOIDC:
+			if s.Issuer == "" {
+				return fmt.Errorf("middleware %s: issuer is required for provider %s", s.Name, s.Provider)
+			}
+		case auth.ProviderGitHub:
+			if s.ClientID == "" || s.ClientSecret == "" {
+				return fmt.Errorf("middleware %s: client-id and client-secret are required for provider %s", s.Name, s.Provider)
+			}
+		default:
+			return fmt.Errorf("middleware %s: provider must be one of %s", s.Name, strings.Join(auth.Providers, ", "))
+		}
+	default:
+		return fmt.Errorf("unknown middleware %q: must be one of %s", s.Name, strings.Join(Names, ", "))
+	}
+	return nil
+}
+
+// ChainOptions supplies what some middlewares need from their host.
+type ChainOptions struct {
+	// AccessLog receives an entry per request for access-log.
+	AccessLog func(model.RequestLog)
+	// ClientKey tells clients apart for rate-limit. Defaults to the remote
+	// IP.
+	ClientKey func(*http.Request) string
+}
+
+// Chain runs configured middlewares in order around a handler.
+type Chain struct {
+	links []link
+}
+
+type link struct {
+	paths []string
+	wrap  func(http.Handler) http.Handler
+}
+
+// NewChain builds the middlewares described by specs, first spec outermost.
+// Middleware state such as rate limits is shared by every handler the chain
+// wraps.
+func NewChain(specs []Spec, opts ChainOptions) (*Chain, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	chain := &Chain{}
+	for _, spec := range specs {
+		if err := spec.Validate(); err != nil {
+			return nil, err
+		}
+		var wrap func(http.Handler) http.Handler
+		switch spec.Name {
+		case NameRequestID:
+			wrap = RequestID()
+		case NameRecovery:
+			wrap = Recovery()
+		case NameCORS:
+			wrap = CORSWithConfig(CORSConfig{Origins: spec.Origins, Methods: spec.Methods, AllowCredentials: spec.Credentials})
+		case NameAccessLog:
+			wrap = AccessLog(opts.AccessLog)
+		case NameTimeout:
+			wrap = Timeout(spec.Timeout)
+		case NameBasicAuth:
+			wrap = BasicAuth(spec.Username, spec.Password)
+		case NameBearerAuth:
+			wrap = BearerAuth(spec.Token)
+		case NameRateLimit:
+			wrap = RateLimit(spec.Rate, spec.Burst, opts.ClientKey)
+		case NameAuth:
+			wrap = auth.Middleware(spec.authProvider())
+			if len(spec.Paths) > 0 {
+				// The provider's own endpoints, such as its login callback,
+				// are served whatever paths it protects.
+				spec.Paths = append(slices.Clone(spec.Paths), auth.PathPrefix)
+			}
+		}
+		chain.links = append(chain.links, link{paths: spec.Paths, wrap: wrap})
+	}
+	return chain, nil
+}
+
+// authProvider builds the auth provider a validated spec describes.
+func (s Spec) authProvider() auth.Provider {
+	switch s.Provider {
+	case auth.ProviderOIDC:
+		return auth.NewOIDC(auth.OIDCConfig{Issuer: s.Issuer, Audience: s.Audience, JWKSURL: s.JWKSURL})
+	case auth.ProviderGitHub:
+		return auth.NewGitHub(auth.GitHubConfig{ClientID: s.ClientID, ClientSecret: s.ClientSecret, Users: s.Users})
+	default:
+		return auth.NewTokens(s.Tokens)
+	}
+}
+
+// Then wraps h in the chain. A nil chain returns h unchanged.
+func (c *Chain) Then(h http.Handler) http.Handler {
+	if c == nil {
+		return h
+	}
+	for i := len(c.links) - 1; i >= 0; i-- {
+		h = c.links[i].then(h)
+	}
+	return h
+}
+
+func (l link) then(next http.Handler) http.Handler {
+	wrapped := l.wrap(next)
+	if len(l.paths) == 0 {
+		return wrapped
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range l.paths {
+			if httputil.UnderPath(r.URL.Path, prefix) {
+				wrapped.ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChainRunsInOrderOnMatchingPaths(t *testing.T) {
+	var order []string
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	chain := &Chain{links: []link{
+		{wrap: tag("first")},
+		{paths: []string{"/api"}, wrap: tag("api")},
+		{wrap: tag("last")},
+	}}
+	handler := chain.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"/api/users", "first,api,last"},
+		{"/api", "first,api,last"},
+		{"/apiary", "first,last"},
+		{"/", "first,last"},
+		// Paths the target may resolve into /api don't skip it.
+		{"//api/users", "first,api,last"},
+		{"/x/../api", "first,api,last"},
+		{"/static/..//api/./users", "first,api,last"},
+	} {
+		order = nil
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if got := strings.Join(order, ","); got != tc.want {
+			t.Fatalf("%s: expected %s, got %s", tc.path, tc.want, got)
+		}
+	}
+}
+
+func TestNewChainBuildsConfiguredMiddlewares(t *testing.T) {
+	chain, err := NewChain([]Spec{
+		{Name: NameRequestID},
+		{Name: NameBasicAuth, Paths: []string{"/admin"}, Username: "dev", Password: "s3cret"},
+		{Name: NameRateLimit, Rate: 1, Burst: 1},
+	}, ChainOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := chain.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(path, remote string, auth bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remote
+		if auth {
+			req.SetBasicAuth("dev", "s3cret")
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve("/admin", "100.64.0.1:1000", false); rr.Code != http.StatusUnauthorized || rr.Header().Get("X-Request-ID") == "" {
+		t.Fatalf("expected 401 with a request ID, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := serve("/admin", "100.64.0.2:1000", true); rr.Code != http.StatusOK {
+		t.Fatalf("expected authorized request to pass, got %d", rr.Code)
+	}
+	if rr := serve("/admin", "100.64.0.2:1001", true); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected the second request from a client to be limited, got %d", rr.Code)
+	}
+	if rr := serve("/", "100.64.0.3:1000", false); rr.Code != http.StatusOK {
+		t.Fatalf("expected other clients and paths to pass, got %d", rr.Code)
+	}
+}
+
+func TestSpecValidate(t *testing.T) {
+	for _, spec := range []Spec{
+		{Name: "gzip"},
+		{Name: NameTimeout},
+		{Name: NameBasicAuth, Username: "dev"},
+		{Name: NameRateLimit, Rate: 0},
+		{Name: NameCORS},
+		{Name: NameCORS, Origins: []string{"*"}, Credentials: true},
+		{Name: NameRequestID, Paths: []string{"api"}},
+		{Name: NameAuth},
+		{Name: NameAuth, Provider: "token"},
+		{Name: NameAuth, Provider: "oidc"},
+		{Name: NameAuth, Provider: "github", ClientID: "Iv1.abc"},
+	} {
+		if err := spec.Validate(); err == nil {
+			t.Fatalf("expected %+v to be invalid", spec)
+		}
+	}
+	if err := (Spec{Name: NameTimeout, Timeout: time.Second}).Validate(); err != nil {
+		t.Fatalf("expected valid timeout spec, got %v", err)
+	}
+}
+
+func TestAuthServesItsEndpointsOutsideItsPaths(t *testing.T) {
+	chain, err := NewChain([]Spec{
+		{Name: NameAuth, Paths: []string{"/admin"}, Provider: "github", ClientID: "Iv1.abc", ClientSecret: "s3cret"},
+	}, ChainOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := chain.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	for path, want := range map[string]int{
+		"/":                      http.StatusTeapot,
+		"/admin":                 http.StatusUnauthorized,
+		"/__portal/auth/logout":  http.StatusFound,
+		"/__portal/auth/unknown": http.StatusNotFound,
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultCORSMethods are allowed when CORSConfig.Methods is empty.
+var DefaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// CORSConfig describes which cross-origin requests are allowed
+type CORSConfig struct {
+	// Origins lists allowed origins; "*" allows any origin.
+	Origins []string
+	// Methods lists allowed methods (default: DefaultCORSMethods).
+	Methods []string
+	// AllowCredentials allows cookies and Authorization headers to be sent.
+	AllowCredentials bool
+}
+
+// Enabled reports whether any origin is allowed
+func (c CORSConfig) Enabled() bool {
+	return len(c.Origins) > 0
+}
+
+// AllowsOrigin reports whether origin may access responses
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	return slices.Contains(c.Origins, "*") || slices.Contains(c.Origins, origin)
+}
+
+// IsPreflight reports whether r is a CORS preflight request
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// Apply sets the CORS response headers for a request from origin. Existing
+// CORS headers, such as those sent by an upstream, are replaced.
+func (c CORSConfig) Apply(h http.Header, origin string) {
+	if !c.AllowsOrigin(origin) {
+		return
+	}
+
+	// The origin is echoed rather than "*" so that credentialed requests
+	// are accepted by browsers.
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Add("Vary", "Origin")
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	} else {
+		h.Del("Access-Control-Allow-Credentials")
+	}
+}
+
+// Preflight answers a preflight request with the allowed methods and the
+// requested headers. Disallowed origins get a 403.
+func (c CORSConfig) Preflight(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if !c.AllowsOrigin(origin) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	methods := c.Methods
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods
+	}
+
+	c.Apply(w.Header(), origin)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		w.Header().Set("Access-Control-Allow-Headers", requested)
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+	}
+	w.Header().Set("Access-Control-Max-Age", strconv.Itoa(600))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CORS returns middleware that adds CORS headers
+func CORS(origins []string) func(http.Handler) http.Handler {
+	return CORSWithConfig(CORSConfig{Origins: origins})
+}
+
+// CORSWithConfig returns middleware that answers preflight requests and adds
+// CORS headers to every other response
+func CORSWithConfig(cfg CORSConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsPreflight(r) {
+				cfg.Preflight(w, r)
+				return
+			}
+			cfg.Apply(w.Header(), r.Header.Get("Origin"))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
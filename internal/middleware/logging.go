@@ -14,12 +14,16 @@ type LoggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
 	size       int64
-	headers    map[string]string
+	headers    model.Headers
 }
 
-// WriteHeader captures the status code
+// WriteHeader captures the status code. Informational responses are passed
+// on without being taken for the final status.
 func (lrw *LoggingResponseWriter) WriteHeader(code int) {
-	lrw.statusCode = code
+	if lrw.statusCode == 0 && (code < 100 || code >= 200 || code == http.StatusSwitchingProtocols) {
+		lrw.statusCode = code
+		lrw.captureHeaders()
+	}
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
@@ -27,23 +31,31 @@ func (lrw *LoggingResponseWriter) WriteHeader(code int) {
 func (lrw *LoggingResponseWriter) Write(b []byte) (int, error) {
 	if lrw.statusCode == 0 {
 		lrw.statusCode = 200
+		lrw.captureHeaders()
 	}
 	size, err := lrw.ResponseWriter.Write(b)
 	lrw.size += int64(size)
 	return size, err
 }
 
+// Flush sends what has been written so far to the client.
+func (lrw *LoggingResponseWriter) Flush() {
+	if lrw.statusCode == 0 {
+		lrw.statusCode = 200
+		lrw.captureHeaders()
+	}
+	_ = http.NewResponseController(lrw.ResponseWriter).Flush()
+}
+
 // Header returns the response headers
 func (lrw *LoggingResponseWriter) Header() http.Header {
 	return lrw.ResponseWriter.Header()
 }
 
-// captureHeaders captures response headers for logging
+// captureHeaders captures response headers for logging, when the status is
+// written so trailers set later aren't mistaken for headers.
 func (lrw *LoggingResponseWriter) captureHeaders() {
-	lrw.headers = make(map[string]string)
-	for k, v := range lrw.ResponseWriter.Header() {
-		lrw.headers[k] = strings.Join(v, ", ")
-	}
+	lrw.headers = model.HeadersFrom(lrw.ResponseWriter.Header())
 }
 
 // AccessLog returns middleware that logs HTTP requests and responses
@@ -57,7 +69,6 @@ func AccessLog(callback func(model.RequestLog)) func(http.Handler) http.Handler
 				ResponseWriter: w,
 				statusCode:     0,
 				size:           0,
-				headers:        make(map[string]string),
 			}
 
 			// Read request body for logging (if not too large)
@@ -70,16 +81,14 @@ func AccessLog(callback func(model.RequestLog)) func(http.Handler) http.Handler
 			}
 
 			// Capture request headers
-			reqHeaders := make(map[string]string)
-			for k, v := range r.Header {
-				reqHeaders[k] = strings.Join(v, ", ")
-			}
+			reqHeaders := model.HeadersFrom(r.Header)
 
 			// Serve the request
 			next.ServeHTTP(lrw, r)
 
-			// Capture response headers after serving
-			lrw.captureHeaders()
+			if lrw.statusCode == 0 {
+				lrw.captureHeaders()
+			}
 
 			duration := time.Since(start)
 
@@ -127,38 +136,6 @@ func randomString(length int) string {
 	return string(b)
 }
 
-// CORS returns middleware that adds CORS headers
-func CORS(origins []string) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			origin := r.Header.Get("Origin")
-
-			// Check if origin is allowed
-			allowed := false
-			for _, allowedOrigin := range origins {
-				if allowedOrigin == "*" || allowedOrigin == origin {
-					allowed = true
-					break
-				}
-			}
-
-			if allowed {
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-			}
-
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-
-			next.ServeHTTP(w, r)
-		})
-	}
-}
-
 // RequestID returns middleware that adds a unique request ID to the request context
 func RequestID() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
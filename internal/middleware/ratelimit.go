@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// limiterIdle is how long a client's limiter is kept after its last request.
+const limiterIdle = 10 * time.Minute
+
+// RateLimit returns middleware that allows each client perSecond requests
+// per second with bursts of up to burst, answering the rest with 429.
+// Clients are told apart by key, or by remote IP when key is nil.
+func RateLimit(perSecond float64, burst int, key func(*http.Request) string) func(http.Handler) http.Handler {
+	if key == nil {
+		key = remoteHost
+	}
+	limiters := &clientLimiters{
+		limit:   rate.Limit(perSecond),
+		burst:   max(burst, 1),
+		clients: make(map[string]*clientLimiter),
+	}
+	retryAfter := strconv.Itoa(int(math.Ceil(1 / perSecond)))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiters.allow(key(r), time.Now()) {
+				w.Header().Set("Retry-After", retryAfter)
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type clientLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+// clientLimiters holds a token bucket per client, dropping idle ones.
+type clientLimiters struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+func (c *clientLimiters) allow(client string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) > limiterIdle {
+		for key, limiter := range c.clients {
+			if now.Sub(limiter.lastSeen) > limiterIdle {
+				delete(c.clients, key)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	limiter, ok := c.clients[client]
+	if !ok {
+		limiter = &clientLimiter{Limiter: rate.NewLimiter(c.limit, c.burst)}
+		c.clients[client] = limiter
+	}
+	limiter.lastSeen = now
+	return limiter.AllowN(now, 1)
+}
+
+func remoteHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
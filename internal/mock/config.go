@@ -0,0 +1,155 @@
+// internal/mock/config.go
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Latency distributions supported by profiles.
+const (
+	DistributionFixed   = "fixed"
+	DistributionUniform = "uniform"
+	DistributionNormal  = "normal"
+)
+
+// Config is the mock behaviour file passed with --mock-config.
+type Config struct {
+	Profiles  []Profile  `yaml:"profiles"`
+	Rules     []Rule     `yaml:"rules"`
+	Scenarios []Scenario `yaml:"scenarios"`
+	Resources []Resource `yaml:"resources"`
+}
+
+// Profile shapes the latency and failure rate of mock responses for requests
+// whose path matches Path. The first matching profile wins.
+type Profile struct {
+	// Path is a path.Match pattern; a trailing "/**" matches any subpath.
+	Path string `yaml:"path"`
+	// Methods restricts the profile to these methods; empty matches all.
+	Methods []string `yaml:"methods"`
+	Latency Latency  `yaml:"latency"`
+	// ErrorRate is the fraction of requests (0-1) answered with ErrorStatus.
+	ErrorRate   float64 `yaml:"error_rate"`
+	ErrorStatus int     `yaml:"error_status"`
+}
+
+// Latency describes the delay added before a mock response is written.
+type Latency struct {
+	Distribution string        `yaml:"distribution"`
+	Value        time.Duration `yaml:"value"`
+	Min          time.Duration `yaml:"min"`
+	Max          time.Duration `yaml:"max"`
+	Mean         time.Duration `yaml:"mean"`
+	StdDev       time.Duration `yaml:"stddev"`
+}
+
+// LoadConfig reads and validates a mock config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mock config %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse mock config %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid mock config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Validate checks profiles for missing or inconsistent values and fills in
+// defaults.
+func (c *Config) Validate() error {
+	for i := range c.Profiles {
+		p := &c.Profiles[i]
+		if strings.TrimSpace(p.Path) == "" {
+			return fmt.Errorf("profile %d: path is required", i)
+		}
+		if p.ErrorRate < 0 || p.ErrorRate > 1 {
+			return fmt.Errorf("profile %q: error_rate must be between 0 and 1", p.Path)
+		}
+		if p.ErrorStatus == 0 {
+			p.ErrorStatus = http.StatusInternalServerError
+		}
+		if p.ErrorStatus < 100 || p.ErrorStatus > 599 {
+			return fmt.Errorf("profile %q: error_status %d is not a valid HTTP status", p.Path, p.ErrorStatus)
+		}
+		for j, method := range p.Methods {
+			p.Methods[j] = strings.ToUpper(strings.TrimSpace(method))
+		}
+		if err := p.Latency.validate(); err != nil {
+			return fmt.Errorf("profile %q: %w", p.Path, err)
+		}
+	}
+
+	ruleIDs := make(map[string]bool, len(c.Rules))
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if err := r.normalize(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+		if r.ID == "" {
+			r.ID = fmt.Sprintf("rule_%d", i+1)
+		}
+		if ruleIDs[r.ID] {
+			return fmt.Errorf("rule %q is declared more than once", r.ID)
+		}
+		ruleIDs[r.ID] = true
+	}
+
+	scenarioIDs := make(map[string]bool, len(c.Scenarios))
+	for i := range c.Scenarios {
+		if err := c.Scenarios[i].normalize(i); err != nil {
+			return err
+		}
+		if scenarioIDs[c.Scenarios[i].ID] {
+			return fmt.Errorf("scenario %q is declared more than once", c.Scenarios[i].ID)
+		}
+		scenarioIDs[c.Scenarios[i].ID] = true
+	}
+
+	seen := make(map[string]bool, len(c.Resources))
+	for i := range c.Resources {
+		r := &c.Resources[i]
+		r.Path = strings.TrimSuffix(strings.TrimSpace(r.Path), "/")
+		if !strings.HasPrefix(r.Path, "/") {
+			return fmt.Errorf("resource %d: path must start with / (got %q)", i, r.Path)
+		}
+		if seen[r.Path] {
+			return fmt.Errorf("resource %q is declared more than once", r.Path)
+		}
+		seen[r.Path] = true
+	}
+	return nil
+}
+
+func (l *Latency) validate() error {
+	l.Distribution = strings.ToLower(strings.TrimSpace(l.Distribution))
+	switch l.Distribution {
+	case "":
+		if l.Value > 0 {
+			l.Distribution = DistributionFixed
+		}
+	case DistributionFixed:
+	case DistributionUniform:
+		if l.Max < l.Min {
+			return fmt.Errorf("uniform latency max must be >= min")
+		}
+	case DistributionNormal:
+		if l.StdDev < 0 {
+			return fmt.Errorf("normal latency stddev must not be negative")
+		}
+	default:
+		return fmt.Errorf("unknown latency distribution %q (use fixed, uniform or normal)", l.Distribution)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+// internal/mock/engine.go
+package mock
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcome is how a mock response should behave for a single request.
+type Outcome struct {
+	// Profile is the matching profile's path pattern, empty when none matched.
+	Profile string
+	Delay   time.Duration
+	// FailStatus is non-zero when the request should be answered with an
+	// injected error.
+	FailStatus int
+}
+
+// Engine applies mock profiles and stateful resources to incoming requests.
+type Engine struct {
+	profiles []Profile
+	store    *Store
+	playback *Recording
+
+	mu        sync.RWMutex
+	rules     []Rule
+	ruleSeq   int
+	scenarios []*scenarioState
+	counters  map[string]int64
+
+	// deterministic pins the clock to DeterministicEpoch and draws random
+	// values from random, seeded with DeterministicSeed.
+	deterministic bool
+	randomMu      sync.Mutex
+	random        *rand.Rand
+}
+
+// DeterministicEpoch is the time templates and mock responses report in
+// deterministic mode.
+var DeterministicEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// DeterministicSeed seeds the random values of a deterministic engine, so
+// generated UUIDs, latencies and injected errors repeat from run to run.
+const DeterministicSeed = 1
+
+// NewEngine returns an engine for the given config. A nil config yields an
+// engine that never delays or fails.
+func NewEngine(cfg *Config) *Engine {
+	engine := &Engine{}
+	if cfg != nil {
+		engine.profiles = cfg.Profiles
+		engine.rules = append(engine.rules, cfg.Rules...)
+		for _, scenario := range cfg.Scenarios {
+			engine.scenarios = append(engine.scenarios, &scenarioState{Scenario: scenario})
+		}
+		if len(cfg.Resources) > 0 {
+			engine.store = NewStore(cfg.Resources)
+		}
+	}
+	return engine
+}
+
+// Reload replaces the profiles, rules, scenarios and resources with those in
+// cfg. Rules added at runtime are dropped, scenarios restart from their first
+// step and resources are reset to their seed data. Playback is unaffected.
+func (e *Engine) Reload(cfg *Config) {
+	next := NewEngine(cfg)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.profiles = next.profiles
+	e.rules = next.rules
+	e.scenarios = next.scenarios
+	e.store = next.store
+}
+
+// SetDeterministic pins the clock and seeds random values so the same
+// requests get the same responses on every run, for snapshot tests.
+func (e *Engine) SetDeterministic() {
+	e.randomMu.Lock()
+	defer e.randomMu.Unlock()
+	e.deterministic = true
+	e.random = rand.New(rand.NewPCG(DeterministicSeed, DeterministicSeed))
+}
+
+// Deterministic reports whether SetDeterministic was called.
+func (e *Engine) Deterministic() bool {
+	e.randomMu.Lock()
+	defer e.randomMu.Unlock()
+	return e.deterministic
+}
+
+// Now is the current time, or DeterministicEpoch in deterministic mode.
+func (e *Engine) Now() time.Time {
+	if e.Deterministic() {
+		return DeterministicEpoch
+	}
+	return time.Now()
+}
+
+// withRandom calls f with the seeded source in deterministic mode, or with
+// nil to use the global one.
+func (e *Engine) withRandom(f func(r *rand.Rand)) {
+	e.randomMu.Lock()
+	defer e.randomMu.Unlock()
+	f(e.random)
+}
+
+// Store returns the resource store, or nil when no resources are configured.
+func (e *Engine) Store() *Store {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.store
+}
+
+// SetPlayback serves recorded upstream responses for matching requests.
+func (e *Engine) SetPlayback(recording *Recording) {
+	e.playback = recording
+}
+
+// HandlePlayback serves r from the playback recording and reports whether it
+// wrote a response. With playback enabled, requests that were never recorded
+// get a 404 rather than the generic mock echo.
+func (e *Engine) HandlePlayback(w http.ResponseWriter, r *http.Request, body string) bool {
+	if e.playback == nil {
+		return false
+	}
+	if e.playback.Handle(w, r, body) {
+		return true
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{
+		"error":  "no recorded response",
+		"method": r.Method,
+		"path":   r.URL.RequestURI(),
+	})
+	return true
+}
+
+// HandleStore serves r from the resource store and reports whether it did.
+func (e *Engine) HandleStore(w http.ResponseWriter, r *http.Request, body string) bool {
+	store := e.Store()
+	if store == nil {
+		return false
+	}
+	return store.Handle(w, r, body)
+}
+
+// Decide samples the latency and error profile matching the request.
+func (e *Engine) Decide(method, requestPath string) Outcome {
+	profile := e.match(method, requestPath)
+	if profile == nil {
+		return Outcome{}
+	}
+
+	outcome := Outcome{Profile: profile.Path}
+	e.withRandom(func(r *rand.Rand) {
+		outcome.Delay = profile.Latency.sample(r)
+		if profile.ErrorRate > 0 && float64N(r) < profile.ErrorRate {
+			outcome.FailStatus = profile.ErrorStatus
+		}
+	})
+	return outcome
+}
+
+func (e *Engine) match(method, requestPath string) *Profile {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for i := range e.profiles {
+		p := &e.profiles[i]
+		if len(p.Methods) > 0 && !slices.Contains(p.Methods, method) {
+			continue
+		}
+		if matchPath(p.Path, requestPath) {
+			return p
+		}
+	}
+	return nil
+}
+
+// matchPath matches path.Match patterns, with a trailing "/**" matching the
+// prefix and everything beneath it. A "{name}" segment matches any single
+// segment and is exposed to templates as a path parameter.
+func matchPath(pattern, requestPath string) bool {
+	pattern = globPattern(pattern)
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+	}
+	matched, err := path.Match(pattern, requestPath)
+	return err == nil && matched
+}
+
+func globPattern(pattern string) string {
+	if !strings.Contains(pattern, "{") {
+		return pattern
+	}
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if isParamSegment(segment) {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathParams extracts "{name}" segments of pattern from requestPath.
+func pathParams(pattern, requestPath string) map[string]string {
+	params := map[string]string{}
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(requestPath, "/")
+	for i, segment := range patternSegments {
+		if i >= len(pathSegments) {
+			break
+		}
+		if isParamSegment(segment) {
+			params[segment[1:len(segment)-1]] = pathSegments[i]
+		}
+	}
+	return params
+}
+
+func isParamSegment(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// sample draws a latency, from r when it is non-nil.
+func (l Latency) sample(r *rand.Rand) time.Duration {
+	var d time.Duration
+	switch l.Distribution {
+	case DistributionFixed:
+		d = l.Value
+	case DistributionUniform:
+		d = l.Min
+		if span := l.Max - l.Min; span > 0 {
+			n := int64(span) + 1
+			if r != nil {
+				d += time.Duration(r.Int64N(n))
+			} else {
+				d += time.Duration(rand.Int64N(n))
+			}
+		}
+	case DistributionNormal:
+		norm := rand.NormFloat64
+		if r != nil {
+			norm = r.NormFloat64
+		}
+		d = l.Mean + time.Duration(norm()*float64(l.StdDev))
+	}
+	return max(d, 0)
+}
+
+func float64N(r *rand.Rand) float64 {
+	if r != nil {
+		return r.Float64()
+	}
+	return rand.Float64()
+}
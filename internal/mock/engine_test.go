@@ -0,0 +1,176 @@
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigParsesProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mock.yml")
+	data := `profiles:
+  - path: /api/orders/**
+    methods: [get, post]
+    latency:
+      distribution: uniform
+      min: 50ms
+      max: 150ms
+    error_rate: 0.25
+  - path: /health
+    latency:
+      value: 5ms
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(cfg.Profiles) != 2 {
+		t.Fatalf("expected 2 profiles, got %d", len(cfg.Profiles))
+	}
+	orders := cfg.Profiles[0]
+	if orders.Latency.Min != 50*time.Millisecond || orders.Latency.Max != 150*time.Millisecond {
+		t.Fatalf("unexpected uniform bounds: %+v", orders.Latency)
+	}
+	if orders.ErrorStatus != 500 {
+		t.Fatalf("expected default error status 500, got %d", orders.ErrorStatus)
+	}
+	if orders.Methods[0] != "GET" {
+		t.Fatalf("expected methods to be upper-cased, got %v", orders.Methods)
+	}
+	if cfg.Profiles[1].Latency.Distribution != DistributionFixed {
+		t.Fatalf("expected value-only latency to default to fixed, got %q", cfg.Profiles[1].Latency.Distribution)
+	}
+}
+
+func TestConfigValidateRejectsBadProfiles(t *testing.T) {
+	tests := []Config{
+		{Profiles: []Profile{{}}},
+		{Profiles: []Profile{{Path: "/", ErrorRate: 1.5}}},
+		{Profiles: []Profile{{Path: "/", ErrorStatus: 700}}},
+		{Profiles: []Profile{{Path: "/", Latency: Latency{Distribution: "poisson"}}}},
+		{Profiles: []Profile{{Path: "/", Latency: Latency{Distribution: DistributionUniform, Min: time.Second}}}},
+	}
+	for i, cfg := range tests {
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("case %d: expected validation error", i)
+		}
+	}
+}
+
+func TestEngineDecide(t *testing.T) {
+	cfg := &Config{Profiles: []Profile{
+		{Path: "/flaky", Methods: []string{"POST"}, ErrorRate: 1, ErrorStatus: 503},
+		{Path: "/slow/*", Latency: Latency{Distribution: DistributionFixed, Value: 20 * time.Millisecond}},
+		{Path: "/jitter", Latency: Latency{Distribution: DistributionNormal, Mean: time.Millisecond, StdDev: time.Second}},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	engine := NewEngine(cfg)
+
+	if got := engine.Decide("POST", "/flaky"); got.FailStatus != 503 {
+		t.Fatalf("expected injected 503, got %+v", got)
+	}
+	if got := engine.Decide("GET", "/flaky"); got.Profile != "" {
+		t.Fatalf("expected method filter to skip profile, got %+v", got)
+	}
+	if got := engine.Decide("GET", "/slow/report"); got.Delay != 20*time.Millisecond || got.FailStatus != 0 {
+		t.Fatalf("expected fixed 20ms delay, got %+v", got)
+	}
+	for range 50 {
+		if got := engine.Decide("GET", "/jitter"); got.Delay < 0 {
+			t.Fatalf("expected normal latency to be clamped at zero, got %s", got.Delay)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/api/*", "/api/users", true},
+		{"/api/*", "/api/users/1", false},
+		{"/api/**", "/api/users/1", true},
+		{"/api/**", "/api", true},
+		{"/api/**", "/apiary", false},
+	}
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.path); got != tt.want {
+			t.Fatalf("matchPath(%q, %q) = %t, want %t", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestEngineReload(t *testing.T) {
+	engine := NewEngine(&Config{Rules: []Rule{{ID: "old", Path: "/old", Status: 200}}})
+	if _, err := engine.AddRule(Rule{Path: "/runtime"}); err != nil {
+		t.Fatalf("add rule: %v", err)
+	}
+
+	engine.Reload(&Config{
+		Profiles:  []Profile{{Path: "/slow", Latency: Latency{Distribution: DistributionFixed, Value: time.Second}}},
+		Rules:     []Rule{{ID: "new", Path: "/new", Status: 201}},
+		Resources: []Resource{{Path: "/items"}},
+	})
+
+	rules := engine.Rules()
+	if len(rules) != 1 || rules[0].ID != "new" {
+		t.Fatalf("expected only the reloaded rule, got %+v", rules)
+	}
+	if engine.Decide("GET", "/slow").Delay != time.Second {
+		t.Fatalf("expected reloaded profile to apply")
+	}
+	if engine.Store() == nil {
+		t.Fatalf("expected reloaded resources to create a store")
+	}
+}
+
+func TestDeterministicEngineRepeats(t *testing.T) {
+	cfg := &Config{
+		Profiles: []Profile{{
+			Path:        "/api/**",
+			Latency:     Latency{Distribution: DistributionUniform, Min: time.Millisecond, Max: time.Second},
+			ErrorRate:   0.5,
+			ErrorStatus: 503,
+		}},
+		Rules: []Rule{{Path: "/id", Status: http.StatusOK, Body: `{{uuid}} {{timestamp}} {{unix}}`}},
+	}
+
+	run := func() []string {
+		engine := NewEngine(cfg)
+		engine.SetDeterministic()
+		var out []string
+		for range 3 {
+			rr := httptest.NewRecorder()
+			if !engine.HandleRule(rr, httptest.NewRequest(http.MethodGet, "/id", nil), "") {
+				t.Fatal("expected the rule to match")
+			}
+			outcome := engine.Decide(http.MethodGet, "/api/x")
+			out = append(out, fmt.Sprintf("%s %s %d", rr.Body.String(), outcome.Delay, outcome.FailStatus))
+		}
+		return out
+	}
+
+	first, second := run(), run()
+	if !slices.Equal(first, second) {
+		t.Fatalf("expected identical runs, got\n%v\n%v", first, second)
+	}
+	if first[0] == first[1] {
+		t.Fatalf("expected values to differ between requests, got %v", first)
+	}
+	if !strings.Contains(first[0], " 2000-01-01T00:00:00Z 946684800 ") {
+		t.Fatalf("expected the pinned clock, got %q", first[0])
+	}
+}
@@ -0,0 +1,158 @@
+// internal/mock/recording.go
+package mock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RecordedResponse is an upstream response captured in proxy-record mode.
+type RecordedResponse struct {
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	BodySHA256 string              `json:"body_sha256"`
+	Status     int                 `json:"status"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       []byte              `json:"body,omitempty"`
+	RecordedAt time.Time           `json:"recorded_at"`
+}
+
+// Key identifies a recorded response by method, path (with query) and
+// request body hash.
+func (r RecordedResponse) Key() string {
+	return RecordingKey(r.Method, r.Path, r.BodySHA256)
+}
+
+// RecordingKey builds the lookup key for a request.
+func RecordingKey(method, pathWithQuery, bodySHA256 string) string {
+	return method + " " + pathWithQuery + " " + bodySHA256
+}
+
+// HashBody returns the hex SHA-256 of a request body.
+func HashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// skippedRecordHeaders are recomputed on playback rather than replayed.
+var skippedRecordHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Date":              true,
+	"Connection":        true,
+	"Transfer-Encoding": true,
+	"Keep-Alive":        true,
+}
+
+// Recording is a set of recorded responses persisted as a JSON file. The
+// latest response for a key wins.
+type Recording struct {
+	mu        sync.RWMutex
+	path      string
+	responses map[string]RecordedResponse
+}
+
+// OpenRecording loads the recording at path. A missing file yields an empty
+// recording that will be created on the first Add.
+func OpenRecording(path string) (*Recording, error) {
+	recording := &Recording{path: path, responses: make(map[string]RecordedResponse)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return recording, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording %s: %w", path, err)
+	}
+
+	var entries []RecordedResponse
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse recording %s: %w", path, err)
+	}
+	for _, entry := range entries {
+		recording.responses[entry.Key()] = entry
+	}
+	return recording, nil
+}
+
+// Len returns the number of recorded responses.
+func (r *Recording) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.responses)
+}
+
+// Add records a response and rewrites the recording file.
+func (r *Recording) Add(entry RecordedResponse) error {
+	headers := make(map[string][]string, len(entry.Headers))
+	for key, values := range entry.Headers {
+		if !skippedRecordHeaders[http.CanonicalHeaderKey(key)] {
+			headers[key] = values
+		}
+	}
+	entry.Headers = headers
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses[entry.Key()] = entry
+	return r.saveLocked()
+}
+
+// Lookup returns the recorded response for a request, if any.
+func (r *Recording) Lookup(method, pathWithQuery string, body []byte) (RecordedResponse, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.responses[RecordingKey(method, pathWithQuery, HashBody(body))]
+	return entry, ok
+}
+
+// Handle writes the recorded response for r and reports whether one existed.
+func (r *Recording) Handle(w http.ResponseWriter, req *http.Request, body string) bool {
+	entry, ok := r.Lookup(req.Method, req.URL.RequestURI(), []byte(body))
+	if !ok {
+		return false
+	}
+	w.Header().Del("Content-Type")
+	for key, values := range entry.Headers {
+		w.Header()[key] = values
+	}
+	w.Header().Set("X-portal-playback", entry.RecordedAt.UTC().Format(time.RFC3339))
+	w.WriteHeader(entry.Status)
+	w.Write(entry.Body)
+	return true
+}
+
+// saveLocked writes the recording atomically. Callers must hold r.mu.
+func (r *Recording) saveLocked() error {
+	entries := make([]RecordedResponse, 0, len(r.responses))
+	for _, entry := range r.responses {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key() < entries[j].Key() })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recording: %w", err)
+	}
+
+	if dir := filepath.Dir(r.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create recording directory: %w", err)
+		}
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("failed to save recording: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// internal/mock/rules.go
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrRuleNotFound is returned when a rule ID does not exist.
+	ErrRuleNotFound = errors.New("mock rule not found")
+	// ErrScenarioNotFound is returned when a scenario ID does not exist.
+	ErrScenarioNotFound = errors.New("mock scenario not found")
+)
+
+// Rule returns a fixed response for requests matching Method and Path. Rules
+// are checked in order before resources, playback and the default echo.
+type Rule struct {
+	ID string `yaml:"id" json:"id"`
+	// Method restricts the rule to one method; empty matches all.
+	Method string `yaml:"method" json:"method,omitempty"`
+	// Path uses the same pattern syntax as profiles.
+	Path    string            `yaml:"path" json:"path"`
+	Status  int               `yaml:"status" json:"status"`
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
+	Body    string            `yaml:"body" json:"body,omitempty"`
+}
+
+func (r *Rule) normalize() error {
+	r.Method = strings.ToUpper(strings.TrimSpace(r.Method))
+	r.Path = strings.TrimSpace(r.Path)
+	if !strings.HasPrefix(r.Path, "/") {
+		return fmt.Errorf("rule path must start with / (got %q)", r.Path)
+	}
+	if r.Status == 0 {
+		r.Status = http.StatusOK
+	}
+	if r.Status < 100 || r.Status > 599 {
+		return fmt.Errorf("rule status %d is not a valid HTTP status", r.Status)
+	}
+	if err := checkTemplates(r.Headers, r.Body); err != nil {
+		return fmt.Errorf("rule template: %w", err)
+	}
+	return nil
+}
+
+func (r Rule) matches(method, requestPath string) bool {
+	if r.Method != "" && r.Method != method {
+		return false
+	}
+	return matchPath(r.Path, requestPath)
+}
+
+// Rules returns a copy of the current rules in evaluation order.
+func (e *Engine) Rules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]Rule(nil), e.rules...)
+}
+
+// Rule returns the rule with the given ID.
+func (e *Engine) Rule(id string) (Rule, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, rule := range e.rules {
+		if rule.ID == id {
+			return rule, nil
+		}
+	}
+	return Rule{}, ErrRuleNotFound
+}
+
+// AddRule validates rule, assigns an ID when missing and appends it.
+func (e *Engine) AddRule(rule Rule) (Rule, error) {
+	if err := rule.normalize(); err != nil {
+		return Rule{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if rule.ID == "" {
+		rule.ID = e.nextRuleIDLocked()
+	} else if e.ruleIndexLocked(rule.ID) >= 0 {
+		return Rule{}, fmt.Errorf("mock rule %q already exists", rule.ID)
+	}
+	e.rules = append(e.rules, rule)
+	return rule, nil
+}
+
+// UpdateRule replaces the rule with the given ID, keeping its position.
+func (e *Engine) UpdateRule(id string, rule Rule) (Rule, error) {
+	if err := rule.normalize(); err != nil {
+		return Rule{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	idx := e.ruleIndexLocked(id)
+	if idx < 0 {
+		return Rule{}, ErrRuleNotFound
+	}
+	rule.ID = id
+	e.rules[idx] = rule
+	return rule, nil
+}
+
+// DeleteRule removes the rule with the given ID.
+func (e *Engine) DeleteRule(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	idx := e.ruleIndexLocked(id)
+	if idx < 0 {
+		return ErrRuleNotFound
+	}
+	e.rules = append(e.rules[:idx], e.rules[idx+1:]...)
+	return nil
+}
+
+// HandleRule writes the first matching rule's response and reports whether a
+// rule matched. body is the request body, available to response templates.
+func (e *Engine) HandleRule(w http.ResponseWriter, r *http.Request, body string) bool {
+	e.mu.RLock()
+	var matched *Rule
+	for i := range e.rules {
+		if e.rules[i].matches(r.Method, r.URL.Path) {
+			rule := e.rules[i]
+			matched = &rule
+			break
+		}
+	}
+	e.mu.RUnlock()
+	if matched == nil {
+		return false
+	}
+
+	w.Header().Set("X-portal-mock-rule", matched.ID)
+	e.writeTemplated(w, r, body, matched.Path, matched.Status, matched.Headers, matched.Body)
+	return true
+}
+
+func writeResponse(w http.ResponseWriter, status int, headers map[string]string, body string) {
+	for key, value := range headers {
+		w.Header().Set(key, value)
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
+
+func (e *Engine) ruleIndexLocked(id string) int {
+	for i, rule := range e.rules {
+		if rule.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *Engine) nextRuleIDLocked() string {
+	for {
+		e.ruleSeq++
+		id := fmt.Sprintf("rule_%d", e.ruleSeq)
+		if e.ruleIndexLocked(id) < 0 {
+			return id
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package mock
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEngineRuleLifecycle(t *testing.T) {
+	engine := NewEngine(nil)
+
+	created, err := engine.AddRule(Rule{Method: "get", Path: "/users/*", Status: 418, Body: "teapot"})
+	if err != nil {
+		t.Fatalf("add rule: %v", err)
+	}
+	if created.ID == "" || created.Method != "GET" {
+		t.Fatalf("expected normalized rule with ID, got %+v", created)
+	}
+
+	rr := httptest.NewRecorder()
+	if !engine.HandleRule(rr, httptest.NewRequest(http.MethodGet, "/users/7", nil), "") {
+		t.Fatalf("expected rule to match")
+	}
+	if rr.Code != 418 || rr.Body.String() != "teapot" || rr.Header().Get("X-portal-mock-rule") != created.ID {
+		t.Fatalf("unexpected rule response: %d %q %v", rr.Code, rr.Body.String(), rr.Header())
+	}
+
+	if _, err := engine.UpdateRule(created.ID, Rule{Path: "/users/*", Body: "updated"}); err != nil {
+		t.Fatalf("update rule: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	engine.HandleRule(rr, httptest.NewRequest(http.MethodPost, "/users/7", nil), "")
+	if rr.Code != http.StatusOK || rr.Body.String() != "updated" {
+		t.Fatalf("expected updated rule to match any method with default 200, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	if err := engine.DeleteRule(created.ID); err != nil {
+		t.Fatalf("delete rule: %v", err)
+	}
+	if engine.HandleRule(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/7", nil), "") {
+		t.Fatalf("expected no match after delete")
+	}
+	if err := engine.DeleteRule(created.ID); !errors.Is(err, ErrRuleNotFound) {
+		t.Fatalf("expected ErrRuleNotFound, got %v", err)
+	}
+}
+
+func TestEngineRejectsInvalidRules(t *testing.T) {
+	engine := NewEngine(&Config{Rules: []Rule{{ID: "existing", Path: "/"}}})
+
+	if _, err := engine.AddRule(Rule{Path: "relative"}); err == nil {
+		t.Fatalf("expected relative path to be rejected")
+	}
+	if _, err := engine.AddRule(Rule{Path: "/", Status: 42}); err == nil {
+		t.Fatalf("expected invalid status to be rejected")
+	}
+	if _, err := engine.AddRule(Rule{ID: "existing", Path: "/"}); err == nil {
+		t.Fatalf("expected duplicate ID to be rejected")
+	}
+}
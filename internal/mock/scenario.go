@@ -0,0 +1,137 @@
+// internal/mock/scenario.go
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Scenario returns a scripted sequence of responses to consecutive matching
+// requests, e.g. 500, 500, then 200 to exercise client retry logic.
+type Scenario struct {
+	ID     string `yaml:"id" json:"id"`
+	Method string `yaml:"method" json:"method,omitempty"`
+	Path   string `yaml:"path" json:"path"`
+	// Loop restarts the sequence after the last step. Without it the last
+	// step repeats.
+	Loop  bool   `yaml:"loop" json:"loop"`
+	Steps []Step `yaml:"steps" json:"steps"`
+}
+
+// Step is one scripted response in a scenario.
+type Step struct {
+	Status  int               `yaml:"status" json:"status"`
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
+	Body    string            `yaml:"body" json:"body,omitempty"`
+}
+
+// ScenarioStatus reports where a scenario is in its sequence.
+type ScenarioStatus struct {
+	Scenario
+	// Position is the index of the step the next matching request receives.
+	Position int `json:"position"`
+	Calls    int `json:"calls"`
+}
+
+type scenarioState struct {
+	Scenario
+	position int
+	calls    int
+}
+
+func (s *Scenario) normalize(index int) error {
+	s.Method = strings.ToUpper(strings.TrimSpace(s.Method))
+	s.Path = strings.TrimSpace(s.Path)
+	if s.ID == "" {
+		s.ID = fmt.Sprintf("scenario_%d", index+1)
+	}
+	if !strings.HasPrefix(s.Path, "/") {
+		return fmt.Errorf("scenario %q: path must start with / (got %q)", s.ID, s.Path)
+	}
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("scenario %q: at least one step is required", s.ID)
+	}
+	for i := range s.Steps {
+		if s.Steps[i].Status == 0 {
+			s.Steps[i].Status = http.StatusOK
+		}
+		if s.Steps[i].Status < 100 || s.Steps[i].Status > 599 {
+			return fmt.Errorf("scenario %q step %d: status %d is not a valid HTTP status", s.ID, i+1, s.Steps[i].Status)
+		}
+		if err := checkTemplates(s.Steps[i].Headers, s.Steps[i].Body); err != nil {
+			return fmt.Errorf("scenario %q step %d: template: %w", s.ID, i+1, err)
+		}
+	}
+	return nil
+}
+
+// HandleScenario writes the next step of the first matching scenario and
+// reports whether one matched.
+func (e *Engine) HandleScenario(w http.ResponseWriter, r *http.Request, body string) bool {
+	e.mu.Lock()
+	var (
+		matched *scenarioState
+		step    Step
+		index   int
+	)
+	for _, state := range e.scenarios {
+		if state.Method != "" && state.Method != r.Method {
+			continue
+		}
+		if !matchPath(state.Path, r.URL.Path) {
+			continue
+		}
+		matched = state
+		index = state.position
+		step = state.Steps[index]
+		state.calls++
+		switch {
+		case state.position < len(state.Steps)-1:
+			state.position++
+		case state.Loop:
+			state.position = 0
+		}
+		break
+	}
+	e.mu.Unlock()
+	if matched == nil {
+		return false
+	}
+
+	w.Header().Set("X-portal-mock-scenario", fmt.Sprintf("%s step=%d/%d", matched.ID, index+1, len(matched.Steps)))
+	e.writeTemplated(w, r, body, matched.Path, step.Status, step.Headers, step.Body)
+	return true
+}
+
+// Scenarios returns every scenario with its current position.
+func (e *Engine) Scenarios() []ScenarioStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	statuses := make([]ScenarioStatus, 0, len(e.scenarios))
+	for _, state := range e.scenarios {
+		statuses = append(statuses, ScenarioStatus{Scenario: state.Scenario, Position: state.position, Calls: state.calls})
+	}
+	return statuses
+}
+
+// ResetScenario rewinds one scenario to its first step. An empty id rewinds
+// every scenario.
+func (e *Engine) ResetScenario(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	found := id == ""
+	for _, state := range e.scenarios {
+		if id == "" || state.ID == id {
+			state.position = 0
+			state.calls = 0
+			found = true
+		}
+	}
+	if !found {
+		return ErrScenarioNotFound
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package mock
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEngineScenarioSequence(t *testing.T) {
+	cfg := &Config{Scenarios: []Scenario{{
+		ID:     "flaky",
+		Method: "post",
+		Path:   "/checkout",
+		Steps:  []Step{{Status: 500}, {Status: 500}, {Body: "ok"}},
+	}}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	engine := NewEngine(cfg)
+
+	var got []int
+	for range 4 {
+		rr := httptest.NewRecorder()
+		if !engine.HandleScenario(rr, httptest.NewRequest(http.MethodPost, "/checkout", nil), "") {
+			t.Fatalf("expected scenario to match")
+		}
+		got = append(got, rr.Code)
+	}
+	want := []int{500, 500, 200, 200}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected statuses %v, got %v", want, got)
+		}
+	}
+
+	if engine.HandleScenario(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/checkout", nil), "") {
+		t.Fatalf("expected method mismatch to fall through")
+	}
+
+	status := engine.Scenarios()[0]
+	if status.Position != 2 || status.Calls != 4 {
+		t.Fatalf("expected position 2 after 4 calls, got %+v", status)
+	}
+
+	if err := engine.ResetScenario("flaky"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	engine.HandleScenario(rr, httptest.NewRequest(http.MethodPost, "/checkout", nil), "")
+	if rr.Code != 500 || rr.Header().Get("X-portal-mock-scenario") != "flaky step=1/3" {
+		t.Fatalf("expected first step after reset, got %d %v", rr.Code, rr.Header())
+	}
+
+	if err := engine.ResetScenario("missing"); !errors.Is(err, ErrScenarioNotFound) {
+		t.Fatalf("expected ErrScenarioNotFound, got %v", err)
+	}
+}
+
+func TestEngineScenarioLoop(t *testing.T) {
+	engine := NewEngine(&Config{Scenarios: []Scenario{{
+		ID:    "toggle",
+		Path:  "/toggle",
+		Loop:  true,
+		Steps: []Step{{Status: 200}, {Status: 503}},
+	}}})
+
+	var got []int
+	for range 3 {
+		rr := httptest.NewRecorder()
+		engine.HandleScenario(rr, httptest.NewRequest(http.MethodGet, "/toggle", nil), "")
+		got = append(got, rr.Code)
+	}
+	if got[0] != 200 || got[1] != 503 || got[2] != 200 {
+		t.Fatalf("expected looping sequence, got %v", got)
+	}
+}
+
+func TestConfigRejectsInvalidScenarios(t *testing.T) {
+	cases := map[string]Scenario{
+		"no steps":       {ID: "a", Path: "/a"},
+		"relative path":  {ID: "a", Path: "a", Steps: []Step{{}}},
+		"invalid status": {ID: "a", Path: "/a", Steps: []Step{{Status: 42}}},
+	}
+	for name, scenario := range cases {
+		cfg := &Config{Scenarios: []Scenario{scenario}}
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+
+	cfg := &Config{Scenarios: []Scenario{
+		{ID: "dup", Path: "/a", Steps: []Step{{}}},
+		{ID: "dup", Path: "/b", Steps: []Step{{}}},
+	}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected duplicate scenario IDs to be rejected")
+	}
+}
@@ -0,0 +1,256 @@
+// internal/mock/store.go
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Resource declares a collection served by the in-memory CRUD store.
+type Resource struct {
+	// Path is the collection path, e.g. /api/users. Items live at Path/{id}.
+	Path string `yaml:"path"`
+	// IDField is the JSON field holding the item ID (default "id").
+	IDField string `yaml:"id_field"`
+	// Seed items are loaded at startup and restored on reset.
+	Seed []map[string]any `yaml:"seed"`
+}
+
+// Store is an in-memory resource store backing stateful mock endpoints.
+type Store struct {
+	mu          sync.Mutex
+	resources   []Resource
+	collections map[string]*collection
+}
+
+type collection struct {
+	idField string
+	nextID  int
+	order   []string
+	items   map[string]map[string]any
+}
+
+// NewStore creates a store for the given resources, loading any seed items.
+func NewStore(resources []Resource) *Store {
+	store := &Store{resources: resources}
+	store.Reset()
+	return store
+}
+
+// Reset drops all stored items and restores seed data.
+func (s *Store) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.collections = make(map[string]*collection, len(s.resources))
+	for _, resource := range s.resources {
+		c := &collection{idField: resource.IDField, items: make(map[string]map[string]any)}
+		for _, item := range resource.Seed {
+			c.insert(cloneItem(item))
+		}
+		s.collections[resource.Path] = c
+	}
+}
+
+// Snapshot returns every collection's items in insertion order.
+func (s *Store) Snapshot() map[string][]map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make(map[string][]map[string]any, len(s.collections))
+	for path, c := range s.collections {
+		snapshot[path] = c.list()
+	}
+	return snapshot
+}
+
+// Handle serves r when it targets a configured collection and reports whether
+// it did.
+func (s *Store) Handle(w http.ResponseWriter, r *http.Request, body string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, collectionPath, id, ok := s.route(r.URL.Path)
+	if !ok {
+		return false
+	}
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, c.list())
+	case id == "" && r.Method == http.MethodPost:
+		item, ok := decodeItem(w, body)
+		if !ok {
+			return true
+		}
+		id, ok := c.insert(item)
+		if !ok {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "already exists", "id": id})
+			return true
+		}
+		w.Header().Set("Location", collectionPath+"/"+id)
+		writeJSON(w, http.StatusCreated, item)
+	case id == "":
+		writeMethodNotAllowed(w, "GET, POST")
+	case r.Method == http.MethodGet:
+		item, found := c.items[id]
+		if !found {
+			writeNotFound(w, id)
+			return true
+		}
+		writeJSON(w, http.StatusOK, item)
+	case r.Method == http.MethodPut || r.Method == http.MethodPatch:
+		existing, found := c.items[id]
+		if !found {
+			writeNotFound(w, id)
+			return true
+		}
+		item, ok := decodeItem(w, body)
+		if !ok {
+			return true
+		}
+		if r.Method == http.MethodPatch {
+			for key, value := range item {
+				existing[key] = value
+			}
+			item = existing
+		}
+		item[c.field()] = existing[c.field()]
+		c.items[id] = item
+		writeJSON(w, http.StatusOK, item)
+	case r.Method == http.MethodDelete:
+		if _, found := c.items[id]; !found {
+			writeNotFound(w, id)
+			return true
+		}
+		c.remove(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, "GET, PUT, PATCH, DELETE")
+	}
+	return true
+}
+
+// route resolves a request path to a collection and optional item ID.
+func (s *Store) route(requestPath string) (*collection, string, string, bool) {
+	requestPath = strings.TrimSuffix(requestPath, "/")
+	if c, ok := s.collections[requestPath]; ok {
+		return c, requestPath, "", true
+	}
+	idx := strings.LastIndex(requestPath, "/")
+	if idx <= 0 {
+		return nil, "", "", false
+	}
+	parent, id := requestPath[:idx], requestPath[idx+1:]
+	if c, ok := s.collections[parent]; ok && id != "" {
+		return c, parent, id, true
+	}
+	return nil, "", "", false
+}
+
+func (c *collection) field() string {
+	if c.idField == "" {
+		return "id"
+	}
+	return c.idField
+}
+
+// insert stores item, keeping a caller-supplied ID or assigning the next
+// sequential one, and returns the ID. It reports false, storing nothing, when
+// the supplied ID is already in use. Assigned IDs stay above every numeric
+// ID stored so far, so they never replace seeded or supplied items.
+func (c *collection) insert(item map[string]any) (string, bool) {
+	id := ""
+	if raw, ok := item[c.field()]; ok && raw != nil {
+		id = idString(raw)
+	}
+	if id == "" {
+		for {
+			c.nextID++
+			id = strconv.Itoa(c.nextID)
+			if _, exists := c.items[id]; !exists {
+				break
+			}
+		}
+		item[c.field()] = id
+	}
+	if _, exists := c.items[id]; exists {
+		return id, false
+	}
+	if n, err := strconv.Atoi(id); err == nil && n > c.nextID {
+		c.nextID = n
+	}
+	c.order = append(c.order, id)
+	c.items[id] = item
+	return id, true
+}
+
+func (c *collection) remove(id string) {
+	delete(c.items, id)
+	for i, existing := range c.order {
+		if existing == id {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (c *collection) list() []map[string]any {
+	items := make([]map[string]any, 0, len(c.order))
+	for _, id := range c.order {
+		items = append(items, c.items[id])
+	}
+	return items
+}
+
+func idString(raw any) string {
+	switch v := raw.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+func cloneItem(item map[string]any) map[string]any {
+	clone := make(map[string]any, len(item))
+	for key, value := range item {
+		clone[key] = value
+	}
+	return clone
+}
+
+func decodeItem(w http.ResponseWriter, body string) (map[string]any, bool) {
+	item := map[string]any{}
+	if strings.TrimSpace(body) == "" {
+		return item, true
+	}
+	if err := json.Unmarshal([]byte(body), &item); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "request body must be a JSON object"})
+		return nil, false
+	}
+	return item, true
+}
+
+func writeNotFound(w http.ResponseWriter, id string) {
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found", "id": id})
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
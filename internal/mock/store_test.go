@@ -0,0 +1,119 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func storeRequest(t *testing.T, store *Store, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	if !store.Handle(rr, httptest.NewRequest(method, path, nil), body) {
+		t.Fatalf("expected %s %s to be handled by the store", method, path)
+	}
+	return rr
+}
+
+func TestStoreCRUDLifecycle(t *testing.T) {
+	store := NewStore([]Resource{{Path: "/api/users"}})
+
+	rr := storeRequest(t, store, http.MethodPost, "/api/users", `{"name":"ada"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Location"); got != "/api/users/1" {
+		t.Fatalf("expected Location /api/users/1, got %q", got)
+	}
+
+	rr = storeRequest(t, store, http.MethodPut, "/api/users/1", `{"name":"grace"}`)
+	var updated map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &updated); err != nil {
+		t.Fatalf("decode put response: %v", err)
+	}
+	if updated["name"] != "grace" || updated["id"] != "1" {
+		t.Fatalf("unexpected updated item: %v", updated)
+	}
+
+	rr = storeRequest(t, store, http.MethodPatch, "/api/users/1", `{"role":"admin"}`)
+	var patched map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &patched); err != nil {
+		t.Fatalf("decode patch response: %v", err)
+	}
+	if patched["name"] != "grace" || patched["role"] != "admin" {
+		t.Fatalf("expected patch to merge fields, got %v", patched)
+	}
+
+	if rr := storeRequest(t, store, http.MethodDelete, "/api/users/1", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 on delete, got %d", rr.Code)
+	}
+	if rr := storeRequest(t, store, http.MethodGet, "/api/users/1", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", rr.Code)
+	}
+}
+
+func TestStoreResetRestoresSeed(t *testing.T) {
+	store := NewStore([]Resource{{Path: "/todos", IDField: "key", Seed: []map[string]any{{"key": "a", "done": false}}}})
+
+	storeRequest(t, store, http.MethodPost, "/todos", `{"title":"new"}`)
+	if got := len(store.Snapshot()["/todos"]); got != 2 {
+		t.Fatalf("expected 2 items before reset, got %d", got)
+	}
+
+	store.Reset()
+	items := store.Snapshot()["/todos"]
+	if len(items) != 1 || items[0]["key"] != "a" {
+		t.Fatalf("expected only seed item after reset, got %v", items)
+	}
+}
+
+func TestStoreAssignsIDsAfterSeed(t *testing.T) {
+	store := NewStore([]Resource{{Path: "/users", Seed: []map[string]any{
+		{"id": 1, "name": "alice"},
+		{"id": 2, "name": "bob"},
+	}}})
+
+	rr := storeRequest(t, store, http.MethodPost, "/users", `{"name":"carol"}`)
+	if rr.Code != http.StatusCreated || rr.Header().Get("Location") != "/users/3" {
+		t.Fatalf("expected carol created at /users/3, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	rr = storeRequest(t, store, http.MethodPost, "/users", `{"id":10,"name":"dave"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for a free ID, got %d", rr.Code)
+	}
+	if rr := storeRequest(t, store, http.MethodPost, "/users", `{"name":"erin"}`); rr.Header().Get("Location") != "/users/11" {
+		t.Fatalf("expected the next ID after a supplied one, got %q", rr.Header().Get("Location"))
+	}
+
+	if rr := storeRequest(t, store, http.MethodPost, "/users", `{"id":1,"name":"mallory"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for an ID in use, got %d", rr.Code)
+	}
+
+	var names []any
+	for _, item := range store.Snapshot()["/users"] {
+		names = append(names, item["name"])
+	}
+	if want := []any{"alice", "bob", "carol", "dave", "erin"}; !slices.Equal(names, want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+}
+
+func TestStoreIgnoresUnknownPaths(t *testing.T) {
+	store := NewStore([]Resource{{Path: "/api/users"}})
+
+	for _, path := range []string{"/", "/api", "/api/orders/1", "/api/users/1/roles"} {
+		if store.Handle(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil), "") {
+			t.Fatalf("expected %s not to be handled", path)
+		}
+	}
+}
+
+func TestStoreRejectsInvalidJSON(t *testing.T) {
+	store := NewStore([]Resource{{Path: "/items"}})
+
+	if rr := storeRequest(t, store, http.MethodPost, "/items", `[1,2]`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for non-object body, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,147 @@
+// internal/mock/template.go
+package mock
+
+import (
+	"bytes"
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateData is the dot value available to response templates.
+type templateData struct {
+	Method  string
+	Path    string
+	Query   map[string][]string
+	Headers http.Header
+	Params  map[string]string
+	Body    string
+}
+
+// isTemplate reports whether s needs rendering; plain bodies are written as-is.
+func isTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// checkTemplates reports syntax errors in response templates up front.
+func checkTemplates(headers map[string]string, body string) error {
+	for _, s := range append(slices.Collect(maps.Values(headers)), body) {
+		if !isTemplate(s) {
+			continue
+		}
+		if _, err := template.New("response").Funcs(stubTemplateFuncs).Parse(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stubTemplateFuncs lets templates parse without an engine; only the names
+// matter for validation.
+var stubTemplateFuncs = template.FuncMap{
+	"header":    func(string) string { return "" },
+	"query":     func(string) string { return "" },
+	"param":     func(string) string { return "" },
+	"uuid":      func() string { return "" },
+	"now":       func() time.Time { return time.Time{} },
+	"timestamp": func() string { return "" },
+	"unix":      func() int64 { return 0 },
+	"counter":   func(string) int64 { return 0 },
+}
+
+// render executes s against r. Strings without template actions are returned
+// unchanged.
+func (e *Engine) render(s string, r *http.Request, pattern, body string) (string, error) {
+	if !isTemplate(s) {
+		return s, nil
+	}
+
+	data := templateData{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.Query(),
+		Headers: r.Header,
+		Params:  pathParams(pattern, r.URL.Path),
+		Body:    body,
+	}
+	tmpl, err := template.New("response").Funcs(template.FuncMap{
+		"header":    r.Header.Get,
+		"query":     r.URL.Query().Get,
+		"param":     func(name string) string { return data.Params[name] },
+		"uuid":      e.newUUID,
+		"now":       e.Now,
+		"timestamp": func() string { return e.Now().UTC().Format(time.RFC3339) },
+		"unix":      func() int64 { return e.Now().Unix() },
+		"counter":   e.nextCounter,
+	}).Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// writeTemplated renders headers and body for r before writing them. A
+// template that fails to execute produces a 500 describing the error.
+func (e *Engine) writeTemplated(w http.ResponseWriter, r *http.Request, requestBody, pattern string, status int, headers map[string]string, body string) {
+	rendered := make(map[string]string, len(headers))
+	for key, value := range headers {
+		v, err := e.render(value, r, pattern, requestBody)
+		if err != nil {
+			writeTemplateError(w, err)
+			return
+		}
+		rendered[key] = v
+	}
+	out, err := e.render(body, r, pattern, requestBody)
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+	writeResponse(w, status, rendered, out)
+}
+
+func writeTemplateError(w http.ResponseWriter, err error) {
+	writeJSON(w, http.StatusInternalServerError, map[string]string{
+		"error": fmt.Sprintf("mock template: %v", err),
+	})
+}
+
+// nextCounter increments and returns the named counter, starting at 1.
+func (e *Engine) nextCounter(name string) int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.counters == nil {
+		e.counters = make(map[string]int64)
+	}
+	e.counters[name]++
+	return e.counters[name]
+}
+
+// newUUID returns a random version 4 UUID, drawn from the seeded source in
+// deterministic mode.
+func (e *Engine) newUUID() string {
+	var b [16]byte
+	e.withRandom(func(r *rand.Rand) {
+		if r == nil {
+			_, _ = cryptorand.Read(b[:])
+			return
+		}
+		binary.BigEndian.PutUint64(b[:8], r.Uint64())
+		binary.BigEndian.PutUint64(b[8:], r.Uint64())
+	})
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
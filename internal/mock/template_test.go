@@ -0,0 +1,74 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestRuleTemplateRendersRequestData(t *testing.T) {
+	engine := NewEngine(nil)
+	_, err := engine.AddRule(Rule{
+		Path:    "/users/{id}",
+		Headers: map[string]string{"X-Request-Trace": `{{header "X-Trace"}}`},
+		Body:    `{"id":"{{param "id"}}","q":"{{query "q"}}","method":"{{.Method}}","n":{{counter "users"}},"uuid":"{{uuid}}","body":{{.Body}}}`,
+	})
+	if err != nil {
+		t.Fatalf("add rule: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/users/42?q=search", nil)
+	req.Header.Set("X-Trace", "abc")
+	rr := httptest.NewRecorder()
+	if !engine.HandleRule(rr, req, `{"name":"ada"}`) {
+		t.Fatalf("expected rule to match")
+	}
+
+	var got struct {
+		ID     string         `json:"id"`
+		Q      string         `json:"q"`
+		Method string         `json:"method"`
+		N      int            `json:"n"`
+		UUID   string         `json:"uuid"`
+		Body   map[string]any `json:"body"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode %q: %v", rr.Body.String(), err)
+	}
+	if got.ID != "42" || got.Q != "search" || got.Method != "POST" || got.N != 1 || got.Body["name"] != "ada" {
+		t.Fatalf("unexpected rendered body: %+v", got)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(got.UUID) {
+		t.Fatalf("expected v4 UUID, got %q", got.UUID)
+	}
+	if rr.Header().Get("X-Request-Trace") != "abc" {
+		t.Fatalf("expected templated header, got %v", rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	engine.HandleRule(rr, httptest.NewRequest(http.MethodGet, "/users/7", nil), "{}")
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || got.N != 2 {
+		t.Fatalf("expected counter to increment, got %q", rr.Body.String())
+	}
+}
+
+func TestRuleTemplateValidation(t *testing.T) {
+	engine := NewEngine(nil)
+	if _, err := engine.AddRule(Rule{Path: "/", Body: "{{ .Method "}); err == nil {
+		t.Fatalf("expected template syntax error")
+	}
+	if _, err := engine.AddRule(Rule{Path: "/", Body: "{{ unknownFunc }}"}); err == nil {
+		t.Fatalf("expected unknown function to be rejected")
+	}
+
+	if _, err := engine.AddRule(Rule{Path: "/fail", Body: "{{ index .Query.missing 3 }}"}); err != nil {
+		t.Fatalf("add rule: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	engine.HandleRule(rr, httptest.NewRequest(http.MethodGet, "/fail", nil), "")
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 for failing template, got %d %q", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Header is one header field. Repeated headers are kept as separate
+// entries: joining them with commas, as http.Header.Get callers often do,
+// corrupts values such as Set-Cookie whose dates contain commas.
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Headers are the header fields of a request or response. Names are sorted,
+// since net/http doesn't keep the order between different names, and the
+// values of a repeated name stay in the order they arrived.
+type Headers []Header
+
+// HeadersFrom copies h.
+func HeadersFrom(h http.Header) Headers {
+	n := 0
+	for _, values := range h {
+		n += len(values)
+	}
+	headers := make(Headers, 0, n)
+	for _, name := range slices.Sorted(maps.Keys(h)) {
+		for _, value := range h[name] {
+			headers = append(headers, Header{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// HeadersFromMap converts headers with one value per name, as capture
+// files from before repeated headers were kept have them, sorting them by
+// name.
+func HeadersFromMap(m map[string]string) Headers {
+	if m == nil {
+		return nil
+	}
+	headers := make(Headers, 0, len(m))
+	for _, name := range slices.Sorted(maps.Keys(m)) {
+		headers = append(headers, Header{Name: name, Value: m[name]})
+	}
+	return headers
+}
+
+// Get returns the first value of the named header, ignoring case, or "".
+func (h Headers) Get(name string) string {
+	for _, header := range h {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+// Values returns every value of the named header, ignoring case.
+func (h Headers) Values(name string) []string {
+	var values []string
+	for _, header := range h {
+		if strings.EqualFold(header.Name, name) {
+			values = append(values, header.Value)
+		}
+	}
+	return values
+}
+
+// Header converts h to an http.Header, canonicalizing the names.
+func (h Headers) Header() http.Header {
+	header := make(http.Header, len(h))
+	for _, field := range h {
+		header.Add(field.Name, field.Value)
+	}
+	return header
+}
+
+// UnmarshalJSON reads a list of name and value objects, or an object of
+// names to values as written before repeated headers were kept, so older
+// capture files still load.
+func (h *Headers) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var m map[string]string
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+		*h = HeadersFromMap(m)
+		return nil
+	}
+	var list []Header
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*h = list
+	return nil
+}
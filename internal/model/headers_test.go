@@ -0,0 +1,54 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestHeadersFrom(t *testing.T) {
+	headers := HeadersFrom(http.Header{
+		"X-Tag":        {"b", "a"},
+		"Content-Type": {"application/json"},
+	})
+	want := Headers{{"Content-Type", "application/json"}, {"X-Tag", "b"}, {"X-Tag", "a"}}
+	if !slices.Equal(headers, want) {
+		t.Fatalf("expected names sorted and values in order, got %v", headers)
+	}
+	if headers.Get("x-tag") != "b" || !slices.Equal(headers.Values("X-TAG"), []string{"b", "a"}) {
+		t.Fatalf("expected lookups to ignore case, got %q %q", headers.Get("x-tag"), headers.Values("X-TAG"))
+	}
+	if back := headers.Header(); !slices.Equal(back["X-Tag"], []string{"b", "a"}) {
+		t.Fatalf("unexpected http.Header %v", back)
+	}
+}
+
+func TestHeadersJSON(t *testing.T) {
+	data, err := json.Marshal(Headers{{"Set-Cookie", "a=1"}, {"Set-Cookie", "b=2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[{"name":"Set-Cookie","value":"a=1"},{"name":"Set-Cookie","value":"b=2"}]` {
+		t.Fatalf("unexpected encoding %s", data)
+	}
+
+	var entry RequestLog
+	if err := json.Unmarshal([]byte(`{"headers":{"X-B":"2","X-A":"1, 2"},"response":{"headers":`+string(data)+`}}`), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(entry.Headers, Headers{{"X-A", "1, 2"}, {"X-B", "2"}}) {
+		t.Fatalf("expected headers written as an object to load sorted, got %v", entry.Headers)
+	}
+	if len(entry.Response.Headers.Values("Set-Cookie")) != 2 {
+		t.Fatalf("expected both cookies, got %v", entry.Response.Headers)
+	}
+
+	var edit ReplayRequest
+	if err := json.Unmarshal([]byte(`{"headers":null}`), &edit); err != nil || edit.Headers != nil {
+		t.Fatalf("expected null headers to stay nil, got %v %v", edit.Headers, err)
+	}
+	if err := json.Unmarshal([]byte(`{"headers":[]}`), &edit); err != nil || edit.Headers == nil {
+		t.Fatalf("expected an empty list to clear the headers, got %v %v", edit.Headers, err)
+	}
+}
@@ -1,25 +1,370 @@
 package model
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// ErrRequestNotFound is returned when a request ID is no longer in the
+// request history.
+var ErrRequestNotFound = errors.New("request not found")
+
 // RequestLog represents a logged HTTP request
 type RequestLog struct {
-	ID          string            `json:"id"`
-	Timestamp   time.Time         `json:"timestamp"`
-	Method      string            `json:"method"`
-	URL         string            `json:"url"`
-	RemoteAddr  string            `json:"remote_addr"`
-	Headers     map[string]string `json:"headers"`
-	Body        string            `json:"body,omitempty"`
-	Response    ResponseLog       `json:"response"`
-	Duration    time.Duration     `json:"duration"`
-	UserAgent   string            `json:"user_agent"`
-	ContentType string            `json:"content_type"`
-	Size        int64             `json:"size"`
-	StatusCode  int               `json:"status_code"` // Convenience field for UI
+	ID          string          `json:"id"`
+	Timestamp   time.Time       `json:"timestamp"`
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	RemoteAddr  string          `json:"remote_addr"`
+	Headers     Headers         `json:"headers"`
+	Body        string          `json:"body,omitempty"`
+	Multipart   []MultipartPart `json:"multipart,omitempty"`
+	Response    ResponseLog     `json:"response"`
+	Duration    time.Duration   `json:"duration"`
+	Timing      *Timing         `json:"timing,omitempty"`
+	UserAgent   string          `json:"user_agent"`
+	ContentType string          `json:"content_type"`
+	Size        int64           `json:"size"`
+	StatusCode  int             `json:"status_code"` // Convenience field for UI
+	// BodyBytes holds a prefix of a binary body instead of Body, which
+	// would mangle it; BodyTruncated is set when the body was longer.
+	BodyBytes     []byte `json:"body_bytes,omitempty"`
+	BodyTruncated bool   `json:"body_truncated,omitempty"`
+	// JWT is the decoded bearer token from the Authorization header.
+	JWT *JWT `json:"jwt,omitempty"`
+	// OAuth is set on requests to the --oauth-callback path.
+	OAuth *OAuthCallback `json:"oauth,omitempty"`
+	// Imported marks entries loaded with "portal import" rather than
+	// captured by this process.
+	Imported bool `json:"imported,omitempty"`
+	// ReplayOf is the ID of the captured request this one was replayed from.
+	ReplayOf string `json:"replay_of,omitempty"`
+	// DuplicateOf is the ID of the earlier delivery this request repeats.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// Expose is the --expose node the request arrived on.
+	Expose string `json:"expose,omitempty"`
+	// BodyOmitted is set when request body capture was off; Size still
+	// reports the body's length.
+	BodyOmitted bool `json:"body_omitted,omitempty"`
+	// Identity is who sent the request, when the source address was known.
+	Identity *Identity `json:"identity,omitempty"`
+	// SchemaChanges lists how a JSON body differs from earlier deliveries
+	// to the same endpoint.
+	SchemaChanges []SchemaChange `json:"schema_changes,omitempty"`
+	// Chaos lists the IDs of the chaos rules that delayed or failed the
+	// request.
+	Chaos []string `json:"chaos,omitempty"`
+	// Scanner says why a Funnel request looks like internet scanner
+	// traffic, such as "path /.env", or is "banned" when it was refused
+	// because its source was.
+	Scanner string `json:"scanner,omitempty"`
+	// GeoIP is where a public client address is, from --geoip-db.
+	GeoIP *GeoIP `json:"geoip,omitempty"`
+	// Trailers are the fields a chunked request sent after its body.
+	Trailers Headers `json:"trailers,omitempty"`
+}
+
+// GeoIP is the country and network of a client address. Country is an ISO
+// 3166-1 code such as "DE"; ASN and ASOrg name the autonomous system.
+type GeoIP struct {
+	Country     string `json:"country,omitempty"`
+	CountryName string `json:"country_name,omitempty"`
+	ASN         uint32 `json:"asn,omitempty"`
+	ASOrg       string `json:"as_org,omitempty"`
+}
+
+// Label describes the location for display, such as
+// "Germany, AS20712 Andrews & Arnold Ltd".
+func (g GeoIP) Label() string {
+	var parts []string
+	if country := cmp.Or(g.CountryName, g.Country); country != "" {
+		parts = append(parts, country)
+	}
+	if g.ASN != 0 {
+		parts = append(parts, strings.TrimSpace(fmt.Sprintf("AS%d %s", g.ASN, g.ASOrg)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SchemaChange is how a JSON payload differs from the schema inferred from
+// earlier deliveries to the same endpoint. Field is a path such as
+// $.data.items[].sku; Change is SchemaAdded, SchemaRemoved or SchemaRetyped,
+// and Was and Now name the JSON types involved.
+type SchemaChange struct {
+	Field  string `json:"field"`
+	Change string `json:"change"`
+	Was    string `json:"was,omitempty"`
+	Now    string `json:"now,omitempty"`
+}
+
+// Schema change kinds. Removed and retyped fields are breaking changes.
+const (
+	SchemaAdded   = "added"
+	SchemaRemoved = "removed"
+	SchemaRetyped = "type"
+)
+
+// EndpointSchema is the payload schema inferred for one endpoint from the
+// JSON request bodies it received.
+type EndpointSchema struct {
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	Deliveries int           `json:"deliveries"`
+	Fields     []SchemaField `json:"fields"`
+	// Changed counts the deliveries that deviated from the schema before
+	// them; LastChange is the latest such delivery's time and changes.
+	Changed    int            `json:"changed"`
+	LastChange *time.Time     `json:"last_change,omitempty"`
+	Changes    []SchemaChange `json:"changes,omitempty"`
+}
+
+// SchemaField is one field of an EndpointSchema: the JSON types seen for it
+// and in how many deliveries it was present. Fields present in every
+// delivery are required.
+type SchemaField struct {
+	Path     string   `json:"path"`
+	Types    []string `json:"types"`
+	Seen     int      `json:"seen"`
+	Required bool     `json:"required"`
+}
+
+// Identity is the client behind a request: the tailnet user and device
+// Tailscale reports for its address, or only the address for Funnel clients
+// and lookups that failed.
+type Identity struct {
+	Address string   `json:"address"`
+	Login   string   `json:"login,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Device  string   `json:"device,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Funnel  bool     `json:"funnel,omitempty"`
+}
+
+// Key identifies the client across requests: the user when Tailscale named
+// one, otherwise the device or address.
+func (i Identity) Key() string {
+	switch {
+	case i.Login != "":
+		return "user:" + i.Login
+	case i.Device != "":
+		return "device:" + i.Device
+	}
+	return "addr:" + i.Address
+}
+
+// Label is a short description of the client for display.
+func (i Identity) Label() string {
+	label := i.Address
+	switch {
+	case i.Login != "":
+		label = i.Login
+	case len(i.Tags) > 0:
+		label = strings.Join(i.Tags, ",")
+	}
+	if i.Device != "" && i.Device != label {
+		label += " (" + i.Device + ")"
+	}
+	if i.Funnel {
+		label += " via Funnel"
+	}
+	return label
+}
+
+// Presence is a client seen during the session, with how many requests it
+// sent and when.
+type Presence struct {
+	Identity  Identity  `json:"identity"`
+	Requests  int       `json:"requests"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Annotation is one web UI viewer's triage marks on a captured request: a
+// note, tags and a star. An annotation with none of them is removed.
+type Annotation struct {
+	RequestID string    `json:"request_id"`
+	Author    Identity  `json:"author"`
+	Note      string    `json:"note,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Starred   bool      `json:"starred,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Empty reports whether the annotation marks nothing.
+func (a Annotation) Empty() bool {
+	return a.Note == "" && len(a.Tags) == 0 && !a.Starred
+}
+
+// QuotaUsage is how much of a request quota one client has used in the
+// current window, which ends at ResetsAt.
+type QuotaUsage struct {
+	Client    string    `json:"client"`
+	Match     string    `json:"match"`
+	Limit     int       `json:"limit"`
+	Period    string    `json:"period"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// ConnectionStats describes the client connections the proxy accepted, as
+// opposed to the requests sent over them. RequestsPerConnection buckets
+// closed connections by how many requests they carried ("0", "1", "2-5",
+// "6-20" and "21+"); Reused counts those that carried more than one.
+// Lifetimes are in milliseconds, and LongestOpenMs is the age of the oldest
+// connection still open, such as a long-lived stream.
+type ConnectionStats struct {
+	Accepted              int            `json:"accepted"`
+	Open                  int            `json:"open"`
+	Idle                  int            `json:"idle"`
+	Closed                int            `json:"closed"`
+	Hijacked              int            `json:"hijacked"`
+	Reused                int            `json:"reused"`
+	Requests              int64          `json:"requests"`
+	RequestsPerConnection map[string]int `json:"requests_per_connection"`
+	AvgRequests           float64        `json:"avg_requests_per_connection"`
+	AvgLifetimeMs         float64        `json:"avg_lifetime_ms"`
+	MaxLifetimeMs         float64        `json:"max_lifetime_ms"`
+	LongestOpenMs         float64        `json:"longest_open_ms"`
+}
+
+// Serve change actions.
+const (
+	ServeChangeAdd     = "add"
+	ServeChangeRemove  = "remove"
+	ServeChangeReplace = "replace"
+)
+
+// ServeChange is one change to a node's serve config or prefs. Kind is
+// "web", "tcp", "funnel" or "advertise"; Target is where it applies, such as
+// "dev-box.example.ts.net:443/api", and Handler what serves it.
+type ServeChange struct {
+	Action   string `json:"action"`
+	Kind     string `json:"kind"`
+	Target   string `json:"target"`
+	Handler  string `json:"handler,omitempty"`
+	Previous string `json:"previous,omitempty"`
+}
+
+// ServePlan is what a session would do to the local Tailscale daemon,
+// reported by --dry-run instead of doing it. The local ports are picked
+// afresh on every start.
+type ServePlan struct {
+	DNSName    string        `json:"dns_name"`
+	ServiceURL string        `json:"service_url"`
+	WebUIURL   string        `json:"web_ui_url,omitempty"`
+	ServePort  int           `json:"serve_port"`
+	MountPath  string        `json:"mount_path"`
+	ProxyPort  int           `json:"proxy_port"`
+	UIPort     int           `json:"ui_port,omitempty"`
+	Funnel     bool          `json:"funnel"`
+	FunnelURLs []string      `json:"funnel_urls,omitempty"`
+	Changes    []ServeChange `json:"changes"`
+}
+
+// JWT signature states.
+const (
+	JWTUnverified = "unverified"
+	JWTValid      = "valid"
+	JWTInvalid    = "invalid"
+)
+
+// JWT is a bearer token decoded for display. Signature is JWTUnverified
+// unless a JWKS URL was configured; SignatureError explains JWTInvalid.
+type JWT struct {
+	Header         map[string]any `json:"header"`
+	Claims         map[string]any `json:"claims"`
+	ExpiresAt      *time.Time     `json:"expires_at,omitempty"`
+	Expired        bool           `json:"expired,omitempty"`
+	Signature      string         `json:"signature"`
+	SignatureError string         `json:"signature_error,omitempty"`
+}
+
+// OAuthCallback is an OAuth 2.0 authorization redirect captured on the
+// callback path: the code and state, or the error the provider returned.
+type OAuthCallback struct {
+	Code             string      `json:"code,omitempty"`
+	State            string      `json:"state,omitempty"`
+	Error            string      `json:"error,omitempty"`
+	ErrorDescription string      `json:"error_description,omitempty"`
+	Token            *OAuthToken `json:"token,omitempty"`
+}
+
+// OAuthToken is the token endpoint's answer when the code was exchanged.
+// Response holds the decoded JSON body, or Body the raw one when it wasn't
+// JSON; Error is set when the exchange couldn't be made at all.
+type OAuthToken struct {
+	StatusCode int            `json:"status_code,omitempty"`
+	Response   map[string]any `json:"response,omitempty"`
+	Body       string         `json:"body,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// ReplayRequest is an edited copy of a captured request to send again.
+// Empty Method and URL, nil Headers and a nil Body keep the captured values.
+type ReplayRequest struct {
+	Method  string  `json:"method,omitempty"`
+	URL     string  `json:"url,omitempty"`
+	Headers Headers `json:"headers,omitempty"`
+	Body    *string `json:"body,omitempty"`
+}
+
+// SequenceReplay selects captured requests to send again in their original
+// order.
+type SequenceReplay struct {
+	IDs []string `json:"ids"`
+	// Rate sends a fixed number of requests per second. Zero keeps the
+	// original gaps between requests.
+	Rate float64 `json:"rate,omitempty"`
+}
+
+// ReplayOutcome reports the result of one request in a sequence replay.
+type ReplayOutcome struct {
+	ID         string        `json:"id"`
+	ReplayID   string        `json:"replay_id,omitempty"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Duration   time.Duration `json:"duration,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Timing splits a proxied request's duration into upstream phases. Whatever
+// Duration is left over was spent in portal and the tunnel, reading the
+// request from the client.
+type Timing struct {
+	// Connect is the time to obtain an upstream connection; near zero when
+	// a keep-alive connection was reused.
+	Connect time.Duration `json:"connect"`
+	// TTFB is from having a connection to the first response byte: writing
+	// the request and the upstream's processing time.
+	TTFB time.Duration `json:"ttfb"`
+	// Transfer is from the first response byte to the body being fully
+	// copied to the client, including pushing it through the tunnel.
+	Transfer   time.Duration `json:"transfer"`
+	ConnReused bool          `json:"conn_reused,omitempty"`
+}
+
+// SensitiveRequest describes a request to a path marked sensitive, as shown
+// to the operator for approval.
+type SensitiveRequest struct {
+	ID         string `json:"id"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	RemoteAddr string `json:"remote_addr"`
+	Pattern    string `json:"pattern"`
+}
+
+// MultipartPart summarizes one part of a multipart/form-data request. File
+// contents are not kept; plain form fields keep a short Value.
+type MultipartPart struct {
+	Name        string `json:"name"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"`
+	Value       string `json:"value,omitempty"`
 }
 
 // EndpointState represents startup/endpoint reachability details for TUI.
@@ -33,21 +378,84 @@ type EndpointState struct {
 	WebUIStatus string `json:"web_ui_status"`
 	WebUIURL    string `json:"web_ui_url,omitempty"`
 	WebUIReason string `json:"web_ui_reason,omitempty"`
+
+	// Upstream is set when portal was started before its target and reports
+	// whether the target port is accepting connections yet.
+	Upstream string `json:"upstream,omitempty"`
+
+	// Cert reports the HTTPS certificate being fetched ahead of the first
+	// request when serving over HTTPS.
+	Cert string `json:"cert,omitempty"`
+
+	// Mounts lists path prefixes routed to other upstreams, formatted as
+	// "/api → localhost:3000/api, /docs → localhost:4000".
+	Mounts string `json:"mounts,omitempty"`
+
+	// Exposes lists the extra tsnet nodes and their targets, with each
+	// node's URL once it is serving.
+	Exposes string `json:"exposes,omitempty"`
+}
+
+// ServerState is a snapshot of what a proxy server is doing: its mode, where
+// requests go, how it is reached and what it captures.
+type ServerState struct {
+	Mode string `json:"mode"`
+	// TargetURL is where requests are forwarded; empty in mock mode and
+	// for mount-only configurations.
+	TargetURL string        `json:"target_url,omitempty"`
+	Version   string        `json:"version,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	Endpoint  EndpointState `json:"endpoint"`
+	Capture   CaptureState  `json:"capture"`
+}
+
+// CaptureState reports whether new requests are kept in the history. While
+// paused, requests are still proxied and counted in the stats, and Skipped
+// counts those left out since the pause began. RequestBodies and
+// ResponseBodies report whether bodies are kept for each direction.
+type CaptureState struct {
+	Paused         bool  `json:"paused"`
+	Skipped        int64 `json:"skipped"`
+	RequestBodies  bool  `json:"request_bodies"`
+	ResponseBodies bool  `json:"response_bodies"`
 }
 
 const (
 	EndpointReadinessStarting = "starting"
 	EndpointReadinessReady    = "ready"
 	EndpointReadinessFailed   = "failed"
+
+	UpstreamWaiting     = "waiting"
+	UpstreamReachable   = "reachable"
+	UpstreamUnreachable = "unreachable"
+
+	CertProvisioning = "provisioning"
+	CertReady        = "ready"
+	CertFailed       = "failed"
 )
 
 // ResponseLog represents the response part of a logged request
 type ResponseLog struct {
-	StatusCode    int               `json:"status_code"`
-	Headers       map[string]string `json:"headers"`
-	Body          string            `json:"body,omitempty"`
-	BodyTruncated bool              `json:"body_truncated,omitempty"`
-	Size          int64             `json:"size"`
+	StatusCode    int     `json:"status_code"`
+	Headers       Headers `json:"headers"`
+	Body          string  `json:"body,omitempty"`
+	BodyTruncated bool    `json:"body_truncated,omitempty"`
+	Size          int64   `json:"size"`
+	// BodyBytes holds a prefix of a binary body instead of Body.
+	BodyBytes []byte `json:"body_bytes,omitempty"`
+	// BodyOmitted is set when response body capture was off.
+	BodyOmitted bool `json:"body_omitted,omitempty"`
+	// Informational lists the 1xx responses, such as 103 Early Hints,
+	// sent before the final one.
+	Informational []Informational `json:"informational,omitempty"`
+	// Trailers are the fields sent after the body.
+	Trailers Headers `json:"trailers,omitempty"`
+}
+
+// Informational is an interim 1xx response.
+type Informational struct {
+	StatusCode int     `json:"status_code"`
+	Headers    Headers `json:"headers,omitempty"`
 }
 
 // Config holds the main application configuration
@@ -0,0 +1,153 @@
+// internal/notify/notify.go
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/config"
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/startup"
+)
+
+// errorCooldown is the minimum gap between upstream 5xx notifications, so a
+// failing endpoint doesn't raise one per request.
+const errorCooldown = 30 * time.Second
+
+// Sender shows a notification with a title and message.
+type Sender func(ctx context.Context, title, message string) error
+
+// Notifier raises desktop notifications for the events it was configured
+// with. A nil Notifier does nothing, so callers needn't check whether
+// notifications are enabled.
+type Notifier struct {
+	events map[string]bool
+	send   Sender
+	logger *zap.Logger
+	now    func() time.Time
+
+	mu           sync.Mutex
+	seenRequest  bool
+	upstreamDown bool
+	lastError    time.Time
+}
+
+// New returns a notifier for events that shows notifications with send, or
+// nil when events is empty.
+func New(events []string, send Sender, logger *zap.Logger) *Notifier {
+	if len(events) == 0 {
+		return nil
+	}
+	n := &Notifier{events: make(map[string]bool), send: send, logger: logger, now: time.Now}
+	for _, event := range events {
+		n.events[event] = true
+	}
+	return n
+}
+
+// Ready notifies that the service URL is reachable.
+func (n *Notifier) Ready(summary startup.Summary) {
+	if n == nil || !n.events[config.NotifyReady] || !summary.IsReady() {
+		return
+	}
+	title := "portal is ready"
+	if summary.Exposure == startup.ExposureFunnel {
+		title = "portal Funnel URL is ready"
+	}
+	n.notify(title, summary.ServiceURL)
+}
+
+// Request notifies about the first request, upstream 5xx responses and the
+// upstream going down. A proxied request without an upstream response
+// timing failed to reach the upstream. Its signature matches
+// proxy.Server.AddListener.
+func (n *Notifier) Request(entry model.RequestLog) {
+	if n == nil {
+		return
+	}
+	line := fmt.Sprintf("%s %s", entry.Method, entry.URL)
+
+	n.mu.Lock()
+	first := !n.seenRequest
+	n.seenRequest = true
+
+	reached := entry.Timing != nil
+	wentDown := !reached && entry.StatusCode == http.StatusBadGateway && !n.upstreamDown
+	if wentDown {
+		n.upstreamDown = true
+	} else if reached {
+		n.upstreamDown = false
+	}
+
+	serverError := reached && entry.StatusCode >= 500 && n.now().Sub(n.lastError) >= errorCooldown
+	if serverError && n.events[config.NotifyUpstream5xx] {
+		n.lastError = n.now()
+	}
+	n.mu.Unlock()
+
+	if first && n.events[config.NotifyFirstRequest] {
+		n.notify("portal received its first request", line)
+	}
+	if wentDown && n.events[config.NotifyUpstreamDown] {
+		n.notify("portal upstream is down", line+" could not reach the upstream")
+	}
+	if serverError && n.events[config.NotifyUpstream5xx] {
+		n.notify(fmt.Sprintf("Upstream returned %d", entry.StatusCode), line)
+	}
+}
+
+// notify sends in the background so request handling never waits on the
+// desktop.
+func (n *Notifier) notify(title, message string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := n.send(ctx, title, message); err != nil {
+			n.logger.Warn("Desktop notification failed",
+				logging.Component("notify"),
+				logging.Error(err),
+			)
+		}
+	}()
+}
+
+// Desktop shows notifications with the platform's notifier: osascript on
+// macOS, notify-send on Linux and a PowerShell toast on Windows.
+func Desktop(ctx context.Context, title, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			title, message)
+	case "windows":
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", windowsToast)
+		// Passing the text through the environment avoids quoting it
+		// into the script.
+		cmd.Env = append(os.Environ(), "PORTAL_NOTIFY_TITLE="+title, "PORTAL_NOTIFY_MESSAGE="+message)
+	default:
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=portal", title, message)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", cmd.Args[0], err, out)
+	}
+	return nil
+}
+
+const windowsToast = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:PORTAL_NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:PORTAL_NOTIFY_MESSAGE)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('portal').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
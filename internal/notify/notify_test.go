@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/config"
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/startup"
+)
+
+type notification struct {
+	title, message string
+}
+
+func newTestNotifier(events ...string) (*Notifier, chan notification) {
+	sent := make(chan notification, 10)
+	n := New(events, func(ctx context.Context, title, message string) error {
+		sent <- notification{title, message}
+		return nil
+	}, zap.NewNop())
+	return n, sent
+}
+
+func receive(t *testing.T, sent chan notification) notification {
+	t.Helper()
+	select {
+	case got := <-sent:
+		return got
+	case <-time.After(time.Second):
+		t.Fatalf("expected a notification")
+		return notification{}
+	}
+}
+
+func expectNone(t *testing.T, sent chan notification) {
+	t.Helper()
+	select {
+	case got := <-sent:
+		t.Fatalf("expected no notification, got %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNotifierFirstRequestOnce(t *testing.T) {
+	n, sent := newTestNotifier(config.NotifyFirstRequest)
+
+	n.Request(model.RequestLog{Method: "POST", URL: "/webhook", StatusCode: 200})
+	if got := receive(t, sent); got.title != "portal received its first request" || got.message != "POST /webhook" {
+		t.Fatalf("unexpected notification %+v", got)
+	}
+	n.Request(model.RequestLog{Method: "POST", URL: "/webhook", StatusCode: 200})
+	expectNone(t, sent)
+}
+
+func TestNotifierUpstreamDownAndErrors(t *testing.T) {
+	n, sent := newTestNotifier(config.NotifyUpstreamDown, config.NotifyUpstream5xx)
+	now := time.Now()
+	n.now = func() time.Time { return now }
+
+	down := model.RequestLog{Method: "GET", URL: "/", StatusCode: http.StatusBadGateway}
+	n.Request(down)
+	if got := receive(t, sent); got.title != "portal upstream is down" {
+		t.Fatalf("unexpected notification %+v", got)
+	}
+	n.Request(down)
+	expectNone(t, sent)
+
+	failed := model.RequestLog{Method: "GET", URL: "/", StatusCode: 500, Timing: &model.Timing{}}
+	n.Request(failed)
+	if got := receive(t, sent); got.title != "Upstream returned 500" {
+		t.Fatalf("unexpected notification %+v", got)
+	}
+	n.Request(failed)
+	expectNone(t, sent)
+
+	now = now.Add(errorCooldown)
+	n.Request(failed)
+	receive(t, sent)
+
+	// The upstream answered, so a later failure to reach it is news again.
+	n.Request(down)
+	if got := receive(t, sent); got.title != "portal upstream is down" {
+		t.Fatalf("unexpected notification %+v", got)
+	}
+}
+
+func TestNotifierReady(t *testing.T) {
+	n, sent := newTestNotifier(config.NotifyReady)
+
+	n.Ready(startup.Summary{Readiness: startup.ReadinessReady, Exposure: startup.ExposureFunnel, ServiceURL: "https://node.example.ts.net/"})
+	if got := receive(t, sent); got.title != "portal Funnel URL is ready" || got.message != "https://node.example.ts.net/" {
+		t.Fatalf("unexpected notification %+v", got)
+	}
+	n.Request(model.RequestLog{StatusCode: 200})
+	expectNone(t, sent)
+}
+
+func TestNilNotifier(t *testing.T) {
+	n := New(nil, nil, zap.NewNop())
+	if n != nil {
+		t.Fatalf("expected no notifier without events")
+	}
+	n.Ready(startup.Summary{})
+	n.Request(model.RequestLog{})
+}
@@ -0,0 +1,20 @@
+// internal/proxy/annotations.go
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/jaxxstorm/portal/internal/annotations"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// Annotations returns the web UI's shared request annotations.
+func (s *Server) Annotations() *annotations.Store {
+	return s.annotations
+}
+
+// Viewer returns who is making a web UI request, identified the same way as
+// proxied clients, so annotations can be attributed to them.
+func (s *Server) Viewer(r *http.Request) *model.Identity {
+	return s.identify(r)
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/capture"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+type discardSink struct{}
+
+func (discardSink) Write(model.RequestLog) error { return nil }
+
+func benchmarkServer(b *testing.B, sink RequestSink) *Server {
+	b.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	b.Cleanup(upstream.Close)
+
+	return NewServer(Config{
+		TargetPort:  upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:        model.ModeProxy,
+		UseTUI:      true,
+		Logger:      zap.NewNop(),
+		CaptureSink: sink,
+		Transport:   Transport{MaxIdleConnsPerHost: 64},
+	})
+}
+
+func benchmarkRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/github?delivery=1", strings.NewReader(`{"action":"opened","number":42}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GitHub-Hookshot/abc123")
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	req.Header.Set("X-Hub-Signature-256", "sha256=d57c68ca6f92289e6987922ff26938930f6e66a2d161ef06abdf1859230aa23c")
+	return req
+}
+
+func runServeHTTPBenchmark(b *testing.B, server *Server) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			server.ServeHTTP(httptest.NewRecorder(), benchmarkRequest())
+		}
+	})
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	runServeHTTPBenchmark(b, benchmarkServer(b, nil))
+}
+
+func BenchmarkServeHTTPCapture(b *testing.B) {
+	runServeHTTPBenchmark(b, benchmarkServer(b, discardSink{}))
+}
+
+func BenchmarkServeHTTPCaptureFile(b *testing.B) {
+	sink, err := capture.NewFileSink(b.TempDir()+"/capture.jsonl", 0)
+	if err != nil {
+		b.Fatalf("new sink: %v", err)
+	}
+	b.Cleanup(func() { sink.Close() })
+	runServeHTTPBenchmark(b, benchmarkServer(b, sink))
+}
+
+func BenchmarkHeadersFrom(b *testing.B) {
+	header := benchmarkRequest().Header
+	b.ReportAllocs()
+	for b.Loop() {
+		model.HeadersFrom(header)
+	}
+}
@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBinaryBodyBytes caps the raw prefix kept of a binary request or
+// response body for the hex viewers.
+const maxBinaryBodyBytes = 64 * 1024
+
+// isTextContentType reports whether contentType is shown as text even when
+// the body is not valid UTF-8.
+func isTextContentType(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.HasPrefix(contentType, "application/json") ||
+		strings.HasPrefix(contentType, "application/xml") ||
+		strings.HasPrefix(contentType, "application/javascript") ||
+		strings.HasPrefix(contentType, "application/x-www-form-urlencoded") ||
+		strings.Contains(contentType, "+json") ||
+		strings.Contains(contentType, "+xml")
+}
+
+// isBinaryBody reports whether body should be kept as raw bytes rather than
+// converted to a string, which would mangle it.
+func isBinaryBody(contentType string, body []byte) bool {
+	return len(body) > 0 && !isTextContentType(contentType) && !utf8.Valid(body)
+}
+
+// binaryPrefix returns a copy of at most maxBinaryBodyBytes of body and
+// whether it was cut short.
+func binaryPrefix(body []byte) ([]byte, bool) {
+	if len(body) > maxBinaryBodyBytes {
+		return append([]byte(nil), body[:maxBinaryBodyBytes]...), true
+	}
+	return append([]byte(nil), body...), false
+}
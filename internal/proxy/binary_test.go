@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPKeepsBinaryBodiesAsBytes(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0xff, 0x00}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+
+	upload := append(bytes.Repeat([]byte{0xfe, 0x01}, maxBinaryBodyBytes/2), 0xff)
+	req := httptest.NewRequest(http.MethodPut, "/blob", bytes.NewReader(upload))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 log, got %d", len(logs))
+	}
+	entry := logs[0]
+	if entry.Body != "" || len(entry.BodyBytes) != maxBinaryBodyBytes || !entry.BodyTruncated {
+		t.Fatalf("expected a truncated raw request prefix, got body=%d bytes=%d truncated=%t", len(entry.Body), len(entry.BodyBytes), entry.BodyTruncated)
+	}
+	if entry.Response.Body != "" || !bytes.Equal(entry.Response.BodyBytes, png) || entry.Response.BodyTruncated {
+		t.Fatalf("expected raw response bytes, got body=%q bytes=%x", entry.Response.Body, entry.Response.BodyBytes)
+	}
+}
+
+func TestIsBinaryBody(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		body        []byte
+		want        bool
+	}{
+		{"application/octet-stream", []byte{0xff, 0xfe}, true},
+		{"application/octet-stream", []byte("plain"), false},
+		{"text/plain; charset=latin1", []byte{0xe9}, false},
+		{"", nil, false},
+	} {
+		if got := isBinaryBody(tc.contentType, tc.body); got != tc.want {
+			t.Fatalf("isBinaryBody(%q, %x) = %t, want %t", tc.contentType, tc.body, got, tc.want)
+		}
+	}
+}
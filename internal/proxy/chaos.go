@@ -0,0 +1,54 @@
+// internal/proxy/chaos.go
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/chaos"
+	"github.com/jaxxstorm/portal/internal/logging"
+)
+
+// chaosRuleHeader names the chaos rules that failed a response, like
+// X-portal-mock-rule does for mock rules.
+const chaosRuleHeader = "X-portal-chaos-rule"
+
+// Chaos returns the chaos rules, or nil when none were configured.
+func (s *Server) Chaos() *chaos.Engine {
+	return s.chaos
+}
+
+// injectFault applies what the chaos rules chose for r: it waits out the
+// added latency and, when a rule fails the request, answers with its status
+// instead. It reports whether the request should still be served.
+func (s *Server) injectFault(w http.ResponseWriter, r *http.Request, fault chaos.Fault) bool {
+	if !fault.Injected() {
+		return true
+	}
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return false
+		}
+	}
+	if fault.Status == 0 {
+		return true
+	}
+	rules := strings.Join(fault.Rules, ",")
+	s.log().Debug("Injected chaos failure",
+		logging.Component("proxy_server"),
+		zap.String("path", r.URL.Path),
+		zap.String("rules", rules),
+		zap.Int("status_code", fault.Status),
+	)
+	w.Header().Set(chaosRuleHeader, rules)
+	http.Error(w, fmt.Sprintf("%d %s (injected by portal chaos rule %s)", fault.Status, http.StatusText(fault.Status), fault.Rules[len(fault.Rules)-1]), fault.Status)
+	return false
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/chaos"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPInjectsChaos(t *testing.T) {
+	var upstreamHits atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits.Add(1)
+	}))
+	defer upstream.Close()
+
+	var rules []chaos.Rule
+	for _, expr := range []string{"POST /payments 502", "header:X-Debug=slow +50ms"} {
+		rule, err := chaos.ParseRule(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Chaos:      chaos.NewEngine(rules),
+	})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/payments/charge", nil))
+	if rr.Code != http.StatusBadGateway || rr.Header().Get(chaosRuleHeader) != "chaos_1" || upstreamHits.Load() != 0 {
+		t.Fatalf("expected an injected 502 without reaching the upstream, got %d %q after %d upstream hits", rr.Code, rr.Header().Get(chaosRuleHeader), upstreamHits.Load())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/payments", nil)
+	req.Header.Set("X-Debug", "slow")
+	rr = httptest.NewRecorder()
+	start := time.Now()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || time.Since(start) < 50*time.Millisecond || upstreamHits.Load() != 1 {
+		t.Fatalf("expected a delayed 200 from the upstream, got %d after %s", rr.Code, time.Since(start))
+	}
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/payments", nil))
+	if rr.Code != http.StatusOK || upstreamHits.Load() != 2 {
+		t.Fatalf("expected an unmatched request through untouched, got %d", rr.Code)
+	}
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 3 || len(logs[0].Chaos) != 1 || logs[0].Chaos[0] != "chaos_1" || len(logs[1].Chaos) != 1 || logs[1].Chaos[0] != "chaos_2" || logs[2].Chaos != nil {
+		t.Fatalf("expected captured requests to name the rules that fired, got %+v", logs)
+	}
+}
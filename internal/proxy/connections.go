@@ -0,0 +1,177 @@
+// internal/proxy/connections.go
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// connectionTracker keeps connection-level metrics, separate from the
+// request stats: how long client connections live and how many requests
+// each carries, to diagnose keep-alive behaviour.
+type connectionTracker struct {
+	mu       sync.Mutex
+	open     map[net.Conn]*connRecord
+	accepted int
+	closed   int
+	hijacked int
+	reused   int
+	requests int64
+	buckets  map[string]int
+	lifetime time.Duration
+	longest  time.Duration
+}
+
+type connRecordKey struct{}
+
+// connRecord is one open connection.
+type connRecord struct {
+	opened   time.Time
+	idle     bool
+	requests atomic.Int64
+}
+
+// trackConnections records the connections srv accepts. Existing
+// ConnContext and ConnState hooks are kept.
+func (s *Server) trackConnections(srv *http.Server) {
+	tracker := &s.connections
+	previousContext := srv.ConnContext
+	srv.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+		if previousContext != nil {
+			ctx = previousContext(ctx, conn)
+		}
+		record := &connRecord{opened: time.Now()}
+		tracker.mu.Lock()
+		if tracker.open == nil {
+			tracker.open = make(map[net.Conn]*connRecord)
+		}
+		tracker.open[conn] = record
+		tracker.accepted++
+		tracker.mu.Unlock()
+		return context.WithValue(ctx, connRecordKey{}, record)
+	}
+	previousState := srv.ConnState
+	srv.ConnState = func(conn net.Conn, state http.ConnState) {
+		tracker.changed(conn, state)
+		if previousState != nil {
+			previousState(conn, state)
+		}
+	}
+}
+
+func (t *connectionTracker) changed(conn net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	record := t.open[conn]
+	if record == nil {
+		return
+	}
+	switch state {
+	case http.StateActive:
+		record.idle = false
+	case http.StateIdle:
+		record.idle = true
+	case http.StateHijacked, http.StateClosed:
+		delete(t.open, conn)
+		if state == http.StateHijacked {
+			// Upgraded connections leave net/http; their stream's length
+			// is the request's duration.
+			t.hijacked++
+			return
+		}
+		lifetime := time.Since(record.opened)
+		requests := record.requests.Load()
+		t.closed++
+		t.lifetime += lifetime
+		t.longest = max(t.longest, lifetime)
+		if requests > 1 {
+			t.reused++
+		}
+		if t.buckets == nil {
+			t.buckets = make(map[string]int)
+		}
+		t.buckets[requestsBucket(requests)]++
+	}
+}
+
+// countConnectionRequest adds r to its connection's request count.
+func (s *Server) countConnectionRequest(r *http.Request) {
+	if record, ok := r.Context().Value(connRecordKey{}).(*connRecord); ok {
+		record.requests.Add(1)
+		s.connections.mu.Lock()
+		s.connections.requests++
+		s.connections.mu.Unlock()
+	}
+}
+
+func requestsBucket(requests int64) string {
+	switch {
+	case requests == 0:
+		return "0"
+	case requests == 1:
+		return "1"
+	case requests <= 5:
+		return "2-5"
+	case requests <= 20:
+		return "6-20"
+	}
+	return "21+"
+}
+
+// GetConnectionStats returns the connection metrics since the last reset.
+func (s *Server) GetConnectionStats() model.ConnectionStats {
+	t := &s.connections
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := model.ConnectionStats{
+		Accepted:              t.accepted,
+		Open:                  len(t.open),
+		Closed:                t.closed,
+		Hijacked:              t.hijacked,
+		Reused:                t.reused,
+		Requests:              t.requests,
+		RequestsPerConnection: make(map[string]int, len(t.buckets)),
+		MaxLifetimeMs:         durationMs(t.longest),
+	}
+	for bucket, count := range t.buckets {
+		stats.RequestsPerConnection[bucket] = count
+	}
+	if t.accepted > 0 {
+		stats.AvgRequests = float64(t.requests) / float64(t.accepted)
+	}
+	if t.closed > 0 {
+		stats.AvgLifetimeMs = durationMs(t.lifetime / time.Duration(t.closed))
+	}
+	now := time.Now()
+	for _, record := range t.open {
+		if record.idle {
+			stats.Idle++
+		}
+		stats.LongestOpenMs = max(stats.LongestOpenMs, durationMs(now.Sub(record.opened)))
+	}
+	return stats
+}
+
+// reset clears the counts. Open connections are kept, with the requests
+// they carry from now on.
+func (t *connectionTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.accepted = len(t.open)
+	t.closed, t.hijacked, t.reused, t.requests = 0, 0, 0, 0
+	t.buckets = nil
+	t.lifetime, t.longest = 0, 0
+	for _, record := range t.open {
+		record.requests.Store(0)
+	}
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
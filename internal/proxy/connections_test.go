@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestConnectionStats(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+	front := httptest.NewUnstartedServer(server)
+	server.ConfigureHTTPServer(front.Config)
+	front.Start()
+	defer front.Close()
+
+	get := func(client *http.Client) {
+		t.Helper()
+		resp, err := client.Get(front.URL + "/hook")
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	// Three requests over one kept-alive connection, then one over its own.
+	keepAlive := &http.Client{Transport: &http.Transport{}}
+	for range 3 {
+		get(keepAlive)
+	}
+	get(&http.Client{Transport: &http.Transport{DisableKeepAlives: true}})
+
+	// Connection state changes land just after the client has its response.
+	waitFor := func(what string, done func(model.ConnectionStats) bool) model.ConnectionStats {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			stats := server.GetConnectionStats()
+			if done(stats) {
+				return stats
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %s, got %+v", what, stats)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	stats := waitFor("the kept-alive connection to be open and idle", func(stats model.ConnectionStats) bool {
+		return stats.Open == 1 && stats.Idle == 1
+	})
+	if stats.Accepted != 2 || stats.Requests != 4 || stats.AvgRequests != 2 {
+		t.Fatalf("unexpected connection stats %+v", stats)
+	}
+
+	keepAlive.CloseIdleConnections()
+	stats = waitFor("both connections to close", func(stats model.ConnectionStats) bool { return stats.Closed == 2 })
+	if stats.Reused != 1 || stats.RequestsPerConnection["1"] != 1 || stats.RequestsPerConnection["2-5"] != 1 {
+		t.Fatalf("unexpected requests per connection %+v", stats)
+	}
+	if stats.MaxLifetimeMs <= 0 || stats.AvgLifetimeMs > stats.MaxLifetimeMs {
+		t.Fatalf("unexpected lifetimes %+v", stats)
+	}
+
+	server.ResetStats()
+	if stats = server.GetConnectionStats(); stats.Accepted != 0 || stats.Closed != 0 || len(stats.RequestsPerConnection) != 0 {
+		t.Fatalf("expected reset connection stats, got %+v", stats)
+	}
+}
@@ -0,0 +1,65 @@
+// internal/proxy/cookies.go
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CookieRewrite adjusts the cookies an upstream sets so they work through
+// the portal URL: apps developed on localhost often scope cookies to
+// Domain=localhost or leave out Secure, and browsers then drop them.
+type CookieRewrite struct {
+	Enabled bool
+	// SameSite, when set, replaces each cookie's SameSite attribute
+	// ("None", "Lax" or "Strict").
+	SameSite string
+}
+
+// rewriteCookies rewrites the Set-Cookie headers of resp: Domain is removed
+// so the cookie belongs to the host the client used, Secure is added when
+// the client connected over HTTPS, and SameSite is replaced when
+// configured. Other attributes are kept as sent.
+func rewriteCookies(resp *http.Response, rewrite CookieRewrite) {
+	cookies := resp.Header.Values("Set-Cookie")
+	if len(cookies) == 0 {
+		return
+	}
+	// The director recorded the client's scheme for the upstream.
+	secure := resp.Request != nil && resp.Request.Header.Get("X-Forwarded-Proto") == "https"
+	rewritten := make([]string, len(cookies))
+	for i, cookie := range cookies {
+		rewritten[i] = rewriteSetCookie(cookie, secure, rewrite.SameSite)
+	}
+	resp.Header["Set-Cookie"] = rewritten
+}
+
+func rewriteSetCookie(cookie string, secure bool, sameSite string) string {
+	parts := strings.Split(cookie, ";")
+	attributes := []string{strings.TrimSpace(parts[0])}
+	hasSecure := false
+	for _, part := range parts[1:] {
+		attribute := strings.TrimSpace(part)
+		name, _, _ := strings.Cut(attribute, "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+			continue
+		case "domain":
+			continue
+		case "samesite":
+			if sameSite != "" {
+				continue
+			}
+		case "secure":
+			hasSecure = true
+		}
+		attributes = append(attributes, attribute)
+	}
+	if secure && !hasSecure {
+		attributes = append(attributes, "Secure")
+	}
+	if sameSite != "" {
+		attributes = append(attributes, "SameSite="+sameSite)
+	}
+	return strings.Join(attributes, "; ")
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPRewriteCookies(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "session=abc; Path=/; Domain=localhost; HttpOnly; SameSite=Lax")
+		w.Header().Add("Set-Cookie", "theme=dark; domain=.localhost; Secure; Partitioned")
+	}))
+	defer upstream.Close()
+
+	newServer := func(scheme string, cookies CookieRewrite) *Server {
+		return NewServer(Config{
+			TargetPort:     upstream.Listener.Addr().(*net.TCPAddr).Port,
+			Mode:           model.ModeProxy,
+			UseTUI:         true,
+			Logger:         zap.NewNop(),
+			ExternalScheme: scheme,
+			Cookies:        cookies,
+		})
+	}
+	setCookies := func(server *Server) []string {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/login", nil))
+		return rr.Result().Header.Values("Set-Cookie")
+	}
+
+	original := []string{
+		"session=abc; Path=/; Domain=localhost; HttpOnly; SameSite=Lax",
+		"theme=dark; domain=.localhost; Secure; Partitioned",
+	}
+	if got := setCookies(newServer("https", CookieRewrite{})); !slices.Equal(got, original) {
+		t.Fatalf("expected cookies untouched by default, got %q", got)
+	}
+
+	want := []string{
+		"session=abc; Path=/; HttpOnly; Secure; SameSite=None",
+		"theme=dark; Secure; Partitioned; SameSite=None",
+	}
+	if got := setCookies(newServer("https", CookieRewrite{Enabled: true, SameSite: "None"})); !slices.Equal(got, want) {
+		t.Fatalf("expected rewritten cookies %q, got %q", want, got)
+	}
+
+	// Over plain HTTP a Secure attribute would make browsers drop the cookie.
+	want = []string{
+		"session=abc; Path=/; HttpOnly; SameSite=Lax",
+		"theme=dark; Secure; Partitioned",
+	}
+	if got := setCookies(newServer("http", CookieRewrite{Enabled: true})); !slices.Equal(got, want) {
+		t.Fatalf("expected rewritten cookies %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,56 @@
+// internal/proxy/cors.go
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/jaxxstorm/portal/internal/middleware"
+)
+
+// handleCORS applies the configured CORS policy to w and reports whether r
+// was a preflight request that has already been answered. Preflights are
+// answered here rather than forwarded, since most dev servers do not handle
+// OPTIONS.
+func (s *Server) handleCORS(w http.ResponseWriter, r *http.Request) bool {
+	cors := s.currentSettings().CORS
+	if !cors.Enabled() {
+		return false
+	}
+	if middleware.IsPreflight(r) {
+		cors.Preflight(w, r)
+		return true
+	}
+	cors.Apply(w.Header(), r.Header.Get("Origin"))
+	return false
+}
+
+// modifyResponse is the ModifyResponse hook of the reverse proxy to target,
+// served under the mount path stripPrefix.
+func (s *Server) modifyResponse(resp *http.Response, target *url.URL, stripPrefix string) error {
+	if deadline, ok := resp.Request.Context().Value(upstreamDeadlineKey{}).(*upstreamDeadline); ok {
+		deadline.gotResponse(resp)
+	}
+	settings := s.currentSettings()
+	if settings.Cookies.Enabled {
+		rewriteCookies(resp, settings.Cookies)
+	}
+	if !settings.KeepLocation {
+		s.rewriteLocation(resp, target, stripPrefix)
+	}
+	if settings.RewriteHTML {
+		if err := s.rewriteHTML(resp, target, stripPrefix); err != nil {
+			return err
+		}
+	}
+	if settings.CORS.Enabled() {
+		// The configured policy was already written to the client response;
+		// drop the upstream's values so browsers do not see duplicates.
+		resp.Header.Del("Access-Control-Allow-Origin")
+		resp.Header.Del("Access-Control-Allow-Credentials")
+	}
+	if s.recorder != nil {
+		return s.recordResponse(resp)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/middleware"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPAppliesCORSPolicy(t *testing.T) {
+	var upstreamHits int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits++
+		w.Header().Set("Access-Control-Allow-Origin", "https://stale.example")
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		CORS: middleware.CORSConfig{
+			Origins:          []string{"https://app.example"},
+			Methods:          []string{"GET", "POST"},
+			AllowCredentials: true,
+		},
+	})
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/api", nil)
+	preflight.Header.Set("Origin", "https://app.example")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	preflight.Header.Set("Access-Control-Request-Headers", "Content-Type, X-Token")
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, preflight)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 preflight, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+		t.Fatalf("expected configured methods, got %q", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, X-Token" {
+		t.Fatalf("expected requested headers to be allowed, got %q", got)
+	}
+	if upstreamHits != 0 {
+		t.Fatalf("expected preflight to be answered without the upstream")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set("Origin", "https://app.example")
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if got := rr.Header().Values("Access-Control-Allow-Origin"); len(got) != 1 || got[0] != "https://app.example" {
+		t.Fatalf("expected a single allowed origin replacing the upstream's, got %v", got)
+	}
+	if rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatalf("expected credentials to be allowed, got %v", rr.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/api", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for disallowed origin, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,143 @@
+// internal/proxy/dedupe.go
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+)
+
+// DefaultDuplicateWindow is how long a delivery is remembered when detecting
+// duplicates.
+const DefaultDuplicateWindow = 10 * time.Minute
+
+// DeliveryIDHeaders are the headers webhook providers use to identify a
+// delivery, kept the same across their retries.
+var DeliveryIDHeaders = []string{
+	"X-GitHub-Delivery",
+	"X-Gitlab-Event-UUID",
+	"X-Shopify-Webhook-Id",
+	"Svix-Id",
+	"Webhook-Id",
+	"Linear-Delivery",
+	"X-Twilio-Idempotency-Token",
+}
+
+// duplicateSweepSize is how many remembered deliveries trigger a sweep of
+// expired ones.
+const duplicateSweepSize = 4096
+
+// Duplicates detects repeated webhook deliveries: the same delivery ID
+// header, or without one, the same method, path and body within Window.
+type Duplicates struct {
+	Enabled bool
+	// Window defaults to DefaultDuplicateWindow.
+	Window time.Duration
+	// Skip answers duplicates with 200 instead of forwarding them.
+	Skip bool
+	// Headers are extra delivery ID headers checked before DeliveryIDHeaders.
+	Headers []string
+}
+
+type seenDelivery struct {
+	id string
+	at time.Time
+}
+
+type duplicateTracker struct {
+	Duplicates
+
+	mu   sync.Mutex
+	seen map[string]seenDelivery
+}
+
+// deliveryKey identifies r for duplicate detection, or returns "" when it
+// has neither a delivery ID nor a body.
+func (d *duplicateTracker) deliveryKey(r *http.Request, body []byte) string {
+	for _, name := range d.Headers {
+		if id := r.Header.Get(name); id != "" {
+			return http.CanonicalHeaderKey(name) + ":" + id
+		}
+	}
+	for _, name := range DeliveryIDHeaders {
+		if id := r.Header.Get(name); id != "" {
+			return name + ":" + id
+		}
+	}
+	if len(body) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return r.Method + " " + r.URL.Path + " " + hex.EncodeToString(sum[:])
+}
+
+// observe records the delivery and returns the ID of the request it
+// duplicates, or "" for a first delivery.
+func (d *duplicateTracker) observe(key, requestID string, now time.Time) string {
+	window := d.Window
+	if window <= 0 {
+		window = DefaultDuplicateWindow
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen == nil {
+		d.seen = make(map[string]seenDelivery)
+	}
+	if first, ok := d.seen[key]; ok && now.Sub(first.at) < window {
+		return first.id
+	}
+	if len(d.seen) >= duplicateSweepSize {
+		for k, delivery := range d.seen {
+			if now.Sub(delivery.at) >= window {
+				delete(d.seen, k)
+			}
+		}
+	}
+	d.seen[key] = seenDelivery{id: requestID, at: now}
+	return ""
+}
+
+// detectDuplicate returns the ID of the earlier request r repeats. Replays
+// are deliberate repeats and are never flagged.
+func (s *Server) detectDuplicate(r *http.Request, requestID string, body []byte) string {
+	if !s.duplicates.Enabled {
+		return ""
+	}
+	if _, replay := r.Context().Value(replayKey{}).(*replayState); replay {
+		return ""
+	}
+	key := s.duplicates.deliveryKey(r, body)
+	if key == "" {
+		return ""
+	}
+	duplicateOf := s.duplicates.observe(key, requestID, time.Now())
+	if duplicateOf != "" {
+		s.log().Info("Duplicate delivery detected",
+			logging.Component("dedupe"),
+			zap.String("request_id", requestID),
+			zap.String("duplicate_of", duplicateOf),
+			zap.Bool("skipped", s.duplicates.Skip),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+		)
+	}
+	return duplicateOf
+}
+
+// skipDuplicate answers a duplicate with 200 without forwarding it when
+// configured, reporting whether it did.
+func (s *Server) skipDuplicate(w http.ResponseWriter, duplicateOf string) bool {
+	if duplicateOf == "" || !s.duplicates.Skip {
+		return false
+	}
+	w.Header().Set("X-Portal-Duplicate-Of", duplicateOf)
+	w.WriteHeader(http.StatusOK)
+	return true
+}
@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPMarksDuplicateDeliveries(t *testing.T) {
+	upstreamHits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Duplicates: Duplicates{Enabled: true},
+	})
+
+	send := func(body, delivery string) {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+		if delivery != "" {
+			req.Header.Set("X-GitHub-Delivery", delivery)
+		}
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	send(`{"a":1}`, "d-1")
+	send(`{"a":2}`, "d-1")
+	send(`{"b":1}`, "")
+	send(`{"b":1}`, "")
+	send(`{"b":2}`, "")
+
+	logs := server.GetRequestLogs()
+	if logs[0].DuplicateOf != "" || logs[1].DuplicateOf != logs[0].ID {
+		t.Fatalf("expected delivery ID header to match, got %q and %q", logs[0].DuplicateOf, logs[1].DuplicateOf)
+	}
+	if logs[3].DuplicateOf != logs[2].ID || logs[4].DuplicateOf != "" {
+		t.Fatalf("expected identical bodies to match, got %q and %q", logs[3].DuplicateOf, logs[4].DuplicateOf)
+	}
+	if upstreamHits != 5 {
+		t.Fatalf("expected duplicates to be forwarded without --skip-duplicates, got %d upstream hits", upstreamHits)
+	}
+
+	if _, err := server.Replay(context.Background(), logs[0].ID, model.ReplayRequest{}); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replayed := server.GetRequestLogs()[5]; replayed.DuplicateOf != "" {
+		t.Fatalf("expected replays not to be flagged as duplicates")
+	}
+}
+
+func TestServeHTTPSkipsDuplicateDeliveries(t *testing.T) {
+	upstreamHits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits++
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Duplicates: Duplicates{Enabled: true, Skip: true, Headers: []string{"X-Event-Id"}},
+	})
+
+	var codes []int
+	for _, body := range []string{"one", "two"} {
+		req := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(body))
+		req.Header.Set("X-Event-Id", "evt_1")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		codes = append(codes, rr.Code)
+		if body == "two" && rr.Header().Get("X-Portal-Duplicate-Of") == "" {
+			t.Fatalf("expected duplicate response to name the original")
+		}
+	}
+	if codes[0] != http.StatusAccepted || codes[1] != http.StatusOK || upstreamHits != 1 {
+		t.Fatalf("expected duplicate to be answered without forwarding, got codes %v and %d upstream hits", codes, upstreamHits)
+	}
+}
+
+func TestDuplicateTrackerWindow(t *testing.T) {
+	tracker := &duplicateTracker{Duplicates: Duplicates{Enabled: true, Window: time.Minute}}
+	now := time.Now()
+	if got := tracker.observe("k", "req_1", now); got != "" {
+		t.Fatalf("expected first delivery, got %q", got)
+	}
+	if got := tracker.observe("k", "req_2", now.Add(30*time.Second)); got != "req_1" {
+		t.Fatalf("expected duplicate within the window, got %q", got)
+	}
+	if got := tracker.observe("k", "req_3", now.Add(2*time.Minute)); got != "" {
+		t.Fatalf("expected delivery after the window to be new, got %q", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if key := tracker.deliveryKey(req, nil); key != "" {
+		t.Fatalf("expected requests without an ID or body to be ignored, got %q", key)
+	}
+}
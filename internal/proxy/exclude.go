@@ -0,0 +1,67 @@
+// internal/proxy/exclude.go
+package proxy
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	porthttputil "github.com/jaxxstorm/portal/internal/httputil"
+)
+
+// Exclude keeps matching requests out of the request history, stats and
+// listeners; they are still served. Path is a prefix ("/healthz") or a
+// path.Match glob ("/assets/*.js"), and an empty Method matches any.
+type Exclude struct {
+	Method string
+	Path   string
+}
+
+func (e Exclude) matches(r *http.Request) bool {
+	if e.Method != "" && e.Method != r.Method {
+		return false
+	}
+	if strings.ContainsAny(e.Path, "*?[") {
+		ok, _ := path.Match(e.Path, r.URL.Path)
+		return ok
+	}
+	return porthttputil.UnderPath(r.URL.Path, e.Path)
+}
+
+// excluded reports whether r matches one of Settings.Exclude.
+func (s *Server) excluded(r *http.Request) bool {
+	for _, rule := range s.currentSettings().Exclude {
+		if rule.matches(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetUI serves handler, the web UI, under mountPath on the proxy itself,
+// for backends where tailscale serve can't mount it next to the service.
+// UI traffic never reaches the target and is never captured. Call it
+// before serving.
+func (s *Server) SetUI(mountPath string, handler http.Handler) {
+	s.uiPath, s.ui = mountPath, handler
+}
+
+// serveUI answers requests under the UI's mount path, returning false for
+// any other request. The UI sees the paths it has on a port of its own, as
+// it does behind a tailscale serve mount.
+func (s *Server) serveUI(w http.ResponseWriter, r *http.Request) bool {
+	if s.ui == nil || !porthttputil.UnderPath(r.URL.Path, s.uiPath) {
+		return false
+	}
+	// The UI is routed on the path that matched. Cleaning drops a trailing
+	// slash, which tells the UI's root from its redirect to it.
+	requestPath := porthttputil.CleanPath(r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") && requestPath != "/" {
+		requestPath += "/"
+	}
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = "/ui" + strings.TrimPrefix(requestPath, s.uiPath)
+	r2.URL.RawPath = ""
+	s.ui.ServeHTTP(w, r2)
+	return true
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPExclude(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort:  upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:        model.ModeProxy,
+		UseTUI:      true,
+		Logger:      zap.NewNop(),
+		MaxRequests: 2,
+		Exclude: []Exclude{
+			{Path: "/healthz"},
+			{Method: http.MethodGet, Path: "/assets/*.js"},
+		},
+	})
+	var uiPaths []string
+	server.SetUI("/__portal/ui", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uiPaths = append(uiPaths, r.URL.Path)
+	}))
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+		return rr
+	}
+
+	for _, target := range []string{"/healthz", "/healthz/ready", "/assets/app.js", "/__portal/ui/", "/__portal/ui/api/requests", "//__portal/ui/./api/../app.js"} {
+		if rr := serve(http.MethodGet, target); rr.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d", target, rr.Code)
+		}
+	}
+	if want := []string{"/ui/", "/ui/api/requests", "/ui/app.js"}; !slices.Equal(uiPaths, want) {
+		t.Fatalf("expected the UI to see its own paths %v, got %v", want, uiPaths)
+	}
+	if logs := server.GetRequestLogs(); len(logs) != 0 {
+		t.Fatalf("expected excluded requests to stay out of the history, got %d", len(logs))
+	}
+	if total, _, _, _, _, _ := server.GetStats(); total != 0 {
+		t.Fatalf("expected excluded requests to stay out of the stats, got %d", total)
+	}
+
+	// Only the rule's method is excluded, and excluded requests didn't use
+	// up the share's budget.
+	serve(http.MethodPost, "/assets/app.js")
+	serve(http.MethodGet, "/healthzz")
+	if logs := server.GetRequestLogs(); len(logs) != 2 {
+		t.Fatalf("expected 2 captured requests, got %d", len(logs))
+	}
+}
@@ -0,0 +1,91 @@
+// internal/proxy/expiry.go
+package proxy
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+)
+
+// Reasons a share stops serving.
+const (
+	ExpiryTime        = "expires_in"
+	ExpiryMaxRequests = "max_requests"
+)
+
+// shareBudget stops serving after a request budget is used up or the share
+// is expired by a timer. Once expired every request gets 410 Gone.
+type shareBudget struct {
+	maxRequests int64
+	served      atomic.Int64
+
+	mu       sync.Mutex
+	reason   string
+	onExpire func(reason string)
+}
+
+// SetOnExpire registers a hook run once when the share expires, e.g. to
+// shut portal down.
+func (s *Server) SetOnExpire(onExpire func(reason string)) {
+	s.budget.mu.Lock()
+	defer s.budget.mu.Unlock()
+	s.budget.onExpire = onExpire
+}
+
+// Expire stops serving requests. Only the first call has any effect.
+func (s *Server) Expire(reason string) {
+	s.budget.mu.Lock()
+	if s.budget.reason != "" {
+		s.budget.mu.Unlock()
+		return
+	}
+	s.budget.reason = reason
+	onExpire := s.budget.onExpire
+	s.budget.mu.Unlock()
+
+	total, _, _, _, _, _ := s.stats.GetStats()
+	s.log().Info("Share expired",
+		logging.Component("proxy_server"),
+		zap.String("reason", reason),
+		zap.Int("requests_served", total),
+	)
+	if onExpire != nil {
+		onExpire(reason)
+	}
+}
+
+// ExpiredReason returns why the share expired, or "" while it is serving.
+func (s *Server) ExpiredReason() string {
+	s.budget.mu.Lock()
+	defer s.budget.mu.Unlock()
+	return s.budget.reason
+}
+
+// takeRequest claims one request from the budget. It returns false, having
+// written a 410, when the share has expired or the budget is spent, and last
+// is true for the request that uses up the budget. A free request, one that
+// is excluded from the history, doesn't count against the budget.
+func (s *Server) takeRequest(w http.ResponseWriter, free bool) (ok, last bool) {
+	if s.ExpiredReason() != "" {
+		writeExpired(w)
+		return false, false
+	}
+	if free || s.budget.maxRequests <= 0 {
+		return true, false
+	}
+	n := s.budget.served.Add(1)
+	if n > s.budget.maxRequests {
+		writeExpired(w)
+		return false, false
+	}
+	return true, n == s.budget.maxRequests
+}
+
+func writeExpired(w http.ResponseWriter) {
+	w.Header().Set("Connection", "close")
+	http.Error(w, "This share has expired", http.StatusGone)
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPMaxRequestsExpiresShare(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort:     upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:           model.ModeProxy,
+		UseTUI:         true,
+		Logger:         zap.NewNop(),
+		MaxRequests:    2,
+		StatusEndpoint: true,
+	})
+	var expiredWith []string
+	server.SetOnExpire(func(reason string) { expiredWith = append(expiredWith, reason) })
+
+	codes := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		codes = append(codes, rr.Code)
+		if i == 0 && len(expiredWith) != 0 {
+			t.Fatalf("expected share to keep serving after the first request")
+		}
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusGone {
+		t.Fatalf("expected 200, 200, 410, got %v", codes)
+	}
+	if len(expiredWith) != 1 || expiredWith[0] != ExpiryMaxRequests {
+		t.Fatalf("expected one max_requests expiry, got %v", expiredWith)
+	}
+	if got := len(server.GetRequestLogs()); got != 2 {
+		t.Fatalf("expected only served requests to be logged, got %d", got)
+	}
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, StatusPath, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status endpoint to keep answering, got %d", rr.Code)
+	}
+}
+
+func TestExpireStopsServingOnce(t *testing.T) {
+	server := NewServer(Config{
+		Mode:   model.ModeMock,
+		UseTUI: true,
+		Logger: zap.NewNop(),
+	})
+	calls := 0
+	server.SetOnExpire(func(string) { calls++ })
+
+	server.Expire(ExpiryTime)
+	server.Expire(ExpiryMaxRequests)
+	if calls != 1 || server.ExpiredReason() != ExpiryTime {
+		t.Fatalf("expected a single expires_in expiry, got calls=%d reason=%q", calls, server.ExpiredReason())
+	}
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusGone {
+		t.Fatalf("expected 410 after expiry, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,89 @@
+// internal/proxy/expose.go
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	porthttputil "github.com/jaxxstorm/portal/internal/httputil"
+)
+
+// Expose proxies requests arriving on its own tsnet node to Target. Every
+// expose shares the server's capture pipeline, so its requests show up next
+// to the main target's.
+type Expose struct {
+	Name   string
+	Target *url.URL
+}
+
+// String formats the expose for display.
+func (e Expose) String() string {
+	return e.Name + " → " + e.Target.Host + e.Target.Path
+}
+
+type exposeRoute struct {
+	Expose
+	proxy *httputil.ReverseProxy
+	url   string // set once the node is serving; guarded by endpointMu
+}
+
+type exposeContextKey struct{}
+
+// buildExposes builds a reverse proxy per expose. Like mounts, exposes dial
+// their targets directly.
+func (s *Server) buildExposes(exposes []Expose) {
+	for _, expose := range exposes {
+		s.exposes = append(s.exposes, &exposeRoute{
+			Expose: expose,
+			proxy:  s.newReverseProxy(expose.Target, "", (&porthttputil.LoopbackDialer{}).DialContext),
+		})
+	}
+	s.endpoint.Exposes = s.exposeTable()
+}
+
+// ExposeHandler returns the handler for the node serving the named expose.
+func (s *Server) ExposeHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), exposeContextKey{}, name)))
+	})
+}
+
+// SetExposeURL records the URL the named expose is reachable at.
+func (s *Server) SetExposeURL(name, url string) {
+	s.endpointMu.Lock()
+	defer s.endpointMu.Unlock()
+	for _, route := range s.exposes {
+		if route.Name == name {
+			route.url = url
+		}
+	}
+	s.endpoint.Exposes = s.exposeTable()
+}
+
+// matchExpose returns the expose r arrived through, or nil.
+func (s *Server) matchExpose(r *http.Request) *exposeRoute {
+	name, _ := r.Context().Value(exposeContextKey{}).(string)
+	for _, route := range s.exposes {
+		if route.Name == name {
+			return route
+		}
+	}
+	return nil
+}
+
+// exposeTable formats the exposes for the endpoint state, with their URLs
+// once known. The caller holds endpointMu, or has not started serving.
+func (s *Server) exposeTable() string {
+	table := make([]string, 0, len(s.exposes))
+	for _, route := range s.exposes {
+		entry := route.String()
+		if route.url != "" {
+			entry += " at " + route.url
+		}
+		table = append(table, entry)
+	}
+	return strings.Join(table, ", ")
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestExposeHandlerRoutesToItsTarget(t *testing.T) {
+	main := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("main " + r.URL.RequestURI()))
+	}))
+	defer main.Close()
+	docs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("docs " + r.URL.RequestURI()))
+	}))
+	defer docs.Close()
+
+	docsURL, _ := url.Parse(docs.URL + "/site")
+	server := NewServer(Config{
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		TargetPort: main.Listener.Addr().(*net.TCPAddr).Port,
+		Exposes:    []Expose{{Name: "docs", Target: docsURL}},
+	})
+
+	rr := httptest.NewRecorder()
+	server.ExposeHandler("docs").ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/intro", nil))
+	if rr.Body.String() != "docs /site/intro" {
+		t.Fatalf("expected the expose target, got %d %q", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/intro", nil))
+	if rr.Body.String() != "main /intro" {
+		t.Fatalf("expected the main target, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 2 || logs[0].Expose != "docs" || logs[1].Expose != "" {
+		t.Fatalf("expected both requests in one history, tagged by expose, got %+v", logs)
+	}
+
+	// Replays go back through the same expose.
+	replayed, err := server.Replay(context.Background(), logs[0].ID, model.ReplayRequest{})
+	if err != nil || replayed.Expose != "docs" || replayed.StatusCode != http.StatusOK {
+		t.Fatalf("expected replay through the docs expose, got %+v %v", replayed, err)
+	}
+
+	if got := server.GetEndpointState().Exposes; !strings.HasPrefix(got, "docs → ") || strings.Contains(got, " at ") {
+		t.Fatalf("unexpected expose table %q", got)
+	}
+	server.SetExposeURL("docs", "https://docs.example.ts.net")
+	server.SetEndpointState(model.EndpointState{Readiness: model.EndpointReadinessReady})
+	if got := server.GetEndpointState().Exposes; !strings.HasSuffix(got, " at https://docs.example.ts.net") {
+		t.Fatalf("expected the expose URL to survive endpoint updates, got %q", got)
+	}
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	porthttputil "github.com/jaxxstorm/portal/internal/httputil"
+)
+
+// requestScheme is the scheme the client used to reach portal. Funnel only
+// serves HTTPS on 443, so its requests are https even when the private
+// listener is plain HTTP, as with --funnel-path.
+func (s *Server) requestScheme(r *http.Request) string {
+	switch {
+	case r.Header.Get(porthttputil.FunnelRequestHeader) != "":
+		return "https"
+	case s.externalScheme != "":
+		return s.externalScheme
+	case r.TLS != nil:
+		return "https"
+	}
+	return "http"
+}
+
+// setForwardingHeaders describes the client-facing side of the request to the
+// target. It runs in the director before the Host header is rewritten, so
+// req.Host is still the host the client used. X-Forwarded-For is appended by
+// httputil.ReverseProxy itself once the director returns, which keeps any
+// chain set by an earlier hop such as tailscale serve.
+func setForwardingHeaders(req *http.Request, scheme string, emitForwarded bool) {
+	req.Header.Set("X-Forwarded-Proto", scheme)
+	req.Header.Set("X-Forwarded-Host", req.Host)
+
+	if emitForwarded {
+		element := forwardedElement(req.RemoteAddr, req.Host, scheme)
+		if prior := req.Header.Values("Forwarded"); len(prior) > 0 {
+			element = strings.Join(prior, ", ") + ", " + element
+		}
+		req.Header.Set("Forwarded", element)
+	}
+}
+
+// forwardedElement builds one RFC 7239 forwarded-element.
+func forwardedElement(remoteAddr, host, scheme string) string {
+	node := "unknown"
+	if addr, ok := parseIPValue(remoteAddr); ok {
+		node = addr.String()
+		if addr.Is6() {
+			node = "[" + node + "]"
+		}
+	}
+
+	parts := []string{"for=" + forwardedValue(node)}
+	if host != "" {
+		parts = append(parts, "host="+forwardedValue(host))
+	}
+	parts = append(parts, "proto="+scheme)
+	return strings.Join(parts, ";")
+}
+
+// forwardedValue quotes v unless it is a valid RFC 7230 token.
+func forwardedValue(v string) string {
+	for _, c := range v {
+		if !isTokenChar(c) {
+			return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+		}
+	}
+	return v
+}
+
+func isTokenChar(c rune) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", c)
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPForwardingHeaders(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort:      upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:            model.ModeProxy,
+		UseTUI:          true,
+		Logger:          zap.NewNop(),
+		ExternalScheme:  "http",
+		ForwardedHeader: true,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://dev.example.ts.net:8080/", nil)
+	req.RemoteAddr = "[fd7a:115c:a1e0::1]:41000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("Forwarded", "for=203.0.113.7")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	if v := got.Get("X-Forwarded-Proto"); v != "http" {
+		t.Fatalf("expected X-Forwarded-Proto http for plain HTTP serve, got %q", v)
+	}
+	if v := got.Get("X-Forwarded-Host"); v != "dev.example.ts.net:8080" {
+		t.Fatalf("expected original host in X-Forwarded-Host, got %q", v)
+	}
+	if v := got.Get("X-Forwarded-For"); v != "203.0.113.7, fd7a:115c:a1e0::1" {
+		t.Fatalf("expected appended X-Forwarded-For chain, got %q", v)
+	}
+	want := `for=203.0.113.7, for="[fd7a:115c:a1e0::1]";host="dev.example.ts.net:8080";proto=http`
+	if v := got.Get("Forwarded"); v != want {
+		t.Fatalf("expected Forwarded %q, got %q", want, v)
+	}
+}
+
+func TestServeHTTPOmitsForwardedHeaderByDefault(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort:     upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:           model.ModeProxy,
+		UseTUI:         true,
+		Logger:         zap.NewNop(),
+		ExternalScheme: "https",
+	})
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got.Get("Forwarded") != "" || got.Get("X-Forwarded-Proto") != "https" {
+		t.Fatalf("unexpected forwarding headers: %v", got)
+	}
+}
+
+func TestServeHTTPFunnelRequestsForwardHTTPS(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		if r.URL.Path == "/login" {
+			http.Redirect(w, r, "http://"+r.Host+"/dashboard", http.StatusFound)
+		}
+	}))
+	defer upstream.Close()
+
+	// --funnel-path keeps the private listener on plain HTTP, but Funnel
+	// only serves HTTPS.
+	server := NewServer(Config{
+		TargetPort:      upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:            model.ModeProxy,
+		UseTUI:          true,
+		Logger:          zap.NewNop(),
+		ExternalScheme:  "http",
+		ForwardedHeader: true,
+		FunnelPaths:     []string{"/"},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://dev.example.ts.net/login", nil)
+	req.RemoteAddr = "203.0.113.7:41000"
+	req.Header.Set("Tailscale-Funnel-Request", "?1")
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if v := got.Get("X-Forwarded-Proto"); v != "https" {
+		t.Fatalf("expected X-Forwarded-Proto https for a Funnel request, got %q", v)
+	}
+	if v := got.Get("Forwarded"); !strings.HasSuffix(v, "proto=https") {
+		t.Fatalf("expected Forwarded proto=https for a Funnel request, got %q", v)
+	}
+	if v := rr.Header().Get("Location"); v != "https://dev.example.ts.net/dashboard" {
+		t.Fatalf("expected the redirect rewritten to the Funnel URL, got %q", v)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://dev.example.ts.net/", nil)
+	server.ServeHTTP(httptest.NewRecorder(), req)
+	if v := got.Get("X-Forwarded-Proto"); v != "http" {
+		t.Fatalf("expected X-Forwarded-Proto http for a tailnet request, got %q", v)
+	}
+}
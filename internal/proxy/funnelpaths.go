@@ -0,0 +1,34 @@
+// internal/proxy/funnelpaths.go
+package proxy
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	porthttputil "github.com/jaxxstorm/portal/internal/httputil"
+	"github.com/jaxxstorm/portal/internal/logging"
+)
+
+// enforceFunnelPaths rejects Funnel requests outside the configured public
+// paths. Tailscale serve only routes those paths to portal from Funnel, so
+// this is a second check in case the serve config is edited by hand.
+func (s *Server) enforceFunnelPaths(w http.ResponseWriter, r *http.Request) bool {
+	if len(s.funnelPaths) == 0 || r.Header.Get(porthttputil.FunnelRequestHeader) == "" {
+		return true
+	}
+	for _, prefix := range s.funnelPaths {
+		if porthttputil.UnderPath(r.URL.Path, prefix) {
+			return true
+		}
+	}
+
+	s.log().Warn("Funnel request denied",
+		logging.Component("funnel_paths"),
+		zap.String("deny_reason", "path_not_public"),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+	)
+	http.NotFound(w, r)
+	return false
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPFunnelPathsLimitPublicRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort:  upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:        model.ModeProxy,
+		UseTUI:      true,
+		Logger:      zap.NewNop(),
+		FunnelPaths: []string{"/webhooks"},
+	})
+
+	cases := []struct {
+		path   string
+		funnel bool
+		want   int
+	}{
+		{"/webhooks", true, http.StatusOK},
+		{"/webhooks/github", true, http.StatusOK},
+		{"/webhooksx", true, http.StatusNotFound},
+		{"/admin", true, http.StatusNotFound},
+		{"/admin", false, http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, tc.path, nil)
+		if tc.funnel {
+			req.Header.Set("Tailscale-Funnel-Request", "?1")
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("%s (funnel=%t): expected %d, got %d", tc.path, tc.funnel, tc.want, rr.Code)
+		}
+	}
+}
+
+func TestServeHTTPFunnelPathsEnforceAllowlist(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort:      upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:            model.ModeProxy,
+		UseTUI:          true,
+		Logger:          zap.NewNop(),
+		FunnelPaths:     []string{"/webhooks"},
+		FunnelAllowlist: mustPrefixes(t, "203.0.113.0/24"),
+	})
+
+	cases := []struct {
+		source string
+		funnel bool
+		want   int
+	}{
+		{"203.0.113.10", true, http.StatusOK},
+		{"198.51.100.7", true, http.StatusForbidden},
+		// Tailnet requests to the private listener aren't allowlisted.
+		{"100.64.0.5", false, http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/github", nil)
+		req.Header.Set("X-Forwarded-For", tc.source)
+		if tc.funnel {
+			req.Header.Set("Tailscale-Funnel-Request", "?1")
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("%s (funnel=%t): expected %d, got %d", tc.source, tc.funnel, tc.want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+type fakeLocator map[netip.Addr]*model.GeoIP
+
+func (f fakeLocator) Lookup(addr netip.Addr) *model.GeoIP {
+	return f[addr]
+}
+
+func TestServeHTTPEnrichesWithGeoIP(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	germany := &model.GeoIP{Country: "DE", CountryName: "Germany", ASN: 20712, ASOrg: "Andrews & Arnold Ltd"}
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		GeoIP: fakeLocator{
+			netip.MustParseAddr("81.2.69.160"): germany,
+			netip.MustParseAddr("81.2.69.161"): {ASN: 20712},
+		},
+	})
+	for _, clientIP := range []string{"81.2.69.160", "81.2.69.160", "81.2.69.161", "198.51.100.1"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Tailscale-Client-IP", clientIP)
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	logs := server.GetRequestLogs()
+	if logs[0].GeoIP == nil || *logs[0].GeoIP != *germany || logs[3].GeoIP != nil {
+		t.Fatalf("expected known clients to be located, got %+v and %+v", logs[0].GeoIP, logs[3].GeoIP)
+	}
+	if countries := server.GetCountries(); len(countries) != 1 || countries["DE"] != 2 {
+		t.Fatalf("expected two requests counted for DE, got %v", countries)
+	}
+}
@@ -0,0 +1,61 @@
+// internal/proxy/html.go
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// maxHTMLRewrite bounds the HTML documents rewritten in memory; larger ones
+// are passed through unchanged.
+const maxHTMLRewrite = 8 << 20
+
+// absoluteURL matches absolute and protocol-relative URLs in a document,
+// up to the quote, whitespace or bracket that ends them.
+var absoluteURL = regexp.MustCompile(`(?:https?:)?//[A-Za-z0-9.\-\[\]:]+[^"'\s<>()\\]*`)
+
+// rewriteHTML points absolute URLs to the target in an HTML response, such
+// as <base href> and asset links a dev server built from its own address,
+// at the URL the client used.
+func (s *Server) rewriteHTML(resp *http.Response, target *url.URL, stripPrefix string) error {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" || resp.Request == nil {
+		return nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTMLRewrite+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxHTMLRewrite {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+
+	body = absoluteURL.ReplaceAllFunc(body, func(match []byte) []byte {
+		raw := string(match)
+		if bytes.HasPrefix(match, []byte("//")) {
+			raw = "http:" + raw
+		}
+		u, err := url.Parse(raw)
+		if err != nil || !s.clientURL(u, resp.Request, target, stripPrefix) {
+			return match
+		}
+		return []byte(u.String())
+	})
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPRewriteHTML(t *testing.T) {
+	var page string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/app.json" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(page))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			_, _ = gz.Write([]byte(page))
+			return
+		}
+		_, _ = w.Write([]byte(page))
+	}))
+	defer upstream.Close()
+	port := upstream.Listener.Addr().(*net.TCPAddr).Port
+	origin := "localhost:" + strconv.Itoa(port)
+	page = `<html><head><base href="http://` + origin + `/">` +
+		`<script src="http://127.0.0.1:` + strconv.Itoa(port) + `/main.js"></script>` +
+		`<link href="//` + origin + `/app.css" rel="stylesheet">` +
+		`<script src="https://cdn.example.com/lib.js"></script></head></html>`
+
+	newServer := func(rewrite bool) *Server {
+		return NewServer(Config{
+			TargetPort:     port,
+			Mode:           model.ModeProxy,
+			UseTUI:         true,
+			Logger:         zap.NewNop(),
+			ExternalScheme: "https",
+			RewriteHTML:    rewrite,
+		})
+	}
+	get := func(server *Server, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://dev.example.ts.net"+path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get(newServer(true), "/")
+	want := `<html><head><base href="https://dev.example.ts.net/">` +
+		`<script src="https://dev.example.ts.net/main.js"></script>` +
+		`<link href="https://dev.example.ts.net/app.css" rel="stylesheet">` +
+		`<script src="https://cdn.example.com/lib.js"></script></head></html>`
+	if rr.Body.String() != want {
+		t.Fatalf("expected rewritten page\n%s\ngot\n%s", want, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Encoding") != "" || rr.Header().Get("Content-Length") != strconv.Itoa(len(want)) {
+		t.Fatalf("expected a plain body with its new length, got %v", rr.Header())
+	}
+
+	if rr := get(newServer(true), "/app.json"); rr.Body.String() != page {
+		t.Fatalf("expected non-HTML responses untouched, got %s", rr.Body.String())
+	}
+	if rr := get(newServer(false), "/"); rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected the client's encoding to pass through by default, got %v", rr.Header())
+	}
+}
@@ -0,0 +1,159 @@
+// internal/proxy/identity.go
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	porthttputil "github.com/jaxxstorm/portal/internal/httputil"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// WhoIsFunc looks up the tailnet user and device behind addr, reporting
+// false when it isn't known.
+type WhoIsFunc func(ctx context.Context, addr netip.Addr) (model.Identity, bool)
+
+const (
+	// whoIsTTL is how long a WhoIs answer is reused for an address.
+	whoIsTTL = time.Minute
+	// whoIsTimeout bounds a lookup, which delays the request it's for.
+	whoIsTimeout = 2 * time.Second
+	// maxCachedIdentities caps the WhoIs cache; it is emptied when full.
+	maxCachedIdentities = 1024
+	// MaxPresence caps the clients listed by Presence. Later clients are
+	// still identified on their requests.
+	MaxPresence = 1000
+)
+
+type cachedIdentity struct {
+	identity model.Identity
+	ok       bool
+	expires  time.Time
+}
+
+// identityTracker identifies the clients behind requests and remembers who
+// has connected during the session.
+type identityTracker struct {
+	mu       sync.Mutex
+	whoIs    WhoIsFunc
+	cache    map[netip.Addr]cachedIdentity
+	presence map[string]*model.Presence
+}
+
+// SetWhoIs sets how tailnet addresses are resolved to users and devices,
+// once Tailscale is up.
+func (s *Server) SetWhoIs(whoIs WhoIsFunc) {
+	s.identities.mu.Lock()
+	defer s.identities.mu.Unlock()
+	s.identities.whoIs = whoIs
+	s.identities.cache = nil
+}
+
+// identify returns who sent r. Behind tailscale serve the connection comes
+// from loopback and the client's address and user are in the headers it
+// sets; a tsnet node is connected to directly. The user is filled in with a
+// WhoIs lookup of the address. Funnel clients aren't on the tailnet, so
+// only their address is known. Replays come from portal itself and have no
+// identity.
+func (s *Server) identify(r *http.Request) *model.Identity {
+	if _, replay := r.Context().Value(replayKey{}).(*replayState); replay {
+		return nil
+	}
+	funnel := r.Header.Get(porthttputil.FunnelRequestHeader) != ""
+	addr, ok := parseIPValue(r.RemoteAddr)
+	if funnel || !ok || addr.IsLoopback() {
+		addr, _, ok = resolveSourceIP(r, s.preferRemoteIP)
+	}
+	if !ok {
+		return nil
+	}
+	identity := model.Identity{
+		Address: addr.String(),
+		Login:   r.Header.Get("Tailscale-User-Login"),
+		Name:    r.Header.Get("Tailscale-User-Name"),
+		Funnel:  funnel,
+	}
+	if funnel {
+		return &identity
+	}
+	if known, ok := s.lookupIdentity(r.Context(), addr); ok {
+		identity.Device, identity.Tags = known.Device, known.Tags
+		identity.Login = cmp.Or(identity.Login, known.Login)
+		identity.Name = cmp.Or(identity.Name, known.Name)
+	}
+	return &identity
+}
+
+func (s *Server) lookupIdentity(ctx context.Context, addr netip.Addr) (model.Identity, bool) {
+	t := &s.identities
+	t.mu.Lock()
+	whoIs := t.whoIs
+	cached, hit := t.cache[addr]
+	t.mu.Unlock()
+	if whoIs == nil {
+		return model.Identity{}, false
+	}
+	now := time.Now()
+	if hit && now.Before(cached.expires) {
+		return cached.identity, cached.ok
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, whoIsTimeout)
+	defer cancel()
+	identity, ok := whoIs(ctx, addr)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cache == nil || len(t.cache) >= maxCachedIdentities {
+		t.cache = make(map[netip.Addr]cachedIdentity)
+	}
+	t.cache[addr] = cachedIdentity{identity: identity, ok: ok, expires: now.Add(whoIsTTL)}
+	return identity, ok
+}
+
+// recordPresence counts a request from identity.
+func (s *Server) recordPresence(identity *model.Identity, at time.Time) {
+	if identity == nil {
+		return
+	}
+	t := &s.identities
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := identity.Key()
+	presence, ok := t.presence[key]
+	if !ok {
+		if t.presence == nil {
+			t.presence = make(map[string]*model.Presence)
+		}
+		if len(t.presence) >= MaxPresence {
+			return
+		}
+		presence = &model.Presence{Identity: *identity, FirstSeen: at}
+		t.presence[key] = presence
+	}
+	presence.Requests++
+	presence.LastSeen = at
+	// The address of a user's device can change between requests.
+	presence.Identity.Address = identity.Address
+}
+
+// Presence returns the clients that have sent requests this session, most
+// recently seen first.
+func (s *Server) Presence() []model.Presence {
+	t := &s.identities
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	presence := make([]model.Presence, 0, len(t.presence))
+	for _, entry := range t.presence {
+		presence = append(presence, *entry)
+	}
+	slices.SortFunc(presence, func(a, b model.Presence) int {
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	return presence
+}
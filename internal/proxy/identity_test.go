@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"go.uber.org/zap"
+
+	porthttputil "github.com/jaxxstorm/portal/internal/httputil"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPIdentifiesClients(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+	lookups := 0
+	server.SetWhoIs(func(ctx context.Context, addr netip.Addr) (model.Identity, bool) {
+		lookups++
+		switch addr.String() {
+		case "100.64.0.2":
+			return model.Identity{Address: addr.String(), Login: "alice@example.com", Name: "Alice", Device: "laptop"}, true
+		case "100.64.0.9":
+			return model.Identity{Address: addr.String(), Device: "ci-runner", Tags: []string{"tag:ci"}}, true
+		}
+		return model.Identity{}, false
+	})
+	serve := func(remoteAddr string, header map[string]string) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Behind tailscale serve: loopback, with the client in the headers.
+	serve("127.0.0.1:5000", map[string]string{"Tailscale-Client-IP": "100.64.0.2", "Tailscale-User-Login": "alice@example.com"})
+	serve("127.0.0.1:5001", map[string]string{"Tailscale-Client-IP": "100.64.0.2", "Tailscale-User-Login": "alice@example.com"})
+	// Connected to a tsnet node directly.
+	serve("100.64.0.9:40000", nil)
+	// Funnel clients are never looked up.
+	serve("127.0.0.1:5002", map[string]string{"Tailscale-Client-IP": "203.0.113.7", porthttputil.FunnelRequestHeader: "?1"})
+
+	if lookups != 2 {
+		t.Fatalf("expected one cached lookup per tailnet address, got %d", lookups)
+	}
+	logs := server.GetRequestLogs()
+	if len(logs) != 4 {
+		t.Fatalf("expected 4 requests, got %d", len(logs))
+	}
+	if got := logs[0].Identity; got == nil || got.Device != "laptop" || got.Name != "Alice" {
+		t.Fatalf("expected the WhoIs device merged into the serve identity, got %+v", got)
+	}
+
+	presence := server.Presence()
+	if len(presence) != 3 {
+		t.Fatalf("expected 3 clients, got %+v", presence)
+	}
+	byKey := make(map[string]model.Presence)
+	for _, p := range presence {
+		byKey[p.Identity.Key()] = p
+	}
+	if p := byKey["user:alice@example.com"]; p.Requests != 2 {
+		t.Fatalf("expected 2 requests from alice, got %+v", p)
+	}
+	if p := byKey["device:ci-runner"]; p.Requests != 1 || p.Identity.Label() != "tag:ci (ci-runner)" {
+		t.Fatalf("unexpected tagged device %+v", p)
+	}
+	if p := byKey["addr:203.0.113.7"]; !p.Identity.Funnel || p.Identity.Label() != "203.0.113.7 via Funnel" {
+		t.Fatalf("unexpected funnel client %+v", p)
+	}
+	if presence[0].Identity.Key() != "addr:203.0.113.7" {
+		t.Fatalf("expected the most recent client first, got %+v", presence[0])
+	}
+}
+
+func TestViewerAttributesAnnotations(t *testing.T) {
+	server := NewServer(Config{Mode: model.ModeProxy, Logger: zap.NewNop()})
+	server.SetWhoIs(func(ctx context.Context, addr netip.Addr) (model.Identity, bool) {
+		return model.Identity{Address: addr.String(), Login: "alice@example.com", Device: "laptop"}, true
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/annotations", nil)
+	req.RemoteAddr = "100.64.0.2:41234"
+	viewer := server.Viewer(req)
+	if viewer == nil || viewer.Login != "alice@example.com" || viewer.Device != "laptop" {
+		t.Fatalf("unexpected viewer %+v", viewer)
+	}
+
+	if _, err := server.Annotations().Put(model.Annotation{RequestID: "req_1", Author: *viewer, Starred: true}); err != nil {
+		t.Fatal(err)
+	}
+	server.ClearRequestLogs()
+	if len(server.Annotations().List()) != 0 {
+		t.Fatal("expected clearing the history to clear annotations")
+	}
+}
@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/jwt"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// decodeJWT decodes a bearer JWT from the Authorization header for display,
+// verifying its signature when a JWKS URL is configured. Opaque tokens and
+// other schemes return nil.
+func (s *Server) decodeJWT(ctx context.Context, authorization string) *model.JWT {
+	token, ok := jwt.BearerToken(authorization)
+	if !ok {
+		return nil
+	}
+	info, err := jwt.Decode(token, time.Now())
+	if err != nil {
+		return nil
+	}
+	if s.jwks != nil {
+		if err := s.jwks.Verify(ctx, token); err != nil {
+			info.Signature = model.JWTInvalid
+			info.SignatureError = err.Error()
+		} else {
+			info.Signature = model.JWTValid
+		}
+	}
+	return info
+}
@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPDecodesBearerJWT(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+
+	enc := base64.RawURLEncoding.EncodeToString
+	token := enc([]byte(`{"alg":"RS256"}`)) + "." + enc([]byte(`{"sub":"user-1","exp":1}`)) + ".c2ln"
+	for _, authorization := range []string{"Bearer " + token, "Bearer opaque", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 3 {
+		t.Fatalf("expected 3 logs, got %d", len(logs))
+	}
+	info := logs[0].JWT
+	if info == nil || info.Claims["sub"] != "user-1" || !info.Expired || info.Signature != model.JWTUnverified {
+		t.Fatalf("expected an expired, unverified JWT, got %+v", info)
+	}
+	if logs[1].JWT != nil || logs[2].JWT != nil {
+		t.Fatalf("expected no JWT for opaque or missing tokens, got %+v %+v", logs[1].JWT, logs[2].JWT)
+	}
+}
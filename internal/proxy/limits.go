@@ -0,0 +1,159 @@
+// internal/proxy/limits.go
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+)
+
+// Limit violation kinds counted in stats.
+const (
+	ViolationBodyTooLarge    = "body_too_large"
+	ViolationHeadersTooLarge = "headers_too_large"
+	ViolationTooManyHeaders  = "too_many_headers"
+	ViolationSlowClient      = "slow_client"
+)
+
+// Limits bound request sizes and how long clients may take, protecting
+// Funnel-exposed endpoints from oversized and slowloris-style requests.
+// Zero values disable a limit.
+type Limits struct {
+	MaxBodyBytes   int64
+	MaxHeaderBytes int
+	MaxHeaders     int
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+}
+
+// ConfigureHTTPServer applies the timeouts and header limit to srv and
+// counts connections dropped by the header read timeout as slow clients. It
+// also installs the connection metrics. Existing ConnContext and ConnState
+// hooks are kept.
+func (s *Server) ConfigureHTTPServer(srv *http.Server) {
+	limits := s.limits
+	srv.ReadHeaderTimeout = limits.ReadHeaderTimeout
+	srv.ReadTimeout = limits.ReadTimeout
+	srv.WriteTimeout = limits.WriteTimeout
+	srv.IdleTimeout = limits.IdleTimeout
+	if limits.MaxHeaderBytes > 0 {
+		// net/http enforces this itself with an uncounted 431, so leave
+		// headroom and let enforceRequestLimits reject and count first.
+		srv.MaxHeaderBytes = 2*limits.MaxHeaderBytes + 4096
+	}
+	s.trackConnections(srv)
+
+	if limits.ReadHeaderTimeout <= 0 {
+		return
+	}
+	var mu sync.Mutex
+	conns := make(map[net.Conn]*trackedConn)
+	previousContext := srv.ConnContext
+	srv.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+		if previousContext != nil {
+			ctx = previousContext(ctx, conn)
+		}
+		tracked := &trackedConn{opened: time.Now()}
+		mu.Lock()
+		conns[conn] = tracked
+		mu.Unlock()
+		return context.WithValue(ctx, trackedConnKey{}, tracked)
+	}
+	previousState := srv.ConnState
+	srv.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed || state == http.StateHijacked {
+			mu.Lock()
+			tracked := conns[conn]
+			delete(conns, conn)
+			mu.Unlock()
+			// A connection closed without ever delivering a complete
+			// request after the header timeout is a slow (or slowloris)
+			// client.
+			if tracked != nil && !tracked.served.Load() && time.Since(tracked.opened) >= limits.ReadHeaderTimeout {
+				s.countViolation(ViolationSlowClient, conn.RemoteAddr().String(), "")
+			}
+		}
+		if previousState != nil {
+			previousState(conn, state)
+		}
+	}
+}
+
+type trackedConnKey struct{}
+
+// trackedConn records whether a connection ever got a request to the handler.
+type trackedConn struct {
+	opened time.Time
+	served atomic.Bool
+}
+
+// markServed notes that r's connection delivered a complete request.
+func markServed(r *http.Request) {
+	if tracked, ok := r.Context().Value(trackedConnKey{}).(*trackedConn); ok {
+		tracked.served.Store(true)
+	}
+}
+
+// enforceRequestLimits rejects requests whose headers or declared body size
+// exceed the limits, and caps the body reader otherwise. It returns false
+// when a response has been written.
+func (s *Server) enforceRequestLimits(w http.ResponseWriter, r *http.Request) bool {
+	markServed(r)
+	limits := s.limits
+
+	if limits.MaxHeaders > 0 || limits.MaxHeaderBytes > 0 {
+		count, size := 0, len(r.Method)+len(r.RequestURI)
+		for name, values := range r.Header {
+			count += len(values)
+			for _, value := range values {
+				size += len(name) + len(value) + 4
+			}
+		}
+		if limits.MaxHeaders > 0 && count > limits.MaxHeaders {
+			s.countViolation(ViolationTooManyHeaders, r.RemoteAddr, r.URL.Path)
+			http.Error(w, "Request Header Fields Too Large", http.StatusRequestHeaderFieldsTooLarge)
+			return false
+		}
+		if limits.MaxHeaderBytes > 0 && size > limits.MaxHeaderBytes {
+			s.countViolation(ViolationHeadersTooLarge, r.RemoteAddr, r.URL.Path)
+			http.Error(w, "Request Header Fields Too Large", http.StatusRequestHeaderFieldsTooLarge)
+			return false
+		}
+	}
+
+	if limits.MaxBodyBytes > 0 && r.Body != nil {
+		if r.ContentLength > limits.MaxBodyBytes {
+			s.countViolation(ViolationBodyTooLarge, r.RemoteAddr, r.URL.Path)
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return false
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limits.MaxBodyBytes)
+	}
+	return true
+}
+
+func (s *Server) countViolation(kind, remoteAddr, path string) {
+	s.stats.AddViolation(kind)
+	s.log().Warn("Request limit exceeded",
+		logging.Component("request_limits"),
+		zap.String("violation", kind),
+		zap.String("remote_addr", remoteAddr),
+		zap.String("path", path),
+	)
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
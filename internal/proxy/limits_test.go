@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func newLimitedServer(t *testing.T, limits Limits) *Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(upstream.Close)
+
+	return NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Limits:     limits,
+	})
+}
+
+func TestServeHTTPRejectsLargeBodies(t *testing.T) {
+	server := newLimitedServer(t, Limits{MaxBodyBytes: 16})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 32))))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for declared length, got %d", rr.Code)
+	}
+
+	// Chunked bodies have no declared length and are cut off while reading.
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 32)))
+	req.ContentLength = -1
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for chunked body, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("small")))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected small body to pass, got %d", rr.Code)
+	}
+
+	if got := server.GetViolations()[ViolationBodyTooLarge]; got != 2 {
+		t.Fatalf("expected 2 body violations, got %d", got)
+	}
+	if logs := server.GetRequestLogs(); len(logs) != 3 || logs[0].StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected rejected requests to be logged, got %+v", logs)
+	}
+}
+
+func TestServeHTTPRejectsOversizedHeaders(t *testing.T) {
+	server := newLimitedServer(t, Limits{MaxHeaders: 3, MaxHeaderBytes: 256})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, name := range []string{"A", "B", "C", "D"} {
+		req.Header.Set("X-"+name, "1")
+	}
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Fatalf("expected 431 for header count, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", strings.Repeat("c", 300))
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Fatalf("expected 431 for header size, got %d", rr.Code)
+	}
+
+	violations := server.GetViolations()
+	if violations[ViolationTooManyHeaders] != 1 || violations[ViolationHeadersTooLarge] != 1 {
+		t.Fatalf("unexpected violations %v", violations)
+	}
+}
+
+func TestConfigureHTTPServerCountsSlowClients(t *testing.T) {
+	server := newLimitedServer(t, Limits{ReadHeaderTimeout: 50 * time.Millisecond})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	httpServer := &http.Server{Handler: server}
+	server.ConfigureHTTPServer(httpServer)
+	go httpServer.Serve(listener)
+	defer httpServer.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\n"))
+
+	// The server closes the connection once the header timeout passes.
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	bufio.NewReader(conn).ReadString('\n')
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.GetViolations()[ViolationSlowClient] == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected slow client violation")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
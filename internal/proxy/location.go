@@ -0,0 +1,70 @@
+// internal/proxy/location.go
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// rewriteLocation points absolute redirects to the target back at the URL
+// the client used. Dev servers build them from the Host they see, such as
+// http://localhost:3000/login, and following one would leave the tunnel.
+func (s *Server) rewriteLocation(resp *http.Response, target *url.URL, stripPrefix string) {
+	location := resp.Header.Get("Location")
+	if location == "" || resp.Request == nil {
+		return
+	}
+	u, err := url.Parse(location)
+	if err != nil || !s.clientURL(u, resp.Request, target, stripPrefix) {
+		return
+	}
+	resp.Header.Set("Location", u.String())
+}
+
+// clientURL rewrites u, an absolute URL on the target, to the URL the
+// client used for the upstream request out. stripPrefix is the mount path
+// the target is served under, put back in front of the path. It reports
+// false, leaving u alone, when u is elsewhere.
+func (s *Server) clientURL(u *url.URL, out *http.Request, target *url.URL, stripPrefix string) bool {
+	if u.Host == "" || !targetHost(u, target, s.currentSettings().UpstreamHost) {
+		return false
+	}
+	// The director recorded what the client used for the upstream.
+	host := out.Header.Get("X-Forwarded-Host")
+	scheme := out.Header.Get("X-Forwarded-Proto")
+	if host == "" || scheme == "" {
+		return false
+	}
+	if stripPrefix != "" {
+		base := strings.TrimSuffix(target.Path, "/")
+		rest, ok := strings.CutPrefix(u.Path, base)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			// Outside the mounted path: nothing on the portal URL serves it.
+			return false
+		}
+		u.Path = strings.TrimSuffix(stripPrefix, "/") + rest
+		u.RawPath = ""
+	}
+	u.Scheme = scheme
+	u.Host = host
+	return true
+}
+
+// targetHost reports whether u names the target: its address, another
+// loopback name for its port, or the Host header sent to it.
+func targetHost(u, target *url.URL, upstreamHost string) bool {
+	if upstreamHost != "" && strings.EqualFold(u.Host, upstreamHost) {
+		return true
+	}
+	if u.Port() != target.Port() {
+		return false
+	}
+	hostname := u.Hostname()
+	if strings.EqualFold(hostname, target.Hostname()) || strings.EqualFold(hostname, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(hostname)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPRewritesLocation(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+	}))
+	defer upstream.Close()
+	port := upstream.Listener.Addr().(*net.TCPAddr).Port
+	apiURL, _ := url.Parse(upstream.URL + "/v1")
+
+	newServer := func(keep bool) *Server {
+		return NewServer(Config{
+			TargetPort:     port,
+			Mode:           model.ModeProxy,
+			UseTUI:         true,
+			Logger:         zap.NewNop(),
+			ExternalScheme: "https",
+			Mounts:         []Mount{{Path: "/api", Target: apiURL}},
+			KeepLocation:   keep,
+		})
+	}
+	location := func(server *Server, path, to string) string {
+		req := httptest.NewRequest(http.MethodPost, "http://dev.example.ts.net"+path+"?to="+url.QueryEscape(to), nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr.Result().Header.Get("Location")
+	}
+
+	local := "localhost:" + strconv.Itoa(port)
+	server := newServer(false)
+	tests := []struct {
+		path, to, want string
+	}{
+		{"/form", "http://" + local + "/login?next=%2F", "https://dev.example.ts.net/login?next=%2F"},
+		{"/form", "http://127.0.0.1:" + strconv.Itoa(port) + "/done", "https://dev.example.ts.net/done"},
+		{"/form", "http://localhost:1/elsewhere", "http://localhost:1/elsewhere"},
+		{"/form", "https://github.com/login/oauth/authorize", "https://github.com/login/oauth/authorize"},
+		{"/form", "/relative", "/relative"},
+		{"/api/form", "http://" + local + "/v1/users", "https://dev.example.ts.net/api/users"},
+		{"/api/form", "http://" + local + "/v2/users", "http://" + local + "/v2/users"},
+	}
+	for _, tt := range tests {
+		if got := location(server, tt.path, tt.to); got != tt.want {
+			t.Fatalf("%s redirecting to %s: expected Location %q, got %q", tt.path, tt.to, tt.want, got)
+		}
+	}
+
+	to := "http://" + local + "/login"
+	if got := location(newServer(true), "/form", to); got != to {
+		t.Fatalf("expected Location kept with KeepLocation, got %q", got)
+	}
+}
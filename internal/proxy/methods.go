@@ -0,0 +1,40 @@
+// internal/proxy/methods.go
+package proxy
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+)
+
+// ViolationMethodNotAllowed counts requests rejected by Settings.AllowMethods.
+const ViolationMethodNotAllowed = "method_not_allowed"
+
+// enforceAllowedMethods rejects requests whose method is not in
+// Settings.AllowMethods with 405, so an endpoint meant for webhook POSTs does
+// not expose the app's other handlers. HEAD is allowed along with GET.
+func (s *Server) enforceAllowedMethods(w http.ResponseWriter, r *http.Request) bool {
+	allowed := s.currentSettings().AllowMethods
+	if len(allowed) == 0 || methodAllowed(r.Method, allowed) {
+		return true
+	}
+
+	s.stats.AddViolation(ViolationMethodNotAllowed)
+	s.log().Warn("Request method not allowed",
+		logging.Component("allow_methods"),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("remote_addr", r.RemoteAddr),
+	)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func methodAllowed(method string, allowed []string) bool {
+	return slices.Contains(allowed, method) || method == http.MethodHead && slices.Contains(allowed, http.MethodGet)
+}
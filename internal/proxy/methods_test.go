@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/middleware"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPAllowMethods(t *testing.T) {
+	var reached []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = append(reached, r.Method)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort:   upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:         model.ModeProxy,
+		UseTUI:       true,
+		Logger:       zap.NewNop(),
+		AllowMethods: []string{http.MethodPost, http.MethodGet},
+		CORS:         middleware.CORSConfig{Origins: []string{"*"}},
+	})
+	serve := func(method string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/hook", nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodGet, http.MethodHead} {
+		if rr := serve(method, nil); rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected the request to be forwarded, got %d", method, rr.Code)
+		}
+	}
+	rr := serve(http.MethodDelete, nil)
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "POST, GET" {
+		t.Fatalf("expected 405 with an Allow header, got %d %q", rr.Code, rr.Header().Get("Allow"))
+	}
+
+	// CORS preflights are answered by portal, not the target.
+	preflight := http.Header{"Origin": {"https://app.example"}, "Access-Control-Request-Method": {"POST"}}
+	if rr := serve(http.MethodOptions, preflight); rr.Code == http.StatusMethodNotAllowed {
+		t.Fatalf("expected preflights to be answered, got %d", rr.Code)
+	}
+
+	if len(reached) != 3 {
+		t.Fatalf("expected only allowed methods to reach the target, got %v", reached)
+	}
+	if got := server.GetViolations()[ViolationMethodNotAllowed]; got != 1 {
+		t.Fatalf("expected one method violation, got %d", got)
+	}
+}
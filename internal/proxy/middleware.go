@@ -0,0 +1,51 @@
+// internal/proxy/middleware.go
+package proxy
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/middleware"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// buildMiddleware sets up the configured middleware chain. Specs are
+// validated when the config is parsed, so an error here only gets logged.
+func (s *Server) buildMiddleware(specs []middleware.Spec) {
+	chain, err := middleware.NewChain(specs, middleware.ChainOptions{
+		AccessLog: s.logAccess,
+		ClientKey: s.clientKey,
+	})
+	if err != nil {
+		s.log().Error("Invalid middleware configuration",
+			logging.Component("proxy_server"),
+			logging.Error(err),
+		)
+		return
+	}
+	s.middleware = chain
+}
+
+// logAccess writes the access-log middleware's line for a request.
+func (s *Server) logAccess(entry model.RequestLog) {
+	s.log().Info("Access",
+		logging.Component("access_log"),
+		zap.String("method", entry.Method),
+		zap.String("url", entry.URL),
+		zap.String("remote_addr", entry.RemoteAddr),
+		zap.Int("status_code", entry.StatusCode),
+		zap.Duration("duration", entry.Duration),
+		zap.Int64("response_size", entry.Response.Size),
+	)
+}
+
+// clientKey identifies the client for rate limits, preferring the Tailscale
+// and forwarded client IPs over the connection address.
+func (s *Server) clientKey(r *http.Request) string {
+	if addr, _, ok := resolveSourceIP(r, s.preferRemoteIP); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/middleware"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPCapturesRequestsRejectedByMiddleware(t *testing.T) {
+	var upstreamHits int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits++
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Middleware: []middleware.Spec{
+			{Name: middleware.NameBearerAuth, Paths: []string{"/admin"}, Token: "s3cret"},
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/users", nil))
+	if rr.Code != http.StatusUnauthorized || upstreamHits != 0 {
+		t.Fatalf("expected 401 without reaching the upstream, got %d after %d hits", rr.Code, upstreamHits)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || upstreamHits != 1 {
+		t.Fatalf("expected the authorized request to be proxied, got %d after %d hits", rr.Code, upstreamHits)
+	}
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 2 || logs[0].StatusCode != http.StatusUnauthorized || logs[1].Timing == nil {
+		t.Fatalf("expected both requests captured, got %+v", logs)
+	}
+}
@@ -0,0 +1,150 @@
+// internal/proxy/mounts.go
+package proxy
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+
+	porthttputil "github.com/jaxxstorm/portal/internal/httputil"
+)
+
+// Mount routes requests under Path to Target. The Path prefix is replaced by
+// Target's own path, so "/api" → "http://localhost:3000/v1" sends
+// /api/users to /v1/users.
+type Mount struct {
+	Path   string
+	Target *url.URL
+}
+
+// String formats the mount for display.
+func (m Mount) String() string {
+	return m.Path + " → " + m.Target.Host + m.Target.Path
+}
+
+// Unmatched says how requests that match no mount are answered. The zero
+// value forwards them to the main target, or answers 404 without one.
+type Unmatched struct {
+	// NotFound answers 404 even when there is a main target.
+	NotFound bool
+	// Redirect answers with a temporary redirect to this URL or path.
+	Redirect string
+	// Mount forwards to the mount with this path, keeping the request path.
+	Mount string
+}
+
+type mountRoute struct {
+	Mount
+	proxy *httputil.ReverseProxy
+}
+
+func (m mountRoute) closeIdle() {
+	if transport, ok := m.proxy.Transport.(interface{ CloseIdleConnections() }); ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+// buildMounts builds a reverse proxy per mount, longest prefix first. Mounts
+// always dial directly, even when the main target is a tailnet peer.
+func (s *Server) buildMounts(mounts []Mount) []mountRoute {
+	routes := make([]mountRoute, 0, len(mounts))
+	for _, mount := range mounts {
+		routes = append(routes, mountRoute{
+			Mount: mount,
+			proxy: s.newReverseProxy(mount.Target, mount.Path, (&porthttputil.LoopbackDialer{}).DialContext),
+		})
+	}
+	slices.SortStableFunc(routes, func(a, b mountRoute) int {
+		return cmp.Compare(len(b.Path), len(a.Path))
+	})
+	return routes
+}
+
+func mountTable(routes []mountRoute) string {
+	table := make([]string, 0, len(routes))
+	for _, route := range routes {
+		table = append(table, route.String())
+	}
+	return strings.Join(table, ", ")
+}
+
+// matchMount returns the mount whose path is a segment-aligned prefix of
+// requestPath, or nil.
+func (s *Server) matchMount(requestPath string) *mountRoute {
+	mounts := s.currentSettings().mounts
+	for i := range mounts {
+		prefix := mounts[i].Path
+		if requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/") {
+			return &mounts[i]
+		}
+	}
+	return nil
+}
+
+// mountByPath returns the mount declared with path, or nil.
+func (s *Server) mountByPath(path string) *mountRoute {
+	mounts := s.currentSettings().mounts
+	for i := range mounts {
+		if mounts[i].Path == path {
+			return &mounts[i]
+		}
+	}
+	return nil
+}
+
+// serveUnmatched answers a request that matched no mount, as configured with
+// Settings.Unmatched, and counts it. It reports false when the request should
+// go to the main target as usual.
+func (s *Server) serveUnmatched(w http.ResponseWriter, r *http.Request, upstreamReq *http.Request) bool {
+	settings := s.currentSettings()
+	if len(settings.mounts) == 0 {
+		return false
+	}
+	s.stats.AddUnmatched(r.URL.Path)
+
+	unmatched := settings.Unmatched
+	switch {
+	case unmatched.Redirect != "":
+		http.Redirect(w, r, unmatched.Redirect, http.StatusTemporaryRedirect)
+	case unmatched.Mount != "":
+		mount := s.mountByPath(unmatched.Mount)
+		if mount == nil {
+			s.writeNoMount(w, r, settings.mounts)
+			return true
+		}
+		mount.proxy.ServeHTTP(w, upstreamReq)
+	case unmatched.NotFound || s.proxy == nil:
+		s.writeNoMount(w, r, settings.mounts)
+	default:
+		return false
+	}
+	return true
+}
+
+// writeNoMount answers 404, listing the mounts so a mistyped path is easy to
+// correct.
+func (s *Server) writeNoMount(w http.ResponseWriter, r *http.Request, mounts []mountRoute) {
+	paths := make([]string, 0, len(mounts))
+	for _, mount := range mounts {
+		paths = append(paths, mount.Path)
+	}
+	slices.Sort(paths)
+	http.Error(w, fmt.Sprintf("no mount matches %s (mounts: %s)", r.URL.Path, strings.Join(paths, ", ")), http.StatusNotFound)
+}
+
+// mountPath replaces the mount prefix of requestPath with targetPath.
+func mountPath(targetPath, requestPath, prefix string) string {
+	rest := strings.TrimPrefix(requestPath, prefix)
+	base := strings.TrimSuffix(targetPath, "/")
+	if rest == "" {
+		if base == "" {
+			return "/"
+		}
+		return base
+	}
+	return base + rest
+}
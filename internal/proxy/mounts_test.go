@@ -0,0 +1,147 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPRoutesMounts(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("api " + r.URL.RequestURI()))
+	}))
+	defer api.Close()
+	docs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("docs " + r.URL.RequestURI()))
+	}))
+	defer docs.Close()
+
+	apiURL, _ := url.Parse(api.URL + "/v1")
+	docsURL, _ := url.Parse(docs.URL)
+	server := NewServer(Config{
+		Mode:   model.ModeProxy,
+		UseTUI: true,
+		Logger: zap.NewNop(),
+		Mounts: []Mount{
+			{Path: "/docs", Target: docsURL},
+			{Path: "/api", Target: apiURL},
+		},
+	})
+
+	tests := map[string]string{
+		"/api/users?page=2": "api /v1/users?page=2",
+		"/api":              "api /v1",
+		"/docs/intro":       "docs /intro",
+		"/docs":             "docs /",
+	}
+	for path, want := range tests {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Body.String() != want {
+			t.Fatalf("%s: expected %q, got %d %q", path, want, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/apiary", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unmounted path without a default target, got %d", rr.Code)
+	}
+
+	if got := server.GetEndpointState().Mounts; got == "" {
+		t.Fatalf("expected mount table in endpoint state")
+	}
+}
+
+func TestApplySettingsReplacesMounts(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+
+	server := NewServer(Config{
+		Mode:   model.ModeProxy,
+		UseTUI: true,
+		Logger: zap.NewNop(),
+		Mounts: []Mount{{Path: "/old", Target: target}},
+	})
+
+	settings := server.CurrentSettings()
+	settings.Mounts = []Mount{{Path: "/new", Target: target}}
+	server.ApplySettings(settings)
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/new/x", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "/x" {
+		t.Fatalf("expected new mount to route, got %d %q", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/old/x", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected old mount to be removed, got %d", rr.Code)
+	}
+	if got := server.GetEndpointState().Mounts; got != "/new → "+target.Host {
+		t.Fatalf("expected updated mount table, got %q", got)
+	}
+}
+
+func TestServeHTTPUnmatchedRoutes(t *testing.T) {
+	upstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + " " + r.URL.Path))
+		}))
+	}
+	main, api := upstream("main"), upstream("api")
+	defer main.Close()
+	defer api.Close()
+	apiURL, _ := url.Parse(api.URL + "/v1")
+
+	server := NewServer(Config{
+		TargetPort: main.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Mounts:     []Mount{{Path: "/api", Target: apiURL}},
+	})
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	if rr := get("/webhok"); rr.Body.String() != "main /webhok" {
+		t.Fatalf("expected unmatched paths to reach the main target, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	settings := server.CurrentSettings()
+	settings.Unmatched = Unmatched{NotFound: true}
+	server.ApplySettings(settings)
+	if rr := get("/webhok"); rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "/api") {
+		t.Fatalf("expected a 404 listing the mounts, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	settings.Unmatched = Unmatched{Redirect: "/api/"}
+	server.ApplySettings(settings)
+	if rr := get("/webhok"); rr.Code != http.StatusTemporaryRedirect || rr.Header().Get("Location") != "/api/" {
+		t.Fatalf("expected a redirect, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	settings.Unmatched = Unmatched{Mount: "/api"}
+	server.ApplySettings(settings)
+	if rr := get("/webhok"); rr.Body.String() != "api /v1/webhok" {
+		t.Fatalf("expected unmatched paths to fall through to the mount, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	get("/api/users")
+	if unmatched := server.GetUnmatched(); len(unmatched) != 1 || unmatched["/webhok"] != 4 {
+		t.Fatalf("expected only unmatched requests to be counted, got %v", unmatched)
+	}
+}
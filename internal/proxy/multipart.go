@@ -0,0 +1,65 @@
+// internal/proxy/multipart.go
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// multipartValueLimit caps how much of a plain form field is kept.
+const multipartValueLimit = 1024
+
+// parseMultipart summarizes a multipart/form-data body into its parts. It
+// returns nil when the request is not multipart or the body cannot be
+// parsed, in which case the raw body is logged as usual.
+func parseMultipart(contentType string, body []byte) []model.MultipartPart {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil
+	}
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	var parts []model.MultipartPart
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil
+		}
+
+		summary := model.MultipartPart{
+			Name:        part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+		}
+		var value bytes.Buffer
+		if summary.Filename == "" {
+			summary.Size, err = io.Copy(&value, io.LimitReader(part, multipartValueLimit))
+			if err == nil {
+				var rest int64
+				rest, err = io.Copy(io.Discard, part)
+				summary.Size += rest
+			}
+			if utf8.Valid(value.Bytes()) {
+				summary.Value = value.String()
+			}
+		} else {
+			summary.Size, err = io.Copy(io.Discard, part)
+		}
+		part.Close()
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, summary)
+	}
+	return parts
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestParseMultipartSummarizesParts(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("title", "holiday")
+	file, _ := writer.CreateFormFile("photo", "beach.jpg")
+	file.Write(bytes.Repeat([]byte{0xff, 0xd8}, 5000))
+	writer.Close()
+
+	parts := parseMultipart(writer.FormDataContentType(), body.Bytes())
+	if len(parts) != 2 {
+		t.Fatalf("expected 2 parts, got %+v", parts)
+	}
+	if parts[0].Name != "title" || parts[0].Value != "holiday" || parts[0].Size != 7 {
+		t.Fatalf("unexpected field part %+v", parts[0])
+	}
+	if parts[1].Name != "photo" || parts[1].Filename != "beach.jpg" || parts[1].Size != 10000 || parts[1].Value != "" {
+		t.Fatalf("unexpected file part %+v", parts[1])
+	}
+	if parts[1].ContentType != "application/octet-stream" {
+		t.Fatalf("expected part content type, got %q", parts[1].ContentType)
+	}
+}
+
+func TestParseMultipartIgnoresOtherBodies(t *testing.T) {
+	if parts := parseMultipart("application/json", []byte(`{}`)); parts != nil {
+		t.Fatalf("expected nil for JSON, got %+v", parts)
+	}
+	if parts := parseMultipart("multipart/form-data; boundary=x", []byte("garbage")); parts != nil {
+		t.Fatalf("expected nil for malformed body, got %+v", parts)
+	}
+}
+
+func TestServeHTTPLogsMultipartWithoutRawBody(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("upstream parse: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	file, _ := writer.CreateFormFile("upload", "data.bin")
+	file.Write(bytes.Repeat([]byte{0}, 2048))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected upstream to receive the full body, got %d", rr.Code)
+	}
+	logs := server.GetRequestLogs()
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 log, got %d", len(logs))
+	}
+	if logs[0].Body != "" || strings.Contains(logs[0].Body, "\x00") {
+		t.Fatalf("expected raw multipart body to be dropped, got %d bytes", len(logs[0].Body))
+	}
+	if len(logs[0].Multipart) != 1 || logs[0].Multipart[0].Filename != "data.bin" || logs[0].Multipart[0].Size != 2048 {
+		t.Fatalf("unexpected multipart summary %+v", logs[0].Multipart)
+	}
+}
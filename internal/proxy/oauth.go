@@ -0,0 +1,191 @@
+// internal/proxy/oauth.go
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// DefaultOAuthCallbackPath is the callback path used when --oauth-callback is
+// given without one.
+const DefaultOAuthCallbackPath = "/callback"
+
+// oauthExchangeTimeout bounds the token endpoint request made for a callback.
+var oauthExchangeTimeout = 15 * time.Second
+
+// maxOAuthTokenBody caps how much of the token endpoint's response is kept.
+const maxOAuthTokenBody = 64 << 10
+
+// OAuth answers OAuth 2.0 authorization redirects on Path itself instead of
+// forwarding them, capturing the code and state. When TokenURL is set the
+// code is exchanged for tokens straight away.
+type OAuth struct {
+	Path         string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// CodeVerifier is sent for PKCE flows.
+	CodeVerifier string
+	// RedirectURI must match the one the authorization request used.
+	// Defaults to the URL the callback arrived on, without its query.
+	RedirectURI string
+}
+
+// matchOAuthCallback reports whether r is for the OAuth callback path.
+func (s *Server) matchOAuthCallback(r *http.Request) bool {
+	return s.oauth.Path != "" && r.URL.Path == s.oauth.Path
+}
+
+// serveOAuthCallback captures the authorization response on the callback
+// path, exchanges the code when a token endpoint is configured, and shows the
+// result in the browser.
+func (s *Server) serveOAuthCallback(w http.ResponseWriter, r *http.Request) *model.OAuthCallback {
+	query := r.URL.Query()
+	callback := &model.OAuthCallback{
+		Code:             query.Get("code"),
+		State:            query.Get("state"),
+		Error:            query.Get("error"),
+		ErrorDescription: query.Get("error_description"),
+	}
+
+	if callback.Code != "" && s.oauth.TokenURL != "" {
+		callback.Token = s.exchangeOAuthCode(r.Context(), callback.Code, s.oauthRedirectURI(r))
+	}
+
+	s.log().Info("OAuth callback received",
+		logging.Component("oauth_callback"),
+		zap.Bool("code", callback.Code != ""),
+		zap.String("state", callback.State),
+		zap.String("error", callback.Error),
+		zap.Bool("exchanged", callback.Token != nil && callback.Token.Error == ""),
+	)
+
+	status := http.StatusOK
+	if callback.Code == "" {
+		status = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := oauthPage.Execute(w, oauthPageData(callback)); err != nil {
+		s.log().Debug("Failed to write OAuth callback page",
+			logging.Component("oauth_callback"),
+			logging.Error(err),
+		)
+	}
+	return callback
+}
+
+// oauthRedirectURI is the redirect_uri sent with the token request.
+func (s *Server) oauthRedirectURI(r *http.Request) string {
+	if s.oauth.RedirectURI != "" {
+		return s.oauth.RedirectURI
+	}
+	return (&url.URL{Scheme: s.requestScheme(r), Host: r.Host, Path: r.URL.Path}).String()
+}
+
+// exchangeOAuthCode trades code for tokens with the authorization_code grant.
+// The client secret, if any, is sent with HTTP Basic authentication.
+func (s *Server) exchangeOAuthCode(ctx context.Context, code, redirectURI string) *model.OAuthToken {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	}
+	if s.oauth.ClientID != "" {
+		form.Set("client_id", s.oauth.ClientID)
+	}
+	if s.oauth.CodeVerifier != "" {
+		form.Set("code_verifier", s.oauth.CodeVerifier)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, oauthExchangeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.oauth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return &model.OAuthToken{Error: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.oauth.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.oauth.ClientID), url.QueryEscape(s.oauth.ClientSecret))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &model.OAuthToken{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxOAuthTokenBody))
+	if err != nil {
+		return &model.OAuthToken{StatusCode: resp.StatusCode, Error: err.Error()}
+	}
+
+	token := &model.OAuthToken{StatusCode: resp.StatusCode}
+	if err := json.Unmarshal(body, &token.Response); err != nil {
+		token.Response = nil
+		token.Body = string(body)
+	}
+	return token
+}
+
+type oauthPageView struct {
+	*model.OAuthCallback
+	TokenJSON string
+}
+
+func oauthPageData(callback *model.OAuthCallback) oauthPageView {
+	view := oauthPageView{OAuthCallback: callback}
+	if callback.Token != nil {
+		if callback.Token.Response != nil {
+			data, _ := json.MarshalIndent(callback.Token.Response, "", "  ")
+			view.TokenJSON = string(data)
+		} else {
+			view.TokenJSON = callback.Token.Body
+		}
+	}
+	return view
+}
+
+var oauthPage = template.Must(template.New("oauth").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>portal · OAuth callback</title>
+<style>
+body { font-family: "IBM Plex Sans", "Segoe UI", sans-serif; margin: 2rem auto; max-width: 48rem; color: #101828; }
+dt { font-size: 0.8rem; color: #475467; margin-top: 1rem; }
+dd { margin: 0.2rem 0 0; font-family: "JetBrains Mono", Menlo, monospace; word-break: break-all; font-size: 1.1rem; }
+pre { background: #f8fafc; border: 1px solid #d0d5dd; padding: 0.8rem; overflow-x: auto; }
+.err { color: #b42318; }
+.ok { color: #067647; }
+</style>
+</head>
+<body>
+{{if .Code}}<h1 class="ok">Authorization code received</h1>{{else}}<h1 class="err">No authorization code</h1>{{end}}
+<dl>
+{{if .Code}}<dt>code</dt><dd>{{.Code}}</dd>{{end}}
+{{if .State}}<dt>state</dt><dd>{{.State}}</dd>{{end}}
+{{if .Error}}<dt>error</dt><dd class="err">{{.Error}}</dd>{{end}}
+{{if .ErrorDescription}}<dt>error_description</dt><dd class="err">{{.ErrorDescription}}</dd>{{end}}
+</dl>
+{{with .Token}}
+<h2>Token exchange</h2>
+{{if .Error}}<p class="err">{{.Error}}</p>{{else}}<p class="{{if lt .StatusCode 300}}ok{{else}}err{{end}}">Token endpoint returned {{.StatusCode}}</p>{{end}}
+{{end}}
+{{if .TokenJSON}}<pre>{{.TokenJSON}}</pre>{{end}}
+<p>The request is also in portal's request log. You can close this tab.</p>
+</body>
+</html>
+`))
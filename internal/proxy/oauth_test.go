@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestOAuthCallbackExchangesCode(t *testing.T) {
+	var form url.Values
+	var user, pass string
+	tokenEndpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		user, pass, _ = r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"at-123","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer tokenEndpoint.Close()
+
+	server := NewServer(Config{
+		Mode:           model.ModeMock,
+		UseTUI:         true,
+		Logger:         zap.NewNop(),
+		ExternalScheme: "https",
+		OAuth: OAuth{
+			Path:         DefaultOAuthCallbackPath,
+			TokenURL:     tokenEndpoint.URL,
+			ClientID:     "portal-dev",
+			ClientSecret: "s3cret",
+			CodeVerifier: "verifier",
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "https://dev-box.example.ts.net/callback?code=abc&state=xyz", nil)
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "abc") || !strings.Contains(rec.Body.String(), "at-123") {
+		t.Fatalf("expected callback page with code and token, got %d %q", rec.Code, rec.Body.String())
+	}
+	if form.Get("grant_type") != "authorization_code" || form.Get("code") != "abc" || form.Get("code_verifier") != "verifier" || form.Get("client_id") != "portal-dev" {
+		t.Fatalf("unexpected token request form %v", form)
+	}
+	if form.Get("redirect_uri") != "https://dev-box.example.ts.net/callback" {
+		t.Fatalf("expected redirect_uri from the callback URL, got %q", form.Get("redirect_uri"))
+	}
+	if user != "portal-dev" || pass != "s3cret" {
+		t.Fatalf("expected client credentials with basic auth, got %q %q", user, pass)
+	}
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 1 || logs[0].OAuth == nil {
+		t.Fatalf("expected an OAuth callback log, got %+v", logs)
+	}
+	callback := logs[0].OAuth
+	if callback.Code != "abc" || callback.State != "xyz" || callback.Token == nil || callback.Token.StatusCode != http.StatusOK || callback.Token.Response["access_token"] != "at-123" {
+		t.Fatalf("unexpected callback %+v token %+v", callback, callback.Token)
+	}
+}
+
+func TestOAuthRedirectURIForFunnelRequests(t *testing.T) {
+	server := NewServer(Config{
+		Mode:           model.ModeMock,
+		UseTUI:         true,
+		Logger:         zap.NewNop(),
+		ExternalScheme: "http",
+		OAuth:          OAuth{Path: DefaultOAuthCallbackPath},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "http://dev-box.example.ts.net/callback?code=abc", nil)
+	if got := server.oauthRedirectURI(req); got != "http://dev-box.example.ts.net/callback" {
+		t.Fatalf("expected the tailnet callback URL, got %q", got)
+	}
+	req.Header.Set("Tailscale-Funnel-Request", "?1")
+	if got := server.oauthRedirectURI(req); got != "https://dev-box.example.ts.net/callback" {
+		t.Fatalf("expected the Funnel callback URL over HTTPS, got %q", got)
+	}
+}
+
+func TestOAuthCallbackWithoutCode(t *testing.T) {
+	server := NewServer(Config{
+		Mode:   model.ModeMock,
+		UseTUI: true,
+		Logger: zap.NewNop(),
+		OAuth:  OAuth{Path: "/auth/return", TokenURL: "http://127.0.0.1:1/token"},
+	})
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/return?error=access_denied&error_description=User+declined&state=xyz", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "User declined") {
+		t.Fatalf("expected error page, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/other", nil))
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 logs, got %d", len(logs))
+	}
+	if callback := logs[0].OAuth; callback == nil || callback.Error != "access_denied" || callback.State != "xyz" || callback.Token != nil {
+		t.Fatalf("expected the provider error without an exchange, got %+v", callback)
+	}
+	if logs[1].OAuth != nil {
+		t.Fatalf("expected other paths to be served normally, got %+v", logs[1].OAuth)
+	}
+}
+
+func TestOAuthExchangeKeepsNonJSONBody(t *testing.T) {
+	tokenEndpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_grant", http.StatusBadRequest)
+	}))
+	defer tokenEndpoint.Close()
+
+	server := NewServer(Config{Mode: model.ModeMock, Logger: zap.NewNop(), OAuth: OAuth{Path: "/cb", TokenURL: tokenEndpoint.URL}})
+	token := server.exchangeOAuthCode(t.Context(), "abc", "http://localhost/cb")
+	if token.StatusCode != http.StatusBadRequest || token.Response != nil || !strings.Contains(token.Body, "invalid_grant") {
+		t.Fatalf("expected raw error body, got %+v", token)
+	}
+}
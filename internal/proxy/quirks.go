@@ -0,0 +1,298 @@
+// internal/proxy/quirks.go
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+)
+
+// Quirks work around legacy upstreams, such as embedded devices and old
+// appliances, that break on what net/http sends or reject what they send
+// back.
+type Quirks struct {
+	// HTTP10 sends requests as HTTP/1.0, each on a connection of its own.
+	HTTP10 bool
+	// NoExpectContinue drops Expect: 100-continue from requests.
+	NoExpectContinue bool
+	// NoChunked buffers request bodies of unknown length so they are sent
+	// with a Content-Length instead of chunked transfer encoding.
+	NoChunked bool
+	// LenientHeaders repairs malformed response headers instead of failing
+	// the request with a 502.
+	LenientHeaders bool
+}
+
+// QuirkRoute replaces the default quirks for requests under Path, and only
+// for Methods when set.
+type QuirkRoute struct {
+	Path    string
+	Methods []string
+	Quirks
+}
+
+// UpstreamQuirks holds the default quirks and per-route overrides. The first
+// matching route wins.
+type UpstreamQuirks struct {
+	Default Quirks
+	Routes  []QuirkRoute
+}
+
+// forRequest returns the quirks that apply to r.
+func (u UpstreamQuirks) forRequest(r *http.Request) Quirks {
+	for _, route := range u.Routes {
+		if routeMatches(r, route.Path, route.Methods) {
+			return route.Quirks
+		}
+	}
+	return u.Default
+}
+
+// raw reports whether requests must bypass net/http's transport.
+func (q Quirks) raw() bool {
+	return q.HTTP10 || q.LenientHeaders
+}
+
+type quirksKey struct{}
+
+// withQuirks returns r carrying the quirks its upstream request needs.
+func (s *Server) withQuirks(r *http.Request) *http.Request {
+	quirks := s.quirks.forRequest(r)
+	if quirks == (Quirks{}) {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), quirksKey{}, quirks))
+}
+
+// quirkTransport applies the quirks attached by withQuirks. Requests that
+// need HTTP/1.0 or lenient header parsing are written and read by hand on a
+// fresh connection; everything else goes through base.
+type quirkTransport struct {
+	base *http.Transport
+}
+
+func (t *quirkTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+func (t *quirkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	quirks, _ := req.Context().Value(quirksKey{}).(Quirks)
+	if quirks == (Quirks{}) {
+		return t.base.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request it was given.
+	req = req.Clone(req.Context())
+	if quirks.NoExpectContinue {
+		req.Header.Del("Expect")
+	}
+	if (quirks.NoChunked || quirks.raw()) && req.Body != nil && req.Body != http.NoBody && req.ContentLength < 0 {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to buffer request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.TransferEncoding = nil
+	}
+	if !quirks.raw() {
+		return t.base.RoundTrip(req)
+	}
+	return t.rawRoundTrip(req, quirks)
+}
+
+// rawRoundTrip sends req on a new connection that is closed with the
+// response body. It reports to the request's httptrace hooks like net/http
+// would, so timing still works.
+func (t *quirkTransport) rawRoundTrip(req *http.Request, quirks Quirks) (*http.Response, error) {
+	ctx := req.Context()
+	trace := httptrace.ContextClientTrace(ctx)
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		port := "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(req.URL.Hostname(), port)
+	}
+
+	if trace != nil && trace.GetConn != nil {
+		trace.GetConn(addr)
+	}
+	conn, err := t.base.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: req.URL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{Conn: conn})
+	}
+
+	// Cancelling the request closes the connection, which unblocks any
+	// read or write in progress.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	closeConn := func() {
+		stop()
+		conn.Close()
+	}
+
+	if err := writeRawRequest(conn, req, quirks.HTTP10); err != nil {
+		closeConn()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	if _, err := br.Peek(1); err != nil {
+		closeConn()
+		return nil, err
+	}
+	if trace != nil && trace.GotFirstResponseByte != nil {
+		trace.GotFirstResponseByte()
+	}
+	if quirks.LenientHeaders {
+		if br, err = repairResponseHead(br); err != nil {
+			closeConn()
+			return nil, err
+		}
+	}
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		closeConn()
+		return nil, err
+	}
+	resp.Body = &connBody{ReadCloser: resp.Body, close: closeConn}
+	return resp, nil
+}
+
+// writeRawRequest writes req with its body, which must have a known length,
+// asking the upstream to close the connection afterwards.
+func writeRawRequest(w io.Writer, req *http.Request, http10 bool) error {
+	proto := "HTTP/1.1"
+	if http10 {
+		proto = "HTTP/1.0"
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	header := req.Header.Clone()
+	for _, name := range []string{"Host", "Content-Length", "Transfer-Encoding", "Connection"} {
+		header.Del(name)
+	}
+	header.Set("Connection", "close")
+	if req.ContentLength > 0 || (req.Body != nil && req.Body != http.NoBody) {
+		header.Set("Content-Length", strconv.FormatInt(max(req.ContentLength, 0), 10))
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s %s %s\r\nHost: %s\r\n", req.Method, req.URL.RequestURI(), proto, host)
+	if err := header.Write(bw); err != nil {
+		return err
+	}
+	bw.WriteString("\r\n")
+	if req.Body != nil {
+		if _, err := io.Copy(bw, req.Body); err != nil {
+			return err
+		}
+		req.Body.Close()
+	}
+	return bw.Flush()
+}
+
+// repairResponseHead reads the status line and headers from br, repairs
+// what net/http would reject, and returns a reader that yields the repaired
+// head followed by the rest of the response. Bare LF line endings become
+// CRLF, folded lines are joined, lines without a colon or with an invalid
+// name are dropped, control characters are stripped from values, and only
+// the first Content-Length is kept.
+func repairResponseHead(br *bufio.Reader) (*bufio.Reader, error) {
+	status, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("malformed response status line: %w", err)
+	}
+	var lines []string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("malformed response headers: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += " " + strings.TrimSpace(line)
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	var head bytes.Buffer
+	head.WriteString(strings.TrimRight(status, "\r\n") + "\r\n")
+	seenLength := false
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || !validHeaderName(name) {
+			continue
+		}
+		if http.CanonicalHeaderKey(name) == "Content-Length" {
+			if seenLength {
+				continue
+			}
+			seenLength = true
+		}
+		value = strings.Map(func(r rune) rune {
+			if (r < ' ' && r != '\t') || r == 0x7f {
+				return -1
+			}
+			return r
+		}, strings.TrimSpace(value))
+		head.WriteString(name + ": " + value + "\r\n")
+	}
+	head.WriteString("\r\n")
+	return bufio.NewReader(io.MultiReader(&head, br)), nil
+}
+
+// validHeaderName reports whether name is an RFC 9110 token.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// connBody closes the connection along with the response body.
+type connBody struct {
+	io.ReadCloser
+	close func()
+}
+
+func (b *connBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.close()
+	return err
+}
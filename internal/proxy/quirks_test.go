@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// legacyUpstream answers every connection with response after reading the
+// request head, and sends the request lines it read on the returned channel.
+func legacyUpstream(t *testing.T, response string) (int, <-chan []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	heads := make(chan []string, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			br := bufio.NewReader(conn)
+			var head []string
+			for {
+				line, err := br.ReadString('\n')
+				line = strings.TrimRight(line, "\r\n")
+				if err != nil || line == "" {
+					break
+				}
+				head = append(head, line)
+			}
+			heads <- head
+			io.WriteString(conn, response)
+			conn.Close()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, heads
+}
+
+func TestServeHTTPLenientHeaders(t *testing.T) {
+	port, _ := legacyUpstream(t, "HTTP/1.1 200 OK\nContent-Type: text/plain\nX Bad Name: 1\nbroken line\nContent-Length: 2\nContent-Length: 7\n\nok")
+
+	server := NewServer(Config{
+		TargetPort: port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Quirks: UpstreamQuirks{
+			Routes: []QuirkRoute{{Path: "/legacy", Quirks: Quirks{LenientHeaders: true}}},
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/modern", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Fatalf("expected malformed headers to fail without the quirk, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/legacy/status", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+		t.Fatalf("expected the repaired response, got %d %q", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "text/plain" {
+		t.Fatalf("expected valid headers to survive, got %q", got)
+	}
+}
+
+func TestServeHTTPHTTP10(t *testing.T) {
+	port, heads := legacyUpstream(t, "HTTP/1.0 200 OK\r\nContent-Type: text/plain\r\n\r\nhello")
+
+	server := NewServer(Config{
+		TargetPort: port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Quirks:     UpstreamQuirks{Default: Quirks{HTTP10: true}},
+	})
+
+	// An unknown-length body would otherwise be sent chunked.
+	req := httptest.NewRequest(http.MethodPost, "/hook?x=1", io.MultiReader(strings.NewReader("payload")))
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "hello" {
+		t.Fatalf("expected the upstream response, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	head := <-heads
+	if head[0] != "POST /hook?x=1 HTTP/1.0" {
+		t.Fatalf("unexpected request line %q", head[0])
+	}
+	headers := strings.Join(head[1:], "\n")
+	if !strings.Contains(headers, "Content-Length: 7") || strings.Contains(headers, "Transfer-Encoding") {
+		t.Fatalf("expected a Content-Length and no chunking, got %q", headers)
+	}
+}
+
+func TestServeHTTPNoExpectContinueNoChunked(t *testing.T) {
+	type seen struct {
+		expect   string
+		length   int64
+		encoding []string
+		body     string
+	}
+	requests := make(chan seen, 2)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- seen{r.Header.Get("Expect"), r.ContentLength, r.TransferEncoding, string(body)}
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Quirks: UpstreamQuirks{
+			Default: Quirks{NoExpectContinue: true, NoChunked: true},
+			Routes:  []QuirkRoute{{Path: "/stream", Methods: []string{http.MethodPost}}},
+		},
+	})
+
+	send := func(path string) seen {
+		req := httptest.NewRequest(http.MethodPost, path, io.MultiReader(strings.NewReader("payload")))
+		req.Header.Set("Expect", "100-continue")
+		server.ServeHTTP(httptest.NewRecorder(), req)
+		return <-requests
+	}
+
+	got := send("/hook")
+	if got.expect != "" || got.length != 7 || len(got.encoding) != 0 || got.body != "payload" {
+		t.Fatalf("expected no Expect header and a fixed length body, got %+v", got)
+	}
+	got = send("/stream")
+	if got.length != -1 || len(got.encoding) == 0 || got.body != "payload" {
+		t.Fatalf("expected the route to turn the quirks off, got %+v", got)
+	}
+}
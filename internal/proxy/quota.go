@@ -0,0 +1,174 @@
+// internal/proxy/quota.go
+package proxy
+
+import (
+	"cmp"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// ViolationQuotaExceeded counts requests rejected by Settings.Quotas.
+const ViolationQuotaExceeded = "quota_exceeded"
+
+// Quota selectors other than a login.
+const (
+	QuotaMatchAll  = "*"
+	quotaTagPrefix = "tag:"
+)
+
+// MaxQuotaClients caps the clients tracked per session. Past it, new clients
+// share a window per quota, named QuotaOtherClient.
+const (
+	MaxQuotaClients  = 10000
+	QuotaOtherClient = "other"
+)
+
+// Quota allows each client matched by Match Limit requests per Period. Match
+// is a tailnet login, a tag such as "tag:ci", or "*" for every client.
+type Quota struct {
+	Match  string
+	Limit  int
+	Period time.Duration
+}
+
+// matches reports whether the quota applies to identity.
+func (q Quota) matches(identity *model.Identity) bool {
+	switch {
+	case q.Match == QuotaMatchAll:
+		return true
+	case strings.HasPrefix(q.Match, quotaTagPrefix):
+		return slices.Contains(identity.Tags, q.Match)
+	}
+	return identity.Login != "" && identity.Login == q.Match
+}
+
+type quotaWindow struct {
+	match  string
+	client string
+	period time.Duration
+	start  time.Time
+	used   int
+}
+
+// quotaTracker counts each client's requests in fixed windows, keyed by the
+// quota and client so a reload keeps the counts of quotas it leaves alone.
+type quotaTracker struct {
+	mu      sync.Mutex
+	windows map[string]*quotaWindow
+}
+
+// enforceQuota answers requests past the client's quota with 429 and a
+// Retry-After for when its window ends. The first quota matching the client
+// applies; replays and unidentified requests aren't limited.
+func (s *Server) enforceQuota(w http.ResponseWriter, r *http.Request, identity *model.Identity) bool {
+	if identity == nil {
+		return true
+	}
+	quotas := s.currentSettings().Quotas
+	i := slices.IndexFunc(quotas, func(q Quota) bool { return q.matches(identity) })
+	if i < 0 {
+		return true
+	}
+	quota := quotas[i]
+	retryAfter, ok := s.quotas.take(quota, identity, time.Now())
+	if ok {
+		return true
+	}
+
+	s.stats.AddViolation(ViolationQuotaExceeded)
+	s.log().Warn("Request quota exceeded",
+		logging.Component("quota"),
+		zap.String("client", identity.Label()),
+		zap.String("quota", quota.Match),
+		zap.Int("limit", quota.Limit),
+		zap.Duration("period", quota.Period),
+		zap.String("path", r.URL.Path),
+	)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+	return false
+}
+
+// take counts a request against quota for identity, reporting false and how
+// long until the window ends once the limit is used up.
+func (t *quotaTracker) take(quota Quota, identity *model.Identity, now time.Time) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.windows == nil {
+		t.windows = make(map[string]*quotaWindow)
+	}
+
+	key, client := quota.Match+"|"+identity.Key(), identity.Label()
+	window, ok := t.windows[key]
+	if !ok && len(t.windows) >= MaxQuotaClients {
+		t.sweep(now)
+		if len(t.windows) >= MaxQuotaClients {
+			key, client = quota.Match+"|"+QuotaOtherClient, QuotaOtherClient
+			window, ok = t.windows[key]
+		}
+	}
+	if !ok {
+		window = &quotaWindow{match: quota.Match, client: client, start: now}
+		t.windows[key] = window
+	}
+	window.period = quota.Period
+	if now.Sub(window.start) >= quota.Period {
+		window.start, window.used = now, 0
+	}
+	if window.used >= quota.Limit {
+		return window.start.Add(quota.Period).Sub(now), false
+	}
+	window.used++
+	return 0, true
+}
+
+// sweep drops windows that have ended.
+func (t *quotaTracker) sweep(now time.Time) {
+	for key, window := range t.windows {
+		if now.Sub(window.start) >= window.period {
+			delete(t.windows, key)
+		}
+	}
+}
+
+// GetQuotas returns each client's use of its quota in the current window,
+// for quotas still configured. Clients whose window has ended are left out.
+func (s *Server) GetQuotas() []model.QuotaUsage {
+	quotas := s.currentSettings().Quotas
+	now := time.Now()
+
+	t := &s.quotas
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usage := make([]model.QuotaUsage, 0, len(t.windows))
+	for _, window := range t.windows {
+		i := slices.IndexFunc(quotas, func(q Quota) bool { return q.Match == window.match })
+		if i < 0 || now.Sub(window.start) >= quotas[i].Period {
+			continue
+		}
+		quota := quotas[i]
+		usage = append(usage, model.QuotaUsage{
+			Client:    window.client,
+			Match:     quota.Match,
+			Limit:     quota.Limit,
+			Period:    quota.Period.String(),
+			Used:      window.used,
+			Remaining: max(quota.Limit-window.used, 0),
+			ResetsAt:  window.start.Add(quota.Period),
+		})
+	}
+	slices.SortFunc(usage, func(a, b model.QuotaUsage) int {
+		return cmp.Or(strings.Compare(a.Match, b.Match), strings.Compare(a.Client, b.Client))
+	})
+	return usage
+}
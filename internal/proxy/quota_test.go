@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPQuotas(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Quotas: []Quota{
+			{Match: "tag:ci", Limit: 3, Period: time.Hour},
+			{Match: QuotaMatchAll, Limit: 1, Period: time.Hour},
+		},
+	})
+	server.SetWhoIs(func(ctx context.Context, addr netip.Addr) (model.Identity, bool) {
+		if addr.String() == "100.64.0.9" {
+			return model.Identity{Address: addr.String(), Device: "ci-runner", Tags: []string{"tag:ci"}}, true
+		}
+		return model.Identity{}, false
+	})
+	serve := func(remoteAddr string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+	alice := map[string]string{"Tailscale-Client-IP": "100.64.0.2", "Tailscale-User-Login": "alice@example.com"}
+	bob := map[string]string{"Tailscale-Client-IP": "100.64.0.3", "Tailscale-User-Login": "bob@example.com"}
+
+	for i := range 3 {
+		if rr := serve("100.64.0.9:40000", nil); rr.Code != http.StatusOK {
+			t.Fatalf("ci request %d: expected 200 within the tag quota, got %d", i, rr.Code)
+		}
+	}
+	if rr := serve("100.64.0.9:40000", nil); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After past the tag quota, got %d", rr.Code)
+	}
+	if rr := serve("127.0.0.1:5000", alice); rr.Code != http.StatusOK {
+		t.Fatalf("expected alice's first request through, got %d", rr.Code)
+	}
+	if rr := serve("127.0.0.1:5000", alice); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected alice's second request limited, got %d", rr.Code)
+	}
+	if rr := serve("127.0.0.1:5001", bob); rr.Code != http.StatusOK {
+		t.Fatalf("expected each client to get its own quota, got %d", rr.Code)
+	}
+	if got := server.GetViolations()[ViolationQuotaExceeded]; got != 2 {
+		t.Fatalf("expected 2 quota violations, got %d", got)
+	}
+
+	usage := server.GetQuotas()
+	if len(usage) != 3 {
+		t.Fatalf("expected usage for 3 clients, got %+v", usage)
+	}
+	if u := usage[2]; u.Match != "tag:ci" || u.Client != "tag:ci (ci-runner)" || u.Used != 3 || u.Remaining != 0 || u.Period != "1h0m0s" {
+		t.Fatalf("unexpected tag usage %+v", u)
+	}
+
+	// Reloading keeps the counts of quotas that stay and drops the rest.
+	settings := server.CurrentSettings()
+	settings.Quotas = []Quota{{Match: "tag:ci", Limit: 5, Period: time.Hour}}
+	server.ApplySettings(settings)
+	if rr := serve("100.64.0.9:40000", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected the raised limit to apply, got %d", rr.Code)
+	}
+	if usage := server.GetQuotas(); len(usage) != 1 || usage[0].Used != 4 {
+		t.Fatalf("expected only the tag quota with its count kept, got %+v", usage)
+	}
+}
+
+func TestQuotaTrackerWindows(t *testing.T) {
+	var tracker quotaTracker
+	quota := Quota{Match: QuotaMatchAll, Limit: 2, Period: time.Minute}
+	client := &model.Identity{Address: "203.0.113.7", Funnel: true}
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	for range 2 {
+		if _, ok := tracker.take(quota, client, now); !ok {
+			t.Fatal("expected requests within the limit to be allowed")
+		}
+	}
+	retryAfter, ok := tracker.take(quota, client, now.Add(20*time.Second))
+	if ok || retryAfter != 40*time.Second {
+		t.Fatalf("expected a 40s wait past the limit, got %v %v", retryAfter, ok)
+	}
+	if _, ok := tracker.take(quota, client, now.Add(time.Minute)); !ok {
+		t.Fatal("expected a new window once the period ends")
+	}
+}
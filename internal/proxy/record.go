@@ -0,0 +1,67 @@
+// internal/proxy/record.go
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/mock"
+)
+
+type recordKeyContextKey struct{}
+
+// recordKey is what a recorded response is looked up by. It is captured
+// before proxying, since the body is consumed and mounts rewrite the path.
+type recordKey struct {
+	path     string
+	bodyHash string
+}
+
+// withRecordKey stores the request's record key on its context so
+// recordResponse can key the upstream response.
+func withRecordKey(r *http.Request, body []byte) *http.Request {
+	key := recordKey{path: r.URL.RequestURI(), bodyHash: mock.HashBody(body)}
+	return r.WithContext(context.WithValue(r.Context(), recordKeyContextKey{}, key))
+}
+
+// recordResponse is installed as the reverse proxy's ModifyResponse hook in
+// proxy-record mode. It buffers the upstream body, stores it in the recording
+// and hands an identical body on to the client.
+func (s *Server) recordResponse(resp *http.Response) error {
+	key, ok := resp.Request.Context().Value(recordKeyContextKey{}).(recordKey)
+	if !ok {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	entry := mock.RecordedResponse{
+		Method:     resp.Request.Method,
+		Path:       key.path,
+		BodySHA256: key.bodyHash,
+		Status:     resp.StatusCode,
+		Headers:    resp.Header.Clone(),
+		Body:       body,
+		RecordedAt: time.Now().UTC(),
+	}
+	if err := s.recorder.Add(entry); err != nil {
+		s.log().Warn("Failed to record upstream response",
+			logging.Component("proxy_record"),
+			zap.String("method", entry.Method),
+			zap.String("path", entry.Path),
+			logging.Error(err),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/mock"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestRecordThenPlayback(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("recorded " + r.URL.RequestURI()))
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "recording.json")
+	recorder, err := mock.OpenRecording(path)
+	if err != nil {
+		t.Fatalf("open recording: %v", err)
+	}
+
+	recordServer := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Recorder:   recorder,
+	})
+
+	rr := httptest.NewRecorder()
+	recordServer.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/orders?page=2", strings.NewReader(`{"a":1}`)))
+	if rr.Code != http.StatusAccepted || rr.Body.String() != "recorded /orders?page=2" {
+		t.Fatalf("expected proxied response to reach the client, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	playback, err := mock.OpenRecording(path)
+	if err != nil {
+		t.Fatalf("reopen recording: %v", err)
+	}
+	if playback.Len() != 1 {
+		t.Fatalf("expected 1 recorded response on disk, got %d", playback.Len())
+	}
+
+	engine := mock.NewEngine(nil)
+	engine.SetPlayback(playback)
+	playbackServer := NewServer(Config{
+		Mode:       model.ModeMock,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		MockEngine: engine,
+	})
+
+	rr = httptest.NewRecorder()
+	playbackServer.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/orders?page=2", strings.NewReader(`{"a":1}`)))
+	if rr.Code != http.StatusAccepted || rr.Body.String() != "recorded /orders?page=2" {
+		t.Fatalf("expected recorded response on playback, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("X-Upstream") != "yes" || rr.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("expected recorded headers on playback, got %v", rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	playbackServer.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/orders?page=2", strings.NewReader(`{"a":2}`)))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a different body hash, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,177 @@
+// internal/proxy/replay.go
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+type replayKey struct{}
+
+// replayState links a replayed request back to its original and carries the
+// resulting log entry out of ServeHTTP.
+type replayState struct {
+	of    string
+	entry model.RequestLog
+}
+
+// Replay sends a captured request again, with edit applied, through the same
+// handling as live traffic. The new entry is logged with ReplayOf set to id
+// and returned.
+func (s *Server) Replay(ctx context.Context, id string, edit model.ReplayRequest) (model.RequestLog, error) {
+	original, ok := s.findRequestLog(id)
+	if !ok {
+		return model.RequestLog{}, fmt.Errorf("%w: %s", model.ErrRequestNotFound, id)
+	}
+
+	method := strings.ToUpper(strings.TrimSpace(edit.Method))
+	if method == "" {
+		method = original.Method
+	}
+	target := strings.TrimSpace(edit.URL)
+	if target == "" {
+		target = original.URL
+	}
+	if !strings.HasPrefix(target, "/") {
+		return model.RequestLog{}, fmt.Errorf("url %q must be a path, e.g. /api/users", target)
+	}
+	headers := edit.Headers
+	if headers == nil {
+		headers = original.Headers
+	}
+	if original.BodyOmitted && edit.Body == nil {
+		return model.RequestLog{}, fmt.Errorf("the body of %s was not captured; pass a body to replay it", id)
+	}
+	body := original.Body
+	if len(original.BodyBytes) > 0 {
+		body = string(original.BodyBytes)
+	}
+	if edit.Body != nil {
+		body = *edit.Body
+	}
+
+	state := &replayState{of: original.ID}
+	ctx = context.WithValue(ctx, replayKey{}, state)
+	if original.Expose != "" {
+		// Replays go to the same target as the original.
+		ctx = context.WithValue(ctx, exposeContextKey{}, original.Expose)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(body))
+	if err != nil {
+		return model.RequestLog{}, fmt.Errorf("invalid replay request: %w", err)
+	}
+	for _, header := range headers {
+		switch http.CanonicalHeaderKey(header.Name) {
+		case "Host":
+			req.Host = header.Value
+		case "Content-Length":
+		default:
+			req.Header.Add(header.Name, header.Value)
+		}
+	}
+	req.RequestURI = target
+	req.RemoteAddr = original.RemoteAddr
+
+	s.log().Info("Replaying request",
+		logging.Component("replay"),
+		zap.String("request_id", original.ID),
+		zap.String("method", method),
+		zap.String("path", req.URL.Path),
+	)
+	s.ServeHTTP(&discardResponseWriter{header: http.Header{}}, req)
+	return state.entry, nil
+}
+
+// findRequestLog returns the history entry with the given ID.
+func (s *Server) findRequestLog(id string) (model.RequestLog, bool) {
+	s.logMutex.RLock()
+	defer s.logMutex.RUnlock()
+	for i := len(s.requestLog) - 1; i >= 0; i-- {
+		if s.requestLog[i].ID == id {
+			return s.requestLog[i], true
+		}
+	}
+	return model.RequestLog{}, false
+}
+
+// discardResponseWriter receives a replayed response; the logging writer
+// wrapping it keeps what is shown in the request history.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+// ReplaySequence replays the selected requests unchanged, oldest first,
+// waiting either the original gap between them or 1/Rate seconds. Unknown IDs
+// are reported as failed outcomes. It stops early when ctx is cancelled.
+func (s *Server) ReplaySequence(ctx context.Context, seq model.SequenceReplay) []model.ReplayOutcome {
+	entries := make([]model.RequestLog, 0, len(seq.IDs))
+	outcomes := make([]model.ReplayOutcome, 0, len(seq.IDs))
+	for _, id := range seq.IDs {
+		entry, ok := s.findRequestLog(id)
+		if !ok {
+			outcomes = append(outcomes, model.ReplayOutcome{ID: id, Error: model.ErrRequestNotFound.Error()})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+
+	s.log().Info("Replaying request sequence",
+		logging.Component("replay"),
+		zap.Int("requests", len(entries)),
+		zap.Float64("rate", seq.Rate),
+	)
+	for i, entry := range entries {
+		if i > 0 {
+			gap := entry.Timestamp.Sub(entries[i-1].Timestamp)
+			if seq.Rate > 0 {
+				gap = time.Duration(float64(time.Second) / seq.Rate)
+			}
+			if !sleepContext(ctx, gap) {
+				break
+			}
+		}
+
+		outcome := model.ReplayOutcome{ID: entry.ID}
+		replayed, err := s.Replay(ctx, entry.ID, model.ReplayRequest{})
+		if err != nil {
+			outcome.Error = err.Error()
+		} else {
+			outcome.ReplayID = replayed.ID
+			outcome.StatusCode = replayed.StatusCode
+			outcome.Duration = replayed.Duration
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes
+}
+
+// sleepContext waits for d and reports whether ctx is still live.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
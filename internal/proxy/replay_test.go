@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestReplayAppliesEditsAndLinksOriginal(t *testing.T) {
+	type hit struct {
+		method, uri, token, body string
+	}
+	var hits []hit
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		hits = append(hits, hit{r.Method, r.RequestURI, r.Header.Get("X-Token"), string(body)})
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/orders?x=1", strings.NewReader(`{"qty":1}`))
+	req.Header.Set("X-Token", "a")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+	original := server.GetRequestLogs()[0]
+
+	body := `{"qty":2}`
+	replayed, err := server.Replay(context.Background(), original.ID, model.ReplayRequest{
+		Method:  "put",
+		URL:     "/orders/7",
+		Headers: model.HeadersFromMap(map[string]string{"X-Token": "b", "Content-Length": "9"}),
+		Body:    &body,
+	})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replayed.ReplayOf != original.ID || replayed.ID == original.ID {
+		t.Fatalf("expected replay linked to %s, got %+v", original.ID, replayed)
+	}
+	if replayed.StatusCode != http.StatusAccepted || replayed.Response.Body != "ok" {
+		t.Fatalf("expected upstream response to be logged, got %d %q", replayed.StatusCode, replayed.Response.Body)
+	}
+	if got := hits[1]; got != (hit{http.MethodPut, "/orders/7", "b", body}) {
+		t.Fatalf("unexpected replayed request %+v", got)
+	}
+
+	if _, err := server.Replay(context.Background(), original.ID, model.ReplayRequest{}); err != nil {
+		t.Fatalf("replay unchanged: %v", err)
+	}
+	if got := hits[2]; got != (hit{http.MethodPost, "/orders?x=1", "a", `{"qty":1}`}) {
+		t.Fatalf("expected unchanged replay to match the original, got %+v", got)
+	}
+	if logs := server.GetRequestLogs(); len(logs) != 3 {
+		t.Fatalf("expected replays in the history, got %d logs", len(logs))
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	server := NewServer(Config{Mode: model.ModeMock, UseTUI: true, Logger: zap.NewNop()})
+	if _, err := server.Replay(context.Background(), "req_missing", model.ReplayRequest{}); !errors.Is(err, model.ErrRequestNotFound) {
+		t.Fatalf("expected ErrRequestNotFound, got %v", err)
+	}
+
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	id := server.GetRequestLogs()[0].ID
+	if _, err := server.Replay(context.Background(), id, model.ReplayRequest{URL: "http://example.com/"}); err == nil {
+		t.Fatalf("expected absolute URL to be rejected")
+	}
+}
+
+func TestReplaySequenceKeepsOrderAndGaps(t *testing.T) {
+	server := NewServer(Config{Mode: model.ModeMock, UseTUI: true, Logger: zap.NewNop()})
+	start := time.Now().Add(-time.Minute)
+	server.ImportRequestLogs([]model.RequestLog{
+		{Method: http.MethodPost, URL: "/webhooks/a", Timestamp: start},
+		{Method: http.MethodPost, URL: "/webhooks/b", Timestamp: start.Add(80 * time.Millisecond)},
+	})
+	logs := server.GetRequestLogs()
+
+	began := time.Now()
+	outcomes := server.ReplaySequence(context.Background(), model.SequenceReplay{IDs: []string{logs[1].ID, "req_missing", logs[0].ID}})
+	if elapsed := time.Since(began); elapsed < 80*time.Millisecond {
+		t.Fatalf("expected the original 80ms gap to be kept, took %s", elapsed)
+	}
+	if len(outcomes) != 3 {
+		t.Fatalf("expected 3 outcomes, got %+v", outcomes)
+	}
+	if outcomes[0].ID != "req_missing" || outcomes[0].Error == "" {
+		t.Fatalf("expected unknown ID to be reported, got %+v", outcomes[0])
+	}
+	if outcomes[1].ID != logs[0].ID || outcomes[2].ID != logs[1].ID {
+		t.Fatalf("expected original order, got %+v", outcomes)
+	}
+
+	history := server.GetRequestLogs()
+	for i, outcome := range outcomes[1:] {
+		replayed := history[len(history)-2+i]
+		if outcome.ReplayID != replayed.ID || replayed.ReplayOf != outcome.ID || outcome.StatusCode == 0 {
+			t.Fatalf("unexpected outcome %+v for %+v", outcome, replayed)
+		}
+	}
+}
+
+func TestReplaySequenceFixedRate(t *testing.T) {
+	server := NewServer(Config{Mode: model.ModeMock, UseTUI: true, Logger: zap.NewNop()})
+	start := time.Now().Add(-time.Hour)
+	server.ImportRequestLogs([]model.RequestLog{
+		{Method: http.MethodGet, URL: "/a", Timestamp: start},
+		{Method: http.MethodGet, URL: "/b", Timestamp: start.Add(time.Hour)},
+	})
+	logs := server.GetRequestLogs()
+
+	began := time.Now()
+	outcomes := server.ReplaySequence(context.Background(), model.SequenceReplay{IDs: []string{logs[0].ID, logs[1].ID}, Rate: 50})
+	if elapsed := time.Since(began); elapsed > 5*time.Second {
+		t.Fatalf("expected rate to replace the original gap, took %s", elapsed)
+	}
+	if len(outcomes) != 2 || outcomes[1].Error != "" {
+		t.Fatalf("unexpected outcomes %+v", outcomes)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if outcomes := server.ReplaySequence(ctx, model.SequenceReplay{IDs: []string{logs[0].ID, logs[1].ID}, Rate: 1}); len(outcomes) != 1 {
+		t.Fatalf("expected cancellation to stop after the first request, got %+v", outcomes)
+	}
+}
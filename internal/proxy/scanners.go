@@ -0,0 +1,96 @@
+// internal/proxy/scanners.go
+package proxy
+
+import (
+	"net/http"
+	"net/netip"
+	"sync"
+
+	"go.uber.org/zap"
+
+	porthttputil "github.com/jaxxstorm/portal/internal/httputil"
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/scanner"
+)
+
+// ViolationScannerBanned counts requests refused because their source was
+// banned for scanning.
+const ViolationScannerBanned = "scanner_banned"
+
+// ScannerBanned is the scanner reason recorded on requests refused because
+// their source was already banned.
+const ScannerBanned = "banned"
+
+// Scanners controls what happens to Funnel requests that look like internet
+// scanner traffic. They are always tagged; by default they are left out of
+// stats.
+type Scanners struct {
+	// Stats counts them in stats like any other request.
+	Stats bool
+	// Ban refuses every later request from the same source address for the
+	// rest of the session.
+	Ban bool
+}
+
+type scannerGuard struct {
+	Scanners
+
+	mu     sync.Mutex
+	banned map[netip.Addr]struct{}
+}
+
+// detectScanner returns why a Funnel request looks like scanner traffic, or
+// "" when it doesn't or came from the tailnet.
+func (s *Server) detectScanner(r *http.Request) string {
+	if r.Header.Get(porthttputil.FunnelRequestHeader) == "" {
+		return ""
+	}
+	return scanner.Detect(r)
+}
+
+// enforceScannerBan refuses requests from banned sources with 403, banning
+// the source of a scanner request first when Ban is set. It returns the
+// scanner reason to record, which is ScannerBanned for requests that were
+// only refused for their source.
+func (s *Server) enforceScannerBan(w http.ResponseWriter, r *http.Request, reason string) (string, bool) {
+	if !s.scanners.Ban || r.Header.Get(porthttputil.FunnelRequestHeader) == "" {
+		return reason, true
+	}
+	addr, _, ok := resolveSourceIP(r, s.preferRemoteIP)
+	if !ok {
+		return reason, true
+	}
+
+	s.scanners.mu.Lock()
+	_, banned := s.scanners.banned[addr]
+	if !banned && reason != "" {
+		if s.scanners.banned == nil {
+			s.scanners.banned = make(map[netip.Addr]struct{})
+		}
+		s.scanners.banned[addr] = struct{}{}
+		s.log().Warn("Scanner banned",
+			logging.Component("scanners"),
+			zap.String("source_ip", addr.String()),
+			zap.String("reason", reason),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+		)
+	}
+	s.scanners.mu.Unlock()
+
+	if !banned && reason == "" {
+		return reason, true
+	}
+	if reason == "" {
+		reason = ScannerBanned
+	}
+	s.stats.AddViolation(ViolationScannerBanned)
+	http.Error(w, "Forbidden", http.StatusForbidden)
+	return reason, false
+}
+
+// countsInStats reports whether a request tagged with a scanner reason
+// belongs in the headline stats.
+func (s *Server) countsInStats(reason string) bool {
+	return reason == "" || s.scanners.Stats
+}
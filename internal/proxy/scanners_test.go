@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPTagsAndBansScanners(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	newServer := func(scanners Scanners) *Server {
+		return NewServer(Config{
+			TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+			Mode:       model.ModeProxy,
+			UseTUI:     true,
+			Logger:     zap.NewNop(),
+			Scanners:   scanners,
+		})
+	}
+	serve := func(server *Server, path, clientIP string, funnel bool) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if funnel {
+			req.Header.Set("Tailscale-Funnel-Request", "?1")
+		}
+		req.Header.Set("Tailscale-Client-IP", clientIP)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	server := newServer(Scanners{})
+	for _, tc := range []struct {
+		path   string
+		funnel bool
+	}{{"/.env", true}, {"/", true}, {"/.env", false}} {
+		if code := serve(server, tc.path, "203.0.113.7", tc.funnel); code != http.StatusOK {
+			t.Fatalf("%s: expected scanners to be served without --scanner-ban, got %d", tc.path, code)
+		}
+	}
+	logs := server.GetRequestLogs()
+	if len(logs) != 3 || logs[0].Scanner != "path /.env" || logs[1].Scanner != "" || logs[2].Scanner != "" {
+		t.Fatalf("expected only the Funnel probe to be tagged, got %+v", logs)
+	}
+	if total, _, _, _, _, _ := server.GetStats(); total != 2 {
+		t.Fatalf("expected the probe to be left out of stats, got %d requests", total)
+	}
+
+	server = newServer(Scanners{Stats: true, Ban: true})
+	if code := serve(server, "/", "203.0.113.7", true); code != http.StatusOK {
+		t.Fatalf("expected a normal request before the probe to pass, got %d", code)
+	}
+	if code := serve(server, "/wp-login.php", "203.0.113.7", true); code != http.StatusForbidden {
+		t.Fatalf("expected the probe to be refused, got %d", code)
+	}
+	if code := serve(server, "/", "203.0.113.7", true); code != http.StatusForbidden {
+		t.Fatalf("expected later requests from the banned source to be refused, got %d", code)
+	}
+	if code := serve(server, "/", "198.51.100.1", true); code != http.StatusOK {
+		t.Fatalf("expected other sources to pass, got %d", code)
+	}
+	logs = server.GetRequestLogs()
+	if logs[2].Scanner != ScannerBanned {
+		t.Fatalf("expected the refused request to be tagged %q, got %q", ScannerBanned, logs[2].Scanner)
+	}
+	if total, _, _, _, _, _ := server.GetStats(); total != 4 {
+		t.Fatalf("expected scanners to be counted with Stats set, got %d requests", total)
+	}
+	if violations := server.GetViolations(); violations[ViolationScannerBanned] != 2 {
+		t.Fatalf("expected two banned requests counted, got %v", violations)
+	}
+}
@@ -0,0 +1,70 @@
+// internal/proxy/schema.go
+package proxy
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// observeSchema adds r's JSON body to the schema of its endpoint and returns
+// how it deviates from earlier deliveries. Replays resend old payloads and
+// are left out.
+func (s *Server) observeSchema(r *http.Request, requestID string, body []byte) []model.SchemaChange {
+	if len(body) == 0 || !isJSONContentType(r.Header.Get("Content-Type")) {
+		return nil
+	}
+	if _, replay := r.Context().Value(replayKey{}).(*replayState); replay {
+		return nil
+	}
+	changes := s.schemas.Observe(r.Method, r.URL.Path, body)
+	if len(changes) > 0 {
+		summary := make([]string, 0, len(changes))
+		for _, change := range changes {
+			summary = append(summary, describeSchemaChange(change))
+		}
+		s.log().Warn("Payload schema changed",
+			logging.Component("schema"),
+			logging.RequestID(requestID),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Strings("changes", summary),
+		)
+	}
+	return changes
+}
+
+// GetSchemas returns the payload schemas inferred per endpoint.
+func (s *Server) GetSchemas() []model.EndpointSchema {
+	return s.schemas.Schemas()
+}
+
+// ResetSchemas forgets the inferred schemas; the next delivery to each
+// endpoint starts a new one.
+func (s *Server) ResetSchemas() {
+	s.schemas.Reset()
+	s.log().Info("Payload schemas reset", logging.Component("schema"))
+}
+
+func describeSchemaChange(change model.SchemaChange) string {
+	switch change.Change {
+	case model.SchemaAdded:
+		return fmt.Sprintf("added %s (%s)", change.Field, change.Now)
+	case model.SchemaRemoved:
+		return fmt.Sprintf("removed %s (%s)", change.Field, change.Was)
+	}
+	return fmt.Sprintf("%s changed type from %s to %s", change.Field, change.Was, change.Now)
+}
+
+// isJSONContentType reports whether contentType is JSON, including
+// structured types such as application/vnd.api+json.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
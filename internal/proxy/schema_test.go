@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPFlagsSchemaChanges(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+	post := func(contentType, body string) model.RequestLog {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/orders?attempt=1", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		server.ServeHTTP(httptest.NewRecorder(), req)
+		logs := server.GetRequestLogs()
+		return logs[len(logs)-1]
+	}
+
+	post("application/json", `{"order_id":1,"total":9.5}`)
+	if entry := post("application/json; charset=utf-8", `{"order_id":2,"total":"9.50"}`); len(entry.SchemaChanges) != 1 || entry.SchemaChanges[0].Field != "$.total" {
+		t.Fatalf("expected the total's type change flagged, got %+v", entry.SchemaChanges)
+	}
+	if entry := post("text/plain", `{"order_id":"x"}`); entry.SchemaChanges != nil {
+		t.Fatalf("expected non-JSON content types ignored, got %+v", entry.SchemaChanges)
+	}
+	if entry := post("application/vnd.api+json", `{"order_id":3,"total":1}`); entry.SchemaChanges != nil {
+		t.Fatalf("expected a delivery matching the schema to pass, got %+v", entry.SchemaChanges)
+	}
+
+	schemas := server.GetSchemas()
+	if len(schemas) != 1 || schemas[0].Path != "/hooks/orders" || schemas[0].Deliveries != 3 {
+		t.Fatalf("unexpected schemas %+v", schemas)
+	}
+	server.ResetSchemas()
+	if len(server.GetSchemas()) != 0 {
+		t.Fatalf("expected schemas forgotten after reset")
+	}
+}
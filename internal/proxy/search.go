@@ -0,0 +1,50 @@
+// internal/proxy/search.go
+package proxy
+
+import (
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/search"
+)
+
+// searchTexts are the parts of entry full-text search covers: the text
+// request and response bodies and the values of multipart form fields.
+func searchTexts(entry model.RequestLog) []string {
+	texts := []string{entry.Body, entry.Response.Body}
+	for _, part := range entry.Multipart {
+		texts = append(texts, part.Value)
+	}
+	return texts
+}
+
+// indexRequestLogs adds entries to the search index and drops evicted, the
+// entries that left the history to make room. Called with logMutex held.
+func (s *Server) indexRequestLogs(entries, evicted []model.RequestLog) {
+	for _, entry := range evicted {
+		s.searchIndex.Remove(entry.ID)
+	}
+	for _, entry := range entries {
+		s.searchIndex.Add(entry.ID, searchTexts(entry)...)
+	}
+}
+
+// SearchRequests returns the captured requests whose bodies match query,
+// newest first, at most limit of them. Words match whole words ignoring
+// case, and "quoted phrases" must appear as written.
+func (s *Server) SearchRequests(query string, limit int) []model.RequestLog {
+	q := search.ParseQuery(query)
+	ids := s.searchIndex.Match(q)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	s.logMutex.RLock()
+	defer s.logMutex.RUnlock()
+	var results []model.RequestLog
+	for i := len(s.requestLog) - 1; i >= 0 && len(results) < limit; i-- {
+		entry := s.requestLog[i]
+		if _, ok := ids[entry.ID]; ok && q.MatchesPhrases(searchTexts(entry)...) {
+			results = append(results, entry)
+		}
+	}
+	return results
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestSearchRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"received":true}`))
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		MaxLogs:    3,
+	})
+	post := func(body string) {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	ids := func(results []model.RequestLog) []string {
+		var ids []string
+		for _, entry := range results {
+			ids = append(ids, entry.ID)
+		}
+		return ids
+	}
+
+	post(`{"order_id":12345,"event":"order.paid"}`)
+	post(`{"order_id":777,"event":"order.paid"}`)
+	post(`{"order_id":12345,"event":"order.shipped"}`)
+	logs := server.GetRequestLogs()
+
+	if got := ids(server.SearchRequests("order_id 12345", 10)); len(got) != 2 || got[0] != logs[2].ID || got[1] != logs[0].ID {
+		t.Fatalf("expected both 12345 deliveries, newest first, got %v", got)
+	}
+	if got := server.SearchRequests(`"order.shipped"`, 10); len(got) != 1 || got[0].ID != logs[2].ID {
+		t.Fatalf("expected the phrase to match one delivery, got %v", ids(got))
+	}
+	if got := server.SearchRequests("received", 1); len(got) != 1 {
+		t.Fatalf("expected response bodies searched and the limit kept, got %v", ids(got))
+	}
+
+	// The oldest delivery leaves the history, and the index, to make room.
+	post(`{"order_id":999}`)
+	if got := ids(server.SearchRequests("12345", 10)); len(got) != 1 || got[0] != logs[2].ID {
+		t.Fatalf("expected the evicted delivery gone from search, got %v", got)
+	}
+
+	server.ClearRequestLogs()
+	if got := server.SearchRequests("order_id", 10); len(got) != 0 {
+		t.Fatalf("expected no results after clearing, got %v", ids(got))
+	}
+	if n := server.ImportRequestLogs([]model.RequestLog{{Body: `{"order_id":12345}`}}); n != 1 {
+		t.Fatalf("expected one import, got %d", n)
+	}
+	if got := server.SearchRequests("12345", 10); len(got) != 1 || !got[0].Imported {
+		t.Fatalf("expected imported requests searchable, got %v", ids(got))
+	}
+}
@@ -0,0 +1,125 @@
+// internal/proxy/sensitive.go
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// DefaultApprovalTimeout is how long a sensitive request waits for a
+// decision before it is denied.
+const DefaultApprovalTimeout = 30 * time.Second
+
+// Approver asks the operator whether a sensitive request may proceed. It
+// returns false if ctx ends before a decision.
+type Approver func(ctx context.Context, req model.SensitiveRequest) bool
+
+// Sensitive marks paths whose requests are flagged to the operator and,
+// with RequireApproval, held until approved.
+type Sensitive struct {
+	// Paths are prefixes ("/admin") or path.Match globs ("/users/*/delete").
+	Paths           []string
+	RequireApproval bool
+	// ApprovalTimeout defaults to DefaultApprovalTimeout.
+	ApprovalTimeout time.Duration
+}
+
+type sensitiveGate struct {
+	Sensitive
+
+	mu       sync.RWMutex
+	approver Approver
+}
+
+// SetApprover sets how sensitive requests are approved. Without one,
+// requests that need approval are denied.
+func (s *Server) SetApprover(approver Approver) {
+	s.sensitive.mu.Lock()
+	defer s.sensitive.mu.Unlock()
+	s.sensitive.approver = approver
+}
+
+// matchSensitive returns the first sensitive pattern matching requestPath.
+func (s *Server) matchSensitive(requestPath string) (string, bool) {
+	for _, pattern := range s.sensitive.Paths {
+		if strings.ContainsAny(pattern, "*?[") {
+			if ok, _ := path.Match(pattern, requestPath); ok {
+				return pattern, true
+			}
+			continue
+		}
+		if requestPath == pattern || strings.HasPrefix(requestPath, strings.TrimSuffix(pattern, "/")+"/") {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// checkSensitive flags requests to sensitive paths and, when approval is
+// required, waits for the operator. Denied or timed-out requests get a 403
+// and checkSensitive returns false.
+func (s *Server) checkSensitive(w http.ResponseWriter, r *http.Request, requestID string) bool {
+	pattern, ok := s.matchSensitive(r.URL.Path)
+	if !ok {
+		return true
+	}
+
+	req := model.SensitiveRequest{
+		ID:         requestID,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		RemoteAddr: r.RemoteAddr,
+		Pattern:    pattern,
+	}
+	s.log().Warn("Sensitive request",
+		logging.Component("sensitive_paths"),
+		zap.String("method", req.Method),
+		zap.String("path", req.Path),
+		zap.String("remote_addr", req.RemoteAddr),
+		zap.String("pattern", pattern),
+		zap.Bool("requires_approval", s.sensitive.RequireApproval),
+	)
+	if !s.sensitive.RequireApproval {
+		return true
+	}
+
+	s.sensitive.mu.RLock()
+	approver := s.sensitive.approver
+	s.sensitive.mu.RUnlock()
+
+	timeout := s.sensitive.ApprovalTimeout
+	if timeout <= 0 {
+		timeout = DefaultApprovalTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	if approver != nil && approver(ctx, req) {
+		s.log().Info("Sensitive request approved",
+			logging.Component("sensitive_paths"),
+			zap.String("request_id", requestID),
+		)
+		return true
+	}
+
+	reason := "denied"
+	if ctx.Err() != nil {
+		reason = "approval_timeout"
+	}
+	s.log().Warn("Sensitive request denied",
+		logging.Component("sensitive_paths"),
+		zap.String("request_id", requestID),
+		zap.String("deny_reason", reason),
+	)
+	http.Error(w, "Forbidden: request was not approved", http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func newSensitiveTestServer(t *testing.T, sensitive Sensitive) *Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(upstream.Close)
+
+	return NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Sensitive:  sensitive,
+	})
+}
+
+func TestMatchSensitivePrefixesAndGlobs(t *testing.T) {
+	server := newSensitiveTestServer(t, Sensitive{Paths: []string{"/admin", "/users/*/delete"}})
+
+	cases := map[string]bool{
+		"/admin":             true,
+		"/admin/users":       true,
+		"/administrator":     false,
+		"/users/42/delete":   true,
+		"/users/42/profile":  false,
+		"/users/42/delete/x": false,
+	}
+	for requestPath, want := range cases {
+		if _, got := server.matchSensitive(requestPath); got != want {
+			t.Fatalf("%s: expected sensitive=%t", requestPath, want)
+		}
+	}
+}
+
+func TestServeHTTPSensitiveApproval(t *testing.T) {
+	server := newSensitiveTestServer(t, Sensitive{Paths: []string{"/admin"}, RequireApproval: true})
+
+	var asked []model.SensitiveRequest
+	decision := true
+	server.SetApprover(func(ctx context.Context, req model.SensitiveRequest) bool {
+		asked = append(asked, req)
+		return decision
+	})
+
+	serve := func(p string) int {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, p, nil))
+		return rr.Code
+	}
+
+	if code := serve("/public"); code != http.StatusOK || len(asked) != 0 {
+		t.Fatalf("expected normal traffic to pass without approval, got %d asked=%d", code, len(asked))
+	}
+	if code := serve("/admin/users"); code != http.StatusOK {
+		t.Fatalf("expected approved request to be proxied, got %d", code)
+	}
+	if len(asked) != 1 || asked[0].Path != "/admin/users" || asked[0].Pattern != "/admin" || asked[0].ID == "" {
+		t.Fatalf("unexpected approval request %+v", asked)
+	}
+
+	decision = false
+	if code := serve("/admin"); code != http.StatusForbidden {
+		t.Fatalf("expected denied request to get 403, got %d", code)
+	}
+}
+
+func TestServeHTTPSensitiveApprovalTimesOut(t *testing.T) {
+	server := newSensitiveTestServer(t, Sensitive{Paths: []string{"/admin"}, RequireApproval: true, ApprovalTimeout: 20 * time.Millisecond})
+	server.SetApprover(func(ctx context.Context, req model.SensitiveRequest) bool {
+		<-ctx.Done()
+		return false
+	})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 after approval timeout, got %d", rr.Code)
+	}
+}
+
+func TestServeHTTPSensitiveWithoutApprovalOnlyFlags(t *testing.T) {
+	server := newSensitiveTestServer(t, Sensitive{Paths: []string{"/admin"}})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected flagged request to be proxied, got %d", rr.Code)
+	}
+}
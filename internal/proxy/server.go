@@ -3,25 +3,36 @@ package proxy
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/netip"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unicode/utf8"
 
-	tea "github.com/charmbracelet/bubbletea"
 	"go.uber.org/zap"
 
+	"github.com/jaxxstorm/portal/internal/annotations"
+	"github.com/jaxxstorm/portal/internal/chaos"
+	porthttputil "github.com/jaxxstorm/portal/internal/httputil"
+	"github.com/jaxxstorm/portal/internal/jwt"
 	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/middleware"
+	"github.com/jaxxstorm/portal/internal/mock"
 	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/schema"
+	"github.com/jaxxstorm/portal/internal/search"
 	"github.com/jaxxstorm/portal/internal/stats"
+	"github.com/jaxxstorm/portal/internal/wire"
 )
 
 // LoggingResponseWriter wraps http.ResponseWriter to capture response information
@@ -29,16 +40,37 @@ type LoggingResponseWriter struct {
 	http.ResponseWriter
 	statusCode    int
 	size          int64
-	headers       map[string]string
+	headers       model.Headers
+	informational []model.Informational
+	trailers      model.Headers
 	bodyPreview   []byte
 	bodyTruncated bool
 }
 
 const maxResponseBodyPreviewBytes = 256 * 1024
 
-// WriteHeader captures the status code
+// WriteHeader captures the status code and the headers sent with it.
+// Informational responses are captured too but leave the final status to
+// come, and 101 Switching Protocols is final.
 func (lrw *LoggingResponseWriter) WriteHeader(code int) {
-	lrw.statusCode = code
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		lrw.informational = append(lrw.informational, model.Informational{
+			StatusCode: code,
+			Headers:    model.HeadersFrom(lrw.Header()),
+		})
+		// net/http answers the client's Expect: 100-continue itself when the
+		// body is read, which happens before proxying, so a 100 from
+		// upstream would be a second one.
+		if code == http.StatusContinue {
+			return
+		}
+		lrw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if lrw.statusCode == 0 {
+		lrw.statusCode = code
+		lrw.captureHeaders()
+	}
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
@@ -46,6 +78,7 @@ func (lrw *LoggingResponseWriter) WriteHeader(code int) {
 func (lrw *LoggingResponseWriter) Write(b []byte) (int, error) {
 	if lrw.statusCode == 0 {
 		lrw.statusCode = 200
+		lrw.captureHeaders()
 	}
 
 	remaining := maxResponseBodyPreviewBytes - len(lrw.bodyPreview)
@@ -65,103 +98,375 @@ func (lrw *LoggingResponseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Flush sends what has been written so far, so streamed responses reach
+// the client as they arrive from upstream.
+func (lrw *LoggingResponseWriter) Flush() {
+	if lrw.statusCode == 0 {
+		lrw.statusCode = 200
+		lrw.captureHeaders()
+	}
+	_ = http.NewResponseController(lrw.ResponseWriter).Flush()
+}
+
 // Header returns the response headers
 func (lrw *LoggingResponseWriter) Header() http.Header {
 	return lrw.ResponseWriter.Header()
 }
 
-// captureHeaders captures response headers for logging
+// captureHeaders captures response headers for logging. They are taken
+// when the status is written, since values set afterwards are trailers.
 func (lrw *LoggingResponseWriter) captureHeaders() {
-	lrw.headers = make(map[string]string)
-	for k, v := range lrw.ResponseWriter.Header() {
-		lrw.headers[k] = strings.Join(v, ", ")
+	lrw.headers = model.HeadersFrom(lrw.ResponseWriter.Header())
+}
+
+// captureTrailers captures the trailers set after the body: those the
+// response announced in its Trailer header and any named with
+// http.TrailerPrefix.
+func (lrw *LoggingResponseWriter) captureTrailers() {
+	header := lrw.ResponseWriter.Header()
+	trailers := http.Header{}
+	for _, announced := range lrw.headers.Values("Trailer") {
+		for name := range strings.SplitSeq(announced, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if values := header[name]; len(values) > 0 {
+				trailers[name] = values
+			}
+		}
+	}
+	for name, values := range header {
+		if trailer, ok := strings.CutPrefix(name, http.TrailerPrefix); ok {
+			trailers[http.CanonicalHeaderKey(trailer)] = values
+		}
+	}
+	if len(trailers) > 0 {
+		lrw.trailers = model.HeadersFrom(trailers)
 	}
 }
 
 // Server handles HTTP requests with logging and optional proxying
 type Server struct {
-	logger          *zap.Logger
-	sugarLogger     *zap.SugaredLogger
-	proxy           *httputil.ReverseProxy
-	targetURL       *url.URL
-	requestLog      []model.RequestLog
-	logMutex        sync.RWMutex
-	program         *tea.Program
-	useTUI          bool
-	mode            model.ServerMode
-	stats           *stats.Tracker
-	requestID       int64
-	endpoint        model.EndpointState
-	endpointMu      sync.RWMutex
-	maxLogsCap      int                      // Maximum number of logs to keep
-	listeners       []func(model.RequestLog) // Event listeners for new requests
-	funnelEnabled   bool
-	funnelAllowlist []netip.Prefix
-	preferRemoteIP  bool
+	runtime        runtimeState
+	connections    connectionTracker
+	proxy          *httputil.ReverseProxy
+	targetURL      *url.URL
+	requestLog     []model.RequestLog
+	searchIndex    *search.Index
+	schemas        *schema.Tracker
+	logMutex       sync.RWMutex
+	useTUI         bool
+	mode           model.ServerMode
+	stats          *stats.Tracker
+	requestID      int64
+	endpoint       model.EndpointState
+	endpointMu     sync.RWMutex
+	maxLogsCap     int // Maximum number of logs to keep
+	funnelEnabled  bool
+	funnelPaths    []string
+	limits         Limits
+	transport      Transport
+	statusEndpoint bool
+	uiPath         string
+	ui             http.Handler
+	version        string
+	pprof          bool
+	budget         shareBudget
+	sensitive      sensitiveGate
+	scanners       scannerGuard
+	geoip          GeoLocator
+	wire           *wire.Recorder
+	duplicates     duplicateTracker
+	oauth          OAuth
+	startedAt      time.Time
+	preferRemoteIP bool
+	captureSink    RequestSink
+	// capturePaused keeps new requests out of the history while set;
+	// captureSkipped counts them.
+	capturePaused  atomic.Bool
+	captureSkipped atomic.Int64
+	mockEngine     *mock.Engine
+	recorder       *mock.Recording
+	externalScheme string
+	dial           DialFunc
+	loopback       *porthttputil.LoopbackDialer
+	dialMu         sync.RWMutex
+	settings       atomic.Pointer[runtimeSettings]
+	jwks           *jwt.KeySet
+	exposes        []*exposeRoute
+	middleware     *middleware.Chain
+	timeouts       UpstreamTimeouts
+	quirks         UpstreamQuirks
+	identities     identityTracker
+	quotas         quotaTracker
+	annotations    *annotations.Store
+	chaos          *chaos.Engine
+	// noRequestBodies and noResponseBodies drop bodies from captured
+	// requests, for each direction.
+	noRequestBodies  atomic.Bool
+	noResponseBodies atomic.Bool
+}
+
+// RequestSink receives a copy of every captured request, independent of the
+// in-memory history cap.
+type RequestSink interface {
+	Write(model.RequestLog) error
 }
 
 // Config holds configuration for the proxy server
 type Config struct {
-	TargetPort      int
+	TargetPort int
+	// TargetHost is the host to proxy to (default: localhost). Remote tailnet
+	// targets are reached through Dial.
+	TargetHost string
+	// LoopbackHost is dialled when the target is localhost, e.g. "::1".
+	// When empty, each loopback address is tried in turn.
+	LoopbackHost    string
 	UseTUI          bool
 	Mode            model.ServerMode
 	Logger          *zap.Logger
 	MaxLogs         int // Maximum number of logs to keep (default: 1000)
 	FunnelEnabled   bool
 	FunnelAllowlist []netip.Prefix
+	// AllowMethods, when set, answers requests with other methods with 405.
+	AllowMethods    []string
 	PreferRemoteIP  bool
 	InitialEndpoint model.EndpointState
+	CaptureSink     RequestSink
+	MockEngine      *mock.Engine    // Optional latency/error profiles for mock mode
+	Recorder        *mock.Recording // Optional recording of upstream responses for later playback
+	CORS            middleware.CORSConfig
+	// PreserveHost forwards the client's Host header unchanged instead of
+	// rewriting it to the target address.
+	PreserveHost bool
+	// UpstreamHost, when set, is sent as the Host header to the target.
+	UpstreamHost string
+	// ExternalScheme is the scheme clients use to reach portal ("http" or
+	// "https"), reported in X-Forwarded-Proto. Inferred per request when empty;
+	// Funnel requests are always "https".
+	ExternalScheme string
+	// ForwardedHeader appends an RFC 7239 Forwarded header for the target.
+	ForwardedHeader bool
+	// Cookies rewrites the target's Set-Cookie headers for the portal URL.
+	Cookies CookieRewrite
+	// KeepLocation leaves absolute redirects to the target as sent instead
+	// of pointing them at the URL the client used.
+	KeepLocation bool
+	// RewriteHTML points absolute URLs to the target in HTML responses at
+	// the URL the client used.
+	RewriteHTML bool
+	// Dial connects to the target. It defaults to a loopback dial (see
+	// LoopbackHost) and can be replaced later with SetUpstreamDialer.
+	Dial DialFunc
+	// Mounts route path prefixes to other upstreams. With mounts, TargetPort
+	// may be zero, in which case unmatched paths get a 404.
+	Mounts []Mount
+	// Unmatched overrides how requests that match no mount are answered.
+	Unmatched Unmatched
+	// Quotas limit how many requests each tailnet user, tag or client may
+	// send per period.
+	Quotas []Quota
+	// Exclude keeps matching requests out of the history, stats and logs.
+	Exclude []Exclude
+	// Exposes proxy requests served through ExposeHandler to their own
+	// targets. With exposes, TargetPort may be zero too.
+	Exposes []Expose
+	// FunnelPaths are the only path prefixes served to Funnel (public)
+	// requests; other paths stay tailnet-only.
+	FunnelPaths []string
+	// Limits bound request sizes and client timeouts.
+	Limits Limits
+	// Transport tunes upstream connection reuse and dialing.
+	Transport Transport
+	// StatusEndpoint serves StatusPath for external monitors.
+	StatusEndpoint bool
+	// Version is reported by the status endpoint.
+	Version string
+	// Pprof exposes Go runtime profiles on the web UI.
+	Pprof bool
+	// Sensitive flags requests to sensitive paths and optionally holds them
+	// for approval.
+	Sensitive Sensitive
+	// Duplicates flags repeated webhook deliveries and optionally answers
+	// them without forwarding.
+	Duplicates Duplicates
+	// MaxRequests expires the share once this many requests have been
+	// served. Zero means no limit.
+	MaxRequests int
+	// OAuth answers OAuth redirects on a callback path instead of
+	// forwarding them.
+	OAuth OAuth
+	// JWKSURL, when set, is used to verify the signatures of bearer JWTs
+	// shown in request details.
+	JWKSURL string
+	// Timeouts bound upstream requests, globally and per route.
+	Timeouts UpstreamTimeouts
+	// Quirks work around legacy upstreams, globally and per route.
+	Quirks UpstreamQuirks
+	// Middleware runs around the handling of every request that passed the
+	// built-in checks, first spec outermost.
+	Middleware []middleware.Spec
+	// Chaos injects latency and failures into matching requests. Its rules
+	// can be changed while serving.
+	Chaos *chaos.Engine
+	// Scanners controls how Funnel requests from internet scanners are
+	// counted and whether their sources are banned.
+	Scanners Scanners
+	// GeoIP, when set, looks up the country and network of public clients.
+	GeoIP GeoLocator
+	// Wire, when set, records the bytes exchanged on the connections of
+	// listeners passed through WrapListener.
+	Wire *wire.Recorder
+	// NoRequestBodies and NoResponseBodies keep that direction's bodies out
+	// of the history, the capture file and listeners. They can be changed
+	// later with SetBodyCapture.
+	NoRequestBodies  bool
+	NoResponseBodies bool
 }
 
+// DialFunc opens a connection to the upstream target.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
 // NewServer creates a new proxy server
 func NewServer(config Config) *Server {
 	var targetURL *url.URL
-	var proxy *httputil.ReverseProxy
 
 	maxLogs := config.MaxLogs
 	if maxLogs <= 0 {
 		maxLogs = 1000 // Default
 	}
 
-	if config.Mode == model.ModeProxy {
+	if config.Mode == model.ModeProxy && (config.TargetPort > 0 || len(config.Mounts) == 0 && len(config.Exposes) == 0) {
+		targetHost := config.TargetHost
+		if targetHost == "" {
+			targetHost = "localhost"
+		}
 		targetURL = &url.URL{
 			Scheme: "http",
-			Host:   fmt.Sprintf("localhost:%d", config.TargetPort),
+			Host:   net.JoinHostPort(targetHost, strconv.Itoa(config.TargetPort)),
 		}
+	}
 
-		proxy = httputil.NewSingleHostReverseProxy(targetURL)
+	server := &Server{
+		runtime:        runtimeState{logger: config.Logger},
+		targetURL:      targetURL,
+		requestLog:     make([]model.RequestLog, 0),
+		searchIndex:    search.NewIndex(),
+		schemas:        schema.NewTracker(),
+		useTUI:         config.UseTUI,
+		mode:           config.Mode,
+		stats:          stats.NewTracker(),
+		requestID:      0,
+		endpoint:       config.InitialEndpoint,
+		maxLogsCap:     maxLogs,
+		funnelEnabled:  config.FunnelEnabled,
+		funnelPaths:    config.FunnelPaths,
+		limits:         config.Limits,
+		transport:      config.Transport,
+		statusEndpoint: config.StatusEndpoint,
+		version:        config.Version,
+		pprof:          config.Pprof,
+		startedAt:      time.Now(),
+		preferRemoteIP: config.PreferRemoteIP,
+		captureSink:    config.CaptureSink,
+		mockEngine:     config.MockEngine,
+		recorder:       config.Recorder,
+		externalScheme: config.ExternalScheme,
+		dial:           config.Dial,
+		loopback:       &porthttputil.LoopbackDialer{Host: config.LoopbackHost},
+		timeouts:       config.Timeouts,
+		quirks:         config.Quirks,
+		annotations:    annotations.New(maxLogs),
+		chaos:          config.Chaos,
+		geoip:          config.GeoIP,
+		wire:           config.Wire,
+	}
+	server.budget.maxRequests = int64(config.MaxRequests)
+	server.noRequestBodies.Store(config.NoRequestBodies)
+	server.noResponseBodies.Store(config.NoResponseBodies)
+	server.sensitive.Sensitive = config.Sensitive
+	server.scanners.Scanners = config.Scanners
+	server.duplicates.Duplicates = config.Duplicates
+	server.oauth = config.OAuth
+	if config.JWKSURL != "" {
+		server.jwks = jwt.NewKeySet(config.JWKSURL)
+	}
+	if targetURL != nil {
+		server.proxy = server.newReverseProxy(targetURL, "", server.dialUpstream)
+	}
+	if config.Mode == model.ModeProxy {
+		server.buildExposes(config.Exposes)
+	}
+	server.buildMiddleware(config.Middleware)
+	server.ApplySettings(Settings{
+		CORS:            config.CORS,
+		FunnelAllowlist: config.FunnelAllowlist,
+		AllowMethods:    config.AllowMethods,
+		Mounts:          config.Mounts,
+		Unmatched:       config.Unmatched,
+		Quotas:          config.Quotas,
+		Exclude:         config.Exclude,
+		PreserveHost:    config.PreserveHost,
+		UpstreamHost:    config.UpstreamHost,
+		ForwardedHeader: config.ForwardedHeader,
+		Cookies:         config.Cookies,
+		KeepLocation:    config.KeepLocation,
+		RewriteHTML:     config.RewriteHTML,
+	})
+	return server
+}
 
-		// Customize the director to preserve original headers
-		originalDirector := proxy.Director
-		proxy.Director = func(req *http.Request) {
-			originalDirector(req)
-			req.Header.Set("X-Forwarded-Proto", "https")
-			req.Header.Set("X-Forwarded-Host", req.Host)
+// newReverseProxy builds a reverse proxy to target. stripPrefix is removed
+// from the request path before target's own path is prepended.
+func (s *Server) newReverseProxy(target *url.URL, stripPrefix string, dial DialFunc) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	// Customize the director to preserve original headers
+	originalDirector := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		requestPath := req.URL.Path
+		originalDirector(req)
+		if stripPrefix != "" {
+			req.URL.Path = mountPath(target.Path, requestPath, stripPrefix)
+			req.URL.RawPath = ""
+		}
+		settings := s.currentSettings()
+		setForwardingHeaders(req, s.requestScheme(req), settings.ForwardedHeader)
+		if settings.RewriteHTML {
+			// Without the client's Accept-Encoding the transport asks for
+			// gzip itself and hands back the decoded body to rewrite.
+			req.Header.Del("Accept-Encoding")
+		}
+
+		// Local frameworks commonly reject the tailnet DNS name (Rails
+		// host authorization, Django ALLOWED_HOSTS), so the target sees
+		// its own address unless told otherwise.
+		switch {
+		case settings.UpstreamHost != "":
+			req.Host = settings.UpstreamHost
+		case !settings.PreserveHost:
+			req.Host = target.Host
 		}
 	}
 
-	return &Server{
-		logger:          config.Logger,
-		sugarLogger:     config.Logger.Sugar(),
-		proxy:           proxy,
-		targetURL:       targetURL,
-		requestLog:      make([]model.RequestLog, 0),
-		useTUI:          config.UseTUI,
-		mode:            config.Mode,
-		stats:           stats.NewTracker(),
-		requestID:       0,
-		endpoint:        config.InitialEndpoint,
-		maxLogsCap:      maxLogs,
-		listeners:       make([]func(model.RequestLog), 0),
-		funnelEnabled:   config.FunnelEnabled,
-		funnelAllowlist: config.FunnelAllowlist,
-		preferRemoteIP:  config.PreferRemoteIP,
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		return s.modifyResponse(resp, target, stripPrefix)
 	}
+	proxy.ErrorHandler = s.proxyError
+	proxy.Transport = &quirkTransport{base: s.transport.newTransport(dial)}
+	return proxy
 }
 
-// SetProgram sets the TUI program for sending messages
-func (s *Server) SetProgram(p *tea.Program) {
-	s.program = p
+// TargetURL returns the upstream URL requests are forwarded to, or "" in
+// mock mode and for mount-only configurations.
+func (s *Server) TargetURL() string {
+	if s.targetURL == nil || s.mode != model.ModeProxy {
+		return ""
+	}
+	return s.targetURL.String()
+}
+
+// PprofEnabled reports whether the web UI serves /debug/pprof.
+func (s *Server) PprofEnabled() bool {
+	return s.pprof
 }
 
 // SetWebUIURL stores the web UI URL for display
@@ -191,9 +496,26 @@ func (s *Server) GetWebUIURL() string {
 func (s *Server) SetEndpointState(state model.EndpointState) {
 	s.endpointMu.Lock()
 	defer s.endpointMu.Unlock()
+	if state.Upstream == "" {
+		state.Upstream = s.endpoint.Upstream
+	}
+	if state.Cert == "" {
+		state.Cert = s.endpoint.Cert
+	}
+	if state.Mounts == "" {
+		state.Mounts = s.endpoint.Mounts
+	}
+	state.Exposes = s.exposeTable()
 	s.endpoint = state
 }
 
+// SetCertStatus reports the progress of fetching the HTTPS certificate.
+func (s *Server) SetCertStatus(status string) {
+	s.endpointMu.Lock()
+	defer s.endpointMu.Unlock()
+	s.endpoint.Cert = status
+}
+
 func (s *Server) GetEndpointState() model.EndpointState {
 	s.endpointMu.RLock()
 	defer s.endpointMu.RUnlock()
@@ -214,83 +536,181 @@ func (s *Server) MarkEndpointFailure(reason string) {
 	}
 }
 
-// AddListener adds a listener function that will be called for each new request
-func (s *Server) AddListener(listener func(model.RequestLog)) {
-	if listener != nil {
-		s.listeners = append(s.listeners, listener)
-	}
-}
-
-// ReplaceLogger replaces the current logger with a new one
-func (s *Server) ReplaceLogger(logger *zap.Logger) {
-	s.logger = logger
-	s.sugarLogger = logger.Sugar()
-}
-
 // nextRequestID generates a unique request ID
 func (s *Server) nextRequestID() string {
 	id := atomic.AddInt64(&s.requestID, 1)
-	return fmt.Sprintf("req_%d_%d", time.Now().Unix(), id)
+	return fmt.Sprintf("req_%d_%d", s.mockNow().Unix(), id)
 }
 
 // ServeHTTP implements the http.Handler interface
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.countConnectionRequest(r)
+	if s.statusEndpoint && r.URL.Path == StatusPath {
+		s.serveStatus(w, r)
+		return
+	}
+	if s.serveUI(w, r) {
+		return
+	}
+	excluded := s.excluded(r)
+	allowed, lastRequest := s.takeRequest(w, excluded)
+	if !allowed {
+		return
+	}
+	if lastRequest {
+		defer s.Expire(ExpiryMaxRequests)
+	}
+
 	start := time.Now()
 	requestID := s.nextRequestID()
 
 	// Track connection stats
-	s.stats.IncrementOpen()
-	defer s.stats.DecrementOpen()
+	if !excluded {
+		s.stats.IncrementOpen()
+		defer s.stats.DecrementOpen()
+	}
 
 	// Create logging response writer
-	lrw := &LoggingResponseWriter{
-		ResponseWriter: w,
-		statusCode:     0,
-		size:           0,
-		headers:        make(map[string]string),
-		bodyPreview:    make([]byte, 0),
-		bodyTruncated:  false,
-	}
+	// headers and bodyPreview are allocated lazily by captureHeaders and
+	// Write.
+	lrw := &LoggingResponseWriter{ResponseWriter: w}
+
+	withinLimits := s.enforceRequestLimits(lrw, r)
 
 	// Read request body for logging (if not too large)
 	var bodyBytes []byte
 	var bodyString string
-	if r.Body != nil && r.ContentLength < 10*1024*1024 { // Limit to 10MB
-		bodyBytes, _ = io.ReadAll(r.Body)
+	if withinLimits && r.Body != nil && r.ContentLength < 10*1024*1024 { // Limit to 10MB
+		var readErr error
+		bodyBytes, readErr = io.ReadAll(r.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(readErr, &tooLarge) {
+			s.countViolation(ViolationBodyTooLarge, r.RemoteAddr, r.URL.Path)
+			http.Error(lrw, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			withinLimits = false
+		} else if isTimeout(readErr) {
+			s.countViolation(ViolationSlowClient, r.RemoteAddr, r.URL.Path)
+			http.Error(lrw, "Request Timeout", http.StatusRequestTimeout)
+			withinLimits = false
+		}
 		bodyString = string(bodyBytes)
 		r.Body = io.NopCloser(strings.NewReader(bodyString))
 	}
 
 	// Capture request headers
-	reqHeaders := make(map[string]string)
-	for k, v := range r.Header {
-		reqHeaders[k] = strings.Join(v, ", ")
-	}
+	reqHeaders := model.HeadersFrom(r.Header)
 
 	// Log application-level events using the same pattern as other components
-	s.logger.Info("Request received",
-		logging.Component("proxy_server"),
-		zap.String("method", r.Method),
-		zap.String("path", r.URL.Path),
-		zap.String("remote_addr", r.RemoteAddr),
-	)
+	if !excluded {
+		s.logRequest("Request received",
+			logging.Component("proxy_server"),
+			logging.RequestID(requestID),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.String("remote_addr", r.RemoteAddr),
+		)
+	}
+
+	identity := s.identify(r)
 
-	if s.enforceFunnelAllowlist(lrw, r) {
-		// Handle request based on mode
-		switch s.mode {
-		case model.ModeMock:
-			s.handleMockRequest(lrw, r, bodyString)
-		case model.ModeProxy:
-			s.proxy.ServeHTTP(lrw, r)
+	scannerReason, sourceAllowed := s.detectScanner(r), true
+	if withinLimits {
+		scannerReason, sourceAllowed = s.enforceScannerBan(lrw, r, scannerReason)
+	}
+
+	var duplicateOf string
+	var schemaChanges []model.SchemaChange
+	if withinLimits {
+		duplicateOf = s.detectDuplicate(r, requestID, bodyBytes)
+		if !excluded {
+			schemaChanges = s.observeSchema(r, requestID, bodyBytes)
 		}
 	}
-	// Capture response headers after serving
-	lrw.captureHeaders()
+
+	var timing *model.Timing
+	var oauth *model.OAuthCallback
+	var chaosRules []string
+	expose := s.matchExpose(r)
+	if withinLimits && sourceAllowed && s.enforceFunnelPaths(lrw, r) && s.enforceFunnelAllowlist(lrw, r) && !s.handleCORS(lrw, r) && s.enforceAllowedMethods(lrw, r) && s.enforceQuota(lrw, r, identity) && s.checkSensitive(lrw, r, requestID) && !s.skipDuplicate(lrw, duplicateOf) {
+		// The handler may still be running in the timeout middleware's
+		// goroutine after the chain returns, so its results come back over
+		// a channel rather than through shared variables.
+		type served struct {
+			timing *model.Timing
+			oauth  *model.OAuthCallback
+			chaos  []string
+		}
+		results := make(chan served, 1)
+		handle := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var result served
+			defer func() { results <- result }()
+
+			fault := s.chaos.Decide(r)
+			result.chaos = fault.Rules
+			if !s.injectFault(w, r, fault) {
+				return
+			}
+
+			// Handle request based on mode
+			switch {
+			case s.matchOAuthCallback(r):
+				result.oauth = s.serveOAuthCallback(w, r)
+			case s.mode == model.ModeMock:
+				s.handleMockRequest(w, r, bodyString)
+			case s.mode == model.ModeProxy:
+				if s.recorder != nil {
+					r = withRecordKey(r, bodyBytes)
+				}
+				upstreamReq, trace := traceUpstream(r)
+				upstreamReq = s.withQuirks(upstreamReq)
+				upstreamReq, finish := s.withUpstreamTimeouts(upstreamReq)
+				defer finish()
+				if expose != nil {
+					expose.proxy.ServeHTTP(w, upstreamReq)
+				} else if mount := s.matchMount(r.URL.Path); mount != nil {
+					mount.proxy.ServeHTTP(w, upstreamReq)
+				} else if s.serveUnmatched(w, r, upstreamReq) {
+					// Answered as configured for paths no mount matches.
+				} else if s.proxy == nil {
+					http.Error(w, "no mount matches "+r.URL.Path, http.StatusNotFound)
+				} else if s.upstreamWaiting() {
+					s.writeUpstreamWaiting(w)
+				} else {
+					s.proxy.ServeHTTP(w, upstreamReq)
+				}
+				result.timing = trace.timing(time.Now())
+			}
+		})
+		s.middleware.Then(handle).ServeHTTP(lrw, r)
+		select {
+		case result := <-results:
+			timing, oauth, chaosRules = result.timing, result.oauth, result.chaos
+		default:
+		}
+	}
+	// A response that was never written is an empty 200, which net/http
+	// sends with the headers as they are now.
+	if lrw.statusCode == 0 {
+		lrw.captureHeaders()
+	} else {
+		lrw.captureTrailers()
+	}
 
 	duration := time.Since(start)
+	if excluded {
+		return
+	}
 
 	// Add to stats
-	s.stats.AddRequest(duration)
+	geo := s.locate(r)
+	if s.countsInStats(scannerReason) {
+		s.stats.AddRequest(duration)
+		s.stats.AddResponse(lrw.statusCode, r.URL.Path)
+		if geo != nil && geo.Country != "" {
+			s.stats.AddCountry(geo.Country)
+		}
+	}
+	s.recordPresence(identity, start)
 
 	// Create request log entry
 	logEntry := model.RequestLog{
@@ -308,18 +728,55 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Response: model.ResponseLog{
 			StatusCode:    lrw.statusCode,
 			Headers:       lrw.headers,
-			Body:          formatResponseBodyPreview(lrw.headers, lrw.bodyPreview),
 			BodyTruncated: lrw.bodyTruncated,
 			Size:          lrw.size,
+			Informational: lrw.informational,
+			Trailers:      lrw.trailers,
 		},
-		Duration: duration,
+		Duration:    duration,
+		Timing:      timing,
+		DuplicateOf: duplicateOf,
+		JWT:         s.decodeJWT(r.Context(), reqHeaders.Get("Authorization")),
+		OAuth:       oauth,
+		Identity:    identity,
+		Chaos:       chaosRules,
+		Scanner:     scannerReason,
+		GeoIP:       geo,
+
+		SchemaChanges: schemaChanges,
+	}
+	if len(r.Trailer) > 0 {
+		// Trailers are only known once the body has been read, and names
+		// the request announced but never sent have no values.
+		if trailers := model.HeadersFrom(r.Trailer); len(trailers) > 0 {
+			logEntry.Trailers = trailers
+		}
+	}
+	if expose != nil {
+		logEntry.Expose = expose.Name
+	}
+	var responseTruncated bool
+	logEntry.Response.Body, logEntry.Response.BodyBytes, responseTruncated = responseBody(lrw.headers, lrw.bodyPreview)
+	logEntry.Response.BodyTruncated = logEntry.Response.BodyTruncated || responseTruncated
+	if parts := parseMultipart(logEntry.ContentType, bodyBytes); parts != nil {
+		logEntry.Body = ""
+		logEntry.Multipart = parts
+	} else if isBinaryBody(logEntry.ContentType, bodyBytes) {
+		logEntry.Body = ""
+		logEntry.BodyBytes, logEntry.BodyTruncated = binaryPrefix(bodyBytes)
+	}
+
+	if replay, ok := r.Context().Value(replayKey{}).(*replayState); ok {
+		logEntry.ReplayOf = replay.of
+		replay.entry = logEntry
 	}
 
 	// Store log entry and notify listeners
 	s.captureRequest(logEntry)
 
 	// Log application-level response events with proper structured format
-	s.logger.Info("Request completed",
+	s.logRequest("Request completed",
+		logging.RequestID(requestID),
 		zap.Int("status_code", lrw.statusCode),
 		zap.Duration("duration", duration),
 		zap.Int64("response_size", lrw.size),
@@ -327,35 +784,35 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func formatResponseBodyPreview(headers map[string]string, preview []byte) string {
+// responseBody returns the captured response preview as text, or as a raw
+// byte prefix when it is binary.
+func responseBody(headers model.Headers, preview []byte) (string, []byte, bool) {
 	if len(preview) == 0 {
-		return ""
+		return "", nil, false
 	}
-
-	contentType := strings.ToLower(strings.TrimSpace(headers["Content-Type"]))
-	textLike := strings.HasPrefix(contentType, "text/") ||
-		strings.HasPrefix(contentType, "application/json") ||
-		strings.HasPrefix(contentType, "application/xml") ||
-		strings.HasPrefix(contentType, "application/javascript") ||
-		strings.HasPrefix(contentType, "application/x-www-form-urlencoded") ||
-		strings.Contains(contentType, "+json") ||
-		strings.Contains(contentType, "+xml")
-
-	if textLike || utf8.Valid(preview) {
-		return string(bytes.ToValidUTF8(preview, []byte("\uFFFD")))
+	if isBinaryBody(headers.Get("Content-Type"), preview) {
+		raw, truncated := binaryPrefix(preview)
+		return "", raw, truncated
 	}
-
-	return "[binary response body omitted]"
+	return string(bytes.ToValidUTF8(preview, []byte("\uFFFD"))), nil, false
 }
 
+// enforceFunnelAllowlist rejects Funnel requests from sources outside the
+// allowlist. With --funnel every request came through Funnel; with
+// --funnel-path only those marked by Tailscale did, and the rest are from
+// the tailnet.
 func (s *Server) enforceFunnelAllowlist(w http.ResponseWriter, r *http.Request) bool {
-	if !s.funnelEnabled || len(s.funnelAllowlist) == 0 {
+	allowlist := s.currentSettings().FunnelAllowlist
+	if len(allowlist) == 0 {
+		return true
+	}
+	if !s.funnelEnabled && (len(s.funnelPaths) == 0 || r.Header.Get(porthttputil.FunnelRequestHeader) == "") {
 		return true
 	}
 
 	sourceIP, sourceSignal, resolved := resolveSourceIP(r, s.preferRemoteIP)
 	if !resolved {
-		s.logger.Warn("Funnel request denied",
+		s.log().Warn("Funnel request denied",
 			logging.Component("funnel_allowlist"),
 			logging.FunnelEnabled(true),
 			zap.String("source_signal", sourceSignal),
@@ -367,9 +824,9 @@ func (s *Server) enforceFunnelAllowlist(w http.ResponseWriter, r *http.Request)
 		return false
 	}
 
-	matchedEntry, allowed := allowlistedEntry(sourceIP, s.funnelAllowlist)
+	matchedEntry, allowed := allowlistedEntry(sourceIP, allowlist)
 	if !allowed {
-		s.logger.Warn("Funnel request denied",
+		s.log().Warn("Funnel request denied",
 			logging.Component("funnel_allowlist"),
 			logging.FunnelEnabled(true),
 			zap.String("source_signal", sourceSignal),
@@ -382,7 +839,7 @@ func (s *Server) enforceFunnelAllowlist(w http.ResponseWriter, r *http.Request)
 		return false
 	}
 
-	s.logger.Info("Funnel request allowed",
+	s.log().Info("Funnel request allowed",
 		logging.Component("funnel_allowlist"),
 		logging.FunnelEnabled(true),
 		zap.String("source_signal", sourceSignal),
@@ -397,17 +854,36 @@ func (s *Server) enforceFunnelAllowlist(w http.ResponseWriter, r *http.Request)
 
 // captureRequest stores the log entry and notifies listeners
 func (s *Server) captureRequest(logEntry model.RequestLog) {
+	if s.capturePaused.Load() {
+		s.captureSkipped.Add(1)
+		return
+	}
+	logEntry = s.omitBodies(logEntry)
+
 	// Store log entry
 	s.logMutex.Lock()
 	s.requestLog = append(s.requestLog, logEntry)
 	// Keep only last maxLogsCap requests
+	var evicted []model.RequestLog
 	if len(s.requestLog) > s.maxLogsCap {
+		evicted = s.requestLog[:1]
 		s.requestLog = s.requestLog[1:]
 	}
+	s.indexRequestLogs([]model.RequestLog{logEntry}, evicted)
 	s.logMutex.Unlock()
 
+	if s.captureSink != nil {
+		if err := s.captureSink.Write(logEntry); err != nil {
+			s.log().Warn("Failed to write request to capture file",
+				logging.Component("capture_file"),
+				zap.String("request_id", logEntry.ID),
+				logging.Error(err),
+			)
+		}
+	}
+
 	// Notify listeners - this is the primary way to send to TUI now
-	for _, listener := range s.listeners {
+	for _, listener := range s.listenerList() {
 		listener(logEntry)
 	}
 }
@@ -417,12 +893,22 @@ func (s *Server) handleMockRequest(w http.ResponseWriter, r *http.Request, body
 	// Set response headers
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("X-portal-mode", "mock")
-	w.Header().Set("X-portal-timestamp", time.Now().UTC().Format(time.RFC3339))
+	w.Header().Set("X-portal-timestamp", s.mockNow().UTC().Format(time.RFC3339))
+
+	if s.mockEngine != nil {
+		if !s.applyMockProfile(w, r) ||
+			s.mockEngine.HandleScenario(w, r, body) ||
+			s.mockEngine.HandleRule(w, r, body) ||
+			s.mockEngine.HandleStore(w, r, body) ||
+			s.mockEngine.HandlePlayback(w, r, body) {
+			return
+		}
+	}
 
 	// Create a simple response
 	response := map[string]interface{}{
 		"status":    "received",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
+		"timestamp": s.mockNow().UTC().Format(time.RFC3339),
 		"method":    r.Method,
 		"path":      r.URL.Path,
 		"headers":   len(r.Header),
@@ -444,6 +930,61 @@ func (s *Server) handleMockRequest(w http.ResponseWriter, r *http.Request, body
 	json.NewEncoder(w).Encode(response)
 }
 
+// mockNow is the time mock responses and request IDs use, which is fixed
+// when the mock engine is deterministic.
+func (s *Server) mockNow() time.Time {
+	if s.mockEngine != nil {
+		return s.mockEngine.Now()
+	}
+	return time.Now()
+}
+
+// applyMockProfile delays and optionally fails the request according to the
+// matching mock profile. It returns false when the response has been written
+// (or the client went away) and normal mock handling should stop.
+func (s *Server) applyMockProfile(w http.ResponseWriter, r *http.Request) bool {
+	outcome := s.mockEngine.Decide(r.Method, r.URL.Path)
+	if outcome.Profile == "" {
+		return true
+	}
+	w.Header().Set("X-portal-mock-profile", outcome.Profile)
+
+	if outcome.Delay > 0 {
+		timer := time.NewTimer(outcome.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return false
+		}
+	}
+
+	if outcome.FailStatus != 0 {
+		w.WriteHeader(outcome.FailStatus)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "simulated failure",
+			"status":  outcome.FailStatus,
+			"profile": outcome.Profile,
+		})
+		return false
+	}
+	return true
+}
+
+// MockEngine returns the mock engine, or nil outside mock mode.
+func (s *Server) MockEngine() *mock.Engine {
+	return s.mockEngine
+}
+
+// MockStore returns the mock resource store, or nil when mock mode has no
+// resources configured.
+func (s *Server) MockStore() *mock.Store {
+	if s.mockEngine == nil {
+		return nil
+	}
+	return s.mockEngine.Store()
+}
+
 // GetRequestLogs returns a copy of the request logs (implements model.LogProvider)
 func (s *Server) GetRequestLogs() []model.RequestLog {
 	s.logMutex.RLock()
@@ -455,11 +996,101 @@ func (s *Server) GetRequestLogs() []model.RequestLog {
 	return logs
 }
 
+// ImportRequestLogs appends previously captured requests to the history so
+// they can be inspected and replayed. Entries get fresh IDs, are marked
+// Imported, and do not affect stats, listeners or the capture file. It
+// returns how many entries were kept.
+func (s *Server) ImportRequestLogs(entries []model.RequestLog) int {
+	if len(entries) > s.maxLogsCap {
+		entries = entries[len(entries)-s.maxLogsCap:]
+	}
+
+	s.logMutex.Lock()
+	start := len(s.requestLog)
+	for _, entry := range entries {
+		entry.ID = s.nextRequestID()
+		entry.Imported = true
+		s.requestLog = append(s.requestLog, entry)
+	}
+	imported := s.requestLog[start:]
+	var evicted []model.RequestLog
+	if overflow := len(s.requestLog) - s.maxLogsCap; overflow > 0 {
+		evicted = s.requestLog[:overflow]
+		s.requestLog = s.requestLog[overflow:]
+	}
+	s.indexRequestLogs(imported, evicted)
+	s.logMutex.Unlock()
+
+	s.log().Info("Imported request history",
+		logging.Component("proxy_server"),
+		zap.Int("requests", len(entries)),
+	)
+	return len(entries)
+}
+
 // GetStats returns current statistics (implements model.StatsProvider)
 func (s *Server) GetStats() (ttl, opn int, rt1, rt5, p50, p90 float64) {
 	return s.stats.GetStats()
 }
 
+// GetSeries returns recent requests per second and latency percentiles.
+func (s *Server) GetSeries() stats.Series {
+	return s.stats.Series()
+}
+
+// GetViolations returns how many requests each request limit rejected.
+func (s *Server) GetViolations() map[string]int {
+	return s.stats.GetViolations()
+}
+
+// GetUpstreamFailures returns how many upstream requests failed, by kind.
+func (s *Server) GetUpstreamFailures() map[string]int {
+	return s.stats.GetFailures()
+}
+
+// GetUnmatched returns how many requests matched no mount, by path.
+func (s *Server) GetUnmatched() map[string]int {
+	return s.stats.GetUnmatched()
+}
+
+// GetCountries returns how many requests came from public clients in each
+// country, by ISO code.
+func (s *Server) GetCountries() map[string]int {
+	return s.stats.GetCountries()
+}
+
+// GeoLocator looks up where a client address is, returning nil for
+// addresses it doesn't know. *geoip.DB implements it.
+type GeoLocator interface {
+	Lookup(addr netip.Addr) *model.GeoIP
+}
+
+// locate looks up where the client of a request is, when GeoIP is on and
+// its address is public.
+func (s *Server) locate(r *http.Request) *model.GeoIP {
+	if s.geoip == nil {
+		return nil
+	}
+	addr, _, ok := resolveSourceIP(r, s.preferRemoteIP)
+	if !ok {
+		return nil
+	}
+	return s.geoip.Lookup(addr)
+}
+
+// StatsSnapshot returns the current stats with the period they cover.
+func (s *Server) StatsSnapshot() stats.StatsSnapshot {
+	return s.stats.Snapshot()
+}
+
+// ResetStats zeroes the counters and starts a new stats period, keeping the
+// captured request history.
+func (s *Server) ResetStats() {
+	s.stats.Reset()
+	s.connections.reset()
+	s.log().Info("Stats reset", logging.Component("proxy_server"))
+}
+
 // ClearRequestLogs clears captured request history and resets runtime stats.
 func (s *Server) ClearRequestLogs() {
 	s.logMutex.Lock()
@@ -467,13 +1098,72 @@ func (s *Server) ClearRequestLogs() {
 		s.requestLog[i] = model.RequestLog{}
 	}
 	s.requestLog = nil
+	s.searchIndex.Reset()
 	s.logMutex.Unlock()
+	s.annotations.Clear()
 	s.stats.Reset()
+	s.connections.reset()
+}
+
+// SetCapturePaused stops or resumes keeping new requests in the history, the
+// capture file and listeners such as the TUI. Requests are still proxied and
+// counted in the stats.
+func (s *Server) SetCapturePaused(paused bool) {
+	if s.capturePaused.Swap(paused) == paused {
+		return
+	}
+	if paused {
+		s.captureSkipped.Store(0)
+		s.log().Info("Request capture paused", logging.Component("proxy_server"))
+		return
+	}
+	s.log().Info("Request capture resumed",
+		logging.Component("proxy_server"),
+		zap.Int64("skipped", s.captureSkipped.Load()),
+	)
 }
 
-// SendTUIMessage sends a message to the TUI if available (implements model.TUIMessageSender)
-func (s *Server) SendTUIMessage(msg interface{}) {
-	if s.program != nil {
-		s.program.Send(msg)
+// CaptureState reports whether capture is paused, how many requests the
+// pause has skipped and which bodies are kept.
+func (s *Server) CaptureState() model.CaptureState {
+	return model.CaptureState{
+		Paused:         s.capturePaused.Load(),
+		Skipped:        s.captureSkipped.Load(),
+		RequestBodies:  !s.noRequestBodies.Load(),
+		ResponseBodies: !s.noResponseBodies.Load(),
+	}
+}
+
+// SetBodyCapture turns capture of request and response bodies on or off.
+// Requests are still captured with their headers and sizes; only new
+// requests are affected.
+func (s *Server) SetBodyCapture(request, response bool) {
+	requestChanged := s.noRequestBodies.Swap(!request) != !request
+	responseChanged := s.noResponseBodies.Swap(!response) != !response
+	if !requestChanged && !responseChanged {
+		return
+	}
+	s.log().Info("Body capture changed",
+		logging.Component("proxy_server"),
+		zap.Bool("request_bodies", request),
+		zap.Bool("response_bodies", response),
+	)
+}
+
+// omitBodies drops the bodies of entry that are not being captured.
+func (s *Server) omitBodies(entry model.RequestLog) model.RequestLog {
+	if s.noRequestBodies.Load() {
+		entry.Body = ""
+		entry.BodyBytes = nil
+		entry.BodyTruncated = false
+		entry.Multipart = nil
+		entry.BodyOmitted = true
+	}
+	if s.noResponseBodies.Load() {
+		entry.Response.Body = ""
+		entry.Response.BodyBytes = nil
+		entry.Response.BodyTruncated = false
+		entry.Response.BodyOmitted = true
 	}
+	return entry
 }
@@ -2,15 +2,22 @@ package proxy
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
 	"net/netip"
+	"net/textproto"
 	"os"
+	"strings"
 	"testing"
 
 	"go.uber.org/zap"
 
+	"github.com/jaxxstorm/portal/internal/mock"
 	"github.com/jaxxstorm/portal/internal/model"
 )
 
@@ -274,3 +281,322 @@ func mustPrefixes(t *testing.T, values ...string) []netip.Prefix {
 	}
 	return prefixes
 }
+
+func TestServeHTTPMockProfileInjectsFailure(t *testing.T) {
+	mockConfig := &mock.Config{Profiles: []mock.Profile{{Path: "/orders", ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable}}}
+	if err := mockConfig.Validate(); err != nil {
+		t.Fatalf("validate mock config: %v", err)
+	}
+	server := NewServer(Config{
+		Mode:       model.ModeMock,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		MockEngine: mock.NewEngine(mockConfig),
+	})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected injected 503, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("X-portal-mock-profile"); got != "/orders" {
+		t.Fatalf("expected profile header /orders, got %q", got)
+	}
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/other", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected unmatched path to return 200, got %d", rr.Code)
+	}
+}
+
+func TestServeHTTPRewritesHostHeader(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.Header.Get("X-Forwarded-Host")))
+	}))
+	defer upstream.Close()
+	port := upstream.Listener.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{name: "default", want: fmt.Sprintf("localhost:%d dev.example.ts.net", port)},
+		{name: "preserve", config: Config{PreserveHost: true}, want: "dev.example.ts.net dev.example.ts.net"},
+		{name: "override", config: Config{UpstreamHost: "example.local"}, want: "example.local dev.example.ts.net"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.config
+			cfg.TargetPort = port
+			cfg.Mode = model.ModeProxy
+			cfg.UseTUI = true
+			cfg.Logger = zap.NewNop()
+			server := NewServer(cfg)
+
+			req := httptest.NewRequest(http.MethodGet, "http://dev.example.ts.net/", nil)
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+			if rr.Body.String() != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeHTTPKeepsRepeatedHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("X-Tag", "a, b")
+	req.Header.Add("X-Tag", "c")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 1 {
+		t.Fatalf("expected one request, got %d", len(logs))
+	}
+	if tags := logs[0].Headers.Values("X-Tag"); len(tags) != 2 || tags[0] != "a, b" || tags[1] != "c" {
+		t.Fatalf("expected both request header values kept apart, got %q", tags)
+	}
+	cookies := logs[0].Response.Headers.Values("Set-Cookie")
+	if len(cookies) != 2 || cookies[0] != "a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT" || cookies[1] != "b=2" {
+		t.Fatalf("expected both cookies kept intact, got %q", cookies)
+	}
+}
+
+func TestServeHTTPPassesInformationalResponsesAndTrailers(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Link", "</app.css>; rel=preload")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(string(body) + " " + r.Trailer.Get("X-Checksum")))
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set(http.TrailerPrefix+"X-Late", "yes")
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+	front := httptest.NewServer(server)
+	defer front.Close()
+
+	var hints []int
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			hints = append(hints, code)
+			return nil
+		},
+	})
+	// Hiding the body's length makes the request chunked, which trailers
+	// need.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, front.URL+"/", io.MultiReader(strings.NewReader("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Trailer = http.Header{"X-Checksum": {"abc"}}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if len(hints) != 1 || hints[0] != http.StatusEarlyHints {
+		t.Fatalf("expected the client to get 103 Early Hints, got %v", hints)
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != "hello abc" {
+		t.Fatalf("expected upstream to see the request trailer, got %d %q", resp.StatusCode, body)
+	}
+	if resp.Trailer.Get("Grpc-Status") != "0" || resp.Trailer.Get("X-Late") != "yes" {
+		t.Fatalf("expected response trailers to reach the client, got %v", resp.Trailer)
+	}
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 1 {
+		t.Fatalf("expected one request, got %d", len(logs))
+	}
+	if trailers := logs[0].Trailers; trailers.Get("X-Checksum") != "abc" {
+		t.Fatalf("expected the request trailer captured, got %v", trailers)
+	}
+	response := logs[0].Response
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected the final status captured, got %d", response.StatusCode)
+	}
+	if len(response.Informational) != 1 || response.Informational[0].StatusCode != http.StatusEarlyHints ||
+		response.Informational[0].Headers.Get("Link") != "</app.css>; rel=preload" {
+		t.Fatalf("expected the early hints captured, got %+v", response.Informational)
+	}
+	if response.Headers.Get("Grpc-Status") != "" || response.Headers.Get("Content-Type") != "text/plain" {
+		t.Fatalf("expected the headers without the trailers, got %v", response.Headers)
+	}
+	if response.Trailers.Get("Grpc-Status") != "0" || response.Trailers.Get("X-Late") != "yes" || len(response.Trailers) != 2 {
+		t.Fatalf("expected both trailers captured, got %v", response.Trailers)
+	}
+}
+
+func TestServeHTTPRemoteTargetUsesDialer(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer upstream.Close()
+
+	var dialed string
+	server := NewServer(Config{
+		TargetHost: "build-box",
+		TargetPort: 3000,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = addr
+			var d net.Dialer
+			return d.DialContext(ctx, network, upstream.Listener.Addr().String())
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if dialed != "build-box:3000" {
+		t.Fatalf("expected dial to build-box:3000, got %q", dialed)
+	}
+	if rr.Code != http.StatusOK || rr.Body.String() != "build-box:3000" {
+		t.Fatalf("expected proxied response with peer Host, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestImportRequestLogsMarksAndCapsEntries(t *testing.T) {
+	server := NewServer(Config{
+		TargetPort: 3000,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		MaxLogs:    2,
+	})
+
+	kept := server.ImportRequestLogs([]model.RequestLog{
+		{ID: "req_1", URL: "/a"},
+		{ID: "req_1", URL: "/b"},
+		{ID: "req_1", URL: "/c"},
+	})
+	if kept != 2 {
+		t.Fatalf("expected 2 entries kept, got %d", kept)
+	}
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 2 || logs[0].URL != "/b" || logs[1].URL != "/c" {
+		t.Fatalf("unexpected logs %+v", logs)
+	}
+	if !logs[0].Imported || logs[0].ID == logs[1].ID || logs[0].ID == "req_1" {
+		t.Fatalf("expected imported entries with fresh IDs, got %+v", logs)
+	}
+	if ttl, _, _, _, _, _ := server.GetStats(); ttl != 0 {
+		t.Fatalf("expected imports not to count in stats, got %d", ttl)
+	}
+}
+
+func TestBodyCapturePerDirection(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"email":"someone@example.com"}`))
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort:       upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:             model.ModeProxy,
+		UseTUI:           true,
+		Logger:           zap.NewNop(),
+		NoResponseBodies: true,
+	})
+	send := func() model.RequestLog {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(`{"event":"push"}`)))
+		if !strings.Contains(rr.Body.String(), "someone@example.com") {
+			t.Fatalf("expected the response to reach the client, got %q", rr.Body.String())
+		}
+		logs := server.GetRequestLogs()
+		return logs[len(logs)-1]
+	}
+
+	entry := send()
+	if entry.Body != `{"event":"push"}` || entry.BodyOmitted {
+		t.Fatalf("expected the request body to be captured, got %+v", entry)
+	}
+	if entry.Response.Body != "" || !entry.Response.BodyOmitted || entry.Response.Size == 0 {
+		t.Fatalf("expected the response body to be left out but sized, got %+v", entry.Response)
+	}
+
+	server.SetBodyCapture(false, true)
+	if state := server.CaptureState(); state.RequestBodies || !state.ResponseBodies {
+		t.Fatalf("unexpected capture state %+v", state)
+	}
+	entry = send()
+	if entry.Body != "" || !entry.BodyOmitted || entry.Response.Body == "" || entry.Response.BodyOmitted {
+		t.Fatalf("expected only the response body to be captured, got %+v", entry)
+	}
+	if _, err := server.Replay(context.Background(), entry.ID, model.ReplayRequest{}); err == nil {
+		t.Fatal("expected replaying a request without its body to fail")
+	}
+}
+
+func TestCapturePauseKeepsProxyingWithoutRecording(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+	var notified int
+	server.AddListener(func(model.RequestLog) { notified++ })
+
+	send := func(path string) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("%s: expected the request to be proxied, got %d", path, rr.Code)
+		}
+	}
+
+	send("/before")
+	server.SetCapturePaused(true)
+	send("/paused")
+	send("/paused")
+	if state := server.CaptureState(); !state.Paused || state.Skipped != 2 {
+		t.Fatalf("expected two skipped requests while paused, got %+v", state)
+	}
+	server.SetCapturePaused(false)
+	send("/after")
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 2 || logs[0].URL != "/before" || logs[1].URL != "/after" || notified != 2 {
+		t.Fatalf("expected only unpaused requests recorded, got %+v (notified %d)", logs, notified)
+	}
+	if ttl, _, _, _, _, _ := server.GetStats(); ttl != 4 {
+		t.Fatalf("expected paused requests in the stats, got %d", ttl)
+	}
+}
@@ -0,0 +1,62 @@
+// internal/proxy/settings.go
+package proxy
+
+import (
+	"net/netip"
+
+	"github.com/jaxxstorm/portal/internal/middleware"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// Settings are the parts of the proxy configuration that can be changed
+// while serving, e.g. on a config reload. The Tailscale serve registration
+// and open connections are unaffected.
+type Settings struct {
+	CORS            middleware.CORSConfig
+	FunnelAllowlist []netip.Prefix
+	AllowMethods    []string
+	Mounts          []Mount
+	Unmatched       Unmatched
+	Quotas          []Quota
+	Exclude         []Exclude
+	PreserveHost    bool
+	UpstreamHost    string
+	ForwardedHeader bool
+	Cookies         CookieRewrite
+	KeepLocation    bool
+	RewriteHTML     bool
+}
+
+type runtimeSettings struct {
+	Settings
+	mounts []mountRoute
+}
+
+// ApplySettings swaps in new runtime settings. Requests already in flight
+// finish with the settings they started with.
+func (s *Server) ApplySettings(settings Settings) {
+	next := &runtimeSettings{Settings: settings}
+	if s.mode == model.ModeProxy {
+		next.mounts = s.buildMounts(settings.Mounts)
+	}
+	previous := s.settings.Swap(next)
+
+	s.endpointMu.Lock()
+	s.endpoint.Mounts = mountTable(next.mounts)
+	s.endpointMu.Unlock()
+
+	if previous != nil {
+		for _, mount := range previous.mounts {
+			mount.closeIdle()
+		}
+	}
+}
+
+// CurrentSettings returns the settings in effect.
+func (s *Server) CurrentSettings() Settings {
+	return s.currentSettings().Settings
+}
+
+func (s *Server) currentSettings() *runtimeSettings {
+	return s.settings.Load()
+}
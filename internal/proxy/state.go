@@ -0,0 +1,154 @@
+// internal/proxy/state.go
+package proxy
+
+import (
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/jaxxstorm/portal/internal/apitoken"
+	"github.com/jaxxstorm/portal/internal/filters"
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/termshare"
+)
+
+// runtimeState is the part of the server that changes while it serves: the
+// TUI takes over logging after startup, API tokens and the terminal share
+// are set late, and listeners can be added at any time. Requests read it
+// concurrently, so it is only accessed under mu.
+type runtimeState struct {
+	mu        sync.RWMutex
+	logger    *zap.Logger
+	program   *tea.Program
+	termShare *termshare.Share
+	apiTokens *apitoken.Store
+	filters   *filters.Store
+	listeners []func(model.RequestLog)
+	// logLevel is the level of the per-request log lines; the zero value
+	// is Info.
+	logLevel zapcore.Level
+}
+
+// State returns a snapshot of the server's mode, target, endpoint and
+// capture settings, for the TUI and web UI.
+func (s *Server) State() model.ServerState {
+	return model.ServerState{
+		Mode:      s.mode.String(),
+		TargetURL: s.TargetURL(),
+		Version:   s.version,
+		StartedAt: s.startedAt,
+		Endpoint:  s.GetEndpointState(),
+		Capture:   s.CaptureState(),
+	}
+}
+
+// log returns the current logger.
+func (s *Server) log() *zap.Logger {
+	s.runtime.mu.RLock()
+	defer s.runtime.mu.RUnlock()
+	return s.runtime.logger
+}
+
+// logRequest writes one of the per-request log lines at the level set with
+// SetRequestLogLevel.
+func (s *Server) logRequest(msg string, fields ...zap.Field) {
+	s.runtime.mu.RLock()
+	logger, level := s.runtime.logger, s.runtime.logLevel
+	s.runtime.mu.RUnlock()
+	logger.Log(level, msg, fields...)
+}
+
+// ReplaceLogger replaces the current logger with a new one
+func (s *Server) ReplaceLogger(logger *zap.Logger) {
+	s.runtime.mu.Lock()
+	defer s.runtime.mu.Unlock()
+	s.runtime.logger = logger
+}
+
+// SetRequestLogLevel changes the level of the "Request received" and
+// "Request completed" lines, for when requests are printed another way.
+func (s *Server) SetRequestLogLevel(level zapcore.Level) {
+	s.runtime.mu.Lock()
+	defer s.runtime.mu.Unlock()
+	s.runtime.logLevel = level
+}
+
+// SetProgram sets the TUI program for sending messages
+func (s *Server) SetProgram(p *tea.Program) {
+	s.runtime.mu.Lock()
+	defer s.runtime.mu.Unlock()
+	s.runtime.program = p
+}
+
+// SendTUIMessage sends a message to the TUI if available (implements model.TUIMessageSender)
+func (s *Server) SendTUIMessage(msg interface{}) {
+	s.runtime.mu.RLock()
+	program := s.runtime.program
+	s.runtime.mu.RUnlock()
+	if program != nil {
+		program.Send(msg)
+	}
+}
+
+// SetTerminalShare publishes the TUI's terminal output to web UI viewers.
+func (s *Server) SetTerminalShare(share *termshare.Share) {
+	s.runtime.mu.Lock()
+	defer s.runtime.mu.Unlock()
+	s.runtime.termShare = share
+}
+
+// TerminalShare returns the shared TUI terminal, or nil when not sharing.
+func (s *Server) TerminalShare() *termshare.Share {
+	s.runtime.mu.RLock()
+	defer s.runtime.mu.RUnlock()
+	return s.runtime.termShare
+}
+
+// SetAPITokens requires tokens from the store for mutating UI API calls.
+func (s *Server) SetAPITokens(tokens *apitoken.Store) {
+	s.runtime.mu.Lock()
+	defer s.runtime.mu.Unlock()
+	s.runtime.apiTokens = tokens
+}
+
+// APITokens returns the API token store, or nil when none was loaded.
+func (s *Server) APITokens() *apitoken.Store {
+	s.runtime.mu.RLock()
+	defer s.runtime.mu.RUnlock()
+	return s.runtime.apiTokens
+}
+
+// SetSavedFilters keeps the web UI's saved filters in store.
+func (s *Server) SetSavedFilters(store *filters.Store) {
+	s.runtime.mu.Lock()
+	defer s.runtime.mu.Unlock()
+	s.runtime.filters = store
+}
+
+// SavedFilters returns the saved filter store, or nil when none was loaded.
+func (s *Server) SavedFilters() *filters.Store {
+	s.runtime.mu.RLock()
+	defer s.runtime.mu.RUnlock()
+	return s.runtime.filters
+}
+
+// AddListener adds a listener function that will be called for each new
+// request. It is safe to call while the server is handling requests.
+func (s *Server) AddListener(listener func(model.RequestLog)) {
+	if listener == nil {
+		return
+	}
+	s.runtime.mu.Lock()
+	defer s.runtime.mu.Unlock()
+	s.runtime.listeners = append(s.runtime.listeners, listener)
+}
+
+// listenerList returns the listeners to notify of a request. Listeners are
+// only appended, so the returned elements never change.
+func (s *Server) listenerList() []func(model.RequestLog) {
+	s.runtime.mu.RLock()
+	defer s.runtime.mu.RUnlock()
+	return s.runtime.listeners
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestRuntimeStateChangesWhileServing(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Version:    "1.2.3",
+	})
+
+	// Run with -race: the TUI swaps the logger and the web UI adds
+	// listeners while requests are being handled.
+	var notified atomic.Int64
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			server.ReplaceLogger(zap.NewNop())
+			server.AddListener(func(model.RequestLog) { notified.Add(1) })
+			server.SetWebUIURL("http://100.64.0.1:4040/ui/")
+		}()
+	}
+	wg.Wait()
+
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if notified.Load() < 4 {
+		t.Fatalf("expected every listener to see the last request, got %d notifications", notified.Load())
+	}
+
+	state := server.State()
+	if state.Mode != model.ModeProxy.String() || state.TargetURL != server.TargetURL() || state.Version != "1.2.3" {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	if state.Endpoint.WebUIURL != "http://100.64.0.1:4040/ui/" || state.StartedAt.IsZero() || !state.Capture.RequestBodies {
+		t.Fatalf("unexpected state %+v", state)
+	}
+}
@@ -0,0 +1,64 @@
+// internal/proxy/status.go
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// StatusPath is served by portal itself when the status endpoint is enabled.
+const StatusPath = "/__portal/status"
+
+// statusProbeTimeout bounds the upstream check made for each status request.
+var statusProbeTimeout = time.Second
+
+type statusResponse struct {
+	Status        string  `json:"status"`
+	Version       string  `json:"version,omitempty"`
+	Mode          string  `json:"mode"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+	Upstream      string  `json:"upstream"`
+	UpstreamError string  `json:"upstream_error,omitempty"`
+}
+
+// serveStatus answers health checks from external monitors without touching
+// application routes. The upstream is probed with a TCP dial; an unreachable
+// upstream makes the response a 503. Status requests are not logged.
+func (s *Server) serveStatus(w http.ResponseWriter, r *http.Request) {
+	response := statusResponse{
+		Status:        "ok",
+		Version:       s.version,
+		Mode:          s.mode.String(),
+		UptimeSeconds: time.Since(s.startedAt).Round(time.Second).Seconds(),
+		Upstream:      "n/a",
+	}
+
+	if s.mode == model.ModeMock {
+		response.Upstream = "mock"
+	} else if s.targetURL != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), statusProbeTimeout)
+		conn, err := s.dialUpstream(ctx, "tcp", s.targetURL.Host)
+		cancel()
+		if err != nil {
+			response.Status = "degraded"
+			response.Upstream = model.UpstreamUnreachable
+			response.UpstreamError = err.Error()
+		} else {
+			conn.Close()
+			response.Upstream = model.UpstreamReachable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if response.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if r.Method != http.MethodHead {
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPStatusEndpoint(t *testing.T) {
+	upstreamHits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort:     upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:           model.ModeProxy,
+		UseTUI:         true,
+		Logger:         zap.NewNop(),
+		StatusEndpoint: true,
+		Version:        "v1.2.3",
+	})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, StatusPath, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var status statusResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if status.Status != "ok" || status.Upstream != model.UpstreamReachable || status.Version != "v1.2.3" || status.Mode != "proxy" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if upstreamHits != 0 {
+		t.Fatalf("expected status request not to be forwarded, got %d upstream hits", upstreamHits)
+	}
+	if logs := server.GetRequestLogs(); len(logs) != 0 {
+		t.Fatalf("expected status request not to be logged, got %d logs", len(logs))
+	}
+}
+
+func TestServeHTTPStatusEndpointDegraded(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	server := NewServer(Config{
+		TargetPort:     port,
+		Mode:           model.ModeProxy,
+		UseTUI:         true,
+		Logger:         zap.NewNop(),
+		StatusEndpoint: true,
+	})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, StatusPath, nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+
+	var status statusResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if status.Status != "degraded" || status.Upstream != model.UpstreamUnreachable || status.UpstreamError == "" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestServeHTTPStatusEndpointDisabled(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, StatusPath, nil))
+	if rr.Code != http.StatusTeapot {
+		t.Fatalf("expected status path to be proxied when disabled, got %d", rr.Code)
+	}
+}
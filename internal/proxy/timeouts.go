@@ -0,0 +1,211 @@
+// internal/proxy/timeouts.go
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	porthttputil "github.com/jaxxstorm/portal/internal/httputil"
+	"github.com/jaxxstorm/portal/internal/logging"
+)
+
+// Upstream failure kinds counted in stats.
+const (
+	FailureTimeout     = "timeout"
+	FailureUnreachable = "unreachable"
+)
+
+// Timeouts bound how long the upstream may take. Zero disables a timeout.
+type Timeouts struct {
+	// Overall bounds the whole exchange, from sending the request to the
+	// end of the response body.
+	Overall time.Duration
+	// Header bounds the wait for the response headers.
+	Header time.Duration
+	// Idle bounds the gap between reads of the response body.
+	Idle time.Duration
+}
+
+// TimeoutRoute overrides timeouts for requests under Path, and only for
+// Methods when set.
+type TimeoutRoute struct {
+	Path    string
+	Methods []string
+	Timeouts
+}
+
+// UpstreamTimeouts holds the default upstream timeouts and per-route
+// overrides. The first matching route wins; its zero fields fall back to
+// Default.
+type UpstreamTimeouts struct {
+	Default Timeouts
+	Routes  []TimeoutRoute
+}
+
+// forRequest returns the timeouts that apply to r.
+func (u UpstreamTimeouts) forRequest(r *http.Request) Timeouts {
+	timeouts := u.Default
+	for _, route := range u.Routes {
+		if !routeMatches(r, route.Path, route.Methods) {
+			continue
+		}
+		timeouts.Overall = cmp.Or(route.Overall, timeouts.Overall)
+		timeouts.Header = cmp.Or(route.Header, timeouts.Header)
+		timeouts.Idle = cmp.Or(route.Idle, timeouts.Idle)
+		break
+	}
+	return timeouts
+}
+
+// routeMatches reports whether r is under path and, when methods are given,
+// uses one of them.
+func routeMatches(r *http.Request, path string, methods []string) bool {
+	return porthttputil.UnderPath(r.URL.Path, path) && (len(methods) == 0 || slices.Contains(methods, r.Method))
+}
+
+// upstreamTimeoutError is the cancellation cause of an upstream request
+// whose timeout expired.
+type upstreamTimeoutError struct {
+	kind  string // "overall", "header" or "idle"
+	limit time.Duration
+}
+
+func (e *upstreamTimeoutError) Error() string {
+	return fmt.Sprintf("upstream %s timeout of %s exceeded", e.kind, e.limit)
+}
+
+type upstreamDeadlineKey struct{}
+
+// upstreamDeadline enforces the timeouts of one upstream request by
+// cancelling its context.
+type upstreamDeadline struct {
+	server   *Server
+	timeouts Timeouts
+	path     string
+	cancel   context.CancelCauseFunc
+	header   *time.Timer
+
+	mu     sync.Mutex
+	done   bool
+	timers []*time.Timer
+}
+
+// withUpstreamTimeouts returns r with its timeouts applied and a func to call
+// once the response has been copied.
+func (s *Server) withUpstreamTimeouts(r *http.Request) (*http.Request, func()) {
+	timeouts := s.timeouts.forRequest(r)
+	if timeouts == (Timeouts{}) {
+		return r, func() {}
+	}
+
+	ctx, cancel := context.WithCancelCause(r.Context())
+	d := &upstreamDeadline{server: s, timeouts: timeouts, path: r.URL.Path, cancel: cancel}
+	d.start("overall", timeouts.Overall)
+	d.header = d.start("header", timeouts.Header)
+	return r.WithContext(context.WithValue(ctx, upstreamDeadlineKey{}, d)), d.finish
+}
+
+func (d *upstreamDeadline) start(kind string, limit time.Duration) *time.Timer {
+	if limit <= 0 {
+		return nil
+	}
+	timer := time.AfterFunc(limit, func() { d.expire(kind, limit) })
+	d.mu.Lock()
+	d.timers = append(d.timers, timer)
+	d.mu.Unlock()
+	return timer
+}
+
+// gotResponse swaps the header timeout for the idle timeout once the
+// response headers have arrived.
+func (d *upstreamDeadline) gotResponse(resp *http.Response) {
+	if d.header != nil {
+		d.header.Stop()
+	}
+	if timer := d.start("idle", d.timeouts.Idle); timer != nil {
+		resp.Body = &idleReader{ReadCloser: resp.Body, timer: timer, idle: d.timeouts.Idle}
+	}
+}
+
+func (d *upstreamDeadline) expire(kind string, limit time.Duration) {
+	if !d.stop() {
+		return
+	}
+	d.server.stats.AddFailure(FailureTimeout)
+	d.server.log().Warn("Upstream timed out",
+		logging.Component("proxy_server"),
+		zap.String("timeout", kind),
+		zap.Duration("limit", limit),
+		zap.String("path", d.path),
+	)
+	d.cancel(&upstreamTimeoutError{kind: kind, limit: limit})
+}
+
+func (d *upstreamDeadline) finish() {
+	d.stop()
+	d.cancel(nil)
+}
+
+// stop stops every timer and reports whether this was the first call.
+func (d *upstreamDeadline) stop() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.done {
+		return false
+	}
+	d.done = true
+	for _, timer := range d.timers {
+		timer.Stop()
+	}
+	return true
+}
+
+// idleReader restarts the idle timeout on every read.
+type idleReader struct {
+	io.ReadCloser
+	timer *time.Timer
+	idle  time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.timer.Reset(r.idle)
+	return n, err
+}
+
+// proxyError is the reverse proxy's ErrorHandler. Expired timeouts get a 504
+// saying which one; other failures a 502.
+func (s *Server) proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	var timeout *upstreamTimeoutError
+	if errors.As(context.Cause(r.Context()), &timeout) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusGatewayTimeout)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "upstream timed out",
+			"timeout": timeout.kind,
+			"limit":   timeout.limit.String(),
+		})
+		return
+	}
+
+	// A client that went away isn't the upstream's fault.
+	if r.Context().Err() == nil {
+		s.stats.AddFailure(FailureUnreachable)
+		s.log().Warn("Upstream request failed",
+			logging.Component("proxy_server"),
+			zap.String("path", r.URL.Path),
+			logging.Error(err),
+		)
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPUpstreamHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Timeouts: UpstreamTimeouts{
+			Default: Timeouts{Header: 50 * time.Millisecond},
+			Routes: []TimeoutRoute{
+				{Path: "/slow", Methods: []string{http.MethodPut}, Timeouts: Timeouts{Header: time.Minute}},
+			},
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", rr.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body["timeout"] != "header" || body["limit"] != "50ms" {
+		t.Fatalf("expected a body naming the header timeout, got %q", rr.Body.String())
+	}
+	if failures := server.GetUpstreamFailures(); failures[FailureTimeout] != 1 {
+		t.Fatalf("expected one timeout failure, got %v", failures)
+	}
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected fast responses within the timeout to pass, got %d", rr.Code)
+	}
+	if failures := server.GetUpstreamFailures(); failures[FailureTimeout] != 1 {
+		t.Fatalf("expected no further failures, got %v", failures)
+	}
+}
+
+func TestUpstreamTimeoutsForRequest(t *testing.T) {
+	timeouts := UpstreamTimeouts{
+		Default: Timeouts{Overall: time.Minute, Header: 10 * time.Second},
+		Routes: []TimeoutRoute{
+			{Path: "/reports", Methods: []string{http.MethodPost}, Timeouts: Timeouts{Overall: 5 * time.Minute}},
+			{Path: "/reports", Timeouts: Timeouts{Idle: time.Second}},
+		},
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		want         Timeouts
+	}{
+		{http.MethodPost, "/reports/monthly", Timeouts{Overall: 5 * time.Minute, Header: 10 * time.Second}},
+		{http.MethodGet, "/reports", Timeouts{Overall: time.Minute, Header: 10 * time.Second, Idle: time.Second}},
+		{http.MethodPost, "/reportsx", Timeouts{Overall: time.Minute, Header: 10 * time.Second}},
+	} {
+		got := timeouts.forRequest(httptest.NewRequest(tc.method, tc.path, nil))
+		if got != tc.want {
+			t.Fatalf("%s %s: expected %+v, got %+v", tc.method, tc.path, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// internal/proxy/timing.go
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// upstreamTrace records when each phase of an upstream round trip happened.
+// The transport may invoke hooks from its own goroutines.
+type upstreamTrace struct {
+	mu        sync.Mutex
+	getConn   time.Time
+	gotConn   time.Time
+	firstByte time.Time
+	reused    bool
+}
+
+// traceUpstream returns r with an httptrace hook attached and the trace that
+// will be filled in as the transport proxies it.
+func traceUpstream(r *http.Request) (*http.Request, *upstreamTrace) {
+	trace := &upstreamTrace{}
+	clientTrace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			trace.getConn = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			trace.gotConn = time.Now()
+			trace.reused = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			trace.firstByte = time.Now()
+		},
+	}
+	return r.WithContext(httptrace.WithClientTrace(r.Context(), clientTrace)), trace
+}
+
+// timing converts the trace into phase durations, given when the response
+// body finished copying. It returns nil when no upstream response arrived.
+func (t *upstreamTrace) timing(done time.Time) *model.Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.getConn.IsZero() || t.gotConn.IsZero() || t.firstByte.IsZero() {
+		return nil
+	}
+	return &model.Timing{
+		Connect:    t.gotConn.Sub(t.getConn),
+		TTFB:       t.firstByte.Sub(t.gotConn),
+		Transfer:   max(done.Sub(t.firstByte), 0),
+		ConnReused: t.reused,
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestServeHTTPRecordsUpstreamTiming(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("done"))
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+
+	for i := 0; i < 2; i++ {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	}
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 logs, got %d", len(logs))
+	}
+	first := logs[0].Timing
+	if first == nil {
+		t.Fatalf("expected timing on proxied request")
+	}
+	if first.TTFB < 20*time.Millisecond || first.Transfer < 10*time.Millisecond {
+		t.Fatalf("expected ttfb >= 20ms and transfer >= 10ms, got %+v", first)
+	}
+	if first.Connect+first.TTFB+first.Transfer > logs[0].Duration {
+		t.Fatalf("expected phases to fit within duration %s, got %+v", logs[0].Duration, first)
+	}
+	if first.ConnReused || logs[1].Timing == nil || !logs[1].Timing.ConnReused {
+		t.Fatalf("expected second request to reuse the connection, got %+v then %+v", first, logs[1].Timing)
+	}
+}
+
+func TestServeHTTPMockModeHasNoTiming(t *testing.T) {
+	server := NewServer(Config{
+		Mode:   model.ModeMock,
+		UseTUI: true,
+		Logger: zap.NewNop(),
+	})
+
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if logs := server.GetRequestLogs(); len(logs) != 1 || logs[0].Timing != nil {
+		t.Fatalf("expected mock request without timing, got %+v", logs)
+	}
+}
@@ -0,0 +1,48 @@
+// internal/proxy/transport.go
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Transport tunes how connections to the upstream are opened and reused.
+// Zero values keep net/http's defaults.
+type Transport struct {
+	// MaxIdleConnsPerHost is how many keep-alive connections are kept per
+	// upstream. net/http's default of 2 forces new connections whenever a
+	// burst of webhooks arrives at once.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout closes keep-alive connections idle for this long.
+	IdleConnTimeout time.Duration
+	// DisableKeepAlives opens a new connection for every request, for
+	// targets that mishandle persistent connections.
+	DisableKeepAlives bool
+	// DialTimeout bounds how long connecting to the upstream may take.
+	DialTimeout time.Duration
+}
+
+// newTransport returns a clone of http.DefaultTransport that dials with dial
+// and applies the tuning in t.
+func (t Transport) newTransport(dial DialFunc) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dial
+	if t.DialTimeout > 0 {
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			ctx, cancel := context.WithTimeout(ctx, t.DialTimeout)
+			defer cancel()
+			return dial(ctx, network, addr)
+		}
+	}
+	if t.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = t.MaxIdleConnsPerHost
+		transport.MaxIdleConns = max(transport.MaxIdleConns, t.MaxIdleConnsPerHost)
+	}
+	if t.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = t.IdleConnTimeout
+	}
+	transport.DisableKeepAlives = t.DisableKeepAlives
+	return transport
+}
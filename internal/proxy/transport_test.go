@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestTransportAppliesTuning(t *testing.T) {
+	var dialed bool
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = true
+		if _, ok := ctx.Deadline(); !ok {
+			t.Fatalf("expected dial timeout to set a deadline")
+		}
+		return nil, context.DeadlineExceeded
+	}
+
+	transport := Transport{
+		MaxIdleConnsPerHost: 64,
+		IdleConnTimeout:     time.Minute,
+		DialTimeout:         time.Second,
+	}.newTransport(dial)
+	if transport.MaxIdleConnsPerHost != 64 || transport.MaxIdleConns < 64 || transport.IdleConnTimeout != time.Minute {
+		t.Fatalf("unexpected transport settings: per-host=%d total=%d idle=%s", transport.MaxIdleConnsPerHost, transport.MaxIdleConns, transport.IdleConnTimeout)
+	}
+	transport.DialContext(context.Background(), "tcp", "localhost:1")
+	if !dialed {
+		t.Fatalf("expected custom dialer to be used")
+	}
+
+	defaults := Transport{}.newTransport(dial)
+	if defaults.MaxIdleConnsPerHost != http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost {
+		t.Fatalf("expected zero values to keep net/http defaults")
+	}
+}
+
+func TestServeHTTPDisableKeepAlivesOpensNewConnections(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Transport:  Transport{DisableKeepAlives: true},
+	})
+
+	for i := 0; i < 2; i++ {
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	for _, log := range server.GetRequestLogs() {
+		if log.Timing == nil || log.Timing.ConnReused {
+			t.Fatalf("expected a fresh connection per request, got %+v", log.Timing)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// internal/proxy/upstream.go
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// upstreamPollInterval is how often the target port is probed while waiting.
+var upstreamPollInterval = 500 * time.Millisecond
+
+// WaitForUpstream marks the upstream as waiting and probes the target port in
+// the background until it accepts connections. Requests received meanwhile get
+// a 503 with Retry-After instead of a bad gateway. A zero timeout waits until
+// ctx is cancelled; on timeout the upstream is marked unreachable, onTimeout
+// is called if set, and requests are proxied as usual.
+func (s *Server) WaitForUpstream(ctx context.Context, timeout time.Duration, onTimeout func()) {
+	if s.mode != model.ModeProxy || s.targetURL == nil {
+		return
+	}
+	s.setUpstreamStatus(model.UpstreamWaiting)
+
+	go func() {
+		waitCtx := ctx
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			waitCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		ticker := time.NewTicker(upstreamPollInterval)
+		defer ticker.Stop()
+
+		for {
+			dialCtx, cancelDial := context.WithTimeout(waitCtx, upstreamPollInterval)
+			conn, err := s.dialUpstream(dialCtx, "tcp", s.targetURL.Host)
+			cancelDial()
+			if err == nil {
+				conn.Close()
+				s.setUpstreamStatus(model.UpstreamReachable)
+				s.log().Info("Upstream is now reachable",
+					logging.Component("proxy_server"),
+					zap.String("target", s.targetURL.Host),
+				)
+				return
+			}
+
+			select {
+			case <-waitCtx.Done():
+				if ctx.Err() == nil {
+					s.setUpstreamStatus(model.UpstreamUnreachable)
+					s.log().Error("Timed out waiting for upstream",
+						logging.Component("proxy_server"),
+						zap.String("target", s.targetURL.Host),
+						zap.Duration("timeout", timeout),
+					)
+					if onTimeout != nil {
+						onTimeout()
+					}
+				}
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// SetUpstreamDialer replaces how connections to the target are opened, e.g.
+// with a tsnet dialer once the node is up.
+func (s *Server) SetUpstreamDialer(dial DialFunc) {
+	s.dialMu.Lock()
+	defer s.dialMu.Unlock()
+	s.dial = dial
+}
+
+func (s *Server) dialUpstream(ctx context.Context, network, addr string) (net.Conn, error) {
+	s.dialMu.RLock()
+	dial := s.dial
+	s.dialMu.RUnlock()
+	if dial == nil {
+		return s.loopback.DialContext(ctx, network, addr)
+	}
+	return dial(ctx, network, addr)
+}
+
+func (s *Server) setUpstreamStatus(status string) {
+	s.endpointMu.Lock()
+	defer s.endpointMu.Unlock()
+	s.endpoint.Upstream = status
+}
+
+func (s *Server) upstreamWaiting() bool {
+	s.endpointMu.RLock()
+	defer s.endpointMu.RUnlock()
+	return s.endpoint.Upstream == model.UpstreamWaiting
+}
+
+func (s *Server) writeUpstreamWaiting(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":  "waiting for upstream",
+		"target": s.targetURL.Host,
+	})
+}
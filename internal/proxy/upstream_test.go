@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestWaitForUpstreamServesUnavailableUntilTargetListens(t *testing.T) {
+	previousInterval := upstreamPollInterval
+	upstreamPollInterval = 10 * time.Millisecond
+	defer func() { upstreamPollInterval = previousInterval }()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	server := NewServer(Config{
+		TargetPort: port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server.WaitForUpstream(ctx, 0, nil)
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while waiting for upstream, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After header while waiting")
+	}
+	if got := server.GetEndpointState().Upstream; got != model.UpstreamWaiting {
+		t.Fatalf("expected upstream %q, got %q", model.UpstreamWaiting, got)
+	}
+
+	upstreamListener, err := net.Listen("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("listen on reserved port: %v", err)
+	}
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	upstream.Listener = upstreamListener
+	upstream.Start()
+	defer upstream.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.GetEndpointState().Upstream != model.UpstreamReachable {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for upstream to become reachable")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected proxied status 204, got %d", rr.Code)
+	}
+}
+
+func TestWaitForUpstreamReportsTimeout(t *testing.T) {
+	previousInterval := upstreamPollInterval
+	upstreamPollInterval = 10 * time.Millisecond
+	defer func() { upstreamPollInterval = previousInterval }()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	server := NewServer(Config{
+		TargetPort: port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+
+	timedOut := make(chan struct{})
+	server.WaitForUpstream(context.Background(), 50*time.Millisecond, func() { close(timedOut) })
+	select {
+	case <-timedOut:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected onTimeout to be called")
+	}
+	if got := server.GetEndpointState().Upstream; got != model.UpstreamUnreachable {
+		t.Fatalf("expected upstream %q, got %q", model.UpstreamUnreachable, got)
+	}
+}
+
+func TestServeHTTPReachesIPv6OnlyTarget(t *testing.T) {
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	upstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	})}
+	go upstream.Serve(listener)
+	defer upstream.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	for _, host := range []string{"", "::1"} {
+		server := NewServer(Config{
+			TargetPort:   port,
+			LoopbackHost: host,
+			Mode:         model.ModeProxy,
+			UseTUI:       true,
+			Logger:       zap.NewNop(),
+		})
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("loopback host %q: expected 200, got %d", host, rr.Code)
+		}
+		// The Host header still names localhost, whichever address answered.
+		if want := net.JoinHostPort("localhost", strconv.Itoa(port)); rr.Body.String() != want {
+			t.Fatalf("loopback host %q: expected Host %q, got %q", host, want, rr.Body.String())
+		}
+	}
+}
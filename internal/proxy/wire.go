@@ -0,0 +1,11 @@
+// internal/proxy/wire.go
+package proxy
+
+import "net"
+
+// WrapListener returns ln with its connections recorded to the wire
+// capture, or ln itself when there is none. Pass every listener the proxy
+// serves on through it before serving.
+func (s *Server) WrapListener(ln net.Listener) net.Listener {
+	return s.wire.Listener(ln)
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/wire"
+)
+
+func TestWrapListenerRecordsTLSConnections(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-Forwarded-Proto"))
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "portal.wire")
+	rec, err := wire.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+		Wire:       rec,
+	})
+
+	// Borrow a certificate from an httptest TLS server.
+	certServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer certServer.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := &http.Server{Handler: server}
+	server.ConfigureHTTPServer(httpServer)
+	go httpServer.Serve(server.WrapListener(tls.NewListener(ln, certServer.TLS)))
+	defer httpServer.Close()
+
+	resp, err := certServer.Client().Get("https://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "https" {
+		t.Fatalf("expected the recorded TLS request to be forwarded as https, got %q", body)
+	}
+	httpServer.Close()
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	captured, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(captured), "GET / HTTP/1.1\r\n") {
+		t.Fatal("expected the capture to hold the decrypted request")
+	}
+}
@@ -0,0 +1,28 @@
+// internal/qr/qr.go
+package qr
+
+import (
+	"fmt"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Terminal renders a QR code for content with half-block characters, two
+// modules per text row, so it fits in a terminal pane.
+func Terminal(content string) (string, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return strings.TrimRight(code.ToSmallString(false), "\n"), nil
+}
+
+// PNG renders a QR code for content as a size x size PNG image.
+func PNG(content string, size int) ([]byte, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return code.PNG(size)
+}
@@ -0,0 +1,37 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestTerminalRendersSquareBlock(t *testing.T) {
+	out, err := Terminal("https://portal.tail1234.ts.net/")
+	if err != nil {
+		t.Fatalf("terminal: %v", err)
+	}
+	lines := strings.Split(out, "\n")
+	if len(lines) < 10 {
+		t.Fatalf("expected a multi-line QR code, got %d lines", len(lines))
+	}
+	width := len([]rune(lines[0]))
+	if height := len(lines) * 2; height < width-1 || height > width+1 {
+		t.Fatalf("expected roughly square code, got %d columns and %d rows of modules", width, height)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	data, err := PNG("https://portal.tail1234.ts.net/", 256)
+	if err != nil {
+		t.Fatalf("png: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 256 || bounds.Dy() != 256 {
+		t.Fatalf("expected 256x256 image, got %v", bounds)
+	}
+}
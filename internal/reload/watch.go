@@ -0,0 +1,79 @@
+// internal/reload/watch.go
+package reload
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Trigger reasons passed to the apply callback.
+const (
+	ReasonSignal     = "sighup"
+	ReasonFileChange = "file_change"
+)
+
+// DefaultInterval is how often watched files are checked for changes.
+const DefaultInterval = 2 * time.Second
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// Watch calls apply whenever the process receives SIGHUP or one of paths
+// changes on disk (including being created or removed). It blocks until ctx
+// is cancelled. Files are polled rather than watched with inotify so that
+// editors which replace files on save are handled the same way everywhere.
+func Watch(ctx context.Context, paths []string, interval time.Duration, apply func(reason string)) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	states := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		states[path] = stat(path)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			for _, path := range paths {
+				states[path] = stat(path)
+			}
+			apply(ReasonSignal)
+		case <-ticker.C:
+			changed := false
+			for _, path := range paths {
+				current := stat(path)
+				if current != states[path] {
+					states[path] = current
+					changed = true
+				}
+			}
+			if changed {
+				apply(ReasonFileChange)
+			}
+		}
+	}
+}
+
+func stat(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
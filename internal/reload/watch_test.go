@@ -0,0 +1,52 @@
+//go:build !windows
+
+package reload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchDetectsFileChangesAndSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("funnel: false\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reasons := make(chan string, 4)
+	go Watch(ctx, []string{path}, 10*time.Millisecond, func(reason string) {
+		reasons <- reason
+	})
+
+	// Let the watcher record the initial state before changing the file.
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("funnel: true\nverbose: true\n"), 0o600); err != nil {
+		t.Fatalf("rewrite config: %v", err)
+	}
+	select {
+	case reason := <-reasons:
+		if reason != ReasonFileChange {
+			t.Fatalf("expected %q, got %q", ReasonFileChange, reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for file change")
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("send SIGHUP: %v", err)
+	}
+	select {
+	case reason := <-reasons:
+		if reason != ReasonSignal {
+			t.Fatalf("expected %q, got %q", ReasonSignal, reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for SIGHUP")
+	}
+}
@@ -0,0 +1,110 @@
+// internal/scanner/scanner.go
+package scanner
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// probePaths are paths internet-wide scanners request looking for exposed
+// secrets, admin panels and known vulnerabilities. A path matches when it is
+// one of these or below one ending in "/". Paths a dev server could
+// legitimately serve, such as framework debug consoles, are left out.
+var probePaths = []string{
+	"/.env",
+	"/.git/",
+	"/.svn/",
+	"/.hg/",
+	"/.aws/",
+	"/.ssh/",
+	"/.DS_Store",
+	"/.htaccess",
+	"/.htpasswd",
+	"/wp-login.php",
+	"/wp-admin/",
+	"/wp-content/",
+	"/wp-includes/",
+	"/xmlrpc.php",
+	"/phpmyadmin/",
+	"/pma/",
+	"/phpinfo.php",
+	"/cgi-bin/",
+	"/vendor/phpunit/",
+	"/server-status",
+	"/boaform/",
+	"/HNAP1",
+	"/owa/",
+	"/ecp/",
+	"/autodiscover/autodiscover.xml",
+	"/solr/admin/",
+	"/manager/html",
+	"/global-protect/login.esp",
+	// Nmap's HTTP probe, as decoded from /nice%20ports%2C/Tri%6Eity.txt%2ebak.
+	"/nice ports,/Trinity.txt.bak",
+}
+
+// probeFiles are file names that are only requested by scanners, wherever
+// they are.
+var probeFiles = []string{
+	".env",
+	"wp-config.php",
+	"config.php.bak",
+	"web.config",
+	"id_rsa",
+	"docker-compose.yml",
+}
+
+// userAgents are substrings of the User-Agent headers scanners announce
+// themselves with, lowercased.
+var userAgents = []string{
+	"zgrab",
+	"masscan",
+	"nmap",
+	"sqlmap",
+	"nikto",
+	"nuclei",
+	"censysinspect",
+	"expanse",
+	"l9explore",
+	"l9tcpid",
+	"odin.io",
+	"internetmeasurement",
+	"netcraft",
+	"wpscan",
+	"dirbuster",
+	"gobuster",
+	"fuzz faster u fool",
+	"httpx - open-source",
+}
+
+// Detect reports why r looks like internet scanner traffic, such as
+// "path /.env" or "user agent zgrab", or returns "" when it doesn't.
+func Detect(r *http.Request) string {
+	if agent := strings.ToLower(r.UserAgent()); agent != "" {
+		for _, known := range userAgents {
+			if strings.Contains(agent, known) {
+				return "user agent " + known
+			}
+		}
+	}
+	requestPath := r.URL.Path
+	for _, probe := range probePaths {
+		if prefix, ok := strings.CutSuffix(probe, "/"); ok {
+			if requestPath == prefix || strings.HasPrefix(requestPath, probe) {
+				return "path " + probe
+			}
+		} else if requestPath == probe {
+			return "path " + probe
+		}
+	}
+	if requestPath != "/" {
+		base := path.Base(requestPath)
+		for _, file := range probeFiles {
+			if strings.EqualFold(base, file) {
+				return "path " + requestPath
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package scanner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		path      string
+		userAgent string
+		want      string
+	}{
+		{path: "/.env", want: "path /.env"},
+		{path: "/.git/config", want: "path /.git/"},
+		{path: "/wp-admin", want: "path /wp-admin/"},
+		{path: "/backup/.env", want: "path /backup/.env"},
+		{path: "/blog/wp-config.php", want: "path /blog/wp-config.php"},
+		{path: "/nice%20ports%2C/Tri%6Eity.txt%2ebak", want: "path /nice ports,/Trinity.txt.bak"},
+		{path: "/", userAgent: "Mozilla/5.0 zgrab/0.x", want: "user agent zgrab"},
+		{path: "/", userAgent: "Mozilla/5.0 (compatible; CensysInspect/1.1)", want: "user agent censysinspect"},
+		{path: "/"},
+		{path: "/environment"},
+		{path: "/api/.envelope"},
+		{path: "/webhooks/github", userAgent: "GitHub-Hookshot/abc123"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("User-Agent", tc.userAgent)
+		if got := Detect(req); got != tc.want {
+			t.Fatalf("%s %q: expected %q, got %q", tc.path, tc.userAgent, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// internal/schema/schema.go
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// Limits on what a Tracker learns, so endpoints with IDs in their paths or
+// payloads with unbounded keys can't grow it without end.
+const (
+	maxEndpoints = 500
+	maxFields    = 1000
+)
+
+// Root is the path of the payload itself; fields are $.name, array
+// elements $.items[].
+const Root = "$"
+
+// Tracker infers a schema for each endpoint's JSON payloads and reports
+// deliveries that deviate from it. It is safe for concurrent use.
+type Tracker struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpoint
+}
+
+type endpoint struct {
+	method     string
+	path       string
+	deliveries int
+	fields     map[string]*field
+	changed    int
+	lastChange time.Time
+	changes    []model.SchemaChange
+}
+
+type field struct {
+	types []string
+	seen  int
+}
+
+// NewTracker returns a Tracker that has seen nothing.
+func NewTracker() *Tracker {
+	return &Tracker{endpoints: make(map[string]*endpoint)}
+}
+
+// Observe adds a delivery of body to method and path, returning how it
+// differs from the deliveries before it. The first delivery only sets the
+// schema. Bodies that aren't JSON are ignored.
+func (t *Tracker) Observe(method, path string, body []byte) []model.SchemaChange {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	fields := make(map[string][]string)
+	flatten(Root, value, fields)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := method + " " + path
+	e := t.endpoints[key]
+	if e == nil {
+		if len(t.endpoints) >= maxEndpoints {
+			return nil
+		}
+		e = &endpoint{method: method, path: path, fields: make(map[string]*field)}
+		t.endpoints[key] = e
+	}
+
+	var changes []model.SchemaChange
+	if e.deliveries > 0 {
+		changes = e.compare(fields)
+	}
+	e.merge(fields)
+	if len(changes) > 0 {
+		e.changed++
+		e.lastChange = time.Now()
+		e.changes = changes
+	}
+	return changes
+}
+
+// compare lists how fields differ from the schema: fields it never had,
+// required fields that are missing, and fields of a type not seen before.
+// Only the outermost of a group of added or removed fields is listed.
+func (e *endpoint) compare(fields map[string][]string) []model.SchemaChange {
+	var changes []model.SchemaChange
+	added := make(map[string]bool)
+	for path, types := range fields {
+		known := e.fields[path]
+		switch {
+		case known == nil:
+			added[path] = true
+		case slices.ContainsFunc(types, func(typ string) bool { return !slices.Contains(known.types, typ) }):
+			changes = append(changes, model.SchemaChange{Field: path, Change: model.SchemaRetyped, Was: strings.Join(known.types, "|"), Now: strings.Join(types, "|")})
+		}
+	}
+	for path := range added {
+		if !added[parent(path)] {
+			changes = append(changes, model.SchemaChange{Field: path, Change: model.SchemaAdded, Now: strings.Join(fields[path], "|")})
+		}
+	}
+
+	removed := make(map[string]bool)
+	for path, known := range e.fields {
+		if known.seen < e.deliveries || fields[path] != nil || strings.HasSuffix(path, "[]") {
+			continue
+		}
+		// Fields of array elements are only missing if there were elements.
+		if i := strings.LastIndex(path, "[]"); i >= 0 && fields[path[:i+2]] == nil {
+			continue
+		}
+		removed[path] = true
+	}
+	for path := range removed {
+		if !removed[parent(path)] {
+			changes = append(changes, model.SchemaChange{Field: path, Change: model.SchemaRemoved, Was: strings.Join(e.fields[path].types, "|")})
+		}
+	}
+	slices.SortFunc(changes, func(a, b model.SchemaChange) int { return strings.Compare(a.Field, b.Field) })
+	return changes
+}
+
+func (e *endpoint) merge(fields map[string][]string) {
+	e.deliveries++
+	for path, types := range fields {
+		known := e.fields[path]
+		if known == nil {
+			if len(e.fields) >= maxFields {
+				continue
+			}
+			known = &field{}
+			e.fields[path] = known
+		}
+		known.seen++
+		for _, typ := range types {
+			if !slices.Contains(known.types, typ) {
+				known.types = append(known.types, typ)
+				slices.Sort(known.types)
+			}
+		}
+	}
+}
+
+// Schemas returns the inferred schemas, sorted by path and method.
+func (t *Tracker) Schemas() []model.EndpointSchema {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	schemas := make([]model.EndpointSchema, 0, len(t.endpoints))
+	for _, e := range t.endpoints {
+		schema := model.EndpointSchema{
+			Method:     e.method,
+			Path:       e.path,
+			Deliveries: e.deliveries,
+			Fields:     make([]model.SchemaField, 0, len(e.fields)),
+			Changed:    e.changed,
+			Changes:    slices.Clone(e.changes),
+		}
+		if !e.lastChange.IsZero() {
+			lastChange := e.lastChange
+			schema.LastChange = &lastChange
+		}
+		for path, f := range e.fields {
+			schema.Fields = append(schema.Fields, model.SchemaField{
+				Path:     path,
+				Types:    slices.Clone(f.types),
+				Seen:     f.seen,
+				Required: f.seen == e.deliveries,
+			})
+		}
+		slices.SortFunc(schema.Fields, func(a, b model.SchemaField) int { return strings.Compare(a.Path, b.Path) })
+		schemas = append(schemas, schema)
+	}
+	slices.SortFunc(schemas, func(a, b model.EndpointSchema) int {
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+	return schemas
+}
+
+// Reset forgets every schema.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.endpoints = make(map[string]*endpoint)
+}
+
+// flatten records the JSON type of value at path and of everything inside
+// it. Array elements share the path path[], so it may collect several types.
+func flatten(path string, value any, fields map[string][]string) {
+	typ := jsonType(value)
+	if !slices.Contains(fields[path], typ) {
+		fields[path] = append(fields[path], typ)
+		slices.Sort(fields[path])
+	}
+	switch value := value.(type) {
+	case map[string]any:
+		for key, child := range value {
+			flatten(path+"."+key, child, fields)
+		}
+	case []any:
+		for _, child := range value {
+			flatten(path+"[]", child, fields)
+		}
+	}
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// parent returns the path of the object or array holding path.
+func parent(path string) string {
+	if trimmed, ok := strings.CutSuffix(path, "[]"); ok {
+		return trimmed
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
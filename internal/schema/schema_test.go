@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestTrackerObserve(t *testing.T) {
+	tracker := NewTracker()
+	observe := func(body string) []model.SchemaChange {
+		return tracker.Observe("POST", "/webhooks/stripe", []byte(body))
+	}
+
+	if changes := observe(`{"id":"evt_1","amount":100,"items":[{"sku":"a"}],"note":null}`); changes != nil {
+		t.Fatalf("expected the first delivery to only set the schema, got %+v", changes)
+	}
+	if changes := observe(`{"id":"evt_2","amount":250,"items":[],"note":"gift"}`); len(changes) != 1 || changes[0] != (model.SchemaChange{Field: "$.note", Change: model.SchemaRetyped, Was: "null", Now: "string"}) {
+		t.Fatalf("expected only the note's new type, got %+v", changes)
+	}
+
+	changes := observe(`{"id":7,"items":[{"sku":"b"}],"note":"x","customer":{"email":"a@example.com"}}`)
+	want := []model.SchemaChange{
+		{Field: "$.amount", Change: model.SchemaRemoved, Was: "number"},
+		{Field: "$.customer", Change: model.SchemaAdded, Now: "object"},
+		{Field: "$.id", Change: model.SchemaRetyped, Was: "string", Now: "number"},
+	}
+	if !slices.Equal(changes, want) {
+		t.Fatalf("expected %+v, got %+v", want, changes)
+	}
+
+	// Other endpoints and bodies that aren't JSON have schemas of their own
+	// or none.
+	if changes := tracker.Observe("POST", "/webhooks/github", []byte(`{"action":"opened"}`)); changes != nil {
+		t.Fatalf("expected a new endpoint to start its own schema, got %+v", changes)
+	}
+	if changes := observe(`not json`); changes != nil {
+		t.Fatalf("expected non-JSON bodies ignored, got %+v", changes)
+	}
+
+	schemas := tracker.Schemas()
+	if len(schemas) != 2 || schemas[1].Path != "/webhooks/stripe" || schemas[1].Deliveries != 3 || schemas[1].Changed != 2 || schemas[1].LastChange == nil {
+		t.Fatalf("unexpected schemas %+v", schemas)
+	}
+	for _, field := range schemas[1].Fields {
+		switch field.Path {
+		case "$.id":
+			if !field.Required || !slices.Equal(field.Types, []string{"number", "string"}) {
+				t.Fatalf("unexpected id field %+v", field)
+			}
+		case "$.amount":
+			if field.Required || field.Seen != 2 {
+				t.Fatalf("unexpected amount field %+v", field)
+			}
+		}
+	}
+
+	tracker.Reset()
+	if len(tracker.Schemas()) != 0 {
+		t.Fatalf("expected no schemas after Reset")
+	}
+}
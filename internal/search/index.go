@@ -0,0 +1,172 @@
+// internal/search/index.go
+package search
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// maxIndexedText bounds how much of each document is indexed.
+const maxIndexedText = 1 << 20
+
+// Index is an inverted index of words to the IDs of the documents that
+// contain them. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[string]struct{}
+	docs     map[string][]string
+}
+
+// NewIndex returns an empty index.
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string]struct{}),
+		docs:     make(map[string][]string),
+	}
+}
+
+// Add indexes the words of texts under id, replacing what id held before.
+func (x *Index) Add(id string, texts ...string) {
+	seen := make(map[string]struct{})
+	for _, text := range texts {
+		if len(text) > maxIndexedText {
+			text = text[:maxIndexedText]
+		}
+		for _, word := range Words(text) {
+			seen[word] = struct{}{}
+		}
+	}
+	words := make([]string, 0, len(seen))
+	for word := range seen {
+		words = append(words, word)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(id)
+	if len(words) == 0 {
+		return
+	}
+	x.docs[id] = words
+	for _, word := range words {
+		ids := x.postings[word]
+		if ids == nil {
+			ids = make(map[string]struct{})
+			x.postings[word] = ids
+		}
+		ids[id] = struct{}{}
+	}
+}
+
+// Remove drops id from the index.
+func (x *Index) Remove(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(id)
+}
+
+func (x *Index) remove(id string) {
+	for _, word := range x.docs[id] {
+		delete(x.postings[word], id)
+		if len(x.postings[word]) == 0 {
+			delete(x.postings, word)
+		}
+	}
+	delete(x.docs, id)
+}
+
+// Reset empties the index.
+func (x *Index) Reset() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.postings = make(map[string]map[string]struct{})
+	x.docs = make(map[string][]string)
+}
+
+// Match returns the IDs of the documents containing every word of q.
+func (x *Index) Match(q Query) map[string]struct{} {
+	words := q.Words()
+	if len(words) == 0 {
+		return nil
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	// Start from the rarest word so the intersection stays small.
+	rarest := words[0]
+	for _, word := range words[1:] {
+		if len(x.postings[word]) < len(x.postings[rarest]) {
+			rarest = word
+		}
+	}
+	matches := make(map[string]struct{})
+	for id := range x.postings[rarest] {
+		matches[id] = struct{}{}
+	}
+	for _, word := range words {
+		if word == rarest {
+			continue
+		}
+		ids := x.postings[word]
+		for id := range matches {
+			if _, ok := ids[id]; !ok {
+				delete(matches, id)
+			}
+		}
+	}
+	return matches
+}
+
+// Words splits text into lower-cased words: runs of letters, digits and
+// underscores, so order_id and 12345 are both words of "order_id":12345.
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// Query is a parsed search: words that must all appear, and quoted phrases
+// that must appear as written, ignoring case.
+type Query struct {
+	Terms   []string
+	Phrases []string
+}
+
+// ParseQuery parses q: whitespace-separated words and "quoted phrases".
+func ParseQuery(q string) Query {
+	var query Query
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			if phrase := strings.ToLower(strings.TrimSpace(part)); phrase != "" {
+				query.Phrases = append(query.Phrases, phrase)
+			}
+			continue
+		}
+		query.Terms = append(query.Terms, Words(part)...)
+	}
+	return query
+}
+
+// Words returns every word the query needs, from terms and phrases.
+func (q Query) Words() []string {
+	words := append([]string(nil), q.Terms...)
+	for _, phrase := range q.Phrases {
+		words = append(words, Words(phrase)...)
+	}
+	return words
+}
+
+// MatchesPhrases reports whether texts contain each of the query's
+// phrases; the index only knows the phrases' words.
+func (q Query) MatchesPhrases(texts ...string) bool {
+	if len(q.Phrases) == 0 {
+		return true
+	}
+	text := strings.ToLower(strings.Join(texts, "\n"))
+	for _, phrase := range q.Phrases {
+		if !strings.Contains(text, phrase) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package search
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestIndexMatch(t *testing.T) {
+	index := NewIndex()
+	index.Add("req_1", `{"order_id":12345,"status":"paid"}`, "OK")
+	index.Add("req_2", `{"order_id":123456,"status":"refunded"}`)
+	index.Add("req_3", "order 12345 was paid late")
+
+	match := func(q string) []string {
+		return slices.Sorted(maps.Keys(index.Match(ParseQuery(q))))
+	}
+	tests := map[string][]string{
+		"12345":           {"req_1", "req_3"},
+		"order_id 12345":  {"req_1"},
+		"ORDER_ID":        {"req_1", "req_2"},
+		`"paid late"`:     {"req_3"},
+		"12345 refunded":  nil,
+		"!!!":             nil,
+		`status "paid"`:   {"req_1"},
+		`"order_id":1234`: nil,
+	}
+	for q, want := range tests {
+		if got := match(q); !slices.Equal(got, want) {
+			t.Fatalf("%s: expected %v, got %v", q, want, got)
+		}
+	}
+
+	index.Add("req_1", "replaced")
+	index.Remove("req_3")
+	if got := match("12345"); len(got) != 0 {
+		t.Fatalf("expected re-added and removed documents gone, got %v", got)
+	}
+	index.Reset()
+	if got := match("order_id"); len(got) != 0 {
+		t.Fatalf("expected an empty index after Reset, got %v", got)
+	}
+}
+
+func TestQueryMatchesPhrases(t *testing.T) {
+	q := ParseQuery(`webhook "Order Shipped"`)
+	if !slices.Equal(q.Terms, []string{"webhook"}) || !slices.Equal(q.Phrases, []string{"order shipped"}) {
+		t.Fatalf("unexpected query %+v", q)
+	}
+	if !q.MatchesPhrases("", `{"event":"order shipped"}`) || q.MatchesPhrases("order was shipped") {
+		t.Fatalf("expected phrases to match as written, ignoring case")
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/proxy"
+)
+
+// certWarmTimeout bounds the certificate warm-up; tailscaled keeps trying
+// when the first visitor arrives.
+const certWarmTimeout = 2 * time.Minute
+
+// WarmCertificate fetches the certificate for an HTTPS serviceURL in the
+// background, so the shared URL answers at once instead of hanging on the
+// first request while tailscaled gets it. Progress shows in the TUI and web
+// UI.
+func WarmCertificate(ctx context.Context, proxyServer *proxy.Server, serviceURL string, warm func(context.Context, string) error) {
+	u, err := url.Parse(serviceURL)
+	if err != nil || u.Scheme != "https" {
+		return
+	}
+	proxyServer.SetCertStatus(model.CertProvisioning)
+	go func() {
+		warmCtx, cancel := context.WithTimeout(ctx, certWarmTimeout)
+		defer cancel()
+		if err := warm(warmCtx, u.Hostname()); err != nil {
+			if ctx.Err() == nil {
+				proxyServer.SetCertStatus(model.CertFailed)
+			}
+			return
+		}
+		proxyServer.SetCertStatus(model.CertReady)
+	}()
+}
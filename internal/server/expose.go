@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/config"
+	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/proxy"
+	"github.com/jaxxstorm/portal/internal/tailscale"
+)
+
+// StartExposeNodes starts a tsnet node for each --expose, serving its
+// target through proxyServer so requests share one capture pipeline. The
+// returned function closes the nodes.
+func StartExposeNodes(ctx context.Context, proxyServer *proxy.Server, logger *zap.Logger, cfg *config.Config) func() error {
+	var nodes []*tailscale.TSNetServer
+	for _, expose := range cfg.Exposes {
+		stateDir, err := tailscale.NodeStateDir(expose.Name)
+		if err != nil {
+			logger.Error(logging.MsgSetupFailed,
+				logging.Component("expose"),
+				logging.NodeName(expose.Name),
+				logging.Error(err),
+			)
+			continue
+		}
+
+		node := tailscale.NewTSNetServer(tailscale.TSNetConfig{
+			Hostname:     expose.Name,
+			AuthKey:      cfg.AuthKey,
+			EnableFunnel: cfg.Funnel,
+			UseHTTPS:     cfg.UseHTTPS,
+			ServePort:    cfg.GetServePort(),
+			StateDir:     stateDir,
+			Verbose:      cfg.TSNetVerbose,
+
+			ConfigureHTTPServer: proxyServer.ConfigureHTTPServer,
+			WrapListener:        proxyServer.WrapListener,
+		}, logger)
+		name := expose.Name
+		node.SetReadyCallback(func(info tailscale.TSNetReadyInfo) {
+			proxyServer.SetExposeURL(name, info.ServiceURL)
+			logger.Info("Expose ready",
+				logging.Component("expose"),
+				logging.NodeName(name),
+				zap.String("target", expose.Target.Host+expose.Target.Path),
+				zap.String("url", info.ServiceURL),
+			)
+		})
+		nodes = append(nodes, node)
+
+		go func() {
+			if err := node.Serve(ctx, proxyServer.ExposeHandler(name)); err != nil {
+				logger.Error(logging.MsgRuntimeError,
+					logging.Component("expose"),
+					logging.NodeName(name),
+					logging.Error(err),
+				)
+			}
+		}()
+	}
+
+	return func() error {
+		var errs []error
+		for _, node := range nodes {
+			errs = append(errs, node.Close())
+		}
+		return errors.Join(errs...)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/jaxxstorm/portal/internal/config"
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/tailscale"
+)
+
+// PlanServe runs the serve setup a local-daemon session would, in the same
+// order, against a dry run of tsClient and reports what it would change.
+// Nothing is applied to tailscaled and no servers are started.
+func PlanServe(ctx context.Context, tsClient *tailscale.Client, cfg *config.Config) (*model.ServePlan, error) {
+	tsClient, dryRun := tsClient.DryRun()
+	if cfg.IsServiceMode() {
+		if err := tsClient.ValidateServiceHostIdentity(ctx, cfg.TSNetServiceName); err != nil {
+			return nil, TailscaleFailure(err)
+		}
+	}
+
+	proxyPort, err := tsClient.Ports().ProxyPort()
+	if err != nil {
+		return nil, err
+	}
+	plan := &model.ServePlan{ProxyPort: proxyPort}
+	if !cfg.NoUI {
+		uiPort := cfg.UIPort
+		if uiPort == 0 {
+			if uiPort, err = tsClient.Ports().UIPort(); err != nil {
+				return nil, err
+			}
+		}
+		_, uiURL, err := ServeUI(ctx, tsClient, cfg, uiPort)
+		if err != nil {
+			return nil, err
+		}
+		plan.UIPort, plan.WebUIURL = uiPort, uiURL
+	}
+
+	info, err := tsClient.SetupServe(ctx, ServeConfig(cfg, proxyPort))
+	if err != nil {
+		return nil, TailscaleFailure(err)
+	}
+	plan.DNSName = info.DNSName
+	plan.ServiceURL = info.URL
+	plan.ServePort = info.ServePort
+	plan.MountPath = info.MountPath
+	plan.Funnel = info.IsFunnel
+	plan.FunnelURLs = info.FunnelURLs
+
+	if plan.Changes, err = dryRun.Changes(ctx); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+var serveChangeSymbols = map[string]string{
+	model.ServeChangeAdd:     "+",
+	model.ServeChangeRemove:  "-",
+	model.ServeChangeReplace: "~",
+}
+
+// PrintPlan writes plan for a person to read.
+func PrintPlan(w io.Writer, plan *model.ServePlan, cfg *config.Config) {
+	if len(plan.Changes) == 0 {
+		fmt.Fprintf(w, "No changes to the serve config of %s.\n", plan.DNSName)
+	} else {
+		fmt.Fprintf(w, "Changes to the serve config of %s:\n", plan.DNSName)
+	}
+	for _, change := range plan.Changes {
+		fmt.Fprintf(w, "  %s %-9s %-44s %s", serveChangeSymbols[change.Action], change.Kind, change.Target, change.Handler)
+		if change.Previous != "" {
+			fmt.Fprintf(w, " (was %s)", change.Previous)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  %-12s %s\n", "Service URL", plan.ServiceURL)
+	for _, funnelURL := range plan.FunnelURLs {
+		fmt.Fprintf(w, "  %-12s %s\n", "Public path", funnelURL)
+	}
+	if plan.WebUIURL != "" {
+		fmt.Fprintf(w, "  %-12s %s\n", "Web UI", plan.WebUIURL)
+	}
+	fmt.Fprintf(w, "  %-12s %d, mounted at %s\n", "Serve port", plan.ServePort, plan.MountPath)
+	switch {
+	case plan.Funnel:
+		fmt.Fprintf(w, "  %-12s on, reachable from the internet\n", "Funnel")
+	case len(plan.FunnelURLs) > 0:
+		fmt.Fprintf(w, "  %-12s on for the public paths only\n", "Funnel")
+	default:
+		fmt.Fprintf(w, "  %-12s off, tailnet only\n", "Funnel")
+	}
+	fmt.Fprintf(w, "  %-12s %d (local)\n", "Proxy port", plan.ProxyPort)
+	if plan.UIPort != 0 {
+		fmt.Fprintf(w, "  %-12s %d (local)\n", "UI port", plan.UIPort)
+	}
+
+	fmt.Fprintln(w)
+	if cfg.Namespace != "" {
+		fmt.Fprintln(w, "On exit portal removes only this namespace's handlers.")
+	} else {
+		fmt.Fprintln(w, "On exit portal restores the serve config it found.")
+	}
+	fmt.Fprintln(w, "Dry run: nothing was changed.")
+}
@@ -2,12 +2,14 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
 	"time"
 
 	"github.com/jaxxstorm/portal/internal/config"
+	"github.com/jaxxstorm/portal/internal/exitcode"
 	"github.com/jaxxstorm/portal/internal/httputil"
 	"github.com/jaxxstorm/portal/internal/model"
 	"github.com/jaxxstorm/portal/internal/proxy"
@@ -16,22 +18,40 @@ import (
 	"github.com/jaxxstorm/portal/internal/ui"
 )
 
-// SetupLocalTailscaleQuiet sets up Tailscale serve with minimal TUI logging
-func SetupLocalTailscaleQuiet(ctx context.Context, tsClient *tailscale.Client, proxyServer *proxy.Server, logger *tui.TUIOnlyLogger, cfg *config.Config, uiFiles fs.FS) (cleanup func() error, uiCleanup func() error, serviceInfo *tailscale.ServiceInfo) {
+// TailscaleFailure tags a failure to set up serving through Tailscale with
+// its exit code.
+func TailscaleFailure(err error) error {
+	if errors.Is(err, tailscale.ErrFunnelNotPermitted) {
+		return exitcode.New(exitcode.FunnelNotPermitted, err)
+	}
+	return exitcode.New(exitcode.TailscaleUnavailable, err)
+}
+
+// SetupLocalTailscaleQuiet sets up Tailscale serve with minimal TUI logging.
+// The error, if any, says why serving could not be set up and carries its
+// exit code.
+func SetupLocalTailscaleQuiet(ctx context.Context, tsClient *tailscale.Client, proxyServer *proxy.Server, logger *tui.TUIOnlyLogger, cfg *config.Config, uiFiles fs.FS) (cleanup func() error, uiCleanup func() error, serviceInfo *tailscale.ServiceInfo, err error) {
 	if cfg.IsServiceMode() {
 		if err := tsClient.ValidateServiceHostIdentity(ctx, cfg.TSNetServiceName); err != nil {
 			logger.Errorf("Service mode host identity invalid service_name=%s error=%v", cfg.TSNetServiceName, err)
 			proxyServer.MarkEndpointFailure(err.Error())
-			return nil, nil, nil
+			return nil, nil, nil, TailscaleFailure(err)
 		}
 	}
 
-	// Find an available port for our local proxy server using random allocation
-	proxyPort, err := tailscale.FindAvailableLocalPort()
+	serveSnapshot, snapshotErr := SnapshotServe(ctx, tsClient, cfg)
+	if errors.Is(snapshotErr, tailscale.ErrServeSnapshotHeld) {
+		logger.Infof("Another portal session saved this node's serve config and restores it when it exits: %v", snapshotErr)
+	} else if snapshotErr != nil {
+		logger.Warnf("Failed to save the serve config; it will be cleared on exit instead of restored: %v", snapshotErr)
+	}
+
+	// Reuse this project's proxy port from last time when it is free
+	proxyPort, err := tsClient.Ports().ProxyPort()
 	if err != nil {
 		logger.Errorf("Port allocation failed: %v", err)
 		proxyServer.MarkEndpointFailure(err.Error())
-		return nil, nil, nil
+		return nil, nil, nil, err
 	}
 
 	logger.Infof("Proxy starting port=%d", proxyPort)
@@ -39,19 +59,20 @@ func SetupLocalTailscaleQuiet(ctx context.Context, tsClient *tailscale.Client, p
 	// Start our proxy server
 	useFunnelProxyProtocol := cfg.UseFunnelProxyProtocol()
 	httpServer := &http.Server{
-		Addr:    fmt.Sprintf(":%d", proxyPort),
+		Addr:    cfg.LocalAddr(proxyPort),
 		Handler: proxyServer,
 	}
+	proxyServer.ConfigureHTTPServer(httpServer)
 
 	proxyListener, err := httputil.NewHTTPListener(httpServer.Addr, useFunnelProxyProtocol)
 	if err != nil {
 		logger.Errorf("Proxy listener setup failed port=%d error=%v", proxyPort, err)
 		proxyServer.MarkEndpointFailure(err.Error())
-		return nil, nil, nil
+		return nil, nil, nil, err
 	}
 
 	go func() {
-		if err := httpServer.Serve(proxyListener); err != nil && err != http.ErrServerClosed {
+		if err := httpServer.Serve(proxyServer.WrapListener(proxyListener)); err != nil && err != http.ErrServerClosed {
 			logger.Errorf("Proxy server error port=%d error=%v", proxyPort, err)
 		}
 	}()
@@ -61,7 +82,7 @@ func SetupLocalTailscaleQuiet(ctx context.Context, tsClient *tailscale.Client, p
 		if err := httputil.WaitForServerReady(ctx, fmt.Sprintf("localhost:%d", proxyPort), 2*time.Second); err != nil {
 			logger.Errorf("Proxy server failed to start port=%d error=%v", proxyPort, err)
 			proxyServer.MarkEndpointFailure(err.Error())
-			return nil, nil, nil
+			return nil, nil, nil, err
 		}
 	}
 
@@ -69,20 +90,16 @@ func SetupLocalTailscaleQuiet(ctx context.Context, tsClient *tailscale.Client, p
 	if !cfg.NoUI {
 		uiPort := cfg.UIPort
 		if uiPort == 0 {
-			uiPort, err = tailscale.FindAvailableLocalPortFrom(tailscale.DefaultLocalUIPort)
+			uiPort, err = tsClient.Ports().UIPort()
 			if err != nil {
-				logger.Warnf("UI server port allocation failed preferred_port=%d error=%v fallback=random", tailscale.DefaultLocalUIPort, err)
-				uiPort, err = tailscale.FindAvailableLocalPort()
-				if err != nil {
-					logger.Warnf("UI server fallback port allocation failed error=%v fallback=disabled", err)
-					uiPort = 0
-				}
+				logger.Warnf("UI server port allocation failed error=%v fallback=disabled", err)
+				uiPort = 0
 			}
 		}
 
 		if uiPort > 0 {
 			logger.Infof("UI starting port=%d", uiPort)
-			uiInfo, err := SetupUIServerQuiet(ctx, tsClient, uiPort, proxyServer, logger, uiFiles)
+			uiInfo, err := SetupUIServerQuiet(ctx, tsClient, cfg, uiPort, proxyServer, logger, uiFiles)
 			if err != nil {
 				logger.Warnf("UI setup failed port=%d", uiPort)
 			} else {
@@ -100,22 +117,13 @@ func SetupLocalTailscaleQuiet(ctx context.Context, tsClient *tailscale.Client, p
 	// Set up Tailscale serve
 	logger.Infof("Tailscale serve starting port=%d", cfg.GetServePort())
 
-	tsConfig := tailscale.Config{
-		MountPath:           cfg.GetSetPath(),
-		EnableFunnel:        cfg.Funnel,
-		EnableProxyProtocol: useFunnelProxyProtocol,
-		UseHTTPS:            cfg.UseHTTPS,
-		ServePort:           cfg.GetServePort(),
-		ProxyPort:           proxyPort,
-		ListenMode:          cfg.TSNetListenMode,
-		ServiceName:         cfg.TSNetServiceName,
-	}
+	tsConfig := ServeConfig(cfg, proxyPort)
 
 	serviceInfo, err = tsClient.SetupServe(ctx, tsConfig)
 	if err != nil {
 		logger.Errorf("Tailscale serve setup failed config=%+v error=%v", tsConfig, err)
 		proxyServer.MarkEndpointFailure(err.Error())
-		return nil, nil, nil
+		return nil, nil, nil, TailscaleFailure(err)
 	}
 
 	if cfg.Mock {
@@ -123,15 +131,35 @@ func SetupLocalTailscaleQuiet(ctx context.Context, tsClient *tailscale.Client, p
 	} else {
 		logger.Infof("Proxy operational port=%d target=%d", proxyPort, cfg.Port)
 	}
+	for _, funnelURL := range serviceInfo.FunnelURLs {
+		logger.Infof("Public funnel path url=%s", funnelURL)
+	}
+	if !cfg.IsServiceMode() {
+		WarmCertificate(ctx, proxyServer, serviceInfo.URL, tsClient.WarmCert)
+	}
 
 	cleanup = func() error {
 		// Use a fresh context for cleanup to avoid cancellation issues
 		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cleanupCancel()
 
-		// Comprehensive cleanup of all Tailscale serve configs
-		err := tsClient.CleanupAll(cleanupCtx)
-		if err != nil {
+		if cfg.Namespace != "" {
+			// Other developers' namespaces share the node; remove only ours.
+			if err := tsClient.RemoveMounts(cleanupCtx, tsConfig, tsConfig.MountPath, cfg.UIMountPath()); err != nil {
+				logger.Warnf("Failed to remove serve handlers namespace=%s error=%v", cfg.Namespace, err)
+			}
+		} else if serveSnapshot != nil {
+			if err := tsClient.RestoreServe(cleanupCtx, serveSnapshot); err != nil {
+				logger.Warnf("Failed to restore the serve config; the next portal session will retry: %v", err)
+			}
+		} else if errors.Is(snapshotErr, tailscale.ErrServeSnapshotHeld) {
+			// The session holding the snapshot restores the config; until
+			// then, take down only this session's handlers.
+			if err := tsClient.RemoveMounts(cleanupCtx, tsConfig, tsConfig.MountPath, cfg.UIMountPath()); err != nil {
+				logger.Warnf("Failed to remove serve handlers error=%v", err)
+			}
+		} else if err := tsClient.CleanupAll(cleanupCtx); err != nil {
+			// Comprehensive cleanup of all Tailscale serve configs failed
 			logger.Warnf("Failed to perform comprehensive cleanup, trying specific cleanup: %v", err)
 			// Fallback to specific config cleanup
 			tsClient.Cleanup(cleanupCtx, tsConfig)
@@ -142,11 +170,12 @@ func SetupLocalTailscaleQuiet(ctx context.Context, tsClient *tailscale.Client, p
 		return httpServer.Shutdown(shutdownCtx)
 	}
 
-	return cleanup, uiCleanup, serviceInfo
+	return cleanup, uiCleanup, serviceInfo, nil
 }
 
-// SetupTsnetQuiet sets up TSNet server with minimal TUI logging
-func SetupTsnetQuiet(ctx context.Context, proxyServer *proxy.Server, logger *tui.TUIOnlyLogger, cfg *config.Config, onReady func(readyInfo tailscale.TSNetReadyInfo)) func() error {
+// SetupTsnetQuiet sets up TSNet server with minimal TUI logging. onFailure
+// is called if the node fails to serve before ctx is done.
+func SetupTsnetQuiet(ctx context.Context, proxyServer *proxy.Server, logger *tui.TUIOnlyLogger, cfg *config.Config, onReady func(readyInfo tailscale.TSNetReadyInfo), onFailure func(error)) func() error {
 	logger.Infof("TSNet setup starting hostname=%s auth_key_provided=%t funnel_enabled=%t https_enabled=%t serve_port=%d tsnet_listen_mode_configured=%s tsnet_listen_mode_effective=%s tsnet_service_name=%s",
 		cfg.TailscaleName, cfg.AuthKey != "", cfg.Funnel, cfg.UseHTTPS, cfg.GetServePort(), cfg.TSNetListenMode, cfg.EffectiveTSNetListenMode(), cfg.TSNetServiceName)
 
@@ -161,21 +190,41 @@ func SetupTsnetQuiet(ctx context.Context, proxyServer *proxy.Server, logger *tui
 		ServePort:    cfg.GetServePort(),
 		ListenMode:   cfg.TSNetListenMode,
 		ServiceName:  cfg.TSNetServiceName,
+		Verbose:      cfg.TSNetVerbose,
+
+		ConfigureHTTPServer: proxyServer.ConfigureHTTPServer,
+		WrapListener:        proxyServer.WrapListener,
 	}
 
 	tsnetServer := tailscale.NewTSNetServer(tsnetConfig, tuiZapLogger)
-	tsnetServer.SetReadyCallback(onReady)
+	tsnetServer.SetReadyCallback(func(info tailscale.TSNetReadyInfo) {
+		if cfg.UIPath != "" {
+			proxyServer.SetWebUIURL(info.ServiceURL + cfg.UIPath + "/")
+		}
+		onReady(info)
+		if !cfg.IsServiceMode() {
+			WarmCertificate(ctx, proxyServer, info.ServiceURL, tsnetServer.WarmCert)
+		}
+	})
+	proxyServer.SetWhoIs(tsnetServer.WhoIs)
+	if cfg.TargetHost != "" {
+		proxyServer.SetUpstreamDialer(tsnetServer.DialPeer)
+	}
 
 	go func() {
 		if err := tsnetServer.Serve(ctx, proxyServer); err != nil {
 			logger.Errorf("TSNet server error: %v", err)
 			proxyServer.MarkEndpointFailure(err.Error())
+			if ctx.Err() == nil {
+				onFailure(TailscaleFailure(err))
+			}
 		}
 	}()
+	closeExposes := StartExposeNodes(ctx, proxyServer, tuiZapLogger, cfg)
 
 	return func() error {
 		logger.Infof("TSNet cleanup starting")
-		err := tsnetServer.Close()
+		err := errors.Join(tsnetServer.Close(), closeExposes())
 		if err != nil {
 			logger.Errorf("TSNet cleanup error: %v", err)
 		} else {
@@ -185,20 +234,52 @@ func SetupTsnetQuiet(ctx context.Context, proxyServer *proxy.Server, logger *tui
 	}
 }
 
+// ServeUI puts the web UI on the tailnet: next to the service with
+// --namespace or --ui-path, otherwise on a serve port of its own.
+func ServeUI(ctx context.Context, tsClient *tailscale.Client, cfg *config.Config, uiPort int) (uint16, string, error) {
+	mountPath := cfg.UIMountPath()
+	if mountPath == "" {
+		return tsClient.SetupUIServe(ctx, uiPort)
+	}
+	return tsClient.SetupMountedUIServe(ctx, uiPort, tailscale.Config{
+		Namespace: cfg.Namespace,
+		UseHTTPS:  cfg.UseHTTPS,
+		ServePort: cfg.GetServePort(),
+	}, mountPath)
+}
+
+// ServeConfig is the tailscale serve setup for cfg, proxying to the local
+// proxy on proxyPort.
+func ServeConfig(cfg *config.Config, proxyPort int) tailscale.Config {
+	return tailscale.Config{
+		MountPath:           cfg.GetSetPath(),
+		EnableFunnel:        cfg.Funnel,
+		EnableProxyProtocol: cfg.UseFunnelProxyProtocol(),
+		UseHTTPS:            cfg.UseHTTPS,
+		ServePort:           cfg.GetServePort(),
+		ProxyPort:           proxyPort,
+		ListenMode:          cfg.TSNetListenMode,
+		ServiceName:         cfg.TSNetServiceName,
+		FunnelPaths:         cfg.FunnelPaths,
+		Namespace:           cfg.Namespace,
+		UIPath:              cfg.UIMountPath(),
+	}
+}
+
 // SetupUIServerQuiet sets up the UI server with minimal TUI logging
-func SetupUIServerQuiet(ctx context.Context, tsClient *tailscale.Client, uiPort int, proxyServer *proxy.Server, logger *tui.TUIOnlyLogger, uiFiles fs.FS) (*model.UIServerInfo, error) {
+func SetupUIServerQuiet(ctx context.Context, tsClient *tailscale.Client, cfg *config.Config, uiPort int, proxyServer *proxy.Server, logger *tui.TUIOnlyLogger, uiFiles fs.FS) (*model.UIServerInfo, error) {
 	// Create UI server with the proxy server as the log provider
 	uiServer := ui.NewServer(proxyServer, uiFiles)
 
 	// Set up Tailscale serve for UI
-	tailscalePort, uiURL, err := tsClient.SetupUIServe(ctx, uiPort)
+	tailscalePort, uiURL, err := ServeUI(ctx, tsClient, cfg, uiPort)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup UI Tailscale serve: %w", err)
 	}
 
 	// Start UI server on local port
 	httpServer := &http.Server{
-		Addr:    fmt.Sprintf(":%d", uiPort),
+		Addr:    cfg.LocalAddr(uiPort),
 		Handler: uiServer,
 	}
 
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"context"
+
+	"github.com/jaxxstorm/portal/internal/capture"
+	"github.com/jaxxstorm/portal/internal/config"
+	"github.com/jaxxstorm/portal/internal/tailscale"
+)
+
+// SnapshotServe saves the node's serve config before this session changes
+// it, so it can be restored exactly on exit instead of cleared. Namespaced
+// sessions share the node and only remove their own handlers, so they take
+// no snapshot and get nil.
+func SnapshotServe(ctx context.Context, tsClient *tailscale.Client, cfg *config.Config) (*tailscale.ServeSnapshot, error) {
+	if cfg.Namespace != "" {
+		return nil, nil
+	}
+	path, err := tailscale.DefaultServeSnapshotPath()
+	if err != nil {
+		return nil, err
+	}
+	return tsClient.SnapshotServe(ctx, path, capture.ProcessAlive)
+}
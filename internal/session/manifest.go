@@ -0,0 +1,353 @@
+// internal/session/manifest.go
+
+// Package session writes a signed manifest of each portal session on
+// shutdown: what was exposed, when, how it was protected and who connected.
+// Manifests are kept in ~/.portal/sessions for review and archiving.
+package session
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/httputil"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// MaxIdentities caps the identities recorded per session. Later ones are
+// only counted in Manifest.Requests, and IdentitiesTruncated is set.
+const MaxIdentities = 1000
+
+// Ways a request reached portal.
+const (
+	ViaTailnet = "tailnet"
+	ViaFunnel  = "funnel"
+)
+
+// manifestPrefix and manifestSuffix frame the session ID in file names.
+const (
+	manifestPrefix = "session-"
+	manifestSuffix = ".json"
+)
+
+// Manifest describes one portal session.
+type Manifest struct {
+	ID            string    `json:"id"`
+	Host          string    `json:"host"`
+	User          string    `json:"user,omitempty"`
+	PortalVersion string    `json:"portal_version"`
+	StartedAt     time.Time `json:"started_at"`
+	EndedAt       time.Time `json:"ended_at"`
+	ExitCode      int       `json:"exit_code"`
+
+	Exposure Exposure `json:"exposure"`
+	Access   Access   `json:"access"`
+
+	// Requests counts every request served, including those from
+	// identities past MaxIdentities.
+	Requests            int        `json:"requests"`
+	Identities          []Identity `json:"identities"`
+	IdentitiesTruncated bool       `json:"identities_truncated,omitempty"`
+}
+
+// Exposure is what the session made reachable and how.
+type Exposure struct {
+	// Mode is "proxy" or "mock".
+	Mode   string `json:"mode"`
+	Target string `json:"target,omitempty"`
+	URL    string `json:"url,omitempty"`
+	// Funnel is set when the endpoint was reachable from the internet;
+	// FunnelPaths limits that to some path prefixes.
+	Funnel      bool     `json:"funnel"`
+	FunnelPaths []string `json:"funnel_paths,omitempty"`
+	// Tailscale is "local" for the machine's tailscaled, or "tsnet" for a
+	// separate node portal ran itself.
+	Tailscale string `json:"tailscale"`
+	Mounts    string `json:"mounts,omitempty"`
+	Exposes   string `json:"exposes,omitempty"`
+	WebUIURL  string `json:"web_ui_url,omitempty"`
+}
+
+// Access is how the endpoint was protected.
+type Access struct {
+	FunnelAllowlist []string `json:"funnel_allowlist,omitempty"`
+	AllowMethods    []string `json:"allow_methods,omitempty"`
+	CORSOrigins     []string `json:"cors_origins,omitempty"`
+	Quotas          []string `json:"quotas,omitempty"`
+	// APITokens is set when mutating web UI API calls needed a token.
+	APITokens   bool   `json:"api_tokens"`
+	JWKSURL     string `json:"jwks_url,omitempty"`
+	ExpiresIn   string `json:"expires_in,omitempty"`
+	MaxRequests int    `json:"max_requests,omitempty"`
+}
+
+// Identity is a client that sent requests during the session: a tailnet
+// user when Tailscale identified one, otherwise a source address.
+type Identity struct {
+	Via       string    `json:"via"`
+	Login     string    `json:"login,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Address   string    `json:"address,omitempty"`
+	Requests  int       `json:"requests"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Recorder collects the identities that connect during a session.
+type Recorder struct {
+	mu         sync.Mutex
+	manifest   Manifest
+	identities map[string]*Identity
+}
+
+// NewRecorder starts recording a session described by manifest.
+func NewRecorder(manifest Manifest) *Recorder {
+	return &Recorder{manifest: manifest, identities: make(map[string]*Identity)}
+}
+
+// Request records the client of a served request. Imported and replayed
+// requests didn't come from a client and are skipped.
+func (r *Recorder) Request(entry model.RequestLog) {
+	if entry.Imported || entry.ReplayOf != "" {
+		return
+	}
+	identity := identify(entry)
+	key := identity.Via + "|" + identity.Login
+	if identity.Login == "" {
+		key += "|" + identity.Address
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.manifest.Requests++
+	known, ok := r.identities[key]
+	if !ok {
+		if len(r.identities) >= MaxIdentities {
+			r.manifest.IdentitiesTruncated = true
+			return
+		}
+		identity.FirstSeen = entry.Timestamp
+		known = &identity
+		r.identities[key] = known
+	}
+	known.Requests++
+	known.LastSeen = entry.Timestamp
+}
+
+// Manifest returns the session so far, with identities in the order they
+// first connected.
+func (r *Recorder) Manifest() Manifest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	manifest := r.manifest
+	manifest.Identities = make([]Identity, 0, len(r.identities))
+	for _, identity := range r.identities {
+		manifest.Identities = append(manifest.Identities, *identity)
+	}
+	slices.SortFunc(manifest.Identities, func(a, b Identity) int {
+		return a.FirstSeen.Compare(b.FirstSeen)
+	})
+	return manifest
+}
+
+// identify returns who sent entry, as resolved by the proxy when it did so.
+// Otherwise tailscale serve adds the Tailscale-User headers for tailnet users
+// and Tailscale-Funnel-Request for Funnel.
+func identify(entry model.RequestLog) Identity {
+	if known := entry.Identity; known != nil {
+		identity := Identity{Via: ViaTailnet, Login: known.Login, Name: known.Name, Address: known.Address}
+		if known.Funnel {
+			identity.Via = ViaFunnel
+		}
+		return identity
+	}
+	identity := Identity{
+		Via:     ViaTailnet,
+		Login:   entry.Headers.Get("Tailscale-User-Login"),
+		Name:    entry.Headers.Get("Tailscale-User-Name"),
+		Address: clientAddress(entry),
+	}
+	if entry.Headers.Get(httputil.FunnelRequestHeader) != "" {
+		identity.Via = ViaFunnel
+	}
+	return identity
+}
+
+// clientAddress returns the first X-Forwarded-For entry, which tailscale
+// serve sets to the client, or the host of RemoteAddr.
+func clientAddress(entry model.RequestLog) string {
+	if forwarded, _, _ := strings.Cut(entry.Headers.Get("X-Forwarded-For"), ","); strings.TrimSpace(forwarded) != "" {
+		return strings.TrimSpace(forwarded)
+	}
+	if host, _, err := net.SplitHostPort(entry.RemoteAddr); err == nil {
+		return host
+	}
+	return entry.RemoteAddr
+}
+
+// File is a manifest as stored on disk. Signature is the Ed25519 signature
+// of the Manifest bytes exactly as stored, by PublicKey; both are base64.
+type File struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	PublicKey string          `json:"public_key"`
+	Signature string          `json:"signature"`
+}
+
+// Sign encodes and signs manifest with key.
+func Sign(manifest Manifest, key ed25519.PrivateKey) (File, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return File{}, err
+	}
+	return File{
+		Manifest:  data,
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)),
+	}, nil
+}
+
+// Verify checks the file's signature. With trusted set, the file must also
+// have been signed by that key.
+func (f File) Verify(trusted ed25519.PublicKey) error {
+	publicKey, err := base64.StdEncoding.DecodeString(f.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("invalid public key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(f.Signature)
+	if err != nil || !ed25519.Verify(publicKey, f.Manifest, signature) {
+		return errors.New("signature does not match")
+	}
+	if trusted != nil && !bytes.Equal(publicKey, trusted) {
+		return errors.New("signed by another key")
+	}
+	return nil
+}
+
+// DefaultDir returns where manifests are kept (~/.portal/sessions).
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".portal", "sessions"), nil
+}
+
+// DefaultKeyPath returns the signing key location (~/.portal/session.key).
+func DefaultKeyPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".portal", "session.key"), nil
+}
+
+// LoadKey reads the signing key at path, creating one when none exists.
+// The file holds the base64 Ed25519 seed.
+func LoadKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+		seed := base64.StdEncoding.EncodeToString(key.Seed()) + "\n"
+		if err := os.WriteFile(path, []byte(seed), 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write session key: %w", err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session key: %w", err)
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid session key in %s", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// Write signs manifest and stores it in dir, returning the file's path.
+func Write(dir string, manifest Manifest, key ed25519.PrivateKey) (string, error) {
+	file, err := Sign(manifest, key)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, manifestPrefix+manifest.ID+manifestSuffix)
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Stored is a manifest read back from disk. Err is set when the file could
+// not be verified; Manifest is still filled in when it could be decoded.
+type Stored struct {
+	Path     string
+	Manifest Manifest
+	Err      error
+}
+
+// List reads the manifests in dir, oldest session first, verifying each
+// against trusted (see File.Verify). A missing dir has no sessions.
+func List(dir string, trusted ed25519.PublicKey) ([]Stored, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []Stored
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, manifestPrefix) || !strings.HasSuffix(name, manifestSuffix) {
+			continue
+		}
+		stored := Stored{Path: filepath.Join(dir, name)}
+		var file File
+		data, err := os.ReadFile(stored.Path)
+		if err == nil {
+			err = json.Unmarshal(data, &file)
+		}
+		if err == nil {
+			err = json.Unmarshal(file.Manifest, &stored.Manifest)
+		}
+		if err == nil {
+			err = file.Verify(trusted)
+		}
+		stored.Err = err
+		sessions = append(sessions, stored)
+	}
+	slices.SortFunc(sessions, func(a, b Stored) int {
+		return a.Manifest.StartedAt.Compare(b.Manifest.StartedAt)
+	})
+	return sessions, nil
+}
+
+// NewID returns a session ID from its start time and process, which sorts
+// and stays unique across concurrent sessions.
+func NewID(startedAt time.Time) string {
+	return fmt.Sprintf("%s-%d", startedAt.UTC().Format("20060102T150405Z"), os.Getpid())
+}
@@ -0,0 +1,95 @@
+package session
+
+import (
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestRecorderCollectsIdentities(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	recorder := NewRecorder(Manifest{ID: NewID(start), StartedAt: start})
+
+	alice := model.HeadersFromMap(map[string]string{"Tailscale-User-Login": "alice@example.com", "Tailscale-User-Name": "Alice", "X-Forwarded-For": "100.64.0.2"})
+	funnel := model.HeadersFromMap(map[string]string{"Tailscale-Funnel-Request": "?1", "X-Forwarded-For": "203.0.113.7, 100.64.0.1"})
+	for _, entry := range []model.RequestLog{
+		{Timestamp: start.Add(1 * time.Second), Headers: alice, RemoteAddr: "127.0.0.1:5000"},
+		{Timestamp: start.Add(2 * time.Second), Headers: funnel, RemoteAddr: "127.0.0.1:5001"},
+		{Timestamp: start.Add(3 * time.Second), Headers: alice, RemoteAddr: "127.0.0.1:5002"},
+		{Timestamp: start.Add(4 * time.Second), Headers: alice, ReplayOf: "req_1"},
+		{Timestamp: start.Add(5 * time.Second), Headers: funnel, Imported: true},
+	} {
+		recorder.Request(entry)
+	}
+
+	manifest := recorder.Manifest()
+	if manifest.Requests != 3 || len(manifest.Identities) != 2 {
+		t.Fatalf("expected 3 requests from 2 identities, got %+v", manifest)
+	}
+	first, second := manifest.Identities[0], manifest.Identities[1]
+	if first.Via != ViaTailnet || first.Login != "alice@example.com" || first.Requests != 2 || !first.LastSeen.Equal(start.Add(3*time.Second)) {
+		t.Fatalf("unexpected tailnet identity %+v", first)
+	}
+	if second.Via != ViaFunnel || second.Address != "203.0.113.7" || second.Login != "" {
+		t.Fatalf("unexpected funnel identity %+v", second)
+	}
+}
+
+func TestWriteAndListVerifiesSignatures(t *testing.T) {
+	dir := t.TempDir()
+	key, err := LoadKey(filepath.Join(dir, "session.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadKey(filepath.Join(dir, "session.key"))
+	if err != nil || !reloaded.Equal(key) {
+		t.Fatalf("expected the key to be reused, got %v", err)
+	}
+
+	sessions := filepath.Join(dir, "sessions")
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	var paths []string
+	for i := range 2 {
+		startedAt := start.Add(time.Duration(i) * time.Hour)
+		manifest := Manifest{ID: NewID(startedAt), StartedAt: startedAt, Exposure: Exposure{URL: "https://dev.example.ts.net/", Funnel: true}}
+		path, err := Write(sessions, manifest, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	data, _ := os.ReadFile(paths[1])
+	tampered := strings.Replace(string(data), `"funnel":true`, `"funnel":false`, 1)
+	if err := os.WriteFile(paths[1], []byte(tampered), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := List(sessions, key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 || !stored[0].Manifest.Exposure.Funnel {
+		t.Fatalf("expected both sessions, got %+v", stored)
+	}
+	if stored[0].Err != nil || stored[1].Err == nil {
+		t.Fatalf("expected only the tampered manifest to fail verification, got %v and %v", stored[0].Err, stored[1].Err)
+	}
+
+	other, err := LoadKey(filepath.Join(dir, "other.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ = List(sessions, other.Public().(ed25519.PublicKey))
+	if stored[0].Err == nil {
+		t.Fatal("expected a manifest signed by another key to be flagged")
+	}
+
+	if stored, err := List(filepath.Join(dir, "missing"), nil); err != nil || stored != nil {
+		t.Fatalf("expected nothing for a missing directory, got %+v %v", stored, err)
+	}
+}
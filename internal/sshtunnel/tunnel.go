@@ -0,0 +1,229 @@
+// internal/sshtunnel/tunnel.go
+package sshtunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultPort is the SSH port used when the target doesn't name one.
+const DefaultPort = 22
+
+// dialTimeout bounds connecting and authenticating to the bastion.
+const dialTimeout = 15 * time.Second
+
+// defaultIdentities are tried, in order, when no identity file is given.
+var defaultIdentities = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// Config describes the bastion to expose the proxy on.
+type Config struct {
+	// Target is user@host[:port].
+	Target string
+	// RemotePort is the port to listen on at the bastion. Zero lets the
+	// SSH server pick one.
+	RemotePort int
+	// IdentityFile is a private key to authenticate with. When empty the
+	// SSH agent and ~/.ssh/id_ed25519, id_ecdsa and id_rsa are tried.
+	IdentityFile string
+	// KnownHostsFile verifies the bastion's host key. Defaults to
+	// ~/.ssh/known_hosts.
+	KnownHostsFile string
+}
+
+// Tunnel is a reverse port forward: connections to the bastion's remote
+// port arrive on the tunnel's listener.
+type Tunnel struct {
+	client   *ssh.Client
+	listener net.Listener
+	host     string
+}
+
+// ParseTarget splits user@host[:port] into the user and a host:port address.
+func ParseTarget(raw string) (string, string, error) {
+	user, hostPort, ok := strings.Cut(strings.TrimSpace(raw), "@")
+	if !ok || user == "" || hostPort == "" {
+		return "", "", fmt.Errorf("invalid ssh target %q: must be user@host[:port], e.g. deploy@bastion.example.com", raw)
+	}
+	host, port := hostPort, strconv.Itoa(DefaultPort)
+	if h, p, err := net.SplitHostPort(hostPort); err == nil {
+		host, port = h, p
+	}
+	host = strings.Trim(host, "[]")
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return "", "", fmt.Errorf("invalid ssh target %q: port must be between 1 and 65535", raw)
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("invalid ssh target %q: missing host", raw)
+	}
+	return user, net.JoinHostPort(host, port), nil
+}
+
+// Open connects to the bastion and asks it to forward RemotePort back to
+// this process.
+func Open(ctx context.Context, cfg Config) (*Tunnel, error) {
+	user, addr, err := ParseTarget(cfg.Target)
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+
+	auth, err := authMethods(cfg.IdentityFile)
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := hostKeyCallback(cfg.KnownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := dialCtx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         dialTimeout,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh handshake with %s failed: %w", addr, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	listener, err := client.Listen("tcp", net.JoinHostPort("0.0.0.0", strconv.Itoa(cfg.RemotePort)))
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to open remote port %d on %s: %w", cfg.RemotePort, addr, err)
+	}
+
+	return &Tunnel{client: client, listener: listener, host: host}, nil
+}
+
+// RemotePort is the port the bastion is listening on, which is the one the
+// server assigned when Config.RemotePort was zero.
+func (t *Tunnel) RemotePort() int {
+	if addr, ok := t.listener.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
+// URL is where the forwarded service can be reached on the bastion.
+func (t *Tunnel) URL() string {
+	return "http://" + net.JoinHostPort(t.host, strconv.Itoa(t.RemotePort()))
+}
+
+// Serve serves handler on the forwarded port until the tunnel is closed or
+// the SSH connection drops. configure, when set, tunes the http.Server
+// before it starts, and wrap, when set, wraps the forwarded listener.
+func (t *Tunnel) Serve(handler http.Handler, configure func(*http.Server), wrap func(net.Listener) net.Listener) error {
+	server := &http.Server{Handler: handler}
+	if configure != nil {
+		configure(server)
+	}
+	ln := t.listener
+	if wrap != nil {
+		ln = wrap(ln)
+	}
+	err := server.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// Wait blocks until the SSH connection ends.
+func (t *Tunnel) Wait() error {
+	return t.client.Wait()
+}
+
+// Close cancels the remote forward and disconnects, which stops Serve.
+func (t *Tunnel) Close() error {
+	_ = t.listener.Close()
+	return t.client.Close()
+}
+
+func authMethods(identityFile string) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if identityFile != "" {
+		signer, err := loadKey(identityFile)
+		if err != nil {
+			return nil, err
+		}
+		return append(methods, ssh.PublicKeys(signer)), nil
+	}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err == nil {
+		var signers []ssh.Signer
+		for _, name := range defaultIdentities {
+			// Keys that are missing or passphrase-protected are skipped;
+			// the agent covers the latter.
+			if signer, err := loadKey(filepath.Join(homeDir, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+		if len(signers) > 0 {
+			methods = append(methods, ssh.PublicKeys(signers...))
+		}
+	}
+
+	if len(methods) == 0 {
+		return nil, errors.New("no ssh credentials found: start ssh-agent or pass --ssh-identity")
+	}
+	return methods, nil
+}
+
+func loadKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh identity %s: %w", path, err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ssh identity %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+func hostKeyCallback(path string) (ssh.HostKeyCallback, error) {
+	if path == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine home directory: %w", err)
+		}
+		path = filepath.Join(homeDir, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts from %s (connect once with ssh to record the host key): %w", path, err)
+	}
+	return callback, nil
+}
@@ -0,0 +1,222 @@
+package sshtunnel
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestParseTarget(t *testing.T) {
+	for _, tc := range []struct {
+		raw, user, addr string
+	}{
+		{"deploy@bastion", "deploy", "bastion:22"},
+		{"deploy@bastion:2222", "deploy", "bastion:2222"},
+		{"ops@[2001:db8::1]:2200", "ops", "[2001:db8::1]:2200"},
+	} {
+		user, addr, err := ParseTarget(tc.raw)
+		if err != nil || user != tc.user || addr != tc.addr {
+			t.Fatalf("ParseTarget(%q): expected %s %s, got %s %s (%v)", tc.raw, tc.user, tc.addr, user, addr, err)
+		}
+	}
+
+	for _, raw := range []string{"bastion", "@bastion", "deploy@", "deploy@bastion:0", "deploy@bastion:ssh"} {
+		if _, _, err := ParseTarget(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestTunnelServesThroughRemoteForward(t *testing.T) {
+	dir := t.TempDir()
+	clientKey := writeIdentity(t, filepath.Join(dir, "id_ed25519"))
+	addr, hostKey := startServer(t, clientKey)
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatalf("write known_hosts: %v", err)
+	}
+
+	tunnel, err := Open(context.Background(), Config{
+		Target:         "deploy@" + addr,
+		IdentityFile:   filepath.Join(dir, "id_ed25519"),
+		KnownHostsFile: knownHosts,
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer tunnel.Close()
+	if tunnel.RemotePort() == 0 {
+		t.Fatalf("expected the server to assign a remote port")
+	}
+
+	go tunnel.Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "through the bastion")
+	}), nil, nil)
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/", tunnel.RemotePort()))
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "through the bastion" {
+		t.Fatalf("expected forwarded response, got %q", body)
+	}
+}
+
+func TestOpenRejectsUnknownHostKey(t *testing.T) {
+	dir := t.TempDir()
+	clientKey := writeIdentity(t, filepath.Join(dir, "id_ed25519"))
+	addr, _ := startServer(t, clientKey)
+	knownHosts := filepath.Join(dir, "known_hosts")
+	if err := os.WriteFile(knownHosts, nil, 0o600); err != nil {
+		t.Fatalf("write known_hosts: %v", err)
+	}
+
+	_, err := Open(context.Background(), Config{
+		Target:         "deploy@" + addr,
+		IdentityFile:   filepath.Join(dir, "id_ed25519"),
+		KnownHostsFile: knownHosts,
+	})
+	if err == nil {
+		t.Fatalf("expected an unknown host key to be rejected")
+	}
+}
+
+func writeIdentity(t *testing.T, path string) ssh.PublicKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("public key: %v", err)
+	}
+	return sshPub
+}
+
+// startServer runs a minimal SSH server that accepts clientKey and
+// implements tcpip-forward on 127.0.0.1.
+func startServer(t *testing.T, clientKey ssh.PublicKey) (string, ssh.PublicKey) {
+	t.Helper()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatalf("host signer: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, fmt.Errorf("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(t, conn, config)
+		}
+	}()
+	return ln.Addr().String(), hostSigner.PublicKey()
+}
+
+func serveConn(t *testing.T, conn net.Conn, config *ssh.ServerConfig) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer sshConn.Close()
+	go func() {
+		for ch := range chans {
+			ch.Reject(ssh.Prohibited, "no sessions")
+		}
+	}()
+
+	for req := range reqs {
+		if req.Type != "tcpip-forward" {
+			req.Reply(false, nil)
+			continue
+		}
+		var forward struct {
+			Addr string
+			Port uint32
+		}
+		if err := ssh.Unmarshal(req.Payload, &forward); err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		ln, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(int(forward.Port)))
+		if err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		t.Cleanup(func() { ln.Close() })
+		port := uint32(ln.Addr().(*net.TCPAddr).Port)
+		req.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+
+		go func() {
+			for {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				payload := ssh.Marshal(struct {
+					Addr       string
+					Port       uint32
+					OriginAddr string
+					OriginPort uint32
+				}{forward.Addr, port, "127.0.0.1", uint32(c.RemoteAddr().(*net.TCPAddr).Port)})
+				go func() {
+					defer c.Close()
+					ch, chReqs, err := sshConn.OpenChannel("forwarded-tcpip", payload)
+					if err != nil {
+						return
+					}
+					go ssh.DiscardRequests(chReqs)
+					go func() {
+						io.Copy(ch, c)
+						ch.CloseWrite()
+					}()
+					io.Copy(c, ch)
+					ch.Close()
+				}()
+			}
+		}()
+	}
+}
@@ -16,9 +16,11 @@ const (
 
 	ModeLocalDaemon = "local_daemon"
 	ModeTSNet       = "tsnet"
+	ModeSSH         = "ssh"
 
 	ExposureTailnet = "tailnet"
 	ExposureFunnel  = "funnel"
+	ExposureBastion = "bastion"
 
 	BackendModeProxy = "proxy"
 	BackendModeMock  = "mock"
@@ -58,6 +60,9 @@ type Summary struct {
 	WebUIStatus string
 	WebUIURL    string
 	WebUIReason string
+	// OAuthCallbackURL is where OAuth providers should redirect to when
+	// --oauth-callback is set.
+	OAuthCallbackURL string
 	TSNetDetails
 	Capabilities
 }
@@ -93,6 +98,10 @@ func BuildReadySummary(cfg *config.Config, useLocalDaemon bool, serviceURL, loca
 		},
 	}
 
+	if cfg.OAuthCallback != "" && summary.ServiceURL != "" {
+		summary.OAuthCallbackURL = strings.TrimRight(summary.ServiceURL, "/") + cfg.OAuthCallback
+	}
+
 	if mode == ModeTSNet {
 		configured := strings.TrimSpace(tsnetDetails.ConfiguredListenMode)
 		if configured == "" {
@@ -133,6 +142,15 @@ func BuildReadySummary(cfg *config.Config, useLocalDaemon bool, serviceURL, loca
 	return summary
 }
 
+// BuildSSHReadySummary describes a proxy exposed on a bastion through a
+// reverse SSH tunnel. The web UI, when running, is only served locally.
+func BuildSSHReadySummary(cfg *config.Config, serviceURL, webUIURL string) Summary {
+	summary := BuildReadySummary(cfg, true, serviceURL, "", webUIURL, TSNetDetails{})
+	summary.Mode = ModeSSH
+	summary.Exposure = ExposureBastion
+	return summary
+}
+
 func ResolveWebUIStatus(uiDisabled bool, webUIURL string) string {
 	if uiDisabled {
 		return WebUIStatusDisabled
@@ -201,6 +219,9 @@ func (s Summary) Fields() []zap.Field {
 	if s.WebUIReason != "" {
 		fields = append(fields, zap.String("web_ui_reason", s.WebUIReason))
 	}
+	if s.OAuthCallbackURL != "" {
+		fields = append(fields, zap.String("oauth_callback_url", s.OAuthCallbackURL))
+	}
 	if s.Mode == ModeTSNet {
 		fields = append(fields,
 			zap.String("tsnet_listen_mode_configured", s.ConfiguredListenMode),
@@ -228,3 +228,21 @@ func TestSummaryEndpointStateProjection(t *testing.T) {
 		t.Fatalf("unexpected web UI status: got %q want %q", got, want)
 	}
 }
+
+func TestBuildReadySummaryOAuthCallbackURL(t *testing.T) {
+	cfg := &config.Config{Mock: true, OAuthCallback: "/callback"}
+
+	summary := BuildReadySummary(cfg, true, "https://node.ts.net/", "", "", TSNetDetails{})
+	if got, want := summary.OAuthCallbackURL, "https://node.ts.net/callback"; got != want {
+		t.Fatalf("unexpected callback URL: got %q want %q", got, want)
+	}
+	found := false
+	for _, field := range summary.Fields() {
+		if field.Key == "oauth_callback_url" && field.String == "https://node.ts.net/callback" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected oauth_callback_url field")
+	}
+}
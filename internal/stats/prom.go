@@ -0,0 +1,52 @@
+// internal/stats/prom.go
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// PrometheusContentType is the content type of WritePrometheus output.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WritePrometheus writes the snapshot in the Prometheus text exposition
+// format. Response times are in seconds, as Prometheus expects.
+func (s StatsSnapshot) WritePrometheus(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("portal_stats_start_time_seconds", "gauge", "When the counters were started or last reset, as a Unix time.")
+	fmt.Fprintf(out, "portal_stats_start_time_seconds %d\n", s.StartedAt.Unix())
+	metric("portal_requests_total", "counter", "Requests served since the counters started.")
+	fmt.Fprintf(out, "portal_requests_total %d\n", s.TotalConnections)
+	metric("portal_open_connections", "gauge", "Requests in flight.")
+	fmt.Fprintf(out, "portal_open_connections %d\n", s.OpenConnections)
+
+	metric("portal_response_time_average_seconds", "gauge", "Average response time of recent requests.")
+	fmt.Fprintf(out, "portal_response_time_average_seconds{window=\"1m\"} %g\n", s.AvgResponseTime1m/1000)
+	fmt.Fprintf(out, "portal_response_time_average_seconds{window=\"5m\"} %g\n", s.AvgResponseTime5m/1000)
+	metric("portal_response_time_seconds", "gauge", "Response time percentiles of recent requests.")
+	fmt.Fprintf(out, "portal_response_time_seconds{quantile=\"0.5\"} %g\n", s.P50ResponseTime/1000)
+	fmt.Fprintf(out, "portal_response_time_seconds{quantile=\"0.9\"} %g\n", s.P90ResponseTime/1000)
+
+	counts := func(name, label, help string, byLabel map[string]int) {
+		if len(byLabel) == 0 {
+			return
+		}
+		metric(name, "counter", help)
+		for _, value := range slices.Sorted(maps.Keys(byLabel)) {
+			fmt.Fprintf(out, "%s{%s=%q} %d\n", name, label, value, byLabel[value])
+		}
+	}
+	counts("portal_limit_violations_total", "kind", "Requests and connections rejected by request limits.", s.Violations)
+	counts("portal_upstream_failures_total", "kind", "Upstream requests that failed.", s.UpstreamFailures)
+	counts("portal_unmatched_requests_total", "path", "Requests that matched no mount.", s.UnmatchedRoutes)
+	counts("portal_requests_by_country_total", "country", "Requests from public clients, by GeoIP country.", s.Countries)
+
+	return out.Flush()
+}
@@ -0,0 +1,174 @@
+// internal/stats/series.go
+package stats
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+const (
+	// SeriesStep is the width of each time bucket in a Series.
+	SeriesStep = 5 * time.Second
+	// SeriesLength is how many buckets a Series covers, five minutes' worth.
+	SeriesLength = 60
+
+	// bucketSamples bounds the durations kept per bucket for percentiles.
+	bucketSamples = 512
+	// bucketPaths bounds the distinct paths counted per bucket; the rest
+	// are counted under OtherPaths.
+	bucketPaths = 100
+	// topPaths is how many paths a Series lists.
+	topPaths = 10
+)
+
+// OtherPaths stands in for paths beyond the per-bucket limit in TopPaths.
+const OtherPaths = "(other)"
+
+// Series is recent traffic in SeriesStep buckets, oldest first. It ends with
+// the last complete bucket, so a partial bucket never shows as a dip.
+type Series struct {
+	// Start is when the first bucket begins.
+	Start       time.Time `json:"start"`
+	StepSeconds float64   `json:"step_seconds"`
+
+	Requests          []int     `json:"requests"`
+	RequestsPerSecond []float64 `json:"requests_per_second"`
+	// P50, P90 and P99 are response time percentiles in ms, zero for
+	// buckets without requests.
+	P50 []float64 `json:"p50_ms"`
+	P90 []float64 `json:"p90_ms"`
+	P99 []float64 `json:"p99_ms"`
+	// StatusCodes counts responses per bucket by status code.
+	StatusCodes map[int][]int `json:"status_codes"`
+	// TopPaths are the most requested paths across the whole series.
+	TopPaths []PathCount `json:"top_paths"`
+}
+
+// PathCount is how many requests a path received.
+type PathCount struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// bucket counts the requests completed during one SeriesStep.
+type bucket struct {
+	slot      int64 // start time in SeriesStep units; identifies stale buckets
+	count     int
+	durations []time.Duration
+	statuses  map[int]int
+	paths     map[string]int
+}
+
+// at returns the bucket for now, clearing it first if it holds an older
+// slot. Callers hold t.mu.
+func (t *Tracker) at(now time.Time) *bucket {
+	slot := seriesSlot(now)
+	b := &t.buckets[slot%SeriesLength]
+	if b.slot != slot {
+		clear(b.statuses)
+		clear(b.paths)
+		*b = bucket{slot: slot, durations: b.durations[:0], statuses: b.statuses, paths: b.paths}
+	}
+	return b
+}
+
+func seriesSlot(now time.Time) int64 {
+	return now.UnixNano() / int64(SeriesStep)
+}
+
+// record adds a request completed at now to its bucket. Callers hold t.mu.
+func (t *Tracker) record(now time.Time, duration time.Duration) {
+	b := t.at(now)
+	// Past the sample cap, overwrite earlier samples in turn so the
+	// percentiles still see the whole bucket.
+	if len(b.durations) < bucketSamples {
+		b.durations = append(b.durations, duration)
+	} else {
+		b.durations[b.count%bucketSamples] = duration
+	}
+	b.count++
+}
+
+// AddResponse counts the status code and path of a completed request in the
+// series. It complements AddRequest, which records the duration.
+func (t *Tracker) AddResponse(status int, path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.recordResponse(time.Now(), status, path)
+}
+
+func (t *Tracker) recordResponse(now time.Time, status int, path string) {
+	b := t.at(now)
+	if b.statuses == nil {
+		b.statuses = make(map[int]int)
+		b.paths = make(map[string]int)
+	}
+	b.statuses[status]++
+	if _, ok := b.paths[path]; !ok && len(b.paths) >= bucketPaths {
+		path = OtherPaths
+	}
+	b.paths[path]++
+}
+
+// Series returns the traffic, latency percentiles, status codes and top
+// paths over the last SeriesLength buckets.
+func (t *Tracker) Series() Series {
+	return t.seriesAt(time.Now())
+}
+
+func (t *Tracker) seriesAt(now time.Time) Series {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	current := seriesSlot(now)
+	first := current - SeriesLength
+	series := Series{
+		Start:             time.Unix(0, first*int64(SeriesStep)).UTC(),
+		StepSeconds:       SeriesStep.Seconds(),
+		Requests:          make([]int, SeriesLength),
+		RequestsPerSecond: make([]float64, SeriesLength),
+		P50:               make([]float64, SeriesLength),
+		P90:               make([]float64, SeriesLength),
+		P99:               make([]float64, SeriesLength),
+		StatusCodes:       make(map[int][]int),
+	}
+	paths := make(map[string]int)
+	for i := range SeriesLength {
+		slot := first + int64(i)
+		b := t.buckets[slot%SeriesLength]
+		if b.slot != slot {
+			continue
+		}
+		for status, count := range b.statuses {
+			if series.StatusCodes[status] == nil {
+				series.StatusCodes[status] = make([]int, SeriesLength)
+			}
+			series.StatusCodes[status][i] = count
+		}
+		for path, count := range b.paths {
+			paths[path] += count
+		}
+		if b.count == 0 {
+			continue
+		}
+		series.Requests[i] = b.count
+		series.RequestsPerSecond[i] = float64(b.count) / SeriesStep.Seconds()
+		sorted := slices.Clone(b.durations)
+		slices.Sort(sorted)
+		series.P50[i] = float64(sorted[len(sorted)*50/100]) / float64(time.Millisecond)
+		series.P90[i] = float64(sorted[len(sorted)*90/100]) / float64(time.Millisecond)
+		series.P99[i] = float64(sorted[len(sorted)*99/100]) / float64(time.Millisecond)
+	}
+
+	for path, count := range paths {
+		series.TopPaths = append(series.TopPaths, PathCount{Path: path, Count: count})
+	}
+	slices.SortFunc(series.TopPaths, func(a, b PathCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Path, b.Path))
+	})
+	if len(series.TopPaths) > topPaths {
+		series.TopPaths = series.TopPaths[:topPaths]
+	}
+	return series
+}
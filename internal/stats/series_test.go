@@ -0,0 +1,67 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeriesBucketsRecentTraffic(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Unix(1_700_000_000, 0)
+	lastStep := now.Add(-SeriesStep)
+	// Too old to show: its slot is reused by the last step.
+	tracker.record(now.Add(-SeriesLength*SeriesStep-SeriesStep), time.Second)
+	for i := range 10 {
+		tracker.record(lastStep, time.Duration(i+1)*time.Millisecond)
+	}
+	tracker.record(now.Add(-3*SeriesStep), 40*time.Millisecond)
+	// In the bucket still being filled.
+	tracker.record(now, time.Second)
+
+	series := tracker.seriesAt(now)
+	if len(series.RequestsPerSecond) != SeriesLength {
+		t.Fatalf("expected %d buckets, got %d", SeriesLength, len(series.RequestsPerSecond))
+	}
+	last := SeriesLength - 1
+	if series.RequestsPerSecond[last] != 2 || series.P50[last] != 6 || series.P90[last] != 10 {
+		t.Fatalf("unexpected last bucket: %v req/s, p50 %v, p90 %v", series.RequestsPerSecond[last], series.P50[last], series.P90[last])
+	}
+	if series.RequestsPerSecond[last-2] != 0.2 || series.P50[last-2] != 40 {
+		t.Fatalf("unexpected earlier bucket: %v req/s, p50 %v", series.RequestsPerSecond[last-2], series.P50[last-2])
+	}
+	var total float64
+	for _, rps := range series.RequestsPerSecond {
+		total += rps
+	}
+	if total != 2.2 {
+		t.Fatalf("expected only the two recent buckets to count, got %v req/s in total", total)
+	}
+
+	tracker.Reset()
+	if series := tracker.seriesAt(now); series.RequestsPerSecond[last] != 0 {
+		t.Fatalf("expected Reset to clear the series, got %v", series.RequestsPerSecond)
+	}
+}
+
+func TestSeriesStatusCodesAndTopPaths(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Unix(1_700_000_000, 0)
+	lastStep := now.Add(-SeriesStep)
+	for range 3 {
+		tracker.recordResponse(lastStep, 200, "/hooks")
+	}
+	tracker.recordResponse(lastStep, 502, "/hooks")
+	tracker.recordResponse(now.Add(-2*SeriesStep), 404, "/missing")
+
+	series := tracker.seriesAt(now)
+	last := SeriesLength - 1
+	if series.StatusCodes[200][last] != 3 || series.StatusCodes[502][last] != 1 || series.StatusCodes[404][last-1] != 1 {
+		t.Fatalf("unexpected status codes %v", series.StatusCodes)
+	}
+	if len(series.TopPaths) != 2 || series.TopPaths[0] != (PathCount{Path: "/hooks", Count: 4}) {
+		t.Fatalf("unexpected top paths %+v", series.TopPaths)
+	}
+	if !series.Start.Equal(now.Add(-SeriesLength * SeriesStep)) {
+		t.Fatalf("expected the series to start %s ago, got %s", SeriesLength*SeriesStep, series.Start)
+	}
+}
@@ -14,7 +14,23 @@ type Tracker struct {
 	Durations        []time.Duration
 	ResponseTimes1m  []time.Duration
 	ResponseTimes5m  []time.Duration
-	mu               sync.RWMutex
+	// Violations counts requests and connections rejected by request limits,
+	// keyed by violation kind (e.g. "body_too_large").
+	Violations map[string]int
+	// Failures counts upstream requests that failed, keyed by failure kind
+	// (e.g. "timeout").
+	Failures map[string]int
+	// Unmatched counts requests that matched no mount, keyed by path. Only
+	// the first MaxUnmatchedPaths paths get their own count.
+	Unmatched map[string]int
+	// Countries counts requests from public clients by the ISO code of the
+	// country GeoIP placed them in.
+	Countries map[string]int
+	// buckets is a ring of recent traffic for Series.
+	buckets [SeriesLength]bucket
+	// startedAt is when counting began: at creation or the last Reset.
+	startedAt time.Time
+	mu        sync.RWMutex
 }
 
 // Stats is an alias for Tracker to match the alternate interface
@@ -26,6 +42,7 @@ func NewTracker() *Tracker {
 		Durations:       make([]time.Duration, 0),
 		ResponseTimes1m: make([]time.Duration, 0),
 		ResponseTimes5m: make([]time.Duration, 0),
+		startedAt:       time.Now(),
 	}
 }
 
@@ -41,6 +58,7 @@ func (t *Tracker) AddRequest(duration time.Duration) {
 
 	t.TotalConnections++
 	t.Durations = append(t.Durations, duration)
+	t.record(time.Now(), duration)
 
 	// Keep only last minute for rt1
 	t.ResponseTimes1m = append(t.ResponseTimes1m, duration)
@@ -89,6 +107,103 @@ func (t *Tracker) DecOpen() {
 	t.DecrementOpen()
 }
 
+// AddViolation counts a request or connection rejected by a limit.
+func (t *Tracker) AddViolation(kind string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Violations == nil {
+		t.Violations = make(map[string]int)
+	}
+	t.Violations[kind]++
+}
+
+// GetViolations returns a copy of the limit violation counts.
+func (t *Tracker) GetViolations() map[string]int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	violations := make(map[string]int, len(t.Violations))
+	for kind, count := range t.Violations {
+		violations[kind] = count
+	}
+	return violations
+}
+
+// AddFailure counts an upstream request that failed.
+func (t *Tracker) AddFailure(kind string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Failures == nil {
+		t.Failures = make(map[string]int)
+	}
+	t.Failures[kind]++
+}
+
+// GetFailures returns a copy of the upstream failure counts.
+func (t *Tracker) GetFailures() map[string]int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	failures := make(map[string]int, len(t.Failures))
+	for kind, count := range t.Failures {
+		failures[kind] = count
+	}
+	return failures
+}
+
+// MaxUnmatchedPaths caps the distinct paths counted by AddUnmatched, so a
+// scanner can't grow the map without bound. Later paths are counted under
+// UnmatchedOther.
+const MaxUnmatchedPaths = 100
+
+// UnmatchedOther is the key unmatched requests are counted under once
+// MaxUnmatchedPaths paths have been seen.
+const UnmatchedOther = "other"
+
+// AddUnmatched counts a request to path that matched no route.
+func (t *Tracker) AddUnmatched(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Unmatched == nil {
+		t.Unmatched = make(map[string]int)
+	}
+	if _, ok := t.Unmatched[path]; !ok && len(t.Unmatched) >= MaxUnmatchedPaths {
+		path = UnmatchedOther
+	}
+	t.Unmatched[path]++
+}
+
+// GetUnmatched returns a copy of the unmatched route counts.
+func (t *Tracker) GetUnmatched() map[string]int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	unmatched := make(map[string]int, len(t.Unmatched))
+	for path, count := range t.Unmatched {
+		unmatched[path] = count
+	}
+	return unmatched
+}
+
+// AddCountry counts a request from a client in the country with the given
+// ISO code.
+func (t *Tracker) AddCountry(code string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Countries == nil {
+		t.Countries = make(map[string]int)
+	}
+	t.Countries[code]++
+}
+
+// GetCountries returns a copy of the per-country request counts.
+func (t *Tracker) GetCountries() map[string]int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	countries := make(map[string]int, len(t.Countries))
+	for code, count := range t.Countries {
+		countries[code] = count
+	}
+	return countries
+}
+
 // GetStats returns current statistics
 // Returns: total connections, open connections, avg response time 1m, avg response time 5m, p50, p90 (all times in ms)
 func (t *Tracker) GetStats() (ttl, opn int, rt1, rt5, p50, p90 float64) {
@@ -138,40 +253,68 @@ func (t *Tracker) GetStats() (ttl, opn int, rt1, rt5, p50, p90 float64) {
 	return
 }
 
-// StatsSnapshot represents a snapshot of statistics
+// StatsSnapshot represents a snapshot of statistics. StartedAt and TakenAt
+// bound the period the counters cover, so scripts can diff successive
+// snapshots and notice a reset in between (StartedAt moves).
 type StatsSnapshot struct {
-	TotalConnections  int     `json:"total_connections"`
-	OpenConnections   int     `json:"open_connections"`
-	AvgResponseTime1m float64 `json:"avg_response_time_1m"`
-	AvgResponseTime5m float64 `json:"avg_response_time_5m"`
-	P50ResponseTime   float64 `json:"p50_response_time"`
-	P90ResponseTime   float64 `json:"p90_response_time"`
+	StartedAt time.Time `json:"started_at"`
+	TakenAt   time.Time `json:"taken_at"`
+	// WindowSeconds is the time between StartedAt and TakenAt.
+	WindowSeconds float64 `json:"window_seconds"`
+
+	TotalConnections  int            `json:"total_connections"`
+	OpenConnections   int            `json:"open_connections"`
+	AvgResponseTime1m float64        `json:"avg_response_time_1m"`
+	AvgResponseTime5m float64        `json:"avg_response_time_5m"`
+	P50ResponseTime   float64        `json:"p50_response_time"`
+	P90ResponseTime   float64        `json:"p90_response_time"`
+	Violations        map[string]int `json:"limit_violations,omitempty"`
+	UpstreamFailures  map[string]int `json:"upstream_failures,omitempty"`
+	UnmatchedRoutes   map[string]int `json:"unmatched_routes,omitempty"`
+	Countries         map[string]int `json:"countries,omitempty"`
 }
 
 // Snapshot returns a snapshot of current statistics
 func (t *Tracker) Snapshot() StatsSnapshot {
 	ttl, opn, rt1, rt5, p50, p90 := t.GetStats()
+	t.mu.RLock()
+	startedAt := t.startedAt
+	t.mu.RUnlock()
+	now := time.Now()
 
 	return StatsSnapshot{
+		StartedAt:         startedAt,
+		TakenAt:           now,
+		WindowSeconds:     now.Sub(startedAt).Seconds(),
 		TotalConnections:  ttl,
 		OpenConnections:   opn,
 		AvgResponseTime1m: rt1,
 		AvgResponseTime5m: rt5,
 		P50ResponseTime:   p50,
 		P90ResponseTime:   p90,
+		Violations:        t.GetViolations(),
+		UpstreamFailures:  t.GetFailures(),
+		UnmatchedRoutes:   t.GetUnmatched(),
+		Countries:         t.GetCountries(),
 	}
 }
 
-// Reset resets all statistics
+// Reset resets all statistics and starts a new counting period. Open
+// connections are left alone, as those requests are still in flight.
 func (t *Tracker) Reset() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	t.TotalConnections = 0
-	t.OpenConnections = 0
 	t.Durations = t.Durations[:0]
 	t.ResponseTimes1m = t.ResponseTimes1m[:0]
 	t.ResponseTimes5m = t.ResponseTimes5m[:0]
+	t.Violations = nil
+	t.Failures = nil
+	t.Unmatched = nil
+	t.Countries = nil
+	t.buckets = [SeriesLength]bucket{}
+	t.startedAt = time.Now()
 }
 
 // GetConnectionCount returns the current connection counts
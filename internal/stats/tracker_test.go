@@ -0,0 +1,86 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResetStartsANewSnapshotPeriod(t *testing.T) {
+	tracker := NewTracker()
+	tracker.IncrementOpen()
+	tracker.AddRequest(20 * time.Millisecond)
+	tracker.AddFailure("timeout")
+
+	before := tracker.Snapshot()
+	if before.TotalConnections != 1 || before.StartedAt.IsZero() || before.TakenAt.Before(before.StartedAt) {
+		t.Fatalf("unexpected snapshot %+v", before)
+	}
+
+	time.Sleep(time.Millisecond)
+	tracker.Reset()
+	after := tracker.Snapshot()
+	if after.TotalConnections != 0 || len(after.UpstreamFailures) != 0 {
+		t.Fatalf("expected Reset to zero the counters, got %+v", after)
+	}
+	if after.OpenConnections != 1 {
+		t.Fatalf("expected in-flight requests to survive a reset, got %d", after.OpenConnections)
+	}
+	if !after.StartedAt.After(before.StartedAt) || after.WindowSeconds > before.WindowSeconds+1 {
+		t.Fatalf("expected a new period after Reset, got %v then %v", before.StartedAt, after.StartedAt)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	snapshot := StatsSnapshot{
+		StartedAt:        time.Unix(1_700_000_000, 0),
+		TotalConnections: 12,
+		OpenConnections:  1,
+		P50ResponseTime:  25,
+		UpstreamFailures: map[string]int{"timeout": 2, "refused": 1},
+		UnmatchedRoutes:  map[string]int{"/webhok": 3},
+		Countries:        map[string]int{"DE": 4},
+	}
+	var out strings.Builder
+	if err := snapshot.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"portal_stats_start_time_seconds 1700000000\n",
+		"# TYPE portal_requests_total counter\nportal_requests_total 12\n",
+		"portal_open_connections 1\n",
+		`portal_response_time_seconds{quantile="0.5"} 0.025` + "\n",
+		`portal_upstream_failures_total{kind="refused"} 1` + "\n" + `portal_upstream_failures_total{kind="timeout"} 2` + "\n",
+		`portal_unmatched_requests_total{path="/webhok"} 3` + "\n",
+		`portal_requests_by_country_total{country="DE"} 4` + "\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "portal_limit_violations_total") {
+		t.Fatalf("expected no violation metric without violations, got\n%s", out.String())
+	}
+}
+
+func TestAddUnmatchedCapsDistinctPaths(t *testing.T) {
+	tracker := NewTracker()
+	for i := range MaxUnmatchedPaths + 5 {
+		tracker.AddUnmatched(fmt.Sprintf("/probe-%d", i))
+	}
+	tracker.AddUnmatched("/probe-0")
+
+	unmatched := tracker.GetUnmatched()
+	if len(unmatched) != MaxUnmatchedPaths+1 {
+		t.Fatalf("expected %d paths plus %q, got %d", MaxUnmatchedPaths, UnmatchedOther, len(unmatched))
+	}
+	if unmatched["/probe-0"] != 2 || unmatched[UnmatchedOther] != 5 {
+		t.Fatalf("unexpected counts %v", unmatched)
+	}
+
+	tracker.Reset()
+	if len(tracker.Snapshot().UnmatchedRoutes) != 0 {
+		t.Fatalf("expected reset to clear unmatched counts")
+	}
+}
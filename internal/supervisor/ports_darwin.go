@@ -0,0 +1,42 @@
+package supervisor
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// listeningPorts returns the TCP ports that processes in the process group
+// pgid listen on, lowest first. macOS has no /proc, so it asks lsof.
+func listeningPorts(pgid int) ([]int, error) {
+	out, err := exec.Command("lsof", "-nP", "-a", "-g", strconv.Itoa(pgid), "-iTCP", "-sTCP:LISTEN", "-Fn").Output()
+	if err != nil {
+		// lsof exits with 1 when nothing matches.
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ports []int
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// Names look like "n*:3000", "n127.0.0.1:3000" or "n[::1]:3000".
+		name, ok := strings.CutPrefix(scanner.Text(), "n")
+		if !ok {
+			continue
+		}
+		i := strings.LastIndexByte(name, ':')
+		if i < 0 {
+			continue
+		}
+		if port, err := strconv.Atoi(name[i+1:]); err == nil {
+			ports = append(ports, port)
+		}
+	}
+	slices.Sort(ports)
+	return slices.Compact(ports), nil
+}
@@ -0,0 +1,118 @@
+package supervisor
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the socket state /proc/net/tcp reports for listening sockets.
+const tcpListen = "0A"
+
+// listeningPorts returns the TCP ports that processes in the process group
+// pgid listen on, lowest first.
+func listeningPorts(pgid int) ([]int, error) {
+	inodes, err := groupSockets(pgid)
+	if err != nil || len(inodes) == 0 {
+		return nil, err
+	}
+	var ports []int
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		found, err := listeningInTable(table, inodes)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		ports = append(ports, found...)
+	}
+	slices.Sort(ports)
+	return slices.Compact(ports), nil
+}
+
+// groupSockets returns the inodes of the sockets that processes in the
+// process group pgid hold open.
+func groupSockets(pgid int) (map[string]bool, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	inodes := make(map[string]bool)
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		dir := filepath.Join("/proc", entry.Name())
+		if processGroup(dir) != pgid {
+			continue
+		}
+		fds, err := os.ReadDir(filepath.Join(dir, "fd"))
+		if err != nil {
+			// The process exited or isn't ours to inspect.
+			continue
+		}
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(dir, "fd", fd.Name()))
+			if err != nil {
+				continue
+			}
+			if inode, ok := strings.CutPrefix(link, "socket:["); ok {
+				inodes[strings.TrimSuffix(inode, "]")] = true
+			}
+		}
+	}
+	return inodes, nil
+}
+
+// processGroup reads the process group from /proc/<pid>/stat, or returns -1.
+func processGroup(dir string) int {
+	data, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return -1
+	}
+	// The command name may contain spaces, so fields are counted from the
+	// closing parenthesis: state, ppid, pgrp.
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return -1
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 3 {
+		return -1
+	}
+	pgrp, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return -1
+	}
+	return pgrp
+}
+
+// listeningInTable returns the ports of listening sockets in a /proc/net/tcp
+// style table whose inodes are in inodes.
+func listeningInTable(table string, inodes map[string]bool) ([]int, error) {
+	f, err := os.Open(table)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ports []int
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListen || !inodes[fields[9]] {
+			continue
+		}
+		_, hexPort, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		if port, err := strconv.ParseUint(hexPort, 16, 16); err == nil {
+			ports = append(ports, int(port))
+		}
+	}
+	return ports, scanner.Err()
+}
@@ -0,0 +1,36 @@
+package supervisor
+
+import (
+	"net"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+func TestListeningPortsFindsTheGroupsSockets(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	ports, err := listeningPorts(syscall.Getpgrp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(ports, port) {
+		t.Fatalf("expected port %d among %v", port, ports)
+	}
+
+	// A connected socket is not a listener.
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	local := conn.LocalAddr().(*net.TCPAddr).Port
+	if ports, _ := listeningPorts(syscall.Getpgrp()); slices.Contains(ports, local) {
+		t.Fatalf("did not expect the client port %d among %v", local, ports)
+	}
+}
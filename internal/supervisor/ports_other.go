@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package supervisor
+
+import "errors"
+
+// errPortDetectionUnsupported is returned where the command's listening
+// sockets cannot be inspected.
+var errPortDetectionUnsupported = errors.New("port detection is not supported on this platform; pass the port, e.g. portal run 3000 -- npm run dev")
+
+func listeningPorts(pgid int) ([]int, error) {
+	return nil, errPortDetectionUnsupported
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package supervisor
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup puts the command in its own process group so stopping it
+// also stops the processes it started, such as the server behind npm.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func terminate(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+func kill(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
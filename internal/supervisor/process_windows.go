@@ -0,0 +1,16 @@
+//go:build windows
+
+package supervisor
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminate kills the command outright, as Windows has no SIGTERM.
+func terminate(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func kill(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,415 @@
+// internal/supervisor/supervisor.go
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/config"
+	"github.com/jaxxstorm/portal/internal/logging"
+)
+
+// Streams a Line can come from.
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+const (
+	// minBackoff and maxBackoff bound the wait before a restart, which
+	// doubles while the command keeps failing quickly.
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+	// stableAfter is how long a run must last to reset the backoff.
+	stableAfter = 10 * time.Second
+	// stopTimeout is how long the command gets to exit after SIGTERM
+	// before it is killed.
+	stopTimeout = 5 * time.Second
+	// maxBacklog bounds the lines kept until SetOutput is called.
+	maxBacklog = 1000
+	// maxLineLength splits overlong lines, e.g. from progress bars.
+	maxLineLength = 4096
+	// portPollInterval is how often the command's sockets are checked while
+	// detecting its port.
+	portPollInterval = 250 * time.Millisecond
+)
+
+// Line is one line of the command's output.
+type Line struct {
+	Stream string
+	Text   string
+	Time   time.Time
+}
+
+// Options configures the supervised command.
+type Options struct {
+	// Command is the program and its arguments.
+	Command []string
+	// Env is added to portal's own environment for the command.
+	Env []string
+	// Restart is one of config.RestartPolicies.
+	Restart string
+	// DetectPort finds the port the command listens on after every start,
+	// for commands that don't take the port from portal.
+	DetectPort bool
+	Logger     *zap.Logger
+}
+
+// Supervisor runs a command, restarting it according to its policy until
+// Stop. A nil Supervisor does nothing, so callers needn't check whether
+// `portal run` is in use.
+type Supervisor struct {
+	opts Options
+
+	mu       sync.Mutex
+	logger   *zap.Logger
+	output   func(Line)
+	backlog  []Line
+	cmd      *exec.Cmd
+	exited   chan struct{}
+	exitCode int
+
+	// port is the last port detected; portReady is closed once the first
+	// is found or detection fails with portErr.
+	port      int
+	portErr   error
+	portReady chan struct{}
+	portOnce  sync.Once
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Start starts the command and supervises it in the background. It fails
+// only when the command cannot be started at all.
+func Start(opts Options) (*Supervisor, error) {
+	if len(opts.Command) == 0 {
+		return nil, errors.New("no command to run")
+	}
+	s := &Supervisor{
+		opts:      opts,
+		logger:    opts.Logger,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		portReady: make(chan struct{}),
+	}
+	if s.logger == nil {
+		s.logger = zap.NewNop()
+	}
+	cmd, err := s.start()
+	if err != nil {
+		return nil, err
+	}
+	go s.supervise(cmd)
+	return s, nil
+}
+
+// SetOutput sends the command's output to fn, starting with the lines
+// printed before it was set.
+func (s *Supervisor) SetOutput(fn func(Line)) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, line := range s.backlog {
+		fn(line)
+	}
+	s.backlog = nil
+	s.output = fn
+}
+
+// ReplaceLogger sends lifecycle logs such as restarts to logger, e.g. the
+// TUI's.
+func (s *Supervisor) ReplaceLogger(logger *zap.Logger) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = logger
+}
+
+// Done is closed once the command has exited and will not be restarted.
+func (s *Supervisor) Done() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.done
+}
+
+// ExitCode is the command's last exit status, valid once Done is closed.
+func (s *Supervisor) ExitCode() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exitCode
+}
+
+// Port is the port the command was last detected listening on, or 0.
+func (s *Supervisor) Port() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.port
+}
+
+// WaitForPort waits until the command listens on a port and returns it. It
+// fails if the command exits for good first or the port cannot be detected.
+func (s *Supervisor) WaitForPort(ctx context.Context) (int, error) {
+	if s == nil {
+		return 0, errors.New("no command to detect a port for")
+	}
+	select {
+	case <-s.portReady:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.port, s.portErr
+	case <-s.done:
+		return 0, fmt.Errorf("%s exited before listening on a port", s.opts.Command[0])
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// Stop terminates the command, killing it if it hasn't exited within a few
+// seconds, and waits for it.
+func (s *Supervisor) Stop() {
+	if s == nil {
+		return
+	}
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		s.mu.Lock()
+		cmd := s.cmd
+		s.mu.Unlock()
+		if cmd != nil {
+			terminate(cmd)
+			select {
+			case <-s.done:
+			case <-time.After(stopTimeout):
+				kill(cmd)
+			}
+		}
+		<-s.done
+	})
+}
+
+func (s *Supervisor) start() (*exec.Cmd, error) {
+	cmd := exec.Command(s.opts.Command[0], s.opts.Command[1:]...)
+	cmd.Env = append(os.Environ(), s.opts.Env...)
+	cmd.Stdout = &lineWriter{stream: Stdout, emit: s.emit}
+	cmd.Stderr = &lineWriter{stream: Stderr, emit: s.emit}
+	// Children such as the server npm starts may keep the output open after
+	// the command exits; don't wait on them forever.
+	cmd.WaitDelay = time.Second
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", s.opts.Command[0], err)
+	}
+
+	exited := make(chan struct{})
+	s.mu.Lock()
+	s.cmd = cmd
+	s.exited = exited
+	logger := s.logger
+	s.mu.Unlock()
+	logger.Info("Started command",
+		logging.Component("supervisor"),
+		zap.String("command", strings.Join(s.opts.Command, " ")),
+		zap.Int("pid", cmd.Process.Pid),
+	)
+	if s.opts.DetectPort {
+		go s.detectPort(cmd.Process.Pid, exited)
+	}
+	return cmd, nil
+}
+
+// detectPort polls the sockets of the command's process group until one
+// listens, or the run started as pid exits. The lowest port wins when there
+// are several, e.g. a dev server and its live reload socket.
+func (s *Supervisor) detectPort(pid int, exited <-chan struct{}) {
+	ticker := time.NewTicker(portPollInterval)
+	defer ticker.Stop()
+	for {
+		ports, err := listeningPorts(pid)
+		if err != nil {
+			s.mu.Lock()
+			logger := s.logger
+			s.mu.Unlock()
+			logger.Warn("Failed to detect the command's port",
+				logging.Component("supervisor"),
+				logging.Error(err),
+			)
+			s.portOnce.Do(func() {
+				s.mu.Lock()
+				s.portErr = err
+				s.mu.Unlock()
+				close(s.portReady)
+			})
+			return
+		}
+		if len(ports) > 0 {
+			s.setPort(ports)
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-exited:
+			return
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *Supervisor) setPort(ports []int) {
+	s.mu.Lock()
+	previous := s.port
+	s.port = ports[0]
+	logger := s.logger
+	s.mu.Unlock()
+
+	fields := []zap.Field{logging.Component("supervisor"), logging.TargetPort(ports[0])}
+	if len(ports) > 1 {
+		fields = append(fields, zap.Ints("ports", ports))
+	}
+	switch {
+	case previous == 0:
+		logger.Info("Detected the command's port", fields...)
+	case previous != ports[0]:
+		logger.Warn("Command moved to another port", append(fields, zap.Int("previous_port", previous))...)
+	}
+	s.portOnce.Do(func() { close(s.portReady) })
+}
+
+// supervise waits for each run of the command and starts the next one when
+// the policy calls for it.
+func (s *Supervisor) supervise(cmd *exec.Cmd) {
+	defer close(s.done)
+	backoff := minBackoff
+	for {
+		started := time.Now()
+		err := cmd.Wait()
+		cmd.Stdout.(*lineWriter).flush()
+		cmd.Stderr.(*lineWriter).flush()
+		code := cmd.ProcessState.ExitCode()
+		s.mu.Lock()
+		close(s.exited)
+		s.exitCode = code
+		logger := s.logger
+		s.mu.Unlock()
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		fields := []zap.Field{logging.Component("supervisor"), zap.Int("exit_code", code)}
+		if err != nil {
+			fields = append(fields, logging.Error(err))
+		}
+		if !s.restarts(code) {
+			logger.Info("Command exited", fields...)
+			return
+		}
+		if time.Since(started) >= stableAfter {
+			backoff = minBackoff
+		}
+		logger.Warn("Command exited, restarting", append(fields, zap.Duration("delay", backoff))...)
+		select {
+		case <-time.After(backoff):
+		case <-s.stop:
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+
+		if cmd, err = s.start(); err != nil {
+			logger.Error("Failed to restart command", logging.Component("supervisor"), logging.Error(err))
+			return
+		}
+	}
+}
+
+// restarts reports whether the policy restarts a command that exited with
+// code.
+func (s *Supervisor) restarts(code int) bool {
+	switch s.opts.Restart {
+	case config.RestartAlways:
+		return true
+	case config.RestartNever:
+		return false
+	default:
+		return code != 0
+	}
+}
+
+func (s *Supervisor) emit(line Line) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.output != nil {
+		s.output(line)
+		return
+	}
+	s.backlog = append(s.backlog, line)
+	if len(s.backlog) > maxBacklog {
+		s.backlog = s.backlog[1:]
+	}
+}
+
+// lineWriter splits a stream into lines. A trailing partial line is held
+// until it is completed.
+type lineWriter struct {
+	stream  string
+	emit    func(Line)
+	pending []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.send(w.pending[:i])
+		w.pending = w.pending[i+1:]
+	}
+	for len(w.pending) > maxLineLength {
+		w.send(w.pending[:maxLineLength])
+		w.pending = w.pending[maxLineLength:]
+	}
+	return len(p), nil
+}
+
+// flush sends a final line that didn't end with a newline.
+func (w *lineWriter) flush() {
+	if len(w.pending) > 0 {
+		w.send(w.pending)
+		w.pending = nil
+	}
+}
+
+func (w *lineWriter) send(text []byte) {
+	// Progress output redraws with carriage returns; keep the last state.
+	text = bytes.TrimRight(text, "\r")
+	if i := bytes.LastIndexByte(text, '\r'); i >= 0 {
+		text = text[i+1:]
+	}
+	w.emit(Line{Stream: w.stream, Text: string(text), Time: time.Now()})
+}
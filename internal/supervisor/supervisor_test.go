@@ -0,0 +1,170 @@
+//go:build !windows
+
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/config"
+)
+
+// collect gathers the lines a supervisor prints.
+type collect struct {
+	mu    sync.Mutex
+	lines []Line
+}
+
+func (c *collect) add(line Line) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines = append(c.lines, line)
+}
+
+func (c *collect) texts(stream string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var texts []string
+	for _, line := range c.lines {
+		if line.Stream == stream {
+			texts = append(texts, line.Text)
+		}
+	}
+	return texts
+}
+
+func waitDone(t *testing.T, s *Supervisor) {
+	t.Helper()
+	select {
+	case <-s.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("command was not done in time")
+	}
+}
+
+func TestSupervisorSplitsOutputAndReplaysBacklog(t *testing.T) {
+	s, err := Start(Options{
+		Command: []string{"sh", "-c", `printf 'one\ntw'; printf 'o\n' ; echo "port $PORT" >&2; printf 'working...\rdone'`},
+		Env:     []string{"PORT=3000"},
+		Restart: config.RestartNever,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, s)
+
+	// Output printed before SetOutput is replayed.
+	var out collect
+	s.SetOutput(out.add)
+	if got := out.texts(Stdout); !slices.Equal(got, []string{"one", "two", "done"}) {
+		t.Fatalf("unexpected stdout lines %q", got)
+	}
+	if got := out.texts(Stderr); !slices.Equal(got, []string{"port 3000"}) {
+		t.Fatalf("unexpected stderr lines %q", got)
+	}
+	if s.ExitCode() != 0 {
+		t.Fatalf("expected exit code 0, got %d", s.ExitCode())
+	}
+}
+
+func TestSupervisorRestartsOnFailure(t *testing.T) {
+	// The command fails the first time and succeeds the second.
+	marker := filepath.Join(t.TempDir(), "ran")
+	var out collect
+	s, err := Start(Options{
+		Command: []string{"sh", "-c", `if [ -e "$MARKER" ]; then echo second; exit 0; fi; touch "$MARKER"; echo first; exit 3`},
+		Env:     []string{"MARKER=" + marker},
+		Restart: config.RestartOnFailure,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetOutput(out.add)
+	waitDone(t, s)
+
+	if got := out.texts(Stdout); !slices.Equal(got, []string{"first", "second"}) {
+		t.Fatalf("expected one restart, got %q", got)
+	}
+	if s.ExitCode() != 0 {
+		t.Fatalf("expected the final exit code 0, got %d", s.ExitCode())
+	}
+}
+
+func TestSupervisorNeverRestartsKeepsExitCode(t *testing.T) {
+	s, err := Start(Options{Command: []string{"sh", "-c", "exit 7"}, Restart: config.RestartNever})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, s)
+	if s.ExitCode() != 7 {
+		t.Fatalf("expected exit code 7, got %d", s.ExitCode())
+	}
+}
+
+func TestSupervisorStopEndsTheProcessGroup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("checks processes through /proc")
+	}
+	// The shell starts a child that would outlive it without the process
+	// group.
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	s, err := Start(Options{
+		Command: []string{"sh", "-c", `sleep 60 & echo $! > "$PIDFILE"; wait`},
+		Env:     []string{"PIDFILE=" + pidFile},
+		Restart: config.RestartAlways,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pid string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if data, err := os.ReadFile(pidFile); err == nil && strings.HasSuffix(string(data), "\n") {
+			pid = strings.TrimSpace(string(data))
+			break
+		}
+	}
+	if pid == "" {
+		t.Fatal("child did not start")
+	}
+
+	s.Stop()
+	waitDone(t, s)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(filepath.Join("/proc", pid)); os.IsNotExist(err) {
+			return
+		}
+		if data, err := os.ReadFile(filepath.Join("/proc", pid, "stat")); err == nil && strings.Contains(string(data), ") Z ") {
+			return
+		}
+	}
+	t.Fatalf("child %s outlived Stop", pid)
+}
+
+func TestWaitForPortFailsWhenTheCommandExits(t *testing.T) {
+	s, err := Start(Options{Command: []string{"sh", "-c", "exit 0"}, Restart: config.RestartNever, DetectPort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if port, err := s.WaitForPort(ctx); err == nil {
+		t.Fatalf("expected an error for a command that never listened, got port %d", port)
+	}
+	if s.Port() != 0 {
+		t.Fatalf("expected no port, got %d", s.Port())
+	}
+}
+
+func TestStartFailsForMissingCommand(t *testing.T) {
+	if _, err := Start(Options{Command: []string{"portal-no-such-command"}}); err == nil {
+		t.Fatal("expected an error for a missing command")
+	}
+}
@@ -0,0 +1,75 @@
+// internal/tailscale/capabilities.go
+package tailscale
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+)
+
+// Capabilities summarizes what the local node is allowed to do on its tailnet.
+// It is used by the setup and diagnostics commands to explain missing
+// prerequisites before portal tries to configure serve or funnel.
+type Capabilities struct {
+	Version      string
+	BackendState string
+	DNSName      string
+	MagicDNS     bool
+	HTTPS        bool
+	Funnel       bool
+	CertDomains  []string
+}
+
+// Capabilities queries the local daemon and reports the node's tailnet
+// capabilities.
+func (c *Client) Capabilities(ctx context.Context) (*Capabilities, error) {
+	status, err := c.lc.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tailscale status: %w", err)
+	}
+	return capabilitiesFromStatus(status), nil
+}
+
+func capabilitiesFromStatus(status *ipnstate.Status) *Capabilities {
+	caps := &Capabilities{
+		Version:      status.Version,
+		BackendState: status.BackendState,
+		CertDomains:  status.CertDomains,
+	}
+	if status.CurrentTailnet != nil {
+		caps.MagicDNS = status.CurrentTailnet.MagicDNSEnabled
+	}
+	if status.Self != nil {
+		caps.DNSName = strings.TrimSuffix(status.Self.DNSName, ".")
+		caps.HTTPS = status.Self.HasCap(tailcfg.CapabilityHTTPS)
+		caps.Funnel = status.Self.HasCap(tailcfg.NodeAttrFunnel)
+	}
+	return caps
+}
+
+// ServePortsInUse returns the tailnet ports that already have a serve or
+// funnel handler in the node's current serve config, in ascending order.
+func (c *Client) ServePortsInUse(ctx context.Context) ([]uint16, error) {
+	sc, err := c.lc.GetServeConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get serve config: %w", err)
+	}
+	return servePortsInUse(sc), nil
+}
+
+func servePortsInUse(sc *ipn.ServeConfig) []uint16 {
+	if sc == nil {
+		return nil
+	}
+	ports := make([]uint16, 0, len(sc.TCP))
+	for port := range sc.TCP {
+		ports = append(ports, port)
+	}
+	slices.Sort(ports)
+	return ports
+}
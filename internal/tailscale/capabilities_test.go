@@ -0,0 +1,51 @@
+package tailscale
+
+import (
+	"testing"
+
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+)
+
+func TestCapabilitiesFromStatus(t *testing.T) {
+	status := &ipnstate.Status{
+		Version:        "1.94.1",
+		CertDomains:    []string{"dev.example.ts.net"},
+		CurrentTailnet: &ipnstate.TailnetStatus{MagicDNSEnabled: true},
+		Self: &ipnstate.PeerStatus{
+			DNSName: "dev.example.ts.net.",
+			CapMap: tailcfg.NodeCapMap{
+				tailcfg.CapabilityHTTPS: nil,
+			},
+		},
+	}
+
+	caps := capabilitiesFromStatus(status)
+	if caps.DNSName != "dev.example.ts.net" {
+		t.Fatalf("expected trimmed DNS name, got %q", caps.DNSName)
+	}
+	if !caps.MagicDNS || !caps.HTTPS {
+		t.Fatalf("expected MagicDNS and HTTPS, got %+v", caps)
+	}
+	if caps.Funnel {
+		t.Fatalf("expected funnel to be unavailable without node attribute")
+	}
+}
+
+func TestServePortsInUse(t *testing.T) {
+	sc := &ipn.ServeConfig{
+		TCP: map[uint16]*ipn.TCPPortHandler{
+			8443: {HTTPS: true},
+			443:  {HTTPS: true},
+		},
+	}
+
+	ports := servePortsInUse(sc)
+	if len(ports) != 2 || ports[0] != 443 || ports[1] != 8443 {
+		t.Fatalf("expected sorted ports [443 8443], got %v", ports)
+	}
+	if got := servePortsInUse(nil); got != nil {
+		t.Fatalf("expected nil for missing serve config, got %v", got)
+	}
+}
@@ -0,0 +1,47 @@
+// internal/tailscale/cert.go
+package tailscale
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+)
+
+// WarmCert fetches the HTTPS certificate for domain now, rather than when
+// tailscaled first needs it for a visitor's TLS handshake, which can take
+// many seconds.
+func (c *Client) WarmCert(ctx context.Context, domain string) error {
+	return warmCert(ctx, c.logger, c.lc.CertPair, domain)
+}
+
+// WarmCert fetches the tsnet node's HTTPS certificate for domain before the
+// first visitor's TLS handshake needs it.
+func (ts *TSNetServer) WarmCert(ctx context.Context, domain string) error {
+	return warmCert(ctx, ts.logger, ts.server.CertPair, domain)
+}
+
+func warmCert(ctx context.Context, logger *zap.Logger, certPair func(context.Context, string) ([]byte, []byte, error), domain string) error {
+	logger.Info("Provisioning HTTPS certificate",
+		logging.Component("tailscale_https"),
+		zap.String("dns_name", domain),
+	)
+	start := time.Now()
+	if _, _, err := certPair(ctx, domain); err != nil {
+		logger.Warn("Failed to provision HTTPS certificate; the first HTTPS request may be slow",
+			logging.Component("tailscale_https"),
+			zap.String("dns_name", domain),
+			logging.Error(err),
+		)
+		return fmt.Errorf("failed to get certificate for %s: %w", domain, err)
+	}
+	logger.Info("HTTPS certificate ready",
+		logging.Component("tailscale_https"),
+		zap.String("dns_name", domain),
+		logging.Duration(time.Since(start)),
+	)
+	return nil
+}
@@ -0,0 +1,41 @@
+package tailscale
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/testsupport"
+)
+
+func TestWarmCertFetchesTheCertificate(t *testing.T) {
+	ctx := context.Background()
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	client := NewClientWithLocal(daemon, zap.NewNop())
+
+	if err := client.WarmCert(ctx, "dev-box.example.ts.net"); err == nil {
+		t.Fatal("expected an error without HTTPS enabled")
+	}
+	daemon.GrantHTTPS()
+	if err := client.WarmCert(ctx, "dev-box.example.ts.net"); err != nil {
+		t.Fatalf("WarmCert: %v", err)
+	}
+	if got := daemon.CertRequests(); !slices.Equal(got, []string{"dev-box.example.ts.net", "dev-box.example.ts.net"}) {
+		t.Fatalf("unexpected certificate requests %q", got)
+	}
+}
+
+func TestTSNetWarmCertUsesTheNode(t *testing.T) {
+	node := testsupport.NewFakeNode("portal.example.ts.net")
+	node.GrantHTTPS()
+	server := NewTSNetServerWithNode(TSNetConfig{Hostname: "portal", UseHTTPS: true}, node, zap.NewNop())
+
+	if err := server.WarmCert(context.Background(), "portal.example.ts.net"); err != nil {
+		t.Fatalf("WarmCert: %v", err)
+	}
+	if got := node.CertRequests(); !slices.Equal(got, []string{"portal.example.ts.net"}) {
+		t.Fatalf("unexpected certificate requests %q", got)
+	}
+}
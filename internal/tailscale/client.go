@@ -3,10 +3,11 @@ package tailscale
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand/v2"
 	"net"
 	"slices"
+	"strconv"
 	"strings"
 
 	"go.uber.org/zap"
@@ -28,6 +29,42 @@ type Config struct {
 	ProxyPort           int
 	ListenMode          string
 	ServiceName         string
+	// FunnelPaths exposes only these path prefixes through Funnel on port
+	// 443; the main serve port stays tailnet-only.
+	FunnelPaths []string
+	// Namespace is set when MountPath is this session's namespace on a node
+	// shared with other developers. Their namespaces may serve other paths
+	// on the same port, so only this session's handlers are replaced or
+	// removed.
+	Namespace string
+	// UIPath is where this session's web UI is mounted on the serve port,
+	// if it is. SetupServe doesn't count it as the port being in use.
+	UIPath string
+}
+
+// servePortAndTLS returns the serve port config asks for and whether it
+// uses TLS.
+func servePortAndTLS(config Config) (uint16, bool) {
+	if config.ServePort != 0 {
+		return uint16(config.ServePort), config.UseHTTPS || config.ServePort == 443
+	}
+	if config.UseHTTPS {
+		return 443, true
+	}
+	return 80, false
+}
+
+// sharedPortConflict reports whether another session's use of port rules
+// out adding a namespace to it: a raw TCP forward, or web handlers speaking
+// the other protocol.
+func sharedPortConflict(sc *ipn.ServeConfig, port uint16, useTLS bool) bool {
+	if sc.IsTCPForwardingOnPort(port, "") {
+		return true
+	}
+	if useTLS {
+		return sc.IsServingHTTP(port, "")
+	}
+	return sc.IsServingHTTPS(port, "")
 }
 
 // ServiceInfo holds information about the configured service
@@ -40,12 +77,15 @@ type ServiceInfo struct {
 	IsFunnel  bool   // Whether funnel is enabled (internet accessible)
 	IsHTTPS   bool   // Whether HTTPS is enabled
 	MountPath string // Mount path for the service
+	// FunnelURLs are the public URLs for --funnel-path prefixes.
+	FunnelURLs []string
 }
 
 // Client wraps the Tailscale local client with additional functionality
 type Client struct {
-	lc     *local.Client
+	lc     LocalClient
 	logger *zap.Logger
+	ports  *PortMemory
 }
 
 const (
@@ -58,12 +98,27 @@ const (
 
 // NewClient creates a new Tailscale client with structured logging
 func NewClient(logger *zap.Logger) *Client {
+	return NewClientWithLocal(&local.Client{}, logger)
+}
+
+// NewClientWithLocal creates a client that talks to tailscaled through lc.
+func NewClientWithLocal(lc LocalClient, logger *zap.Logger) *Client {
 	return &Client{
-		lc:     &local.Client{},
+		lc:     lc,
 		logger: logger,
 	}
 }
 
+// SetPortMemory makes the client reuse the ports remembered in ports.
+func (c *Client) SetPortMemory(ports *PortMemory) {
+	c.ports = ports
+}
+
+// Ports returns the client's port memory, which may be nil.
+func (c *Client) Ports() *PortMemory {
+	return c.ports
+}
+
 // IsAvailable checks if local Tailscale is available
 func (c *Client) IsAvailable(ctx context.Context) bool {
 	c.logger.Info(logging.MsgTailscaleAvailability,
@@ -176,21 +231,7 @@ func (c *Client) SetupServe(ctx context.Context, config Config) (*ServiceInfo, e
 	}
 
 	// Determine serve port and TLS usage
-	var srvPort uint16
-	var useTLS bool
-
-	if config.ServePort != 0 {
-		srvPort = uint16(config.ServePort)
-		useTLS = config.UseHTTPS || config.ServePort == 443
-	} else {
-		if config.UseHTTPS {
-			srvPort = 443
-			useTLS = true
-		} else {
-			srvPort = 80
-			useTLS = false
-		}
-	}
+	srvPort, useTLS := servePortAndTLS(config)
 
 	c.logger.Info("Tailscale serve configuration",
 		logging.Component("tailscale_serve"),
@@ -223,10 +264,27 @@ func (c *Client) SetupServe(ctx context.Context, config Config) (*ServiceInfo, e
 	}
 
 	// Check if port is already in use in node-level or service-level config.
-	portInUse := sc.IsTCPForwardingOnPort(srvPort, "") || sc.IsServingWeb(srvPort, "")
+	// The web UI may have been mounted on the port first.
+	hp := ipn.HostPort(net.JoinHostPort(dnsName, strconv.Itoa(int(srvPort))))
+	checked := sc
+	if config.UIPath != "" && sc.Web[hp] != nil {
+		checked = sc.Clone()
+		checked.RemoveWebHandler(dnsName, srvPort, []string{config.UIPath}, false)
+	}
+	portInUse := checked.IsTCPForwardingOnPort(srvPort, "") || checked.IsServingWeb(srvPort, "")
 	if serviceNameTag != "" {
 		portInUse = portInUse || sc.IsTCPForwardingOnPort(srvPort, serviceNameTag) || sc.IsServingWeb(srvPort, serviceNameTag)
 	}
+	if config.Namespace != "" {
+		portInUse = sharedPortConflict(sc, srvPort, useTLS)
+		if !portInUse && sc.WebHandlerExists("", hp, mountPath) {
+			c.logger.Warn("Replacing the existing handler for this namespace",
+				logging.Component("tailscale_serve"),
+				logging.ServePort(int(srvPort)),
+				logging.MountPath(mountPath),
+			)
+		}
+	}
 	if portInUse {
 		c.logger.Error("Port already in use for serve",
 			logging.Component("tailscale_serve"),
@@ -278,36 +336,44 @@ func (c *Client) SetupServe(ctx context.Context, config Config) (*ServiceInfo, e
 			return nil, fmt.Errorf("funnel requires HTTPS on port 443")
 		}
 
-		// Enable HTTPS feature first if needed
-		if err := c.enableHTTPSFeature(ctx); err != nil {
-			c.logger.Error("Failed to enable HTTPS feature for funnel",
-				logging.Component("tailscale_serve"),
-				logging.Error(err),
-				logging.Status("funnel_setup_failed"),
-			)
-			return nil, fmt.Errorf("HTTPS certificates not enabled: %w", err)
+		if err := c.prepareFunnel(ctx, dnsName); err != nil {
+			return nil, err
 		}
 
-		// Check certificate status before enabling funnel
-		c.logger.Info("Checking HTTPS certificate status for funnel",
+		sc.SetFunnel(dnsName, srvPort, true)
+		c.logger.Info("Funnel enabled successfully",
 			logging.Component("tailscale_serve"),
-			zap.String("dns_name", dnsName),
+			logging.Status("internet_accessible"),
 		)
+	}
 
-		if err := c.checkTailscaleCertificates(ctx, dnsName); err != nil {
-			c.logger.Error("Certificate check failed for funnel",
-				logging.Component("tailscale_serve"),
-				zap.String("dns_name", dnsName),
-				logging.Error(err),
-				logging.Status("funnel_cert_check_failed"),
-			)
-			return nil, fmt.Errorf("cannot enable funnel: %w", err)
+	var funnelURLs []string
+	if len(config.FunnelPaths) > 0 {
+		if listenMode == TSNetListenModeService || config.EnableFunnel {
+			return nil, fmt.Errorf("funnel paths cannot be combined with service mode or full funnel")
 		}
-
-		sc.SetFunnel(dnsName, srvPort, true)
-		c.logger.Info("Funnel enabled successfully",
+		if srvPort == funnelPort {
+			return nil, fmt.Errorf("funnel paths use port %d, so the private serve port must be different", funnelPort)
+		}
+		if sc.IsTCPForwardingOnPort(funnelPort, "") || sc.IsServingWeb(funnelPort, "") {
+			return nil, fmt.Errorf("port %d is already in use by tailscale serve", funnelPort)
+		}
+		if err := c.prepareFunnel(ctx, dnsName); err != nil {
+			return nil, err
+		}
+		for _, funnelPath := range config.FunnelPaths {
+			// Proxy to the same path so tailscaled's mount-point stripping
+			// leaves the request path unchanged.
+			sc.SetWebHandler(&ipn.HTTPHandler{
+				Proxy: fmt.Sprintf("http://localhost:%d%s", config.ProxyPort, funnelPath),
+			}, dnsName, funnelPort, funnelPath, true, "")
+			funnelURLs = append(funnelURLs, fmt.Sprintf("https://%s%s", dnsName, funnelPath))
+		}
+		sc.SetFunnel(dnsName, funnelPort, true)
+		c.logger.Info("Funnel enabled for selected paths",
 			logging.Component("tailscale_serve"),
-			logging.Status("internet_accessible"),
+			zap.Strings("funnel_urls", funnelURLs),
+			logging.Status("internet_accessible_paths"),
 		)
 	}
 
@@ -363,19 +429,59 @@ func (c *Client) SetupServe(ctx context.Context, config Config) (*ServiceInfo, e
 
 	// Create ServiceInfo to return
 	serviceInfo := &ServiceInfo{
-		URL:       url,
-		LocalURL:  fmt.Sprintf("http://localhost:%d", config.ProxyPort),
-		DNSName:   dnsName,
-		ServePort: int(srvPort),
-		ProxyPort: config.ProxyPort,
-		IsFunnel:  config.EnableFunnel,
-		IsHTTPS:   useTLS,
-		MountPath: mountPath,
+		URL:        url,
+		LocalURL:   fmt.Sprintf("http://localhost:%d", config.ProxyPort),
+		DNSName:    dnsName,
+		ServePort:  int(srvPort),
+		ProxyPort:  config.ProxyPort,
+		IsFunnel:   config.EnableFunnel,
+		IsHTTPS:    useTLS,
+		MountPath:  mountPath,
+		FunnelURLs: funnelURLs,
 	}
 
 	return serviceInfo, nil
 }
 
+// funnelPort is the only port Funnel paths are exposed on.
+const funnelPort uint16 = 443
+
+// ErrFunnelNotPermitted is wrapped by errors from enabling Funnel on a node
+// the tailnet doesn't allow it for, e.g. without HTTPS certificates or the
+// funnel node attribute.
+var ErrFunnelNotPermitted = errors.New("funnel is not permitted for this machine")
+
+// prepareFunnel checks that HTTPS certificates are available for dnsName,
+// which Funnel requires.
+func (c *Client) prepareFunnel(ctx context.Context, dnsName string) error {
+	// Enable HTTPS feature first if needed
+	if err := c.enableHTTPSFeature(ctx); err != nil {
+		c.logger.Error("Failed to enable HTTPS feature for funnel",
+			logging.Component("tailscale_serve"),
+			logging.Error(err),
+			logging.Status("funnel_setup_failed"),
+		)
+		return fmt.Errorf("%w: HTTPS certificates not enabled: %w", ErrFunnelNotPermitted, err)
+	}
+
+	// Check certificate status before enabling funnel
+	c.logger.Info("Checking HTTPS certificate status for funnel",
+		logging.Component("tailscale_serve"),
+		zap.String("dns_name", dnsName),
+	)
+
+	if err := c.checkTailscaleCertificates(ctx, dnsName); err != nil {
+		c.logger.Error("Certificate check failed for funnel",
+			logging.Component("tailscale_serve"),
+			zap.String("dns_name", dnsName),
+			logging.Error(err),
+			logging.Status("funnel_cert_check_failed"),
+		)
+		return fmt.Errorf("%w: cannot enable funnel: %w", ErrFunnelNotPermitted, err)
+	}
+	return nil
+}
+
 func normalizeServeListenMode(mode string) string {
 	mode = strings.ToLower(strings.TrimSpace(mode))
 	if mode == "" {
@@ -467,7 +573,6 @@ func (c *Client) SetupUIServe(ctx context.Context, uiPort int) (uint16, string,
 		return 0, "", err
 	}
 
-	// Find available Tailscale port starting from a random port
 	tailscalePort, err := c.findAvailableTailscalePort(sc, 8080)
 	if err != nil {
 		c.logger.Error("Failed to find available Tailscale port for UI",
@@ -511,6 +616,92 @@ func (c *Client) SetupUIServe(ctx context.Context, uiPort int) (uint16, string,
 	return tailscalePort, uiURL, nil
 }
 
+// SetupMountedUIServe serves the web UI under mountPath on the service's
+// serve port, rather than on a port of its own, so it sits next to the
+// service and no other port needs exposing.
+func (c *Client) SetupMountedUIServe(ctx context.Context, uiPort int, config Config, mountPath string) (uint16, string, error) {
+	c.logger.Info("Setting up Tailscale UI serve",
+		logging.Component("tailscale_ui_serve"),
+		logging.UIPort(uiPort),
+		logging.MountPath(mountPath),
+	)
+
+	sc, err := c.lc.GetServeConfig(ctx)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get serve config: %w", err)
+	}
+	if sc == nil {
+		sc = new(ipn.ServeConfig)
+	}
+	dnsName, err := c.GetDNSName(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+
+	port, useTLS := servePortAndTLS(config)
+	if sharedPortConflict(sc, port, useTLS) {
+		return 0, "", fmt.Errorf("port %d is already in use by tailscale serve", port)
+	}
+	// tailscaled strips the mount point, so proxying to /ui gives the UI the
+	// same paths it has on a port of its own.
+	sc.SetWebHandler(&ipn.HTTPHandler{
+		Proxy: fmt.Sprintf("http://localhost:%d/ui", uiPort),
+	}, dnsName, port, mountPath, useTLS, "")
+	if err := c.lc.SetServeConfig(ctx, sc); err != nil {
+		return 0, "", fmt.Errorf("failed to set UI serve config: %w", err)
+	}
+
+	uiURL := serveURL(dnsName, port, useTLS) + mountPath + "/"
+	c.logger.Info("Web UI serve configured successfully",
+		logging.Component("tailscale_ui_serve"),
+		logging.URL(uiURL),
+		logging.TailscalePort(int(port)),
+		logging.Status("tailnet_accessible"),
+	)
+	return port, uiURL, nil
+}
+
+// RemoveMounts removes only the given mount paths from the serve port in
+// config, leaving other sessions' handlers in place.
+func (c *Client) RemoveMounts(ctx context.Context, config Config, mountPaths ...string) error {
+	sc, err := c.lc.GetServeConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get serve config: %w", err)
+	}
+	dnsName, err := c.GetDNSName(ctx)
+	if err != nil {
+		return err
+	}
+	port, _ := servePortAndTLS(config)
+	hp := ipn.HostPort(net.JoinHostPort(dnsName, strconv.Itoa(int(port))))
+	if sc == nil || sc.Web[hp] == nil {
+		return nil
+	}
+
+	c.logger.Info("Removing this session's Tailscale serve handlers",
+		logging.Component("tailscale_serve"),
+		logging.ServePort(int(port)),
+		zap.Strings("mount_paths", mountPaths),
+	)
+	sc.RemoveWebHandler(dnsName, port, mountPaths, false)
+	if err := c.lc.SetServeConfig(ctx, sc); err != nil {
+		return fmt.Errorf("failed to set serve config: %w", err)
+	}
+	return nil
+}
+
+// serveURL is the base URL of a serve port, leaving out default ports.
+func serveURL(dnsName string, port uint16, useTLS bool) string {
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	if (scheme == "http" && port == 80) || (scheme == "https" && port == 443) {
+		return fmt.Sprintf("%s://%s", scheme, dnsName)
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, dnsName, port)
+}
+
 // Cleanup removes Tailscale serve configuration
 func (c *Client) Cleanup(ctx context.Context, config Config) error {
 	c.logger.Info(logging.MsgCleanupStarting,
@@ -690,64 +881,62 @@ func (c *Client) checkTailscaleCertificates(ctx context.Context, dnsName string)
 	return nil
 }
 
-// findAvailableTailscalePort finds an available port for Tailscale serve
+// findAvailableTailscalePort finds a port for the UI's Tailscale serve: the
+// one remembered for the project when nothing else serves on it, otherwise
+// the first free one from startPort.
 func (c *Client) findAvailableTailscalePort(sc *ipn.ServeConfig, startPort uint16) (uint16, error) {
-	// Use a random starting port to avoid conflicts
-	randomOffset := rand.IntN(100) // Random offset 0-99
-	actualStartPort := startPort + uint16(randomOffset)
+	inUse := func(port uint16) bool {
+		if sc.IsTCPForwardingOnPort(port, "") {
+			return true
+		}
+		for hp := range sc.Web {
+			if _, p, err := net.SplitHostPort(string(hp)); err == nil && p == strconv.Itoa(int(port)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if preferred := c.ports.Preferred(PortUIServe); preferred > 0 && preferred <= 65535 && !inUse(uint16(preferred)) {
+		c.logger.Debug("Reusing the remembered Tailscale port",
+			logging.Component("tailscale_port_allocation"),
+			logging.Port(preferred),
+		)
+		return uint16(preferred), nil
+	}
 
 	c.logger.Debug("Finding available Tailscale port",
 		logging.Component("tailscale_port_allocation"),
-		logging.StartPort(int(actualStartPort)),
+		logging.StartPort(int(startPort)),
 	)
-
-	for port := actualStartPort; port < actualStartPort+200; port++ {
-		if !sc.IsTCPForwardingOnPort(port, "") {
-			// Also check if web handlers exist on this port
-			available := true
-			if sc.Web != nil {
-				for hostPort := range sc.Web {
-					if strings.Contains(string(hostPort), fmt.Sprintf(":%d", port)) {
-						available = false
-						break
-					}
-				}
-			}
-			if available {
-				c.logger.Debug("Found available Tailscale port",
-					logging.Component("tailscale_port_allocation"),
-					logging.Port(int(port)),
-				)
-				return port, nil
-			}
+	for port := startPort; port < startPort+200; port++ {
+		if inUse(port) {
+			continue
 		}
+		c.logger.Debug("Found available Tailscale port",
+			logging.Component("tailscale_port_allocation"),
+			logging.Port(int(port)),
+		)
+		// Failing to remember only costs the next session its stable port.
+		_ = c.ports.Remember(PortUIServe, int(port))
+		return port, nil
 	}
 
 	c.logger.Error("No available Tailscale port found",
 		logging.Component("tailscale_port_allocation"),
-		logging.StartPort(int(actualStartPort)),
+		logging.StartPort(int(startPort)),
 	)
-	return 0, fmt.Errorf("no available Tailscale port found starting from %d", actualStartPort)
+	return 0, fmt.Errorf("no available Tailscale port found starting from %d", startPort)
 }
 
-// FindAvailableLocalPort finds an available local port starting from a random port
+// FindAvailableLocalPort returns a free local port assigned by the OS.
 func FindAvailableLocalPort() (int, error) {
-	// Use a random starting port in the ephemeral port range (49152-65535)
-	startPort := 49152 + rand.IntN(10000) // Random port between 49152 and 59151
-
-	for port := startPort; port < startPort+1000; port++ {
-		if port > 65535 {
-			// Wrap around if we exceed the port range
-			port = 49152 + (port - 65535)
-		}
-
-		ln, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
-		if err == nil {
-			ln.Close()
-			return port, nil
-		}
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, fmt.Errorf("no available local port: %w", err)
 	}
-	return 0, fmt.Errorf("no available port found starting from random port %d", startPort)
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
 }
 
 // FindAvailableLocalPortFrom finds an available local port starting from the
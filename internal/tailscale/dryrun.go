@@ -0,0 +1,229 @@
+// internal/tailscale/dryrun.go
+package tailscale
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"tailscale.com/ipn"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// DryRun is a LocalClient that reads from tailscaled but keeps the serve
+// config and prefs changes made through it to itself, so a Client using it
+// can plan a session without touching the node. Changes lists what would
+// have been applied.
+type DryRun struct {
+	LocalClient
+
+	mu        sync.Mutex
+	before    *ipn.ServeConfig
+	serve     *ipn.ServeConfig
+	advertise []string
+}
+
+// NewDryRun returns a dry run over lc.
+func NewDryRun(lc LocalClient) *DryRun {
+	return &DryRun{LocalClient: lc}
+}
+
+// DryRun returns a client that makes its changes to a dry run of c's
+// LocalClient rather than to tailscaled, and that dry run.
+func (c *Client) DryRun() (*Client, *DryRun) {
+	dryRun := NewDryRun(c.lc)
+	client := NewClientWithLocal(dryRun, c.logger)
+	client.ports = c.ports.dryRun()
+	return client, dryRun
+}
+
+// GetServeConfig returns the config as changed so far, reading the node's
+// the first time.
+func (d *DryRun) GetServeConfig(ctx context.Context) (*ipn.ServeConfig, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.load(ctx); err != nil {
+		return nil, err
+	}
+	return d.serve.Clone(), nil
+}
+
+// SetServeConfig records config instead of applying it.
+func (d *DryRun) SetServeConfig(ctx context.Context, config *ipn.ServeConfig) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.load(ctx); err != nil {
+		return err
+	}
+	d.serve = config.Clone()
+	if d.serve == nil {
+		d.serve = new(ipn.ServeConfig)
+	}
+	return nil
+}
+
+// GetPrefs returns the node's prefs with the recorded edits applied.
+func (d *DryRun) GetPrefs(ctx context.Context) (*ipn.Prefs, error) {
+	prefs, err := d.LocalClient.GetPrefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.advertise != nil {
+		prefs.AdvertiseServices = slices.Clone(d.advertise)
+	}
+	return prefs, nil
+}
+
+// EditPrefs records the advertised services instead of applying them; they
+// are the only prefs portal edits.
+func (d *DryRun) EditPrefs(ctx context.Context, prefs *ipn.MaskedPrefs) (*ipn.Prefs, error) {
+	if prefs.AdvertiseServicesSet {
+		d.mu.Lock()
+		d.advertise = append([]string{}, prefs.AdvertiseServices...)
+		d.mu.Unlock()
+	}
+	return d.GetPrefs(ctx)
+}
+
+func (d *DryRun) load(ctx context.Context) error {
+	if d.before != nil {
+		return nil
+	}
+	sc, err := d.LocalClient.GetServeConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if sc == nil {
+		sc = new(ipn.ServeConfig)
+	}
+	d.before, d.serve = sc, sc.Clone()
+	return nil
+}
+
+// Changes lists the recorded changes against the node's config, in target
+// order.
+func (d *DryRun) Changes(ctx context.Context) ([]model.ServeChange, error) {
+	d.mu.Lock()
+	if err := d.load(ctx); err != nil {
+		d.mu.Unlock()
+		return nil, err
+	}
+	before, after := serveEntries(d.before), serveEntries(d.serve)
+	advertise := d.advertise
+	d.mu.Unlock()
+
+	var changes []model.ServeChange
+	for key, handler := range after {
+		previous, ok := before[key]
+		switch {
+		case !ok:
+			changes = append(changes, newServeChange(model.ServeChangeAdd, key, handler))
+		case previous != handler:
+			change := newServeChange(model.ServeChangeReplace, key, handler)
+			change.Previous = previous
+			changes = append(changes, change)
+		}
+	}
+	for key, handler := range before {
+		if _, ok := after[key]; !ok {
+			changes = append(changes, newServeChange(model.ServeChangeRemove, key, handler))
+		}
+	}
+	slices.SortFunc(changes, func(a, b model.ServeChange) int {
+		return cmp.Or(strings.Compare(a.Target, b.Target), strings.Compare(a.Kind, b.Kind))
+	})
+
+	if advertise != nil {
+		prefs, err := d.LocalClient.GetPrefs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range advertise {
+			if !slices.Contains(prefs.AdvertiseServices, name) {
+				changes = append(changes, model.ServeChange{Action: model.ServeChangeAdd, Kind: "advertise", Target: name})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// serveEntryKey is where a serve config entry applies.
+type serveEntryKey struct {
+	kind   string
+	target string
+}
+
+func newServeChange(action string, key serveEntryKey, handler string) model.ServeChange {
+	return model.ServeChange{Action: action, Kind: key.kind, Target: key.target, Handler: handler}
+}
+
+// serveEntries flattens sc into what serves each port, mount and funnel
+// setting, including those of services.
+func serveEntries(sc *ipn.ServeConfig) map[serveEntryKey]string {
+	entries := make(map[serveEntryKey]string)
+	addServeEntries(entries, "", sc.TCP, sc.Web)
+	for hp, on := range sc.AllowFunnel {
+		if on {
+			entries[serveEntryKey{"funnel", string(hp)}] = "on"
+		}
+	}
+	for name, svc := range sc.Services {
+		if svc != nil {
+			addServeEntries(entries, string(name)+" ", svc.TCP, svc.Web)
+		}
+	}
+	return entries
+}
+
+func addServeEntries(entries map[serveEntryKey]string, prefix string, tcp map[uint16]*ipn.TCPPortHandler, web map[ipn.HostPort]*ipn.WebServerConfig) {
+	for port, h := range tcp {
+		if h != nil {
+			entries[serveEntryKey{"tcp", prefix + strconv.Itoa(int(port))}] = describeTCPHandler(h)
+		}
+	}
+	for hp, server := range web {
+		if server == nil {
+			continue
+		}
+		for mount, h := range server.Handlers {
+			if h != nil {
+				entries[serveEntryKey{"web", prefix + string(hp) + mount}] = describeHTTPHandler(h)
+			}
+		}
+	}
+}
+
+func describeTCPHandler(h *ipn.TCPPortHandler) string {
+	switch {
+	case h.TCPForward != "" && h.TerminateTLS != "":
+		return fmt.Sprintf("forward to %s, terminating TLS for %s", h.TCPForward, h.TerminateTLS)
+	case h.TCPForward != "":
+		return "forward to " + h.TCPForward
+	case h.HTTPS:
+		return "https"
+	case h.HTTP:
+		return "http"
+	}
+	return "tcp"
+}
+
+func describeHTTPHandler(h *ipn.HTTPHandler) string {
+	switch {
+	case h.Proxy != "":
+		return "proxy to " + h.Proxy
+	case h.Path != "":
+		return "files at " + h.Path
+	case h.Redirect != "":
+		return "redirect to " + h.Redirect
+	case h.Text != "":
+		return "text"
+	}
+	return "handler"
+}
@@ -0,0 +1,48 @@
+package tailscale
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+	"tailscale.com/ipn"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/testsupport"
+)
+
+func TestDryRunReportsChangesWithoutApplying(t *testing.T) {
+	ctx := context.Background()
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	existing := new(ipn.ServeConfig)
+	existing.SetWebHandler(&ipn.HTTPHandler{Proxy: "http://127.0.0.1:9000"}, "dev-box.example.ts.net", 8443, "/grafana", true, "")
+	if err := daemon.SetServeConfig(ctx, existing); err != nil {
+		t.Fatal(err)
+	}
+	calls := daemon.SetServeCalls()
+
+	client, dryRun := NewClientWithLocal(daemon, zap.NewNop()).DryRun()
+	info, err := client.SetupServe(ctx, Config{ProxyPort: 51234, MountPath: "/api"})
+	if err != nil {
+		t.Fatalf("SetupServe: %v", err)
+	}
+	if info.URL != "http://dev-box.example.ts.net/api" {
+		t.Fatalf("unexpected URL %q", info.URL)
+	}
+	if daemon.SetServeCalls() != calls {
+		t.Fatal("expected the dry run to leave tailscaled alone")
+	}
+
+	changes, err := dryRun.Changes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.ServeChange{
+		{Action: model.ServeChangeAdd, Kind: "tcp", Target: "80", Handler: "http"},
+		{Action: model.ServeChangeAdd, Kind: "web", Target: "dev-box.example.ts.net:80/api", Handler: "proxy to http://localhost:51234"},
+	}
+	if !slices.Equal(changes, want) {
+		t.Fatalf("expected %+v, got %+v", want, changes)
+	}
+}
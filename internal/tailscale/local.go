@@ -0,0 +1,66 @@
+// internal/tailscale/local.go
+package tailscale
+
+import (
+	"context"
+	"net"
+
+	"tailscale.com/client/local"
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tsnet"
+)
+
+// LocalClient is the part of the tailscaled LocalAPI that Client uses.
+// *local.Client implements it; tests substitute testsupport.FakeLocalClient
+// so serve and funnel setup can run without a tailnet.
+type LocalClient interface {
+	Status(ctx context.Context) (*ipnstate.Status, error)
+	GetServeConfig(ctx context.Context) (*ipn.ServeConfig, error)
+	SetServeConfig(ctx context.Context, config *ipn.ServeConfig) error
+	GetPrefs(ctx context.Context) (*ipn.Prefs, error)
+	EditPrefs(ctx context.Context, prefs *ipn.MaskedPrefs) (*ipn.Prefs, error)
+	UserDial(ctx context.Context, network, host string, port uint16) (net.Conn, error)
+	CertPair(ctx context.Context, domain string) (certPEM, keyPEM []byte, err error)
+}
+
+var _ LocalClient = (*local.Client)(nil)
+
+// Node is the part of a tsnet node that TSNetServer uses. NewTSNetServer
+// wraps a *tsnet.Server; tests substitute testsupport.FakeNode.
+type Node interface {
+	Up(ctx context.Context) (*ipnstate.Status, error)
+	Status(ctx context.Context) (*ipnstate.Status, error)
+	Listen(network, addr string) (net.Listener, error)
+	ListenTLS(network, addr string) (net.Listener, error)
+	ListenFunnel(network, addr string) (net.Listener, error)
+	ListenService(name string, mode tsnet.ServiceMode) (*tsnet.ServiceListener, error)
+	Dial(ctx context.Context, network, addr string) (net.Conn, error)
+	CertPair(ctx context.Context, domain string) (certPEM, keyPEM []byte, err error)
+	Close() error
+}
+
+// tsnetNode adapts *tsnet.Server to Node.
+type tsnetNode struct {
+	*tsnet.Server
+}
+
+func (n tsnetNode) Status(ctx context.Context) (*ipnstate.Status, error) {
+	lc, err := n.LocalClient()
+	if err != nil {
+		return nil, err
+	}
+	return lc.Status(ctx)
+}
+
+func (n tsnetNode) CertPair(ctx context.Context, domain string) ([]byte, []byte, error) {
+	lc, err := n.LocalClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	return lc.CertPair(ctx, domain)
+}
+
+func (n tsnetNode) ListenFunnel(network, addr string) (net.Listener, error) {
+	return n.Server.ListenFunnel(network, addr)
+}
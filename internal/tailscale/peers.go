@@ -0,0 +1,87 @@
+// internal/tailscale/peers.go
+package tailscale
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"tailscale.com/ipn/ipnstate"
+)
+
+// DialPeer connects to addr ("host:port") on another tailnet machine through
+// the local daemon. host may be a machine name, a MagicDNS name or a
+// Tailscale IP.
+func (c *Client) DialPeer(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := splitPeerAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	status, err := c.lc.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tailscale status: %w", err)
+	}
+	ip, err := resolvePeer(status, host)
+	if err != nil {
+		return nil, err
+	}
+	return c.lc.UserDial(ctx, network, ip.String(), port)
+}
+
+// DialPeer connects to addr ("host:port") on another tailnet machine through
+// the tsnet node.
+func (ts *TSNetServer) DialPeer(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := splitPeerAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	status, err := ts.server.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tailscale status: %w", err)
+	}
+	ip, err := resolvePeer(status, host)
+	if err != nil {
+		return nil, err
+	}
+	return ts.server.Dial(ctx, network, net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+}
+
+func splitPeerAddr(addr string) (string, uint16, error) {
+	host, portText, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid peer address %q: %w", addr, err)
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil || port == 0 {
+		return "", 0, fmt.Errorf("invalid peer port in %q", addr)
+	}
+	return host, uint16(port), nil
+}
+
+// resolvePeer finds the Tailscale address of the peer named host. Machine
+// names and the first label of MagicDNS names are matched case-insensitively;
+// Tailscale IPs are returned as-is.
+func resolvePeer(status *ipnstate.Status, host string) (netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr, nil
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, peer := range status.Peer {
+		if peer == nil || len(peer.TailscaleIPs) == 0 {
+			continue
+		}
+		dnsName := strings.ToLower(strings.TrimSuffix(peer.DNSName, "."))
+		shortName, _, _ := strings.Cut(dnsName, ".")
+		if name == dnsName || name == shortName || name == strings.ToLower(peer.HostName) {
+			if !peer.Online {
+				return netip.Addr{}, fmt.Errorf("tailnet peer %q is offline", host)
+			}
+			return peer.TailscaleIPs[0], nil
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("no tailnet peer named %q", host)
+}
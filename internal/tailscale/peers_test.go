@@ -0,0 +1,57 @@
+package tailscale
+
+import (
+	"net/netip"
+	"testing"
+
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/key"
+)
+
+func TestResolvePeer(t *testing.T) {
+	status := &ipnstate.Status{
+		Peer: map[key.NodePublic]*ipnstate.PeerStatus{
+			key.NewNode().Public(): {
+				HostName:     "Build-Box",
+				DNSName:      "build-box.example.ts.net.",
+				TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.64.0.7")},
+				Online:       true,
+			},
+			key.NewNode().Public(): {
+				HostName:     "nas",
+				DNSName:      "nas.example.ts.net.",
+				TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.64.0.8")},
+			},
+		},
+	}
+
+	for _, name := range []string{"build-box", "BUILD-BOX", "build-box.example.ts.net", "Build-Box"} {
+		addr, err := resolvePeer(status, name)
+		if err != nil || addr.String() != "100.64.0.7" {
+			t.Fatalf("resolve %q: expected 100.64.0.7, got %v (%v)", name, addr, err)
+		}
+	}
+
+	if addr, err := resolvePeer(status, "100.100.1.1"); err != nil || addr.String() != "100.100.1.1" {
+		t.Fatalf("expected IP to pass through, got %v (%v)", addr, err)
+	}
+	if _, err := resolvePeer(status, "nas"); err == nil {
+		t.Fatalf("expected offline peer to fail")
+	}
+	if _, err := resolvePeer(status, "missing"); err == nil {
+		t.Fatalf("expected unknown peer to fail")
+	}
+}
+
+func TestSplitPeerAddr(t *testing.T) {
+	host, port, err := splitPeerAddr("build-box:3000")
+	if err != nil || host != "build-box" || port != 3000 {
+		t.Fatalf("unexpected split: %q %d %v", host, port, err)
+	}
+	if _, _, err := splitPeerAddr("build-box"); err == nil {
+		t.Fatalf("expected missing port to fail")
+	}
+	if _, _, err := splitPeerAddr("build-box:99999"); err == nil {
+		t.Fatalf("expected out-of-range port to fail")
+	}
+}
@@ -0,0 +1,172 @@
+// internal/tailscale/ports.go
+package tailscale
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Roles of the ports PortMemory remembers.
+const (
+	// PortProxy is the local proxy that tailscale serve forwards to.
+	PortProxy = "proxy"
+	// PortUI is the local web UI server.
+	PortUI = "ui"
+	// PortUIServe is the Tailscale port the web UI is served on.
+	PortUIServe = "ui-serve"
+)
+
+// PortMemory remembers the ports a project's sessions were given, so a
+// restart lands on the same ones and bookmarked URLs keep working. A port is
+// only reused while it is free; otherwise a new one is picked and remembered
+// instead. A nil PortMemory remembers nothing.
+type PortMemory struct {
+	path     string
+	project  string
+	readOnly bool
+
+	mu sync.Mutex
+}
+
+type portFile struct {
+	Projects map[string]map[string]int `json:"projects"`
+}
+
+// DefaultPortMemoryPath is where ports are remembered: portal/ports.json in
+// the user's cache directory.
+func DefaultPortMemoryPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the cache directory: %w", err)
+	}
+	return filepath.Join(dir, "portal", "ports.json"), nil
+}
+
+// NewPortMemory remembers ports for project in the file at path.
+func NewPortMemory(path, project string) *PortMemory {
+	return &PortMemory{path: path, project: project}
+}
+
+// dryRun returns a copy of m that reads the remembered ports but doesn't
+// change them.
+func (m *PortMemory) dryRun() *PortMemory {
+	if m == nil {
+		return nil
+	}
+	return &PortMemory{path: m.path, project: m.project, readOnly: true}
+}
+
+// Preferred returns the port remembered for role, or 0.
+func (m *PortMemory) Preferred(role string) int {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ports, _ := m.read()
+	return ports.Projects[m.project][role]
+}
+
+// Remember records port for role.
+func (m *PortMemory) Remember(role string, port int) error {
+	if m == nil || m.readOnly {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ports, err := m.read()
+	if err != nil {
+		return err
+	}
+	if ports.Projects[m.project][role] == port {
+		return nil
+	}
+	if ports.Projects == nil {
+		ports.Projects = make(map[string]map[string]int)
+	}
+	if ports.Projects[m.project] == nil {
+		ports.Projects[m.project] = make(map[string]int)
+	}
+	ports.Projects[m.project][role] = port
+	return m.write(ports)
+}
+
+// ProxyPort returns a local port for the proxy: the remembered one when it
+// is free, otherwise one the OS assigns.
+func (m *PortMemory) ProxyPort() (int, error) {
+	return m.localPort(PortProxy, FindAvailableLocalPort)
+}
+
+// UIPort returns a local port for the web UI: the remembered one when it is
+// free, otherwise the first free one from DefaultLocalUIPort, otherwise one
+// the OS assigns.
+func (m *PortMemory) UIPort() (int, error) {
+	return m.localPort(PortUI, func() (int, error) {
+		if port, err := FindAvailableLocalPortFrom(DefaultLocalUIPort); err == nil {
+			return port, nil
+		}
+		return FindAvailableLocalPort()
+	})
+}
+
+func (m *PortMemory) localPort(role string, find func() (int, error)) (int, error) {
+	if port := m.Preferred(role); port != 0 && localPortFree(port) {
+		return port, nil
+	}
+	port, err := find()
+	if err != nil {
+		return 0, err
+	}
+	// Failing to remember only costs the next session its stable port.
+	_ = m.Remember(role, port)
+	return port, nil
+}
+
+func (m *PortMemory) read() (portFile, error) {
+	var ports portFile
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ports, nil
+	}
+	if err != nil {
+		return ports, err
+	}
+	if err := json.Unmarshal(data, &ports); err != nil {
+		// A damaged file is replaced rather than blocking every session.
+		return portFile{}, nil
+	}
+	return ports, nil
+}
+
+// write saves the file, replacing it atomically so sessions starting at the
+// same time never read half of one.
+func (m *PortMemory) write(ports portFile) error {
+	data, err := json.MarshalIndent(ports, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o700); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp." + strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to remember ports: %w", err)
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// localPortFree reports whether port can be listened on locally.
+func localPortFree(port int) bool {
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
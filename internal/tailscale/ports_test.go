@@ -0,0 +1,86 @@
+package tailscale
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"go.uber.org/zap"
+	"tailscale.com/ipn"
+
+	"github.com/jaxxstorm/portal/internal/testsupport"
+)
+
+func TestPortMemoryReusesPorts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.json")
+
+	first, err := NewPortMemory(path, "/src/app:3000").ProxyPort()
+	if err != nil {
+		t.Fatalf("ProxyPort: %v", err)
+	}
+	again, err := NewPortMemory(path, "/src/app:3000").ProxyPort()
+	if err != nil || again != first {
+		t.Fatalf("expected the remembered port %d after a restart, got %d (%v)", first, again, err)
+	}
+	if other := NewPortMemory(path, "/src/api:8080").Preferred(PortProxy); other != 0 {
+		t.Fatalf("expected another project to have no remembered port, got %d", other)
+	}
+
+	// Something else took the port: a new one is picked and remembered.
+	occupied, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(first)))
+	if err != nil {
+		t.Skipf("could not occupy port %d: %v", first, err)
+	}
+	defer occupied.Close()
+	moved, err := NewPortMemory(path, "/src/app:3000").ProxyPort()
+	if err != nil || moved == first {
+		t.Fatalf("expected a free port instead of the taken %d, got %d (%v)", first, moved, err)
+	}
+	if got := NewPortMemory(path, "/src/app:3000").Preferred(PortProxy); got != moved {
+		t.Fatalf("expected %d to be remembered, got %d", moved, got)
+	}
+
+	var none *PortMemory
+	if port, err := none.ProxyPort(); err != nil || port == 0 {
+		t.Fatalf("expected a nil memory to still find a port, got %d (%v)", port, err)
+	}
+}
+
+func TestUIServeReusesTailscalePort(t *testing.T) {
+	ctx := context.Background()
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	client := NewClientWithLocal(daemon, zap.NewNop())
+	client.SetPortMemory(NewPortMemory(filepath.Join(t.TempDir(), "ports.json"), "/src/app:3000"))
+
+	// Another service already serves on the first candidate port.
+	existing := new(ipn.ServeConfig)
+	existing.SetWebHandler(&ipn.HTTPHandler{Proxy: "http://127.0.0.1:9000"}, "dev-box.example.ts.net", 8080, "/", false, "")
+	if err := daemon.SetServeConfig(ctx, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	port, _, err := client.SetupUIServe(ctx, 4040)
+	if err != nil {
+		t.Fatalf("SetupUIServe: %v", err)
+	}
+	if port != 8081 {
+		t.Fatalf("expected the first free port after 8080, got %d", port)
+	}
+	if err := daemon.SetServeConfig(ctx, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run plans with the remembered port but doesn't change it.
+	if err := client.Ports().Remember(PortUIServe, 8090); err != nil {
+		t.Fatal(err)
+	}
+	dryClient, _ := client.DryRun()
+	if port, _, err := dryClient.SetupUIServe(ctx, 4040); err != nil || port != 8090 {
+		t.Fatalf("expected the remembered port 8090, got %d (%v)", port, err)
+	}
+	if err := dryClient.Ports().Remember(PortUIServe, 8095); err != nil || client.Ports().Preferred(PortUIServe) != 8090 {
+		t.Fatalf("expected a dry run to leave the remembered port alone")
+	}
+}
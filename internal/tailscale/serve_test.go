@@ -0,0 +1,263 @@
+package tailscale
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/testsupport"
+)
+
+var (
+	_ LocalClient = (*testsupport.FakeLocalClient)(nil)
+	_ Node        = (*testsupport.FakeNode)(nil)
+)
+
+func TestSetupServeConfiguresWebHandler(t *testing.T) {
+	ctx := context.Background()
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	client := NewClientWithLocal(daemon, zap.NewNop())
+
+	info, err := client.SetupServe(ctx, Config{ProxyPort: 51234, MountPath: "api"})
+	if err != nil {
+		t.Fatalf("SetupServe: %v", err)
+	}
+	if info.URL != "http://dev-box.example.ts.net/api" || info.ServePort != 80 || info.IsHTTPS {
+		t.Fatalf("unexpected service info %+v", info)
+	}
+	target, err := daemon.ProxyTarget(80, "/api")
+	if err != nil || target != "http://localhost:51234" {
+		t.Fatalf("expected handler proxying to the local port, got %q %v", target, err)
+	}
+
+	if _, err := client.SetupServe(ctx, Config{ProxyPort: 51235}); err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Fatalf("expected port conflict, got %v", err)
+	}
+
+	if err := client.CleanupAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if sc := daemon.ServeConfig(); len(sc.Web) != 0 || len(sc.TCP) != 0 {
+		t.Fatalf("expected an empty serve config after cleanup, got %+v", sc)
+	}
+}
+
+func TestSetupServeFunnelRequiresHTTPSCertificates(t *testing.T) {
+	ctx := context.Background()
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	client := NewClientWithLocal(daemon, zap.NewNop())
+
+	config := Config{ProxyPort: 51234, UseHTTPS: true, EnableFunnel: true}
+	if _, err := client.SetupServe(ctx, config); !errors.Is(err, ErrFunnelNotPermitted) {
+		t.Fatalf("expected funnel to be refused without HTTPS, got %v", err)
+	}
+	if daemon.SetServeCalls() != 0 {
+		t.Fatal("expected no serve config to be written on failure")
+	}
+
+	daemon.GrantHTTPS()
+	info, err := client.SetupServe(ctx, config)
+	if err != nil {
+		t.Fatalf("SetupServe: %v", err)
+	}
+	if !info.IsFunnel || info.URL != "https://dev-box.example.ts.net/" || !daemon.FunnelEnabled(443) {
+		t.Fatalf("expected funnel on 443, got %+v", info)
+	}
+}
+
+func TestSetupServeFunnelPaths(t *testing.T) {
+	ctx := context.Background()
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	daemon.GrantHTTPS()
+	client := NewClientWithLocal(daemon, zap.NewNop())
+
+	info, err := client.SetupServe(ctx, Config{ProxyPort: 51234, FunnelPaths: []string{"/hooks"}})
+	if err != nil {
+		t.Fatalf("SetupServe: %v", err)
+	}
+	if !slices.Equal(info.FunnelURLs, []string{"https://dev-box.example.ts.net/hooks"}) {
+		t.Fatalf("unexpected funnel URLs %v", info.FunnelURLs)
+	}
+	target, err := daemon.ProxyTarget(443, "/hooks")
+	if err != nil || target != "http://localhost:51234/hooks" {
+		t.Fatalf("expected funnel path handler, got %q %v", target, err)
+	}
+	if !daemon.FunnelEnabled(443) || daemon.FunnelEnabled(80) {
+		t.Fatal("expected funnel only on 443")
+	}
+}
+
+func TestSetupServeProxyProtocolUsesTCPForwarding(t *testing.T) {
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	daemon.GrantHTTPS()
+	client := NewClientWithLocal(daemon, zap.NewNop())
+
+	_, err := client.SetupServe(context.Background(), Config{ProxyPort: 51234, UseHTTPS: true, EnableFunnel: true, EnableProxyProtocol: true})
+	if err != nil {
+		t.Fatalf("SetupServe: %v", err)
+	}
+	if target, ok := daemon.TCPForward(443); !ok || target != "127.0.0.1:51234" {
+		t.Fatalf("expected TCP forwarding to the proxy, got %q", target)
+	}
+}
+
+func TestSetupServeServiceModeAdvertisesService(t *testing.T) {
+	ctx := context.Background()
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	client := NewClientWithLocal(daemon, zap.NewNop())
+	config := Config{ProxyPort: 51234, ListenMode: TSNetListenModeService, ServiceName: "svc:portal"}
+
+	if _, err := client.SetupServe(ctx, config); err == nil || !strings.Contains(err.Error(), "tag-based identity") {
+		t.Fatalf("expected untagged host to be rejected, got %v", err)
+	}
+
+	daemon.SetTags("tag:dev")
+	info, err := client.SetupServe(ctx, config)
+	if err != nil {
+		t.Fatalf("SetupServe: %v", err)
+	}
+	if info.URL != "http://portal.example.ts.net/" {
+		t.Fatalf("unexpected service URL %q", info.URL)
+	}
+	prefs, _ := daemon.GetPrefs(ctx)
+	if !slices.Equal(prefs.AdvertiseServices, []string{"svc:portal"}) {
+		t.Fatalf("expected service to be advertised, got %v", prefs.AdvertiseServices)
+	}
+}
+
+func TestSetupUIServe(t *testing.T) {
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	client := NewClientWithLocal(daemon, zap.NewNop())
+
+	port, url, err := client.SetupUIServe(context.Background(), 4040)
+	if err != nil {
+		t.Fatalf("SetupUIServe: %v", err)
+	}
+	if !strings.HasPrefix(url, "http://dev-box.example.ts.net:") || !strings.HasSuffix(url, "/ui/") {
+		t.Fatalf("unexpected UI URL %q", url)
+	}
+	target, err := daemon.ProxyTarget(port, "/ui/")
+	if err != nil || target != "http://localhost:4040" {
+		t.Fatalf("expected UI handler, got %q %v", target, err)
+	}
+}
+
+func TestNamespacesShareTheServePort(t *testing.T) {
+	ctx := context.Background()
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	client := NewClientWithLocal(daemon, zap.NewNop())
+
+	alice := Config{ProxyPort: 51234, MountPath: "/alice", Namespace: "alice"}
+	bob := Config{ProxyPort: 51235, MountPath: "/bob", Namespace: "bob"}
+	for _, config := range []Config{alice, bob} {
+		info, err := client.SetupServe(ctx, config)
+		if err != nil {
+			t.Fatalf("SetupServe %s: %v", config.Namespace, err)
+		}
+		if info.URL != "http://dev-box.example.ts.net"+config.MountPath || info.ServePort != 80 {
+			t.Fatalf("unexpected service info %+v", info)
+		}
+	}
+
+	port, url, err := client.SetupMountedUIServe(ctx, 4040, alice, "/alice/ui")
+	if err != nil {
+		t.Fatalf("SetupMountedUIServe: %v", err)
+	}
+	if port != 80 || url != "http://dev-box.example.ts.net/alice/ui/" {
+		t.Fatalf("unexpected UI serve port=%d url=%q", port, url)
+	}
+	target, err := daemon.ProxyTarget(80, "/alice/ui")
+	if err != nil || target != "http://localhost:4040/ui" {
+		t.Fatalf("expected the UI handler under the namespace, got %q %v", target, err)
+	}
+
+	if _, err := client.SetupServe(ctx, Config{ProxyPort: 51236, UseHTTPS: true, ServePort: 80, MountPath: "/carol", Namespace: "carol"}); err == nil {
+		t.Fatal("expected HTTPS on a port serving HTTP to conflict")
+	}
+
+	if err := client.RemoveMounts(ctx, alice, alice.MountPath, "/alice/ui"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := daemon.ProxyTarget(80, "/alice"); err == nil {
+		t.Fatal("expected alice's handler to be removed")
+	}
+	if target, err := daemon.ProxyTarget(80, "/bob"); err != nil || target != "http://localhost:51235" {
+		t.Fatalf("expected bob's handler to survive, got %q %v", target, err)
+	}
+}
+
+func TestUIPathSharesTheServePort(t *testing.T) {
+	ctx := context.Background()
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	client := NewClientWithLocal(daemon, zap.NewNop())
+	config := Config{ProxyPort: 51234, UIPath: "/__portal/ui"}
+
+	port, url, err := client.SetupMountedUIServe(ctx, 4040, config, config.UIPath)
+	if err != nil {
+		t.Fatalf("SetupMountedUIServe: %v", err)
+	}
+	if port != 80 || url != "http://dev-box.example.ts.net/__portal/ui/" {
+		t.Fatalf("unexpected UI serve port=%d url=%q", port, url)
+	}
+	if _, err := client.SetupServe(ctx, config); err != nil {
+		t.Fatalf("expected the service to share the port with its UI, got %v", err)
+	}
+	if target, err := daemon.ProxyTarget(80, "/"); err != nil || target != "http://localhost:51234" {
+		t.Fatalf("expected the service at the root, got %q %v", target, err)
+	}
+	if target, err := daemon.ProxyTarget(80, "/__portal/ui"); err != nil || target != "http://localhost:4040/ui" {
+		t.Fatalf("expected the UI handler kept, got %q %v", target, err)
+	}
+
+	// Another handler on the port still counts.
+	if _, err := client.SetupServe(ctx, Config{ProxyPort: 51235, UIPath: "/__portal/ui"}); err == nil {
+		t.Fatal("expected a port serving another session to be in use")
+	}
+}
+
+func TestClientUnavailableWithoutDaemon(t *testing.T) {
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	daemon.SetErr(net.ErrClosed)
+	client := NewClientWithLocal(daemon, zap.NewNop())
+
+	if client.IsAvailable(context.Background()) {
+		t.Fatal("expected daemon to be unavailable")
+	}
+	if _, err := client.SetupServe(context.Background(), Config{ProxyPort: 51234}); err == nil {
+		t.Fatal("expected SetupServe to fail")
+	}
+}
+
+func TestDialPeerThroughFakeDaemon(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Write([]byte("hi"))
+			conn.Close()
+		}
+	}()
+
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	daemon.AddPeer("build-box", netip.MustParseAddr("100.64.0.7"), 3000, ln.Addr().String())
+	client := NewClientWithLocal(daemon, zap.NewNop())
+
+	conn, err := client.DialPeer(context.Background(), "tcp", "build-box:3000")
+	if err != nil {
+		t.Fatalf("DialPeer: %v", err)
+	}
+	defer conn.Close()
+	buf := make([]byte, 2)
+	if _, err := conn.Read(buf); err != nil || string(buf) != "hi" {
+		t.Fatalf("expected to reach the peer, got %q %v", buf, err)
+	}
+}
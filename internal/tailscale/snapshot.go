@@ -0,0 +1,157 @@
+// internal/tailscale/snapshot.go
+package tailscale
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+	"tailscale.com/ipn"
+
+	"github.com/jaxxstorm/portal/internal/logging"
+)
+
+// ErrServeSnapshotHeld is returned by SnapshotServe when another portal
+// session that is still running saved the serve config first; that session
+// restores it.
+var ErrServeSnapshotHeld = errors.New("serve config snapshot is held by another running portal session")
+
+// ServeSnapshot is the node's serve config from before portal changed it,
+// kept on disk until it is restored so a session that doesn't exit cleanly
+// can still be undone.
+type ServeSnapshot struct {
+	PID     int              `json:"pid"`
+	TakenAt time.Time        `json:"taken_at"`
+	Config  *ipn.ServeConfig `json:"config"`
+
+	path string
+}
+
+// DefaultServeSnapshotPath is where the serve config is saved:
+// portal/serve-snapshot.json in the user's cache directory.
+func DefaultServeSnapshotPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the cache directory: %w", err)
+	}
+	return filepath.Join(dir, "portal", "serve-snapshot.json"), nil
+}
+
+// SnapshotServe saves the node's serve config to path before this session
+// changes it. running reports whether a process is alive. A snapshot left by
+// a session that has exited is taken over instead, because the node still has
+// that session's changes on top of the config it saved. With an empty path
+// the snapshot is only kept in memory.
+func (c *Client) SnapshotServe(ctx context.Context, path string, running func(pid int) bool) (*ServeSnapshot, error) {
+	if path != "" {
+		previous, err := readServeSnapshot(path)
+		switch {
+		case err == nil:
+			if previous.PID != os.Getpid() && running(previous.PID) {
+				return nil, fmt.Errorf("%w (pid %d)", ErrServeSnapshotHeld, previous.PID)
+			}
+			previous.PID = os.Getpid()
+			if err := previous.write(); err != nil {
+				return nil, err
+			}
+			c.logger.Warn("Found the serve config saved by a portal session that didn't exit cleanly; it will be restored on exit",
+				logging.Component("tailscale_serve"),
+				zap.String("path", path),
+				zap.Time("taken_at", previous.TakenAt),
+			)
+			return previous, nil
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+	}
+
+	sc, err := c.lc.GetServeConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get serve config: %w", err)
+	}
+	if sc == nil {
+		sc = new(ipn.ServeConfig)
+	}
+	snapshot := &ServeSnapshot{PID: os.Getpid(), TakenAt: time.Now(), Config: sc.Clone(), path: path}
+	if path == "" {
+		return snapshot, nil
+	}
+	if err := snapshot.write(); err != nil {
+		return nil, err
+	}
+	c.logger.Debug("Saved serve config snapshot",
+		logging.Component("tailscale_serve"),
+		zap.String("path", path),
+	)
+	return snapshot, nil
+}
+
+// RestoreServe sets the serve config back to snapshot and removes its file.
+// The file is kept when the restore fails, so the next session retries it.
+func (c *Client) RestoreServe(ctx context.Context, snapshot *ServeSnapshot) error {
+	config := snapshot.Config.Clone()
+	if config == nil {
+		config = new(ipn.ServeConfig)
+	}
+	if err := c.lc.SetServeConfig(ctx, config); err != nil {
+		return fmt.Errorf("failed to restore serve config: %w", err)
+	}
+	c.logger.Info("Restored the serve config from before this session",
+		logging.Component("tailscale_serve"),
+		zap.Time("taken_at", snapshot.TakenAt),
+	)
+	if snapshot.path == "" {
+		return nil
+	}
+	if err := os.Remove(snapshot.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// DiscardServeSnapshot removes a snapshot at path left by a session that has
+// exited, for when the serve config is cleared by hand.
+func DiscardServeSnapshot(path string, running func(pid int) bool) error {
+	snapshot, err := readServeSnapshot(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err == nil && running(snapshot.PID) {
+		return ErrServeSnapshotHeld
+	}
+	return os.Remove(path)
+}
+
+func readServeSnapshot(path string) (*ServeSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &ServeSnapshot{path: path}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("invalid serve config snapshot %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// write saves the snapshot, replacing the file atomically so a crash never
+// leaves half of one.
+func (s *ServeSnapshot) write() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save serve config snapshot: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
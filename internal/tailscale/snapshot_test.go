@@ -0,0 +1,102 @@
+package tailscale
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+	"tailscale.com/ipn"
+
+	"github.com/jaxxstorm/portal/internal/testsupport"
+)
+
+func TestSnapshotServeRestoresExistingConfig(t *testing.T) {
+	ctx := context.Background()
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	client := NewClientWithLocal(daemon, zap.NewNop())
+
+	// Another service the node already serves with tailscale serve.
+	existing := new(ipn.ServeConfig)
+	existing.SetWebHandler(&ipn.HTTPHandler{Proxy: "http://127.0.0.1:9000"}, "dev-box.example.ts.net", 8443, "/grafana", true, "")
+	if err := daemon.SetServeConfig(ctx, existing); err != nil {
+		t.Fatal(err)
+	}
+	want := daemon.ServeConfig().Clone()
+
+	path := filepath.Join(t.TempDir(), "serve-snapshot.json")
+	notRunning := func(int) bool { return false }
+	snapshot, err := client.SnapshotServe(ctx, path, notRunning)
+	if err != nil {
+		t.Fatalf("SnapshotServe: %v", err)
+	}
+	if _, err := client.SetupServe(ctx, Config{ProxyPort: 51234}); err != nil {
+		t.Fatalf("SetupServe: %v", err)
+	}
+	if reflect.DeepEqual(daemon.ServeConfig(), want) {
+		t.Fatal("expected SetupServe to change the serve config")
+	}
+
+	if err := client.RestoreServe(ctx, snapshot); err != nil {
+		t.Fatalf("RestoreServe: %v", err)
+	}
+	if got := daemon.ServeConfig(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the original serve config back, got %+v", got)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the snapshot file to be removed, got %v", err)
+	}
+}
+
+func TestSnapshotServeAdoptsLeftoverSnapshot(t *testing.T) {
+	ctx := context.Background()
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	client := NewClientWithLocal(daemon, zap.NewNop())
+	path := filepath.Join(t.TempDir(), "serve-snapshot.json")
+
+	// A session saves the empty config, serves, then dies without restoring.
+	if _, err := client.SnapshotServe(ctx, path, func(int) bool { return false }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SetupServe(ctx, Config{ProxyPort: 51234}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.SnapshotServe(ctx, path, func(int) bool { return true }); err != nil {
+		t.Fatalf("expected this process's own snapshot to be reused, got %v", err)
+	}
+	leftover, err := readServeSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leftover.PID = os.Getpid() + 1
+	if err := leftover.write(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SnapshotServe(ctx, path, func(int) bool { return true }); !errors.Is(err, ErrServeSnapshotHeld) {
+		t.Fatalf("expected a running session's snapshot to be left alone, got %v", err)
+	}
+	if err := DiscardServeSnapshot(path, func(int) bool { return true }); !errors.Is(err, ErrServeSnapshotHeld) {
+		t.Fatalf("expected a running session's snapshot to be kept, got %v", err)
+	}
+
+	snapshot, err := client.SnapshotServe(ctx, path, func(int) bool { return false })
+	if err != nil {
+		t.Fatalf("SnapshotServe: %v", err)
+	}
+	if snapshot.PID != os.Getpid() || len(snapshot.Config.Web) != 0 {
+		t.Fatalf("expected the dead session's empty config to be adopted, got %+v", snapshot)
+	}
+	if err := client.RestoreServe(ctx, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if sc := daemon.ServeConfig(); len(sc.Web) != 0 || len(sc.TCP) != 0 {
+		t.Fatalf("expected the crashed session's handlers to be removed, got %+v", sc)
+	}
+	if err := DiscardServeSnapshot(path, func(int) bool { return false }); err != nil {
+		t.Fatalf("expected a missing snapshot to be fine, got %v", err)
+	}
+}
@@ -3,20 +3,23 @@ package tailscale
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/netip"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"tailscale.com/ipn"
 	"tailscale.com/tailcfg"
 	"tailscale.com/tsnet"
 
+	"github.com/jaxxstorm/portal/internal/httputil"
 	"github.com/jaxxstorm/portal/internal/logging"
 )
 
@@ -34,6 +37,19 @@ type TSNetConfig struct {
 	ServePort    int
 	ListenMode   string
 	ServiceName  string
+	// StateDir holds the node's state. Empty uses tsnet's default, which
+	// only one node per process can use.
+	StateDir string
+	// ConfigureHTTPServer, when set, adjusts the serving http.Server (e.g.
+	// timeouts and header limits) before it starts.
+	ConfigureHTTPServer func(*http.Server)
+	// WrapListener, when set, wraps the serving listener (e.g. to record
+	// its connections) before it is served.
+	WrapListener func(net.Listener) net.Listener
+	// Verbose includes tsnet's backend logs, which are otherwise dropped
+	// unless the logger is at debug level. Messages tsnet meant for the
+	// user, such as login URLs, are always kept.
+	Verbose bool
 }
 
 // TSNetReadyInfo captures serving details emitted once TSNet is ready.
@@ -47,7 +63,7 @@ type TSNetReadyInfo struct {
 
 // TSNetServer wraps a tsnet server with additional functionality
 type TSNetServer struct {
-	server        *tsnet.Server
+	server        Node
 	logger        *zap.Logger
 	config        TSNetConfig
 	readyCallback func(TSNetReadyInfo)
@@ -61,10 +77,25 @@ func NewTSNetServer(config TSNetConfig, logger *zap.Logger) *TSNetServer {
 	server := &tsnet.Server{
 		Hostname: config.Hostname,
 		AuthKey:  config.AuthKey,
-		Logf:     newTSNetRuntimeLogAdapter(logger, config.Hostname),
-		UserLogf: newTSNetRuntimeLogAdapter(logger, config.Hostname),
+		Dir:      config.StateDir,
+		Logf:     newTSNetRuntimeLogAdapter(logger, config.Hostname, config.Verbose || logger.Core().Enabled(zapcore.DebugLevel)),
+		UserLogf: newTSNetRuntimeLogAdapter(logger, config.Hostname, true),
 	}
+	return NewTSNetServerWithNode(config, tsnetNode{server}, logger)
+}
 
+// NodeStateDir returns a state directory for an extra tsnet node, next to
+// the default one tsnet uses for this program.
+func NodeStateDir(name string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "tsnet-"+filepath.Base(os.Args[0])+"-"+name), nil
+}
+
+// NewTSNetServerWithNode creates a tsnet server that runs on node.
+func NewTSNetServerWithNode(config TSNetConfig, node Node, logger *zap.Logger) *TSNetServer {
 	logger.Info("Creating TSNet server",
 		logging.Component("tsnet_server"),
 		logging.TailscaleMode("tsnet"),
@@ -79,7 +110,7 @@ func NewTSNetServer(config TSNetConfig, logger *zap.Logger) *TSNetServer {
 	)
 
 	return &TSNetServer{
-		server: server,
+		server: node,
 		logger: logger,
 		config: config,
 	}
@@ -215,12 +246,24 @@ func (ts *TSNetServer) Serve(ctx context.Context, handler http.Handler) error {
 			return ctx
 		},
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Set the headers tailscale serve would, so the proxy can tell
+			// Funnel clients apart, and drop any a client sent itself.
+			r.Header.Del(httputil.FunnelRequestHeader)
+			r.Header.Del("Tailscale-User-Login")
+			r.Header.Del("Tailscale-User-Name")
 			if sourceIP, ok := funnelClientIPFromContext(r.Context()); ok {
 				r.Header.Set("Tailscale-Client-IP", sourceIP)
+				r.Header.Set(httputil.FunnelRequestHeader, "?1")
 			}
 			handler.ServeHTTP(w, r)
 		}),
 	}
+	if ts.config.ConfigureHTTPServer != nil {
+		ts.config.ConfigureHTTPServer(httpServer)
+	}
+	if ts.config.WrapListener != nil {
+		ln = ts.config.WrapListener(ln)
+	}
 
 	// Start the device
 	serviceURL, err := ts.Start(ctx)
@@ -328,6 +371,9 @@ func (ts *TSNetServer) listenForServe(addr string, port int, useTLS bool, effect
 		switch {
 		case ts.config.EnableFunnel:
 			ln, err = ts.server.ListenFunnel("tcp", addr)
+			if err != nil {
+				err = fmt.Errorf("%w: %w", ErrFunnelNotPermitted, err)
+			}
 		case useTLS:
 			ln, err = ts.server.ListenTLS("tcp", addr)
 		default:
@@ -466,7 +512,8 @@ func funnelSourceIPFromConn(conn net.Conn) (netip.Addr, bool) {
 		if addr.IsValid() {
 			return addr, true
 		}
-	case *tls.Conn:
+	case interface{ NetConn() net.Conn }:
+		// TLS and recorded connections.
 		return funnelSourceIPFromConn(c.NetConn())
 	}
 
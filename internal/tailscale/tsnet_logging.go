@@ -10,8 +10,10 @@ import (
 	"github.com/jaxxstorm/portal/internal/logging"
 )
 
-func newTSNetRuntimeLogAdapter(logger *zap.Logger, nodeName string) func(format string, args ...any) {
-	verboseEnabled := logger.Core().Enabled(zapcore.DebugLevel)
+// newTSNetRuntimeLogAdapter routes tsnet's printf-style logs into logger,
+// rather than tsnet's default of stderr, which would corrupt the TUI. Logs
+// are dropped unless include is set.
+func newTSNetRuntimeLogAdapter(logger *zap.Logger, nodeName string, include bool) func(format string, args ...any) {
 	runtimeLogger := logger.WithOptions(zap.AddStacktrace(zapcore.PanicLevel))
 
 	baseFields := []zap.Field{
@@ -28,9 +30,9 @@ func newTSNetRuntimeLogAdapter(logger *zap.Logger, nodeName string) func(format
 			return
 		}
 
-		// Underlying tsnet runtime logs are debug-only noise for normal runs.
-		// Surface them only when verbose mode enables debug-level logging.
-		if !verboseEnabled {
+		// Underlying tsnet runtime logs are noise for normal runs; they are
+		// only included with --verbose or --tsnet-verbose.
+		if !include {
 			return
 		}
 
@@ -15,7 +15,7 @@ func TestTSNetRuntimeLogsUsePrimaryLoggerWithStructuredContext(t *testing.T) {
 	server := NewTSNetServer(TSNetConfig{Hostname: "portal"}, logger)
 	observed.TakeAll() // Drop construction log.
 
-	server.server.Logf("AuthLoop: state is Running; done")
+	server.server.(tsnetNode).Logf("AuthLoop: state is Running; done")
 
 	entries := observed.All()
 	if len(entries) != 1 {
@@ -44,8 +44,8 @@ func TestTSNetRuntimeLogLevelAndPhaseMapping(t *testing.T) {
 	server := NewTSNetServer(TSNetConfig{Hostname: "portal"}, logger)
 	observed.TakeAll() // Drop construction log.
 
-	server.server.Logf("Authkey is set; but state is NoState. Ignoring authkey.")
-	server.server.Logf("Funnel setup failed: certificate check failed")
+	server.server.(tsnetNode).Logf("Authkey is set; but state is NoState. Ignoring authkey.")
+	server.server.(tsnetNode).Logf("Funnel setup failed: certificate check failed")
 
 	entries := observed.All()
 	if len(entries) != 2 {
@@ -70,7 +70,7 @@ func TestTSNetRuntimeLogAdapterSkipsEmptyMessages(t *testing.T) {
 	server := NewTSNetServer(TSNetConfig{Hostname: "portal"}, logger)
 	observed.TakeAll() // Drop construction log.
 
-	server.server.Logf("   ")
+	server.server.(tsnetNode).Logf("   ")
 
 	if got := len(observed.All()); got != 0 {
 		t.Fatalf("expected no entries for empty messages, got %d", got)
@@ -84,7 +84,7 @@ func TestTSNetRuntimeInfoLogsSuppressedWithoutVerbose(t *testing.T) {
 	server := NewTSNetServer(TSNetConfig{Hostname: "portal"}, logger)
 	observed.TakeAll() // Drop construction log.
 
-	server.server.Logf("wgengine: Reconfig: configuring userspace WireGuard config")
+	server.server.(tsnetNode).Logf("wgengine: Reconfig: configuring userspace WireGuard config")
 
 	if got := len(observed.All()); got != 0 {
 		t.Fatalf("expected no runtime info logs without verbose mode, got %d", got)
@@ -98,14 +98,45 @@ func TestTSNetRuntimeWarnAndErrorLogsSuppressedWithoutVerbose(t *testing.T) {
 	server := NewTSNetServer(TSNetConfig{Hostname: "portal"}, logger)
 	observed.TakeAll() // Drop construction log.
 
-	server.server.Logf("Authkey is set; but state is NoState. Ignoring authkey.")
-	server.server.Logf("health(warnable=warming-up): error: Tailscale is starting. Please wait.")
+	server.server.(tsnetNode).Logf("Authkey is set; but state is NoState. Ignoring authkey.")
+	server.server.(tsnetNode).Logf("health(warnable=warming-up): error: Tailscale is starting. Please wait.")
 
 	if got := len(observed.All()); got != 0 {
 		t.Fatalf("expected no runtime warn/error logs without verbose mode, got %d", got)
 	}
 }
 
+func TestTSNetVerboseIncludesBackendLogs(t *testing.T) {
+	core, observed := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	server := NewTSNetServer(TSNetConfig{Hostname: "portal", Verbose: true}, logger)
+	observed.TakeAll() // Drop construction log.
+
+	server.server.(tsnetNode).Logf("wgengine: Reconfig: configuring userspace WireGuard config")
+
+	entries := observed.All()
+	if len(entries) != 1 || entries[0].Level != zapcore.InfoLevel {
+		t.Fatalf("expected the backend log with --tsnet-verbose, got %+v", entries)
+	}
+}
+
+func TestTSNetUserLogsKeptWithoutVerbose(t *testing.T) {
+	core, observed := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	server := NewTSNetServer(TSNetConfig{Hostname: "portal"}, logger)
+	observed.TakeAll() // Drop construction log.
+
+	server.server.(tsnetNode).UserLogf("To start this tsnet server, restart with TS_AUTHKEY set, or go to: https://login.tailscale.com/a/abc123")
+
+	entries := observed.All()
+	if len(entries) != 1 {
+		t.Fatalf("expected the login URL to be logged without verbose mode, got %d entries", len(entries))
+	}
+	assertStringField(t, entries[0].ContextMap(), "component", "tsnet_runtime")
+}
+
 func TestTSNetUserLogsUseAdapterAndDoNotLeak(t *testing.T) {
 	core, observed := observer.New(zapcore.DebugLevel)
 	logger := zap.New(core)
@@ -113,11 +144,11 @@ func TestTSNetUserLogsUseAdapterAndDoNotLeak(t *testing.T) {
 	server := NewTSNetServer(TSNetConfig{Hostname: "portal"}, logger)
 	observed.TakeAll() // Drop construction log.
 
-	if server.server.UserLogf == nil {
+	if server.server.(tsnetNode).UserLogf == nil {
 		t.Fatalf("expected tsnet user logger hook to be configured")
 	}
 
-	server.server.UserLogf("AuthLoop: state is Running; done")
+	server.server.(tsnetNode).UserLogf("AuthLoop: state is Running; done")
 
 	entries := observed.All()
 	if len(entries) != 1 {
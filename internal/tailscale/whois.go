@@ -0,0 +1,71 @@
+// internal/tailscale/whois.go
+package tailscale
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/net/tsaddr"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// whoIser is implemented by LocalAPI clients that can say who owns a
+// tailnet address. *local.Client does; the test fake doesn't need to.
+type whoIser interface {
+	WhoIs(ctx context.Context, remoteAddr string) (*apitype.WhoIsResponse, error)
+}
+
+// WhoIs looks up the user and device behind a tailnet address through the
+// local daemon. ok is false for addresses outside the tailnet and when the
+// lookup fails.
+func (c *Client) WhoIs(ctx context.Context, addr netip.Addr) (model.Identity, bool) {
+	return whoIs(ctx, c.lc, addr)
+}
+
+// WhoIs looks up the user and device behind a tailnet address through the
+// tsnet node.
+func (ts *TSNetServer) WhoIs(ctx context.Context, addr netip.Addr) (model.Identity, bool) {
+	return whoIs(ctx, ts.server, addr)
+}
+
+func (n tsnetNode) WhoIs(ctx context.Context, remoteAddr string) (*apitype.WhoIsResponse, error) {
+	lc, err := n.LocalClient()
+	if err != nil {
+		return nil, err
+	}
+	return lc.WhoIs(ctx, remoteAddr)
+}
+
+func whoIs(ctx context.Context, client any, addr netip.Addr) (model.Identity, bool) {
+	lookup, ok := client.(whoIser)
+	if !ok || !tsaddr.IsTailscaleIP(addr) {
+		return model.Identity{}, false
+	}
+	response, err := lookup.WhoIs(ctx, addr.String())
+	if err != nil || response == nil || response.Node == nil {
+		return model.Identity{}, false
+	}
+	return identityFromWhoIs(addr, response), true
+}
+
+// identityFromWhoIs converts a WhoIs answer. Tagged devices belong to their
+// tags rather than a user, so their placeholder user profile is dropped.
+func identityFromWhoIs(addr netip.Addr, response *apitype.WhoIsResponse) model.Identity {
+	node := response.Node
+	identity := model.Identity{
+		Address: addr.String(),
+		Device:  strings.TrimSuffix(node.ComputedName, "."),
+		Tags:    node.Tags,
+	}
+	if identity.Device == "" {
+		identity.Device, _, _ = strings.Cut(node.Name, ".")
+	}
+	if !node.IsTagged() && response.UserProfile != nil {
+		identity.Login = response.UserProfile.LoginName
+		identity.Name = response.UserProfile.DisplayName
+	}
+	return identity
+}
@@ -0,0 +1,38 @@
+package tailscale
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+
+	"github.com/jaxxstorm/portal/internal/testsupport"
+)
+
+func TestIdentityFromWhoIs(t *testing.T) {
+	addr := netip.MustParseAddr("100.64.0.7")
+	user := identityFromWhoIs(addr, &apitype.WhoIsResponse{
+		Node:        &tailcfg.Node{ComputedName: "alice-laptop", Name: "alice-laptop.tail1234.ts.net."},
+		UserProfile: &tailcfg.UserProfile{LoginName: "alice@example.com", DisplayName: "Alice"},
+	})
+	if user.Login != "alice@example.com" || user.Name != "Alice" || user.Device != "alice-laptop" || user.Address != "100.64.0.7" {
+		t.Fatalf("unexpected user identity %+v", user)
+	}
+
+	tagged := identityFromWhoIs(addr, &apitype.WhoIsResponse{
+		Node:        &tailcfg.Node{Name: "ci-runner.tail1234.ts.net.", Tags: []string{"tag:ci"}},
+		UserProfile: &tailcfg.UserProfile{LoginName: "tagged-devices"},
+	})
+	if tagged.Login != "" || tagged.Device != "ci-runner" || len(tagged.Tags) != 1 || tagged.Tags[0] != "tag:ci" {
+		t.Fatalf("unexpected tagged identity %+v", tagged)
+	}
+}
+
+func TestWhoIsSkipsAddressesOutsideTheTailnet(t *testing.T) {
+	client := NewClientWithLocal(testsupport.NewFakeLocalClient("dev.tail1234.ts.net"), nil)
+	if _, ok := client.WhoIs(context.Background(), netip.MustParseAddr("203.0.113.7")); ok {
+		t.Fatal("expected no identity for a public address")
+	}
+}
@@ -0,0 +1,143 @@
+// internal/termshare/share.go
+package termshare
+
+import (
+	"os"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// subscriberBuffer is how many pending writes a viewer may fall behind by
+// before it is disconnected.
+const subscriberBuffer = 256
+
+// Size is the terminal size viewers should render at.
+type Size struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+// Share fans the TUI's terminal output out to read-only viewers. It is an
+// io.Writer meant to be combined with the real terminal via io.MultiWriter.
+type Share struct {
+	mu     sync.Mutex
+	subs   map[chan []byte]struct{}
+	size   func() Size
+	onJoin func()
+}
+
+// New returns a share. size reports the current terminal size and onJoin is
+// called whenever a viewer connects so the TUI can repaint the full screen;
+// either may be nil.
+func New(size func() Size, onJoin func()) *Share {
+	return &Share{
+		subs:   make(map[chan []byte]struct{}),
+		size:   size,
+		onJoin: onJoin,
+	}
+}
+
+// SetOnJoin replaces the join hook, for when the program to repaint is
+// created after the share.
+func (s *Share) SetOnJoin(onJoin func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onJoin = onJoin
+}
+
+// Write copies p to every viewer. Viewers that cannot keep up are dropped
+// rather than blocking the TUI; their clients reconnect and get a repaint.
+func (s *Share) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.subs) == 0 {
+		return len(p), nil
+	}
+	chunk := append([]byte(nil), p...)
+	for ch := range s.subs {
+		select {
+		case ch <- chunk:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+	return len(p), nil
+}
+
+// Subscribe registers a viewer and returns its output channel, which is
+// closed when the viewer falls behind or cancel is called.
+func (s *Share) Subscribe() (<-chan []byte, func()) {
+	ch := make(chan []byte, subscriberBuffer)
+
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	onJoin := s.onJoin
+	s.mu.Unlock()
+
+	if onJoin != nil {
+		onJoin()
+	}
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[ch]; ok {
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// Viewers returns the number of connected viewers.
+func (s *Share) Viewers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+// Size returns the terminal size, defaulting to 80x24 when unknown.
+func (s *Share) Size() Size {
+	if s.size != nil {
+		if size := s.size(); size.Cols > 0 && size.Rows > 0 {
+			return size
+		}
+	}
+	return Size{Cols: 80, Rows: 24}
+}
+
+// File is a terminal that also copies its output to a share. It keeps the
+// underlying *os.File's descriptor so bubbletea still detects a TTY and
+// window resizes.
+type File struct {
+	*os.File
+	share *Share
+}
+
+// Tee returns f with every write also sent to s.
+func Tee(f *os.File, s *Share) *File {
+	return &File{File: f, share: s}
+}
+
+// Write writes p to the terminal and then to viewers.
+func (f *File) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	if n > 0 {
+		f.share.Write(p[:n])
+	}
+	return n, err
+}
+
+// TerminalSize returns a size func that reports f's current dimensions.
+func TerminalSize(f *os.File) func() Size {
+	return func() Size {
+		cols, rows, err := term.GetSize(int(f.Fd()))
+		if err != nil {
+			return Size{}
+		}
+		return Size{Cols: cols, Rows: rows}
+	}
+}
@@ -0,0 +1,60 @@
+package termshare
+
+import (
+	"testing"
+)
+
+func TestShareBroadcastsToViewers(t *testing.T) {
+	joins := 0
+	share := New(nil, func() { joins++ })
+
+	out, cancel := share.Subscribe()
+	defer cancel()
+	if joins != 1 {
+		t.Fatalf("expected join hook to run once, got %d", joins)
+	}
+
+	share.Write([]byte("\x1b[2Jhello"))
+	if got := string(<-out); got != "\x1b[2Jhello" {
+		t.Fatalf("expected written bytes, got %q", got)
+	}
+
+	cancel()
+	if _, ok := <-out; ok {
+		t.Fatalf("expected channel to be closed after cancel")
+	}
+	if share.Viewers() != 0 {
+		t.Fatalf("expected no viewers, got %d", share.Viewers())
+	}
+}
+
+func TestShareDropsSlowViewers(t *testing.T) {
+	share := New(nil, nil)
+	out, cancel := share.Subscribe()
+	defer cancel()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		share.Write([]byte("x"))
+	}
+	if share.Viewers() != 0 {
+		t.Fatalf("expected slow viewer to be dropped")
+	}
+
+	n := 0
+	for range out {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Fatalf("expected %d buffered writes, got %d", subscriberBuffer, n)
+	}
+}
+
+func TestShareSizeDefault(t *testing.T) {
+	if got := New(nil, nil).Size(); got != (Size{Cols: 80, Rows: 24}) {
+		t.Fatalf("expected 80x24 default, got %+v", got)
+	}
+	share := New(func() Size { return Size{Cols: 200, Rows: 50} }, nil)
+	if got := share.Size(); got.Cols != 200 || got.Rows != 50 {
+		t.Fatalf("expected reported size, got %+v", got)
+	}
+}
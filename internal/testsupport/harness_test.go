@@ -0,0 +1,181 @@
+package testsupport_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/proxy"
+	"github.com/jaxxstorm/portal/internal/tailscale"
+	"github.com/jaxxstorm/portal/internal/testsupport"
+)
+
+// startProxy serves a proxy for upstream on a loopback port, the way the
+// local daemon mode does, and returns the server and its port.
+func startProxy(t *testing.T, config proxy.Config) (*proxy.Server, int) {
+	t.Helper()
+	config.UseTUI = true
+	config.Logger = zap.NewNop()
+	server := proxy.NewServer(config)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := &http.Server{Handler: server}
+	server.ConfigureHTTPServer(httpServer)
+	go httpServer.Serve(ln)
+	t.Cleanup(func() { httpServer.Close() })
+	return server, ln.Addr().(*net.TCPAddr).Port
+}
+
+func get(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestLocalDaemonServesThroughProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello from "+r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	server, proxyPort := startProxy(t, proxy.Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+	})
+
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	client := tailscale.NewClientWithLocal(daemon, zap.NewNop())
+	info, err := client.SetupServe(context.Background(), tailscale.Config{ProxyPort: proxyPort})
+	if err != nil {
+		t.Fatalf("SetupServe: %v", err)
+	}
+	if info.URL != "http://dev-box.example.ts.net/" {
+		t.Fatalf("unexpected service URL %q", info.URL)
+	}
+
+	// Follow the serve config the way tailscaled would.
+	target, err := daemon.ProxyTarget(80, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := get(t, target+"/widgets"); body != "hello from /widgets" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	logs := server.GetRequestLogs()
+	if len(logs) != 1 || logs[0].URL != "/widgets" || logs[0].StatusCode != http.StatusOK {
+		t.Fatalf("expected the request to be captured, got %+v", logs)
+	}
+}
+
+func TestTSNetFunnelServesThroughProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("Tailscale-Client-IP"))
+	}))
+	defer upstream.Close()
+
+	server := proxy.NewServer(proxy.Config{
+		TargetPort: upstream.Listener.Addr().(*net.TCPAddr).Port,
+		Mode:       model.ModeProxy,
+		UseTUI:     true,
+		Logger:     zap.NewNop(),
+	})
+
+	node := testsupport.NewFakeNode("portal.example.ts.net")
+	node.GrantFunnel()
+	node.FunnelSource = netip.MustParseAddrPort("203.0.113.10:44444")
+	tsnetServer := tailscale.NewTSNetServerWithNode(tailscale.TSNetConfig{
+		Hostname:     "portal",
+		EnableFunnel: true,
+		UseHTTPS:     true,
+	}, node, zap.NewNop())
+	defer tsnetServer.Close()
+
+	ready := make(chan tailscale.TSNetReadyInfo, 1)
+	tsnetServer.SetReadyCallback(func(info tailscale.TSNetReadyInfo) { ready <- info })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go tsnetServer.Serve(ctx, server)
+
+	addr, kind, err := node.WaitListener(ctx, ":443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind != testsupport.ListenFunnel {
+		t.Fatalf("expected a funnel listener, got %s", kind)
+	}
+	select {
+	case info := <-ready:
+		if info.ServiceURL != "https://portal.example.ts.net" {
+			t.Fatalf("unexpected service URL %q", info.ServiceURL)
+		}
+	case <-ctx.Done():
+		t.Fatal("tsnet server never became ready")
+	}
+
+	if body := get(t, "http://"+addr+"/hook"); body != "203.0.113.10" {
+		t.Fatalf("expected the funnel client IP to reach the upstream, got %q", body)
+	}
+	if logs := server.GetRequestLogs(); len(logs) != 1 || logs[0].URL != "/hook" {
+		t.Fatalf("expected the request to be captured, got %+v", logs)
+	}
+}
+
+func TestTSNetFunnelRequiresAttribute(t *testing.T) {
+	node := testsupport.NewFakeNode("portal.example.ts.net")
+	tsnetServer := tailscale.NewTSNetServerWithNode(tailscale.TSNetConfig{
+		Hostname:     "portal",
+		EnableFunnel: true,
+		UseHTTPS:     true,
+	}, node, zap.NewNop())
+	defer tsnetServer.Close()
+
+	err := tsnetServer.Serve(context.Background(), http.NotFoundHandler())
+	if err == nil || !strings.Contains(err.Error(), "funnel") {
+		t.Fatalf("expected funnel listen to fail, got %v", err)
+	}
+	if node.IsUp() {
+		t.Fatal("expected the node not to come up")
+	}
+}
+
+func TestTailnetPeerTargetThroughProxy(t *testing.T) {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "from build-box")
+	}))
+	defer peer.Close()
+
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	daemon.AddPeer("build-box", netip.MustParseAddr("100.64.0.7"), 3000, peer.Listener.Addr().String())
+	client := tailscale.NewClientWithLocal(daemon, zap.NewNop())
+
+	server, proxyPort := startProxy(t, proxy.Config{
+		TargetHost: "build-box",
+		TargetPort: 3000,
+		Mode:       model.ModeProxy,
+	})
+	server.SetUpstreamDialer(client.DialPeer)
+
+	if body := get(t, "http://127.0.0.1:"+strconv.Itoa(proxyPort)+"/"); body != "from build-box" {
+		t.Fatalf("expected the peer's response, got %q", body)
+	}
+}
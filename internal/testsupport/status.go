@@ -0,0 +1,152 @@
+// internal/testsupport/status.go
+
+// Package testsupport provides in-memory stand-ins for tailscaled and tsnet
+// so serve, funnel and proxy setup can be exercised end to end in tests
+// without a tailnet. FakeLocalClient satisfies tailscale.LocalClient and
+// FakeNode satisfies tailscale.Node.
+package testsupport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
+	"tailscale.com/types/views"
+)
+
+// SelfIP is the Tailscale address the fakes report for the local node.
+var SelfIP = netip.MustParseAddr("100.64.0.1")
+
+// tailnet is the node status shared by the fakes, along with the local
+// addresses that stand in for tailnet peers.
+type tailnet struct {
+	mu     sync.Mutex
+	status *ipnstate.Status
+	// peers maps a peer's "ip:port" to the local address dialed instead.
+	peers map[string]string
+	// certRequests lists the domains CertPair was asked for.
+	certRequests []string
+}
+
+func newTailnet(dnsName string) tailnet {
+	dnsName = strings.TrimSuffix(dnsName, ".")
+	_, suffix, _ := strings.Cut(dnsName, ".")
+	return tailnet{
+		status: &ipnstate.Status{
+			Version:        "fake",
+			BackendState:   "Running",
+			MagicDNSSuffix: suffix,
+			CurrentTailnet: &ipnstate.TailnetStatus{
+				Name:            suffix,
+				MagicDNSSuffix:  suffix,
+				MagicDNSEnabled: true,
+			},
+			Self: &ipnstate.PeerStatus{
+				HostName:     strings.SplitN(dnsName, ".", 2)[0],
+				DNSName:      dnsName + ".",
+				TailscaleIPs: []netip.Addr{SelfIP},
+				Online:       true,
+				CapMap:       tailcfg.NodeCapMap{},
+			},
+			Peer: map[key.NodePublic]*ipnstate.PeerStatus{},
+		},
+		peers: map[string]string{},
+	}
+}
+
+// GrantHTTPS gives the node the HTTPS capability and a certificate for its
+// DNS name, as when HTTPS is enabled for the tailnet.
+func (t *tailnet) GrantHTTPS() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Self.CapMap[tailcfg.CapabilityHTTPS] = nil
+	t.status.CertDomains = []string{strings.TrimSuffix(t.status.Self.DNSName, ".")}
+}
+
+// CertPair stands in for fetching the node's HTTPS certificate. It
+// succeeds for the domains GrantHTTPS issued certificates for.
+func (t *tailnet) CertPair(ctx context.Context, domain string) ([]byte, []byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.certRequests = append(t.certRequests, domain)
+	if !slices.Contains(t.status.CertDomains, domain) {
+		return nil, nil, fmt.Errorf("fake tailnet: no certificate for %s", domain)
+	}
+	return []byte("fake certificate"), []byte("fake key"), nil
+}
+
+// CertRequests returns the domains certificates were requested for.
+func (t *tailnet) CertRequests() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.certRequests)
+}
+
+// GrantFunnel gives the node the Funnel node attribute.
+func (t *tailnet) GrantFunnel() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Self.CapMap[tailcfg.NodeAttrFunnel] = nil
+}
+
+// SetTags sets the node's ACL tags.
+func (t *tailnet) SetTags(tags ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	view := views.SliceOf(tags)
+	t.status.Self.Tags = &view
+}
+
+// AddPeer adds an online tailnet machine named name at ip. Dials to ip:port
+// connect to target instead, usually an httptest server's address.
+func (t *tailnet) AddPeer(name string, ip netip.Addr, port uint16, target string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, suffix, _ := strings.Cut(strings.TrimSuffix(t.status.Self.DNSName, "."), ".")
+	t.status.Peer[key.NewNode().Public()] = &ipnstate.PeerStatus{
+		HostName:     name,
+		DNSName:      name + "." + suffix + ".",
+		TailscaleIPs: []netip.Addr{ip},
+		Online:       true,
+	}
+	t.peers[net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))] = target
+}
+
+// Status returns a copy of the node status.
+func (t *tailnet) Status(ctx context.Context) (*ipnstate.Status, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := *t.status
+	self := *t.status.Self
+	status.Self = &self
+	return &status, nil
+}
+
+// hostPort is the serve config key for the node's DNS name and port.
+func (t *tailnet) hostPort(port uint16) ipn.HostPort {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dnsName := strings.TrimSuffix(t.status.Self.DNSName, ".")
+	return ipn.HostPort(net.JoinHostPort(dnsName, strconv.Itoa(int(port))))
+}
+
+// dialPeer connects to the local stand-in for the peer at addr.
+func (t *tailnet) dialPeer(ctx context.Context, network, addr string) (net.Conn, error) {
+	t.mu.Lock()
+	target, ok := t.peers[addr]
+	t.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("fake tailnet: nothing listening on %s", addr)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, target)
+}
@@ -0,0 +1,172 @@
+// internal/testsupport/tailscaled.go
+package testsupport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+)
+
+// FakeLocalClient is an in-memory tailscaled LocalAPI. It keeps the serve
+// config and prefs that portal writes so tests can inspect them, and routes
+// serve handlers to their local proxy targets.
+type FakeLocalClient struct {
+	tailnet
+
+	serveMu sync.Mutex
+	serve   *ipn.ServeConfig
+	prefs   *ipn.Prefs
+	// setServeCalls counts SetServeConfig calls.
+	setServeCalls int
+	// err, when set, is returned by every call, as when tailscaled isn't
+	// running.
+	err error
+}
+
+// NewFakeLocalClient returns a running node named dnsName (for example
+// "dev-box.example.ts.net") with an empty serve config.
+func NewFakeLocalClient(dnsName string) *FakeLocalClient {
+	return &FakeLocalClient{
+		tailnet: newTailnet(dnsName),
+		serve:   &ipn.ServeConfig{},
+		prefs:   &ipn.Prefs{},
+	}
+}
+
+// SetErr makes every call fail with err; nil restores normal behaviour.
+func (f *FakeLocalClient) SetErr(err error) {
+	f.serveMu.Lock()
+	defer f.serveMu.Unlock()
+	f.err = err
+}
+
+func (f *FakeLocalClient) failure() error {
+	f.serveMu.Lock()
+	defer f.serveMu.Unlock()
+	return f.err
+}
+
+// Status implements tailscale.LocalClient.
+func (f *FakeLocalClient) Status(ctx context.Context) (*ipnstate.Status, error) {
+	if err := f.failure(); err != nil {
+		return nil, err
+	}
+	return f.tailnet.Status(ctx)
+}
+
+// CertPair implements tailscale.LocalClient.
+func (f *FakeLocalClient) CertPair(ctx context.Context, domain string) ([]byte, []byte, error) {
+	if err := f.failure(); err != nil {
+		return nil, nil, err
+	}
+	return f.tailnet.CertPair(ctx, domain)
+}
+
+// GetServeConfig implements tailscale.LocalClient.
+func (f *FakeLocalClient) GetServeConfig(ctx context.Context) (*ipn.ServeConfig, error) {
+	if err := f.failure(); err != nil {
+		return nil, err
+	}
+	return f.ServeConfig(), nil
+}
+
+// SetServeConfig implements tailscale.LocalClient.
+func (f *FakeLocalClient) SetServeConfig(ctx context.Context, config *ipn.ServeConfig) error {
+	if err := f.failure(); err != nil {
+		return err
+	}
+	f.serveMu.Lock()
+	defer f.serveMu.Unlock()
+	f.serve = config.Clone()
+	if f.serve == nil {
+		f.serve = &ipn.ServeConfig{}
+	}
+	f.setServeCalls++
+	return nil
+}
+
+// GetPrefs implements tailscale.LocalClient.
+func (f *FakeLocalClient) GetPrefs(ctx context.Context) (*ipn.Prefs, error) {
+	if err := f.failure(); err != nil {
+		return nil, err
+	}
+	f.serveMu.Lock()
+	defer f.serveMu.Unlock()
+	return f.prefs.Clone(), nil
+}
+
+// EditPrefs implements tailscale.LocalClient. Only the fields portal edits
+// are applied.
+func (f *FakeLocalClient) EditPrefs(ctx context.Context, prefs *ipn.MaskedPrefs) (*ipn.Prefs, error) {
+	if err := f.failure(); err != nil {
+		return nil, err
+	}
+	f.serveMu.Lock()
+	defer f.serveMu.Unlock()
+	if prefs.AdvertiseServicesSet {
+		f.prefs.AdvertiseServices = append([]string(nil), prefs.AdvertiseServices...)
+	}
+	return f.prefs.Clone(), nil
+}
+
+// UserDial implements tailscale.LocalClient, connecting to the stand-in for
+// a peer added with AddPeer.
+func (f *FakeLocalClient) UserDial(ctx context.Context, network, host string, port uint16) (net.Conn, error) {
+	if err := f.failure(); err != nil {
+		return nil, err
+	}
+	return f.dialPeer(ctx, network, net.JoinHostPort(host, strconv.Itoa(int(port))))
+}
+
+// ServeConfig returns a copy of the current serve config.
+func (f *FakeLocalClient) ServeConfig() *ipn.ServeConfig {
+	f.serveMu.Lock()
+	defer f.serveMu.Unlock()
+	return f.serve.Clone()
+}
+
+// SetServeCalls reports how many times the serve config was written.
+func (f *FakeLocalClient) SetServeCalls() int {
+	f.serveMu.Lock()
+	defer f.serveMu.Unlock()
+	return f.setServeCalls
+}
+
+// ProxyTarget returns the proxy URL of the web handler serving mountPath on
+// port, the local address tailscaled would forward tailnet requests to.
+func (f *FakeLocalClient) ProxyTarget(port uint16, mountPath string) (string, error) {
+	hostPort := f.hostPort(port)
+
+	f.serveMu.Lock()
+	defer f.serveMu.Unlock()
+	web := f.serve.Web[hostPort]
+	if web == nil || web.Handlers[mountPath] == nil {
+		return "", fmt.Errorf("fake tailscaled: no web handler for %s%s", hostPort, mountPath)
+	}
+	return web.Handlers[mountPath].Proxy, nil
+}
+
+// FunnelEnabled reports whether Funnel is on for port.
+func (f *FakeLocalClient) FunnelEnabled(port uint16) bool {
+	hostPort := f.hostPort(port)
+
+	f.serveMu.Lock()
+	defer f.serveMu.Unlock()
+	return f.serve.AllowFunnel[hostPort]
+}
+
+// TCPForward returns the local address a TCP handler on port forwards to.
+func (f *FakeLocalClient) TCPForward(port uint16) (string, bool) {
+	f.serveMu.Lock()
+	defer f.serveMu.Unlock()
+	handler := f.serve.TCP[port]
+	if handler == nil || handler.TCPForward == "" {
+		return "", false
+	}
+	return handler.TCPForward, true
+}
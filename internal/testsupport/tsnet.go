@@ -0,0 +1,196 @@
+// internal/testsupport/tsnet.go
+package testsupport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+	"tailscale.com/tsnet"
+)
+
+// FakeNode is an in-memory tsnet node. Its listeners are plain TCP
+// listeners on 127.0.0.1 (without TLS, even for ListenTLS and ListenFunnel),
+// so tests can reach what a TSNetServer serves with an ordinary HTTP client.
+type FakeNode struct {
+	tailnet
+
+	// UpErr, when set, is returned by Up, as when the node can't log in.
+	UpErr error
+	// FunnelSource, when valid, marks connections accepted by ListenFunnel
+	// as Funnel traffic from that address, the way tsnet wraps them.
+	FunnelSource netip.AddrPort
+
+	listenMu  sync.Mutex
+	listeners map[string]*fakeListener
+	changed   chan struct{}
+	up        bool
+	closed    bool
+}
+
+// fakeListener records how a tailnet address was listened on.
+type fakeListener struct {
+	net.Listener
+	kind string
+}
+
+// Listener kinds reported by FakeNode.Listener.
+const (
+	ListenPlain   = "plain"
+	ListenTLS     = "tls"
+	ListenFunnel  = "funnel"
+	ListenService = "service"
+)
+
+// NewFakeNode returns a node that comes up as dnsName.
+func NewFakeNode(dnsName string) *FakeNode {
+	return &FakeNode{
+		tailnet:   newTailnet(dnsName),
+		listeners: map[string]*fakeListener{},
+		changed:   make(chan struct{}),
+	}
+}
+
+// Up implements tailscale.Node.
+func (n *FakeNode) Up(ctx context.Context) (*ipnstate.Status, error) {
+	if n.UpErr != nil {
+		return nil, n.UpErr
+	}
+	n.listenMu.Lock()
+	n.up = true
+	n.listenMu.Unlock()
+	return n.tailnet.Status(ctx)
+}
+
+// Listen implements tailscale.Node.
+func (n *FakeNode) Listen(network, addr string) (net.Listener, error) {
+	return n.listen(ListenPlain, addr, nil)
+}
+
+// ListenTLS implements tailscale.Node.
+func (n *FakeNode) ListenTLS(network, addr string) (net.Listener, error) {
+	return n.listen(ListenTLS, addr, nil)
+}
+
+// ListenFunnel implements tailscale.Node. Like tsnet, it needs the Funnel
+// node attribute.
+func (n *FakeNode) ListenFunnel(network, addr string) (net.Listener, error) {
+	status, _ := n.tailnet.Status(context.Background())
+	if !status.Self.HasCap(tailcfg.NodeAttrFunnel) {
+		return nil, errors.New("fake tsnet: Funnel not available; node lacks the funnel attribute")
+	}
+	return n.listen(ListenFunnel, addr, func(conn net.Conn) net.Conn {
+		if !n.FunnelSource.IsValid() {
+			return conn
+		}
+		return &ipn.FunnelConn{Conn: conn, Src: n.FunnelSource}
+	})
+}
+
+// ListenService implements tailscale.Node. The service's FQDN is its name
+// under the node's MagicDNS suffix; tagged identity is required as with
+// tsnet.
+func (n *FakeNode) ListenService(name string, mode tsnet.ServiceMode) (*tsnet.ServiceListener, error) {
+	status, _ := n.tailnet.Status(context.Background())
+	if status.Self.Tags == nil || status.Self.Tags.Len() == 0 {
+		return nil, errors.New("fake tsnet: service hosts must be tagged")
+	}
+	ln, err := n.listen(ListenService, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	fqdn := strings.TrimPrefix(name, "svc:") + "." + status.MagicDNSSuffix
+	return &tsnet.ServiceListener{Listener: ln, FQDN: fqdn}, nil
+}
+
+// Status implements tailscale.Node.
+func (n *FakeNode) Status(ctx context.Context) (*ipnstate.Status, error) {
+	return n.tailnet.Status(ctx)
+}
+
+// Dial implements tailscale.Node, connecting to the stand-in for a peer
+// added with AddPeer.
+func (n *FakeNode) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	return n.dialPeer(ctx, network, addr)
+}
+
+// Close implements tailscale.Node, closing every listener.
+func (n *FakeNode) Close() error {
+	n.listenMu.Lock()
+	defer n.listenMu.Unlock()
+	n.closed = true
+	for _, ln := range n.listeners {
+		ln.Close()
+	}
+	return nil
+}
+
+func (n *FakeNode) listen(kind, addr string, wrap func(net.Conn) net.Conn) (net.Listener, error) {
+	n.listenMu.Lock()
+	defer n.listenMu.Unlock()
+	if n.closed {
+		return nil, errors.New("fake tsnet: node closed")
+	}
+	if _, ok := n.listeners[addr]; ok {
+		return nil, fmt.Errorf("fake tsnet: %s already in use", addr)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	fake := &fakeListener{Listener: ln, kind: kind}
+	var result net.Listener = fake
+	if wrap != nil {
+		result = &wrappingListener{Listener: fake, wrap: wrap}
+	}
+	n.listeners[addr] = fake
+	close(n.changed)
+	n.changed = make(chan struct{})
+	return result, nil
+}
+
+// WaitListener waits until addr (":443", or a service name) is listened on
+// and returns the local address serving it and the listener kind.
+func (n *FakeNode) WaitListener(ctx context.Context, addr string) (string, string, error) {
+	for {
+		n.listenMu.Lock()
+		ln, ok := n.listeners[addr]
+		changed := n.changed
+		n.listenMu.Unlock()
+		if ok {
+			return ln.Addr().String(), ln.kind, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return "", "", fmt.Errorf("fake tsnet: nothing listened on %s: %w", addr, ctx.Err())
+		}
+	}
+}
+
+// IsUp reports whether Up has succeeded.
+func (n *FakeNode) IsUp() bool {
+	n.listenMu.Lock()
+	defer n.listenMu.Unlock()
+	return n.up
+}
+
+type wrappingListener struct {
+	net.Listener
+	wrap func(net.Conn) net.Conn
+}
+
+func (l *wrappingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return l.wrap(conn), nil
+}
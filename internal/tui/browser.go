@@ -0,0 +1,48 @@
+package tui
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openedMsg reports the result of opening a URL in the browser.
+type openedMsg struct {
+	url string
+	err error
+}
+
+// startBrowser opens url in the default browser without waiting for it.
+// Tests replace it.
+var startBrowser = func(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		// start would need the URL quoted for cmd.exe; rundll32 takes it as is.
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// openInBrowser opens the named URL, e.g. the service URL, reporting in the
+// logs pane when it isn't known yet.
+func (m *Model) openInBrowser(name, url string) tea.Cmd {
+	if url == "" {
+		m.appendLog(LogMsg{Level: "WARN", Message: fmt.Sprintf("The %s URL is not available yet", name), Time: time.Now()})
+		return nil
+	}
+	return func() tea.Msg {
+		return openedMsg{url: url, err: startBrowser(url)}
+	}
+}
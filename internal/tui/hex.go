@@ -0,0 +1,96 @@
+// internal/tui/hex.go
+package tui
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// hexBody is a binary request or response body the hex view can show.
+type hexBody struct {
+	label string
+	data  []byte
+	size  int64
+}
+
+// hexBodies lists the binary bodies of the request shown in the hex view,
+// request first.
+func (m *Model) hexBodies() []hexBody {
+	log := m.hexRequest
+	if log == nil {
+		return nil
+	}
+	var bodies []hexBody
+	if len(log.BodyBytes) > 0 {
+		bodies = append(bodies, hexBody{label: "Request body", data: log.BodyBytes, size: log.Size})
+	}
+	if len(log.Response.BodyBytes) > 0 {
+		bodies = append(bodies, hexBody{label: "Response body", data: log.Response.BodyBytes, size: log.Response.Size})
+	}
+	return bodies
+}
+
+// toggleHexView opens the hex view on the last request and steps through
+// its binary bodies, then goes back to the dashboard. The request stays
+// pinned while newer requests arrive.
+func (m *Model) toggleHexView() {
+	m.hexPage = 0
+	if m.hexView == 0 {
+		m.hexRequest = m.lastRequest
+		if len(m.hexBodies()) == 0 {
+			m.appendLog(LogMsg{Level: "INFO", Message: "The last request has no binary body to show", Time: time.Now()})
+			return
+		}
+	}
+	if m.hexView < len(m.hexBodies()) {
+		m.hexView++
+		return
+	}
+	m.hexView = 0
+}
+
+// hexPageLines is how many 16-byte rows fit on one page of the hex view.
+func (m *Model) hexPageLines() int {
+	return maxInt(m.height-6, 4)
+}
+
+// pageHexView moves the hex view by delta pages, staying within the body.
+func (m *Model) pageHexView(delta int) {
+	bodies := m.hexBodies()
+	if m.hexView == 0 || m.hexView > len(bodies) {
+		return
+	}
+	rows := (len(bodies[m.hexView-1].data) + 15) / 16
+	pages := maxInt((rows+m.hexPageLines()-1)/m.hexPageLines(), 1)
+	m.hexPage = min(max(m.hexPage+delta, 0), pages-1)
+}
+
+// renderHexSection shows one page of a hex and ASCII dump of a binary body,
+// replacing the dashboard until 'x' steps past the last body.
+func (m *Model) renderHexSection() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+	dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+
+	body := m.hexBodies()[m.hexView-1]
+
+	lines := strings.Split(strings.TrimSuffix(hex.Dump(body.data), "\n"), "\n")
+	pageLines := m.hexPageLines()
+	pages := maxInt((len(lines)+pageLines-1)/pageLines, 1)
+	start := m.hexPage * pageLines
+	end := min(start+pageLines, len(lines))
+
+	title := fmt.Sprintf("%s of %s %s (%d bytes)", body.label, m.hexRequest.Method, m.hexRequest.URL, body.size)
+	footer := fmt.Sprintf("Page %d/%d | PgUp/PgDn to page | 'x' next body or back", m.hexPage+1, pages)
+	if int64(len(body.data)) < body.size {
+		footer = fmt.Sprintf("First %d bytes kept | ", len(body.data)) + footer
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render(truncateString(title, maxInt(m.width, 20))),
+		strings.Join(lines[start:end], "\n"),
+		dimStyle.Render(footer),
+	)
+}
@@ -0,0 +1,66 @@
+package tui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestHexViewPagesThroughBinaryBodies(t *testing.T) {
+	m := NewModel(&stubStatsProvider{}, Options{})
+	resizeModel(t, &m, 140, 40)
+
+	body := append([]byte{0x89, 'P', 'N', 'G'}, bytes.Repeat([]byte{0}, 4096)...)
+	updateModel(t, &m, RequestMsg{Log: model.RequestLog{
+		ID:       "req_1",
+		Method:   "GET",
+		URL:      "/logo.png",
+		Response: model.ResponseLog{StatusCode: 200, BodyBytes: body, Size: 10000},
+	}})
+
+	x := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}}
+	updateModel(t, &m, x)
+	view := ansi.Strip(m.View())
+	if !strings.Contains(view, "Response body of GET /logo.png") || !strings.Contains(view, "00000000  89 50 4e 47") || !strings.Contains(view, "|.PNG") {
+		t.Fatalf("expected hex dump of the response body, got %q", view)
+	}
+	if !strings.Contains(view, "First 4100 bytes kept") || !strings.Contains(view, "Page 1/") {
+		t.Fatalf("expected truncation note and page count, got %q", view)
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyPgDown})
+	view = ansi.Strip(m.View())
+	if !strings.Contains(view, "Page 2/") || strings.Contains(view, "00000000  89 50") {
+		t.Fatalf("expected second page, got %q", view)
+	}
+
+	// A newer request doesn't move the view off the pinned one.
+	updateModel(t, &m, RequestMsg{Log: model.RequestLog{ID: "req_2", Method: "GET", URL: "/"}})
+	if !strings.Contains(ansi.Strip(m.View()), "/logo.png") {
+		t.Fatalf("expected hex view to stay on the pinned request")
+	}
+
+	updateModel(t, &m, x)
+	if strings.Contains(ansi.Strip(m.View()), "Response body of") {
+		t.Fatalf("expected 'x' past the last body to return to the dashboard")
+	}
+}
+
+func TestHexViewWithoutBinaryBody(t *testing.T) {
+	m := NewModel(&stubStatsProvider{}, Options{})
+	resizeModel(t, &m, 140, 40)
+	updateModel(t, &m, RequestMsg{Log: model.RequestLog{ID: "req_1", Method: "POST", URL: "/", Body: "{}"}})
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	if m.hexView != 0 {
+		t.Fatalf("expected hex view to stay closed for a text body")
+	}
+	if last := m.logs.entries[len(m.logs.entries)-1]; !strings.Contains(last.Message, "no binary body") {
+		t.Fatalf("expected a log line explaining why, got %q", last.Message)
+	}
+}
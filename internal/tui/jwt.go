@@ -0,0 +1,52 @@
+// internal/tui/jwt.go
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// jwtSummaryClaims are the claims shown on the JWT line, in order, when the
+// token carries them.
+var jwtSummaryClaims = []string{"sub", "iss", "aud"}
+
+// renderJWTSummary describes a decoded bearer token in one line for the
+// headers pane: its identifying claims, expiry and signature status.
+func renderJWTSummary(info *model.JWT, now time.Time) string {
+	parts := []string{}
+	for _, claim := range jwtSummaryClaims {
+		if v, ok := info.Claims[claim]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%v", claim, v))
+		}
+	}
+
+	expiry := "no exp"
+	expiryStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	if info.ExpiresAt != nil {
+		left := info.ExpiresAt.Sub(now).Round(time.Second)
+		expiry = "exp in " + left.String()
+		if info.Expired {
+			expiry = fmt.Sprintf("EXPIRED %s ago", (-left).String())
+			expiryStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
+		}
+	}
+	parts = append(parts, expiryStyle.Render(expiry))
+
+	switch info.Signature {
+	case model.JWTValid:
+		parts = append(parts, lipgloss.NewStyle().Foreground(lipgloss.Color("46")).Render("signature valid"))
+	case model.JWTInvalid:
+		parts = append(parts, lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render("signature invalid: "+info.SignatureError))
+	default:
+		parts = append(parts, lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("signature not verified"))
+	}
+
+	if alg, ok := info.Header["alg"].(string); ok {
+		parts = append(parts, "alg="+alg)
+	}
+	return lipgloss.NewStyle().Bold(true).Render("JWT:") + " " + strings.Join(parts, " · ")
+}
@@ -0,0 +1,48 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestHeadersPaneShowsJWT(t *testing.T) {
+	m := NewModel(&stubStatsProvider{}, Options{})
+	resizeModel(t, &m, 160, 40)
+
+	expired := time.Now().Add(-5 * time.Minute)
+	updateModel(t, &m, RequestMsg{Log: model.RequestLog{
+		ID:      "req_1",
+		Method:  "GET",
+		URL:     "/api",
+		Headers: model.HeadersFromMap(map[string]string{"Authorization": "Bearer a.b.c"}),
+		JWT: &model.JWT{
+			Header:         map[string]any{"alg": "RS256"},
+			Claims:         map[string]any{"sub": "user-1"},
+			ExpiresAt:      &expired,
+			Expired:        true,
+			Signature:      model.JWTInvalid,
+			SignatureError: "signature does not match",
+		},
+	}})
+
+	view := ansi.Strip(m.View())
+	for _, want := range []string{"JWT: sub=user-1", "EXPIRED 5m", "signature invalid: signature does not match", "alg=RS256"} {
+		if !strings.Contains(view, want) {
+			t.Fatalf("expected %q in view, got %q", want, view)
+		}
+	}
+}
+
+func TestRenderJWTSummaryUnexpired(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	exp := now.Add(90 * time.Second)
+	line := ansi.Strip(renderJWTSummary(&model.JWT{ExpiresAt: &exp, Signature: model.JWTValid}, now))
+	if line != "JWT: exp in 1m30s · signature valid" {
+		t.Fatalf("unexpected summary %q", line)
+	}
+}
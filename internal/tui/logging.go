@@ -1,7 +1,10 @@
 package tui
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -88,220 +91,124 @@ func (l *TUIOnlyLogger) Fatalf(format string, args ...interface{}) {
 	})
 }
 
-// logWithFields formats structured log fields into a TUI-friendly message
+// logWithFields sends a structured log entry to the TUI. Errors carry the
+// caller's stack trace so the log pane can show it when expanded.
 func (l *TUIOnlyLogger) logWithFields(level, msg string, fields ...zap.Field) {
-	message := msg
-
-	// Convert zap fields to key=value pairs for TUI display
-	if len(fields) > 0 {
-		var parts []string
-		for _, field := range fields {
-			switch field.Type {
-			case zapcore.StringType:
-				parts = append(parts, fmt.Sprintf("%s=%s", field.Key, field.String))
-			case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
-				parts = append(parts, fmt.Sprintf("%s=%d", field.Key, field.Integer))
-			case zapcore.BoolType:
-				value := "false"
-				if field.Integer == 1 {
-					value = "true"
-				}
-				parts = append(parts, fmt.Sprintf("%s=%s", field.Key, value))
-			case zapcore.DurationType:
-				parts = append(parts, fmt.Sprintf("%s=%s", field.Key, time.Duration(field.Integer).String()))
-			default:
-				// For other types, use the field's String method or convert appropriately
-				parts = append(parts, fmt.Sprintf("%s=%v", field.Key, field.Interface))
-			}
-		}
-		if len(parts) > 0 {
-			message = fmt.Sprintf("%s %s", msg, strings.Join(parts, " "))
-		}
-	}
-
-	l.program.Send(LogMsg{
+	entry := LogMsg{
 		Level:   level,
-		Message: message,
+		Message: msg,
 		Time:    time.Now(),
-	})
-}
-
-// CreateTUIZapLogger creates a zap logger that sends output to the TUI
-func CreateTUIZapLogger(program *tea.Program) *zap.Logger {
-	// Create a TUIOnlyLogger instance
-	tuiLogger := NewTUIOnlyLogger(program)
-
-	// Create a custom core that routes directly to TUIOnlyLogger
-	tuiCore := &tuiZapCore{tuiLogger: tuiLogger}
-
-	// Create logger with custom core
-	logger := zap.New(tuiCore)
-
-	return logger
-}
-
-// tuiZapWriter implements zapcore.WriteSyncer for sending zap logs to TUI
-type tuiZapWriter struct {
-	program *tea.Program
-}
-
-func (w *tuiZapWriter) Write(p []byte) (n int, err error) {
-	// Parse the zap log line and send to TUI
-	line := strings.TrimSpace(string(p))
-
-	// Skip empty lines
-	if line == "" {
-		return len(p), nil
-	}
-
-	level := "INFO"
-	message := line
-
-	// Try to parse zap console format: TIMESTAMP\tLEVEL\tMESSAGE[\tJSON_FIELDS]
-	if strings.Contains(line, "\t") {
-		parts := strings.Split(line, "\t")
-
-		if len(parts) >= 3 {
-			// Extract level (second part)
-			level = strings.ToUpper(strings.TrimSpace(parts[1]))
-			// Extract message (third part)
-			baseMessage := strings.TrimSpace(parts[2])
-
-			// For TUI, keep it simple - just use the base message and a few key fields
-			if len(parts) >= 4 {
-				jsonFields := strings.TrimSpace(parts[3])
-				// Only extract the most essential fields to keep messages short
-				essential := extractEssentialFields(jsonFields)
-				if essential != "" {
-					message = fmt.Sprintf("%s %s", baseMessage, essential)
-				} else {
-					message = baseMessage
-				}
-			} else {
-				message = baseMessage
-			}
-		}
-	} else {
-		// Handle non-tab formatted logs - these should be properly formatted
-		// Since our CreateTUIZapLogger uses ConsoleEncoder, all logs should come through
-		// in tab-separated format. If we reach here, something might be wrong with the encoder.
-
-		// Skip very short or empty lines to reduce noise
-		if len(strings.TrimSpace(line)) < 3 {
-			return len(p), nil
-		}
-
-		// Simple level detection for fallback
-		upperLine := strings.ToUpper(line)
-		if strings.Contains(upperLine, "ERROR") || strings.Contains(upperLine, "FAIL") {
-			level = "ERROR"
-		} else if strings.Contains(upperLine, "WARN") {
-			level = "WARN"
-		} else if strings.Contains(upperLine, "DEBUG") {
-			level = "DEBUG"
-		} else if strings.Contains(upperLine, "FATAL") {
-			level = "FATAL"
-		} else {
-			level = "INFO"
-		}
-
-		message = strings.TrimSpace(line)
+		Fields:  logFields(fields),
 	}
-
-	// Ensure message isn't too long for TUI display
-	if len(message) > 120 {
-		message = message[:117] + "..."
+	if level == "ERROR" || level == "FATAL" {
+		// Skip logWithFields and the level method.
+		entry.Stack = zap.StackSkip("", 2).String
 	}
-
-	w.program.Send(LogMsg{
-		Level:   level,
-		Message: message,
-		Time:    time.Now(),
-	})
-
-	return len(p), nil
+	l.program.Send(entry)
 }
 
-func (w *tuiZapWriter) Sync() error {
-	return nil
+// CreateTUIZapLogger creates a zap logger that sends output to the TUI
+func CreateTUIZapLogger(program *tea.Program) *zap.Logger {
+	return newTUIZapLogger(program.Send, zapcore.InfoLevel)
 }
 
 // CreateTUIOnlyZapLogger creates a zap logger that routes to TUI via TUIOnlyLogger.
 // When verbose is enabled, debug-level logs are emitted; otherwise INFO+ are emitted.
 func CreateTUIOnlyZapLogger(tuiLogger *TUIOnlyLogger, verbose bool) *zap.Logger {
-	// Create a custom writer that sends to TUI via the TUIOnlyLogger
-	tuiWriter := &tuiOnlyZapWriter{tuiLogger: tuiLogger}
 	level := zapcore.InfoLevel
 	if verbose {
 		level = zapcore.DebugLevel
 	}
+	return newTUIZapLogger(tuiLogger.program.Send, level)
+}
 
-	// Use the standard console encoder config from logging package for consistency
-	encoderConfig := logging.ConsoleEncoderConfig()
-
-	// Create logger with custom writer and consistent encoding
-	logger := zap.New(
-		zapcore.NewCore(
-			zapcore.NewConsoleEncoder(encoderConfig),
-			zapcore.AddSync(tuiWriter),
-			level,
-		),
-	)
+// newTUIZapLogger returns a zap logger whose entries are sent as LogMsgs,
+// with stack traces attached to errors.
+func newTUIZapLogger(send func(tea.Msg), level zapcore.Level) *zap.Logger {
+	return zap.New(NewZapCore(send, level), zap.AddStacktrace(zapcore.ErrorLevel))
+}
 
-	return logger
+// NewZapCore returns a zap core that sends each entry enabled by level to
+// send as a LogMsg, for loggers teed to a TUI in another process.
+func NewZapCore(send func(tea.Msg), level zapcore.LevelEnabler) zapcore.Core {
+	return &tuiZapCore{LevelEnabler: level, send: send}
 }
 
-// tuiOnlyZapWriter implements zapcore.WriteSyncer for sending zap logs to TUIOnlyLogger
-type tuiOnlyZapWriter struct {
-	tuiLogger *TUIOnlyLogger
+// tuiZapCore implements zapcore.Core by sending each entry to the TUI with
+// its fields intact, including those added with With.
+type tuiZapCore struct {
+	zapcore.LevelEnabler
+	send   func(tea.Msg)
+	fields []LogField
 }
 
-func (w *tuiOnlyZapWriter) Write(p []byte) (n int, err error) {
-	// Parse the zap log line and send to TUI via TUIOnlyLogger
-	line := strings.TrimSpace(string(p))
+func (c *tuiZapCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(slices.Clip(c.fields), logFields(fields)...)
+	return &clone
+}
 
-	// Skip empty lines
-	if line == "" {
-		return len(p), nil
+func (c *tuiZapCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
 	}
+	return checked
+}
 
-	// Simple parsing - just extract level and message
-	parts := strings.Split(line, "\t")
+func (c *tuiZapCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	c.send(LogMsg{
+		Level:   entry.Level.CapitalString(),
+		Message: entry.Message,
+		Time:    entry.Time,
+		Fields:  append(slices.Clip(c.fields), logFields(fields)...),
+		Stack:   entry.Stack,
+	})
+	return nil
+}
 
-	if len(parts) >= 3 {
-		level := strings.ToUpper(strings.TrimSpace(parts[1]))
-		message := strings.TrimSpace(parts[2])
+func (c *tuiZapCore) Sync() error {
+	return nil
+}
 
-		// If there are more parts, include them
-		if len(parts) > 3 {
-			message += " " + strings.Join(parts[3:], " ")
+// logFields renders zap fields for display, in the order they were logged.
+// Fields that encode to several keys, such as errors with a verbose form,
+// produce one LogField per key.
+func logFields(fields []zapcore.Field) []LogField {
+	var out []LogField
+	for _, field := range fields {
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+		keys := make([]string, 0, len(enc.Fields))
+		for key := range enc.Fields {
+			if key != field.Key {
+				keys = append(keys, key)
+			}
 		}
-
-		// Route to appropriate TUIOnlyLogger method based on level
-		switch level {
-		case "DEBUG":
-			w.tuiLogger.Debugf("%s", message)
-		case "INFO":
-			w.tuiLogger.Infof("%s", message)
-		case "WARN":
-			w.tuiLogger.Warnf("%s", message)
-		case "ERROR":
-			w.tuiLogger.Errorf("%s", message)
-		case "FATAL":
-			w.tuiLogger.Fatalf("%s", message)
-		default:
-			w.tuiLogger.Infof("%s", message)
+		sort.Strings(keys)
+		if _, ok := enc.Fields[field.Key]; ok {
+			keys = append([]string{field.Key}, keys...)
+		}
+		for _, key := range keys {
+			out = append(out, LogField{Key: key, Value: fieldValue(enc.Fields[key])})
 		}
-	} else {
-		// Fallback for malformed log lines
-		w.tuiLogger.Infof("%s", line)
 	}
-
-	return len(p), nil
+	return out
 }
 
-func (w *tuiOnlyZapWriter) Sync() error {
-	return nil
+// fieldValue formats a value stored by zapcore.MapObjectEncoder. Objects
+// and arrays are shown as JSON.
+func fieldValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]any, []any:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(v)
 }
 
 // Convenience methods for common logging scenarios using standard logging fields
@@ -375,242 +282,6 @@ func (l *TUIOnlyLogger) TailscaleServeSuccess() {
 	l.Info(logging.MsgTailscaleServeSuccess)
 }
 
-// parseIncomingRequest creates a detailed message from zap JSON fields using logging formatter
-func parseIncomingRequest(jsonFields string) string {
-	// Parse all available fields
-	method := extractJSONField(jsonFields, "method")
-	url := extractJSONField(jsonFields, "url")
-	remoteAddr := extractJSONField(jsonFields, "remote_addr")
-	userAgent := extractJSONField(jsonFields, "user_agent")
-	contentLength := extractJSONField(jsonFields, "content_length")
-	requestID := extractJSONField(jsonFields, "request_id")
-
-	// Use the logging formatter for consistent formatting
-	var clInt int64
-	if contentLength != "" {
-		fmt.Sscanf(contentLength, "%d", &clInt)
-	}
-
-	// Clean up user agent
-	cleanUserAgent := strings.Trim(userAgent, `"`)
-	if cleanUserAgent == "" || cleanUserAgent == `""` {
-		cleanUserAgent = ""
-	}
-
-	// Build detailed message with consistent formatting
-	var parts []string
-
-	if method != "" && url != "" {
-		parts = append(parts, fmt.Sprintf("method=%s url=%s", method, url))
-	}
-
-	if remoteAddr != "" {
-		parts = append(parts, fmt.Sprintf("remote_addr=%s", remoteAddr))
-	}
-
-	if cleanUserAgent != "" {
-		parts = append(parts, fmt.Sprintf("user_agent=%q", cleanUserAgent))
-	}
-
-	if clInt > 0 {
-		parts = append(parts, fmt.Sprintf("content_length=%d", clInt))
-	}
-
-	if requestID != "" {
-		requestID = strings.Trim(requestID, `"`)
-		parts = append(parts, fmt.Sprintf("request_id=%s", requestID))
-	}
-
-	if len(parts) > 0 {
-		return fmt.Sprintf("%s %s", logging.MsgIncomingRequest, strings.Join(parts, " "))
-	}
-
-	return logging.MsgIncomingRequest
-}
-
-// parseResponseSent creates a detailed message from zap JSON fields using logging constants
-func parseResponseSent(jsonFields string) string {
-	// Parse all available fields
-	statusCode := extractJSONField(jsonFields, "status_code")
-	responseSize := extractJSONField(jsonFields, "response_size")
-	duration := extractJSONField(jsonFields, "duration")
-	requestID := extractJSONField(jsonFields, "request_id")
-
-	// Build detailed message
-	var parts []string
-
-	if statusCode != "" {
-		parts = append(parts, fmt.Sprintf("status_code=%s", statusCode))
-	}
-
-	if responseSize != "" {
-		parts = append(parts, fmt.Sprintf("response_size=%s", responseSize))
-	}
-
-	if duration != "" {
-		duration = strings.Trim(duration, `"`)
-		parts = append(parts, fmt.Sprintf("duration=%s", duration))
-	}
-
-	if requestID != "" {
-		requestID = strings.Trim(requestID, `"`)
-		parts = append(parts, fmt.Sprintf("request_id=%s", requestID))
-	}
-
-	if len(parts) > 0 {
-		return fmt.Sprintf("%s %s", logging.MsgResponseSent, strings.Join(parts, " "))
-	}
-
-	return logging.MsgResponseSent
-}
-
-// extractJSONField extracts a field value from a JSON-like string
-func extractJSONField(jsonStr, fieldName string) string {
-	// Simple extraction for {"field": "value"} format
-	re := strings.NewReplacer(
-		`"`, `"`,
-		` `, ` `,
-	)
-	cleanStr := re.Replace(jsonStr)
-
-	// Find the field
-	startPattern := fmt.Sprintf(`"%s": `, fieldName)
-	startIdx := strings.Index(cleanStr, startPattern)
-	if startIdx == -1 {
-		return ""
-	}
-
-	startIdx += len(startPattern)
-	remaining := cleanStr[startIdx:]
-
-	// Find the end of the value
-	var endIdx int
-	if strings.HasPrefix(remaining, `"`) {
-		// String value - find closing quote
-		endIdx := strings.Index(remaining[1:], `"`)
-		if endIdx != -1 {
-			return remaining[1 : endIdx+1] // Extract without quotes
-		}
-	} else {
-		// Numeric value - find comma or end
-		endIdx = strings.IndexAny(remaining, ",}")
-		if endIdx == -1 {
-			endIdx = len(remaining)
-		}
-		return strings.TrimSpace(remaining[:endIdx])
-	}
-
-	return ""
-}
-
-// parseStructuredFields extracts key=value pairs from JSON fields for general log messages
-func parseStructuredFields(jsonFields string) string {
-	if jsonFields == "" || jsonFields == "{}" {
-		return ""
-	}
-
-	var parts []string
-
-	// Common fields to extract and format nicely - prioritize the most important ones
-	priorityFields := []string{
-		"component", "phase", "status", "operation", "error",
-		"proxy_port", "ui_port", "tailscale_port", "serve_port",
-		"url", "mount_path", "dns_name",
-	}
-
-	// Secondary fields
-	secondaryFields := []string{
-		"funnel_enabled", "https_enabled", "ui_enabled", "tui_enabled",
-		"port", "target_port", "server_mode", "bind_address", "local_port",
-		"hostname", "auth_key_provided", "tailscale_mode",
-	}
-
-	// Extract priority fields first
-	for _, fieldName := range priorityFields {
-		if value := extractJSONField(jsonFields, fieldName); value != "" {
-			value = cleanFieldValue(fieldName, value)
-			parts = append(parts, fmt.Sprintf("%s=%s", fieldName, value))
-		}
-	}
-
-	// Add secondary fields if we have room (limit total message length)
-	currentLength := len(strings.Join(parts, " "))
-	if currentLength < 80 { // Leave room for secondary fields
-		for _, fieldName := range secondaryFields {
-			if value := extractJSONField(jsonFields, fieldName); value != "" {
-				value = cleanFieldValue(fieldName, value)
-				newPart := fmt.Sprintf("%s=%s", fieldName, value)
-				if currentLength+len(newPart)+1 < 120 { // Keep total under 120 chars
-					parts = append(parts, newPart)
-					currentLength += len(newPart) + 1
-				} else {
-					break // Stop adding fields if getting too long
-				}
-			}
-		}
-	}
-
-	if len(parts) > 0 {
-		return strings.Join(parts, " ")
-	}
-
-	return ""
-}
-
-// cleanFieldValue cleans up field values for display
-func cleanFieldValue(fieldName, value string) string {
-	// Clean up boolean values
-	if value == "1" && (strings.Contains(fieldName, "enabled") || strings.Contains(fieldName, "provided")) {
-		return "true"
-	} else if value == "0" && (strings.Contains(fieldName, "enabled") || strings.Contains(fieldName, "provided")) {
-		return "false"
-	}
-
-	// Clean up quoted strings
-	value = strings.Trim(value, `"`)
-
-	// Truncate very long URLs
-	if fieldName == "url" && len(value) > 40 {
-		return value[:37] + "..."
-	}
-
-	return value
-}
-
-// extractEssentialFields extracts only the most important fields for TUI display
-func extractEssentialFields(jsonFields string) string {
-	if jsonFields == "" || jsonFields == "{}" {
-		return ""
-	}
-
-	var parts []string
-
-	// Only extract the most essential fields to keep messages concise
-	essentialFields := []string{
-		"component", "port", "proxy_port", "serve_port", "status", "error",
-		"method", "path", "remote_addr", "user_agent", "status_code", "duration", "response_size",
-	}
-
-	for _, fieldName := range essentialFields {
-		if value := extractJSONField(jsonFields, fieldName); value != "" {
-			value = strings.Trim(value, `"`)
-			// Keep it very short
-			if fieldName == "component" {
-				parts = append(parts, value) // Just the component name without key=
-			} else {
-				parts = append(parts, fmt.Sprintf("%s=%s", fieldName, value))
-			}
-		}
-	}
-
-	// Limit to first 3 fields to keep messages short
-	if len(parts) > 3 {
-		parts = parts[:3]
-	}
-
-	return strings.Join(parts, " ")
-}
-
 // CreateSimpleTUIZapLogger creates a zap logger that routes directly to TUIOnlyLogger without parsing
 func CreateSimpleTUIZapLogger(tuiLogger *TUIOnlyLogger) *zap.Logger {
 	// Create a custom writer that directly routes to TUIOnlyLogger
@@ -659,55 +330,3 @@ func (w *simpleTUIZapWriter) Write(p []byte) (n int, err error) {
 func (w *simpleTUIZapWriter) Sync() error {
 	return nil
 }
-
-// tuiZapCore implements zapcore.Core for direct TUIOnlyLogger integration
-type tuiZapCore struct {
-	tuiLogger *TUIOnlyLogger
-	level     zapcore.Level
-}
-
-func (c *tuiZapCore) Enabled(level zapcore.Level) bool {
-	return level >= zapcore.InfoLevel // Always show INFO and above
-}
-
-func (c *tuiZapCore) With(fields []zapcore.Field) zapcore.Core {
-	// For simplicity, return the same core
-	return c
-}
-
-func (c *tuiZapCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
-	if c.Enabled(entry.Level) {
-		return checked.AddCore(entry, c)
-	}
-	return checked
-}
-
-func (c *tuiZapCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
-	// Convert zapcore fields to zap fields
-	zapFields := make([]zap.Field, len(fields))
-	for i, field := range fields {
-		zapFields[i] = zap.Field(field)
-	}
-
-	// Route to appropriate TUIOnlyLogger method based on level
-	switch entry.Level {
-	case zapcore.DebugLevel:
-		c.tuiLogger.Debug(entry.Message, zapFields...)
-	case zapcore.InfoLevel:
-		c.tuiLogger.Info(entry.Message, zapFields...)
-	case zapcore.WarnLevel:
-		c.tuiLogger.Warn(entry.Message, zapFields...)
-	case zapcore.ErrorLevel:
-		c.tuiLogger.Error(entry.Message, zapFields...)
-	case zapcore.FatalLevel:
-		c.tuiLogger.Fatal(entry.Message, zapFields...)
-	default:
-		c.tuiLogger.Info(entry.Message, zapFields...)
-	}
-
-	return nil
-}
-
-func (c *tuiZapCore) Sync() error {
-	return nil
-}
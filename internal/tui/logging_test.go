@@ -0,0 +1,49 @@
+package tui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestTUIZapLoggerKeepsFields(t *testing.T) {
+	var sent []LogMsg
+	logger := newTUIZapLogger(func(msg tea.Msg) { sent = append(sent, msg.(LogMsg)) }, zapcore.InfoLevel)
+
+	long := strings.Repeat("very long message ", 20)
+	logger.With(zap.String("component", "proxy")).Info(long, zap.Int("port", 8080), zap.Bool("funnel", true))
+	logger.Debug("hidden")
+	logger.Error("dial failed", zap.Error(errors.New("connection refused")))
+
+	if len(sent) != 2 {
+		t.Fatalf("expected info and error entries, got %+v", sent)
+	}
+	info := sent[0]
+	if info.Level != "INFO" || info.Message != long {
+		t.Fatalf("expected the whole message, got %s %q", info.Level, info.Message)
+	}
+	want := []LogField{{"component", "proxy"}, {"port", "8080"}, {"funnel", "true"}}
+	if len(info.Fields) != len(want) {
+		t.Fatalf("expected fields %v, got %v", want, info.Fields)
+	}
+	for i := range want {
+		if info.Fields[i] != want[i] {
+			t.Fatalf("expected fields %v, got %v", want, info.Fields)
+		}
+	}
+	if info.Stack != "" {
+		t.Fatalf("expected no stack trace for info, got %q", info.Stack)
+	}
+
+	failed := sent[1]
+	if failed.Level != "ERROR" || len(failed.Fields) != 1 || failed.Fields[0] != (LogField{"error", "connection refused"}) {
+		t.Fatalf("unexpected error entry %+v", failed)
+	}
+	if !strings.Contains(failed.Stack, "TestTUIZapLoggerKeepsFields") {
+		t.Fatalf("expected a stack trace from the caller, got %q", failed.Stack)
+	}
+}
@@ -0,0 +1,296 @@
+// internal/tui/logpane.go
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// maxLogEntries is how many log entries the log pane keeps.
+const maxLogEntries = 1000
+
+// logView selects what the log pane shows.
+type logView int
+
+const (
+	appLogView       logView = iota
+	tailscaleLogView         // tsnet and tailscale logs, shown with 't'
+	processLogView           // output of the command `portal run` started, shown with 'l'
+)
+
+// logEntry is a log message in the log pane. Expanded entries show the
+// whole message, every field and the stack trace.
+type logEntry struct {
+	LogMsg
+	expanded bool
+}
+
+// logPane holds the entries of one log view and which entry is selected.
+type logPane struct {
+	entries []logEntry
+	cursor  int  // index of the selected entry
+	follow  bool // keep the newest entry selected as logs arrive
+}
+
+func (p *logPane) append(msg LogMsg) {
+	p.entries = append(p.entries, logEntry{LogMsg: msg})
+	if len(p.entries) > maxLogEntries {
+		p.entries = p.entries[1:]
+		p.cursor = max(p.cursor-1, 0)
+	}
+	if p.follow {
+		p.cursor = len(p.entries) - 1
+	}
+}
+
+// isTailscaleLog reports whether msg comes from tsnet or the Tailscale
+// client rather than portal itself.
+func isTailscaleLog(msg LogMsg) bool {
+	for _, field := range msg.Fields {
+		if field.Key == "component" {
+			return strings.HasPrefix(field.Value, "tailscale_") || strings.HasPrefix(field.Value, "tsnet_")
+		}
+	}
+	return false
+}
+
+// appendLog adds msg to the application logs, or to the Tailscale logs when
+// it comes from Tailscale. Tailscale warnings and errors go to both so
+// failures aren't hidden.
+func (m *Model) appendLog(msg LogMsg) {
+	if isTailscaleLog(msg) {
+		m.tsLogs.append(msg)
+		if msg.Level == "INFO" || msg.Level == "DEBUG" {
+			if m.ready {
+				m.refreshLogs()
+			}
+			return
+		}
+	}
+	m.logs.append(msg)
+
+	if m.ready {
+		m.refreshLogs()
+	}
+}
+
+// appendProcessOutput adds a line of the supervised command's output to its
+// log view.
+func (m *Model) appendProcessOutput(msg ProcessOutputMsg) {
+	level := "OUT"
+	if msg.Stream == "stderr" {
+		level = "ERR"
+	}
+	// Cursor movement and colors from the command would garble the pane.
+	m.procLogs.append(LogMsg{Level: level, Message: ansi.Strip(msg.Text), Time: msg.Time})
+	if m.ready && m.logView == processLogView {
+		m.refreshLogs()
+	}
+}
+
+// toggleLogView switches the log pane to view, or back to the application
+// logs if it is already showing.
+func (m *Model) toggleLogView(view logView) {
+	if m.logView == view {
+		m.logView = appLogView
+	} else {
+		m.logView = view
+	}
+	if m.ready {
+		m.refreshLogs()
+	}
+}
+
+// activeLogs is the log view shown in the log pane.
+func (m *Model) activeLogs() *logPane {
+	switch m.logView {
+	case tailscaleLogView:
+		return &m.tsLogs
+	case processLogView:
+		return &m.procLogs
+	default:
+		return &m.logs
+	}
+}
+
+// logsTitle names the log view, pointing out the hidden ones that have
+// entries.
+func (m *Model) logsTitle() string {
+	switch m.logView {
+	case tailscaleLogView:
+		return "Tailscale Logs ('t' for application logs)"
+	case processLogView:
+		return "Command Output ('l' for application logs)"
+	}
+	var hidden []string
+	if n := len(m.tsLogs.entries); n > 0 {
+		hidden = append(hidden, fmt.Sprintf("%d Tailscale, 't' to show", n))
+	}
+	if n := len(m.procLogs.entries); n > 0 {
+		hidden = append(hidden, fmt.Sprintf("%d command output, 'l' to show", n))
+	}
+	if len(hidden) > 0 {
+		return fmt.Sprintf("Application Logs (%s)", strings.Join(hidden, "; "))
+	}
+	return "Application Logs"
+}
+
+// moveLogCursor moves the selection by delta entries. Selecting the newest
+// entry goes back to following new logs.
+func (m *Model) moveLogCursor(delta int) {
+	p := m.activeLogs()
+	if len(p.entries) == 0 {
+		return
+	}
+	p.cursor = min(max(p.cursor+delta, 0), len(p.entries)-1)
+	p.follow = p.cursor == len(p.entries)-1
+	if m.ready {
+		m.refreshLogs()
+	}
+}
+
+// toggleLogEntry expands or collapses the selected entry.
+func (m *Model) toggleLogEntry() {
+	p := m.activeLogs()
+	if len(p.entries) == 0 {
+		return
+	}
+	p.entries[p.cursor].expanded = !p.entries[p.cursor].expanded
+	if m.ready {
+		m.refreshLogs()
+	}
+}
+
+// refreshLogs redraws the log pane, scrolling so the selected entry is in
+// view.
+func (m *Model) refreshLogs() {
+	p := m.activeLogs()
+	content, start, end := p.render(m.appLogs.Width)
+	m.appLogs.SetContent(content)
+	if len(p.entries) == 0 {
+		return
+	}
+	if p.follow && !p.entries[p.cursor].expanded {
+		m.appLogs.GotoBottom()
+		return
+	}
+	offset := m.appLogs.YOffset
+	if end > offset+m.appLogs.Height {
+		offset = end - m.appLogs.Height
+	}
+	if start < offset {
+		offset = start
+	}
+	m.appLogs.SetYOffset(offset)
+}
+
+// render renders the entries for a pane width along with the first and one
+// past the last line of the selected entry.
+func (p *logPane) render(width int) (string, int, int) {
+	if len(p.entries) == 0 {
+		return "", 0, 0
+	}
+
+	// Keep one column of headroom to avoid terminal hard-wrap at exact pane width.
+	displayWidth := 0
+	if width > 0 {
+		displayWidth = maxInt(width-1, 8)
+	}
+
+	var lines []string
+	var start, end int
+	for i, entry := range p.entries {
+		selected := i == p.cursor
+		if selected {
+			start = len(lines)
+		}
+		lines = append(lines, renderLogEntry(entry, selected, displayWidth)...)
+		if selected {
+			end = len(lines)
+		}
+	}
+	return strings.Join(lines, "\n"), start, end
+}
+
+// renderLogEntry renders one entry. Collapsed entries are cut to width, so
+// expanding is the way to read the rest.
+func renderLogEntry(entry logEntry, selected bool, width int) []string {
+	dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	keyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("75"))
+	levelStyle := dimStyle
+	switch entry.Level {
+	case "ERROR", "FATAL":
+		levelStyle = levelStyle.Foreground(lipgloss.Color("196"))
+	case "WARN", "ERR":
+		levelStyle = levelStyle.Foreground(lipgloss.Color("208"))
+	case "INFO":
+		levelStyle = levelStyle.Foreground(lipgloss.Color("34"))
+	case "DEBUG":
+		levelStyle = levelStyle.Foreground(lipgloss.Color("75"))
+	}
+
+	marker := "  "
+	if selected {
+		marker = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212")).Render("> ")
+	}
+	prefix := fmt.Sprintf("%s%s %s ",
+		marker,
+		dimStyle.Render(entry.Time.Format("15:04:05")),
+		levelStyle.Render(fmt.Sprintf("%-5s", entry.Level)))
+
+	if !entry.expanded {
+		line := prefix + strings.ReplaceAll(entry.summary(), "\n", " ")
+		if width > 0 {
+			line = ansi.Truncate(line, width, "...")
+		}
+		return []string{line}
+	}
+
+	const indent = "    "
+	wrap := func(text string, margin int) []string {
+		if width > 0 {
+			text = ansi.Wrap(text, maxInt(width-margin, 8), "")
+		}
+		return strings.Split(text, "\n")
+	}
+
+	messageLines := wrap(entry.Message, ansi.StringWidth(prefix))
+	lines := []string{prefix + messageLines[0]}
+	for _, line := range messageLines[1:] {
+		lines = append(lines, strings.Repeat(" ", ansi.StringWidth(prefix))+line)
+	}
+	for _, field := range entry.Fields {
+		for i, line := range wrap(field.Key+": "+field.Value, len(indent)) {
+			if i == 0 {
+				key, value, _ := strings.Cut(line, ": ")
+				line = keyStyle.Render(key) + ": " + value
+			}
+			lines = append(lines, indent+line)
+		}
+	}
+	if entry.Stack != "" {
+		lines = append(lines, indent+keyStyle.Render("stack:"))
+		for _, line := range wrap(strings.ReplaceAll(entry.Stack, "\t", "  "), len(indent)+2) {
+			lines = append(lines, indent+"  "+dimStyle.Render(line))
+		}
+	}
+	return lines
+}
+
+// summary is the message followed by its fields as key=value pairs.
+func (l LogMsg) summary() string {
+	var b strings.Builder
+	b.WriteString(l.Message)
+	for _, field := range l.Fields {
+		value := field.Value
+		if value == "" || strings.ContainsAny(value, " \t\n\"") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, " %s=%s", field.Key, value)
+	}
+	return b.String()
+}
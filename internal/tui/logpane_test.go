@@ -0,0 +1,134 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestLogEntryExpandsWithEnter(t *testing.T) {
+	m := NewModel(&stubStatsProvider{}, Options{})
+	resizeModel(t, &m, 140, 42)
+
+	long := strings.Repeat("word ", 60) + "tail-end"
+	updateModel(t, &m, LogMsg{
+		Level:   "ERROR",
+		Message: long,
+		Time:    time.Now(),
+		Fields:  []LogField{{Key: "error", Value: "connection refused"}},
+		Stack:   "main.run\n\t/src/main.go:42",
+	})
+	updateModel(t, &m, LogMsg{Level: "INFO", Message: "newer", Time: time.Now()})
+
+	collapsed := ansi.Strip(m.appLogs.View())
+	if strings.Contains(collapsed, "tail-end") || strings.Contains(collapsed, "main.go:42") {
+		t.Fatalf("expected collapsed entries to fit on one line, got %q", collapsed)
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'k'}})
+	if m.logs.cursor != 0 || m.logs.follow {
+		t.Fatalf("expected 'k' to select the older entry, got cursor %d follow %v", m.logs.cursor, m.logs.follow)
+	}
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyEnter})
+
+	expanded := ansi.Strip(m.appLogs.View())
+	for _, want := range []string{"tail-end", "error: connection refused", "stack:", "/src/main.go:42"} {
+		if !strings.Contains(expanded, want) {
+			t.Fatalf("expected expanded entry to show %q, got %q", want, expanded)
+		}
+	}
+
+	// New logs don't move the selection away from the expanded entry.
+	updateModel(t, &m, LogMsg{Level: "INFO", Message: "newest", Time: time.Now()})
+	if m.logs.cursor != 0 {
+		t.Fatalf("expected selection to stay on the expanded entry, got %d", m.logs.cursor)
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyEnter})
+	if strings.Contains(ansi.Strip(m.appLogs.View()), "stack:") {
+		t.Fatalf("expected Enter to collapse the entry again")
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	if !m.logs.follow {
+		t.Fatalf("expected selecting the newest entry to follow new logs again")
+	}
+	updateModel(t, &m, LogMsg{Level: "INFO", Message: "latest", Time: time.Now()})
+	if m.logs.cursor != len(m.logs.entries)-1 {
+		t.Fatalf("expected the newest entry to be selected, got %d of %d", m.logs.cursor, len(m.logs.entries))
+	}
+}
+
+func TestLogSummaryQuotesValues(t *testing.T) {
+	msg := LogMsg{Message: "serve", Fields: []LogField{{Key: "port", Value: "443"}, {Key: "path", Value: "/a b"}}}
+	if got, want := msg.summary(), `serve port=443 path="/a b"`; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestTailscaleLogsHaveTheirOwnView(t *testing.T) {
+	m := NewModel(&stubStatsProvider{}, Options{})
+	resizeModel(t, &m, 140, 42)
+
+	tsnet := []LogField{{Key: "component", Value: "tsnet_runtime"}}
+	updateModel(t, &m, LogMsg{Level: "INFO", Message: "magicsock: derp-1 connected", Time: time.Now(), Fields: tsnet})
+	updateModel(t, &m, LogMsg{Level: "INFO", Message: "Request completed", Time: time.Now(), Fields: []LogField{{Key: "component", Value: "proxy_server"}}})
+	updateModel(t, &m, LogMsg{Level: "ERROR", Message: "serve config rejected", Time: time.Now(), Fields: []LogField{{Key: "component", Value: "tailscale_serve"}}})
+
+	view := ansi.Strip(m.View())
+	if strings.Contains(view, "magicsock") {
+		t.Fatalf("expected tsnet logs to stay out of the application logs, got %q", view)
+	}
+	if !strings.Contains(view, "Request completed") || !strings.Contains(view, "serve config rejected") {
+		t.Fatalf("expected application logs and Tailscale errors, got %q", view)
+	}
+	if !strings.Contains(view, "Application Logs (2 Tailscale, 't' to show)") {
+		t.Fatalf("expected the title to count Tailscale logs, got %q", view)
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	logs := ansi.Strip(m.appLogs.View())
+	if !strings.Contains(logs, "magicsock") || !strings.Contains(logs, "serve config rejected") || strings.Contains(logs, "Request completed") {
+		t.Fatalf("expected only Tailscale logs after 't', got %q", logs)
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	if logs := ansi.Strip(m.appLogs.View()); strings.Contains(logs, "magicsock") {
+		t.Fatalf("expected 't' to go back to application logs, got %q", logs)
+	}
+}
+
+func TestProcessOutputHasItsOwnView(t *testing.T) {
+	m := NewModel(&stubStatsProvider{}, Options{})
+	resizeModel(t, &m, 140, 42)
+
+	updateModel(t, &m, LogMsg{Level: "INFO", Message: "Request completed", Time: time.Now()})
+	updateModel(t, &m, ProcessOutputMsg{Stream: "stdout", Text: "\x1b[32mready\x1b[0m in 412ms", Time: time.Now()})
+	updateModel(t, &m, ProcessOutputMsg{Stream: "stderr", Text: "warning: deprecated option", Time: time.Now()})
+
+	view := ansi.Strip(m.View())
+	if strings.Contains(view, "ready in 412ms") {
+		t.Fatalf("expected command output to stay out of the application logs, got %q", view)
+	}
+	if !strings.Contains(view, "Application Logs (2 command output, 'l' to show)") {
+		t.Fatalf("expected the title to count command output, got %q", view)
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	logs := ansi.Strip(m.appLogs.View())
+	if !strings.Contains(logs, "OUT   ready in 412ms") || !strings.Contains(logs, "ERR   warning: deprecated option") || strings.Contains(logs, "Request completed") {
+		t.Fatalf("expected only command output after 'l', got %q", logs)
+	}
+	if !strings.Contains(ansi.Strip(m.View()), "Command Output ('l' for application logs)") {
+		t.Fatal("expected the command output title")
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	if logs := ansi.Strip(m.appLogs.View()); strings.Contains(logs, "ready in 412ms") {
+		t.Fatalf("expected 't' to switch straight to the Tailscale logs, got %q", logs)
+	}
+}
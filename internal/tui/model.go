@@ -3,8 +3,9 @@ package tui
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
-	"sort"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/charmbracelet/x/ansi"
 
 	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/qr"
 )
 
 const (
@@ -53,6 +55,25 @@ type StatsProvider interface {
 	GetEndpointState() model.EndpointState
 }
 
+// CapturePauser is optionally implemented by the stats provider to stop
+// recording new requests while traffic keeps flowing.
+type CapturePauser interface {
+	SetCapturePaused(paused bool)
+	CaptureState() model.CaptureState
+}
+
+// StateProvider is optionally implemented by the stats provider to report
+// its target along with the endpoint state.
+type StateProvider interface {
+	State() model.ServerState
+}
+
+// StatsResetter is optionally implemented by the stats provider to zero the
+// counters, e.g. between test runs.
+type StatsResetter interface {
+	ResetStats()
+}
+
 // Model represents the TUI application state
 type Model struct {
 	endpointPane viewport.Model
@@ -63,10 +84,26 @@ type Model struct {
 	width       int
 	height      int
 	layout      layoutSpec
-	appLogLines []string
+	logs        logPane // application logs
+	tsLogs      logPane // tsnet and tailscale logs, shown with 't'
+	procLogs    logPane // output of the command `portal run` started
+	logView     logView
 	lastRequest *model.RequestLog
+	approvals   []ApprovalMsg
+	alerts      []AlertMsg
+	capture     model.CaptureState
+	showQR      bool
+	presence    bool // who's connected in place of the stats ('w')
+	qrURL       string
+	qrCode      string
+	hexView     int // 0 for the dashboard, n for hexRequest's n-th binary body
+	hexPage     int
+	hexRequest  *model.RequestLog
 	ready       bool
 	server      StatsProvider
+	refresh     time.Duration
+	polled      string // pollState as of the last refresh
+	frame       *frame
 }
 
 // Message types for TUI updates
@@ -74,6 +111,14 @@ type LogMsg struct {
 	Level   string
 	Message string
 	Time    time.Time
+	Fields  []LogField
+	Stack   string // stack trace for errors, if any
+}
+
+// LogField is a structured log field formatted for display.
+type LogField struct {
+	Key   string
+	Value string
 }
 
 // RequestMsg is the correct message type for request updates
@@ -81,44 +126,104 @@ type RequestMsg struct {
 	Log model.RequestLog
 }
 
+// ProcessOutputMsg is a line printed by the command `portal run` started,
+// on Stream "stdout" or "stderr".
+type ProcessOutputMsg struct {
+	Stream string
+	Text   string
+	Time   time.Time
+}
+
+// ApprovalMsg asks the operator to allow or deny a request to a sensitive
+// path. The decision is sent on Reply, which must be buffered; prompts are
+// dropped once Deadline passes.
+type ApprovalMsg struct {
+	Request  model.SensitiveRequest
+	Reply    chan<- bool
+	Deadline time.Time
+}
+
+// AlertMsg reports an alert rule starting or stopping firing. Firing alerts
+// show in a banner until they resolve.
+type AlertMsg struct {
+	Rule   string
+	Firing bool
+	Value  string
+	Time   time.Time
+}
+
 type tickMsg struct{}
 
 // NewModel creates a new TUI model
-func NewModel(server StatsProvider) Model {
+func NewModel(server StatsProvider, opts Options) Model {
 	return Model{
 		endpointPane: viewport.New(0, 0),
 		statsPane:    viewport.New(0, 0),
 		headersPane:  viewport.New(0, 0),
 		appLogs:      viewport.New(0, 0),
-		appLogLines:  []string{},
+		logs:         logPane{follow: true},
+		tsLogs:       logPane{follow: true},
+		procLogs:     logPane{follow: true},
 		server:       server,
+		refresh:      opts.refresh(),
+		frame:        &frame{stale: true},
 	}
 }
 
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
-	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
-		return tickMsg{}
-	})
+	return m.nextTick()
 }
 
 // Update handles messages and updates the model
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Anything but a tick may change the screen; ticks decide for
+	// themselves.
+	if _, ok := msg.(tickMsg); !ok {
+		m.frame.invalidate()
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.applyWindowSize(msg.Width, msg.Height)
 
 	case tickMsg:
-		if m.ready {
-			m.refreshPaneContent()
-		}
-		return m, tea.Tick(time.Second, func(t time.Time) tea.Msg {
-			return tickMsg{}
-		})
+		return m.tick(time.Now())
 
 	case LogMsg:
 		m.appendLog(msg)
 
+	case ProcessOutputMsg:
+		m.appendProcessOutput(msg)
+
+	case editedRequestMsg:
+		if replayer, ok := m.server.(Replayer); ok {
+			return m, replayEdited(replayer, msg)
+		}
+
+	case replayedMsg:
+		if msg.err != nil {
+			m.appendLog(LogMsg{Level: "ERROR", Message: fmt.Sprintf("Replay of %s failed: %v", msg.of, msg.err), Time: time.Now()})
+		} else {
+			m.appendLog(LogMsg{Level: "INFO", Message: fmt.Sprintf("Replayed %s as %s: %d", msg.of, msg.entry.ID, msg.entry.StatusCode), Time: time.Now()})
+		}
+
+	case openedMsg:
+		if msg.err != nil {
+			m.appendLog(LogMsg{Level: "ERROR", Message: fmt.Sprintf("Failed to open %s: %v", msg.url, msg.err), Time: time.Now()})
+		} else {
+			m.appendLog(LogMsg{Level: "INFO", Message: "Opened " + msg.url + " in the browser", Time: time.Now()})
+		}
+
+	case ApprovalMsg:
+		m.approvals = append(m.approvals, msg)
+
+	case AlertMsg:
+		m.alerts = slices.DeleteFunc(m.alerts, func(a AlertMsg) bool { return a.Rule == msg.Rule })
+		if msg.Firing {
+			m.alerts = append(m.alerts, msg)
+		}
+
 	case RequestMsg:
 		m.lastRequest = &msg.Log
 		if m.ready {
@@ -130,11 +235,75 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		case "ctrl+c", "q":
 			return m, tea.Quit
-		case "up", "k", "down", "j", "pgup", "pgdown":
+		case "c":
+			m.showQR = !m.showQR
+			return m, nil
+		case "p":
+			if pauser, ok := m.server.(CapturePauser); ok {
+				pauser.SetCapturePaused(!pauser.CaptureState().Paused)
+				m.refreshCaptureState()
+			}
+			return m, nil
+		case "x":
+			m.toggleHexView()
+			return m, nil
+		case "w":
+			m.togglePresence()
+			return m, nil
+		case "r":
+			if resetter, ok := m.server.(StatsResetter); ok {
+				resetter.ResetStats()
+				if m.ready {
+					m.updateStatsPane()
+				}
+			}
+			return m, nil
+		case "e":
+			if _, ok := m.server.(Replayer); ok && m.lastRequest != nil {
+				return m, editRequest(*m.lastRequest)
+			}
+		case "y", "n":
+			if len(m.approvals) > 0 {
+				m.approvals[0].Reply <- msg.String() == "y"
+				m.approvals = m.approvals[1:]
+				return m, nil
+			}
+		case "pgup", "pgdown":
+			if m.hexView > 0 {
+				if msg.String() == "pgup" {
+					m.pageHexView(-1)
+				} else {
+					m.pageHexView(1)
+				}
+				return m, nil
+			}
 			if m.ready {
 				m.appLogs, _ = m.appLogs.Update(msg)
 			}
 			return m, nil
+		case "up", "k":
+			m.moveLogCursor(-1)
+			return m, nil
+		case "down", "j":
+			m.moveLogCursor(1)
+			return m, nil
+		case "enter":
+			m.toggleLogEntry()
+			return m, nil
+		case "t":
+			m.toggleLogView(tailscaleLogView)
+			return m, nil
+		case "l":
+			m.toggleLogView(processLogView)
+			return m, nil
+		case "o", "O":
+			state := m.server.GetEndpointState()
+			name, url := "service", state.ServiceURL
+			if msg.String() == "O" {
+				name, url = "web UI", state.WebUIURL
+			}
+			cmd := m.openInBrowser(name, url)
+			return m, cmd
 		}
 	}
 
@@ -145,6 +314,23 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// refreshCaptureState picks up pauses made through the web UI or API too.
+func (m *Model) refreshCaptureState() {
+	if pauser, ok := m.server.(CapturePauser); ok {
+		m.capture = pauser.CaptureState()
+	}
+}
+
+func (m *Model) dropExpiredApprovals(now time.Time) {
+	kept := m.approvals[:0]
+	for _, approval := range m.approvals {
+		if approval.Deadline.IsZero() || now.Before(approval.Deadline) {
+			kept = append(kept, approval)
+		}
+	}
+	m.approvals = kept
+}
+
 func (m *Model) applyWindowSize(width, height int) {
 	m.width = width
 	m.height = height
@@ -323,57 +509,7 @@ func (m *Model) refreshPaneContent() {
 	m.updateEndpointPane()
 	m.updateStatsPane()
 	m.updateHeadersPane()
-	m.appLogs.SetContent(m.renderLogsContent())
-}
-
-func (m *Model) appendLog(msg LogMsg) {
-	timestamp := msg.Time.Format("15:04:05")
-	levelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
-	switch msg.Level {
-	case "ERROR", "FATAL":
-		levelStyle = levelStyle.Foreground(lipgloss.Color("196"))
-	case "WARN":
-		levelStyle = levelStyle.Foreground(lipgloss.Color("208"))
-	case "INFO":
-		levelStyle = levelStyle.Foreground(lipgloss.Color("34"))
-	case "DEBUG":
-		levelStyle = levelStyle.Foreground(lipgloss.Color("75"))
-	}
-
-	logLine := fmt.Sprintf("%s %s %s",
-		lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(timestamp),
-		levelStyle.Render(fmt.Sprintf("%-5s", msg.Level)),
-		msg.Message)
-
-	m.appLogLines = append(m.appLogLines, logLine)
-	if len(m.appLogLines) > 1000 {
-		m.appLogLines = m.appLogLines[1:]
-	}
-
-	if m.ready {
-		m.appLogs.SetContent(m.renderLogsContent())
-		m.appLogs.GotoBottom()
-	}
-}
-
-func (m *Model) renderLogsContent() string {
-	if len(m.appLogLines) == 0 {
-		return ""
-	}
-
-	maxWidth := m.appLogs.Width
-	if maxWidth <= 0 {
-		return strings.Join(m.appLogLines, "\n")
-	}
-
-	// Keep one column of headroom to avoid terminal hard-wrap at exact pane width.
-	displayWidth := maxInt(maxWidth-1, 8)
-
-	lines := make([]string, len(m.appLogLines))
-	for i, line := range m.appLogLines {
-		lines[i] = ansi.Truncate(line, displayWidth, "...")
-	}
-	return strings.Join(lines, "\n")
+	m.refreshLogs()
 }
 
 func (m *Model) updateEndpointPane() {
@@ -382,19 +518,44 @@ func (m *Model) updateEndpointPane() {
 	}
 
 	state := m.server.GetEndpointState()
+	target := ""
+	if provider, ok := m.server.(StateProvider); ok {
+		current := provider.State()
+		state, target = current.Endpoint, current.TargetURL
+	}
 	contentWidth := maxInt(m.endpointPane.Width-2, 24)
 	var b strings.Builder
 
-	b.WriteString(fmt.Sprintf("Mode: %s  Exposure: %s\n",
+	b.WriteString(fmt.Sprintf("Mode: %s  Exposure: %s",
 		fallbackString(strings.TrimSpace(state.Mode), "unknown"),
 		exposureLabel(strings.TrimSpace(state.Exposure)),
 	))
+	if state.Upstream != "" {
+		b.WriteString(fmt.Sprintf("  Upstream: %s", upstreamLabel(state.Upstream)))
+	}
+	if state.Cert != "" {
+		b.WriteString(fmt.Sprintf("  Cert: %s", certLabel(state.Cert)))
+	}
+	b.WriteString("\n")
 
 	b.WriteString("Service: ")
 	for _, line := range formatURLForDisplay(state.ServiceURL, contentWidth-9, 1) {
 		b.WriteString(line)
 	}
 	b.WriteString("\n")
+	if target != "" {
+		b.WriteString(ansi.Truncate("Target: "+target, contentWidth, "..."))
+		b.WriteString("\n")
+	}
+
+	if state.Mounts != "" {
+		b.WriteString(ansi.Truncate("Mounts: "+state.Mounts, contentWidth, "..."))
+		b.WriteString("\n")
+	}
+	if state.Exposes != "" {
+		b.WriteString(ansi.Truncate("Exposes: "+state.Exposes, contentWidth, "..."))
+		b.WriteString("\n")
+	}
 
 	b.WriteString(fmt.Sprintf("Web UI: %s", fallbackString(strings.TrimSpace(state.WebUIStatus), "unavailable")))
 	if strings.TrimSpace(state.WebUIURL) != "" {
@@ -416,6 +577,10 @@ func (m *Model) updateStatsPane() {
 	if m.server == nil {
 		return
 	}
+	if provider, ok := m.server.(PresenceProvider); ok && m.presence {
+		m.statsPane.SetContent(renderPresence(provider.Presence(), time.Now()))
+		return
+	}
 
 	ttl, opn, rt1, rt5, p50, p90 := m.server.GetStats()
 
@@ -429,6 +594,13 @@ func (m *Model) updateStatsPane() {
 	b.WriteString(fmt.Sprintf("%-12s %5d %5d %6.1f %6.1f %6.1f %6.1f\n\n",
 		"", ttl, opn, rt1, rt5, p50, p90))
 
+	if provider, ok := m.server.(SeriesProvider); ok {
+		// The panel pads the pane by a column on each side.
+		if sparklines := renderSparklines(provider.GetSeries(), m.statsPane.Width-2); sparklines != "" {
+			b.WriteString(sparklines + "\n")
+		}
+	}
+
 	b.WriteString("Legend:\n")
 	b.WriteString("  ttl: Total requests\n")
 	b.WriteString("  opn: Open connections\n")
@@ -470,39 +642,66 @@ func (m *Model) updateHeadersPane() {
 		lipgloss.NewStyle().Foreground(statusColor).Render(fmt.Sprintf("%d", m.lastRequest.Response.StatusCode)),
 		m.lastRequest.Duration.Round(time.Millisecond).String()))
 
-	b.WriteString(fmt.Sprintf("From: %s\n", truncateString(m.lastRequest.RemoteAddr, lineWidth)))
+	if m.lastRequest.Expose != "" {
+		b.WriteString(fmt.Sprintf("Expose: %s\n", m.lastRequest.Expose))
+	}
+	if m.lastRequest.ReplayOf != "" {
+		b.WriteString(fmt.Sprintf("Replay of %s\n", m.lastRequest.ReplayOf))
+	}
+	if m.lastRequest.DuplicateOf != "" {
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Render("Duplicate of "+m.lastRequest.DuplicateOf) + "\n")
+	}
+	if m.lastRequest.Scanner != "" {
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Render("Scanner: "+m.lastRequest.Scanner) + "\n")
+	}
+
+	if timing := m.lastRequest.Timing; timing != nil {
+		b.WriteString(fmt.Sprintf("Upstream: connect %s  ttfb %s  transfer %s\n",
+			formatPhase(timing.Connect), formatPhase(timing.TTFB), formatPhase(timing.Transfer)))
+	}
+
+	from := m.lastRequest.RemoteAddr
+	if geo := m.lastRequest.GeoIP; geo != nil {
+		from += " (" + geo.Label() + ")"
+	}
+	b.WriteString(fmt.Sprintf("From: %s\n", truncateString(from, lineWidth)))
 	b.WriteString(fmt.Sprintf("Time: %s\n\n", m.lastRequest.Timestamp.Format("15:04:05")))
 
+	if m.lastRequest.OAuth != nil {
+		b.WriteString(renderOAuthSection(m.lastRequest.OAuth, lineWidth))
+		b.WriteString("\n")
+	}
+
 	if len(m.lastRequest.Headers) > 0 {
 		b.WriteString(lipgloss.NewStyle().Bold(true).Render("Request Headers:"))
 		b.WriteString("\n")
 
 		priorityHeaders := []string{"User-Agent", "Content-Type", "Authorization", "Accept", "Host", "Accept-Encoding"}
-		shown := make(map[string]bool)
+		shown := 0
 		for _, key := range priorityHeaders {
-			if value, exists := m.lastRequest.Headers[key]; exists {
+			for _, value := range m.lastRequest.Headers.Values(key) {
 				b.WriteString(fmt.Sprintf("  %s: %s\n",
 					lipgloss.NewStyle().Foreground(lipgloss.Color("75")).Render(key),
 					truncateString(value, headerValueLimit)))
-				shown[key] = true
+				shown++
 			}
 		}
 
-		var otherHeaders []string
-		for k := range m.lastRequest.Headers {
-			if !shown[k] {
-				otherHeaders = append(otherHeaders, k)
+		// Repeated headers, such as Set-Cookie, get a line per value.
+		var otherHeaders model.Headers
+		for _, header := range m.lastRequest.Headers {
+			if !slices.Contains(priorityHeaders, http.CanonicalHeaderKey(header.Name)) {
+				otherHeaders = append(otherHeaders, header)
 			}
 		}
-		sort.Strings(otherHeaders)
 
-		currentLines := 7 + len(shown)
+		currentLines := 7 + shown
 		availableLines := m.headersPane.Height - currentLines - 3
 		if availableLines < 0 {
 			availableLines = 0
 		}
 
-		for i, k := range otherHeaders {
+		for i, header := range otherHeaders {
 			if i >= availableLines {
 				remaining := len(otherHeaders) - i
 				if remaining > 0 {
@@ -513,8 +712,28 @@ func (m *Model) updateHeadersPane() {
 				break
 			}
 			b.WriteString(fmt.Sprintf("  %s: %s\n",
-				lipgloss.NewStyle().Foreground(lipgloss.Color("75")).Render(k),
-				truncateString(m.lastRequest.Headers[k], headerValueLimit)))
+				lipgloss.NewStyle().Foreground(lipgloss.Color("75")).Render(header.Name),
+				truncateString(header.Value, headerValueLimit)))
+		}
+		b.WriteString("\n")
+	}
+
+	if m.lastRequest.JWT != nil {
+		b.WriteString(renderJWTSummary(m.lastRequest.JWT, time.Now()))
+		b.WriteString("\n\n")
+	}
+
+	if len(m.lastRequest.Multipart) > 0 {
+		b.WriteString(lipgloss.NewStyle().Bold(true).Render("Multipart Form:"))
+		b.WriteString("\n")
+		for _, part := range m.lastRequest.Multipart {
+			b.WriteString("  " + lipgloss.NewStyle().Foreground(lipgloss.Color("75")).Render(part.Name) + ": ")
+			if part.Filename != "" {
+				b.WriteString(fmt.Sprintf("%s (%s, %d bytes)", part.Filename, part.ContentType, part.Size))
+			} else {
+				b.WriteString(truncateString(part.Value, headerValueLimit))
+			}
+			b.WriteString("\n")
 		}
 		b.WriteString("\n")
 	}
@@ -543,6 +762,11 @@ func (m *Model) updateHeadersPane() {
 		b.WriteString("\n")
 	}
 
+	if len(m.lastRequest.BodyBytes) > 0 || len(m.lastRequest.Response.BodyBytes) > 0 {
+		b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("Binary body captured; press 'x' for the hex view"))
+		b.WriteString("\n")
+	}
+
 	m.headersPane.SetContent(b.String())
 }
 
@@ -560,8 +784,19 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen-3] + "..."
 }
 
-// View renders the TUI
+// View renders the TUI, or returns the last frame when nothing has changed
+// since.
 func (m Model) View() string {
+	if m.frame == nil {
+		return m.render()
+	}
+	if m.frame.stale {
+		m.frame.view, m.frame.stale = m.render(), false
+	}
+	return m.frame.view
+}
+
+func (m Model) render() string {
 	if !m.ready {
 		return "Initializing TUI..."
 	}
@@ -587,14 +822,18 @@ func (m Model) View() string {
 
 	statsSection := ""
 	if m.layout.showStats {
+		statsTitle := "Statistics"
+		if m.presence {
+			statsTitle = "Who's Connected"
+		}
 		statsSection = lipgloss.JoinVertical(lipgloss.Top,
-			titleStyle.Render("Statistics"),
+			titleStyle.Render(statsTitle),
 			panelStyle.Width(m.layout.statsWidth).Height(m.layout.statsHeight).Render(m.statsPane.View()),
 		)
 	}
 
 	logsSection := lipgloss.JoinVertical(lipgloss.Top,
-		titleStyle.Render("Application Logs"),
+		titleStyle.Render(m.logsTitle()),
 		panelStyle.Width(m.layout.logsWidth).Height(m.layout.logsHeight).Render(m.appLogs.View()),
 	)
 
@@ -625,15 +864,108 @@ func (m Model) View() string {
 		mainSections = append(mainSections, logsSection)
 	}
 
+	help := "Press 'q' or Ctrl+C to quit | Up/Down or j/k to select logs | Enter to expand | 't' Tailscale logs | PgUp/PgDn for faster scrolling | 'e' edit & resend | 'p' pause capture | 'r' reset stats | 'c' QR code | 'x' hex view | 'o'/'O' open service/web UI"
+	if len(m.procLogs.entries) > 0 {
+		help += " | 'l' command output"
+	}
+	if _, ok := m.server.(PresenceProvider); ok {
+		help += " | 'w' who's connected"
+	}
 	footer := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("240")).
-		Render("Press 'q' or Ctrl+C to quit | Up/Down or j/k to scroll logs | PgUp/PgDn for faster scrolling")
+		Render(help)
+	if m.hexView > 0 {
+		mainSections = []string{m.renderHexSection()}
+	}
+	if m.showQR {
+		mainSections = []string{m.renderQRSection()}
+	}
 
 	mainView := lipgloss.JoinVertical(lipgloss.Top, mainSections...)
 	final := lipgloss.JoinVertical(lipgloss.Top, mainView, footer)
+	if m.capture.Paused {
+		final = lipgloss.JoinVertical(lipgloss.Top, m.renderPausedBanner(), final)
+	}
+	if len(m.alerts) > 0 {
+		final = lipgloss.JoinVertical(lipgloss.Top, m.renderAlertBanner(), final)
+	}
+	if len(m.approvals) > 0 {
+		// The view is clipped from the bottom on short terminals, so the
+		// prompt goes first.
+		final = lipgloss.JoinVertical(lipgloss.Top, m.renderApprovalPrompt(), final)
+	}
 	return sanitizeViewToWindow(strings.TrimRight(final, "\n"), m.width, m.height)
 }
 
+// renderQRSection shows a QR code of the service URL for opening it on a
+// phone, replacing the dashboard until 'c' is pressed again.
+func (m *Model) renderQRSection() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+	serviceURL := ""
+	if m.server != nil {
+		serviceURL = m.server.GetEndpointState().ServiceURL
+	}
+	if serviceURL == "" {
+		return titleStyle.Render("QR Code") + "\nThe service URL is not ready yet. Press 'c' to go back."
+	}
+
+	if serviceURL != m.qrURL {
+		code, err := qr.Terminal(serviceURL)
+		if err != nil {
+			code = err.Error()
+		}
+		m.qrURL, m.qrCode = serviceURL, code
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render("Scan to open "+serviceURL),
+		m.qrCode,
+		"Press 'c' to go back.",
+	)
+}
+
+// renderApprovalPrompt asks about the oldest pending sensitive request.
+func (m Model) renderApprovalPrompt() string {
+	req := m.approvals[0].Request
+	prompt := fmt.Sprintf("Sensitive request %s %s from %s (matched %s). Allow? y = allow, n = deny",
+		req.Method, req.Path, req.RemoteAddr, req.Pattern)
+	if remaining := time.Until(m.approvals[0].Deadline); !m.approvals[0].Deadline.IsZero() && remaining > 0 {
+		prompt += fmt.Sprintf(" (%ds)", int(remaining.Seconds()))
+	}
+	if waiting := len(m.approvals) - 1; waiting > 0 {
+		prompt += fmt.Sprintf(" | %d more waiting", waiting)
+	}
+	return lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("16")).
+		Background(lipgloss.Color("214")).
+		Render(truncateString(prompt, maxInt(m.width, 20)))
+}
+
+// renderAlertBanner lists the firing alerts with the value that set them
+// off.
+func (m Model) renderAlertBanner() string {
+	parts := make([]string, 0, len(m.alerts))
+	for _, a := range m.alerts {
+		parts = append(parts, fmt.Sprintf("%s (%s since %s)", a.Rule, a.Value, a.Time.Local().Format("15:04:05")))
+	}
+	return lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("231")).
+		Background(lipgloss.Color("160")).
+		Render(truncateString("ALERT "+strings.Join(parts, " | "), maxInt(m.width, 20)))
+}
+
+// renderPausedBanner says that capture is paused and how many requests it
+// has missed.
+func (m Model) renderPausedBanner() string {
+	text := fmt.Sprintf("CAPTURE PAUSED: %d requests not recorded | press 'p' to resume", m.capture.Skipped)
+	return lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("16")).
+		Background(lipgloss.Color("220")).
+		Render(truncateString(text, maxInt(m.width, 20)))
+}
+
 func exposureLabel(exposure string) string {
 	switch exposure {
 	case "tailnet":
@@ -645,6 +977,26 @@ func exposureLabel(exposure string) string {
 	}
 }
 
+func upstreamLabel(status string) string {
+	switch status {
+	case model.UpstreamWaiting:
+		return "waiting for upstream"
+	default:
+		return status
+	}
+}
+
+func certLabel(status string) string {
+	switch status {
+	case model.CertProvisioning:
+		return "provisioning..."
+	case model.CertFailed:
+		return "failed, first request may be slow"
+	default:
+		return status
+	}
+}
+
 func formatURLForDisplay(raw string, maxWidth, maxLines int) []string {
 	if maxWidth < 16 {
 		maxWidth = 16
@@ -752,6 +1104,15 @@ func (w *LogWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// formatPhase rounds a timing phase for display, keeping sub-millisecond
+// phases such as reused connections readable.
+func formatPhase(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
 // CreateRequestMsg creates a request message for the TUI
 func CreateRequestMsg(log model.RequestLog) tea.Msg {
 	return RequestMsg{Log: log}
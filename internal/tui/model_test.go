@@ -65,7 +65,7 @@ func TestLayoutProfilesAndResizePreserveState(t *testing.T) {
 		},
 	}
 
-	m := NewModel(provider)
+	m := NewModel(provider, Options{})
 
 	resizeModel(t, &m, 100, 30)
 	if got, want := m.layout.profile, layoutCompact; got != want {
@@ -120,7 +120,7 @@ func TestEndpointSummaryTailnetRendering(t *testing.T) {
 		},
 	}
 
-	m := NewModel(provider)
+	m := NewModel(provider, Options{})
 	resizeModel(t, &m, 150, 44)
 
 	content := m.endpointPane.View()
@@ -161,7 +161,7 @@ func TestFunnelLongURLRenderingPreservesHost(t *testing.T) {
 		},
 	}
 
-	m := NewModel(provider)
+	m := NewModel(provider, Options{})
 	resizeModel(t, &m, 96, 32)
 	content := m.endpointPane.View()
 	if !strings.Contains(content, "Exposure: funnel-public") {
@@ -169,6 +169,101 @@ func TestFunnelLongURLRenderingPreservesHost(t *testing.T) {
 	}
 }
 
+func TestEndpointSummaryShowsCertProgress(t *testing.T) {
+	provider := &stubStatsProvider{
+		state: model.EndpointState{
+			Readiness:  model.EndpointReadinessReady,
+			Mode:       "local_daemon",
+			Exposure:   "funnel",
+			ServiceURL: "https://portal.example.ts.net",
+			Cert:       model.CertProvisioning,
+		},
+	}
+	m := NewModel(provider, Options{})
+	resizeModel(t, &m, 140, 42)
+	if content := m.endpointPane.View(); !strings.Contains(content, "Cert: provisioning...") {
+		t.Fatalf("expected certificate progress in endpoint summary, got %q", content)
+	}
+
+	provider.state.Cert = model.CertReady
+	updateModel(t, &m, tickMsg{})
+	if content := m.endpointPane.View(); !strings.Contains(content, "Cert: ready") {
+		t.Fatalf("expected the certificate to show as ready, got %q", content)
+	}
+}
+
+func TestTickRedrawsOnlyWhenStateChanges(t *testing.T) {
+	provider := &stubStatsProvider{state: model.EndpointState{Readiness: model.EndpointReadinessReady}}
+	m := NewModel(provider, Options{})
+	resizeModel(t, &m, 140, 42)
+	updateModel(t, &m, tickMsg{})
+	m.View()
+
+	updateModel(t, &m, tickMsg{})
+	if m.frame.stale {
+		t.Fatal("expected an idle tick to keep the last frame")
+	}
+
+	provider.ttl = 7
+	updateModel(t, &m, tickMsg{})
+	if !m.frame.stale {
+		t.Fatal("expected a tick with new stats to redraw")
+	}
+	if view := ansi.Strip(m.View()); !strings.Contains(view, "    7 ") {
+		t.Fatalf("expected the new total in the stats pane, got %q", view)
+	}
+
+	updateModel(t, &m, LogMsg{Level: "INFO", Message: "hello", Time: time.Now()})
+	if !m.frame.stale {
+		t.Fatal("expected a log line to redraw")
+	}
+}
+
+func TestOptionsRefresh(t *testing.T) {
+	for _, tc := range []struct {
+		opts Options
+		want time.Duration
+	}{
+		{Options{}, DefaultRefresh},
+		{Options{Refresh: 250 * time.Millisecond}, 250 * time.Millisecond},
+		{Options{Refresh: time.Second, LowPower: true}, LowPowerRefresh},
+		{Options{Refresh: time.Minute, LowPower: true}, time.Minute},
+	} {
+		if got := tc.opts.refresh(); got != tc.want {
+			t.Fatalf("%+v: expected %v, got %v", tc.opts, tc.want, got)
+		}
+	}
+	if len(Options{}.ProgramOptions()) != 0 || len(Options{LowPower: true}.ProgramOptions()) != 1 {
+		t.Fatal("expected only low power to change the program options")
+	}
+}
+
+type stubStateProvider struct {
+	stubStatsProvider
+	target string
+}
+
+func (s *stubStateProvider) State() model.ServerState {
+	return model.ServerState{Mode: "proxy", TargetURL: s.target, Endpoint: s.state}
+}
+
+func TestEndpointSummaryShowsTarget(t *testing.T) {
+	provider := &stubStateProvider{
+		stubStatsProvider: stubStatsProvider{state: model.EndpointState{
+			Readiness:  model.EndpointReadinessReady,
+			Mode:       "local_daemon",
+			ServiceURL: "https://portal.example.ts.net",
+		}},
+		target: "http://localhost:3000",
+	}
+	m := NewModel(provider, Options{})
+	resizeModel(t, &m, 140, 42)
+	content := normalizePaneText(m.endpointPane.View())
+	if !strings.Contains(content, "Service: https://portal.example.ts.net\nTarget: http://localhost:3000") {
+		t.Fatalf("expected the target under the service URL, got %q", content)
+	}
+}
+
 func TestEndpointSummaryUnavailableAndFailureStates(t *testing.T) {
 	t.Run("tsnet unavailable ui", func(t *testing.T) {
 		provider := &stubStatsProvider{
@@ -182,7 +277,7 @@ func TestEndpointSummaryUnavailableAndFailureStates(t *testing.T) {
 			},
 		}
 
-		m := NewModel(provider)
+		m := NewModel(provider, Options{})
 		resizeModel(t, &m, 140, 42)
 		content := normalizePaneText(m.endpointPane.View())
 		for _, required := range []string{"Mode: tsnet  Exposure: tailnet-private", "Web UI: unavailable", "Web UI Reason: tsnet_ui_not_exposed"} {
@@ -204,7 +299,7 @@ func TestEndpointSummaryUnavailableAndFailureStates(t *testing.T) {
 			},
 		}
 
-		m := NewModel(provider)
+		m := NewModel(provider, Options{})
 		resizeModel(t, &m, 140, 42)
 		content := normalizePaneText(m.endpointPane.View())
 		for _, required := range []string{"Mode: local_daemon  Exposure: funnel-public", "Service: unavailable", "Web UI Reason: funnel requires HTTPS on port 443"} {
@@ -230,7 +325,7 @@ func TestScrollKeysOnlyMoveLogsPane(t *testing.T) {
 		},
 	}
 
-	m := NewModel(provider)
+	m := NewModel(provider, Options{})
 	resizeModel(t, &m, 140, 42)
 
 	updateModel(t, &m, LogMsg{Level: "INFO", Message: "line 1", Time: time.Now()})
@@ -273,7 +368,7 @@ func TestViewFitsWithinWindowHeight(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			m := NewModel(provider)
+			m := NewModel(provider, Options{})
 			resizeModel(t, &m, tc.width, tc.height)
 			updateModel(t, &m, LogMsg{
 				Level:   "INFO",
@@ -293,3 +388,174 @@ func TestViewFitsWithinWindowHeight(t *testing.T) {
 		})
 	}
 }
+
+func TestApprovalPromptAnswersOldestRequest(t *testing.T) {
+	m := NewModel(&stubStatsProvider{}, Options{})
+	resizeModel(t, &m, 140, 42)
+
+	first := make(chan bool, 1)
+	second := make(chan bool, 1)
+	updateModel(t, &m, ApprovalMsg{Request: model.SensitiveRequest{Method: "POST", Path: "/admin/users", RemoteAddr: "100.1.2.3:1234", Pattern: "/admin"}, Reply: first})
+	updateModel(t, &m, ApprovalMsg{Request: model.SensitiveRequest{Method: "DELETE", Path: "/admin/db", Pattern: "/admin"}, Reply: second})
+
+	view := ansi.Strip(m.View())
+	if !strings.Contains(view, "Sensitive request POST /admin/users") || !strings.Contains(view, "1 more waiting") {
+		t.Fatalf("expected approval prompt in view, got %q", view)
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	if approved := <-first; !approved {
+		t.Fatalf("expected first request to be approved")
+	}
+	if approved := <-second; approved {
+		t.Fatalf("expected second request to be denied")
+	}
+	if strings.Contains(ansi.Strip(m.View()), "Sensitive request") {
+		t.Fatalf("expected prompt to clear once answered")
+	}
+}
+
+func TestApprovalPromptDropsExpiredRequests(t *testing.T) {
+	m := NewModel(&stubStatsProvider{}, Options{})
+	resizeModel(t, &m, 140, 42)
+
+	updateModel(t, &m, ApprovalMsg{Request: model.SensitiveRequest{Path: "/admin"}, Reply: make(chan bool, 1), Deadline: time.Now().Add(-time.Second)})
+	updateModel(t, &m, tickMsg{})
+	if len(m.approvals) != 0 {
+		t.Fatalf("expected expired approval to be dropped, got %d pending", len(m.approvals))
+	}
+}
+
+func TestAlertBannerShowsFiringAlerts(t *testing.T) {
+	m := NewModel(&stubStatsProvider{}, Options{})
+	resizeModel(t, &m, 140, 42)
+
+	updateModel(t, &m, AlertMsg{Rule: "5xx > 5 in 1m", Firing: true, Value: "7", Time: time.Now()})
+	updateModel(t, &m, AlertMsg{Rule: "p90 > 2s", Firing: true, Value: "2.4s", Time: time.Now()})
+	view := ansi.Strip(m.View())
+	if !strings.Contains(view, "ALERT 5xx > 5 in 1m (7 since") || !strings.Contains(view, "p90 > 2s (2.4s since") {
+		t.Fatalf("expected alert banner in view, got %q", view)
+	}
+
+	updateModel(t, &m, AlertMsg{Rule: "5xx > 5 in 1m", Value: "0"})
+	view = ansi.Strip(m.View())
+	if strings.Contains(view, "5xx > 5") || !strings.Contains(view, "ALERT p90 > 2s") {
+		t.Fatalf("expected resolved alert to leave the banner, got %q", view)
+	}
+}
+
+type stubCapturePauser struct {
+	stubStatsProvider
+	state model.CaptureState
+}
+
+func (s *stubCapturePauser) SetCapturePaused(paused bool) {
+	s.state.Paused = paused
+}
+
+func (s *stubCapturePauser) CaptureState() model.CaptureState {
+	return s.state
+}
+
+func TestPauseKeyTogglesCapture(t *testing.T) {
+	provider := &stubCapturePauser{}
+	m := NewModel(provider, Options{})
+	resizeModel(t, &m, 140, 42)
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	if !provider.state.Paused {
+		t.Fatal("expected 'p' to pause capture")
+	}
+	provider.state.Skipped = 4
+	updateModel(t, &m, tickMsg{})
+	if view := ansi.Strip(m.View()); !strings.Contains(view, "CAPTURE PAUSED: 4 requests not recorded") {
+		t.Fatalf("expected paused banner in view, got %q", view)
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}})
+	if provider.state.Paused || strings.Contains(ansi.Strip(m.View()), "CAPTURE PAUSED") {
+		t.Fatal("expected 'p' again to resume capture and drop the banner")
+	}
+}
+
+type stubStatsResetter struct {
+	stubStatsProvider
+}
+
+func (s *stubStatsResetter) ResetStats() {
+	s.ttl = 0
+}
+
+func TestResetKeyZeroesStats(t *testing.T) {
+	provider := &stubStatsResetter{stubStatsProvider{ttl: 42}}
+	m := NewModel(provider, Options{})
+	resizeModel(t, &m, 140, 42)
+	if view := ansi.Strip(m.statsPane.View()); !strings.Contains(view, "42") {
+		t.Fatalf("expected the stats pane to show the total, got %q", view)
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	if provider.ttl != 0 {
+		t.Fatal("expected 'r' to reset the stats")
+	}
+	if view := ansi.Strip(m.statsPane.View()); strings.Contains(view, "42") {
+		t.Fatalf("expected the stats pane to show the reset counters, got %q", view)
+	}
+}
+
+func TestOpenKeysOpenServiceAndWebUI(t *testing.T) {
+	var opened []string
+	orig := startBrowser
+	t.Cleanup(func() { startBrowser = orig })
+	startBrowser = func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}
+
+	provider := &stubStatsProvider{state: model.EndpointState{ServiceURL: "https://portal.example.ts.net"}}
+	m := NewModel(provider, Options{})
+	resizeModel(t, &m, 140, 42)
+
+	press := func(key rune) {
+		t.Helper()
+		updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{key}})
+		m = updated.(Model)
+		if cmd != nil {
+			updateModel(t, &m, cmd())
+		}
+	}
+	press('o')
+	// The web UI URL is not known yet.
+	press('O')
+	if len(opened) != 1 || opened[0] != "https://portal.example.ts.net" {
+		t.Fatalf("expected only the service URL to be opened, got %q", opened)
+	}
+	logs := ansi.Strip(m.appLogs.View())
+	if !strings.Contains(logs, "Opened https://portal.example.ts.net in the browser") || !strings.Contains(logs, "The web UI URL is not available yet") {
+		t.Fatalf("expected open results in the logs, got %q", logs)
+	}
+
+	provider.state.WebUIURL = "https://portal.example.ts.net:4040"
+	press('O')
+	if len(opened) != 2 || opened[1] != provider.state.WebUIURL {
+		t.Fatalf("expected the web UI URL to be opened, got %q", opened)
+	}
+}
+
+func TestQRCodeToggle(t *testing.T) {
+	provider := &stubStatsProvider{state: model.EndpointState{ServiceURL: "https://portal.example.ts.net"}}
+	m := NewModel(provider, Options{})
+	resizeModel(t, &m, 140, 60)
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}})
+	view := ansi.Strip(m.View())
+	if !strings.Contains(view, "Scan to open https://portal.example.ts.net") || !strings.Contains(view, "█") {
+		t.Fatalf("expected QR code in view, got %q", view)
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}})
+	if strings.Contains(ansi.Strip(m.View()), "Scan to open") {
+		t.Fatalf("expected QR code to be hidden after toggling again")
+	}
+}
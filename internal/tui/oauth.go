@@ -0,0 +1,60 @@
+// internal/tui/oauth.go
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// renderOAuthSection shows a captured OAuth callback at the top of the
+// headers pane. The code and state are printed in full so they can be
+// copied out of the terminal.
+func renderOAuthSection(callback *model.OAuthCallback, lineWidth int) string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("212"))
+	keyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("75"))
+	errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	okStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("46"))
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("OAuth Callback:"))
+	b.WriteString("\n")
+	if callback.Code != "" {
+		b.WriteString(fmt.Sprintf("  %s: %s\n", keyStyle.Render("code"), callback.Code))
+	}
+	if callback.State != "" {
+		b.WriteString(fmt.Sprintf("  %s: %s\n", keyStyle.Render("state"), callback.State))
+	}
+	if callback.Error != "" {
+		b.WriteString(fmt.Sprintf("  %s: %s\n", keyStyle.Render("error"), errStyle.Render(callback.Error)))
+	}
+	if callback.ErrorDescription != "" {
+		b.WriteString(fmt.Sprintf("  %s: %s\n", keyStyle.Render("error_description"), callback.ErrorDescription))
+	}
+
+	if token := callback.Token; token != nil {
+		switch {
+		case token.Error != "":
+			b.WriteString(fmt.Sprintf("  %s: %s\n", keyStyle.Render("token"), errStyle.Render("exchange failed: "+token.Error)))
+		case token.StatusCode >= 300:
+			b.WriteString(fmt.Sprintf("  %s: %s\n", keyStyle.Render("token"), errStyle.Render(fmt.Sprintf("endpoint returned %d", token.StatusCode))))
+		default:
+			b.WriteString(fmt.Sprintf("  %s: %s\n", keyStyle.Render("token"), okStyle.Render(fmt.Sprintf("endpoint returned %d", token.StatusCode))))
+		}
+		keys := make([]string, 0, len(token.Response))
+		for key := range token.Response {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			b.WriteString(fmt.Sprintf("    %s: %s\n", keyStyle.Render(key), truncateString(fmt.Sprint(token.Response[key]), lineWidth)))
+		}
+		if token.Body != "" {
+			b.WriteString("    " + truncateString(token.Body, lineWidth) + "\n")
+		}
+	}
+	return b.String()
+}
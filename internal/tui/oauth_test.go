@@ -0,0 +1,33 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+func TestHeadersPaneShowsOAuthCallback(t *testing.T) {
+	m := NewModel(&stubStatsProvider{}, Options{})
+	resizeModel(t, &m, 160, 40)
+
+	updateModel(t, &m, RequestMsg{Log: model.RequestLog{
+		ID:     "req_1",
+		Method: "GET",
+		URL:    "/callback?code=abc&state=xyz",
+		OAuth: &model.OAuthCallback{
+			Code:  "abc",
+			State: "xyz",
+			Token: &model.OAuthToken{StatusCode: 200, Response: map[string]any{"access_token": "at-123"}},
+		},
+	}})
+
+	view := ansi.Strip(m.View())
+	for _, want := range []string{"OAuth Callback:", "code: abc", "state: xyz", "token: endpoint returned 200", "access_token: at-123"} {
+		if !strings.Contains(view, want) {
+			t.Fatalf("expected %q in view, got %q", want, view)
+		}
+	}
+}
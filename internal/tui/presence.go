@@ -0,0 +1,62 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// PresenceProvider is implemented by servers that know which tailnet users
+// and devices have sent requests, for the "Who's Connected" pane.
+type PresenceProvider interface {
+	Presence() []model.Presence
+}
+
+// togglePresence switches the statistics pane between the connection stats
+// and who has connected.
+func (m *Model) togglePresence() {
+	if _, ok := m.server.(PresenceProvider); !ok {
+		return
+	}
+	m.presence = !m.presence
+	if m.ready {
+		m.updateStatsPane()
+	}
+}
+
+// renderPresence lists the clients seen this session, most recent first,
+// with their request counts and when they were last seen.
+func renderPresence(presence []model.Presence, now time.Time) string {
+	var b strings.Builder
+	b.WriteString(lipgloss.NewStyle().Bold(true).Render("Who's Connected"))
+	b.WriteString("\n\n")
+	if len(presence) == 0 {
+		b.WriteString("No requests from identified clients yet.\n")
+		return b.String()
+	}
+
+	b.WriteString(fmt.Sprintf("%-28s %6s %9s\n", "Client", "reqs", "last seen"))
+	b.WriteString(strings.Repeat("-", 45) + "\n")
+	for _, p := range presence {
+		b.WriteString(fmt.Sprintf("%-28s %6d %9s\n", truncateString(p.Identity.Label(), 28), p.Requests, lastSeen(p.LastSeen, now)))
+	}
+	return b.String()
+}
+
+// lastSeen formats how long ago t was, in the largest whole unit.
+func lastSeen(t, now time.Time) string {
+	ago := now.Sub(t)
+	switch {
+	case ago < 5*time.Second:
+		return "just now"
+	case ago < time.Minute:
+		return fmt.Sprintf("%ds ago", int(ago.Seconds()))
+	case ago < time.Hour:
+		return fmt.Sprintf("%dm ago", int(ago.Minutes()))
+	}
+	return fmt.Sprintf("%dh ago", int(ago.Hours()))
+}
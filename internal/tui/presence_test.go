@@ -0,0 +1,59 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+type presenceStatsProvider struct {
+	stubStatsProvider
+	presence []model.Presence
+}
+
+func (s *presenceStatsProvider) Presence() []model.Presence {
+	return s.presence
+}
+
+func TestPresenceKeyShowsWhoIsConnected(t *testing.T) {
+	now := time.Now()
+	provider := &presenceStatsProvider{presence: []model.Presence{
+		{Identity: model.Identity{Address: "100.64.0.2", Login: "alice@example.com", Device: "laptop"}, Requests: 12, LastSeen: now},
+		{Identity: model.Identity{Address: "100.64.0.9", Device: "ci-runner", Tags: []string{"tag:ci"}}, Requests: 3, LastSeen: now.Add(-3 * time.Minute)},
+	}}
+	m := NewModel(provider, Options{})
+	resizeModel(t, &m, 180, 50)
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	if !strings.Contains(ansi.Strip(m.View()), "Who's Connected") {
+		t.Fatal("expected the pane to be retitled")
+	}
+	content := normalizePaneText(m.statsPane.View())
+	for _, want := range []string{"Who's Connected", "alice@example.com (laptop)", "12", "just now", "tag:ci (ci-runner)", "3m ago"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("expected presence pane to contain %q, got:\n%s", want, content)
+		}
+	}
+
+	updateModel(t, &m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	if content := normalizePaneText(m.statsPane.View()); !strings.Contains(content, "Connection Statistics") {
+		t.Fatalf("expected 'w' again to return to the stats, got:\n%s", content)
+	}
+}
+
+func TestLastSeen(t *testing.T) {
+	now := time.Now()
+	for ago, want := range map[time.Duration]string{
+		time.Second:      "just now",
+		30 * time.Second: "30s ago",
+		90 * time.Minute: "1h ago",
+	} {
+		if got := lastSeen(now.Add(-ago), now); got != want {
+			t.Fatalf("lastSeen(%v) = %q, want %q", ago, got, want)
+		}
+	}
+}
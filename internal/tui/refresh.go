@@ -0,0 +1,124 @@
+// internal/tui/refresh.go
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// DefaultRefresh is how often the dashboard polls the server for
+	// changed stats and endpoint state.
+	DefaultRefresh = time.Second
+	// LowPowerRefresh is the most often the dashboard polls with
+	// Options.LowPower.
+	LowPowerRefresh = 10 * time.Second
+	// lowPowerFPS caps the renderer with Options.LowPower, down from
+	// bubbletea's 60 frames a second.
+	lowPowerFPS = 10
+)
+
+// Options tune how much work the TUI does while it waits.
+type Options struct {
+	// Refresh is how often the stats, endpoint and capture state are
+	// polled; zero means DefaultRefresh. Requests and logs show as they
+	// arrive either way.
+	Refresh time.Duration
+	// LowPower polls at most every LowPowerRefresh and redraws less often,
+	// for long idle waits on battery.
+	LowPower bool
+}
+
+func (o Options) refresh() time.Duration {
+	refresh := o.Refresh
+	if refresh <= 0 {
+		refresh = DefaultRefresh
+	}
+	if o.LowPower {
+		refresh = max(refresh, LowPowerRefresh)
+	}
+	return refresh
+}
+
+// ProgramOptions returns the bubbletea options o needs, to add to those
+// the program is started with.
+func (o Options) ProgramOptions() []tea.ProgramOption {
+	if o.LowPower {
+		return []tea.ProgramOption{tea.WithFPS(lowPowerFPS)}
+	}
+	return nil
+}
+
+// frame keeps the last rendered view. Copies of a Model share it, so a tick
+// that finds nothing changed returns the same screen without rendering it
+// again.
+type frame struct {
+	view  string
+	stale bool
+}
+
+func (f *frame) invalidate() {
+	if f != nil {
+		f.stale = true
+	}
+}
+
+func (m Model) nextTick() tea.Cmd {
+	return tea.Tick(m.refresh, func(time.Time) tea.Msg {
+		return tickMsg{}
+	})
+}
+
+// tick refreshes the panes when the polled state changed since the last
+// tick, or when the screen shows something that changes with time alone.
+// An idle dashboard is otherwise left as it is.
+func (m Model) tick(now time.Time) (tea.Model, tea.Cmd) {
+	state := m.pollState()
+	if state == m.polled && !m.showsClock() {
+		return m, m.nextTick()
+	}
+	m.polled = state
+	m.frame.invalidate()
+	m.dropExpiredApprovals(now)
+	m.refreshCaptureState()
+	if m.ready {
+		m.refreshPaneContent()
+	}
+	return m, m.nextTick()
+}
+
+// pollState summarizes what the dashboard shows from polling the server,
+// formatted as it is displayed so changes too small to see don't count.
+func (m Model) pollState() string {
+	if m.server == nil {
+		return ""
+	}
+	var b strings.Builder
+	ttl, opn, rt1, rt5, p50, p90 := m.server.GetStats()
+	fmt.Fprintf(&b, "%d %d %.1f %.1f %.1f %.1f\n", ttl, opn, rt1, rt5, p50, p90)
+	fmt.Fprintf(&b, "%+v\n", m.server.GetEndpointState())
+	if provider, ok := m.server.(StateProvider); ok {
+		fmt.Fprintf(&b, "%+v\n", provider.State())
+	}
+	if pauser, ok := m.server.(CapturePauser); ok {
+		fmt.Fprintf(&b, "%+v\n", pauser.CaptureState())
+	}
+	if provider, ok := m.server.(SeriesProvider); ok {
+		// The series' start moves on a bucket at a time even while idle,
+		// scrolling the sparklines along with it.
+		series := provider.GetSeries()
+		fmt.Fprintf(&b, "%d %.1f %.0f %.0f\n", series.Start.Unix(), series.RequestsPerSecond, series.P50, series.P90)
+	}
+	return b.String()
+}
+
+// showsClock reports whether the screen shows times that move on their
+// own: approval prompts expiring, when peers were last seen and a JWT's
+// expiry.
+func (m Model) showsClock() bool {
+	return len(m.approvals) > 0 || m.presence ||
+		(m.lastRequest != nil && m.lastRequest.JWT != nil && m.lastRequest.JWT.ExpiresAt != nil)
+}
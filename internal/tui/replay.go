@@ -0,0 +1,144 @@
+// internal/tui/replay.go
+package tui
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// Replayer is optionally implemented by the stats provider to send a
+// captured request again with edits.
+type Replayer interface {
+	Replay(ctx context.Context, id string, edit model.ReplayRequest) (model.RequestLog, error)
+}
+
+// editedRequestMsg reports that the editor opened on a request has exited.
+type editedRequestMsg struct {
+	id   string
+	path string
+	err  error
+	// binaryBody is set when the request's body is binary and so was left
+	// out of the file; an empty body then resends it unchanged.
+	binaryBody bool
+}
+
+// replayedMsg carries the result of a replay back to the TUI.
+type replayedMsg struct {
+	of    string
+	entry model.RequestLog
+	err   error
+}
+
+// editRequest writes log to a temp file as an HTTP request and opens it in
+// $VISUAL or $EDITOR, suspending the TUI until the editor exits.
+func editRequest(log model.RequestLog) tea.Cmd {
+	file, err := os.CreateTemp("", "portal-replay-*.http")
+	if err != nil {
+		return replayFailed(log.ID, fmt.Errorf("failed to create edit file: %w", err))
+	}
+	_, err = file.WriteString(formatRequestForEdit(log))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return replayFailed(log.ID, fmt.Errorf("failed to write edit file: %w", err))
+	}
+
+	editor := strings.Fields(cmp.Or(os.Getenv("VISUAL"), os.Getenv("EDITOR"), "vi"))
+	cmd := exec.Command(editor[0], append(editor[1:], file.Name())...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editedRequestMsg{id: log.ID, path: file.Name(), err: err, binaryBody: len(log.BodyBytes) > 0}
+	})
+}
+
+// replayEdited reads the edited request back and replays it. Saving an empty
+// file cancels the replay.
+func replayEdited(replayer Replayer, msg editedRequestMsg) tea.Cmd {
+	data, readErr := os.ReadFile(msg.path)
+	os.Remove(msg.path)
+	if msg.err != nil {
+		return replayFailed(msg.id, fmt.Errorf("editor failed: %w", msg.err))
+	}
+	if readErr != nil {
+		return replayFailed(msg.id, readErr)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return nil
+	}
+
+	edit, err := parseEditedRequest(string(data))
+	if err != nil {
+		return replayFailed(msg.id, err)
+	}
+	if msg.binaryBody && *edit.Body == "" {
+		edit.Body = nil
+	}
+	return func() tea.Msg {
+		entry, err := replayer.Replay(context.Background(), msg.id, edit)
+		return replayedMsg{of: msg.id, entry: entry, err: err}
+	}
+}
+
+func replayFailed(id string, err error) tea.Cmd {
+	return func() tea.Msg {
+		return replayedMsg{of: id, err: err}
+	}
+}
+
+// formatRequestForEdit renders a request line, the headers a line per
+// value, a blank line and the body.
+func formatRequestForEdit(log model.RequestLog) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", log.Method, log.URL)
+	for _, header := range log.Headers {
+		fmt.Fprintf(&b, "%s: %s\n", header.Name, header.Value)
+	}
+	b.WriteString("\n")
+	b.WriteString(log.Body)
+	return b.String()
+}
+
+// parseEditedRequest reads back the format written by formatRequestForEdit.
+func parseEditedRequest(text string) (model.ReplayRequest, error) {
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(text)))
+	line, err := reader.ReadLine()
+	if err != nil {
+		return model.ReplayRequest{}, fmt.Errorf("missing request line: %w", err)
+	}
+	method, target, ok := strings.Cut(strings.TrimSpace(line), " ")
+	if !ok || strings.TrimSpace(target) == "" {
+		return model.ReplayRequest{}, fmt.Errorf("invalid request line %q: expected METHOD /path", line)
+	}
+
+	mime, err := reader.ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return model.ReplayRequest{}, fmt.Errorf("invalid headers: %w", err)
+	}
+	headers := model.HeadersFrom(http.Header(mime))
+
+	var body strings.Builder
+	if _, err := reader.R.WriteTo(&body); err != nil {
+		return model.ReplayRequest{}, err
+	}
+	bodyText := body.String()
+	return model.ReplayRequest{
+		Method:  method,
+		URL:     strings.TrimSpace(target),
+		Headers: headers,
+		Body:    &bodyText,
+	}, nil
+}
@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+type stubReplayer struct {
+	stubStatsProvider
+	id   string
+	edit model.ReplayRequest
+}
+
+func (s *stubReplayer) Replay(_ context.Context, id string, edit model.ReplayRequest) (model.RequestLog, error) {
+	s.id, s.edit = id, edit
+	return model.RequestLog{ID: "req_2", ReplayOf: id, StatusCode: 201}, nil
+}
+
+func TestEditedRequestRoundTrip(t *testing.T) {
+	text := formatRequestForEdit(model.RequestLog{
+		Method: "POST",
+		URL:    "/orders?x=1",
+		Headers: model.Headers{
+			{Name: "Content-Type", Value: "application/json"},
+			{Name: "X-Tag", Value: "a, b"},
+			{Name: "X-Tag", Value: "c"},
+			{Name: "X-Token", Value: "a"},
+		},
+		Body: "{\"qty\":1}\n",
+	})
+	if !strings.HasPrefix(text, "POST /orders?x=1\nContent-Type: application/json\nX-Tag: a, b\nX-Tag: c\nX-Token: a\n\n") {
+		t.Fatalf("unexpected edit text %q", text)
+	}
+
+	edit, err := parseEditedRequest(strings.Replace(text, "X-Token: a", "X-Token: b", 1))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if edit.Method != "POST" || edit.URL != "/orders?x=1" || edit.Headers.Get("X-Token") != "b" || *edit.Body != "{\"qty\":1}\n" {
+		t.Fatalf("unexpected edit %+v body=%q", edit, *edit.Body)
+	}
+	if tags := edit.Headers.Values("X-Tag"); len(tags) != 2 || tags[0] != "a, b" || tags[1] != "c" {
+		t.Fatalf("expected repeated headers to stay separate, got %q", tags)
+	}
+
+	if _, err := parseEditedRequest("GET\n"); err == nil {
+		t.Fatalf("expected a request line without a path to fail")
+	}
+}
+
+func TestReplayEditedRequest(t *testing.T) {
+	provider := &stubReplayer{}
+	m := NewModel(provider, Options{})
+	resizeModel(t, &m, 140, 42)
+
+	path := filepath.Join(t.TempDir(), "edit.http")
+	if err := os.WriteFile(path, []byte("PUT /orders/7\nX-Token: b\n\n{}"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	updated, cmd := m.Update(editedRequestMsg{id: "req_1", path: path})
+	m = updated.(Model)
+	if cmd == nil {
+		t.Fatalf("expected replay command")
+	}
+	updateModel(t, &m, cmd())
+
+	if provider.id != "req_1" || provider.edit.Method != "PUT" || *provider.edit.Body != "{}" {
+		t.Fatalf("unexpected replay %s %+v", provider.id, provider.edit)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected edit file to be removed")
+	}
+	if view := ansi.Strip(m.View()); !strings.Contains(view, "Replayed req_1 as req_2: 201") {
+		t.Fatalf("expected replay result in logs, got %q", view)
+	}
+}
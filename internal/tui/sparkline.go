@@ -0,0 +1,85 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jaxxstorm/portal/internal/stats"
+)
+
+// SeriesProvider is implemented by servers that keep recent traffic for the
+// stats pane's sparklines.
+type SeriesProvider interface {
+	GetSeries() stats.Series
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders the last width values as block characters scaled to
+// their maximum. Zero values are blank so idle periods stand out.
+func sparkline(values []float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	peak := 0.0
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", width-len(values)))
+	for _, v := range values {
+		if v <= 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		level := int(v / peak * float64(len(sparkBlocks)-1))
+		b.WriteRune(sparkBlocks[min(level, len(sparkBlocks)-1)])
+	}
+	return b.String()
+}
+
+// lastNonZero returns the most recent non-zero value, or zero.
+func lastNonZero(values []float64) float64 {
+	for i := len(values) - 1; i >= 0; i-- {
+		if values[i] > 0 {
+			return values[i]
+		}
+	}
+	return 0
+}
+
+// renderSparklines draws requests per second and p50/p90 latency over the
+// last few minutes, each with its latest value, in width columns.
+func renderSparklines(series stats.Series, width int) string {
+	const labelWidth, valueWidth = 6, 9
+	chartWidth := width - labelWidth - valueWidth
+	if chartWidth < 8 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Last %.0f minutes\n", (stats.SeriesStep * stats.SeriesLength).Minutes())
+	// Latency shows the last bucket that had requests, as an idle bucket
+	// has none.
+	rps := 0.0
+	if n := len(series.RequestsPerSecond); n > 0 {
+		rps = series.RequestsPerSecond[n-1]
+	}
+	rows := []struct {
+		label  string
+		values []float64
+		latest string
+	}{
+		{"req/s", series.RequestsPerSecond, fmt.Sprintf("%7.1f/s", rps)},
+		{"p50", series.P50, fmt.Sprintf("%7.0fms", lastNonZero(series.P50))},
+		{"p90", series.P90, fmt.Sprintf("%7.0fms", lastNonZero(series.P90))},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(&b, "%-*s%s%s\n", labelWidth, row.label, sparkline(row.values, chartWidth), row.latest)
+	}
+	return b.String()
+}
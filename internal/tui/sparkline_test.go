@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/stats"
+)
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]float64{0, 1, 2, 4, 8}, 4); got != "▁▂▄█" {
+		t.Fatalf("unexpected sparkline %q", got)
+	}
+	if got := sparkline([]float64{0, 8}, 4); got != "   █" {
+		t.Fatalf("expected short series to be right-aligned with idle buckets blank, got %q", got)
+	}
+}
+
+type seriesStatsProvider struct {
+	stubStatsProvider
+	series stats.Series
+}
+
+func (s *seriesStatsProvider) GetSeries() stats.Series {
+	return s.series
+}
+
+func TestStatsPaneShowsSparklines(t *testing.T) {
+	provider := &seriesStatsProvider{series: stats.Series{
+		RequestsPerSecond: []float64{1, 2, 4, 0},
+		P50:               []float64{10, 20, 30, 0},
+		P90:               []float64{15, 25, 45, 0},
+	}}
+	m := NewModel(provider, Options{})
+	resizeModel(t, &m, 180, 50)
+
+	content := normalizePaneText(m.statsPane.View())
+	for _, want := range []string{"Last 5 minutes", "req/s", "0.0/s", "30ms", "45ms", "▂▄█"} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("expected stats pane to contain %q, got:\n%s", want, content)
+		}
+	}
+
+	if got := renderSparklines(provider.series, 10); got != "" {
+		t.Fatalf("expected no sparklines in a narrow pane, got %q", got)
+	}
+}
+
+func TestTickScrollsSparklinesWhileIdle(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0).UTC()
+	provider := &seriesStatsProvider{series: stats.Series{
+		Start:             start,
+		StepSeconds:       stats.SeriesStep.Seconds(),
+		RequestsPerSecond: []float64{0, 0, 0, 0},
+		P50:               []float64{0, 0, 0, 0},
+		P90:               []float64{0, 0, 0, 0},
+	}}
+	m := NewModel(provider, Options{})
+	resizeModel(t, &m, 180, 50)
+	updateModel(t, &m, tickMsg{})
+	m.View()
+
+	updateModel(t, &m, tickMsg{})
+	if m.frame.stale {
+		t.Fatal("expected an idle tick within a bucket to keep the last frame")
+	}
+
+	// No requests arrive, but the window moves on a bucket.
+	provider.series.Start = start.Add(stats.SeriesStep)
+	updateModel(t, &m, tickMsg{})
+	if !m.frame.stale {
+		t.Fatal("expected a tick after the series moved on to redraw")
+	}
+}
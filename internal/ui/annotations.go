@@ -0,0 +1,148 @@
+// internal/ui/annotations.go
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/annotations"
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// AnnotationProvider is optionally implemented by log providers that keep
+// notes, tags and stars on requests, attributed to the tailnet user viewing
+// the web UI.
+type AnnotationProvider interface {
+	Annotations() *annotations.Store
+	Viewer(r *http.Request) *model.Identity
+}
+
+func (s *Server) annotationProvider(w http.ResponseWriter) (AnnotationProvider, bool) {
+	provider, ok := s.logProvider.(AnnotationProvider)
+	if !ok || provider.Annotations() == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "annotations not available"})
+		return nil, false
+	}
+	return provider, true
+}
+
+// handleAnnotations returns every annotation along with who the viewer is,
+// so the UI can tell their own marks from everyone else's.
+func (s *Server) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	provider, ok := s.annotationProvider(w)
+	if !ok {
+		return
+	}
+	list := provider.Annotations().List()
+	if list == nil {
+		list = []model.Annotation{}
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"viewer":      provider.Viewer(r),
+		"annotations": list,
+	})
+}
+
+// handleAnnotation sets the viewer's annotation on request id with PUT and
+// removes it with DELETE.
+func (s *Server) handleAnnotation(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	provider, ok := s.annotationProvider(w)
+	if !ok {
+		return
+	}
+	viewer := provider.Viewer(r)
+	if viewer == nil {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "could not identify the viewer"})
+		return
+	}
+
+	annotation := model.Annotation{RequestID: id, Author: *viewer}
+	if r.Method == http.MethodPut {
+		var update struct {
+			Note    string   `json:"note"`
+			Tags    []string `json:"tags"`
+			Starred bool     `json:"starred"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid annotation JSON"})
+			return
+		}
+		annotation.Note, annotation.Tags, annotation.Starred = update.Note, update.Tags, update.Starred
+	}
+	stored, err := provider.Annotations().Put(annotation)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if stored.Empty() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	json.NewEncoder(w).Encode(stored)
+}
+
+// handleAnnotationStream streams annotation changes as server-sent events,
+// one JSON annotation per event. A removed annotation is sent empty.
+func (s *Server) handleAnnotationStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	provider, ok := s.annotationProvider(w)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "streaming not supported"})
+		return
+	}
+
+	changes, cancel := provider.Annotations().Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(termKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case annotation, ok := <-changes:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and
+				// reloads the annotations.
+				return
+			}
+			data, _ := json.Marshal(annotation)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
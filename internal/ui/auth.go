@@ -0,0 +1,81 @@
+// internal/ui/auth.go
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/jaxxstorm/portal/internal/apitoken"
+	"github.com/jaxxstorm/portal/internal/httputil"
+)
+
+// APITokenProvider is optionally implemented by log providers that require
+// API tokens for mutating endpoints.
+type APITokenProvider interface {
+	APITokens() *apitoken.Store
+}
+
+// tailnetOnly refuses requests that arrived over Funnel, returning false
+// after writing a 403. With --ui-path the UI shares the serve port with the
+// service, which may be on Funnel, but the UI is only for the tailnet.
+func tailnetOnly(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get(httputil.FunnelRequestHeader) == "" {
+		return true
+	}
+	http.Error(w, "The web UI is only available on the tailnet", http.StatusForbidden)
+	return false
+}
+
+// requiredScope returns the token scope needed for a request, or "" for
+// read-only requests. Runtime profiles always need admin.
+func requiredScope(method, apiPath string) string {
+	if strings.HasPrefix(apiPath, pprofPrefix) {
+		return apitoken.ScopeAdmin
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ""
+	}
+	switch {
+	case apiPath == "/api/requests", strings.HasPrefix(apiPath, "/api/requests/"), apiPath == "/api/capture/pause", apiPath == "/api/capture/bodies", apiPath == "/api/stats/reset", apiPath == "/api/schemas", strings.HasPrefix(apiPath, "/api/filters/"):
+		return apitoken.ScopeRequests
+	case strings.HasPrefix(apiPath, "/api/mock/"):
+		return apitoken.ScopeMock
+	default:
+		return apitoken.ScopeAdmin
+	}
+}
+
+// authorize checks the bearer token for mutating requests once any API token
+// exists, writing a 401 or 403 and returning false when the request may not
+// proceed.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, apiPath string) bool {
+	scope := requiredScope(r.Method, apiPath)
+	if scope == "" {
+		return true
+	}
+	provider, ok := s.logProvider.(APITokenProvider)
+	if !ok {
+		return true
+	}
+	tokens := provider.APITokens()
+	if !tokens.Enabled() {
+		return true
+	}
+
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token, valid := tokens.Verify(strings.TrimSpace(secret))
+	if !ok || !valid {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="portal"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "a valid API token is required"})
+		return false
+	}
+	if !token.Allows(scope) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": "token " + token.ID + " lacks the " + scope + " scope"})
+		return false
+	}
+	return true
+}
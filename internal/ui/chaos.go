@@ -0,0 +1,105 @@
+// internal/ui/chaos.go
+package ui
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jaxxstorm/portal/internal/chaos"
+)
+
+// ChaosProvider is optionally implemented by log providers that inject
+// failures into matching requests.
+type ChaosProvider interface {
+	Chaos() *chaos.Engine
+}
+
+// handleChaosRules serves CRUD for chaos rules: the collection when id is
+// empty, otherwise a single rule. Rules are sent as {"expr": "5% POST
+// /payments 502"}.
+func (s *Server) handleChaosRules(w http.ResponseWriter, r *http.Request, id string) {
+	var engine *chaos.Engine
+	if provider, ok := s.logProvider.(ChaosProvider); ok {
+		engine = provider.Chaos()
+	}
+	if engine == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "chaos rules not available"})
+		return
+	}
+
+	writeRuleError := func(err error) {
+		status := http.StatusBadRequest
+		if errors.Is(err, chaos.ErrRuleNotFound) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+	decodeExpr := func() (string, bool) {
+		var rule struct {
+			Expr string `json:"expr"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid chaos rule JSON"})
+			return "", false
+		}
+		return rule.Expr, true
+	}
+	find := func() (chaos.Rule, bool) {
+		for _, rule := range engine.Rules() {
+			if rule.ID == id {
+				return rule, true
+			}
+		}
+		writeRuleError(chaos.ErrRuleNotFound)
+		return chaos.Rule{}, false
+	}
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		rules := engine.Rules()
+		if rules == nil {
+			rules = []chaos.Rule{}
+		}
+		json.NewEncoder(w).Encode(rules)
+	case id == "" && r.Method == http.MethodPost:
+		expr, ok := decodeExpr()
+		if !ok {
+			return
+		}
+		created, err := engine.Add(expr)
+		if err != nil {
+			writeRuleError(err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	case id != "" && r.Method == http.MethodGet:
+		if rule, ok := find(); ok {
+			json.NewEncoder(w).Encode(rule)
+		}
+	case id != "" && r.Method == http.MethodPut:
+		expr, ok := decodeExpr()
+		if !ok {
+			return
+		}
+		updated, err := engine.Update(id, expr)
+		if err != nil {
+			writeRuleError(err)
+			return
+		}
+		json.NewEncoder(w).Encode(updated)
+	case id != "" && r.Method == http.MethodDelete:
+		if err := engine.Delete(id); err != nil {
+			writeRuleError(err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
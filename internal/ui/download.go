@@ -0,0 +1,148 @@
+// internal/ui/download.go
+package ui
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/jaxxstorm/portal/internal/model"
+)
+
+// bodyExtensions are the file extensions of downloaded bodies by media
+// type, ahead of the platform's MIME table, which varies between systems.
+var bodyExtensions = map[string]string{
+	"application/json":                  ".json",
+	"application/xml":                   ".xml",
+	"text/xml":                          ".xml",
+	"text/html":                         ".html",
+	"text/plain":                        ".txt",
+	"text/csv":                          ".csv",
+	"text/css":                          ".css",
+	"text/javascript":                   ".js",
+	"application/javascript":            ".js",
+	"application/x-www-form-urlencoded": ".txt",
+	"application/pdf":                   ".pdf",
+	"application/zip":                   ".zip",
+	"application/gzip":                  ".gz",
+	"application/octet-stream":          ".bin",
+	"image/png":                         ".png",
+	"image/jpeg":                        ".jpg",
+	"image/gif":                         ".gif",
+	"image/webp":                        ".webp",
+	"image/svg+xml":                     ".svg",
+}
+
+// encodingExtensions are appended to the names of bodies captured with a
+// Content-Encoding, which are kept as sent.
+var encodingExtensions = map[string]string{
+	"gzip":    ".gz",
+	"br":      ".br",
+	"zstd":    ".zst",
+	"deflate": ".zz",
+}
+
+// handleBodyDownload serves the captured request or response body of a
+// request as a file. side is "request" or "response".
+func (s *Server) handleBodyDownload(w http.ResponseWriter, r *http.Request, id, side string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.logProvider == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "log provider not available"})
+		return
+	}
+	var entry *model.RequestLog
+	for _, candidate := range s.logProvider.GetRequestLogs() {
+		if candidate.ID == id {
+			entry = &candidate
+			break
+		}
+	}
+	if entry == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": model.ErrRequestNotFound.Error()})
+		return
+	}
+
+	var (
+		body      []byte
+		headers   model.Headers
+		truncated bool
+	)
+	switch side {
+	case "request":
+		body, headers, truncated = entry.BodyBytes, entry.Headers, entry.BodyTruncated
+		if body == nil {
+			body = []byte(entry.Body)
+		}
+	case "response":
+		body, headers, truncated = entry.Response.BodyBytes, entry.Response.Headers, entry.Response.BodyTruncated
+		if body == nil {
+			body = []byte(entry.Response.Body)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "body must be request or response"})
+		return
+	}
+	if len(body) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "no " + side + " body was captured"})
+		return
+	}
+
+	contentType := headers.Get("Content-Type")
+	if encoding := strings.Join(headers.Values("Content-Encoding"), ", "); encoding != "" && encoding != "identity" {
+		// The captured bytes are still encoded.
+		contentType = ""
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": bodyFilename(id, side, headers),
+	}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if truncated {
+		// Only a prefix of the body was captured.
+		w.Header().Set("X-Portal-Body-Truncated", "true")
+	}
+	_, _ = w.Write(body)
+}
+
+// bodyFilename names a downloaded body: the filename the message's own
+// Content-Disposition gave, else the request ID and side with an extension
+// for its Content-Type, plus one for its Content-Encoding.
+func bodyFilename(id, side string, headers model.Headers) string {
+	encoding := ""
+	if value := strings.Join(headers.Values("Content-Encoding"), ", "); value != "" && value != "identity" {
+		var ok bool
+		if encoding, ok = encodingExtensions[strings.ToLower(value)]; !ok {
+			encoding = ".bin"
+		}
+	}
+	if _, params, err := mime.ParseMediaType(headers.Get("Content-Disposition")); err == nil {
+		name := path.Base(strings.ReplaceAll(params["filename"], `\`, "/"))
+		if name != "" && name != "." && name != "/" {
+			return name + encoding
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(headers.Get("Content-Type"))
+	extension, ok := bodyExtensions[mediaType]
+	if !ok {
+		extension = ".bin"
+		if extensions, _ := mime.ExtensionsByType(mediaType); len(extensions) > 0 {
+			extension = extensions[0]
+		}
+	}
+	return "portal-" + id + "-" + side + extension + encoding
+}
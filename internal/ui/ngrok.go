@@ -0,0 +1,108 @@
+// internal/ui/ngrok.go
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ngrokTunnelName is the name the ngrok agent gives a tunnel started from
+// the command line, which is what scripts querying it usually expect.
+const ngrokTunnelName = "command_line"
+
+// TargetProvider is optionally implemented by log providers that forward to
+// an upstream, reporting its URL.
+type TargetProvider interface {
+	TargetURL() string
+}
+
+// ngrokTunnel is the subset of the ngrok agent API's tunnel object that
+// tools commonly read.
+type ngrokTunnel struct {
+	Name      string            `json:"name"`
+	ID        string            `json:"ID"`
+	URI       string            `json:"uri"`
+	PublicURL string            `json:"public_url"`
+	Proto     string            `json:"proto"`
+	Config    ngrokTunnelConfig `json:"config"`
+	Metrics   ngrokMetrics      `json:"metrics"`
+}
+
+type ngrokTunnelConfig struct {
+	Addr    string `json:"addr"`
+	Inspect bool   `json:"inspect"`
+}
+
+type ngrokMetrics struct {
+	Conns ngrokCounter `json:"conns"`
+	HTTP  ngrokCounter `json:"http"`
+}
+
+type ngrokCounter struct {
+	Count int `json:"count"`
+	Gauge int `json:"gauge,omitempty"`
+}
+
+// handleNgrokTunnels serves GET /api/tunnels and /api/tunnels/{name} in the
+// shape of the ngrok agent API, so tools that ask ngrok for the public URL
+// work against portal unchanged.
+func (s *Server) handleNgrokTunnels(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	tunnels := []ngrokTunnel{}
+	if tunnel, ok := s.ngrokTunnel(); ok {
+		tunnels = append(tunnels, tunnel)
+	}
+
+	if name == "" {
+		json.NewEncoder(w).Encode(map[string]any{"tunnels": tunnels, "uri": "/api/tunnels"})
+		return
+	}
+	for _, tunnel := range tunnels {
+		if tunnel.Name == name {
+			json.NewEncoder(w).Encode(tunnel)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]any{"error_code": 100, "status_code": http.StatusNotFound, "msg": "Tunnel not found"})
+}
+
+// ngrokTunnel describes the service URL as an ngrok tunnel, once it is known.
+func (s *Server) ngrokTunnel() (ngrokTunnel, bool) {
+	provider, ok := s.logProvider.(EndpointProvider)
+	if !ok {
+		return ngrokTunnel{}, false
+	}
+	publicURL := provider.GetEndpointState().ServiceURL
+	if publicURL == "" {
+		return ngrokTunnel{}, false
+	}
+
+	proto := "http"
+	if strings.HasPrefix(publicURL, "https://") {
+		proto = "https"
+	}
+	tunnel := ngrokTunnel{
+		Name:      ngrokTunnelName,
+		ID:        "portal",
+		URI:       "/api/tunnels/" + ngrokTunnelName,
+		PublicURL: publicURL,
+		Proto:     proto,
+		Config:    ngrokTunnelConfig{Inspect: true},
+	}
+	if target, ok := s.logProvider.(TargetProvider); ok {
+		tunnel.Config.Addr = target.TargetURL()
+	}
+	if s.logProvider != nil {
+		total, open, _, _, _, _ := s.logProvider.GetStats()
+		tunnel.Metrics.Conns = ngrokCounter{Count: total, Gauge: open}
+		tunnel.Metrics.HTTP = ngrokCounter{Count: total}
+	}
+	return tunnel, true
+}
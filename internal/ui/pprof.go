@@ -0,0 +1,43 @@
+// internal/ui/pprof.go
+package ui
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// PprofProvider is optionally implemented by log providers that expose Go
+// runtime profiles on the web UI.
+type PprofProvider interface {
+	PprofEnabled() bool
+}
+
+// pprofPrefix is where runtime profiles are served when enabled.
+const pprofPrefix = "/debug/pprof/"
+
+// handlePprof serves net/http/pprof. Profiles reveal internals, so once API
+// tokens exist they require the admin scope like other privileged calls.
+func (s *Server) handlePprof(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.logProvider.(PprofProvider)
+	if !ok || !provider.PprofEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+	if !s.authorize(w, r, r.URL.Path) {
+		return
+	}
+
+	switch strings.TrimPrefix(r.URL.Path, pprofPrefix) {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Index(w, r)
+	}
+}
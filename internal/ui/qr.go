@@ -0,0 +1,33 @@
+// internal/ui/qr.go
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jaxxstorm/portal/internal/qr"
+)
+
+// qrImageSize is the width and height of the service URL QR code in pixels.
+const qrImageSize = 256
+
+// handleQR serves a PNG QR code of the service URL so it can be opened on a
+// phone.
+func (s *Server) handleQR(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.logProvider.(EndpointProvider)
+	if !ok || provider.GetEndpointState().ServiceURL == "" {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "service URL not ready"})
+		return
+	}
+
+	image, err := qr.PNG(provider.GetEndpointState().ServiceURL, qrImageSize)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(image)
+}
@@ -2,15 +2,23 @@
 package ui
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jaxxstorm/portal/internal/capture"
+	"github.com/jaxxstorm/portal/internal/filters"
+	"github.com/jaxxstorm/portal/internal/mock"
 	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/stats"
 )
 
 // LogProvider interface for getting request logs and stats
@@ -20,6 +28,136 @@ type LogProvider interface {
 	ClearRequestLogs()
 }
 
+// EndpointProvider is optionally implemented by log providers that track
+// endpoint and upstream readiness.
+type EndpointProvider interface {
+	GetEndpointState() model.EndpointState
+}
+
+// MockStoreProvider is optionally implemented by log providers running mock
+// mode with an in-memory resource store.
+type MockStoreProvider interface {
+	MockStore() *mock.Store
+}
+
+// MockEngineProvider is optionally implemented by log providers running mock
+// mode, enabling runtime edits to mock rules.
+type MockEngineProvider interface {
+	MockEngine() *mock.Engine
+}
+
+// ViolationProvider is optionally implemented by log providers that enforce
+// request limits and count rejected requests.
+type ViolationProvider interface {
+	GetViolations() map[string]int
+}
+
+// FailureProvider is optionally implemented by log providers that count
+// failed upstream requests.
+type FailureProvider interface {
+	GetUpstreamFailures() map[string]int
+}
+
+// UnmatchedProvider is optionally implemented by log providers that route
+// by mount and count requests no mount matched.
+type UnmatchedProvider interface {
+	GetUnmatched() map[string]int
+}
+
+// CountryProvider is optionally implemented by log providers that look up
+// where public clients are and count their requests by country.
+type CountryProvider interface {
+	GetCountries() map[string]int
+}
+
+// QuotaProvider is optionally implemented by log providers that enforce
+// per-client request quotas.
+type QuotaProvider interface {
+	GetQuotas() []model.QuotaUsage
+}
+
+// ConnectionStatsProvider is optionally implemented by log providers that
+// track client connections as well as requests.
+type ConnectionStatsProvider interface {
+	GetConnectionStats() model.ConnectionStats
+}
+
+// SearchProvider is optionally implemented by log providers that index
+// captured bodies for full-text search.
+type SearchProvider interface {
+	SearchRequests(query string, limit int) []model.RequestLog
+}
+
+// SchemaProvider is optionally implemented by log providers that infer
+// payload schemas from the JSON bodies they receive.
+type SchemaProvider interface {
+	GetSchemas() []model.EndpointSchema
+	ResetSchemas()
+}
+
+// PresenceProvider is optionally implemented by log providers that know which
+// tailnet users and devices have sent requests.
+type PresenceProvider interface {
+	Presence() []model.Presence
+}
+
+// SeriesProvider is an optional interface for servers that keep bucketed
+// traffic history for the dashboard charts.
+type SeriesProvider interface {
+	GetSeries() stats.Series
+}
+
+// CapturePauser is optionally implemented by log providers that can stop
+// keeping new requests in the history without interrupting traffic.
+type CapturePauser interface {
+	SetCapturePaused(paused bool)
+	CaptureState() model.CaptureState
+}
+
+// BodyCapturer is optionally implemented by log providers that can stop
+// keeping request or response bodies while still capturing requests.
+type BodyCapturer interface {
+	SetBodyCapture(request, response bool)
+	CaptureState() model.CaptureState
+}
+
+// StateProvider is optionally implemented by log providers that report their
+// mode, target and URLs in one snapshot.
+type StateProvider interface {
+	State() model.ServerState
+}
+
+// StatsSnapshotter is optionally implemented by log providers that report
+// stats with the period they cover and can start a new period.
+type StatsSnapshotter interface {
+	StatsSnapshot() stats.StatsSnapshot
+	ResetStats()
+}
+
+// RequestImporter is optionally implemented by log providers that accept
+// previously captured requests, as sent by "portal import".
+type RequestImporter interface {
+	ImportRequestLogs([]model.RequestLog) int
+}
+
+// Replayer is optionally implemented by log providers that can send a
+// captured request again, optionally edited.
+type Replayer interface {
+	Replay(ctx context.Context, id string, edit model.ReplayRequest) (model.RequestLog, error)
+}
+
+// SequenceReplayer is optionally implemented by log providers that can replay
+// a selection of captured requests in order.
+type SequenceReplayer interface {
+	ReplaySequence(ctx context.Context, seq model.SequenceReplay) []model.ReplayOutcome
+}
+
+// SavedFilterProvider is optionally implemented by log providers that keep
+// the request list's saved filters.
+type SavedFilterProvider interface {
+	SavedFilters() *filters.Store
+}
+
 // Server serves the web dashboard UI
 type Server struct {
 	logProvider LogProvider
@@ -43,11 +181,19 @@ func NewServer(logProvider LogProvider, uiFS fs.FS) *Server {
 
 // ServeHTTP implements the http.Handler interface
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !tailnetOnly(w, r) {
+		return
+	}
+
 	// API endpoints
 	if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/ui/api/") {
 		s.handleAPI(w, r)
 		return
 	}
+	if strings.HasPrefix(r.URL.Path, pprofPrefix) {
+		s.handlePprof(w, r)
+		return
+	}
 
 	// Static files
 	s.handleStatic(w, r)
@@ -61,7 +207,7 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Vary", "Origin")
 	}
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
@@ -73,7 +219,54 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 		apiPath = strings.TrimPrefix(apiPath, "/ui")
 	}
 
+	if !s.authorize(w, r, apiPath) {
+		return
+	}
+
+	if apiPath == "/api/mock/scenarios" || strings.HasPrefix(apiPath, "/api/mock/scenarios/") {
+		s.handleMockScenarios(w, r, strings.TrimPrefix(strings.TrimPrefix(apiPath, "/api/mock/scenarios"), "/"))
+		return
+	}
+	if apiPath == "/api/mock/rules" || strings.HasPrefix(apiPath, "/api/mock/rules/") {
+		s.handleMockRules(w, r, strings.TrimPrefix(strings.TrimPrefix(apiPath, "/api/mock/rules"), "/"))
+		return
+	}
+
+	if apiPath == "/api/chaos/rules" || strings.HasPrefix(apiPath, "/api/chaos/rules/") {
+		s.handleChaosRules(w, r, strings.TrimPrefix(strings.TrimPrefix(apiPath, "/api/chaos/rules"), "/"))
+		return
+	}
+
+	if id, ok := strings.CutSuffix(strings.TrimPrefix(apiPath, "/api/requests/"), "/annotation"); ok && strings.HasPrefix(apiPath, "/api/requests/") {
+		s.handleAnnotation(w, r, id)
+		return
+	}
+
+	if id, ok := strings.CutSuffix(strings.TrimPrefix(apiPath, "/api/requests/"), "/replay"); ok && strings.HasPrefix(apiPath, "/api/requests/") {
+		s.handleReplay(w, r, id)
+		return
+	}
+
+	if rest, ok := strings.CutPrefix(apiPath, "/api/requests/"); ok {
+		if id, side, ok := strings.Cut(rest, "/body/"); ok && id != "" {
+			s.handleBodyDownload(w, r, id, side)
+			return
+		}
+	}
+
+	if apiPath == "/api/filters" || strings.HasPrefix(apiPath, "/api/filters/") {
+		s.handleSavedFilters(w, r, strings.TrimPrefix(strings.TrimPrefix(apiPath, "/api/filters"), "/"))
+		return
+	}
+
+	if apiPath == "/api/tunnels" || strings.HasPrefix(apiPath, "/api/tunnels/") {
+		s.handleNgrokTunnels(w, r, strings.TrimPrefix(strings.TrimPrefix(apiPath, "/api/tunnels"), "/"))
+		return
+	}
+
 	switch apiPath {
+	case "/api/term/stream":
+		s.handleTermStream(w, r)
 	case "/api/requests":
 		if r.Method == http.MethodDelete {
 			if s.logProvider == nil {
@@ -97,6 +290,18 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 		}
 		requests := s.logProvider.GetRequestLogs()
 		json.NewEncoder(w).Encode(requests)
+	case "/api/qr.png":
+		s.handleQR(w, r)
+	case "/api/requests/import":
+		s.handleImportRequests(w, r)
+	case "/api/schemas":
+		s.handleSchemas(w, r)
+	case "/api/search":
+		s.handleSearch(w, r)
+	case "/api/export":
+		s.handleExport(w, r)
+	case "/api/requests/replay":
+		s.handleReplaySequence(w, r)
 	case "/api/stats":
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -117,7 +322,66 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 			"p50_response_time":    p50,
 			"p90_response_time":    p90,
 		}
+		if provider, ok := s.logProvider.(ViolationProvider); ok {
+			stats["limit_violations"] = provider.GetViolations()
+		}
+		if provider, ok := s.logProvider.(FailureProvider); ok {
+			stats["upstream_failures"] = provider.GetUpstreamFailures()
+		}
+		if provider, ok := s.logProvider.(UnmatchedProvider); ok {
+			stats["unmatched_routes"] = provider.GetUnmatched()
+		}
+		if provider, ok := s.logProvider.(CountryProvider); ok {
+			stats["countries"] = provider.GetCountries()
+		}
+		if provider, ok := s.logProvider.(QuotaProvider); ok {
+			stats["quotas"] = provider.GetQuotas()
+		}
 		json.NewEncoder(w).Encode(stats)
+	case "/api/capture/pause":
+		s.handleCapturePause(w, r)
+	case "/api/capture/bodies":
+		s.handleBodyCapture(w, r)
+	case "/api/state":
+		s.handleState(w, r)
+	case "/api/presence":
+		s.handlePresence(w, r)
+	case "/api/annotations":
+		s.handleAnnotations(w, r)
+	case "/api/annotations/stream":
+		s.handleAnnotationStream(w, r)
+	case "/api/stats/snapshot":
+		s.handleStatsSnapshot(w, r)
+	case "/api/stats/reset":
+		s.handleStatsReset(w, r)
+	case "/api/stats/timeseries":
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+			return
+		}
+		provider, ok := s.logProvider.(SeriesProvider)
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"error": "stats provider not available"})
+			return
+		}
+		json.NewEncoder(w).Encode(provider.GetSeries())
+	case "/api/stats/connections":
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+			return
+		}
+		provider, ok := s.logProvider.(ConnectionStatsProvider)
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"error": "stats provider not available"})
+			return
+		}
+		json.NewEncoder(w).Encode(provider.GetConnectionStats())
+	case "/api/mock/store":
+		s.handleMockStore(w, r)
 	case "/api/health":
 		// Health check endpoint
 		health := map[string]interface{}{
@@ -129,12 +393,544 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 			requests := s.logProvider.GetRequestLogs()
 			health["request_count"] = len(requests)
 		}
+		if provider, ok := s.logProvider.(EndpointProvider); ok {
+			endpoint := provider.GetEndpointState()
+			if upstream := endpoint.Upstream; upstream != "" {
+				health["upstream"] = upstream
+				if upstream != model.UpstreamReachable {
+					health["status"] = "degraded"
+				}
+			}
+			if endpoint.Cert != "" {
+				health["cert"] = endpoint.Cert
+			}
+			if endpoint.Mounts != "" {
+				health["mounts"] = endpoint.Mounts
+			}
+			if endpoint.Exposes != "" {
+				health["exposes"] = endpoint.Exposes
+			}
+			if endpoint.ServiceURL != "" {
+				health["service_url"] = endpoint.ServiceURL
+			}
+		}
 		json.NewEncoder(w).Encode(health)
 	default:
 		http.NotFound(w, r)
 	}
 }
 
+// handleMockScenarios lists scenario positions and resets them. rest is the
+// path after /api/mock/scenarios: "", "reset" or "{id}/reset".
+func (s *Server) handleMockScenarios(w http.ResponseWriter, r *http.Request, rest string) {
+	engine := s.mockEngine()
+	if engine == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "mock mode not enabled"})
+		return
+	}
+
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(engine.Scenarios())
+	case (rest == "reset" || strings.HasSuffix(rest, "/reset")) && r.Method == http.MethodPost:
+		id := strings.TrimSuffix(strings.TrimSuffix(rest, "reset"), "/")
+		if err := engine.ResetScenario(id); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(engine.Scenarios())
+	case rest == "" || rest == "reset" || strings.HasSuffix(rest, "/reset"):
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) mockEngine() *mock.Engine {
+	if provider, ok := s.logProvider.(MockEngineProvider); ok {
+		return provider.MockEngine()
+	}
+	return nil
+}
+
+// handleMockRules serves CRUD for mock rules: the collection when id is empty,
+// otherwise a single rule.
+func (s *Server) handleMockRules(w http.ResponseWriter, r *http.Request, id string) {
+	engine := s.mockEngine()
+	if engine == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "mock mode not enabled"})
+		return
+	}
+
+	writeRuleError := func(err error) {
+		status := http.StatusBadRequest
+		if errors.Is(err, mock.ErrRuleNotFound) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+	decodeRule := func() (mock.Rule, bool) {
+		var rule mock.Rule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid rule JSON"})
+			return rule, false
+		}
+		return rule, true
+	}
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(engine.Rules())
+	case id == "" && r.Method == http.MethodPost:
+		rule, ok := decodeRule()
+		if !ok {
+			return
+		}
+		created, err := engine.AddRule(rule)
+		if err != nil {
+			writeRuleError(err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	case id != "" && r.Method == http.MethodGet:
+		rule, err := engine.Rule(id)
+		if err != nil {
+			writeRuleError(err)
+			return
+		}
+		json.NewEncoder(w).Encode(rule)
+	case id != "" && r.Method == http.MethodPut:
+		rule, ok := decodeRule()
+		if !ok {
+			return
+		}
+		updated, err := engine.UpdateRule(id, rule)
+		if err != nil {
+			writeRuleError(err)
+			return
+		}
+		json.NewEncoder(w).Encode(updated)
+	case id != "" && r.Method == http.MethodDelete:
+		if err := engine.DeleteRule(id); err != nil {
+			writeRuleError(err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
+
+// handleReplay resends request id with the edits in the JSON body and
+// returns the new request log entry.
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	replayer, ok := s.logProvider.(Replayer)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "replay not available"})
+		return
+	}
+
+	var edit model.ReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid replay JSON"})
+		return
+	}
+	entry, err := replayer.Replay(r.Context(), id, edit)
+	if errors.Is(err, model.ErrRequestNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(entry)
+}
+
+// handleReplaySequence replays the requests listed in the JSON body and
+// returns one outcome per request once the sequence has finished.
+func (s *Server) handleReplaySequence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	replayer, ok := s.logProvider.(SequenceReplayer)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "replay not available"})
+		return
+	}
+
+	var seq model.SequenceReplay
+	if err := json.NewDecoder(r.Body).Decode(&seq); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid replay JSON"})
+		return
+	}
+	if len(seq.IDs) == 0 || seq.Rate < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "ids are required and rate must not be negative"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"outcomes": replayer.ReplaySequence(r.Context(), seq)})
+}
+
+// handleImportRequests accepts a JSON array of request logs and adds them to
+// the request history.
+func (s *Server) handleImportRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	importer, ok := s.logProvider.(RequestImporter)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "request import not available"})
+		return
+	}
+
+	var entries []model.RequestLog
+	if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid request log JSON"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]int{"imported": importer.ImportRequestLogs(entries)})
+}
+
+// handleSavedFilters lists the saved filters on GET /api/filters, and saves
+// or deletes one with PUT or DELETE /api/filters/{name}.
+func (s *Server) handleSavedFilters(w http.ResponseWriter, r *http.Request, name string) {
+	var store *filters.Store
+	if provider, ok := s.logProvider.(SavedFilterProvider); ok {
+		store = provider.SavedFilters()
+	}
+	if store == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "saved filters not available"})
+		return
+	}
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(store.List())
+	case name != "" && r.Method == http.MethodPut:
+		var filter filters.Filter
+		if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid filter JSON"})
+			return
+		}
+		filter.Name = name
+		if err := store.Put(filter); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(store.List())
+	case name != "" && r.Method == http.MethodDelete:
+		if err := store.Delete(name); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, filters.ErrFilterNotFound) {
+				status = http.StatusNotFound
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
+
+// handleExport writes the request history as NDJSON (the default) or as CSV
+// with the columns given in ?columns=.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.logProvider == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "log provider not available"})
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	var columns []string
+	switch format {
+	case "", capture.FormatNDJSON:
+		format = capture.FormatNDJSON
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	case capture.FormatCSV:
+		var err error
+		if columns, err = capture.ParseCSVColumns(r.URL.Query().Get("columns")); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "format must be ndjson or csv"})
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="portal-requests.%s"`, format))
+	_ = capture.Export(w, format, s.logProvider.GetRequestLogs(), columns)
+}
+
+// handleSchemas lists the inferred payload schemas on GET and forgets them
+// on DELETE.
+func (s *Server) handleSchemas(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.logProvider.(SchemaProvider)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "schemas not available"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(provider.GetSchemas())
+	case http.MethodDelete:
+		provider.ResetSchemas()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
+
+// Search result limits: the default and the most a request can ask for.
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+// handleSearch returns the captured requests whose bodies match q, newest
+// first.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	provider, ok := s.logProvider.(SearchProvider)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "search not available"})
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "q is required"})
+		return
+	}
+	limit := defaultSearchLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "limit must be a positive number"})
+			return
+		}
+		limit = min(n, maxSearchLimit)
+	}
+	results := provider.SearchRequests(query, limit)
+	if results == nil {
+		results = []model.RequestLog{}
+	}
+	json.NewEncoder(w).Encode(results)
+}
+
+// handleCapturePause reports the capture state on GET, pauses capture on POST
+// and resumes it on DELETE.
+func (s *Server) handleCapturePause(w http.ResponseWriter, r *http.Request) {
+	pauser, ok := s.logProvider.(CapturePauser)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "capture pause not available"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		pauser.SetCapturePaused(true)
+	case http.MethodDelete:
+		pauser.SetCapturePaused(false)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	json.NewEncoder(w).Encode(pauser.CaptureState())
+}
+
+// handleState returns the server's mode, target, endpoint and capture
+// settings.
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	provider, ok := s.logProvider.(StateProvider)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "state not available"})
+		return
+	}
+	json.NewEncoder(w).Encode(provider.State())
+}
+
+// handlePresence returns the clients that have sent requests this session,
+// most recently seen first.
+func (s *Server) handlePresence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	provider, ok := s.logProvider.(PresenceProvider)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "presence not available"})
+		return
+	}
+	json.NewEncoder(w).Encode(provider.Presence())
+}
+
+// handleStatsSnapshot returns the stats as JSON, or in the Prometheus text
+// format with ?format=prom.
+func (s *Server) handleStatsSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	provider, ok := s.logProvider.(StatsSnapshotter)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "stats provider not available"})
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		json.NewEncoder(w).Encode(provider.StatsSnapshot())
+	case "prom":
+		w.Header().Set("Content-Type", stats.PrometheusContentType)
+		provider.StatsSnapshot().WritePrometheus(w)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("unknown format %q: use json or prom", format)})
+	}
+}
+
+// handleStatsReset zeroes the stats on POST and returns the fresh snapshot.
+func (s *Server) handleStatsReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	provider, ok := s.logProvider.(StatsSnapshotter)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "stats provider not available"})
+		return
+	}
+	provider.ResetStats()
+	json.NewEncoder(w).Encode(provider.StatsSnapshot())
+}
+
+// handleBodyCapture reports the capture state on GET and, on PUT, turns body
+// capture on or off for the directions present in the JSON body, e.g.
+// {"response": false}.
+func (s *Server) handleBodyCapture(w http.ResponseWriter, r *http.Request) {
+	capturer, ok := s.logProvider.(BodyCapturer)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "body capture toggle not available"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var update struct {
+			Request  *bool `json:"request"`
+			Response *bool `json:"response"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid body capture JSON"})
+			return
+		}
+		state := capturer.CaptureState()
+		request, response := state.RequestBodies, state.ResponseBodies
+		if update.Request != nil {
+			request = *update.Request
+		}
+		if update.Response != nil {
+			response = *update.Response
+		}
+		capturer.SetBodyCapture(request, response)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	json.NewEncoder(w).Encode(capturer.CaptureState())
+}
+
+// handleMockStore exposes and resets the mock resource store.
+func (s *Server) handleMockStore(w http.ResponseWriter, r *http.Request) {
+	var store *mock.Store
+	if provider, ok := s.logProvider.(MockStoreProvider); ok {
+		store = provider.MockStore()
+	}
+	if store == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "mock resource store not enabled"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(store.Snapshot())
+	case http.MethodDelete:
+		store.Reset()
+		json.NewEncoder(w).Encode(map[string]string{"status": "reset"})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
+
 // handleStatic serves static files from the embedded filesystem
 func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
 	if s.uiFS == nil {
@@ -144,12 +940,18 @@ func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
 
 	path := r.URL.Path
 	if path == "/ui" {
-		http.Redirect(w, r, "/ui/", http.StatusMovedPermanently)
+		// Relative, so the redirect keeps any prefix the UI is mounted
+		// under (e.g. /alice/ui with --namespace). http.Redirect would
+		// resolve it against the request path.
+		w.Header().Set("Location", "ui/")
+		w.WriteHeader(http.StatusMovedPermanently)
 		return
 	}
 	switch {
 	case path == "/ui/":
 		path = "/"
+	case path == "/ui/term":
+		path = "/term.html"
 	case strings.HasPrefix(path, "/ui/"):
 		path = strings.TrimPrefix(path, "/ui")
 	}
@@ -1,6 +1,11 @@
 package ui
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -8,8 +13,17 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/jaxxstorm/portal/internal/annotations"
+	"github.com/jaxxstorm/portal/internal/apitoken"
+	"github.com/jaxxstorm/portal/internal/chaos"
+	"github.com/jaxxstorm/portal/internal/filters"
+	"github.com/jaxxstorm/portal/internal/httputil"
+	"github.com/jaxxstorm/portal/internal/mock"
 	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/stats"
+	"github.com/jaxxstorm/portal/internal/termshare"
 )
 
 type stubLogProvider struct {
@@ -133,7 +147,1213 @@ func TestHandleStaticRedirectsUIWithoutTrailingSlash(t *testing.T) {
 	if rr.Code != http.StatusMovedPermanently {
 		t.Fatalf("expected status %d, got %d", http.StatusMovedPermanently, rr.Code)
 	}
-	if got := rr.Header().Get("Location"); got != "/ui/" {
+	if got := rr.Header().Get("Location"); got != "ui/" {
 		t.Fatalf("unexpected redirect location: %q", got)
 	}
 }
+
+type stubMockStoreProvider struct {
+	stubLogProvider
+	store *mock.Store
+}
+
+func (s *stubMockStoreProvider) MockStore() *mock.Store {
+	return s.store
+}
+
+func TestHandleAPIMockStoreResetAndSnapshot(t *testing.T) {
+	store := mock.NewStore([]mock.Resource{{Path: "/users"}})
+	store.Handle(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", nil), `{"name":"ada"}`)
+	srv := testServerWithUIFiles(t, &stubMockStoreProvider{store: store})
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/mock/store", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "ada") {
+		t.Fatalf("expected snapshot with stored item, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/ui/api/mock/store", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected reset to succeed, got %d", rr.Code)
+	}
+	if got := len(store.Snapshot()["/users"]); got != 0 {
+		t.Fatalf("expected empty store after reset, got %d items", got)
+	}
+}
+
+func TestHandleAPIMockStoreUnavailable(t *testing.T) {
+	srv := testServerWithUIFiles(t, &stubLogProvider{})
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/mock/store", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a mock store, got %d", rr.Code)
+	}
+}
+
+type stubMockEngineProvider struct {
+	stubLogProvider
+	engine *mock.Engine
+}
+
+func (s *stubMockEngineProvider) MockEngine() *mock.Engine {
+	return s.engine
+}
+
+func TestHandleAPIMockScenarios(t *testing.T) {
+	engine := mock.NewEngine(&mock.Config{Scenarios: []mock.Scenario{{
+		ID:    "flaky",
+		Path:  "/pay",
+		Steps: []mock.Step{{Status: 500}, {Status: 200}},
+	}}})
+	srv := testServerWithUIFiles(t, &stubMockEngineProvider{engine: engine})
+	engine.HandleScenario(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/pay", nil), "")
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/mock/scenarios", nil))
+	var statuses []mock.ScenarioStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &statuses); err != nil {
+		t.Fatalf("decode scenarios: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].Position != 1 || statuses[0].Calls != 1 {
+		t.Fatalf("expected scenario at position 1, got %+v", statuses)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/mock/scenarios/flaky/reset", nil))
+	if rr.Code != http.StatusOK || engine.Scenarios()[0].Position != 0 {
+		t.Fatalf("expected reset to rewind scenario, got %d %+v", rr.Code, engine.Scenarios())
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/mock/scenarios/missing/reset", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown scenario, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/mock/scenarios", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for DELETE, got %d", rr.Code)
+	}
+}
+
+func TestHandleAPIMockRulesCRUD(t *testing.T) {
+	engine := mock.NewEngine(nil)
+	srv := testServerWithUIFiles(t, &stubMockEngineProvider{engine: engine})
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/mock/rules", strings.NewReader(`{"id":"hello","path":"/hello","status":201,"body":"hi"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 on create, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/ui/api/mock/rules/hello", strings.NewReader(`{"path":"/hello","status":202}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 on update, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rules := engine.Rules(); len(rules) != 1 || rules[0].Status != 202 {
+		t.Fatalf("expected updated rule, got %+v", rules)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/mock/rules", strings.NewReader(`{"path":"no-slash"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid rule, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/mock/rules/hello", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 on delete, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/mock/rules/hello", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for deleted rule, got %d", rr.Code)
+	}
+}
+
+type stubTerminalShareProvider struct {
+	stubLogProvider
+	share *termshare.Share
+}
+
+func (s *stubTerminalShareProvider) TerminalShare() *termshare.Share {
+	return s.share
+}
+
+func TestHandleAPITermStream(t *testing.T) {
+	share := termshare.New(func() termshare.Size { return termshare.Size{Cols: 120, Rows: 40} }, nil)
+	joined := make(chan struct{}, 1)
+	share.SetOnJoin(func() { joined <- struct{}{} })
+
+	srv := httptest.NewServer(testServerWithUIFiles(t, &stubTerminalShareProvider{share: share}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/ui/api/term/stream")
+	if err != nil {
+		t.Fatalf("get stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected event stream, got %q", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		t.Helper()
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read stream: %v", err)
+			}
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if got := readEvent(); got != "event: size\ndata: {\"cols\":120,\"rows\":40}\n" {
+		t.Fatalf("unexpected size event %q", got)
+	}
+	<-joined
+	share.Write([]byte("\x1b[Hportal"))
+	if got := readEvent(); got != "data: "+base64.StdEncoding.EncodeToString([]byte("\x1b[Hportal"))+"\n" {
+		t.Fatalf("unexpected data event %q", got)
+	}
+}
+
+func TestHandleAPITermStreamDisabled(t *testing.T) {
+	srv := testServerWithUIFiles(t, &stubLogProvider{})
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/term/stream", nil))
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without terminal sharing, got %d", rr.Code)
+	}
+}
+
+type stubAPITokenProvider struct {
+	stubLogProvider
+	tokens *apitoken.Store
+}
+
+func (s *stubAPITokenProvider) APITokens() *apitoken.Store {
+	return s.tokens
+}
+
+func TestHandleAPIRequiresTokenForMutations(t *testing.T) {
+	tokens := &apitoken.Store{}
+	mockSecret, _, err := tokens.Create("mock-only", []string{apitoken.ScopeMock})
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	adminSecret, _, err := tokens.Create("admin", []string{apitoken.ScopeAdmin})
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	provider := &stubAPITokenProvider{tokens: tokens}
+	srv := testServerWithUIFiles(t, provider)
+
+	clear := func(token string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/ui/api/requests", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := clear(""); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", code)
+	}
+	if code := clear("portal_wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unknown token, got %d", code)
+	}
+	if code := clear(mockSecret); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for token without requests scope, got %d", code)
+	}
+	if provider.cleared {
+		t.Fatalf("expected logs to be kept when unauthorized")
+	}
+	if code := clear(adminSecret); code != http.StatusNoContent {
+		t.Fatalf("expected 204 with admin token, got %d", code)
+	}
+	if !provider.cleared {
+		t.Fatalf("expected logs to be cleared")
+	}
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/requests", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected reads to stay open, got %d", rr.Code)
+	}
+}
+
+func TestHandleAPIAllowsMutationsWithoutTokens(t *testing.T) {
+	provider := &stubAPITokenProvider{tokens: &apitoken.Store{}}
+	srv := testServerWithUIFiles(t, provider)
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/requests", nil))
+	if rr.Code != http.StatusNoContent || !provider.cleared {
+		t.Fatalf("expected clear to succeed without configured tokens, got %d", rr.Code)
+	}
+}
+
+func TestServeHTTPRefusesFunnelRequests(t *testing.T) {
+	srv := testServerWithUIFiles(t, &stubLogProvider{})
+
+	for _, path := range []string{"/ui/", "/api/requests"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(httputil.FunnelRequestHeader, "?1")
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Fatalf("expected 403 for %s over Funnel, got %d", path, rr.Code)
+		}
+
+		rr = httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200 for %s from the tailnet, got %d", path, rr.Code)
+		}
+	}
+}
+
+type stubRequestImporter struct {
+	stubLogProvider
+	imported []model.RequestLog
+}
+
+func (s *stubRequestImporter) ImportRequestLogs(entries []model.RequestLog) int {
+	s.imported = append(s.imported, entries...)
+	return len(entries)
+}
+
+func TestHandleAPIImportRequests(t *testing.T) {
+	provider := &stubRequestImporter{}
+	srv := testServerWithUIFiles(t, provider)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/requests/import", strings.NewReader(`[{"method":"GET","url":"/a"},{"method":"POST","url":"/b"}]`))
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(provider.imported) != 2 || provider.imported[1].URL != "/b" {
+		t.Fatalf("unexpected imported entries %+v", provider.imported)
+	}
+	if !strings.Contains(rr.Body.String(), `"imported":2`) {
+		t.Fatalf("expected imported count, got %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/requests/import", strings.NewReader("nope")))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid JSON, got %d", rr.Code)
+	}
+}
+
+type stubHistoryProvider struct {
+	stubLogProvider
+	logs []model.RequestLog
+}
+
+func (s *stubHistoryProvider) GetRequestLogs() []model.RequestLog {
+	return s.logs
+}
+
+func TestHandleAPIExport(t *testing.T) {
+	srv := testServerWithUIFiles(t, &stubHistoryProvider{logs: []model.RequestLog{
+		{ID: "req_1", Method: "GET", URL: "/a", Response: model.ResponseLog{StatusCode: 200}},
+		{ID: "req_2", Method: "POST", URL: "/b", Response: model.ResponseLog{StatusCode: 500}},
+	}})
+	export := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/export"+query, nil))
+		return rr
+	}
+
+	rr := export("")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/x-ndjson" || strings.Count(rr.Body.String(), "\n") != 2 {
+		t.Fatalf("expected two NDJSON lines, got %d %q: %s", rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+	rr = export("?format=csv&columns=method,url,status")
+	if rr.Code != http.StatusOK || rr.Body.String() != "method,url,status\nGET,/a,200\nPOST,/b,500\n" {
+		t.Fatalf("unexpected CSV export %d: %q", rr.Code, rr.Body.String())
+	}
+	if rr := export("?format=csv&columns=colour"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown column, got %d", rr.Code)
+	}
+	if rr := export("?format=xml"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown format, got %d", rr.Code)
+	}
+}
+
+func TestHandleAPIBodyDownload(t *testing.T) {
+	srv := testServerWithUIFiles(t, &stubHistoryProvider{logs: []model.RequestLog{
+		{
+			ID:      "req_1",
+			Headers: model.HeadersFromMap(map[string]string{"Content-Type": "application/json"}),
+			Body:    `{"event":"paid"}`,
+			Response: model.ResponseLog{
+				Headers:       model.HeadersFromMap(map[string]string{"Content-Type": "application/pdf", "Content-Disposition": `attachment; filename="../invoice.pdf"`}),
+				BodyBytes:     []byte("%PDF-1.7"),
+				BodyTruncated: true,
+			},
+		},
+		{ID: "req_2", Response: model.ResponseLog{Headers: model.HeadersFromMap(map[string]string{"Content-Type": "text/html", "Content-Encoding": "gzip"}), BodyBytes: []byte{0x1f, 0x8b}}},
+	}})
+	download := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	rr := download("/api/requests/req_1/body/request")
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"event":"paid"}` || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected request body download %d %v: %s", rr.Code, rr.Header(), rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != "attachment; filename=portal-req_1-request.json" {
+		t.Fatalf("expected a .json file name, got %q", got)
+	}
+
+	rr = download("/ui/api/requests/req_1/body/response")
+	if rr.Code != http.StatusOK || rr.Body.String() != "%PDF-1.7" || rr.Header().Get("X-Portal-Body-Truncated") != "true" {
+		t.Fatalf("unexpected response body download %d %v: %s", rr.Code, rr.Header(), rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != "attachment; filename=invoice.pdf" {
+		t.Fatalf("expected the response's own file name, got %q", got)
+	}
+
+	rr = download("/api/requests/req_2/body/response")
+	if rr.Header().Get("Content-Type") != "application/octet-stream" || rr.Header().Get("Content-Disposition") != "attachment; filename=portal-req_2-response.html.gz" {
+		t.Fatalf("expected an encoded body to download as is, got %v", rr.Header())
+	}
+
+	for path, want := range map[string]int{
+		"/api/requests/req_2/body/request": http.StatusNotFound,
+		"/api/requests/req_9/body/request": http.StatusNotFound,
+		"/api/requests/req_1/body/other":   http.StatusNotFound,
+	} {
+		if rr := download(path); rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, rr.Code)
+		}
+	}
+}
+
+type stubSearchProvider struct {
+	stubLogProvider
+	query string
+	limit int
+}
+
+func (s *stubSearchProvider) SearchRequests(query string, limit int) []model.RequestLog {
+	s.query, s.limit = query, limit
+	if query == "missing" {
+		return nil
+	}
+	return []model.RequestLog{{ID: "req_1", Body: `{"order_id":12345}`}}
+}
+
+func TestHandleAPISearch(t *testing.T) {
+	provider := &stubSearchProvider{}
+	srv := testServerWithUIFiles(t, provider)
+	search := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/search"+query, nil))
+		return rr
+	}
+
+	rr := search("?q=order_id+12345")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"id":"req_1"`) {
+		t.Fatalf("unexpected search response %d: %s", rr.Code, rr.Body.String())
+	}
+	if provider.query != "order_id 12345" || provider.limit != 100 {
+		t.Fatalf("expected the query with the default limit, got %q %d", provider.query, provider.limit)
+	}
+	if search("?q=12345&limit=5000"); provider.limit != 1000 {
+		t.Fatalf("expected the limit capped at 1000, got %d", provider.limit)
+	}
+	if rr := search("?q=missing"); rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Fatalf("expected an empty list, got %d %s", rr.Code, rr.Body.String())
+	}
+	for _, query := range []string{"", "?q=+", "?q=12345&limit=0"} {
+		if rr := search(query); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%q: expected 400, got %d", query, rr.Code)
+		}
+	}
+
+	srv = testServerWithUIFiles(t, &stubLogProvider{})
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/search?q=x", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without search, got %d", rr.Code)
+	}
+}
+
+type stubSchemaProvider struct {
+	stubLogProvider
+	reset bool
+}
+
+func (s *stubSchemaProvider) GetSchemas() []model.EndpointSchema {
+	return []model.EndpointSchema{{Method: "POST", Path: "/hooks", Deliveries: 2, Fields: []model.SchemaField{{Path: "$.id", Types: []string{"string"}, Seen: 2, Required: true}}}}
+}
+
+func (s *stubSchemaProvider) ResetSchemas() {
+	s.reset = true
+}
+
+func TestHandleAPISchemas(t *testing.T) {
+	provider := &stubSchemaProvider{}
+	srv := testServerWithUIFiles(t, provider)
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/schemas", nil))
+	var schemas []model.EndpointSchema
+	if err := json.Unmarshal(rr.Body.Bytes(), &schemas); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(schemas) != 1 || schemas[0].Fields[0].Path != "$.id" {
+		t.Fatalf("unexpected schemas %+v", schemas)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/schemas", nil))
+	if rr.Code != http.StatusNoContent || !provider.reset {
+		t.Fatalf("expected DELETE to reset the schemas, got %d", rr.Code)
+	}
+
+	srv = testServerWithUIFiles(t, &stubLogProvider{})
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/schemas", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without schemas, got %d", rr.Code)
+	}
+}
+
+type stubSavedFilterProvider struct {
+	stubLogProvider
+	store *filters.Store
+}
+
+func (s *stubSavedFilterProvider) SavedFilters() *filters.Store {
+	return s.store
+}
+
+func TestHandleAPISavedFilters(t *testing.T) {
+	store, err := filters.Load(filepath.Join(t.TempDir(), "filters.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := testServerWithUIFiles(t, &stubSavedFilterProvider{store: store})
+	call := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr
+	}
+
+	rr := call(http.MethodPut, "/api/filters/failed%20Stripe%20webhooks", `{"query":"path:/stripe status:>=400","since":60}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = call(http.MethodGet, "/ui/api/filters", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `{"name":"failed Stripe webhooks","query":"path:/stripe status:\u003e=400","since":60}`) {
+		t.Fatalf("expected the saved filter, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := call(http.MethodPut, "/api/filters/everything", `{}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty filter, got %d", rr.Code)
+	}
+	if rr := call(http.MethodDelete, "/api/filters/failed%20Stripe%20webhooks", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if rr := call(http.MethodDelete, "/api/filters/failed%20Stripe%20webhooks", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a deleted filter, got %d", rr.Code)
+	}
+
+	srv = testServerWithUIFiles(t, &stubLogProvider{})
+	if rr := call(http.MethodGet, "/api/filters", ""); rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a filter store, got %d", rr.Code)
+	}
+}
+
+type stubPprofProvider struct {
+	stubAPITokenProvider
+	enabled bool
+}
+
+func (s *stubPprofProvider) PprofEnabled() bool {
+	return s.enabled
+}
+
+func TestHandlePprof(t *testing.T) {
+	provider := &stubPprofProvider{stubAPITokenProvider: stubAPITokenProvider{tokens: &apitoken.Store{}}}
+	srv := testServerWithUIFiles(t, provider)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := get("/debug/pprof/", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 when pprof is disabled, got %d", rr.Code)
+	}
+
+	provider.enabled = true
+	if rr := get("/debug/pprof/", ""); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "goroutine") {
+		t.Fatalf("expected pprof index, got %d", rr.Code)
+	}
+	if rr := get("/debug/pprof/cmdline", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected cmdline profile, got %d", rr.Code)
+	}
+
+	requestsSecret, _, err := provider.tokens.Create("requests", []string{apitoken.ScopeRequests})
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	adminSecret, _, err := provider.tokens.Create("admin", []string{apitoken.ScopeAdmin})
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	if rr := get("/debug/pprof/", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token once tokens exist, got %d", rr.Code)
+	}
+	if rr := get("/debug/pprof/", requestsSecret); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without admin scope, got %d", rr.Code)
+	}
+	if rr := get("/debug/pprof/", adminSecret); rr.Code != http.StatusOK {
+		t.Fatalf("expected admin token to read profiles, got %d", rr.Code)
+	}
+}
+
+type stubEndpointProvider struct {
+	stubLogProvider
+	state model.EndpointState
+}
+
+func (s *stubEndpointProvider) GetEndpointState() model.EndpointState {
+	return s.state
+}
+
+func TestHandleAPIQR(t *testing.T) {
+	provider := &stubEndpointProvider{}
+	srv := testServerWithUIFiles(t, provider)
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/qr.png", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 before the service URL is known, got %d", rr.Code)
+	}
+
+	provider.state.ServiceURL = "https://portal.example.ts.net"
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/qr.png", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "image/png" {
+		t.Fatalf("expected image/png, got %q", ct)
+	}
+	if !bytes.HasPrefix(rr.Body.Bytes(), []byte("\x89PNG")) {
+		t.Fatalf("expected PNG body")
+	}
+}
+
+type stubReplayer struct {
+	stubLogProvider
+	id   string
+	edit model.ReplayRequest
+}
+
+func (s *stubReplayer) Replay(_ context.Context, id string, edit model.ReplayRequest) (model.RequestLog, error) {
+	if id != "req_1" {
+		return model.RequestLog{}, model.ErrRequestNotFound
+	}
+	s.id, s.edit = id, edit
+	return model.RequestLog{ID: "req_2", ReplayOf: id, Method: edit.Method}, nil
+}
+
+func TestHandleAPIReplay(t *testing.T) {
+	provider := &stubReplayer{}
+	srv := testServerWithUIFiles(t, provider)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rr
+	}
+
+	rr := post("/api/requests/req_1/replay", `{"method":"PUT","url":"/a","body":""}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if provider.edit.Method != "PUT" || provider.edit.URL != "/a" || provider.edit.Body == nil || *provider.edit.Body != "" {
+		t.Fatalf("unexpected edit %+v", provider.edit)
+	}
+	if !strings.Contains(rr.Body.String(), `"replay_of":"req_1"`) {
+		t.Fatalf("expected replayed entry, got %s", rr.Body.String())
+	}
+
+	if rr := post("/api/requests/req_1/replay", ""); rr.Code != http.StatusOK || provider.edit.Body != nil {
+		t.Fatalf("expected empty body to replay unchanged, got %d %+v", rr.Code, provider.edit)
+	}
+	if rr := post("/api/requests/req_9/replay", "{}"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown request, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/requests/req_1/replay", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for GET, got %d", rr.Code)
+	}
+}
+
+type stubSequenceReplayer struct {
+	stubLogProvider
+	seq model.SequenceReplay
+}
+
+func (s *stubSequenceReplayer) ReplaySequence(_ context.Context, seq model.SequenceReplay) []model.ReplayOutcome {
+	s.seq = seq
+	return []model.ReplayOutcome{{ID: seq.IDs[0], ReplayID: "req_9", StatusCode: 200}}
+}
+
+func TestHandleAPIReplaySequence(t *testing.T) {
+	provider := &stubSequenceReplayer{}
+	srv := testServerWithUIFiles(t, provider)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/requests/replay", strings.NewReader(body)))
+		return rr
+	}
+
+	rr := post(`{"ids":["req_1","req_2"],"rate":2}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(provider.seq.IDs) != 2 || provider.seq.Rate != 2 {
+		t.Fatalf("unexpected sequence %+v", provider.seq)
+	}
+	if !strings.Contains(rr.Body.String(), `"replay_id":"req_9"`) {
+		t.Fatalf("expected outcomes, got %s", rr.Body.String())
+	}
+	if rr := post(`{"ids":[]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without ids, got %d", rr.Code)
+	}
+}
+
+type stubTargetProvider struct {
+	stubEndpointProvider
+}
+
+func (s *stubTargetProvider) TargetURL() string {
+	return "http://localhost:8080"
+}
+
+func TestHandleAPINgrokTunnels(t *testing.T) {
+	provider := &stubTargetProvider{}
+	srv := testServerWithUIFiles(t, provider)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	if rr := get("/api/tunnels"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"tunnels":[]`) {
+		t.Fatalf("expected no tunnels before the service URL is known, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	provider.state.ServiceURL = "https://portal.example.ts.net"
+	var list struct {
+		Tunnels []ngrokTunnel `json:"tunnels"`
+	}
+	if err := json.Unmarshal(get("/api/tunnels").Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Tunnels) != 1 {
+		t.Fatalf("expected one tunnel, got %+v", list.Tunnels)
+	}
+	tunnel := list.Tunnels[0]
+	if tunnel.PublicURL != "https://portal.example.ts.net" || tunnel.Proto != "https" || tunnel.Config.Addr != "http://localhost:8080" || tunnel.Name != "command_line" {
+		t.Fatalf("unexpected tunnel %+v", tunnel)
+	}
+
+	if rr := get("/api/tunnels/command_line"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"public_url":"https://portal.example.ts.net"`) {
+		t.Fatalf("expected named tunnel, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := get("/api/tunnels/other"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown tunnel, got %d", rr.Code)
+	}
+}
+
+type stubSeriesProvider struct {
+	stubLogProvider
+	series stats.Series
+}
+
+func (s *stubSeriesProvider) GetSeries() stats.Series {
+	return s.series
+}
+
+func TestHandleAPIStatsTimeseries(t *testing.T) {
+	srv := testServerWithUIFiles(t, &stubSeriesProvider{series: stats.Series{
+		StepSeconds:       5,
+		Requests:          []int{0, 10},
+		RequestsPerSecond: []float64{0, 2},
+		StatusCodes:       map[int][]int{200: {0, 8}, 502: {0, 2}},
+		TopPaths:          []stats.PathCount{{Path: "/hook", Count: 10}},
+	}})
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ui/api/stats/timeseries", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var body struct {
+		StepSeconds       float64           `json:"step_seconds"`
+		RequestsPerSecond []float64         `json:"requests_per_second"`
+		StatusCodes       map[string][]int  `json:"status_codes"`
+		TopPaths          []stats.PathCount `json:"top_paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.StepSeconds != 5 || body.RequestsPerSecond[1] != 2 || body.StatusCodes["502"][1] != 2 || body.TopPaths[0].Path != "/hook" {
+		t.Fatalf("unexpected time series %+v", body)
+	}
+
+	srv = testServerWithUIFiles(t, &stubLogProvider{})
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/stats/timeseries", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a series provider, got %d", rr.Code)
+	}
+}
+
+type stubConnectionStatsProvider struct {
+	stubLogProvider
+}
+
+func (s *stubConnectionStatsProvider) GetConnectionStats() model.ConnectionStats {
+	return model.ConnectionStats{Accepted: 3, Closed: 2, Reused: 1, Requests: 7, RequestsPerConnection: map[string]int{"1": 1, "6-20": 1}}
+}
+
+func TestHandleAPIStatsConnections(t *testing.T) {
+	srv := testServerWithUIFiles(t, &stubConnectionStatsProvider{})
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/stats/connections", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var body model.ConnectionStats
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Accepted != 3 || body.Reused != 1 || body.RequestsPerConnection["6-20"] != 1 {
+		t.Fatalf("unexpected connection stats %+v", body)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/stats/connections", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+
+	srv = testServerWithUIFiles(t, &stubLogProvider{})
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/stats/connections", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without connection stats, got %d", rr.Code)
+	}
+}
+
+type stubBodyCapturer struct {
+	stubLogProvider
+	state model.CaptureState
+}
+
+func (s *stubBodyCapturer) SetBodyCapture(request, response bool) {
+	s.state.RequestBodies, s.state.ResponseBodies = request, response
+}
+
+func (s *stubBodyCapturer) CaptureState() model.CaptureState {
+	return s.state
+}
+
+func TestHandleAPIBodyCapture(t *testing.T) {
+	provider := &stubBodyCapturer{state: model.CaptureState{RequestBodies: true, ResponseBodies: true}}
+	srv := testServerWithUIFiles(t, provider)
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/api/capture/bodies", strings.NewReader(`{"response":false}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var state model.CaptureState
+	if err := json.Unmarshal(rr.Body.Bytes(), &state); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !state.RequestBodies || state.ResponseBodies {
+		t.Fatalf("expected only response bodies to be turned off, got %+v", state)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/api/capture/bodies", strings.NewReader(`not json`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid JSON, got %d", rr.Code)
+	}
+
+	srv = testServerWithUIFiles(t, &stubLogProvider{})
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/capture/bodies", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without body capture support, got %d", rr.Code)
+	}
+}
+
+type stubStatsSnapshotter struct {
+	stubLogProvider
+	snapshot stats.StatsSnapshot
+}
+
+func (s *stubStatsSnapshotter) StatsSnapshot() stats.StatsSnapshot {
+	return s.snapshot
+}
+
+func (s *stubStatsSnapshotter) ResetStats() {
+	s.snapshot = stats.StatsSnapshot{StartedAt: time.Unix(1_700_000_100, 0)}
+}
+
+func TestHandleAPIStatsSnapshotAndReset(t *testing.T) {
+	provider := &stubStatsSnapshotter{snapshot: stats.StatsSnapshot{StartedAt: time.Unix(1_700_000_000, 0), TotalConnections: 7}}
+	srv := testServerWithUIFiles(t, provider)
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/stats/snapshot", nil))
+	var snapshot stats.StatsSnapshot
+	if err := json.Unmarshal(rr.Body.Bytes(), &snapshot); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if snapshot.TotalConnections != 7 || snapshot.StartedAt.Unix() != 1_700_000_000 {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/stats/snapshot?format=prom", nil))
+	if ct := rr.Header().Get("Content-Type"); ct != stats.PrometheusContentType || !strings.Contains(rr.Body.String(), "portal_requests_total 7\n") {
+		t.Fatalf("unexpected Prometheus output %q (%s)", rr.Body.String(), ct)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/stats/snapshot?format=xml", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown format, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/stats/reset", nil))
+	if rr.Code != http.StatusMethodNotAllowed || provider.snapshot.TotalConnections != 7 {
+		t.Fatalf("expected GET not to reset, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/stats/reset", nil))
+	if err := json.Unmarshal(rr.Body.Bytes(), &snapshot); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if snapshot.TotalConnections != 0 || snapshot.StartedAt.Unix() != 1_700_000_100 {
+		t.Fatalf("expected a fresh snapshot after reset, got %+v", snapshot)
+	}
+}
+
+type stubCapturePauser struct {
+	stubLogProvider
+	state model.CaptureState
+}
+
+func (s *stubCapturePauser) SetCapturePaused(paused bool) {
+	s.state.Paused = paused
+}
+
+func (s *stubCapturePauser) CaptureState() model.CaptureState {
+	return s.state
+}
+
+func TestHandleAPICapturePause(t *testing.T) {
+	provider := &stubCapturePauser{}
+	srv := testServerWithUIFiles(t, provider)
+
+	send := func(method string) model.CaptureState {
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, httptest.NewRequest(method, "/api/capture/pause", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", method, rr.Code)
+		}
+		var state model.CaptureState
+		if err := json.Unmarshal(rr.Body.Bytes(), &state); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return state
+	}
+
+	if send(http.MethodPost); !provider.state.Paused {
+		t.Fatal("expected POST to pause capture")
+	}
+	provider.state.Skipped = 3
+	if state := send(http.MethodGet); !state.Paused || state.Skipped != 3 {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	if state := send(http.MethodDelete); state.Paused {
+		t.Fatalf("expected DELETE to resume capture, got %+v", state)
+	}
+
+	srv = testServerWithUIFiles(t, &stubLogProvider{})
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/capture/pause", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without capture pause support, got %d", rr.Code)
+	}
+}
+
+type stubStateProvider struct {
+	stubLogProvider
+	state model.ServerState
+}
+
+func (s *stubStateProvider) State() model.ServerState {
+	return s.state
+}
+
+func TestHandleAPIState(t *testing.T) {
+	provider := &stubStateProvider{state: model.ServerState{
+		Mode:      "proxy",
+		TargetURL: "http://localhost:3000",
+		Endpoint:  model.EndpointState{ServiceURL: "https://dev-box.example.ts.net/"},
+	}}
+	srv := testServerWithUIFiles(t, provider)
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/state", nil))
+	var state model.ServerState
+	if err := json.Unmarshal(rr.Body.Bytes(), &state); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if state.TargetURL != "http://localhost:3000" || state.Endpoint.ServiceURL != "https://dev-box.example.ts.net/" {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	srv = testServerWithUIFiles(t, &stubLogProvider{})
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/state", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without state support, got %d", rr.Code)
+	}
+}
+
+type stubPresenceProvider struct {
+	stubLogProvider
+	presence []model.Presence
+}
+
+func (s *stubPresenceProvider) Presence() []model.Presence {
+	return s.presence
+}
+
+func TestHandleAPIPresence(t *testing.T) {
+	provider := &stubPresenceProvider{presence: []model.Presence{{
+		Identity: model.Identity{Address: "100.64.0.2", Login: "alice@example.com", Device: "laptop"},
+		Requests: 4,
+	}}}
+	srv := testServerWithUIFiles(t, provider)
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/presence", nil))
+	var presence []model.Presence
+	if err := json.Unmarshal(rr.Body.Bytes(), &presence); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(presence) != 1 || presence[0].Identity.Login != "alice@example.com" || presence[0].Requests != 4 {
+		t.Fatalf("unexpected presence %+v", presence)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/presence", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for POST, got %d", rr.Code)
+	}
+
+	srv = testServerWithUIFiles(t, &stubLogProvider{})
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/presence", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without presence support, got %d", rr.Code)
+	}
+}
+
+type stubAnnotationProvider struct {
+	stubLogProvider
+	store *annotations.Store
+}
+
+func (s *stubAnnotationProvider) Annotations() *annotations.Store {
+	return s.store
+}
+
+func (s *stubAnnotationProvider) Viewer(r *http.Request) *model.Identity {
+	return &model.Identity{Address: "100.64.0.2", Login: r.Header.Get("Tailscale-User-Login")}
+}
+
+func TestHandleAPIAnnotations(t *testing.T) {
+	srv := httptest.NewServer(testServerWithUIFiles(t, &stubAnnotationProvider{store: annotations.New(10)}))
+	defer srv.Close()
+
+	stream, err := http.Get(srv.URL + "/api/annotations/stream")
+	if err != nil {
+		t.Fatalf("get stream: %v", err)
+	}
+	defer stream.Body.Close()
+	if ct := stream.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected event stream, got %q", ct)
+	}
+	reader := bufio.NewReader(stream.Body)
+	readChange := func() model.Annotation {
+		t.Helper()
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		reader.ReadString('\n')
+		var annotation model.Annotation
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &annotation); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		return annotation
+	}
+
+	annotate := func(method, login, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+"/api/requests/req_1/annotation", strings.NewReader(body))
+		req.Header.Set("Tailscale-User-Login", login)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s annotation: %v", method, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := annotate(http.MethodPut, "alice@example.com", `{"note":"retry storm","tags":["Billing"],"starred":true}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if got := readChange(); got.RequestID != "req_1" || got.Author.Login != "alice@example.com" || got.Note != "retry storm" || !got.Starred || len(got.Tags) != 1 || got.Tags[0] != "billing" {
+		t.Fatalf("unexpected change %+v", got)
+	}
+	annotate(http.MethodPut, "bob@example.com", `{"tags":["flaky"]}`)
+	readChange()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/annotations", nil)
+	req.Header.Set("Tailscale-User-Login", "bob@example.com")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get annotations: %v", err)
+	}
+	var listing struct {
+		Viewer      model.Identity     `json:"viewer"`
+		Annotations []model.Annotation `json:"annotations"`
+	}
+	json.NewDecoder(resp.Body).Decode(&listing)
+	resp.Body.Close()
+	if listing.Viewer.Login != "bob@example.com" || len(listing.Annotations) != 2 || listing.Annotations[1].Author.Login != "bob@example.com" {
+		t.Fatalf("unexpected listing %+v", listing)
+	}
+
+	if resp := annotate(http.MethodDelete, "alice@example.com", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+	if got := readChange(); got.Author.Login != "alice@example.com" || !got.Empty() {
+		t.Fatalf("expected an empty change for the removal, got %+v", got)
+	}
+	if resp := annotate(http.MethodPut, "alice@example.com", `{"tags":["two words"]}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid tag, got %d", resp.StatusCode)
+	}
+
+	rr := httptest.NewRecorder()
+	testServerWithUIFiles(t, &stubLogProvider{}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/annotations", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without annotation support, got %d", rr.Code)
+	}
+}
+
+type stubChaosProvider struct {
+	stubLogProvider
+	engine *chaos.Engine
+}
+
+func (s *stubChaosProvider) Chaos() *chaos.Engine {
+	return s.engine
+}
+
+func TestHandleAPIChaosRules(t *testing.T) {
+	engine := chaos.NewEngine(nil)
+	srv := testServerWithUIFiles(t, &stubChaosProvider{engine: engine})
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/chaos/rules", strings.NewReader(`{"expr":"5% POST /payments 502"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 on create, got %d %s", rr.Code, rr.Body.String())
+	}
+	var created chaos.Rule
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.ID != "chaos_1" || created.Percent != 5 || created.Status != 502 {
+		t.Fatalf("unexpected rule %+v", created)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/ui/api/chaos/rules/chaos_1", strings.NewReader(`{"expr":"header:X-Debug=slow +2s"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 on update, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rules := engine.Rules(); len(rules) != 1 || rules[0].Latency != 2*time.Second {
+		t.Fatalf("expected updated rule, got %+v", rules)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/chaos/rules", strings.NewReader(`{"expr":"/payments"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a rule that injects nothing, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/chaos/rules/chaos_1", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 on delete, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/chaos/rules/chaos_1", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a deleted rule, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/chaos/rules", nil))
+	if strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Fatalf("expected an empty list, got %s", rr.Body.String())
+	}
+}
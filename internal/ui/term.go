@@ -0,0 +1,80 @@
+// internal/ui/term.go
+package ui
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jaxxstorm/portal/internal/termshare"
+)
+
+// termKeepAlive is how often an idle terminal stream sends an SSE comment so
+// intermediaries don't close it.
+var termKeepAlive = 15 * time.Second
+
+// TerminalShareProvider is optionally implemented by log providers whose TUI
+// output is shared read-only with web UI viewers.
+type TerminalShareProvider interface {
+	TerminalShare() *termshare.Share
+}
+
+// handleTermStream streams the TUI's raw terminal output as server-sent
+// events: a "size" event with the terminal dimensions, then base64-encoded
+// output chunks. Viewers only receive output; nothing is read back.
+func (s *Server) handleTermStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+	var share *termshare.Share
+	if provider, ok := s.logProvider.(TerminalShareProvider); ok {
+		share = provider.TerminalShare()
+	}
+	if share == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "terminal sharing is not enabled (start portal with --share-terminal)"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "streaming not supported"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	size, _ := json.Marshal(share.Size())
+	fmt.Fprintf(w, "event: size\ndata: %s\n\n", size)
+	flusher.Flush()
+
+	out, cancel := share.Subscribe()
+	defer cancel()
+
+	keepAlive := time.NewTicker(termKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case chunk, ok := <-out:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and
+				// gets a fresh repaint.
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", base64.StdEncoding.EncodeToString(chunk))
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// internal/wire/dump.go
+package wire
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const timeFormat = "15:04:05.000000"
+
+// Dump writes the records from r as a readable transcript: client bytes
+// are marked ">", bytes sent to the client "<", and the data is printed
+// with control and non-ASCII bytes escaped, so "\r\n" line endings and
+// chunk sizes show exactly as sent. conn limits the transcript to one
+// connection; 0 includes them all.
+func Dump(w io.Writer, r *Reader, conn uint64) error {
+	out := bufio.NewWriter(w)
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			_ = out.Flush()
+			return err
+		}
+		if conn != 0 && rec.Conn != conn {
+			continue
+		}
+
+		prefix := fmt.Sprintf("#%d %s", rec.Conn, rec.Time.Format(timeFormat))
+		switch rec.Kind {
+		case KindOpen:
+			fmt.Fprintf(out, "%s open %s -> %s\n", prefix, rec.Remote, rec.Local)
+		case KindClose:
+			fmt.Fprintf(out, "%s close\n", prefix)
+		case KindRead, KindWrite:
+			direction := ">"
+			if rec.Kind == KindWrite {
+				direction = "<"
+			}
+			fmt.Fprintf(out, "%s %s %d bytes\n", prefix, direction, len(rec.Data))
+			for _, line := range escapeLines(rec.Data) {
+				fmt.Fprintf(out, "    %s\n", line)
+			}
+		}
+	}
+	return out.Flush()
+}
+
+// escapeLines escapes data and splits it after each newline.
+func escapeLines(data []byte) []string {
+	var lines []string
+	var line strings.Builder
+	for _, c := range data {
+		switch {
+		case c == '\r':
+			line.WriteString(`\r`)
+		case c == '\n':
+			line.WriteString(`\n`)
+			lines = append(lines, line.String())
+			line.Reset()
+		case c == '\t':
+			line.WriteString(`\t`)
+		case c == '\\':
+			line.WriteString(`\\`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&line, `\x%02x`, c)
+		default:
+			line.WriteByte(c)
+		}
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
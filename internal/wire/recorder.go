@@ -0,0 +1,173 @@
+// internal/wire/recorder.go
+package wire
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Recorder writes the bytes exchanged on connections to a capture file,
+// exactly as they crossed the wire: header casing and order, duplicate
+// headers and chunked framing included. TLS is already terminated, so it
+// is the plaintext. A nil Recorder records nothing.
+type Recorder struct {
+	conns atomic.Uint64
+
+	mu   sync.Mutex
+	file *os.File
+	buf  []byte
+	err  error
+}
+
+// Create starts a capture file at path, replacing any file already there.
+// Captures hold credentials and bodies in the clear, so only the owner can
+// read it.
+func Create(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wire capture: %w", err)
+	}
+	if _, err := file.Write(append([]byte(magic), version)); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to write wire capture: %w", err)
+	}
+	return &Recorder{file: file}, nil
+}
+
+// Listener returns ln with the connections it accepts recorded, or ln
+// itself when r is nil.
+func (r *Recorder) Listener(ln net.Listener) net.Listener {
+	if r == nil {
+		return ln
+	}
+	return &listener{Listener: ln, recorder: r}
+}
+
+// Close stops recording and closes the file, returning the first error
+// writing it hit, if any.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return r.err
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to write wire capture: %w", err)
+	}
+	r.file = nil
+	return r.err
+}
+
+// record writes rec in one write, so records from concurrent connections
+// never interleave. After a failed write the capture stops.
+func (r *Recorder) record(rec Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil || r.err != nil {
+		return
+	}
+	r.buf = appendRecord(r.buf[:0], rec)
+	if _, err := r.file.Write(r.buf); err != nil {
+		r.err = fmt.Errorf("failed to write wire capture: %w", err)
+	}
+}
+
+type listener struct {
+	net.Listener
+	recorder *Recorder
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	recorded := &conn{Conn: c, recorder: l.recorder, id: l.recorder.conns.Add(1), accepted: time.Now()}
+	// net/http serves a connection as TLS when it has the methods of a
+	// *tls.Conn, so keep them.
+	if tc, ok := c.(*tls.Conn); ok {
+		return &tlsConn{conn: recorded, tls: tc}, nil
+	}
+	return recorded, nil
+}
+
+// conn records what is read from and written to a connection.
+type conn struct {
+	net.Conn
+	recorder *Recorder
+	id       uint64
+	accepted time.Time
+	opened   sync.Once
+	closed   sync.Once
+}
+
+// open records the connection opening. It waits for the first read,
+// write or close rather than running in Accept, because asking a PROXY
+// protocol connection for its address blocks until the client sends the
+// header.
+func (c *conn) open() {
+	c.opened.Do(func() {
+		c.recorder.record(Record{
+			Kind:   KindOpen,
+			Conn:   c.id,
+			Time:   c.accepted,
+			Remote: c.RemoteAddr().String(),
+			Local:  c.LocalAddr().String(),
+		})
+	})
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	c.open()
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.recorder.record(Record{Kind: KindRead, Conn: c.id, Time: time.Now(), Data: b[:n]})
+	}
+	return n, err
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	c.open()
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.recorder.record(Record{Kind: KindWrite, Conn: c.id, Time: time.Now(), Data: b[:n]})
+	}
+	return n, err
+}
+
+func (c *conn) Close() error {
+	c.open()
+	c.closed.Do(func() {
+		c.recorder.record(Record{Kind: KindClose, Conn: c.id, Time: time.Now()})
+	})
+	return c.Conn.Close()
+}
+
+// NetConn returns the recorded connection.
+func (c *conn) NetConn() net.Conn {
+	return c.Conn
+}
+
+type tlsConn struct {
+	*conn
+	tls *tls.Conn
+}
+
+// HandshakeContext runs the TLS handshake of the recorded connection.
+func (c *tlsConn) HandshakeContext(ctx context.Context) error {
+	return c.tls.HandshakeContext(ctx)
+}
+
+// ConnectionState returns the TLS state of the recorded connection.
+func (c *tlsConn) ConnectionState() tls.ConnectionState {
+	return c.tls.ConnectionState()
+}
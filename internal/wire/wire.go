@@ -0,0 +1,154 @@
+// internal/wire/wire.go
+package wire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// magic starts every capture file, followed by a version byte.
+const magic = "PORTALWIRE"
+
+const version = 1
+
+// maxData bounds a single record's payload when reading, so a corrupt file
+// can't make the reader allocate without limit. Reads and writes on a
+// connection are far smaller.
+const maxData = 64 << 20
+
+// Kind is what a record describes.
+type Kind byte
+
+const (
+	// KindOpen is a client connecting.
+	KindOpen Kind = iota + 1
+	// KindRead is bytes the client sent.
+	KindRead
+	// KindWrite is bytes sent to the client.
+	KindWrite
+	// KindClose is the connection closing.
+	KindClose
+)
+
+// Record is one event on a captured connection.
+type Record struct {
+	Kind Kind
+	// Conn numbers the connection, starting at 1, in the order they were
+	// accepted.
+	Conn uint64
+	Time time.Time
+	// Remote and Local are the connection's addresses, set on open records.
+	Remote string
+	Local  string
+	// Data is the bytes read or written.
+	Data []byte
+}
+
+// appendRecord encodes rec: its kind, connection number, time in Unix
+// nanoseconds and then, by kind, the two addresses or the data, each length
+// prefixed.
+func appendRecord(b []byte, rec Record) []byte {
+	b = append(b, byte(rec.Kind))
+	b = binary.AppendUvarint(b, rec.Conn)
+	b = binary.AppendVarint(b, rec.Time.UnixNano())
+	switch rec.Kind {
+	case KindOpen:
+		b = appendBytes(b, []byte(rec.Remote))
+		b = appendBytes(b, []byte(rec.Local))
+	case KindRead, KindWrite:
+		b = appendBytes(b, rec.Data)
+	}
+	return b
+}
+
+func appendBytes(b, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// Reader reads the records of a capture file.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader checks the capture file header and returns a reader for the
+// records after it.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(magic)]) != magic {
+		return nil, errors.New("not a portal wire capture")
+	}
+	if header[len(magic)] != version {
+		return nil, fmt.Errorf("unsupported wire capture version %d", header[len(magic)])
+	}
+	return &Reader{r: br}, nil
+}
+
+// Next returns the next record, or io.EOF after the last one. A capture
+// cut short, such as by portal being killed, ends with
+// io.ErrUnexpectedEOF.
+func (r *Reader) Next() (Record, error) {
+	kind, err := r.r.ReadByte()
+	if err != nil {
+		return Record{}, err
+	}
+	rec := Record{Kind: Kind(kind)}
+	if rec.Conn, err = binary.ReadUvarint(r.r); err != nil {
+		return Record{}, truncated(err)
+	}
+	nanos, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return Record{}, truncated(err)
+	}
+	rec.Time = time.Unix(0, nanos)
+
+	switch rec.Kind {
+	case KindOpen:
+		remote, err := r.bytes()
+		if err != nil {
+			return Record{}, err
+		}
+		local, err := r.bytes()
+		if err != nil {
+			return Record{}, err
+		}
+		rec.Remote, rec.Local = string(remote), string(local)
+	case KindRead, KindWrite:
+		if rec.Data, err = r.bytes(); err != nil {
+			return Record{}, err
+		}
+	case KindClose:
+	default:
+		return Record{}, fmt.Errorf("invalid wire capture: unknown record kind %d", kind)
+	}
+	return rec, nil
+}
+
+func (r *Reader) bytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, truncated(err)
+	}
+	if n > maxData {
+		return nil, fmt.Errorf("invalid wire capture: record of %d bytes", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, truncated(err)
+	}
+	return b, nil
+}
+
+// truncated reports a record that ends early as io.ErrUnexpectedEOF, so
+// only a clean end between records is io.EOF.
+func truncated(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
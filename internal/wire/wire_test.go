@@ -0,0 +1,175 @@
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorderCapturesExactBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "portal.wire")
+	rec, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "hello")
+	})}
+	go server.Serve(rec.Listener(ln))
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := "POST /hook HTTP/1.1\r\nhost: example\r\nX-Dup: 1\r\nX-Dup: 2\r\nTransfer-Encoding: chunked\r\nConnection: close\r\n\r\n3\r\nabc\r\n0\r\n\r\n"
+	if _, err := io.WriteString(conn, request); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	server.Close()
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []Kind
+	var read, written []byte
+	for {
+		r, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Conn != 1 {
+			t.Fatalf("expected one connection, got record for %d", r.Conn)
+		}
+		if len(kinds) == 0 || kinds[len(kinds)-1] != r.Kind {
+			kinds = append(kinds, r.Kind)
+		}
+		switch r.Kind {
+		case KindOpen:
+			if r.Local != ln.Addr().String() || r.Remote != conn.LocalAddr().String() {
+				t.Fatalf("unexpected addresses %s -> %s", r.Remote, r.Local)
+			}
+		case KindRead:
+			read = append(read, r.Data...)
+		case KindWrite:
+			written = append(written, r.Data...)
+		}
+	}
+	if string(read) != request {
+		t.Fatalf("expected the request exactly as sent, got %q", read)
+	}
+	for _, want := range []string{"Set-Cookie: a=1\r\nSet-Cookie: b=2\r\n", "Transfer-Encoding: chunked\r\n", "5\r\nhello\r\n"} {
+		if !bytes.Contains(written, []byte(want)) {
+			t.Fatalf("expected the response to contain %q, got %q", want, written)
+		}
+	}
+	if kinds[0] != KindOpen || kinds[len(kinds)-1] != KindClose {
+		t.Fatalf("expected the connection to open first and close last, got %v", kinds)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the capture to be readable by its owner only, got %v %v", info.Mode(), err)
+	}
+}
+
+func TestDump(t *testing.T) {
+	var file bytes.Buffer
+	file.WriteString(magic)
+	file.WriteByte(version)
+	for _, rec := range []Record{
+		{Kind: KindOpen, Conn: 1, Remote: "100.64.0.2:50000", Local: "127.0.0.1:8080"},
+		{Kind: KindOpen, Conn: 2, Remote: "100.64.0.3:50000", Local: "127.0.0.1:8080"},
+		{Kind: KindRead, Conn: 1, Data: []byte("GET / HTTP/1.1\r\nx-Custom:\tv\\\x00\r\n\r\n")},
+		{Kind: KindWrite, Conn: 1, Data: []byte("HTTP/1.1 204 No Content\r\n\r\n")},
+		{Kind: KindRead, Conn: 2, Data: []byte("other")},
+		{Kind: KindClose, Conn: 1},
+	} {
+		file.Write(appendRecord(nil, rec))
+	}
+
+	reader, err := NewReader(bytes.NewReader(file.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := Dump(&out, reader, 1); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		// Drop the connection number and time.
+		fields := strings.SplitN(scanner.Text(), " ", 3)
+		if strings.HasPrefix(fields[0], "#") {
+			lines = append(lines, fields[0]+" "+fields[2])
+			continue
+		}
+		lines = append(lines, scanner.Text())
+	}
+	want := []string{
+		"#1 open 100.64.0.2:50000 -> 127.0.0.1:8080",
+		"#1 > 33 bytes",
+		`    GET / HTTP/1.1\r\n`,
+		`    x-Custom:\tv\\\x00\r\n`,
+		`    \r\n`,
+		"#1 < 27 bytes",
+		`    HTTP/1.1 204 No Content\r\n`,
+		`    \r\n`,
+		"#1 close",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected transcript:\n%s", out.String())
+	}
+}
+
+func TestReaderRejectsBadFiles(t *testing.T) {
+	if _, err := NewReader(strings.NewReader("{\"method\":\"GET\"}\n")); err == nil {
+		t.Fatal("expected a file that isn't a capture to be rejected")
+	}
+
+	header := magic + string([]byte{version})
+	record := appendRecord(nil, Record{Kind: KindRead, Conn: 1, Data: []byte("GET / HTTP/1.1\r\n")})
+	reader, err := NewReader(strings.NewReader(header + string(record[:len(record)-4])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected a cut short capture to end with io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	reader, err = NewReader(strings.NewReader(header + "\x09\x01\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Next(); err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected an unknown record kind to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+// internal/wizard/init.go
+package wizard
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jaxxstorm/portal/internal/tailscale"
+)
+
+// Prober reports the local node's tailnet capabilities.
+type Prober interface {
+	Capabilities(ctx context.Context) (*tailscale.Capabilities, error)
+}
+
+// Options configures the first-run setup wizard.
+type Options struct {
+	In         io.Reader
+	Out        io.Writer
+	ConfigPath string
+	Prober     Prober
+}
+
+// Settings are the defaults chosen during setup and written to the config file.
+type Settings struct {
+	Funnel     bool
+	NoUI       bool
+	ForceTsnet bool
+	AuthKey    string
+}
+
+// ErrAborted is returned when the user declines to overwrite an existing
+// config file.
+var ErrAborted = errors.New("setup aborted")
+
+// Run detects the local Tailscale setup, asks the user for defaults and writes
+// them to opts.ConfigPath.
+func Run(ctx context.Context, opts Options) (*Settings, error) {
+	w := &wizard{in: bufio.NewReader(opts.In), out: opts.Out}
+
+	w.printf("portal init\n\n")
+
+	if _, err := os.Stat(opts.ConfigPath); err == nil {
+		if !w.confirm(fmt.Sprintf("%s already exists. Overwrite it?", opts.ConfigPath), false) {
+			return nil, ErrAborted
+		}
+		w.printf("\n")
+	}
+
+	w.printf("Checking Tailscale prerequisites...\n")
+	caps, err := opts.Prober.Capabilities(ctx)
+	if err != nil {
+		w.fail("tailscaled is not reachable: %v", err)
+		w.hint("portal will fall back to tsnet and register a separate device on your tailnet.")
+		w.hint("Start Tailscale (https://tailscale.com/download) to reuse this machine's node instead.")
+	} else {
+		w.ok("tailscaled is running (version %s, node %s)", caps.Version, caps.DNSName)
+		if caps.HTTPS {
+			w.ok("HTTPS certificates are enabled for the tailnet")
+		} else {
+			w.fail("HTTPS certificates are not enabled for the tailnet")
+			w.hint("Enable HTTPS in the admin console: https://login.tailscale.com/admin/dns")
+		}
+		if caps.Funnel {
+			w.ok("Funnel is permitted for this node")
+		} else {
+			w.fail("Funnel is not permitted for this node")
+			w.hint("Grant the \"funnel\" node attribute in your tailnet policy: https://tailscale.com/kb/1223/funnel")
+		}
+	}
+	w.printf("\n")
+
+	settings := &Settings{}
+	daemonAvailable := caps != nil
+
+	if daemonAvailable {
+		settings.ForceTsnet = w.confirm("Register a separate tsnet device instead of using this node?", false)
+	} else {
+		settings.ForceTsnet = true
+	}
+	if settings.ForceTsnet {
+		settings.AuthKey = w.ask("Tailscale auth key for the tsnet device (leave empty to log in interactively)", "")
+	}
+
+	switch {
+	case daemonAvailable && !settings.ForceTsnet && !(caps.HTTPS && caps.Funnel):
+		w.printf("Skipping Funnel: this node is missing the prerequisites listed above.\n")
+	default:
+		settings.Funnel = w.confirm("Expose services publicly with Funnel by default?", false)
+	}
+
+	settings.NoUI = !w.confirm("Enable the web UI dashboard?", true)
+
+	if err := writeConfig(opts.ConfigPath, settings); err != nil {
+		return nil, err
+	}
+
+	w.printf("\nWrote %s\n", opts.ConfigPath)
+	w.printf("Start portal with: portal <port>\n")
+	return settings, nil
+}
+
+// Render returns the YAML config file contents for the given settings.
+func Render(settings Settings) []byte {
+	var b strings.Builder
+	b.WriteString("# Written by portal init. Flags and PORTAL_* environment variables override these values.\n")
+	fmt.Fprintf(&b, "funnel: %t\n", settings.Funnel)
+	fmt.Fprintf(&b, "no-ui: %t\n", settings.NoUI)
+	if settings.ForceTsnet {
+		b.WriteString("force-tsnet: true\n")
+	}
+	if settings.AuthKey != "" {
+		fmt.Fprintf(&b, "auth-key: %s\n", strconv.Quote(settings.AuthKey))
+	}
+	return []byte(b.String())
+}
+
+func writeConfig(path string, settings *Settings) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	// The file may hold an auth key, so keep it private to the user.
+	if err := os.WriteFile(path, Render(*settings), 0o600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (w *wizard) printf(format string, args ...any) {
+	fmt.Fprintf(w.out, format, args...)
+}
+
+func (w *wizard) ok(format string, args ...any) {
+	w.printf("  ✓ "+format+"\n", args...)
+}
+
+func (w *wizard) fail(format string, args ...any) {
+	w.printf("  ✗ "+format+"\n", args...)
+}
+
+func (w *wizard) hint(text string) {
+	w.printf("    %s\n", text)
+}
+
+// ask prompts for a free-form answer, returning def on empty input or EOF.
+func (w *wizard) ask(question, def string) string {
+	if def != "" {
+		w.printf("%s [%s]: ", question, def)
+	} else {
+		w.printf("%s: ", question)
+	}
+	line, _ := w.in.ReadString('\n')
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// confirm prompts for a yes/no answer, re-asking until the input is
+// recognised. Empty input or EOF selects def.
+func (w *wizard) confirm(question string, def bool) bool {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+	for {
+		w.printf("%s [%s]: ", question, choices)
+		line, err := w.in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		case "":
+			return def
+		}
+		if err != nil {
+			return def
+		}
+		w.printf("Please answer y or n.\n")
+	}
+}
@@ -0,0 +1,116 @@
+package wizard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaxxstorm/portal/internal/tailscale"
+)
+
+type stubProber struct {
+	caps *tailscale.Capabilities
+	err  error
+}
+
+func (p stubProber) Capabilities(context.Context) (*tailscale.Capabilities, error) {
+	return p.caps, p.err
+}
+
+func TestRunWritesConfigForLocalDaemon(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".portal", "config.yml")
+	var out bytes.Buffer
+
+	settings, err := Run(context.Background(), Options{
+		In:         strings.NewReader("n\ny\nn\n"),
+		Out:        &out,
+		ConfigPath: path,
+		Prober: stubProber{caps: &tailscale.Capabilities{
+			Version: "1.94.1",
+			DNSName: "dev.example.ts.net",
+			HTTPS:   true,
+			Funnel:  true,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !settings.Funnel || !settings.NoUI || settings.ForceTsnet {
+		t.Fatalf("unexpected settings: %+v", settings)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(data), "funnel: true\n") || !strings.Contains(string(data), "no-ui: true\n") {
+		t.Fatalf("unexpected config contents:\n%s", data)
+	}
+}
+
+func TestRunSkipsFunnelWhenCapabilityMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	var out bytes.Buffer
+
+	settings, err := Run(context.Background(), Options{
+		In:         strings.NewReader("\n\n"),
+		Out:        &out,
+		ConfigPath: path,
+		Prober:     stubProber{caps: &tailscale.Capabilities{HTTPS: false}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if settings.Funnel {
+		t.Fatalf("expected funnel to stay disabled without HTTPS capability")
+	}
+	if !strings.Contains(out.String(), "login.tailscale.com/admin/dns") {
+		t.Fatalf("expected HTTPS remediation hint, got:\n%s", out.String())
+	}
+}
+
+func TestRunFallsBackToTsnetWithoutDaemon(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+
+	settings, err := Run(context.Background(), Options{
+		In:         strings.NewReader("tskey-auth-123\n\n\n"),
+		Out:        &bytes.Buffer{},
+		ConfigPath: path,
+		Prober:     stubProber{err: errors.New("connection refused")},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !settings.ForceTsnet || settings.AuthKey != "tskey-auth-123" {
+		t.Fatalf("unexpected settings: %+v", settings)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(data), `auth-key: "tskey-auth-123"`) {
+		t.Fatalf("expected auth key in config, got:\n%s", data)
+	}
+}
+
+func TestRunAbortsWhenOverwriteDeclined(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("funnel: true\n"), 0o600); err != nil {
+		t.Fatalf("seed config: %v", err)
+	}
+
+	_, err := Run(context.Background(), Options{
+		In:         strings.NewReader("n\n"),
+		Out:        &bytes.Buffer{},
+		ConfigPath: path,
+		Prober:     stubProber{caps: &tailscale.Capabilities{}},
+	})
+	if !errors.Is(err, ErrAborted) {
+		t.Fatalf("expected ErrAborted, got %v", err)
+	}
+}
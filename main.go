@@ -2,31 +2,65 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
 	"context"
+	"crypto/ed25519"
 	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/pflag"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/term"
 
+	"github.com/jaxxstorm/portal/internal/alert"
+	"github.com/jaxxstorm/portal/internal/apitoken"
+	"github.com/jaxxstorm/portal/internal/capture"
+	"github.com/jaxxstorm/portal/internal/chaos"
 	"github.com/jaxxstorm/portal/internal/config"
+	"github.com/jaxxstorm/portal/internal/control"
+	"github.com/jaxxstorm/portal/internal/doctor"
+	"github.com/jaxxstorm/portal/internal/events"
+	"github.com/jaxxstorm/portal/internal/exitcode"
+	"github.com/jaxxstorm/portal/internal/expect"
+	"github.com/jaxxstorm/portal/internal/filters"
+	"github.com/jaxxstorm/portal/internal/geoip"
 	"github.com/jaxxstorm/portal/internal/httputil"
 	"github.com/jaxxstorm/portal/internal/logging"
+	"github.com/jaxxstorm/portal/internal/middleware"
+	"github.com/jaxxstorm/portal/internal/mock"
 	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/notify"
 	"github.com/jaxxstorm/portal/internal/proxy"
+	"github.com/jaxxstorm/portal/internal/reload"
 	"github.com/jaxxstorm/portal/internal/server"
+	"github.com/jaxxstorm/portal/internal/session"
+	"github.com/jaxxstorm/portal/internal/sshtunnel"
 	"github.com/jaxxstorm/portal/internal/startup"
+	"github.com/jaxxstorm/portal/internal/supervisor"
 	"github.com/jaxxstorm/portal/internal/tailscale"
+	"github.com/jaxxstorm/portal/internal/termshare"
 	"github.com/jaxxstorm/portal/internal/tui"
 	"github.com/jaxxstorm/portal/internal/ui"
+	"github.com/jaxxstorm/portal/internal/wire"
+	"github.com/jaxxstorm/portal/internal/wizard"
 )
 
 //go:embed ui/*
@@ -36,38 +70,86 @@ var uiFiles embed.FS
 var Version = "dev"
 
 func main() {
+	os.Exit(run())
+}
+
+// run runs portal and returns its exit code; see the exitcode package.
+func run() int {
 	cfg, err := config.Parse()
 	if err != nil {
 		if errors.Is(err, pflag.ErrHelp) {
-			os.Exit(0)
+			return exitcode.OK
 		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return exitcode.Config
 	}
 
 	// Handle version flag
 	if cfg.Version {
 		fmt.Printf("portal version %s\n", Version)
-		os.Exit(0)
+		return exitcode.OK
 	}
 
 	// Handle cleanup flag
 	if cfg.CleanupServe {
 		handleCleanupServe()
-		os.Exit(0)
+		return exitcode.OK
+	}
+
+	// Handle init subcommand
+	if cfg.Command == config.CommandInit {
+		handleInit()
+		return exitcode.OK
+	}
+
+	// Handle doctor subcommand
+	if cfg.Command == config.CommandDoctor {
+		return handleDoctor(cfg)
+	}
+
+	// Handle token subcommands
+	switch cfg.Command {
+	case config.CommandTokenCreate, config.CommandTokenList, config.CommandTokenRevoke:
+		return handleToken(cfg)
+	}
+
+	if cfg.Command == config.CommandSessionsList {
+		return handleSessionsList()
+	}
+
+	// Handle import subcommand
+	if cfg.Command == config.CommandImport {
+		return handleImport(cfg)
+	}
+	if cfg.Command == config.CommandExport {
+		return handleExport(cfg)
+	}
+	if cfg.Command == config.CommandExpect {
+		return handleExpect(cfg)
+	}
+	if cfg.Command == config.CommandAttach {
+		return handleAttach(cfg)
+	}
+	if cfg.Command == config.CommandWire {
+		return handleWire(cfg)
+	}
+
+	if cfg.DryRun {
+		return handleDryRun(cfg)
 	}
 
 	// Setup initial logger
 	logConfig := logging.Config{
-		Verbose: cfg.Verbose,
-		JSON:    cfg.JSON,
-		LogFile: cfg.LogFile,
+		Verbose:       cfg.Verbose,
+		JSON:          cfg.JSON,
+		LogFile:       cfg.LogFile,
+		ReserveStdout: cfg.Output == config.OutputJSON || cfg.Stream,
 	}
 
 	logger, err := logging.SetupLogger(logConfig)
 	if err != nil {
 		fmt.Printf("Failed to setup logger: %v\n", err)
-		os.Exit(1)
+		return exitcode.Config
 	}
 	defer logger.Sync()
 
@@ -77,6 +159,12 @@ func main() {
 		logging.Component("portal"),
 		logging.Version(Version),
 	)
+	if cfg.ProjectConfig != "" {
+		logger.Info("Project config loaded",
+			logging.Component("config"),
+			zap.String("path", cfg.ProjectConfig),
+		)
+	}
 
 	// Server mode determination
 	var serverMode model.ServerMode
@@ -108,36 +196,97 @@ func main() {
 		logging.MockMode(cfg.Mock),
 	)
 
-	// Test local connection only in proxy mode
-	if !cfg.Mock {
-		logger.Info(logging.MsgConnectionTesting,
-			logging.TargetPort(cfg.Port),
-		)
+	// portal run starts the target itself, then waits for its port like
+	// --wait-for-target.
+	child := startSupervisor(logger, cfg)
+	defer child.Stop()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	if child != nil {
+		// Stop serving once the command has exited for good.
+		go func() {
+			select {
+			case <-child.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
 
-		testConn, err := net.DialTimeout("tcp", fmt.Sprintf("localhost:%d", cfg.Port), 5*time.Second)
+	if cfg.DetectPort {
+		logger.Info("Waiting for the command to listen on a port",
+			logging.Component("supervisor"),
+			zap.Duration("timeout", cfg.WaitForTargetTimeout),
+		)
+		detectCtx := ctx
+		if cfg.WaitForTargetTimeout > 0 {
+			var cancelDetect context.CancelFunc
+			detectCtx, cancelDetect = context.WithTimeout(ctx, cfg.WaitForTargetTimeout)
+			defer cancelDetect()
+		}
+		port, err := child.WaitForPort(detectCtx)
 		if err != nil {
-			logger.Fatal(logging.MsgConnectionFailed,
-				logging.TargetPort(cfg.Port),
+			if ctx.Err() != nil {
+				return exitcode.OK
+			}
+			child.Stop()
+			fatal(logger, exitcode.UpstreamUnreachable, logging.MsgSetupFailed,
+				logging.Component("supervisor"),
 				logging.Error(err),
 			)
 		}
-		testConn.Close()
+		cfg.Port = port
+	}
 
-		logger.Info(logging.MsgConnectionSuccess,
+	// Test local connection only in proxy mode; remote tailnet targets are
+	// checked once Tailscale is up.
+	waitForTarget := false
+	if !cfg.Mock && cfg.TargetHost == "" && cfg.Port > 0 {
+		logger.Info(logging.MsgConnectionTesting,
 			logging.TargetPort(cfg.Port),
 		)
-	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer cancel()
+		// The app may listen on only one of 127.0.0.1 and ::1, so both are
+		// tried unless --target-host names the address.
+		dialer := &httputil.LoopbackDialer{Host: cfg.LocalTargetHost}
+		probeCtx, cancelProbe := context.WithTimeout(context.Background(), 5*time.Second)
+		addr, err := dialer.Probe(probeCtx, strconv.Itoa(cfg.Port))
+		cancelProbe()
+		switch {
+		case err == nil:
+			logger.Info(logging.MsgConnectionSuccess,
+				logging.TargetPort(cfg.Port),
+				zap.String("address", addr),
+			)
+		case cfg.WaitForTarget:
+			waitForTarget = true
+			logger.Warn("Target not listening yet, waiting for upstream",
+				logging.TargetPort(cfg.Port),
+				zap.Duration("timeout", cfg.WaitForTargetTimeout),
+				logging.Error(err),
+			)
+		default:
+			fatal(logger, exitcode.UpstreamUnreachable, logging.MsgConnectionFailed,
+				logging.TargetPort(cfg.Port),
+				logging.Error(err),
+			)
+		}
+	}
 
 	// Determine which Tailscale mode to use
 	useLocalTailscale := false
 	var tsClient *tailscale.Client
 
-	if !cfg.ForceTsnet && cfg.AuthKey == "" {
+	if cfg.SSH != "" {
+		logger.Info("Exposing through SSH reverse tunnel",
+			logging.Component("ssh_tunnel"),
+			zap.String("bastion", cfg.SSH),
+		)
+	} else if !cfg.ForceTsnet && cfg.AuthKey == "" {
 		// Try to use local Tailscale - pass logger instead of sugar
 		tsClient = tailscale.NewClient(logger)
+		tsClient.SetPortMemory(portMemory(cfg))
 		if tsClient.IsAvailable(ctx) {
 			useLocalTailscale = true
 			logger.Info(logging.MsgTailscaleDetected,
@@ -164,14 +313,68 @@ func main() {
 		)
 	}
 
+	if len(cfg.FunnelPaths) > 0 && !useLocalTailscale {
+		fatal(logger, exitcode.TailscaleUnavailable, logging.MsgSetupFailed,
+			logging.Component("tailscale_serve"),
+			logging.Error(errors.New("--funnel-path requires the local Tailscale daemon, which is not available")),
+		)
+	}
+	if cfg.Namespace != "" && !useLocalTailscale {
+		fatal(logger, exitcode.TailscaleUnavailable, logging.MsgSetupFailed,
+			logging.Component("tailscale_serve"),
+			logging.Error(errors.New("--namespace requires the local Tailscale daemon, which is not available")),
+		)
+	}
+
+	var upstreamDial proxy.DialFunc
+	if cfg.TargetHost != "" {
+		target := net.JoinHostPort(cfg.TargetHost, strconv.Itoa(cfg.Port))
+		logger.Info("Proxying to tailnet peer",
+			logging.Component("proxy_server"),
+			zap.String("target", target),
+		)
+		if useLocalTailscale {
+			upstreamDial = tsClient.DialPeer
+			testConn, err := tsClient.DialPeer(ctx, "tcp", target)
+			switch {
+			case err == nil:
+				testConn.Close()
+			case cfg.WaitForTarget:
+				waitForTarget = true
+			default:
+				fatal(logger, exitcode.UpstreamUnreachable, logging.MsgConnectionFailed,
+					zap.String("target", target),
+					logging.Error(err),
+				)
+			}
+		} else if cfg.WaitForTarget {
+			// The tsnet node is not up yet, so the peer cannot be probed
+			// before serving starts.
+			waitForTarget = true
+		}
+	}
+
+	if cfg.DetectPort {
+		// Frameworks that pick a random port may pick another one after a
+		// restart, so connect to wherever the command listens now.
+		loopback := &httputil.LoopbackDialer{Host: cfg.LocalTargetHost}
+		upstreamDial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return loopback.DialContext(ctx, network, net.JoinHostPort("localhost", strconv.Itoa(child.Port())))
+		}
+	}
+
 	// Create proxy server
 	requestedFunnelProxyProtocol := cfg.UseFunnelProxyProtocol()
 	effectiveFunnelProxyProtocol := requestedFunnelProxyProtocol && useLocalTailscale
 	if cfg.HasFunnelAllowlist() && !requestedFunnelProxyProtocol {
+		reason := "non_root_mount_path"
+		if len(cfg.FunnelPaths) > 0 {
+			reason = "funnel_paths"
+		}
 		logger.Warn("Funnel allowlist active without PROXY protocol",
 			logging.Component("proxy_server"),
 			zap.String("set_path", cfg.GetSetPath()),
-			zap.String("reason", "non_root_mount_path"),
+			zap.String("reason", reason),
 		)
 	}
 	if cfg.HasFunnelAllowlist() && requestedFunnelProxyProtocol && !useLocalTailscale {
@@ -181,137 +384,811 @@ func main() {
 		)
 	}
 
+	var captureSink *capture.FileSink
+	if cfg.CaptureFile != "" {
+		captureSink, err = capture.NewFileSink(cfg.CaptureFile, int64(cfg.CaptureMaxSize)*1024*1024)
+		if err != nil {
+			fatal(logger, exitcode.Failure, logging.MsgSetupFailed,
+				logging.Component("capture_file"),
+				logging.Error(err),
+			)
+		}
+		defer captureSink.Close()
+
+		logger.Info("Request capture file enabled",
+			logging.Component("capture_file"),
+			zap.String("path", captureSink.Path()),
+			zap.Int("max_size_mb", cfg.CaptureMaxSize),
+		)
+	}
+
+	var mockEngine *mock.Engine
+	if cfg.Mock {
+		var mockConfig *mock.Config
+		if cfg.MockConfig != "" {
+			mockConfig, err = mock.LoadConfig(cfg.MockConfig)
+			if err != nil {
+				fatal(logger, exitcode.Config, logging.MsgSetupFailed,
+					logging.Component("mock_config"),
+					logging.Error(err),
+				)
+			}
+			logger.Info("Mock config loaded",
+				logging.Component("mock_config"),
+				zap.String("path", cfg.MockConfig),
+				zap.Int("profiles", len(mockConfig.Profiles)),
+				zap.Int("rules", len(mockConfig.Rules)),
+				zap.Int("scenarios", len(mockConfig.Scenarios)),
+				zap.Int("resources", len(mockConfig.Resources)),
+			)
+		}
+		mockEngine = mock.NewEngine(mockConfig)
+		if cfg.Deterministic {
+			mockEngine.SetDeterministic()
+		}
+
+		if cfg.Playback != "" {
+			recording := openRecording(logger, cfg.Playback)
+			mockEngine.SetPlayback(recording)
+			logger.Info("Playback enabled",
+				logging.Component("mock_playback"),
+				zap.String("path", cfg.Playback),
+				zap.Int("responses", recording.Len()),
+			)
+		}
+	}
+
+	var recorder *mock.Recording
+	if cfg.Record != "" {
+		recorder = openRecording(logger, cfg.Record)
+		logger.Info("Recording upstream responses",
+			logging.Component("proxy_record"),
+			zap.String("path", cfg.Record),
+			zap.Int("existing_responses", recorder.Len()),
+		)
+	}
+
+	var geoDB proxy.GeoLocator
+	if len(cfg.GeoIPDBs) > 0 {
+		db, err := geoip.Open(cfg.GeoIPDBs)
+		if err != nil {
+			fatal(logger, exitcode.Config, logging.MsgSetupFailed,
+				logging.Component("geoip"),
+				logging.Error(err),
+			)
+		}
+		logger.Info("GeoIP databases loaded",
+			logging.Component("geoip"),
+			zap.Strings("paths", cfg.GeoIPDBs),
+		)
+		geoDB = db
+	}
+
+	var wireRecorder *wire.Recorder
+	if cfg.WireCapture != "" {
+		rec, err := wire.Create(cfg.WireCapture)
+		if err != nil {
+			fatal(logger, exitcode.Config, logging.MsgSetupFailed,
+				logging.Component("wire_capture"),
+				logging.Error(err),
+			)
+		}
+		logger.Info("Recording client connections",
+			logging.Component("wire_capture"),
+			zap.String("path", cfg.WireCapture),
+		)
+		defer func() {
+			if err := rec.Close(); err != nil {
+				logger.Warn("Wire capture incomplete",
+					logging.Component("wire_capture"),
+					logging.Error(err),
+				)
+			}
+		}()
+		wireRecorder = rec
+	}
+
 	proxyConfig := proxy.Config{
 		TargetPort:      cfg.Port,
+		TargetHost:      cfg.TargetHost,
+		LoopbackHost:    cfg.LocalTargetHost,
+		Dial:            upstreamDial,
+		Mounts:          proxyMounts(logger, cfg.Mounts),
+		Unmatched:       proxyUnmatched(cfg.Unmatched),
+		Quotas:          proxyQuotas(cfg.Quotas),
+		Exclude:         proxyExcludes(cfg.Exclude),
+		Exposes:         proxyExposes(logger, cfg.Exposes),
+		Middleware:      cfg.Middleware,
+		Chaos:           chaos.NewEngine(cfg.Chaos),
+		Timeouts:        proxyTimeouts(cfg),
+		Quirks:          proxyQuirks(cfg),
 		UseTUI:          !cfg.NoTUI,
 		Mode:            serverMode,
 		Logger:          logger,
 		FunnelEnabled:   cfg.Funnel,
 		FunnelAllowlist: cfg.FunnelAllowlist,
+		AllowMethods:    cfg.AllowMethods,
+		FunnelPaths:     cfg.FunnelPaths,
 		PreferRemoteIP:  effectiveFunnelProxyProtocol,
 		InitialEndpoint: initialEndpointState(cfg, useLocalTailscale),
+		MockEngine:      mockEngine,
+		Recorder:        recorder,
+		CORS: middleware.CORSConfig{
+			Origins:          cfg.CORSOrigins,
+			Methods:          cfg.CORSMethods,
+			AllowCredentials: cfg.CORSCredentials,
+		},
+		PreserveHost: cfg.PreserveHost,
+		UpstreamHost: cfg.UpstreamHost,
+
+		ExternalScheme:  externalScheme(cfg),
+		ForwardedHeader: cfg.ForwardedHeader,
+		Cookies:         proxyCookies(cfg),
+		KeepLocation:    cfg.NoRewriteLocation,
+		RewriteHTML:     cfg.RewriteHTML,
+
+		StatusEndpoint: cfg.StatusEndpoint,
+		Version:        Version,
+		Pprof:          cfg.Pprof,
+		MaxRequests:    cfg.MaxRequests,
+		JWKSURL:        cfg.JWKSURL,
+		Duplicates: proxy.Duplicates{
+			Enabled: cfg.DetectDuplicates,
+			Window:  cfg.DuplicateWindow,
+			Skip:    cfg.SkipDuplicates,
+			Headers: cfg.DuplicateHeaders,
+		},
+		OAuth: proxy.OAuth{
+			Path:         cfg.OAuthCallback,
+			TokenURL:     cfg.OAuthTokenURL,
+			ClientID:     cfg.OAuthClientID,
+			ClientSecret: cfg.OAuthClientSecret,
+			CodeVerifier: cfg.OAuthCodeVerifier,
+			RedirectURI:  cfg.OAuthRedirectURI,
+		},
+		Scanners: proxy.Scanners{
+			Stats: cfg.ScannerStats,
+			Ban:   cfg.ScannerBan,
+		},
+		GeoIP: geoDB,
+		Wire:  wireRecorder,
+		Sensitive: proxy.Sensitive{
+			Paths:           cfg.SensitivePaths,
+			RequireApproval: cfg.ApproveSensitive,
+			ApprovalTimeout: cfg.ApprovalTimeout,
+		},
+		NoRequestBodies:  cfg.NoRequestBody,
+		NoResponseBodies: cfg.NoResponseBody,
+
+		Limits: proxy.Limits{
+			MaxBodyBytes:      cfg.MaxBodySize,
+			MaxHeaderBytes:    cfg.MaxHeaderSize,
+			MaxHeaders:        cfg.MaxHeaders,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		},
+		Transport: proxy.Transport{
+			MaxIdleConnsPerHost: cfg.UpstreamMaxIdleConns,
+			IdleConnTimeout:     cfg.UpstreamIdleTimeout,
+			DialTimeout:         cfg.UpstreamDialTimeout,
+			DisableKeepAlives:   cfg.UpstreamDisableKeepAlive,
+		},
+	}
+	if captureSink != nil {
+		proxyConfig.CaptureSink = captureSink
 	}
 
 	proxyServer := proxy.NewServer(proxyConfig)
+	proxyServer.SetAPITokens(loadAPITokens(logger))
+	proxyServer.SetSavedFilters(loadSavedFilters(logger))
+	if cfg.UIPath != "" && !useLocalTailscale {
+		// tsnet has no serve config to mount the UI with, so the proxy
+		// serves it itself.
+		proxyServer.SetUI(cfg.UIPath, ui.NewServer(proxyServer, uiFiles))
+	}
+	if useLocalTailscale {
+		proxyServer.SetWhoIs(tsClient.WhoIs)
+	}
+
+	// An expired share, or a failure with --fail-fast, shuts portal down like
+	// a signal would.
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	proxyServer.SetOnExpire(func(string) { stop() })
+	failures := exitcode.NewRecorder(cfg.FailFast, stop)
+	if cfg.ExpiresIn > 0 {
+		expiry := time.AfterFunc(cfg.ExpiresIn, func() { proxyServer.Expire(proxy.ExpiryTime) })
+		defer expiry.Stop()
+	}
+	if cfg.ExpiresIn > 0 || cfg.MaxRequests > 0 {
+		logger.Info("Share will expire",
+			logging.Component("proxy_server"),
+			zap.Duration("expires_in", cfg.ExpiresIn),
+			zap.Int("max_requests", cfg.MaxRequests),
+		)
+	}
+	if waitForTarget {
+		// The target may still come up after the timeout, so it only ends
+		// the run with --fail-fast.
+		var onTimeout func()
+		if cfg.FailFast {
+			onTimeout = func() {
+				failures.Fail(exitcode.UpstreamUnreachable, fmt.Errorf("target not reachable within %s", cfg.WaitForTargetTimeout))
+			}
+		}
+		proxyServer.WaitForUpstream(ctx, cfg.WaitForTargetTimeout, onTimeout)
+	}
+	if cfg.Spool {
+		recoverSpooledSessions(logger, proxyServer)
+		defer startSpool(logger, proxyServer, cfg).Close()
+	}
+
+	// Attached TUIs get the application logs through the control server.
+	var controlServer *control.Server
+	if cfg.GRPCSocket != "" {
+		controlServer = startControl(ctx, logger, proxyServer, cfg.GRPCSocket)
+		logger = controlServer.TeeLogger(logger)
+		proxyServer.ReplaceLogger(logger)
+		child.ReplaceLogger(logger)
+	}
+
+	var sessionRecorder *session.Recorder
+	if !cfg.NoSessionManifest {
+		sessionRecorder = startSessionManifest(cfg, proxyServer, startTime)
+	}
 
 	if cfg.NoTUI {
-		runWithoutTUI(ctx, logger, useLocalTailscale, tsClient, proxyServer, cfg)
+		err = runWithoutTUI(ctx, logger, useLocalTailscale, tsClient, proxyServer, cfg, child, failures)
 	} else {
-		runWithTUI(ctx, logger, useLocalTailscale, tsClient, proxyServer, cfg)
+		err = runWithTUI(ctx, logger, useLocalTailscale, tsClient, proxyServer, cfg, child, failures, controlServer)
+		if err != nil {
+			// The TUI's logs are gone once it exits.
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+
+	if reason := proxyServer.ExpiredReason(); reason != "" {
+		total, _, _, _, _, _ := proxyServer.GetStats()
+		fmt.Fprintln(os.Stderr, expirySummary(reason, time.Since(startTime), total))
+	}
+
+	if sessionRecorder != nil {
+		writeSessionManifest(logger, sessionRecorder, proxyServer, useLocalTailscale, exitcode.Code(err))
 	}
 
 	logger.Info(logging.MsgServerStopped,
 		logging.Duration(time.Since(startTime)),
 	)
+	return exitcode.Code(err)
 }
 
-func runWithoutTUI(ctx context.Context, logger *zap.Logger, useLocalTailscale bool, tsClient *tailscale.Client, proxyServer *proxy.Server, cfg *config.Config) {
-	logger.Info(logging.MsgConsoleMode,
-		logging.TUIEnabled(false),
-	)
-	proxyServer.SetEndpointState(initialEndpointState(cfg, useLocalTailscale))
+// fatal logs msg at fatal level and exits with code.
+func fatal(logger *zap.Logger, code int, msg string, fields ...zap.Field) {
+	logger.WithOptions(zap.WithFatalHook(exitWith(code))).Fatal(msg, fields...)
+}
 
-	// Set up servers
-	var cleanup func() error
-	var uiCleanup func() error
-	var serviceInfo *tailscale.ServiceInfo
+// exitWith is a zap fatal hook that exits with its code rather than 1.
+type exitWith int
 
-	if useLocalTailscale {
-		cleanup, uiCleanup, serviceInfo = setupLocalTailscale(ctx, tsClient, proxyServer, logger, cfg)
-		if serviceInfo != nil {
-			summary := startup.BuildReadySummary(
-				cfg,
-				true,
-				serviceInfo.URL,
-				serviceInfo.LocalURL,
-				proxyServer.GetWebUIURL(),
-				startup.TSNetDetails{},
-			)
-			proxyServer.SetEndpointState(summary.EndpointState())
-			logStartupSummary(logger, summary)
-		} else {
-			proxyServer.MarkEndpointFailure("tailscale serve setup failed")
-		}
-	} else {
-		cleanup = setupTsnet(ctx, proxyServer, logger, cfg, func(readyInfo tailscale.TSNetReadyInfo) {
-			summary := startup.BuildReadySummary(
-				cfg,
-				false,
-				readyInfo.ServiceURL,
-				"",
-				proxyServer.GetWebUIURL(),
-				startup.TSNetDetails{
-					ConfiguredListenMode: readyInfo.ConfiguredListenMode,
-					EffectiveListenMode:  readyInfo.EffectiveListenMode,
-					ServiceName:          readyInfo.ServiceName,
-					ServiceFQDN:          readyInfo.ServiceFQDN,
-				},
-			)
-			proxyServer.SetEndpointState(summary.EndpointState())
-			logStartupSummary(logger, summary)
-		})
+func (code exitWith) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {
+	os.Exit(int(code))
+}
+
+// expirySummary describes why an expiring share stopped.
+func expirySummary(reason string, uptime time.Duration, requests int) string {
+	cause := "its time limit was reached"
+	if reason == proxy.ExpiryMaxRequests {
+		cause = "its request budget was used up"
 	}
+	return fmt.Sprintf("Share expired because %s: served %d requests over %s.", cause, requests, uptime.Round(time.Second))
+}
 
-	logger.Info(logging.MsgSetupComplete,
-		logging.TailscaleMode(func() string {
-			if useLocalTailscale {
-				return "local_daemon"
-			} else {
-				return "tsnet"
-			}
-		}()),
-	)
+// proxySettings builds the runtime-adjustable proxy settings from cfg.
+func proxySettings(cfg *config.Config) proxy.Settings {
+	mounts := make([]proxy.Mount, 0, len(cfg.Mounts))
+	for _, m := range cfg.Mounts {
+		mounts = append(mounts, proxy.Mount{Path: m.Path, Target: m.Target})
+	}
+	return proxy.Settings{
+		CORS: middleware.CORSConfig{
+			Origins:          cfg.CORSOrigins,
+			Methods:          cfg.CORSMethods,
+			AllowCredentials: cfg.CORSCredentials,
+		},
+		FunnelAllowlist: cfg.FunnelAllowlist,
+		AllowMethods:    cfg.AllowMethods,
+		Mounts:          mounts,
+		Unmatched:       proxyUnmatched(cfg.Unmatched),
+		Quotas:          proxyQuotas(cfg.Quotas),
+		Exclude:         proxyExcludes(cfg.Exclude),
+		PreserveHost:    cfg.PreserveHost,
+		UpstreamHost:    cfg.UpstreamHost,
+		ForwardedHeader: cfg.ForwardedHeader,
+		Cookies:         proxyCookies(cfg),
+		KeepLocation:    cfg.NoRewriteLocation,
+		RewriteHTML:     cfg.RewriteHTML,
+	}
+}
 
-	// Wait for shutdown signal
-	<-ctx.Done()
+// proxyCookies converts the configured cookie rewriting.
+func proxyCookies(cfg *config.Config) proxy.CookieRewrite {
+	return proxy.CookieRewrite{Enabled: cfg.RewriteCookies, SameSite: cfg.CookieSameSite}
+}
 
-	logger.Info(logging.MsgServerStopping)
+// proxyUnmatched converts the configured --unmatched action.
+func proxyUnmatched(unmatched config.Unmatched) proxy.Unmatched {
+	switch unmatched.Action {
+	case config.UnmatchedNotFound:
+		return proxy.Unmatched{NotFound: true}
+	case config.UnmatchedRedirect:
+		return proxy.Unmatched{Redirect: unmatched.Location}
+	case config.UnmatchedMount:
+		return proxy.Unmatched{Mount: unmatched.Location}
+	}
+	return proxy.Unmatched{}
+}
 
-	if cleanup != nil {
-		if err := cleanup(); err != nil {
-			logger.Error(logging.MsgRuntimeError,
-				logging.Operation("cleanup"),
-				logging.Error(err),
-			)
-		}
+// proxyQuotas converts the configured --quota entries.
+func proxyQuotas(quotas []config.Quota) []proxy.Quota {
+	converted := make([]proxy.Quota, 0, len(quotas))
+	for _, q := range quotas {
+		converted = append(converted, proxy.Quota{Match: q.Match, Limit: q.Limit, Period: q.Period})
 	}
+	return converted
+}
 
-	if uiCleanup != nil {
-		if err := uiCleanup(); err != nil {
-			logger.Error(logging.MsgRuntimeError,
-				logging.Operation("ui_cleanup"),
+// proxyExcludes converts the configured --exclude rules.
+func proxyExcludes(excludes []config.Exclude) []proxy.Exclude {
+	converted := make([]proxy.Exclude, 0, len(excludes))
+	for _, e := range excludes {
+		converted = append(converted, proxy.Exclude{Method: e.Method, Path: e.Path})
+	}
+	return converted
+}
+
+// startConfigReload re-reads the user and project config files and mock
+// config on SIGHUP or when any of them changes, and applies what can change
+// without restarting.
+// The Tailscale serve registration and open connections are kept.
+func startConfigReload(ctx context.Context, logger *zap.Logger, proxyServer *proxy.Server, cfg *config.Config) {
+	var paths []string
+	if configPath, err := config.DefaultConfigPath(); err == nil {
+		paths = append(paths, configPath)
+	}
+	if cfg.ProjectConfig != "" {
+		paths = append(paths, cfg.ProjectConfig)
+	}
+	if cfg.MockConfig != "" && cfg.MockConfig != cfg.ProjectConfig {
+		paths = append(paths, cfg.MockConfig)
+	}
+	tokenPath, tokenPathErr := apitoken.DefaultPath()
+	if tokenPathErr == nil {
+		paths = append(paths, tokenPath)
+	}
+
+	go reload.Watch(ctx, paths, reload.DefaultInterval, func(reason string) {
+		if tokens := proxyServer.APITokens(); tokens != nil && tokenPathErr == nil {
+			if err := tokens.Reload(tokenPath); err != nil {
+				logger.Error("API token reload failed; keeping current tokens",
+					logging.Component("config_reload"),
+					logging.Error(err),
+				)
+			}
+		}
+
+		next, err := config.Parse()
+		if err != nil {
+			logger.Error("Config reload failed; keeping current configuration",
+				logging.Component("config_reload"),
+				zap.String("trigger", reason),
 				logging.Error(err),
 			)
+			return
 		}
-	}
-}
 
-func runWithTUI(ctx context.Context, logger *zap.Logger, useLocalTailscale bool, tsClient *tailscale.Client, proxyServer *proxy.Server, cfg *config.Config) {
-	// TUI MODE - Initialize TUI with proper message routing
-	proxyServer.SetEndpointState(initialEndpointState(cfg, useLocalTailscale))
+		if (next.Port != cfg.Port && !next.DetectPort) || next.TargetHost != cfg.TargetHost || next.Funnel != cfg.Funnel ||
+			next.UseHTTPS != cfg.UseHTTPS || next.GetServePort() != cfg.GetServePort() ||
+			next.GetSetPath() != cfg.GetSetPath() || next.Mock != cfg.Mock {
+			logger.Warn("Config reload ignored changes to port, target, funnel, HTTPS, serve port, path or mode; restart to apply them",
+				logging.Component("config_reload"),
+			)
+		}
 
-	// Create TUI program
-	tuiModel := tui.NewModel(proxyServer)
-	program := tea.NewProgram(tuiModel, tea.WithAltScreen())
+		proxyServer.ApplySettings(proxySettings(next))
 
-	// Connect the proxy server to the TUI immediately
-	proxyServer.SetProgram(program)
+		if engine := proxyServer.MockEngine(); engine != nil && next.MockConfig != "" {
+			mockConfig, err := mock.LoadConfig(next.MockConfig)
+			if err != nil {
+				logger.Error("Mock config reload failed; keeping current rules",
+					logging.Component("config_reload"),
+					logging.Error(err),
+				)
+			} else {
+				engine.Reload(mockConfig)
+			}
+		}
 
-	// Add a single listener that properly converts and sends messages to TUI
-	proxyServer.AddListener(func(log model.RequestLog) {
-		// Send using the correct TUI message type
-		program.Send(tui.RequestMsg{Log: log})
+		logger.Info("Configuration reloaded",
+			logging.Component("config_reload"),
+			zap.String("trigger", reason),
+		)
 	})
+}
 
-	// Replace the server's logger to route to TUI instead of console
-	tuiZapLogger := tui.CreateTUIZapLogger(program)
-	proxyServer.ReplaceLogger(tuiZapLogger)
+// proxyMounts converts configured mounts and logs the routing table.
+func proxyMounts(logger *zap.Logger, mounts []config.Mount) []proxy.Mount {
+	converted := make([]proxy.Mount, 0, len(mounts))
+	for _, m := range mounts {
+		mount := proxy.Mount{Path: m.Path, Target: m.Target}
+		logger.Info("Mount configured",
+			logging.Component("proxy_server"),
+			zap.String("mount", mount.String()),
+		)
+		converted = append(converted, mount)
+	}
+	return converted
+}
 
-	// Set up servers in background
-	var cleanup func() error
-	var uiCleanup func() error
+// proxyTimeouts converts the configured upstream timeouts.
+func proxyTimeouts(cfg *config.Config) proxy.UpstreamTimeouts {
+	timeouts := proxy.UpstreamTimeouts{
+		Default: proxy.Timeouts{
+			Overall: cfg.UpstreamTimeout,
+			Header:  cfg.UpstreamHeaderTimeout,
+			Idle:    cfg.UpstreamResponseIdleTimeout,
+		},
+	}
+	for _, route := range cfg.TimeoutRoutes {
+		timeouts.Routes = append(timeouts.Routes, proxy.TimeoutRoute{
+			Path:    route.Path,
+			Methods: route.Methods,
+			Timeouts: proxy.Timeouts{
+				Overall: route.Timeout,
+				Header:  route.HeaderTimeout,
+				Idle:    route.IdleTimeout,
+			},
+		})
+	}
+	return timeouts
+}
 
-	// Start server setup in a goroutine
+// proxyQuirks converts the configured upstream quirks.
+func proxyQuirks(cfg *config.Config) proxy.UpstreamQuirks {
+	quirks := proxy.UpstreamQuirks{Default: quirkSet(cfg.UpstreamQuirks)}
+	for _, route := range cfg.QuirkRoutes {
+		quirks.Routes = append(quirks.Routes, proxy.QuirkRoute{
+			Path:    route.Path,
+			Methods: route.Methods,
+			Quirks:  quirkSet(route.Quirks),
+		})
+	}
+	return quirks
+}
+
+func quirkSet(names []string) proxy.Quirks {
+	return proxy.Quirks{
+		HTTP10:           slices.Contains(names, config.QuirkHTTP10),
+		NoExpectContinue: slices.Contains(names, config.QuirkNoExpectContinue),
+		NoChunked:        slices.Contains(names, config.QuirkNoChunked),
+		LenientHeaders:   slices.Contains(names, config.QuirkLenientHeaders),
+	}
+}
+
+// proxyExposes converts configured exposes and logs them.
+func proxyExposes(logger *zap.Logger, exposes []config.Expose) []proxy.Expose {
+	converted := make([]proxy.Expose, 0, len(exposes))
+	for _, e := range exposes {
+		expose := proxy.Expose{Name: e.Name, Target: e.Target}
+		logger.Info("Expose configured",
+			logging.Component("proxy_server"),
+			zap.String("expose", expose.String()),
+		)
+		converted = append(converted, expose)
+	}
+	return converted
+}
+
+// externalScheme is the scheme clients use to reach the tailnet service.
+// Funnel requests are https whatever it is; the proxy sets that per request.
+func externalScheme(cfg *config.Config) string {
+	if cfg.UseHTTPS {
+		return "https"
+	}
+	return "http"
+}
+
+// loadAPITokens reads the API token file. Mutating UI API calls require a
+// token once any exist; a file that cannot be read stops startup rather than
+// leaving the API open.
+func loadAPITokens(logger *zap.Logger) *apitoken.Store {
+	path, err := apitoken.DefaultPath()
+	if err != nil {
+		fatal(logger, exitcode.Failure, logging.MsgSetupFailed, logging.Component("api_tokens"), logging.Error(err))
+	}
+	tokens, err := apitoken.Load(path)
+	if err != nil {
+		fatal(logger, exitcode.Failure, logging.MsgSetupFailed, logging.Component("api_tokens"), logging.Error(err))
+	}
+	if tokens.Enabled() {
+		logger.Info("API token authentication enabled for mutating UI API calls",
+			logging.Component("api_tokens"),
+			zap.Int("tokens", len(tokens.List())),
+		)
+	}
+	return tokens
+}
+
+// portMemory remembers the ports of sessions started from the working
+// directory for the same target, so their URLs stay the same across
+// restarts. Without a cache directory nothing is remembered.
+func portMemory(cfg *config.Config) *tailscale.PortMemory {
+	path, err := tailscale.DefaultPortMemoryPath()
+	if err != nil {
+		return nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	return tailscale.NewPortMemory(path, fmt.Sprintf("%s:%d", dir, cfg.Port))
+}
+
+// loadSavedFilters reads the web UI's saved filters. A broken file only
+// costs the saved filters, so it is logged rather than fatal.
+func loadSavedFilters(logger *zap.Logger) *filters.Store {
+	path, err := filters.DefaultPath()
+	var store *filters.Store
+	if err == nil {
+		store, err = filters.Load(path)
+	}
+	if err != nil {
+		logger.Warn("Saved filters are unavailable",
+			logging.Component("ui_server"),
+			logging.Error(err),
+		)
+	}
+	return store
+}
+
+func openRecording(logger *zap.Logger, path string) *mock.Recording {
+	recording, err := mock.OpenRecording(path)
+	if err != nil {
+		fatal(logger, exitcode.Config, logging.MsgSetupFailed,
+			logging.Component("mock_recording"),
+			logging.Error(err),
+		)
+	}
+	return recording
+}
+
+// runWithoutTUI serves until ctx is done and returns the first failure
+// recorded meanwhile.
+func runWithoutTUI(ctx context.Context, logger *zap.Logger, useLocalTailscale bool, tsClient *tailscale.Client, proxyServer *proxy.Server, cfg *config.Config, child *supervisor.Supervisor, failures *exitcode.Recorder) error {
+	logger.Info(logging.MsgConsoleMode,
+		logging.TUIEnabled(false),
+	)
+	proxyServer.SetEndpointState(initialEndpointState(cfg, useLocalTailscale))
+	startConfigReload(ctx, logger, proxyServer, cfg)
+	startTime := time.Now()
+
+	var emitter *events.Emitter
+	if cfg.Output == config.OutputJSON {
+		emitter = events.NewEmitter(os.Stdout)
+		proxyServer.AddListener(emitter.Request)
+	}
+	if cfg.Stream {
+		proxyServer.AddListener(capture.NewStream(os.Stdout).Request)
+	}
+	notifier := notify.New(cfg.Notify, notify.Desktop, logger)
+	if notifier != nil {
+		proxyServer.AddListener(notifier.Request)
+	}
+	if webhook := startCaptureWebhook(logger, proxyServer, cfg); webhook != nil {
+		defer webhook.Close()
+	}
+	defer startAlerts(logger, proxyServer, cfg, nil).Close()
+	child.SetOutput(commandOutput(logger, cfg))
+	if !cfg.JSON {
+		// Print each request with its response as one block; the per-request
+		// log lines would interleave under concurrency.
+		color := term.IsTerminal(int(os.Stderr.Fd())) && os.Getenv("NO_COLOR") == ""
+		proxyServer.AddListener(logging.NewConsoleRequests(os.Stderr, color).Request)
+		proxyServer.SetRequestLogLevel(zap.DebugLevel)
+	}
+	onReady := func(summary startup.Summary) {
+		proxyServer.SetEndpointState(summary.EndpointState())
+		logStartupSummary(logger, summary)
+		notifier.Ready(summary)
+		if emitter != nil && summary.IsReady() {
+			emitter.Ready(summary)
+		}
+	}
+
+	// Set up servers
+	var cleanup func() error
+	var uiCleanup func() error
+	var serviceInfo *tailscale.ServiceInfo
+
+	if cfg.SSH != "" {
+		var serviceURL string
+		var err error
+		cleanup, uiCleanup, serviceURL, err = setupSSHTunnel(ctx, proxyServer, logger, cfg)
+		if err == nil {
+			onReady(startup.BuildSSHReadySummary(cfg, serviceURL, proxyServer.GetWebUIURL()))
+		} else {
+			logger.Error(logging.MsgSetupFailed,
+				logging.Component("ssh_tunnel"),
+				logging.Error(err),
+			)
+			proxyServer.MarkEndpointFailure(err.Error())
+			if emitter != nil {
+				emitter.Error(err)
+			}
+			failures.Fail(exitcode.Failure, err)
+		}
+	} else if useLocalTailscale {
+		cleanup, uiCleanup, serviceInfo = setupLocalTailscale(ctx, tsClient, proxyServer, logger, cfg)
+		if serviceInfo != nil {
+			summary := startup.BuildReadySummary(
+				cfg,
+				true,
+				serviceInfo.URL,
+				serviceInfo.LocalURL,
+				proxyServer.GetWebUIURL(),
+				startup.TSNetDetails{},
+			)
+			onReady(summary)
+		} else {
+			err := errors.New("tailscale serve setup failed")
+			proxyServer.MarkEndpointFailure(err.Error())
+			if emitter != nil {
+				emitter.Error(err)
+			}
+			failures.Fail(exitcode.Failure, err)
+		}
+	} else {
+		cleanup = setupTsnet(ctx, proxyServer, logger, cfg, func(readyInfo tailscale.TSNetReadyInfo) {
+			summary := startup.BuildReadySummary(
+				cfg,
+				false,
+				readyInfo.ServiceURL,
+				"",
+				proxyServer.GetWebUIURL(),
+				startup.TSNetDetails{
+					ConfiguredListenMode: readyInfo.ConfiguredListenMode,
+					EffectiveListenMode:  readyInfo.EffectiveListenMode,
+					ServiceName:          readyInfo.ServiceName,
+					ServiceFQDN:          readyInfo.ServiceFQDN,
+				},
+			)
+			onReady(summary)
+		}, func(err error) {
+			if emitter != nil {
+				emitter.Error(err)
+			}
+			failures.Fail(exitcode.TailscaleUnavailable, err)
+		})
+	}
+
+	logger.Info(logging.MsgSetupComplete,
+		logging.TailscaleMode(func() string {
+			if cfg.SSH != "" {
+				return startup.ModeSSH
+			} else if useLocalTailscale {
+				return "local_daemon"
+			} else {
+				return "tsnet"
+			}
+		}()),
+	)
+
+	// Wait for shutdown signal
+	<-ctx.Done()
+	// Failures during cleanup aren't the run's.
+	err := failures.Err()
+
+	logger.Info(logging.MsgServerStopping)
+
+	if cleanup != nil {
+		if err := cleanup(); err != nil {
+			logger.Error(logging.MsgRuntimeError,
+				logging.Operation("cleanup"),
+				logging.Error(err),
+			)
+		}
+	}
+
+	if uiCleanup != nil {
+		if err := uiCleanup(); err != nil {
+			logger.Error(logging.MsgRuntimeError,
+				logging.Operation("ui_cleanup"),
+				logging.Error(err),
+			)
+		}
+	}
+
+	if emitter != nil {
+		total, _, _, _, _, _ := proxyServer.GetStats()
+		emitter.Shutdown(time.Since(startTime), total)
+	}
+	return err
+}
+
+// runWithTUI serves until the TUI quits and returns the first failure
+// recorded meanwhile. Failures are shown in the TUI, which keeps running
+// unless --fail-fast is set.
+func runWithTUI(ctx context.Context, logger *zap.Logger, useLocalTailscale bool, tsClient *tailscale.Client, proxyServer *proxy.Server, cfg *config.Config, child *supervisor.Supervisor, failures *exitcode.Recorder, controlServer *control.Server) error {
+	// TUI MODE - Initialize TUI with proper message routing
+	proxyServer.SetEndpointState(initialEndpointState(cfg, useLocalTailscale))
+
+	// Create TUI program
+	tuiOptions := tui.Options{Refresh: cfg.TUIRefresh, LowPower: cfg.LowPower}
+	tuiModel := tui.NewModel(proxyServer, tuiOptions)
+	options := append([]tea.ProgramOption{tea.WithAltScreen()}, tuiOptions.ProgramOptions()...)
+	var share *termshare.Share
+	if cfg.ShareTerminal {
+		share = termshare.New(termshare.TerminalSize(os.Stdout), nil)
+		options = append(options, tea.WithOutput(termshare.Tee(os.Stdout, share)))
+	}
+	program := tea.NewProgram(tuiModel, options...)
+	if share != nil {
+		// Repaint the whole screen for each new viewer; the renderer
+		// otherwise only sends changed lines.
+		share.SetOnJoin(func() { program.Send(tea.ClearScreen()) })
+		proxyServer.SetTerminalShare(share)
+	}
+
+	// Connect the proxy server to the TUI immediately
+	proxyServer.SetProgram(program)
+
+	// Add a single listener that properly converts and sends messages to TUI
+	proxyServer.AddListener(func(log model.RequestLog) {
+		// Send using the correct TUI message type
+		program.Send(tui.RequestMsg{Log: log})
+	})
+
+	// Ask the operator about requests to sensitive paths.
+	proxyServer.SetApprover(func(ctx context.Context, req model.SensitiveRequest) bool {
+		reply := make(chan bool, 1)
+		deadline, _ := ctx.Deadline()
+		program.Send(tui.ApprovalMsg{Request: req, Reply: reply, Deadline: deadline})
+		select {
+		case approved := <-reply:
+			return approved
+		case <-ctx.Done():
+			return false
+		}
+	})
+
+	// Replace the server's logger to route to TUI instead of console
+	tuiZapLogger := controlServer.TeeLogger(tui.CreateTUIZapLogger(program))
+	proxyServer.ReplaceLogger(tuiZapLogger)
+	child.ReplaceLogger(tuiZapLogger)
+	// The TUI isn't running yet, so the command's output so far is replayed
+	// in the background.
+	go child.SetOutput(func(line supervisor.Line) {
+		program.Send(tui.ProcessOutputMsg{Stream: line.Stream, Text: line.Text, Time: line.Time})
+	})
+	startConfigReload(ctx, tuiZapLogger, proxyServer, cfg)
+
+	notifier := notify.New(cfg.Notify, notify.Desktop, tuiZapLogger)
+	if notifier != nil {
+		proxyServer.AddListener(notifier.Request)
+	}
+	if webhook := startCaptureWebhook(tuiZapLogger, proxyServer, cfg); webhook != nil {
+		defer webhook.Close()
+	}
+	defer startAlerts(tuiZapLogger, proxyServer, cfg, func(event alert.Event) {
+		program.Send(tui.AlertMsg{Rule: event.Rule, Firing: event.Firing(), Value: event.Value, Time: event.Time})
+	}).Close()
+
+	// Set up servers in background
+	var cleanup func() error
+	var uiCleanup func() error
+
+	// Start server setup in a goroutine
 	go func() {
 		// Wait a moment for TUI to initialize
 		time.Sleep(500 * time.Millisecond)
@@ -322,6 +1199,9 @@ func runWithTUI(ctx context.Context, logger *zap.Logger, useLocalTailscale bool,
 		// Send initial messages to TUI application logs using the logger for consistency
 		tuiOnlyLogger.Infof("TUI initialization completed successfully mode=interactive")
 		tuiOnlyLogger.Infof("Control commands available quit_key=q alternate_quit=Ctrl+C navigation=arrow_keys,j,k scroll=PgUp,PgDn")
+		if share != nil {
+			tuiOnlyLogger.Infof("Terminal sharing enabled path=/ui/term read_only=true")
+		}
 
 		tuiOnlyLogger.Infof("Server setup starting mode=%s target_port=%d funnel_enabled=%t https_enabled=%t ui_enabled=%t",
 			func() string {
@@ -341,14 +1221,30 @@ func runWithTUI(ctx context.Context, logger *zap.Logger, useLocalTailscale bool,
 			// Verify it's still available with the new client
 			if !tuiTsClient.IsAvailable(ctx) {
 				tuiOnlyLogger.Errorf("Tailscale not available in TUI mode")
+				failures.Fail(exitcode.TailscaleUnavailable, errors.New("tailscale is no longer available"))
 				return
 			}
 		}
 
-		if useLocalTailscale {
+		if cfg.SSH != "" {
+			var serviceURL string
+			var err error
+			cleanup, uiCleanup, serviceURL, err = setupSSHTunnel(ctx, proxyServer, tui.CreateTUIOnlyZapLogger(tuiOnlyLogger, cfg.Verbose), cfg)
+			if err != nil {
+				tuiOnlyLogger.Errorf("SSH tunnel setup failed bastion=%s error=%v", cfg.SSH, err)
+				proxyServer.MarkEndpointFailure(err.Error())
+				failures.Fail(exitcode.Failure, err)
+				return
+			}
+			summary := startup.BuildSSHReadySummary(cfg, serviceURL, proxyServer.GetWebUIURL())
+			proxyServer.SetEndpointState(summary.EndpointState())
+			logStartupSummaryToTUI(tuiOnlyLogger, summary)
+			notifier.Ready(summary)
+		} else if useLocalTailscale {
 			var serviceInfo *tailscale.ServiceInfo
-			cleanup, uiCleanup, serviceInfo = server.SetupLocalTailscaleQuiet(ctx, tuiTsClient, proxyServer, tuiOnlyLogger, cfg, uiFiles)
-			if serviceInfo != nil {
+			var err error
+			cleanup, uiCleanup, serviceInfo, err = server.SetupLocalTailscaleQuiet(ctx, tuiTsClient, proxyServer, tuiOnlyLogger, cfg, uiFiles)
+			if err == nil {
 				summary := startup.BuildReadySummary(
 					cfg,
 					true,
@@ -359,8 +1255,9 @@ func runWithTUI(ctx context.Context, logger *zap.Logger, useLocalTailscale bool,
 				)
 				proxyServer.SetEndpointState(summary.EndpointState())
 				logStartupSummaryToTUI(tuiOnlyLogger, summary)
+				notifier.Ready(summary)
 			} else {
-				proxyServer.MarkEndpointFailure("tailscale serve setup failed")
+				failures.Fail(exitcode.Failure, err)
 			}
 		} else {
 			cleanup = server.SetupTsnetQuiet(ctx, proxyServer, tuiOnlyLogger, cfg, func(readyInfo tailscale.TSNetReadyInfo) {
@@ -379,14 +1276,25 @@ func runWithTUI(ctx context.Context, logger *zap.Logger, useLocalTailscale bool,
 				)
 				proxyServer.SetEndpointState(summary.EndpointState())
 				logStartupSummaryToTUI(tuiOnlyLogger, summary)
+				notifier.Ready(summary)
+			}, func(err error) {
+				failures.Fail(exitcode.TailscaleUnavailable, err)
 			})
 		}
 	}()
 
+	// Quit the TUI when the share expires.
+	go func() {
+		<-ctx.Done()
+		program.Quit()
+	}()
+
 	// Run TUI - this blocks until user quits
 	if _, err := program.Run(); err != nil {
 		fmt.Printf("TUI error: %v\n", err)
 	}
+	// Failures during cleanup aren't the run's.
+	err := failures.Err()
 
 	// Cleanup after TUI exits
 	if cleanup != nil {
@@ -402,26 +1310,40 @@ func runWithTUI(ctx context.Context, logger *zap.Logger, useLocalTailscale bool,
 	}
 
 	fmt.Printf("portal server stopped\n")
+	return err
 }
 
 func setupLocalTailscale(ctx context.Context, tsClient *tailscale.Client, proxyServer *proxy.Server, logger *zap.Logger, cfg *config.Config) (cleanup func() error, uiCleanup func() error, serviceInfo *tailscale.ServiceInfo) {
 	if cfg.IsServiceMode() {
 		if err := tsClient.ValidateServiceHostIdentity(ctx, cfg.TSNetServiceName); err != nil {
-			logger.Fatal(logging.MsgSetupFailed,
+			fatal(logger, exitcode.Code(server.TailscaleFailure(err)), logging.MsgSetupFailed,
 				logging.Component("tailscale_serve"),
 				logging.Error(err),
 			)
 		}
 	}
 
-	// Find an available port for our local proxy server using random allocation
-	logger.Info("Allocating random proxy port",
+	serveSnapshot, snapshotErr := server.SnapshotServe(ctx, tsClient, cfg)
+	if errors.Is(snapshotErr, tailscale.ErrServeSnapshotHeld) {
+		logger.Info("Another portal session saved this node's serve config and restores it when it exits",
+			logging.Component("tailscale_serve"),
+			logging.Error(snapshotErr),
+		)
+	} else if snapshotErr != nil {
+		logger.Warn("Failed to save the serve config; it will be cleared on exit instead of restored",
+			logging.Component("tailscale_serve"),
+			logging.Error(snapshotErr),
+		)
+	}
+
+	// Reuse this project's proxy port from last time when it is free
+	logger.Info("Allocating proxy port",
 		logging.Component("proxy_server"),
 	)
 
-	proxyPort, err := tailscale.FindAvailableLocalPort()
+	proxyPort, err := tsClient.Ports().ProxyPort()
 	if err != nil {
-		logger.Fatal("Failed to allocate proxy port",
+		fatal(logger, exitcode.Failure, "Failed to allocate proxy port",
 			logging.Component("proxy_server"),
 			logging.Error(err),
 		)
@@ -435,18 +1357,19 @@ func setupLocalTailscale(ctx context.Context, tsClient *tailscale.Client, proxyS
 	// Start proxy server
 	logger.Info(logging.MsgProxyStarting,
 		logging.ProxyPort(proxyPort),
-		logging.BindAddress("0.0.0.0"),
+		logging.BindAddress(cfg.Bind),
 	)
 
 	useFunnelProxyProtocol := cfg.UseFunnelProxyProtocol()
 	httpServer := &http.Server{
-		Addr:    fmt.Sprintf(":%d", proxyPort),
+		Addr:    cfg.LocalAddr(proxyPort),
 		Handler: proxyServer,
 	}
+	proxyServer.ConfigureHTTPServer(httpServer)
 
 	proxyListener, err := httputil.NewHTTPListener(httpServer.Addr, useFunnelProxyProtocol)
 	if err != nil {
-		logger.Fatal("Failed to create proxy listener",
+		fatal(logger, exitcode.Failure, "Failed to create proxy listener",
 			logging.Component("proxy_server"),
 			logging.ProxyPort(proxyPort),
 			logging.Error(err),
@@ -454,7 +1377,7 @@ func setupLocalTailscale(ctx context.Context, tsClient *tailscale.Client, proxyS
 	}
 
 	go func() {
-		if err := httpServer.Serve(proxyListener); err != nil && err != http.ErrServerClosed {
+		if err := httpServer.Serve(proxyServer.WrapListener(proxyListener)); err != nil && err != http.ErrServerClosed {
 			logger.Error(logging.MsgRuntimeError,
 				logging.Component("proxy_server"),
 				logging.ProxyPort(proxyPort),
@@ -465,7 +1388,7 @@ func setupLocalTailscale(ctx context.Context, tsClient *tailscale.Client, proxyS
 
 	// Wait for the server to be ready when plain HTTP probing is supported.
 	if !useFunnelProxyProtocol {
-		if err := httputil.WaitForServerReady(ctx, fmt.Sprintf("localhost:%d", proxyPort), 2*time.Second); err != nil {
+		if err := httputil.WaitForServerReady(ctx, net.JoinHostPort("127.0.0.1", strconv.Itoa(proxyPort)), 2*time.Second); err != nil {
 			logger.Error("Proxy server failed to start",
 				logging.Component("proxy_server"),
 				logging.ProxyPort(proxyPort),
@@ -483,35 +1406,19 @@ func setupLocalTailscale(ctx context.Context, tsClient *tailscale.Client, proxyS
 	if !cfg.NoUI {
 		uiPort := cfg.UIPort
 		if uiPort == 0 {
-			logger.Info("Allocating default UI port",
+			logger.Info("Allocating UI port",
 				logging.Component("ui_server"),
 				zap.Int("preferred_ui_port", tailscale.DefaultLocalUIPort),
 			)
 
-			uiPort, err = tailscale.FindAvailableLocalPortFrom(tailscale.DefaultLocalUIPort)
+			uiPort, err = tsClient.Ports().UIPort()
 			if err != nil {
-				logger.Warn("Preferred UI port allocation failed",
+				logger.Warn(logging.MsgPortAllocationFailed,
 					logging.Component("ui_server"),
-					zap.Int("preferred_ui_port", tailscale.DefaultLocalUIPort),
-					logging.Status("falling_back_to_random_port"),
+					logging.Status("disabling_ui"),
 					logging.Error(err),
 				)
-
-				uiPort, err = tailscale.FindAvailableLocalPort()
-				if err != nil {
-					logger.Warn(logging.MsgPortAllocationFailed,
-						logging.Component("ui_server"),
-						logging.Status("disabling_ui"),
-						logging.Error(err),
-					)
-				} else {
-					logger.Info("Allocated fallback UI port",
-						logging.Component("ui_server"),
-						logging.UIPort(uiPort),
-					)
-				}
 			}
-
 		}
 
 		if uiPort > 0 {
@@ -519,7 +1426,7 @@ func setupLocalTailscale(ctx context.Context, tsClient *tailscale.Client, proxyS
 				logging.UIPort(uiPort),
 			)
 
-			uiInfo, err := setupUIServer(ctx, tsClient, uiPort, proxyServer, logger)
+			uiInfo, err := setupUIServer(ctx, tsClient, cfg, uiPort, proxyServer, logger)
 			if err != nil {
 				logger.Warn(logging.MsgSetupFailed,
 					logging.Component("ui_server"),
@@ -551,20 +1458,11 @@ func setupLocalTailscale(ctx context.Context, tsClient *tailscale.Client, proxyS
 		logging.ServePort(cfg.GetServePort()),
 	)
 
-	tsConfig := tailscale.Config{
-		MountPath:           cfg.GetSetPath(),
-		EnableFunnel:        cfg.Funnel,
-		EnableProxyProtocol: useFunnelProxyProtocol,
-		UseHTTPS:            cfg.UseHTTPS,
-		ServePort:           cfg.GetServePort(),
-		ProxyPort:           proxyPort,
-		ListenMode:          cfg.TSNetListenMode,
-		ServiceName:         cfg.TSNetServiceName,
-	}
+	tsConfig := server.ServeConfig(cfg, proxyPort)
 
 	svcInfo, err := tsClient.SetupServe(ctx, tsConfig)
 	if err != nil {
-		logger.Fatal(logging.MsgSetupFailed,
+		fatal(logger, exitcode.Code(server.TailscaleFailure(err)), logging.MsgSetupFailed,
 			logging.Component("tailscale_serve"),
 			logging.Error(err),
 		)
@@ -575,6 +1473,15 @@ func setupLocalTailscale(ctx context.Context, tsClient *tailscale.Client, proxyS
 		logging.TargetPort(cfg.Port),
 		logging.MockMode(cfg.Mock),
 	)
+	for _, funnelURL := range svcInfo.FunnelURLs {
+		logger.Info("Public funnel path",
+			logging.Component("tailscale_serve"),
+			logging.URL(funnelURL),
+		)
+	}
+	if !cfg.IsServiceMode() {
+		server.WarmCertificate(ctx, proxyServer, svcInfo.URL, tsClient.WarmCert)
+	}
 
 	cleanup = func() error {
 		logger.Info(logging.MsgCleanupStarting,
@@ -585,9 +1492,33 @@ func setupLocalTailscale(ctx context.Context, tsClient *tailscale.Client, proxyS
 		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cleanupCancel()
 
-		// Comprehensive cleanup of all Tailscale serve configs
-		err := tsClient.CleanupAll(cleanupCtx)
-		if err != nil {
+		if cfg.Namespace != "" {
+			// Other developers' namespaces share the node; remove only ours.
+			if err := tsClient.RemoveMounts(cleanupCtx, tsConfig, tsConfig.MountPath, cfg.UIMountPath()); err != nil {
+				logger.Warn("Failed to remove serve handlers",
+					logging.Component("tailscale_serve"),
+					zap.String("namespace", cfg.Namespace),
+					logging.Error(err),
+				)
+			}
+		} else if serveSnapshot != nil {
+			if err := tsClient.RestoreServe(cleanupCtx, serveSnapshot); err != nil {
+				logger.Warn("Failed to restore the serve config; the next portal session will retry",
+					logging.Component("tailscale_serve"),
+					logging.Error(err),
+				)
+			}
+		} else if errors.Is(snapshotErr, tailscale.ErrServeSnapshotHeld) {
+			// The session holding the snapshot restores the config; until
+			// then, take down only this session's handlers.
+			if err := tsClient.RemoveMounts(cleanupCtx, tsConfig, tsConfig.MountPath, cfg.UIMountPath()); err != nil {
+				logger.Warn("Failed to remove serve handlers",
+					logging.Component("tailscale_serve"),
+					logging.Error(err),
+				)
+			}
+		} else if err := tsClient.CleanupAll(cleanupCtx); err != nil {
+			// Comprehensive cleanup of all Tailscale serve configs failed
 			logger.Warn("Failed to perform comprehensive cleanup, trying specific cleanup",
 				logging.Component("tailscale_serve"),
 				logging.Error(err),
@@ -647,7 +1578,7 @@ func handleCleanupServe() {
 			logging.Component("cleanup"),
 		)
 		fmt.Printf("Error: Tailscale daemon not available. Please ensure Tailscale is running.\n")
-		os.Exit(1)
+		os.Exit(exitcode.TailscaleUnavailable)
 	}
 
 	// Perform comprehensive cleanup
@@ -658,7 +1589,18 @@ func handleCleanupServe() {
 			logging.Error(err),
 		)
 		fmt.Printf("Error: Failed to cleanup Tailscale serve configurations: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitcode.TailscaleUnavailable)
+	}
+
+	// A snapshot left by a crashed session would otherwise be restored by
+	// the next one, bringing back what was just cleared.
+	if path, err := tailscale.DefaultServeSnapshotPath(); err == nil {
+		if err := tailscale.DiscardServeSnapshot(path, capture.ProcessAlive); err != nil {
+			logger.Warn("Failed to discard the saved serve config",
+				logging.Component("cleanup"),
+				logging.Error(err),
+			)
+		}
 	}
 
 	logger.Info("Tailscale serve cleanup completed successfully",
@@ -668,49 +1610,369 @@ func handleCleanupServe() {
 	fmt.Printf("You can verify with: tailscale serve status\n")
 }
 
-func setupTsnet(ctx context.Context, proxyServer *proxy.Server, logger *zap.Logger, cfg *config.Config, onReady func(readyInfo tailscale.TSNetReadyInfo)) func() error {
-	logger.Info("Setting up TSNet mode",
-		logging.Component("tsnet_setup"),
-		logging.TailscaleMode("tsnet"),
-		logging.NodeName(cfg.TailscaleName),
-		logging.FunnelEnabled(cfg.Funnel),
-		logging.HTTPSEnabled(cfg.UseHTTPS),
-		logging.ServePort(cfg.GetServePort()),
-		zap.String("tsnet_listen_mode_configured", cfg.TSNetListenMode),
-		zap.String("tsnet_listen_mode_effective", cfg.EffectiveTSNetListenMode()),
-		zap.String("tsnet_service_name", cfg.TSNetServiceName),
-	)
+func handleInit() {
+	configPath, err := config.DefaultConfigPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Use tsnet mode
-	tsnetConfig := tailscale.TSNetConfig{
-		Hostname:     cfg.TailscaleName,
-		AuthKey:      cfg.AuthKey,
-		EnableFunnel: cfg.Funnel,
-		UseHTTPS:     cfg.UseHTTPS,
-		ServePort:    cfg.GetServePort(),
-		ListenMode:   cfg.TSNetListenMode,
-		ServiceName:  cfg.TSNetServiceName,
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	_, err = wizard.Run(ctx, wizard.Options{
+		In:         os.Stdin,
+		Out:        os.Stdout,
+		ConfigPath: configPath,
+		Prober:     tailscale.NewClient(zap.NewNop()),
+	})
+	if errors.Is(err, wizard.ErrAborted) {
+		fmt.Printf("Left %s unchanged.\n", configPath)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
+}
 
-	// Pass the zap.Logger directly instead of creating a sugared logger
-	tsnetServer := tailscale.NewTSNetServer(tsnetConfig, logger)
-	tsnetServer.SetReadyCallback(onReady)
+func handleDoctor(cfg *config.Config) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
 
-	go func() {
-		if err := tsnetServer.Serve(ctx, proxyServer); err != nil {
-			logger.Error(logging.MsgRuntimeError,
+	fmt.Printf("portal doctor\n\n")
+	results := doctor.Run(ctx, tailscale.NewClient(zap.NewNop()), doctor.Options{
+		TargetPort: cfg.Port,
+		TargetHost: cfg.LocalTargetHost,
+		ServePort:  cfg.GetServePort(),
+		Funnel:     cfg.Funnel,
+		UseHTTPS:   cfg.UseHTTPS,
+	})
+	doctor.Print(os.Stdout, results)
+
+	if doctor.Failed(results) {
+		fmt.Printf("\nSome checks failed. Fix the items marked ✗ and run portal doctor again.\n")
+		return 1
+	}
+	fmt.Printf("\nAll required checks passed.\n")
+	return 0
+}
+
+// handleDryRun prints the changes a session would make to the local
+// Tailscale daemon without making them: as text, or as one plan event with
+// --output json.
+func handleDryRun(cfg *config.Config) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tsClient := tailscale.NewClient(zap.NewNop())
+	tsClient.SetPortMemory(portMemory(cfg))
+	if !tsClient.IsAvailable(ctx) {
+		fmt.Fprintf(os.Stderr, "Error: --dry-run plans changes to the local Tailscale daemon, which isn't available. Without it portal starts its own tsnet node and changes no serve config.\n")
+		return exitcode.TailscaleUnavailable
+	}
+	plan, err := server.PlanServe(ctx, tsClient, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Code(err)
+	}
+
+	if cfg.Output == config.OutputJSON {
+		events.NewEmitter(os.Stdout).Plan(plan)
+	} else {
+		server.PrintPlan(os.Stdout, plan, cfg)
+	}
+	return exitcode.OK
+}
+
+func handleToken(cfg *config.Config) int {
+	path, err := apitoken.DefaultPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	tokens, err := apitoken.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	switch cfg.Command {
+	case config.CommandTokenCreate:
+		secret, token, err := tokens.Create(cfg.TokenName, cfg.TokenScopes)
+		if err == nil {
+			err = tokens.Save(path)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Created token %s (scopes: %s)\n\n  %s\n\n", token.ID, strings.Join(token.Scopes, ","), secret)
+		fmt.Printf("Store it now; it cannot be shown again. Send it as \"Authorization: Bearer <token>\".\n")
+	case config.CommandTokenList:
+		list := tokens.List()
+		if len(list) == 0 {
+			fmt.Printf("No API tokens; the web UI API accepts mutating calls without one.\n")
+			return 0
+		}
+		for _, token := range list {
+			fmt.Printf("%-14s %-20s %-22s %s\n", token.ID, token.Name, strings.Join(token.Scopes, ","), token.CreatedAt.Format(time.RFC3339))
+		}
+	case config.CommandTokenRevoke:
+		if err := tokens.Revoke(cfg.TokenID); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if err := tokens.Save(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Revoked token %s\n", cfg.TokenID)
+	}
+	return 0
+}
+
+func handleImport(cfg *config.Config) int {
+	entries, err := capture.ReadFile(cfg.ImportFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no requests found in %s\n", cfg.ImportFile)
+		return 1
+	}
+
+	body, err := json.Marshal(entries)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to encode requests: %v\n", err)
+		return 1
+	}
+	req, err := http.NewRequest(http.MethodPost, cfg.ImportURL+"/api/requests/import", bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.ImportToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.ImportToken)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not reach portal at %s (is it running? set --ui-url): %v\n", cfg.ImportURL, err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Imported int    `json:"imported"`
+		Error    string `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != http.StatusOK {
+		if result.Error == "" {
+			result.Error = resp.Status
+		}
+		fmt.Fprintf(os.Stderr, "Error: import failed: %s\n", result.Error)
+		return 1
+	}
+
+	fmt.Printf("Imported %d of %d requests from %s into %s\n", result.Imported, len(entries), cfg.ImportFile, cfg.ImportURL)
+	return 0
+}
+
+func handleExport(cfg *config.Config) int {
+	query := url.Values{"format": {cfg.ExportFormat}}
+	if cfg.ExportFormat == capture.FormatCSV {
+		query.Set("columns", strings.Join(cfg.ExportColumns, ","))
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(cfg.ExportURL + "/api/export?" + query.Encode())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not reach portal at %s (is it running? set --ui-url): %v\n", cfg.ExportURL, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var result struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		fmt.Fprintf(os.Stderr, "Error: export failed: %s\n", cmp.Or(result.Error, resp.Status))
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+	if cfg.ExportFile != "" {
+		file, err := os.Create(cfg.ExportFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: export failed: %v\n", err)
+		return 1
+	}
+	if cfg.ExportFile != "" {
+		fmt.Fprintf(os.Stderr, "Exported the request history from %s to %s\n", cfg.ExportURL, cfg.ExportFile)
+	}
+	return 0
+}
+
+func handleExpect(cfg *config.Config) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	result := expect.Wait(ctx, client, cfg.ExpectURL, cfg.Expect)
+
+	var out io.Writer = os.Stdout
+	if cfg.ExpectJUnit != "" {
+		file, err := os.Create(cfg.ExpectJUnit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitcode.Failure
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := expect.WriteJUnit(out, result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write the JUnit report: %v\n", err)
+		return exitcode.Failure
+	}
+
+	if !result.Met() {
+		fmt.Fprintf(os.Stderr, "FAIL: %s\n", result.Summary())
+		return exitcode.Failure
+	}
+	fmt.Fprintf(os.Stderr, "PASS: %s\n", result.Summary())
+	return exitcode.OK
+}
+
+func handleWire(cfg *config.Config) int {
+	file, err := os.Open(cfg.WireFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Failure
+	}
+	defer file.Close()
+	reader, err := wire.NewReader(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", cfg.WireFile, err)
+		return exitcode.Failure
+	}
+	if err := wire.Dump(os.Stdout, reader, cfg.WireConn); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", cfg.WireFile, err)
+		return exitcode.Failure
+	}
+	return exitcode.OK
+}
+
+func handleAttach(cfg *config.Config) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	remote, err := control.Dial(ctx, cfg.AttachSocket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Failure
+	}
+	defer remote.Close()
+
+	// Quitting only closes this view; the attached portal keeps running.
+	tuiOptions := tui.Options{Refresh: cfg.TUIRefresh, LowPower: cfg.LowPower}
+	program := tea.NewProgram(tui.NewModel(remote, tuiOptions), append([]tea.ProgramOption{tea.WithAltScreen()}, tuiOptions.ProgramOptions()...)...)
+	lost := make(chan error, 1)
+	go func() {
+		program.Send(tui.LogMsg{Level: "INFO", Message: "Attached to portal at " + cfg.AttachSocket + "; q detaches", Time: time.Now()})
+		err := remote.Run(ctx, program.Send)
+		if err != nil {
+			program.Quit()
+		}
+		lost <- err
+	}()
+	_, err = program.Run()
+	cancel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Failure
+	}
+	if err := <-lost; err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitcode.Failure
+	}
+	return exitcode.OK
+}
+
+func setupTsnet(ctx context.Context, proxyServer *proxy.Server, logger *zap.Logger, cfg *config.Config, onReady func(readyInfo tailscale.TSNetReadyInfo), onFailure func(error)) func() error {
+	logger.Info("Setting up TSNet mode",
+		logging.Component("tsnet_setup"),
+		logging.TailscaleMode("tsnet"),
+		logging.NodeName(cfg.TailscaleName),
+		logging.FunnelEnabled(cfg.Funnel),
+		logging.HTTPSEnabled(cfg.UseHTTPS),
+		logging.ServePort(cfg.GetServePort()),
+		zap.String("tsnet_listen_mode_configured", cfg.TSNetListenMode),
+		zap.String("tsnet_listen_mode_effective", cfg.EffectiveTSNetListenMode()),
+		zap.String("tsnet_service_name", cfg.TSNetServiceName),
+	)
+
+	// Use tsnet mode
+	tsnetConfig := tailscale.TSNetConfig{
+		Hostname:     cfg.TailscaleName,
+		AuthKey:      cfg.AuthKey,
+		EnableFunnel: cfg.Funnel,
+		UseHTTPS:     cfg.UseHTTPS,
+		ServePort:    cfg.GetServePort(),
+		ListenMode:   cfg.TSNetListenMode,
+		ServiceName:  cfg.TSNetServiceName,
+		Verbose:      cfg.TSNetVerbose,
+
+		ConfigureHTTPServer: proxyServer.ConfigureHTTPServer,
+		WrapListener:        proxyServer.WrapListener,
+	}
+
+	// Pass the zap.Logger directly instead of creating a sugared logger
+	tsnetServer := tailscale.NewTSNetServer(tsnetConfig, logger)
+	tsnetServer.SetReadyCallback(func(info tailscale.TSNetReadyInfo) {
+		if cfg.UIPath != "" {
+			proxyServer.SetWebUIURL(info.ServiceURL + cfg.UIPath + "/")
+		}
+		onReady(info)
+		if !cfg.IsServiceMode() {
+			server.WarmCertificate(ctx, proxyServer, info.ServiceURL, tsnetServer.WarmCert)
+		}
+	})
+	proxyServer.SetWhoIs(tsnetServer.WhoIs)
+	if cfg.TargetHost != "" {
+		proxyServer.SetUpstreamDialer(tsnetServer.DialPeer)
+	}
+
+	go func() {
+		if err := tsnetServer.Serve(ctx, proxyServer); err != nil {
+			logger.Error(logging.MsgRuntimeError,
 				logging.Component("tsnet_server"),
 				logging.Error(err),
 			)
+			proxyServer.MarkEndpointFailure(err.Error())
+			if ctx.Err() == nil {
+				onFailure(server.TailscaleFailure(err))
+			}
 		}
 	}()
+	closeExposes := server.StartExposeNodes(ctx, proxyServer, logger, cfg)
 
 	return func() error {
 		logger.Info(logging.MsgCleanupStarting,
 			logging.Component("tsnet_server"),
 		)
 
-		err := tsnetServer.Close()
+		err := errors.Join(tsnetServer.Close(), closeExposes())
 		if err != nil {
 			logger.Error(logging.MsgRuntimeError,
 				logging.Component("tsnet_server"),
@@ -726,6 +1988,345 @@ func setupTsnet(ctx context.Context, proxyServer *proxy.Server, logger *zap.Logg
 	}
 }
 
+// startSupervisor starts the command given to `portal run`, or returns nil
+// for a plain run. The command gets PORT set to the target port unless the
+// environment already sets it or the port is to be detected.
+func startSupervisor(logger *zap.Logger, cfg *config.Config) *supervisor.Supervisor {
+	if cfg.Command != config.CommandRun {
+		return nil
+	}
+	var env []string
+	if _, ok := os.LookupEnv("PORT"); !ok && cfg.Port > 0 {
+		env = append(env, "PORT="+strconv.Itoa(cfg.Port))
+	}
+	child, err := supervisor.Start(supervisor.Options{
+		Command:    cfg.RunCommand,
+		Env:        env,
+		Restart:    cfg.Restart,
+		DetectPort: cfg.DetectPort,
+		Logger:     logger,
+	})
+	if err != nil {
+		fatal(logger, exitcode.Failure, logging.MsgSetupFailed,
+			logging.Component("supervisor"),
+			logging.Error(err),
+		)
+	}
+	return child
+}
+
+// commandOutput prints the supervised command's output on stderr, prefixed
+// with the program name, or logs it with --json. It returns nil when no
+// command is supervised.
+func commandOutput(logger *zap.Logger, cfg *config.Config) func(supervisor.Line) {
+	if len(cfg.RunCommand) == 0 {
+		return nil
+	}
+	if cfg.JSON {
+		return func(line supervisor.Line) {
+			logger.Info(line.Text, logging.Component("supervisor"), zap.String("stream", line.Stream))
+		}
+	}
+	prefix := filepath.Base(cfg.RunCommand[0]) + " | "
+	return func(line supervisor.Line) {
+		fmt.Fprintln(os.Stderr, prefix+line.Text)
+	}
+}
+
+// startCaptureWebhook forwards captured requests to --capture-webhook, or
+// returns nil when it isn't set.
+func startCaptureWebhook(logger *zap.Logger, proxyServer *proxy.Server, cfg *config.Config) *capture.Webhook {
+	if cfg.CaptureWebhook == "" {
+		return nil
+	}
+	source, _ := os.Hostname()
+	webhook := capture.NewWebhook(capture.WebhookConfig{
+		URL:       cfg.CaptureWebhook,
+		Secret:    cfg.CaptureWebhookSecret,
+		Source:    source,
+		BatchSize: cfg.CaptureWebhookBatch,
+		Interval:  cfg.CaptureWebhookInterval,
+	}, logger)
+	proxyServer.AddListener(webhook.Request)
+
+	logger.Info("Forwarding captured requests",
+		logging.Component("capture_webhook"),
+		logging.URL(cfg.CaptureWebhook),
+		zap.Bool("signed", cfg.CaptureWebhookSecret != ""),
+	)
+	return webhook
+}
+
+// startSpool keeps a copy of the request history on disk until portal exits
+// cleanly, or returns nil when the spool can't be created.
+func startSpool(logger *zap.Logger, proxyServer *proxy.Server, cfg *config.Config) *capture.Spool {
+	dir, err := capture.DefaultSpoolDir()
+	if err == nil {
+		var spool *capture.Spool
+		if spool, err = capture.NewSpool(dir, proxyServer.GetRequestLogs, 0, int64(cfg.SpoolMaxSize)*1024*1024, logger); err == nil {
+			proxyServer.AddListener(spool.Request)
+			return spool
+		}
+	}
+	logger.Warn("Request history won't survive a crash",
+		logging.Component("capture_spool"),
+		logging.Error(err),
+	)
+	return nil
+}
+
+// startControl serves the gRPC control API on socket until ctx is done. It
+// returns nil when the socket can't be created.
+func startControl(ctx context.Context, logger *zap.Logger, proxyServer *proxy.Server, socket string) *control.Server {
+	listener, err := control.Listen(socket)
+	if err != nil {
+		logger.Warn("gRPC control API not available",
+			logging.Component("control"),
+			zap.String("socket", socket),
+			logging.Error(err),
+		)
+		return nil
+	}
+	logger.Info("gRPC control API listening",
+		logging.Component("control"),
+		zap.String("socket", socket),
+	)
+	server := control.NewServer(proxyServer)
+	go func() {
+		if err := server.Serve(ctx, listener); err != nil {
+			logger.Error("gRPC control API stopped",
+				logging.Component("control"),
+				logging.Error(err),
+			)
+		}
+	}()
+	return server
+}
+
+// startSessionManifest records who connects during the session, for the
+// manifest written on exit.
+func startSessionManifest(cfg *config.Config, proxyServer *proxy.Server, startTime time.Time) *session.Recorder {
+	host, _ := os.Hostname()
+	manifest := session.Manifest{
+		ID:            session.NewID(startTime),
+		Host:          host,
+		User:          cmp.Or(os.Getenv("USER"), os.Getenv("USERNAME")),
+		PortalVersion: Version,
+		StartedAt:     startTime,
+		Access: session.Access{
+			AllowMethods: cfg.AllowMethods,
+			CORSOrigins:  cfg.CORSOrigins,
+			APITokens:    proxyServer.APITokens().Enabled(),
+			JWKSURL:      cfg.JWKSURL,
+			MaxRequests:  cfg.MaxRequests,
+		},
+	}
+	for _, prefix := range cfg.FunnelAllowlist {
+		manifest.Access.FunnelAllowlist = append(manifest.Access.FunnelAllowlist, prefix.String())
+	}
+	for _, quota := range cfg.Quotas {
+		manifest.Access.Quotas = append(manifest.Access.Quotas, quota.String())
+	}
+	if cfg.ExpiresIn > 0 {
+		manifest.Access.ExpiresIn = cfg.ExpiresIn.String()
+	}
+	manifest.Exposure.Funnel = cfg.Funnel || len(cfg.FunnelPaths) > 0
+	manifest.Exposure.FunnelPaths = cfg.FunnelPaths
+
+	recorder := session.NewRecorder(manifest)
+	proxyServer.AddListener(recorder.Request)
+	return recorder
+}
+
+// writeSessionManifest signs the session's manifest and stores it with the
+// manifests of earlier sessions.
+func writeSessionManifest(logger *zap.Logger, recorder *session.Recorder, proxyServer *proxy.Server, useLocalTailscale bool, code int) {
+	manifest := recorder.Manifest()
+	manifest.EndedAt = time.Now()
+	manifest.ExitCode = code
+
+	state := proxyServer.State()
+	manifest.Exposure.Mode = state.Mode
+	manifest.Exposure.Target = state.TargetURL
+	manifest.Exposure.URL = state.Endpoint.ServiceURL
+	manifest.Exposure.Mounts = state.Endpoint.Mounts
+	manifest.Exposure.Exposes = state.Endpoint.Exposes
+	manifest.Exposure.WebUIURL = state.Endpoint.WebUIURL
+	manifest.Exposure.Tailscale = "tsnet"
+	if useLocalTailscale {
+		manifest.Exposure.Tailscale = "local"
+	}
+
+	path, err := writeSignedManifest(manifest)
+	if err != nil {
+		logger.Warn("Failed to write the session manifest",
+			logging.Component("session_manifest"),
+			logging.Error(err),
+		)
+		return
+	}
+	logger.Info("Session manifest written",
+		logging.Component("session_manifest"),
+		zap.String("path", path),
+	)
+}
+
+func writeSignedManifest(manifest session.Manifest) (string, error) {
+	keyPath, err := session.DefaultKeyPath()
+	if err != nil {
+		return "", err
+	}
+	key, err := session.LoadKey(keyPath)
+	if err != nil {
+		return "", err
+	}
+	dir, err := session.DefaultDir()
+	if err != nil {
+		return "", err
+	}
+	return session.Write(dir, manifest, key)
+}
+
+// handleSessionsList prints the manifests of past sessions, flagging any
+// whose signature doesn't check out against this machine's key.
+func handleSessionsList() int {
+	dir, err := session.DefaultDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	var trusted ed25519.PublicKey
+	if keyPath, err := session.DefaultKeyPath(); err == nil {
+		if _, statErr := os.Stat(keyPath); statErr == nil {
+			key, err := session.LoadKey(keyPath)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			trusted = key.Public().(ed25519.PublicKey)
+		}
+	}
+	sessions, err := session.List(dir, trusted)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(sessions) == 0 {
+		fmt.Printf("No session manifests in %s.\n", dir)
+		return 0
+	}
+
+	for _, stored := range sessions {
+		manifest := stored.Manifest
+		exposure := "tailnet"
+		if manifest.Exposure.Funnel {
+			exposure = "funnel"
+		}
+		verified := "signed"
+		if stored.Err != nil {
+			verified = "UNVERIFIED: " + stored.Err.Error()
+		}
+		fmt.Printf("%s  %s  %-8s %-7s %4d requests  %3d identities  %s\n",
+			manifest.StartedAt.Local().Format("2006-01-02 15:04"),
+			manifest.EndedAt.Sub(manifest.StartedAt).Round(time.Second),
+			exposure,
+			manifest.Exposure.Mode,
+			manifest.Requests,
+			len(manifest.Identities),
+			cmp.Or(manifest.Exposure.URL, manifest.Exposure.Target),
+		)
+		fmt.Printf("  %s (%s)\n", stored.Path, verified)
+	}
+	return 0
+}
+
+// recoverSpooledSessions offers to import the history of earlier sessions
+// that didn't exit cleanly. Sessions that aren't imported are moved aside,
+// so the offer isn't repeated, and can still be loaded with portal import
+// until newer ones replace them.
+func recoverSpooledSessions(logger *zap.Logger, proxyServer *proxy.Server) {
+	dir, err := capture.DefaultSpoolDir()
+	if err != nil {
+		return
+	}
+	sessions, err := capture.FindSpooledSessions(dir)
+	if err != nil {
+		logger.Warn("Failed to look for crashed sessions",
+			logging.Component("capture_spool"),
+			logging.Error(err),
+		)
+		return
+	}
+
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	input := bufio.NewReader(os.Stdin)
+	for _, session := range sessions {
+		entries, err := capture.ReadSpool(session.Path)
+		if err != nil || len(entries) == 0 {
+			os.Remove(session.Path)
+			continue
+		}
+
+		if interactive {
+			fmt.Fprintf(os.Stderr, "A portal session that ended at %s didn't exit cleanly. Import its %d captured requests? [Y/n] ",
+				session.SavedAt.Format(time.DateTime), len(entries))
+			answer, _ := input.ReadString('\n')
+			if answer = strings.ToLower(strings.TrimSpace(answer)); answer == "" || answer == "y" || answer == "yes" {
+				imported := proxyServer.ImportRequestLogs(entries)
+				os.Remove(session.Path)
+				fmt.Fprintf(os.Stderr, "Imported %d requests from the previous session.\n", imported)
+				continue
+			}
+		}
+
+		saved, err := capture.SaveSpooledSession(dir, session)
+		if err != nil {
+			logger.Warn("Failed to set aside a crashed session",
+				logging.Component("capture_spool"),
+				zap.String("path", session.Path),
+				logging.Error(err),
+			)
+			continue
+		}
+		if interactive {
+			fmt.Fprintf(os.Stderr, "Kept them in %s; load them later with portal import.\n", saved)
+			continue
+		}
+		logger.Warn("Kept the requests of a session that didn't exit cleanly; load them with portal import",
+			logging.Component("capture_spool"),
+			zap.String("path", saved),
+			zap.Int("requests", len(entries)),
+		)
+	}
+}
+
+// startAlerts checks the configured alert rules against proxied requests,
+// or returns nil when there are none.
+func startAlerts(logger *zap.Logger, proxyServer *proxy.Server, cfg *config.Config, onChange func(alert.Event)) *alert.Monitor {
+	if len(cfg.Alerts) == 0 {
+		return nil
+	}
+	source, _ := os.Hostname()
+	monitor := alert.NewMonitor(cfg.Alerts, alert.Options{
+		Logger:     logger,
+		WebhookURL: cfg.AlertWebhook,
+		Source:     source,
+		OnChange:   onChange,
+	})
+	proxyServer.AddListener(monitor.Request)
+
+	rules := make([]string, 0, len(cfg.Alerts))
+	for _, rule := range cfg.Alerts {
+		rules = append(rules, rule.Expr)
+	}
+	logger.Info("Watching alert rules",
+		logging.Component("alert"),
+		zap.Strings("rules", rules),
+		zap.Bool("webhook", cfg.AlertWebhook != ""),
+	)
+	return monitor
+}
+
 func logStartupSummary(logger *zap.Logger, summary startup.Summary) {
 	if !summary.IsReady() {
 		return
@@ -751,6 +2352,12 @@ func initialEndpointState(cfg *config.Config, useLocalDaemon bool) model.Endpoin
 		exposure = startup.ExposureFunnel
 	}
 
+	if cfg.SSH != "" {
+		mode = startup.ModeSSH
+		exposure = startup.ExposureBastion
+		useLocalDaemon = true
+	}
+
 	return model.EndpointState{
 		Readiness:   model.EndpointReadinessStarting,
 		Mode:        mode,
@@ -762,19 +2369,110 @@ func initialEndpointState(cfg *config.Config, useLocalDaemon bool) model.Endpoin
 	}
 }
 
-func setupUIServer(ctx context.Context, tsClient *tailscale.Client, uiPort int, proxyServer *proxy.Server, logger *zap.Logger) (*model.UIServerInfo, error) {
+// setupSSHTunnel serves the proxy on a bastion through a reverse SSH tunnel.
+// The web UI has no tailnet to publish on, so it is served on loopback only.
+func setupSSHTunnel(ctx context.Context, proxyServer *proxy.Server, logger *zap.Logger, cfg *config.Config) (cleanup func() error, uiCleanup func() error, serviceURL string, err error) {
+	tunnel, err := sshtunnel.Open(ctx, sshtunnel.Config{
+		Target:       cfg.SSH,
+		RemotePort:   cfg.SSHRemotePort,
+		IdentityFile: cfg.SSHIdentity,
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	go func() {
+		if err := tunnel.Serve(proxyServer, proxyServer.ConfigureHTTPServer, proxyServer.WrapListener); err != nil {
+			logger.Error(logging.MsgRuntimeError,
+				logging.Component("ssh_tunnel"),
+				logging.Error(err),
+			)
+		}
+	}()
+	go func() {
+		_ = tunnel.Wait()
+		if ctx.Err() == nil {
+			logger.Error("SSH connection closed",
+				logging.Component("ssh_tunnel"),
+				zap.String("bastion", cfg.SSH),
+			)
+			proxyServer.MarkEndpointFailure("ssh connection closed")
+		}
+	}()
+
+	logger.Info("SSH tunnel established",
+		logging.Component("ssh_tunnel"),
+		zap.String("bastion", cfg.SSH),
+		zap.Int("remote_port", tunnel.RemotePort()),
+		logging.URL(tunnel.URL()),
+	)
+
+	if !cfg.NoUI {
+		uiPort := cfg.UIPort
+		if uiPort == 0 {
+			uiPort, err = tailscale.FindAvailableLocalPortFrom(tailscale.DefaultLocalUIPort)
+			if err != nil {
+				uiPort, err = tailscale.FindAvailableLocalPort()
+			}
+		}
+		if err != nil {
+			logger.Warn(logging.MsgPortAllocationFailed,
+				logging.Component("ui_server"),
+				logging.Status("disabling_ui"),
+				logging.Error(err),
+			)
+		} else {
+			uiServer := &http.Server{
+				Addr:    net.JoinHostPort("127.0.0.1", strconv.Itoa(uiPort)),
+				Handler: ui.NewServer(proxyServer, uiFiles),
+			}
+			go func() {
+				if err := uiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					logger.Error(logging.MsgRuntimeError,
+						logging.Component("ui_server"),
+						logging.UIPort(uiPort),
+						logging.Error(err),
+					)
+				}
+			}()
+			if err := httputil.WaitForServerReady(ctx, uiServer.Addr, 2*time.Second); err != nil {
+				logger.Warn(logging.MsgSetupFailed,
+					logging.Component("ui_server"),
+					logging.Status("continuing_without_ui"),
+					logging.Error(err),
+				)
+			} else {
+				uiURL := "http://" + uiServer.Addr
+				logger.Info(logging.MsgUIAvailable,
+					logging.UIPort(uiPort),
+					logging.URL(uiURL),
+				)
+				proxyServer.SetWebUIURL(uiURL)
+				uiCleanup = func() error {
+					shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					return uiServer.Shutdown(shutdownCtx)
+				}
+			}
+		}
+	}
+
+	return tunnel.Close, uiCleanup, tunnel.URL(), nil
+}
+
+func setupUIServer(ctx context.Context, tsClient *tailscale.Client, cfg *config.Config, uiPort int, proxyServer *proxy.Server, logger *zap.Logger) (*model.UIServerInfo, error) {
 	// Create UI server with the proxy server as the log provider
 	uiServer := ui.NewServer(proxyServer, uiFiles)
 
 	// Set up Tailscale serve for UI
-	tailscalePort, uiURL, err := tsClient.SetupUIServe(ctx, uiPort)
+	tailscalePort, uiURL, err := server.ServeUI(ctx, tsClient, cfg, uiPort)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup UI Tailscale serve: %w", err)
 	}
 
 	// Start UI server on local port
 	httpServer := &http.Server{
-		Addr:    fmt.Sprintf(":%d", uiPort),
+		Addr:    cfg.LocalAddr(uiPort),
 		Handler: uiServer,
 	}
 
@@ -789,7 +2487,7 @@ func setupUIServer(ctx context.Context, tsClient *tailscale.Client, uiPort int,
 	}()
 
 	// Wait for the UI server to be ready
-	if err := httputil.WaitForServerReady(ctx, fmt.Sprintf("localhost:%d", uiPort), 2*time.Second); err != nil {
+	if err := httputil.WaitForServerReady(ctx, net.JoinHostPort("127.0.0.1", strconv.Itoa(uiPort)), 2*time.Second); err != nil {
 		logger.Error("UI server failed to start",
 			logging.Component("ui_server"),
 			logging.UIPort(uiPort),
@@ -0,0 +1,1644 @@
+// Control API for a running portal, served over gRPC on the local socket set
+// with --grpc-socket. It mirrors the web UI's REST API under /api.
+//
+// Regenerate the Go code in pkg/controlpb after editing this file:
+//
+//	cd proto && buf generate
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: portal/control/v1/control.proto
+
+package controlpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetStateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStateRequest) Reset() {
+	*x = GetStateRequest{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStateRequest) ProtoMessage() {}
+
+func (x *GetStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStateRequest.ProtoReflect.Descriptor instead.
+func (*GetStateRequest) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{0}
+}
+
+type State struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Mode is "proxy" or "mock".
+	Mode string `protobuf:"bytes,1,opt,name=mode,proto3" json:"mode,omitempty"`
+	// TargetURL is where requests are forwarded; empty in mock mode.
+	TargetUrl     string                 `protobuf:"bytes,2,opt,name=target_url,json=targetUrl,proto3" json:"target_url,omitempty"`
+	Version       string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	StartedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	Endpoint      *Endpoint              `protobuf:"bytes,5,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	Capture       *CaptureState          `protobuf:"bytes,6,opt,name=capture,proto3" json:"capture,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *State) Reset() {
+	*x = State{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *State) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*State) ProtoMessage() {}
+
+func (x *State) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use State.ProtoReflect.Descriptor instead.
+func (*State) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *State) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *State) GetTargetUrl() string {
+	if x != nil {
+		return x.TargetUrl
+	}
+	return ""
+}
+
+func (x *State) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *State) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *State) GetEndpoint() *Endpoint {
+	if x != nil {
+		return x.Endpoint
+	}
+	return nil
+}
+
+func (x *State) GetCapture() *CaptureState {
+	if x != nil {
+		return x.Capture
+	}
+	return nil
+}
+
+type Endpoint struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Readiness is "starting", "ready" or "failed".
+	Readiness   string `protobuf:"bytes,1,opt,name=readiness,proto3" json:"readiness,omitempty"`
+	Mode        string `protobuf:"bytes,2,opt,name=mode,proto3" json:"mode,omitempty"`
+	Exposure    string `protobuf:"bytes,3,opt,name=exposure,proto3" json:"exposure,omitempty"`
+	ServiceUrl  string `protobuf:"bytes,4,opt,name=service_url,json=serviceUrl,proto3" json:"service_url,omitempty"`
+	WebUiStatus string `protobuf:"bytes,5,opt,name=web_ui_status,json=webUiStatus,proto3" json:"web_ui_status,omitempty"`
+	WebUiUrl    string `protobuf:"bytes,6,opt,name=web_ui_url,json=webUiUrl,proto3" json:"web_ui_url,omitempty"`
+	// Upstream is "waiting", "reachable" or "unreachable" when portal was
+	// started before its target.
+	Upstream      string `protobuf:"bytes,7,opt,name=upstream,proto3" json:"upstream,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Endpoint) Reset() {
+	*x = Endpoint{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Endpoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Endpoint) ProtoMessage() {}
+
+func (x *Endpoint) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Endpoint.ProtoReflect.Descriptor instead.
+func (*Endpoint) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Endpoint) GetReadiness() string {
+	if x != nil {
+		return x.Readiness
+	}
+	return ""
+}
+
+func (x *Endpoint) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *Endpoint) GetExposure() string {
+	if x != nil {
+		return x.Exposure
+	}
+	return ""
+}
+
+func (x *Endpoint) GetServiceUrl() string {
+	if x != nil {
+		return x.ServiceUrl
+	}
+	return ""
+}
+
+func (x *Endpoint) GetWebUiStatus() string {
+	if x != nil {
+		return x.WebUiStatus
+	}
+	return ""
+}
+
+func (x *Endpoint) GetWebUiUrl() string {
+	if x != nil {
+		return x.WebUiUrl
+	}
+	return ""
+}
+
+func (x *Endpoint) GetUpstream() string {
+	if x != nil {
+		return x.Upstream
+	}
+	return ""
+}
+
+type CaptureState struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Paused bool                   `protobuf:"varint,1,opt,name=paused,proto3" json:"paused,omitempty"`
+	// Skipped counts requests left out of the history since the pause began.
+	Skipped        int64 `protobuf:"varint,2,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	RequestBodies  bool  `protobuf:"varint,3,opt,name=request_bodies,json=requestBodies,proto3" json:"request_bodies,omitempty"`
+	ResponseBodies bool  `protobuf:"varint,4,opt,name=response_bodies,json=responseBodies,proto3" json:"response_bodies,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *CaptureState) Reset() {
+	*x = CaptureState{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CaptureState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CaptureState) ProtoMessage() {}
+
+func (x *CaptureState) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CaptureState.ProtoReflect.Descriptor instead.
+func (*CaptureState) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CaptureState) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *CaptureState) GetSkipped() int64 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *CaptureState) GetRequestBodies() bool {
+	if x != nil {
+		return x.RequestBodies
+	}
+	return false
+}
+
+func (x *CaptureState) GetResponseBodies() bool {
+	if x != nil {
+		return x.ResponseBodies
+	}
+	return false
+}
+
+type ListRequestsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Limit keeps the newest requests when positive. Searches return at most
+	// 100 matches without one.
+	Limit int32 `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	// Query searches captured bodies, with the syntax of the web UI's body:
+	// filter.
+	Query         string `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRequestsRequest) Reset() {
+	*x = ListRequestsRequest{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRequestsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequestsRequest) ProtoMessage() {}
+
+func (x *ListRequestsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequestsRequest.ProtoReflect.Descriptor instead.
+func (*ListRequestsRequest) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListRequestsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListRequestsRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+type ListRequestsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requests      []*Request             `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRequestsResponse) Reset() {
+	*x = ListRequestsResponse{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRequestsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequestsResponse) ProtoMessage() {}
+
+func (x *ListRequestsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequestsResponse.ProtoReflect.Descriptor instead.
+func (*ListRequestsResponse) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListRequestsResponse) GetRequests() []*Request {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+type GetRequestRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRequestRequest) Reset() {
+	*x = GetRequestRequest{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRequestRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRequestRequest) ProtoMessage() {}
+
+func (x *GetRequestRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRequestRequest.ProtoReflect.Descriptor instead.
+func (*GetRequestRequest) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetRequestRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ClearRequestsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClearRequestsRequest) Reset() {
+	*x = ClearRequestsRequest{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClearRequestsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClearRequestsRequest) ProtoMessage() {}
+
+func (x *ClearRequestsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClearRequestsRequest.ProtoReflect.Descriptor instead.
+func (*ClearRequestsRequest) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{7}
+}
+
+type ClearRequestsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClearRequestsResponse) Reset() {
+	*x = ClearRequestsResponse{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClearRequestsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClearRequestsResponse) ProtoMessage() {}
+
+func (x *ClearRequestsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClearRequestsResponse.ProtoReflect.Descriptor instead.
+func (*ClearRequestsResponse) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{8}
+}
+
+type ReplayRequestRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Method, URL, headers and body replace those of the captured request when
+	// set. URL must be a path.
+	Method        string    `protobuf:"bytes,2,opt,name=method,proto3" json:"method,omitempty"`
+	Url           string    `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+	Headers       []*Header `protobuf:"bytes,4,rep,name=headers,proto3" json:"headers,omitempty"`
+	Body          *string   `protobuf:"bytes,5,opt,name=body,proto3,oneof" json:"body,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReplayRequestRequest) Reset() {
+	*x = ReplayRequestRequest{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReplayRequestRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplayRequestRequest) ProtoMessage() {}
+
+func (x *ReplayRequestRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplayRequestRequest.ProtoReflect.Descriptor instead.
+func (*ReplayRequestRequest) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ReplayRequestRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ReplayRequestRequest) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *ReplayRequestRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *ReplayRequestRequest) GetHeaders() []*Header {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *ReplayRequestRequest) GetBody() string {
+	if x != nil && x.Body != nil {
+		return *x.Body
+	}
+	return ""
+}
+
+type GetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{10}
+}
+
+type ResetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResetStatsRequest) Reset() {
+	*x = ResetStatsRequest{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetStatsRequest) ProtoMessage() {}
+
+func (x *ResetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetStatsRequest.ProtoReflect.Descriptor instead.
+func (*ResetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{11}
+}
+
+type Stats struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	StartedAt        *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	TakenAt          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=taken_at,json=takenAt,proto3" json:"taken_at,omitempty"`
+	TotalConnections int64                  `protobuf:"varint,3,opt,name=total_connections,json=totalConnections,proto3" json:"total_connections,omitempty"`
+	OpenConnections  int64                  `protobuf:"varint,4,opt,name=open_connections,json=openConnections,proto3" json:"open_connections,omitempty"`
+	// Response times are in milliseconds.
+	AvgResponseTime_1M float64          `protobuf:"fixed64,5,opt,name=avg_response_time_1m,json=avgResponseTime1m,proto3" json:"avg_response_time_1m,omitempty"`
+	AvgResponseTime_5M float64          `protobuf:"fixed64,6,opt,name=avg_response_time_5m,json=avgResponseTime5m,proto3" json:"avg_response_time_5m,omitempty"`
+	P50ResponseTime    float64          `protobuf:"fixed64,7,opt,name=p50_response_time,json=p50ResponseTime,proto3" json:"p50_response_time,omitempty"`
+	P90ResponseTime    float64          `protobuf:"fixed64,8,opt,name=p90_response_time,json=p90ResponseTime,proto3" json:"p90_response_time,omitempty"`
+	LimitViolations    map[string]int64 `protobuf:"bytes,9,rep,name=limit_violations,json=limitViolations,proto3" json:"limit_violations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	UpstreamFailures   map[string]int64 `protobuf:"bytes,10,rep,name=upstream_failures,json=upstreamFailures,proto3" json:"upstream_failures,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	UnmatchedRoutes    map[string]int64 `protobuf:"bytes,11,rep,name=unmatched_routes,json=unmatchedRoutes,proto3" json:"unmatched_routes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Stats) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *Stats) GetTakenAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.TakenAt
+	}
+	return nil
+}
+
+func (x *Stats) GetTotalConnections() int64 {
+	if x != nil {
+		return x.TotalConnections
+	}
+	return 0
+}
+
+func (x *Stats) GetOpenConnections() int64 {
+	if x != nil {
+		return x.OpenConnections
+	}
+	return 0
+}
+
+func (x *Stats) GetAvgResponseTime_1M() float64 {
+	if x != nil {
+		return x.AvgResponseTime_1M
+	}
+	return 0
+}
+
+func (x *Stats) GetAvgResponseTime_5M() float64 {
+	if x != nil {
+		return x.AvgResponseTime_5M
+	}
+	return 0
+}
+
+func (x *Stats) GetP50ResponseTime() float64 {
+	if x != nil {
+		return x.P50ResponseTime
+	}
+	return 0
+}
+
+func (x *Stats) GetP90ResponseTime() float64 {
+	if x != nil {
+		return x.P90ResponseTime
+	}
+	return 0
+}
+
+func (x *Stats) GetLimitViolations() map[string]int64 {
+	if x != nil {
+		return x.LimitViolations
+	}
+	return nil
+}
+
+func (x *Stats) GetUpstreamFailures() map[string]int64 {
+	if x != nil {
+		return x.UpstreamFailures
+	}
+	return nil
+}
+
+func (x *Stats) GetUnmatchedRoutes() map[string]int64 {
+	if x != nil {
+		return x.UnmatchedRoutes
+	}
+	return nil
+}
+
+type SetCaptureRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Paused         *bool                  `protobuf:"varint,1,opt,name=paused,proto3,oneof" json:"paused,omitempty"`
+	RequestBodies  *bool                  `protobuf:"varint,2,opt,name=request_bodies,json=requestBodies,proto3,oneof" json:"request_bodies,omitempty"`
+	ResponseBodies *bool                  `protobuf:"varint,3,opt,name=response_bodies,json=responseBodies,proto3,oneof" json:"response_bodies,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SetCaptureRequest) Reset() {
+	*x = SetCaptureRequest{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetCaptureRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetCaptureRequest) ProtoMessage() {}
+
+func (x *SetCaptureRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetCaptureRequest.ProtoReflect.Descriptor instead.
+func (*SetCaptureRequest) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SetCaptureRequest) GetPaused() bool {
+	if x != nil && x.Paused != nil {
+		return *x.Paused
+	}
+	return false
+}
+
+func (x *SetCaptureRequest) GetRequestBodies() bool {
+	if x != nil && x.RequestBodies != nil {
+		return *x.RequestBodies
+	}
+	return false
+}
+
+func (x *SetCaptureRequest) GetResponseBodies() bool {
+	if x != nil && x.ResponseBodies != nil {
+		return *x.ResponseBodies
+	}
+	return false
+}
+
+type WatchRequestsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequestsRequest) Reset() {
+	*x = WatchRequestsRequest{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequestsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequestsRequest) ProtoMessage() {}
+
+func (x *WatchRequestsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequestsRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequestsRequest) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{14}
+}
+
+type WatchLogsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchLogsRequest) Reset() {
+	*x = WatchLogsRequest{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchLogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchLogsRequest) ProtoMessage() {}
+
+func (x *WatchLogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchLogsRequest.ProtoReflect.Descriptor instead.
+func (*WatchLogsRequest) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{15}
+}
+
+type LogEntry struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Level is DEBUG, INFO, WARN or ERROR.
+	Level   string                 `protobuf:"bytes,1,opt,name=level,proto3" json:"level,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Time    *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	Fields  []*LogField            `protobuf:"bytes,4,rep,name=fields,proto3" json:"fields,omitempty"`
+	// Stack is the stack trace logged with an error, if any.
+	Stack         string `protobuf:"bytes,5,opt,name=stack,proto3" json:"stack,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogEntry) Reset() {
+	*x = LogEntry{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogEntry) ProtoMessage() {}
+
+func (x *LogEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogEntry.ProtoReflect.Descriptor instead.
+func (*LogEntry) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *LogEntry) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+func (x *LogEntry) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *LogEntry) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *LogEntry) GetFields() []*LogField {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *LogEntry) GetStack() string {
+	if x != nil {
+		return x.Stack
+	}
+	return ""
+}
+
+type LogField struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogField) Reset() {
+	*x = LogField{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogField) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogField) ProtoMessage() {}
+
+func (x *LogField) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogField.ProtoReflect.Descriptor instead.
+func (*LogField) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *LogField) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *LogField) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+// Header is one header field. A repeated header is an entry per value, in
+// the order they arrived, so values containing commas stay intact. It has
+// the wire format of a map<string, string> entry, which headers were before.
+type Header struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Header) Reset() {
+	*x = Header{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Header) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Header) ProtoMessage() {}
+
+func (x *Header) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Header.ProtoReflect.Descriptor instead.
+func (*Header) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Header) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Header) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+// Request is a captured request and the response it got.
+type Request struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Id         string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Timestamp  *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Method     string                 `protobuf:"bytes,3,opt,name=method,proto3" json:"method,omitempty"`
+	Url        string                 `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
+	RemoteAddr string                 `protobuf:"bytes,5,opt,name=remote_addr,json=remoteAddr,proto3" json:"remote_addr,omitempty"`
+	Headers    []*Header              `protobuf:"bytes,6,rep,name=headers,proto3" json:"headers,omitempty"`
+	// Body is the captured body, or a prefix of it when body_truncated is set.
+	// Binary bodies are only in body_bytes.
+	Body          string               `protobuf:"bytes,7,opt,name=body,proto3" json:"body,omitempty"`
+	BodyBytes     []byte               `protobuf:"bytes,8,opt,name=body_bytes,json=bodyBytes,proto3" json:"body_bytes,omitempty"`
+	BodyTruncated bool                 `protobuf:"varint,9,opt,name=body_truncated,json=bodyTruncated,proto3" json:"body_truncated,omitempty"`
+	BodyOmitted   bool                 `protobuf:"varint,10,opt,name=body_omitted,json=bodyOmitted,proto3" json:"body_omitted,omitempty"`
+	UserAgent     string               `protobuf:"bytes,11,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	ContentType   string               `protobuf:"bytes,12,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Size          int64                `protobuf:"varint,13,opt,name=size,proto3" json:"size,omitempty"`
+	Duration      *durationpb.Duration `protobuf:"bytes,14,opt,name=duration,proto3" json:"duration,omitempty"`
+	Response      *Response            `protobuf:"bytes,15,opt,name=response,proto3" json:"response,omitempty"`
+	// ReplayOf and DuplicateOf link to the request this one repeats.
+	ReplayOf      string `protobuf:"bytes,16,opt,name=replay_of,json=replayOf,proto3" json:"replay_of,omitempty"`
+	DuplicateOf   string `protobuf:"bytes,17,opt,name=duplicate_of,json=duplicateOf,proto3" json:"duplicate_of,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Request) Reset() {
+	*x = Request{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Request) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Request) ProtoMessage() {}
+
+func (x *Request) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Request.ProtoReflect.Descriptor instead.
+func (*Request) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *Request) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Request) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Request) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *Request) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Request) GetRemoteAddr() string {
+	if x != nil {
+		return x.RemoteAddr
+	}
+	return ""
+}
+
+func (x *Request) GetHeaders() []*Header {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *Request) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Request) GetBodyBytes() []byte {
+	if x != nil {
+		return x.BodyBytes
+	}
+	return nil
+}
+
+func (x *Request) GetBodyTruncated() bool {
+	if x != nil {
+		return x.BodyTruncated
+	}
+	return false
+}
+
+func (x *Request) GetBodyOmitted() bool {
+	if x != nil {
+		return x.BodyOmitted
+	}
+	return false
+}
+
+func (x *Request) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+func (x *Request) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Request) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Request) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *Request) GetResponse() *Response {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *Request) GetReplayOf() string {
+	if x != nil {
+		return x.ReplayOf
+	}
+	return ""
+}
+
+func (x *Request) GetDuplicateOf() string {
+	if x != nil {
+		return x.DuplicateOf
+	}
+	return ""
+}
+
+type Response struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StatusCode    int32                  `protobuf:"varint,1,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	Headers       []*Header              `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty"`
+	Body          string                 `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	BodyBytes     []byte                 `protobuf:"bytes,4,opt,name=body_bytes,json=bodyBytes,proto3" json:"body_bytes,omitempty"`
+	BodyTruncated bool                   `protobuf:"varint,5,opt,name=body_truncated,json=bodyTruncated,proto3" json:"body_truncated,omitempty"`
+	BodyOmitted   bool                   `protobuf:"varint,6,opt,name=body_omitted,json=bodyOmitted,proto3" json:"body_omitted,omitempty"`
+	Size          int64                  `protobuf:"varint,7,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Response) Reset() {
+	*x = Response{}
+	mi := &file_portal_control_v1_control_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Response) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Response) ProtoMessage() {}
+
+func (x *Response) ProtoReflect() protoreflect.Message {
+	mi := &file_portal_control_v1_control_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Response.ProtoReflect.Descriptor instead.
+func (*Response) Descriptor() ([]byte, []int) {
+	return file_portal_control_v1_control_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *Response) GetStatusCode() int32 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+func (x *Response) GetHeaders() []*Header {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *Response) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Response) GetBodyBytes() []byte {
+	if x != nil {
+		return x.BodyBytes
+	}
+	return nil
+}
+
+func (x *Response) GetBodyTruncated() bool {
+	if x != nil {
+		return x.BodyTruncated
+	}
+	return false
+}
+
+func (x *Response) GetBodyOmitted() bool {
+	if x != nil {
+		return x.BodyOmitted
+	}
+	return false
+}
+
+func (x *Response) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+var File_portal_control_v1_control_proto protoreflect.FileDescriptor
+
+const file_portal_control_v1_control_proto_rawDesc = "" +
+	"\n" +
+	"\x1fportal/control/v1/control.proto\x12\x11portal.control.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x11\n" +
+	"\x0fGetStateRequest\"\x83\x02\n" +
+	"\x05State\x12\x12\n" +
+	"\x04mode\x18\x01 \x01(\tR\x04mode\x12\x1d\n" +
+	"\n" +
+	"target_url\x18\x02 \x01(\tR\ttargetUrl\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x129\n" +
+	"\n" +
+	"started_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x127\n" +
+	"\bendpoint\x18\x05 \x01(\v2\x1b.portal.control.v1.EndpointR\bendpoint\x129\n" +
+	"\acapture\x18\x06 \x01(\v2\x1f.portal.control.v1.CaptureStateR\acapture\"\xd7\x01\n" +
+	"\bEndpoint\x12\x1c\n" +
+	"\treadiness\x18\x01 \x01(\tR\treadiness\x12\x12\n" +
+	"\x04mode\x18\x02 \x01(\tR\x04mode\x12\x1a\n" +
+	"\bexposure\x18\x03 \x01(\tR\bexposure\x12\x1f\n" +
+	"\vservice_url\x18\x04 \x01(\tR\n" +
+	"serviceUrl\x12\"\n" +
+	"\rweb_ui_status\x18\x05 \x01(\tR\vwebUiStatus\x12\x1c\n" +
+	"\n" +
+	"web_ui_url\x18\x06 \x01(\tR\bwebUiUrl\x12\x1a\n" +
+	"\bupstream\x18\a \x01(\tR\bupstream\"\x90\x01\n" +
+	"\fCaptureState\x12\x16\n" +
+	"\x06paused\x18\x01 \x01(\bR\x06paused\x12\x18\n" +
+	"\askipped\x18\x02 \x01(\x03R\askipped\x12%\n" +
+	"\x0erequest_bodies\x18\x03 \x01(\bR\rrequestBodies\x12'\n" +
+	"\x0fresponse_bodies\x18\x04 \x01(\bR\x0eresponseBodies\"A\n" +
+	"\x13ListRequestsRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\x12\x14\n" +
+	"\x05query\x18\x02 \x01(\tR\x05query\"N\n" +
+	"\x14ListRequestsResponse\x126\n" +
+	"\brequests\x18\x01 \x03(\v2\x1a.portal.control.v1.RequestR\brequests\"#\n" +
+	"\x11GetRequestRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x16\n" +
+	"\x14ClearRequestsRequest\"\x17\n" +
+	"\x15ClearRequestsResponse\"\xa7\x01\n" +
+	"\x14ReplayRequestRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06method\x18\x02 \x01(\tR\x06method\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\x123\n" +
+	"\aheaders\x18\x04 \x03(\v2\x19.portal.control.v1.HeaderR\aheaders\x12\x17\n" +
+	"\x04body\x18\x05 \x01(\tH\x00R\x04body\x88\x01\x01B\a\n" +
+	"\x05_body\"\x11\n" +
+	"\x0fGetStatsRequest\"\x13\n" +
+	"\x11ResetStatsRequest\"\xe9\x06\n" +
+	"\x05Stats\x129\n" +
+	"\n" +
+	"started_at\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x125\n" +
+	"\btaken_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\atakenAt\x12+\n" +
+	"\x11total_connections\x18\x03 \x01(\x03R\x10totalConnections\x12)\n" +
+	"\x10open_connections\x18\x04 \x01(\x03R\x0fopenConnections\x12/\n" +
+	"\x14avg_response_time_1m\x18\x05 \x01(\x01R\x11avgResponseTime1m\x12/\n" +
+	"\x14avg_response_time_5m\x18\x06 \x01(\x01R\x11avgResponseTime5m\x12*\n" +
+	"\x11p50_response_time\x18\a \x01(\x01R\x0fp50ResponseTime\x12*\n" +
+	"\x11p90_response_time\x18\b \x01(\x01R\x0fp90ResponseTime\x12X\n" +
+	"\x10limit_violations\x18\t \x03(\v2-.portal.control.v1.Stats.LimitViolationsEntryR\x0flimitViolations\x12[\n" +
+	"\x11upstream_failures\x18\n" +
+	" \x03(\v2..portal.control.v1.Stats.UpstreamFailuresEntryR\x10upstreamFailures\x12X\n" +
+	"\x10unmatched_routes\x18\v \x03(\v2-.portal.control.v1.Stats.UnmatchedRoutesEntryR\x0funmatchedRoutes\x1aB\n" +
+	"\x14LimitViolationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1aC\n" +
+	"\x15UpstreamFailuresEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1aB\n" +
+	"\x14UnmatchedRoutesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\xbc\x01\n" +
+	"\x11SetCaptureRequest\x12\x1b\n" +
+	"\x06paused\x18\x01 \x01(\bH\x00R\x06paused\x88\x01\x01\x12*\n" +
+	"\x0erequest_bodies\x18\x02 \x01(\bH\x01R\rrequestBodies\x88\x01\x01\x12,\n" +
+	"\x0fresponse_bodies\x18\x03 \x01(\bH\x02R\x0eresponseBodies\x88\x01\x01B\t\n" +
+	"\a_pausedB\x11\n" +
+	"\x0f_request_bodiesB\x12\n" +
+	"\x10_response_bodies\"\x16\n" +
+	"\x14WatchRequestsRequest\"\x12\n" +
+	"\x10WatchLogsRequest\"\xb5\x01\n" +
+	"\bLogEntry\x12\x14\n" +
+	"\x05level\x18\x01 \x01(\tR\x05level\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12.\n" +
+	"\x04time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x123\n" +
+	"\x06fields\x18\x04 \x03(\v2\x1b.portal.control.v1.LogFieldR\x06fields\x12\x14\n" +
+	"\x05stack\x18\x05 \x01(\tR\x05stack\"2\n" +
+	"\bLogField\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\"2\n" +
+	"\x06Header\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\"\xd6\x04\n" +
+	"\aRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x128\n" +
+	"\ttimestamp\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x16\n" +
+	"\x06method\x18\x03 \x01(\tR\x06method\x12\x10\n" +
+	"\x03url\x18\x04 \x01(\tR\x03url\x12\x1f\n" +
+	"\vremote_addr\x18\x05 \x01(\tR\n" +
+	"remoteAddr\x123\n" +
+	"\aheaders\x18\x06 \x03(\v2\x19.portal.control.v1.HeaderR\aheaders\x12\x12\n" +
+	"\x04body\x18\a \x01(\tR\x04body\x12\x1d\n" +
+	"\n" +
+	"body_bytes\x18\b \x01(\fR\tbodyBytes\x12%\n" +
+	"\x0ebody_truncated\x18\t \x01(\bR\rbodyTruncated\x12!\n" +
+	"\fbody_omitted\x18\n" +
+	" \x01(\bR\vbodyOmitted\x12\x1d\n" +
+	"\n" +
+	"user_agent\x18\v \x01(\tR\tuserAgent\x12!\n" +
+	"\fcontent_type\x18\f \x01(\tR\vcontentType\x12\x12\n" +
+	"\x04size\x18\r \x01(\x03R\x04size\x125\n" +
+	"\bduration\x18\x0e \x01(\v2\x19.google.protobuf.DurationR\bduration\x127\n" +
+	"\bresponse\x18\x0f \x01(\v2\x1b.portal.control.v1.ResponseR\bresponse\x12\x1b\n" +
+	"\treplay_of\x18\x10 \x01(\tR\breplayOf\x12!\n" +
+	"\fduplicate_of\x18\x11 \x01(\tR\vduplicateOf\"\xf1\x01\n" +
+	"\bResponse\x12\x1f\n" +
+	"\vstatus_code\x18\x01 \x01(\x05R\n" +
+	"statusCode\x123\n" +
+	"\aheaders\x18\x02 \x03(\v2\x19.portal.control.v1.HeaderR\aheaders\x12\x12\n" +
+	"\x04body\x18\x03 \x01(\tR\x04body\x12\x1d\n" +
+	"\n" +
+	"body_bytes\x18\x04 \x01(\fR\tbodyBytes\x12%\n" +
+	"\x0ebody_truncated\x18\x05 \x01(\bR\rbodyTruncated\x12!\n" +
+	"\fbody_omitted\x18\x06 \x01(\bR\vbodyOmitted\x12\x12\n" +
+	"\x04size\x18\a \x01(\x03R\x04size2\xd4\x06\n" +
+	"\aControl\x12H\n" +
+	"\bGetState\x12\".portal.control.v1.GetStateRequest\x1a\x18.portal.control.v1.State\x12_\n" +
+	"\fListRequests\x12&.portal.control.v1.ListRequestsRequest\x1a'.portal.control.v1.ListRequestsResponse\x12N\n" +
+	"\n" +
+	"GetRequest\x12$.portal.control.v1.GetRequestRequest\x1a\x1a.portal.control.v1.Request\x12b\n" +
+	"\rClearRequests\x12'.portal.control.v1.ClearRequestsRequest\x1a(.portal.control.v1.ClearRequestsResponse\x12T\n" +
+	"\rReplayRequest\x12'.portal.control.v1.ReplayRequestRequest\x1a\x1a.portal.control.v1.Request\x12H\n" +
+	"\bGetStats\x12\".portal.control.v1.GetStatsRequest\x1a\x18.portal.control.v1.Stats\x12L\n" +
+	"\n" +
+	"ResetStats\x12$.portal.control.v1.ResetStatsRequest\x1a\x18.portal.control.v1.Stats\x12S\n" +
+	"\n" +
+	"SetCapture\x12$.portal.control.v1.SetCaptureRequest\x1a\x1f.portal.control.v1.CaptureState\x12V\n" +
+	"\rWatchRequests\x12'.portal.control.v1.WatchRequestsRequest\x1a\x1a.portal.control.v1.Request0\x01\x12O\n" +
+	"\tWatchLogs\x12#.portal.control.v1.WatchLogsRequest\x1a\x1b.portal.control.v1.LogEntry0\x01B5Z3github.com/jaxxstorm/portal/pkg/controlpb;controlpbb\x06proto3"
+
+var (
+	file_portal_control_v1_control_proto_rawDescOnce sync.Once
+	file_portal_control_v1_control_proto_rawDescData []byte
+)
+
+func file_portal_control_v1_control_proto_rawDescGZIP() []byte {
+	file_portal_control_v1_control_proto_rawDescOnce.Do(func() {
+		file_portal_control_v1_control_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_portal_control_v1_control_proto_rawDesc), len(file_portal_control_v1_control_proto_rawDesc)))
+	})
+	return file_portal_control_v1_control_proto_rawDescData
+}
+
+var file_portal_control_v1_control_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
+var file_portal_control_v1_control_proto_goTypes = []any{
+	(*GetStateRequest)(nil),       // 0: portal.control.v1.GetStateRequest
+	(*State)(nil),                 // 1: portal.control.v1.State
+	(*Endpoint)(nil),              // 2: portal.control.v1.Endpoint
+	(*CaptureState)(nil),          // 3: portal.control.v1.CaptureState
+	(*ListRequestsRequest)(nil),   // 4: portal.control.v1.ListRequestsRequest
+	(*ListRequestsResponse)(nil),  // 5: portal.control.v1.ListRequestsResponse
+	(*GetRequestRequest)(nil),     // 6: portal.control.v1.GetRequestRequest
+	(*ClearRequestsRequest)(nil),  // 7: portal.control.v1.ClearRequestsRequest
+	(*ClearRequestsResponse)(nil), // 8: portal.control.v1.ClearRequestsResponse
+	(*ReplayRequestRequest)(nil),  // 9: portal.control.v1.ReplayRequestRequest
+	(*GetStatsRequest)(nil),       // 10: portal.control.v1.GetStatsRequest
+	(*ResetStatsRequest)(nil),     // 11: portal.control.v1.ResetStatsRequest
+	(*Stats)(nil),                 // 12: portal.control.v1.Stats
+	(*SetCaptureRequest)(nil),     // 13: portal.control.v1.SetCaptureRequest
+	(*WatchRequestsRequest)(nil),  // 14: portal.control.v1.WatchRequestsRequest
+	(*WatchLogsRequest)(nil),      // 15: portal.control.v1.WatchLogsRequest
+	(*LogEntry)(nil),              // 16: portal.control.v1.LogEntry
+	(*LogField)(nil),              // 17: portal.control.v1.LogField
+	(*Header)(nil),                // 18: portal.control.v1.Header
+	(*Request)(nil),               // 19: portal.control.v1.Request
+	(*Response)(nil),              // 20: portal.control.v1.Response
+	nil,                           // 21: portal.control.v1.Stats.LimitViolationsEntry
+	nil,                           // 22: portal.control.v1.Stats.UpstreamFailuresEntry
+	nil,                           // 23: portal.control.v1.Stats.UnmatchedRoutesEntry
+	(*timestamppb.Timestamp)(nil), // 24: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),   // 25: google.protobuf.Duration
+}
+var file_portal_control_v1_control_proto_depIdxs = []int32{
+	24, // 0: portal.control.v1.State.started_at:type_name -> google.protobuf.Timestamp
+	2,  // 1: portal.control.v1.State.endpoint:type_name -> portal.control.v1.Endpoint
+	3,  // 2: portal.control.v1.State.capture:type_name -> portal.control.v1.CaptureState
+	19, // 3: portal.control.v1.ListRequestsResponse.requests:type_name -> portal.control.v1.Request
+	18, // 4: portal.control.v1.ReplayRequestRequest.headers:type_name -> portal.control.v1.Header
+	24, // 5: portal.control.v1.Stats.started_at:type_name -> google.protobuf.Timestamp
+	24, // 6: portal.control.v1.Stats.taken_at:type_name -> google.protobuf.Timestamp
+	21, // 7: portal.control.v1.Stats.limit_violations:type_name -> portal.control.v1.Stats.LimitViolationsEntry
+	22, // 8: portal.control.v1.Stats.upstream_failures:type_name -> portal.control.v1.Stats.UpstreamFailuresEntry
+	23, // 9: portal.control.v1.Stats.unmatched_routes:type_name -> portal.control.v1.Stats.UnmatchedRoutesEntry
+	24, // 10: portal.control.v1.LogEntry.time:type_name -> google.protobuf.Timestamp
+	17, // 11: portal.control.v1.LogEntry.fields:type_name -> portal.control.v1.LogField
+	24, // 12: portal.control.v1.Request.timestamp:type_name -> google.protobuf.Timestamp
+	18, // 13: portal.control.v1.Request.headers:type_name -> portal.control.v1.Header
+	25, // 14: portal.control.v1.Request.duration:type_name -> google.protobuf.Duration
+	20, // 15: portal.control.v1.Request.response:type_name -> portal.control.v1.Response
+	18, // 16: portal.control.v1.Response.headers:type_name -> portal.control.v1.Header
+	0,  // 17: portal.control.v1.Control.GetState:input_type -> portal.control.v1.GetStateRequest
+	4,  // 18: portal.control.v1.Control.ListRequests:input_type -> portal.control.v1.ListRequestsRequest
+	6,  // 19: portal.control.v1.Control.GetRequest:input_type -> portal.control.v1.GetRequestRequest
+	7,  // 20: portal.control.v1.Control.ClearRequests:input_type -> portal.control.v1.ClearRequestsRequest
+	9,  // 21: portal.control.v1.Control.ReplayRequest:input_type -> portal.control.v1.ReplayRequestRequest
+	10, // 22: portal.control.v1.Control.GetStats:input_type -> portal.control.v1.GetStatsRequest
+	11, // 23: portal.control.v1.Control.ResetStats:input_type -> portal.control.v1.ResetStatsRequest
+	13, // 24: portal.control.v1.Control.SetCapture:input_type -> portal.control.v1.SetCaptureRequest
+	14, // 25: portal.control.v1.Control.WatchRequests:input_type -> portal.control.v1.WatchRequestsRequest
+	15, // 26: portal.control.v1.Control.WatchLogs:input_type -> portal.control.v1.WatchLogsRequest
+	1,  // 27: portal.control.v1.Control.GetState:output_type -> portal.control.v1.State
+	5,  // 28: portal.control.v1.Control.ListRequests:output_type -> portal.control.v1.ListRequestsResponse
+	19, // 29: portal.control.v1.Control.GetRequest:output_type -> portal.control.v1.Request
+	8,  // 30: portal.control.v1.Control.ClearRequests:output_type -> portal.control.v1.ClearRequestsResponse
+	19, // 31: portal.control.v1.Control.ReplayRequest:output_type -> portal.control.v1.Request
+	12, // 32: portal.control.v1.Control.GetStats:output_type -> portal.control.v1.Stats
+	12, // 33: portal.control.v1.Control.ResetStats:output_type -> portal.control.v1.Stats
+	3,  // 34: portal.control.v1.Control.SetCapture:output_type -> portal.control.v1.CaptureState
+	19, // 35: portal.control.v1.Control.WatchRequests:output_type -> portal.control.v1.Request
+	16, // 36: portal.control.v1.Control.WatchLogs:output_type -> portal.control.v1.LogEntry
+	27, // [27:37] is the sub-list for method output_type
+	17, // [17:27] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
+}
+
+func init() { file_portal_control_v1_control_proto_init() }
+func file_portal_control_v1_control_proto_init() {
+	if File_portal_control_v1_control_proto != nil {
+		return
+	}
+	file_portal_control_v1_control_proto_msgTypes[9].OneofWrappers = []any{}
+	file_portal_control_v1_control_proto_msgTypes[13].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_portal_control_v1_control_proto_rawDesc), len(file_portal_control_v1_control_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   24,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_portal_control_v1_control_proto_goTypes,
+		DependencyIndexes: file_portal_control_v1_control_proto_depIdxs,
+		MessageInfos:      file_portal_control_v1_control_proto_msgTypes,
+	}.Build()
+	File_portal_control_v1_control_proto = out.File
+	file_portal_control_v1_control_proto_goTypes = nil
+	file_portal_control_v1_control_proto_depIdxs = nil
+}
@@ -0,0 +1,519 @@
+// Control API for a running portal, served over gRPC on the local socket set
+// with --grpc-socket. It mirrors the web UI's REST API under /api.
+//
+// Regenerate the Go code in pkg/controlpb after editing this file:
+//
+//	cd proto && buf generate
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: portal/control/v1/control.proto
+
+package controlpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Control_GetState_FullMethodName      = "/portal.control.v1.Control/GetState"
+	Control_ListRequests_FullMethodName  = "/portal.control.v1.Control/ListRequests"
+	Control_GetRequest_FullMethodName    = "/portal.control.v1.Control/GetRequest"
+	Control_ClearRequests_FullMethodName = "/portal.control.v1.Control/ClearRequests"
+	Control_ReplayRequest_FullMethodName = "/portal.control.v1.Control/ReplayRequest"
+	Control_GetStats_FullMethodName      = "/portal.control.v1.Control/GetStats"
+	Control_ResetStats_FullMethodName    = "/portal.control.v1.Control/ResetStats"
+	Control_SetCapture_FullMethodName    = "/portal.control.v1.Control/SetCapture"
+	Control_WatchRequests_FullMethodName = "/portal.control.v1.Control/WatchRequests"
+	Control_WatchLogs_FullMethodName     = "/portal.control.v1.Control/WatchLogs"
+)
+
+// ControlClient is the client API for Control service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Control manages a running portal.
+type ControlClient interface {
+	// GetState returns the mode, target, endpoint and capture settings, as
+	// GET /api/state does.
+	GetState(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (*State, error)
+	// ListRequests returns captured requests, oldest first, as GET
+	// /api/requests does. With a query it searches captured bodies instead,
+	// as GET /api/search does, and returns the matches newest first.
+	ListRequests(ctx context.Context, in *ListRequestsRequest, opts ...grpc.CallOption) (*ListRequestsResponse, error)
+	// GetRequest returns one captured request.
+	GetRequest(ctx context.Context, in *GetRequestRequest, opts ...grpc.CallOption) (*Request, error)
+	// ClearRequests empties the request history, as DELETE /api/requests does.
+	ClearRequests(ctx context.Context, in *ClearRequestsRequest, opts ...grpc.CallOption) (*ClearRequestsResponse, error)
+	// ReplayRequest sends a captured request again, optionally edited, and
+	// returns the new request, as POST /api/requests/{id}/replay does.
+	ReplayRequest(ctx context.Context, in *ReplayRequestRequest, opts ...grpc.CallOption) (*Request, error)
+	// GetStats returns the stats for the current period, as GET
+	// /api/stats/snapshot does.
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*Stats, error)
+	// ResetStats starts a new stats period and returns its empty stats, as
+	// POST /api/stats/reset does.
+	ResetStats(ctx context.Context, in *ResetStatsRequest, opts ...grpc.CallOption) (*Stats, error)
+	// SetCapture pauses or resumes capture and turns body capture on or off.
+	// Unset fields are left as they are.
+	SetCapture(ctx context.Context, in *SetCaptureRequest, opts ...grpc.CallOption) (*CaptureState, error)
+	// WatchRequests streams requests as they are captured, until the client
+	// cancels.
+	WatchRequests(ctx context.Context, in *WatchRequestsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Request], error)
+	// WatchLogs streams portal's application logs, starting with the most
+	// recent ones, until the client cancels.
+	WatchLogs(ctx context.Context, in *WatchLogsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogEntry], error)
+}
+
+type controlClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewControlClient(cc grpc.ClientConnInterface) ControlClient {
+	return &controlClient{cc}
+}
+
+func (c *controlClient) GetState(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (*State, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(State)
+	err := c.cc.Invoke(ctx, Control_GetState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ListRequests(ctx context.Context, in *ListRequestsRequest, opts ...grpc.CallOption) (*ListRequestsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRequestsResponse)
+	err := c.cc.Invoke(ctx, Control_ListRequests_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) GetRequest(ctx context.Context, in *GetRequestRequest, opts ...grpc.CallOption) (*Request, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Request)
+	err := c.cc.Invoke(ctx, Control_GetRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ClearRequests(ctx context.Context, in *ClearRequestsRequest, opts ...grpc.CallOption) (*ClearRequestsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClearRequestsResponse)
+	err := c.cc.Invoke(ctx, Control_ClearRequests_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ReplayRequest(ctx context.Context, in *ReplayRequestRequest, opts ...grpc.CallOption) (*Request, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Request)
+	err := c.cc.Invoke(ctx, Control_ReplayRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*Stats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Stats)
+	err := c.cc.Invoke(ctx, Control_GetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) ResetStats(ctx context.Context, in *ResetStatsRequest, opts ...grpc.CallOption) (*Stats, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Stats)
+	err := c.cc.Invoke(ctx, Control_ResetStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) SetCapture(ctx context.Context, in *SetCaptureRequest, opts ...grpc.CallOption) (*CaptureState, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CaptureState)
+	err := c.cc.Invoke(ctx, Control_SetCapture_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) WatchRequests(ctx context.Context, in *WatchRequestsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Request], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Control_ServiceDesc.Streams[0], Control_WatchRequests_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequestsRequest, Request]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Control_WatchRequestsClient = grpc.ServerStreamingClient[Request]
+
+func (c *controlClient) WatchLogs(ctx context.Context, in *WatchLogsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogEntry], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Control_ServiceDesc.Streams[1], Control_WatchLogs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchLogsRequest, LogEntry]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Control_WatchLogsClient = grpc.ServerStreamingClient[LogEntry]
+
+// ControlServer is the server API for Control service.
+// All implementations must embed UnimplementedControlServer
+// for forward compatibility.
+//
+// Control manages a running portal.
+type ControlServer interface {
+	// GetState returns the mode, target, endpoint and capture settings, as
+	// GET /api/state does.
+	GetState(context.Context, *GetStateRequest) (*State, error)
+	// ListRequests returns captured requests, oldest first, as GET
+	// /api/requests does. With a query it searches captured bodies instead,
+	// as GET /api/search does, and returns the matches newest first.
+	ListRequests(context.Context, *ListRequestsRequest) (*ListRequestsResponse, error)
+	// GetRequest returns one captured request.
+	GetRequest(context.Context, *GetRequestRequest) (*Request, error)
+	// ClearRequests empties the request history, as DELETE /api/requests does.
+	ClearRequests(context.Context, *ClearRequestsRequest) (*ClearRequestsResponse, error)
+	// ReplayRequest sends a captured request again, optionally edited, and
+	// returns the new request, as POST /api/requests/{id}/replay does.
+	ReplayRequest(context.Context, *ReplayRequestRequest) (*Request, error)
+	// GetStats returns the stats for the current period, as GET
+	// /api/stats/snapshot does.
+	GetStats(context.Context, *GetStatsRequest) (*Stats, error)
+	// ResetStats starts a new stats period and returns its empty stats, as
+	// POST /api/stats/reset does.
+	ResetStats(context.Context, *ResetStatsRequest) (*Stats, error)
+	// SetCapture pauses or resumes capture and turns body capture on or off.
+	// Unset fields are left as they are.
+	SetCapture(context.Context, *SetCaptureRequest) (*CaptureState, error)
+	// WatchRequests streams requests as they are captured, until the client
+	// cancels.
+	WatchRequests(*WatchRequestsRequest, grpc.ServerStreamingServer[Request]) error
+	// WatchLogs streams portal's application logs, starting with the most
+	// recent ones, until the client cancels.
+	WatchLogs(*WatchLogsRequest, grpc.ServerStreamingServer[LogEntry]) error
+	mustEmbedUnimplementedControlServer()
+}
+
+// UnimplementedControlServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedControlServer struct{}
+
+func (UnimplementedControlServer) GetState(context.Context, *GetStateRequest) (*State, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetState not implemented")
+}
+func (UnimplementedControlServer) ListRequests(context.Context, *ListRequestsRequest) (*ListRequestsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRequests not implemented")
+}
+func (UnimplementedControlServer) GetRequest(context.Context, *GetRequestRequest) (*Request, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRequest not implemented")
+}
+func (UnimplementedControlServer) ClearRequests(context.Context, *ClearRequestsRequest) (*ClearRequestsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClearRequests not implemented")
+}
+func (UnimplementedControlServer) ReplayRequest(context.Context, *ReplayRequestRequest) (*Request, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplayRequest not implemented")
+}
+func (UnimplementedControlServer) GetStats(context.Context, *GetStatsRequest) (*Stats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedControlServer) ResetStats(context.Context, *ResetStatsRequest) (*Stats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResetStats not implemented")
+}
+func (UnimplementedControlServer) SetCapture(context.Context, *SetCaptureRequest) (*CaptureState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetCapture not implemented")
+}
+func (UnimplementedControlServer) WatchRequests(*WatchRequestsRequest, grpc.ServerStreamingServer[Request]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRequests not implemented")
+}
+func (UnimplementedControlServer) WatchLogs(*WatchLogsRequest, grpc.ServerStreamingServer[LogEntry]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchLogs not implemented")
+}
+func (UnimplementedControlServer) mustEmbedUnimplementedControlServer() {}
+func (UnimplementedControlServer) testEmbeddedByValue()                 {}
+
+// UnsafeControlServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ControlServer will
+// result in compilation errors.
+type UnsafeControlServer interface {
+	mustEmbedUnimplementedControlServer()
+}
+
+func RegisterControlServer(s grpc.ServiceRegistrar, srv ControlServer) {
+	// If the following call pancis, it indicates UnimplementedControlServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Control_ServiceDesc, srv)
+}
+
+func _Control_GetState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).GetState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_GetState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).GetState(ctx, req.(*GetStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ListRequests_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequestsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ListRequests(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ListRequests_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ListRequests(ctx, req.(*ListRequestsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_GetRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).GetRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_GetRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).GetRequest(ctx, req.(*GetRequestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ClearRequests_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClearRequestsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ClearRequests(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ClearRequests_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ClearRequests(ctx, req.(*ClearRequestsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ReplayRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplayRequestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ReplayRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ReplayRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ReplayRequest(ctx, req.(*ReplayRequestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_ResetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).ResetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_ResetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).ResetStats(ctx, req.(*ResetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_SetCapture_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetCaptureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).SetCapture(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_SetCapture_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).SetCapture(ctx, req.(*SetCaptureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_WatchRequests_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequestsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ControlServer).WatchRequests(m, &grpc.GenericServerStream[WatchRequestsRequest, Request]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Control_WatchRequestsServer = grpc.ServerStreamingServer[Request]
+
+func _Control_WatchLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ControlServer).WatchLogs(m, &grpc.GenericServerStream[WatchLogsRequest, LogEntry]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Control_WatchLogsServer = grpc.ServerStreamingServer[LogEntry]
+
+// Control_ServiceDesc is the grpc.ServiceDesc for Control service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Control_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "portal.control.v1.Control",
+	HandlerType: (*ControlServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetState",
+			Handler:    _Control_GetState_Handler,
+		},
+		{
+			MethodName: "ListRequests",
+			Handler:    _Control_ListRequests_Handler,
+		},
+		{
+			MethodName: "GetRequest",
+			Handler:    _Control_GetRequest_Handler,
+		},
+		{
+			MethodName: "ClearRequests",
+			Handler:    _Control_ClearRequests_Handler,
+		},
+		{
+			MethodName: "ReplayRequest",
+			Handler:    _Control_ReplayRequest_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _Control_GetStats_Handler,
+		},
+		{
+			MethodName: "ResetStats",
+			Handler:    _Control_ResetStats_Handler,
+		},
+		{
+			MethodName: "SetCapture",
+			Handler:    _Control_SetCapture_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchRequests",
+			Handler:       _Control_WatchRequests_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchLogs",
+			Handler:       _Control_WatchLogs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "portal/control/v1/control.proto",
+}
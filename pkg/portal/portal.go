@@ -0,0 +1,258 @@
+// pkg/portal/portal.go
+
+// Package portal embeds portal in other Go programs. A Portal proxies a
+// local port, exposes it on the tailnet (or the internet with Funnel) and
+// captures every request for inspection:
+//
+//	p := portal.New(portal.Options{Port: 8080})
+//	p.OnRequest(func(r portal.Request) { log.Println(r.Method, r.URL, r.StatusCode) })
+//	go func() {
+//		<-p.Ready()
+//		log.Println("serving at", p.URL())
+//	}()
+//	if err := p.Run(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+package portal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/model"
+	"github.com/jaxxstorm/portal/internal/proxy"
+	"github.com/jaxxstorm/portal/internal/stats"
+	"github.com/jaxxstorm/portal/internal/tailscale"
+)
+
+// DefaultHostname is the tsnet node name used when Options.Hostname is empty.
+const DefaultHostname = "portal"
+
+// Options configures a Portal.
+type Options struct {
+	// Port is the local port to proxy to.
+	Port int
+	// TargetHost is the host to proxy to. Defaults to localhost.
+	TargetHost string
+
+	// Hostname is the tsnet node name, which becomes the first label of
+	// the service URL. Defaults to DefaultHostname.
+	Hostname string
+	// AuthKey authenticates the tsnet node. Without one, tsnet logs a login
+	// URL the first time the node starts.
+	AuthKey string
+	// StateDir holds the tsnet node's state. Empty uses tsnet's default.
+	StateDir string
+
+	// LocalDaemon exposes the proxy with tailscale serve on the machine's
+	// tailscaled instead of starting a tsnet node.
+	LocalDaemon bool
+
+	// Funnel makes the service reachable from the internet.
+	Funnel bool
+	// UseHTTPS serves with the node's Tailscale certificate.
+	UseHTTPS bool
+	// ServePort is the port the service is reachable on. Defaults to 443
+	// with HTTPS or Funnel and 80 otherwise.
+	ServePort int
+
+	// MaxLogs is how many captured requests are kept. Defaults to 1000.
+	MaxLogs int
+	// Logger receives portal's logs. Defaults to a no-op logger.
+	Logger *zap.Logger
+}
+
+// Request is a captured request and its response.
+type Request = model.RequestLog
+
+// Stats summarizes the requests a Portal has served.
+type Stats = stats.StatsSnapshot
+
+// Portal is a proxy exposed over Tailscale that captures its requests.
+type Portal struct {
+	opts   Options
+	logger *zap.Logger
+	proxy  *proxy.Server
+
+	// newTSNet and newClient start Tailscale; tests replace them with
+	// fakes.
+	newTSNet  func(tailscale.TSNetConfig, *zap.Logger) *tailscale.TSNetServer
+	newClient func(*zap.Logger) *tailscale.Client
+
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	mu      sync.Mutex
+	url     string
+	running bool
+}
+
+// New returns a Portal for opts. Nothing is started until Run.
+func New(opts Options) *Portal {
+	if opts.Hostname == "" {
+		opts.Hostname = DefaultHostname
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Portal{
+		opts:   opts,
+		logger: logger,
+		proxy: proxy.NewServer(proxy.Config{
+			TargetPort:    opts.Port,
+			TargetHost:    opts.TargetHost,
+			Mode:          model.ModeProxy,
+			Logger:        logger,
+			MaxLogs:       opts.MaxLogs,
+			FunnelEnabled: opts.Funnel,
+		}),
+		newTSNet:  tailscale.NewTSNetServer,
+		newClient: tailscale.NewClient,
+		ready:     make(chan struct{}),
+	}
+}
+
+// OnRequest calls fn with every request once it has been answered. Register
+// listeners before calling Run.
+func (p *Portal) OnRequest(fn func(Request)) {
+	p.proxy.AddListener(fn)
+}
+
+// Handler is the capturing proxy, for serving it on a listener of your own
+// instead of through Run.
+func (p *Portal) Handler() http.Handler {
+	return p.proxy
+}
+
+// Ready is closed once the service URL is reachable.
+func (p *Portal) Ready() <-chan struct{} {
+	return p.ready
+}
+
+// URL is the service URL, or empty until Ready is closed.
+func (p *Portal) URL() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.url
+}
+
+// Requests returns the captured requests, oldest first.
+func (p *Portal) Requests() []Request {
+	return p.proxy.GetRequestLogs()
+}
+
+// Stats returns connection counts, response times and failure counts.
+func (p *Portal) Stats() Stats {
+	return p.proxy.StatsSnapshot()
+}
+
+// ResetStats zeroes the counters, e.g. between test runs. Captured requests
+// are kept.
+func (p *Portal) ResetStats() {
+	p.proxy.ResetStats()
+}
+
+// Run exposes the proxy and serves until ctx is done, then removes what it
+// set up. A Portal can only be run once.
+func (p *Portal) Run(ctx context.Context) error {
+	if p.opts.Port < 1 || p.opts.Port > 65535 {
+		return fmt.Errorf("invalid port %d: must be between 1 and 65535", p.opts.Port)
+	}
+	p.mu.Lock()
+	if p.running {
+		p.mu.Unlock()
+		return errors.New("portal is already running")
+	}
+	p.running = true
+	p.mu.Unlock()
+
+	if p.opts.LocalDaemon {
+		return p.runLocalDaemon(ctx)
+	}
+	return p.runTSNet(ctx)
+}
+
+func (p *Portal) runTSNet(ctx context.Context) error {
+	node := p.newTSNet(tailscale.TSNetConfig{
+		Hostname:     p.opts.Hostname,
+		AuthKey:      p.opts.AuthKey,
+		StateDir:     p.opts.StateDir,
+		EnableFunnel: p.opts.Funnel,
+		UseHTTPS:     p.opts.UseHTTPS,
+		ServePort:    p.opts.ServePort,
+
+		ConfigureHTTPServer: p.proxy.ConfigureHTTPServer,
+	}, p.logger)
+	node.SetReadyCallback(func(info tailscale.TSNetReadyInfo) { p.setURL(info.ServiceURL) })
+	if p.opts.TargetHost != "" {
+		p.proxy.SetUpstreamDialer(node.DialPeer)
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- node.Serve(ctx, p.proxy) }()
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-served:
+	}
+	return errors.Join(err, node.Close())
+}
+
+func (p *Portal) runLocalDaemon(ctx context.Context) error {
+	client := p.newClient(p.logger)
+	if !client.IsAvailable(ctx) {
+		return errors.New("tailscaled is not running or not reachable")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to listen for the proxy: %w", err)
+	}
+	httpServer := &http.Server{Handler: p.proxy}
+	p.proxy.ConfigureHTTPServer(httpServer)
+	served := make(chan error, 1)
+	go func() { served <- httpServer.Serve(ln) }()
+	defer httpServer.Close()
+
+	serveConfig := tailscale.Config{
+		EnableFunnel: p.opts.Funnel,
+		UseHTTPS:     p.opts.UseHTTPS,
+		ServePort:    p.opts.ServePort,
+		ProxyPort:    ln.Addr().(*net.TCPAddr).Port,
+	}
+	// The node may already serve other things; put them back on exit.
+	snapshot, err := client.SnapshotServe(ctx, "", nil)
+	if err != nil {
+		return err
+	}
+	info, err := client.SetupServe(ctx, serveConfig)
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to set up tailscale serve: %w", err),
+			client.RestoreServe(context.WithoutCancel(ctx), snapshot))
+	}
+	p.setURL(info.URL)
+
+	select {
+	case <-ctx.Done():
+	case err = <-served:
+		err = fmt.Errorf("proxy server stopped: %w", err)
+	}
+	// The restore runs after ctx is cancelled, so it mustn't inherit that.
+	return errors.Join(err, client.RestoreServe(context.WithoutCancel(ctx), snapshot))
+}
+
+func (p *Portal) setURL(url string) {
+	p.mu.Lock()
+	p.url = url
+	p.mu.Unlock()
+	p.readyOnce.Do(func() { close(p.ready) })
+}
@@ -0,0 +1,121 @@
+package portal
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/jaxxstorm/portal/internal/tailscale"
+	"github.com/jaxxstorm/portal/internal/testsupport"
+)
+
+func upstreamPort(t *testing.T) int {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello from "+r.URL.Path)
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream.Listener.Addr().(*net.TCPAddr).Port
+}
+
+func get(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+// start runs p until the test ends and waits for it to be ready.
+func start(t *testing.T, p *Portal) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+
+	select {
+	case <-p.Ready():
+	case err := <-done:
+		t.Fatalf("Run returned before ready: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("portal never became ready")
+	}
+}
+
+func TestRunServesThroughTSNet(t *testing.T) {
+	p := New(Options{Port: upstreamPort(t), Hostname: "hooks"})
+	node := testsupport.NewFakeNode("hooks.example.ts.net")
+	p.newTSNet = func(config tailscale.TSNetConfig, logger *zap.Logger) *tailscale.TSNetServer {
+		return tailscale.NewTSNetServerWithNode(config, node, logger)
+	}
+	seen := make(chan Request, 1)
+	p.OnRequest(func(r Request) { seen <- r })
+	start(t, p)
+
+	if p.URL() != "http://hooks.example.ts.net" {
+		t.Fatalf("unexpected URL %q", p.URL())
+	}
+	addr, _, err := node.WaitListener(context.Background(), ":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := get(t, "http://"+addr+"/widgets"); body != "hello from /widgets" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	select {
+	case r := <-seen:
+		if r.URL != "/widgets" || r.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected request %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnRequest was never called")
+	}
+	if requests := p.Requests(); len(requests) != 1 {
+		t.Fatalf("expected one captured request, got %d", len(requests))
+	}
+	if stats := p.Stats(); stats.TotalConnections != 1 {
+		t.Fatalf("expected one connection in stats, got %+v", stats)
+	}
+}
+
+func TestRunServesThroughLocalDaemon(t *testing.T) {
+	p := New(Options{Port: upstreamPort(t), LocalDaemon: true})
+	daemon := testsupport.NewFakeLocalClient("dev-box.example.ts.net")
+	p.newClient = func(logger *zap.Logger) *tailscale.Client {
+		return tailscale.NewClientWithLocal(daemon, logger)
+	}
+	start(t, p)
+
+	if p.URL() != "http://dev-box.example.ts.net/" {
+		t.Fatalf("unexpected URL %q", p.URL())
+	}
+	target, err := daemon.ProxyTarget(80, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := get(t, target+"/hook"); body != "hello from /hook" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestRunRejectsInvalidPort(t *testing.T) {
+	if err := New(Options{}).Run(context.Background()); err == nil {
+		t.Fatal("expected an error without a port")
+	}
+}
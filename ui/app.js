@@ -1,13 +1,24 @@
 const state = {
   requests: [],
   stats: null,
+  timeseries: null,
+  capture: null,
+  presence: null,
+  annotations: [],
+  viewer: null,
+  annotationFormFor: null,
   health: null,
   filter: "",
+  sinceMinutes: 0,
+  savedFilters: null,
+  savedFilter: "",
+  bodySearch: { query: "", ids: null },
   selectedId: null,
   requestTab: "summary",
   responseTab: "summary",
   bootedAt: Date.now(),
-  lastUpdatedAt: null
+  lastUpdatedAt: null,
+  mockRules: null
 }
 
 const apiBasePath = resolveAPIBasePath()
@@ -17,6 +28,10 @@ document.addEventListener("DOMContentLoaded", init)
 function init() {
   wireNavigation()
   wireInspectControls()
+  wireMockControls()
+  wireReplayControls()
+  wireSequenceControls()
+  wireAnnotationControls()
   wireTabs("request-tabs", (tab) => {
     state.requestTab = tab
     renderDetail()
@@ -28,6 +43,7 @@ function init() {
 
   poll()
   setInterval(poll, 1000)
+  watchAnnotations()
 }
 
 function wireNavigation() {
@@ -39,6 +55,9 @@ function wireNavigation() {
       const view = button.dataset.view
       document.querySelectorAll(".view").forEach((node) => node.classList.remove("active"))
       document.getElementById(view).classList.add("active")
+      if (view === "mock-view") {
+        loadMockRules()
+      }
     })
   })
 }
@@ -48,12 +67,19 @@ function wireInspectControls() {
   filterInput.setAttribute("aria-label", "Filter requests")
   filterInput.addEventListener("input", (event) => {
     state.filter = event.target.value || ""
-    renderRequestList()
+    filterChanged()
   })
 
+  document.getElementById("request-since").addEventListener("change", (event) => {
+    state.sinceMinutes = Number(event.target.value) || 0
+    filterChanged()
+  })
+
+  wireSavedFilters()
+
   document.getElementById("clear-requests").addEventListener("click", async () => {
     try {
-      const response = await fetch(apiURL("requests"), { method: "DELETE" })
+      const response = await mutate(apiURL("requests"), { method: "DELETE" })
       if (!response.ok && response.status !== 204) {
         throw new Error("clear failed")
       }
@@ -65,6 +91,534 @@ function wireInspectControls() {
       // Keep the UI responsive even when clear fails.
     }
   })
+
+  // Pausing stops new requests from being recorded; traffic still flows.
+  document.getElementById("capture-toggle").addEventListener("click", async () => {
+    const paused = Boolean(state.capture && state.capture.paused)
+    try {
+      const response = await mutate(apiURL("capture/pause"), { method: paused ? "DELETE" : "POST" })
+      if (!response.ok) {
+        throw new Error("capture toggle failed")
+      }
+      state.capture = await response.json()
+      renderCaptureState()
+    } catch (_error) {
+      // The next poll shows the actual state.
+    }
+  })
+}
+
+// wireSavedFilters loads the saved filters into their dropdown and applies
+// the filter given in the page URL: ?view=<name>, or ?filter= and ?since=.
+function wireSavedFilters() {
+  const params = new URLSearchParams(window.location.search)
+  state.filter = params.get("filter") || ""
+  state.sinceMinutes = Number(params.get("since")) || 0
+  state.savedFilter = params.get("view") || ""
+  showFilter()
+
+  document.getElementById("saved-filter").addEventListener("change", (event) => {
+    applySavedFilter(event.target.value)
+  })
+
+  document.getElementById("save-filter").addEventListener("click", async () => {
+    const name = window.prompt("Save this filter as:", state.savedFilter)
+    if (!name || !name.trim()) {
+      return
+    }
+    try {
+      const response = await mutate(apiURL(`filters/${encodeURIComponent(name.trim())}`), {
+        method: "PUT",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ query: state.filter, since: state.sinceMinutes })
+      })
+      const body = await response.json()
+      if (!response.ok) {
+        throw new Error(body.error || "save failed")
+      }
+      state.savedFilters = body
+      state.savedFilter = name.trim()
+      filterChanged()
+    } catch (error) {
+      window.alert(`Could not save the filter: ${error.message}`)
+    }
+  })
+
+  document.getElementById("delete-filter").addEventListener("click", async () => {
+    const name = state.savedFilter
+    if (!name || !window.confirm(`Delete the saved filter "${name}"?`)) {
+      return
+    }
+    try {
+      const response = await mutate(apiURL(`filters/${encodeURIComponent(name)}`), { method: "DELETE" })
+      if (!response.ok && response.status !== 404) {
+        throw new Error("delete failed")
+      }
+      state.savedFilters = state.savedFilters.filter((filter) => filter.name !== name)
+      state.savedFilter = ""
+      filterChanged()
+    } catch (_error) {
+      // The dropdown still lists the filter; deleting can be retried.
+    }
+  })
+
+  loadSavedFilters()
+}
+
+async function loadSavedFilters() {
+  try {
+    state.savedFilters = await fetchJSON(apiURL("filters"))
+  } catch (_error) {
+    state.savedFilters = null
+  }
+  if (state.savedFilter) {
+    applySavedFilter(state.savedFilter)
+  } else {
+    renderSavedFilters()
+  }
+}
+
+function applySavedFilter(name) {
+  const filter = (state.savedFilters || []).find((saved) => saved.name === name)
+  state.savedFilter = filter ? filter.name : ""
+  if (filter) {
+    state.filter = filter.query || ""
+    state.sinceMinutes = filter.since || 0
+    showFilter()
+  }
+  filterChanged()
+}
+
+// filterChanged keeps the saved filter selected only while the filter still
+// matches it, updates the page URL so the view can be bookmarked or shared,
+// and re-renders the list.
+function filterChanged() {
+  const saved = (state.savedFilters || []).find((filter) => filter.name === state.savedFilter)
+  if (state.savedFilters && (!saved || saved.query !== state.filter.trim() || (saved.since || 0) !== state.sinceMinutes)) {
+    state.savedFilter = ""
+  }
+
+  const params = new URLSearchParams(window.location.search)
+  params.delete("view")
+  params.delete("filter")
+  params.delete("since")
+  if (state.savedFilter) {
+    params.set("view", state.savedFilter)
+  } else {
+    if (state.filter.trim()) {
+      params.set("filter", state.filter.trim())
+    }
+    if (state.sinceMinutes) {
+      params.set("since", String(state.sinceMinutes))
+    }
+  }
+  const query = params.toString()
+  window.history.replaceState(null, "", `${window.location.pathname}${query ? `?${query}` : ""}${window.location.hash}`)
+
+  renderSavedFilters()
+  renderRequestList()
+  scheduleBodySearch()
+}
+
+// bodyQuery joins the filter's body: terms, which are searched on the server
+// across captured bodies.
+function bodyQuery() {
+  return state.filter.trim().split(/\s+/)
+    .filter((term) => term.toLowerCase().startsWith("body:"))
+    .map((term) => term.slice("body:".length))
+    .filter(Boolean)
+    .join(" ")
+}
+
+let bodySearchTimer = null
+
+function scheduleBodySearch() {
+  clearTimeout(bodySearchTimer)
+  bodySearchTimer = setTimeout(refreshBodySearch, 250)
+}
+
+// refreshBodySearch fetches the IDs of requests matching the body: terms.
+async function refreshBodySearch() {
+  const query = bodyQuery()
+  if (!query) {
+    state.bodySearch = { query: "", ids: null }
+    return
+  }
+  try {
+    const results = await fetchJSON(apiURL(`search?q=${encodeURIComponent(query)}&limit=1000`))
+    if (query !== bodyQuery()) {
+      return
+    }
+    state.bodySearch = { query, ids: new Set((results || []).map((request) => request.id)) }
+    renderRequestList()
+  } catch (_error) {
+    state.bodySearch = { query, ids: new Set() }
+    renderRequestList()
+  }
+}
+
+function showFilter() {
+  document.getElementById("request-filter").value = state.filter
+  document.getElementById("request-since").value = String(state.sinceMinutes)
+}
+
+function renderSavedFilters() {
+  const controls = document.getElementById("saved-filter-controls")
+  controls.classList.toggle("hidden", !state.savedFilters)
+  if (!state.savedFilters) {
+    return
+  }
+  const select = document.getElementById("saved-filter")
+  select.innerHTML = `<option value="">Saved filters</option>` + state.savedFilters.map((filter) => {
+    return `<option value="${escapeHtml(filter.name)}">${escapeHtml(filter.name)}</option>`
+  }).join("")
+  select.value = state.savedFilter
+  document.getElementById("delete-filter").classList.toggle("hidden", !state.savedFilter)
+}
+
+// wireReplayControls handles the edit-and-resend form, which is pre-filled
+// from the selected request and replays it through the proxy.
+function wireReplayControls() {
+  const card = document.getElementById("replay-card")
+  document.getElementById("replay-open").addEventListener("click", () => {
+    const request = currentSelectedRequest()
+    if (!request) {
+      return
+    }
+    card.dataset.requestId = request.id
+    document.getElementById("replay-method").value = request.method || "GET"
+    document.getElementById("replay-url").value = request.url || "/"
+    document.getElementById("replay-headers").value = headerEntries(request.headers).map(([name, value]) => `${name}: ${value}`).join("\n")
+    // Binary bodies can't be edited as text; they are resent unchanged.
+    const bodyNode = document.getElementById("replay-body")
+    bodyNode.value = request.body || ""
+    bodyNode.disabled = Boolean(request.body_bytes)
+    bodyNode.placeholder = request.body_bytes ? "Binary body is resent unchanged" : ""
+    document.getElementById("replay-error").textContent = ""
+    card.classList.remove("hidden")
+  })
+  document.getElementById("replay-cancel").addEventListener("click", () => card.classList.add("hidden"))
+
+  document.getElementById("replay-form").addEventListener("submit", async (event) => {
+    event.preventDefault()
+    const errorNode = document.getElementById("replay-error")
+    errorNode.textContent = ""
+
+    // A line per value, so repeated headers are replayed as they arrived.
+    const headers = []
+    for (const line of document.getElementById("replay-headers").value.split("\n")) {
+      if (!line.trim()) {
+        continue
+      }
+      const colon = line.indexOf(":")
+      if (colon <= 0) {
+        errorNode.textContent = `Header lines must be Name: value, got "${line.trim()}"`
+        return
+      }
+      headers.push({ name: line.slice(0, colon).trim(), value: line.slice(colon + 1).trim() })
+    }
+
+    const bodyNode = document.getElementById("replay-body")
+    const edit = {
+      method: document.getElementById("replay-method").value.trim(),
+      url: document.getElementById("replay-url").value.trim(),
+      headers,
+      ...(bodyNode.disabled ? {} : { body: bodyNode.value })
+    }
+
+    try {
+      const response = await mutate(apiURL(`requests/${encodeURIComponent(card.dataset.requestId)}/replay`), {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify(edit)
+      })
+      const payload = await response.json()
+      if (!response.ok) {
+        throw new Error(payload.error || `HTTP ${response.status}`)
+      }
+      card.classList.add("hidden")
+      state.selectedId = payload.id
+      await poll()
+    } catch (error) {
+      errorNode.textContent = error.message
+    }
+  })
+}
+
+// wireAnnotationControls saves or removes the viewer's own note, tags and
+// star on the selected request.
+function wireAnnotationControls() {
+  const form = document.getElementById("annotation-form")
+  const send = async (annotation) => {
+    const errorNode = document.getElementById("annotation-error")
+    errorNode.textContent = ""
+    try {
+      const response = await mutate(apiURL(`requests/${encodeURIComponent(form.dataset.requestId)}/annotation`), annotation
+        ? { method: "PUT", headers: { "Content-Type": "application/json" }, body: JSON.stringify(annotation) }
+        : { method: "DELETE" })
+      if (!response.ok) {
+        const payload = await response.json().catch(() => ({}))
+        throw new Error(payload.error || `HTTP ${response.status}`)
+      }
+      errorNode.textContent = "Saved"
+    } catch (error) {
+      errorNode.textContent = error.message
+    }
+  }
+  form.addEventListener("submit", (event) => {
+    event.preventDefault()
+    send({
+      note: document.getElementById("annotation-note").value,
+      tags: document.getElementById("annotation-tags").value.split(/[\s,]+/).filter(Boolean),
+      starred: document.getElementById("annotation-starred").checked
+    })
+  })
+  document.getElementById("annotation-remove").addEventListener("click", () => {
+    fillAnnotationForm(null)
+    send(null)
+  })
+}
+
+// watchAnnotations streams everyone's annotation changes. The full list is
+// reloaded whenever the stream (re)connects, so nothing is missed while it
+// was down.
+function watchAnnotations() {
+  const source = new EventSource(apiURL("annotations/stream"))
+  source.onopen = loadAnnotations
+  source.onmessage = (event) => {
+    applyAnnotation(JSON.parse(event.data))
+    renderRequestList()
+    renderAnnotations(currentSelectedRequest())
+  }
+}
+
+async function loadAnnotations() {
+  try {
+    const payload = await fetchJSON(apiURL("annotations"))
+    state.viewer = payload.viewer || null
+    state.annotations = Array.isArray(payload.annotations) ? payload.annotations : []
+    state.annotationFormFor = null
+    render()
+  } catch (_error) {
+    // Older servers don't keep annotations.
+  }
+}
+
+// applyAnnotation replaces its author's annotation on the request; an empty
+// one means it was removed.
+function applyAnnotation(change) {
+  const key = identityKey(change.author || {})
+  state.annotations = state.annotations.filter((annotation) => {
+    return annotation.request_id !== change.request_id || identityKey(annotation.author || {}) !== key
+  })
+  if (change.note || (change.tags || []).length > 0 || change.starred) {
+    state.annotations.push(change)
+  }
+}
+
+function annotationsFor(id) {
+  return state.annotations.filter((annotation) => annotation.request_id === id)
+}
+
+// identityKey matches model.Identity.Key.
+function identityKey(identity) {
+  if (identity.login) {
+    return `user:${identity.login}`
+  }
+  if (identity.device) {
+    return `device:${identity.device}`
+  }
+  return `addr:${identity.address}`
+}
+
+function isViewer(identity) {
+  return Boolean(state.viewer) && identityKey(identity || {}) === identityKey(state.viewer)
+}
+
+// renderAnnotations lists who marked the selected request and what, and
+// fills the form with the viewer's own marks when the selection changes so
+// polling doesn't overwrite what they are typing.
+function renderAnnotations(request) {
+  const card = document.getElementById("annotation-card")
+  card.classList.toggle("hidden", !request || !state.viewer)
+  if (!request || !state.viewer) {
+    return
+  }
+  document.getElementById("annotation-viewer").textContent = `as ${formatIdentity(state.viewer)}`
+
+  const annotations = annotationsFor(request.id)
+  document.getElementById("annotation-list").innerHTML = annotations.map((annotation) => {
+    const tags = (annotation.tags || []).map((tag) => `<span class="annotation-tag">${escapeHtml(tag)}</span>`).join("")
+    const who = isViewer(annotation.author) ? "You" : formatIdentity(annotation.author || {})
+    return `<li><strong>${escapeHtml(who)}</strong>${annotation.starred ? " ★" : ""}${tags}${annotation.note ? ` <span>${escapeHtml(annotation.note)}</span>` : ""} <span class="muted">${escapeHtml(timeAgo(toMs(annotation.updated_at)))}</span></li>`
+  }).join("")
+
+  if (state.annotationFormFor !== request.id) {
+    state.annotationFormFor = request.id
+    document.getElementById("annotation-form").dataset.requestId = request.id
+    document.getElementById("annotation-error").textContent = ""
+    fillAnnotationForm(annotations.find((annotation) => isViewer(annotation.author)) || null)
+  }
+}
+
+function fillAnnotationForm(annotation) {
+  document.getElementById("annotation-starred").checked = Boolean(annotation?.starred)
+  document.getElementById("annotation-tags").value = (annotation?.tags || []).join(" ")
+  document.getElementById("annotation-note").value = annotation?.note || ""
+}
+
+// wireSequenceControls replays every request matching the current filter,
+// oldest first, with their original gaps or at a fixed rate.
+function wireSequenceControls() {
+  const form = document.getElementById("sequence-form")
+  const outcomesNode = document.getElementById("sequence-outcomes")
+  document.getElementById("sequence-open").addEventListener("click", () => {
+    const count = filteredRequests().length
+    document.getElementById("sequence-summary").textContent = `Replay the ${count} request${count === 1 ? "" : "s"} matching the current filter in their original order.`
+    outcomesNode.innerHTML = ""
+    form.classList.remove("hidden")
+  })
+  document.getElementById("sequence-cancel").addEventListener("click", () => form.classList.add("hidden"))
+
+  form.addEventListener("submit", async (event) => {
+    event.preventDefault()
+    const ids = filteredRequests().map((request) => request.id)
+    if (ids.length === 0) {
+      outcomesNode.innerHTML = `<li>No requests match the current filter.</li>`
+      return
+    }
+    const sequence = { ids }
+    if (document.getElementById("sequence-pacing").value === "rate") {
+      sequence.rate = Number(document.getElementById("sequence-rate").value) || 1
+    }
+
+    outcomesNode.innerHTML = `<li>Replaying ${ids.length} requests...</li>`
+    try {
+      const response = await mutate(apiURL("requests/replay"), {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify(sequence)
+      })
+      const payload = await response.json()
+      if (!response.ok) {
+        throw new Error(payload.error || `HTTP ${response.status}`)
+      }
+      outcomesNode.innerHTML = (payload.outcomes || []).map((outcome) => {
+        const failed = outcome.error || outcome.status_code >= 400
+        const result = outcome.error || `${outcome.status_code} in ${formatMs(nsToMs(outcome.duration))} ms`
+        return `<li class="${failed ? "status-err" : ""}">${escapeHtml(outcome.id)} → ${escapeHtml(result)}</li>`
+      }).join("")
+      await poll()
+    } catch (error) {
+      outcomesNode.innerHTML = `<li class="status-err">${escapeHtml(error.message)}</li>`
+    }
+  })
+}
+
+function wireMockControls() {
+  document.getElementById("mock-form-reset").addEventListener("click", () => fillMockForm(null))
+
+  document.getElementById("mock-rule-form").addEventListener("submit", async (event) => {
+    event.preventDefault()
+    const errorNode = document.getElementById("mock-form-error")
+    errorNode.textContent = ""
+
+    let headers = {}
+    const rawHeaders = document.getElementById("mock-rule-headers").value.trim()
+    if (rawHeaders) {
+      try {
+        headers = JSON.parse(rawHeaders)
+      } catch (_error) {
+        errorNode.textContent = "Headers must be a JSON object"
+        return
+      }
+    }
+
+    const id = document.getElementById("mock-rule-id").value
+    const rule = {
+      method: document.getElementById("mock-rule-method").value.trim(),
+      path: document.getElementById("mock-rule-path").value.trim(),
+      status: Number(document.getElementById("mock-rule-status").value) || 200,
+      headers,
+      body: document.getElementById("mock-rule-body").value
+    }
+
+    try {
+      const response = await mutate(apiURL(id ? `mock/rules/${encodeURIComponent(id)}` : "mock/rules"), {
+        method: id ? "PUT" : "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify(rule)
+      })
+      const payload = await response.json()
+      if (!response.ok) {
+        throw new Error(payload.error || `HTTP ${response.status}`)
+      }
+      fillMockForm(payload)
+      await loadMockRules()
+    } catch (error) {
+      errorNode.textContent = error.message
+    }
+  })
+
+  document.getElementById("mock-rules-table").addEventListener("click", async (event) => {
+    const button = event.target.closest("button[data-rule-id]")
+    if (!button) {
+      return
+    }
+    const id = button.dataset.ruleId
+    if (button.dataset.action === "edit") {
+      fillMockForm((state.mockRules || []).find((rule) => rule.id === id) || null)
+      return
+    }
+    await mutate(apiURL(`mock/rules/${encodeURIComponent(id)}`), { method: "DELETE" })
+    if (document.getElementById("mock-rule-id").value === id) {
+      fillMockForm(null)
+    }
+    await loadMockRules()
+  })
+}
+
+async function loadMockRules() {
+  const meta = document.getElementById("mock-rules-meta")
+  try {
+    state.mockRules = await fetchJSON(apiURL("mock/rules"))
+    meta.textContent = `${state.mockRules.length} rule(s), first match wins`
+  } catch (_error) {
+    state.mockRules = null
+    meta.textContent = "mock mode not enabled"
+  }
+  renderMockRules()
+}
+
+function renderMockRules() {
+  const table = document.getElementById("mock-rules-table")
+  const rules = state.mockRules || []
+  if (rules.length === 0) {
+    table.innerHTML = `<tr><td colspan="5" class="muted">No rules defined.</td></tr>`
+    return
+  }
+  table.innerHTML = rules.map((rule) => `
+    <tr>
+      <td>${escapeHtml(rule.id)}</td>
+      <td>${escapeHtml(rule.method || "any")}</td>
+      <td>${escapeHtml(rule.path)}</td>
+      <td>${escapeHtml(rule.status)}</td>
+      <td>
+        <button class="btn-secondary" data-action="edit" data-rule-id="${escapeHtml(rule.id)}">Edit</button>
+        <button class="btn-secondary" data-action="delete" data-rule-id="${escapeHtml(rule.id)}">Delete</button>
+      </td>
+    </tr>`).join("")
+}
+
+function fillMockForm(rule) {
+  document.getElementById("mock-form-title").textContent = rule ? `Edit ${rule.id}` : "New Rule"
+  document.getElementById("mock-rule-id").value = rule ? rule.id : ""
+  document.getElementById("mock-rule-method").value = rule ? rule.method || "" : ""
+  document.getElementById("mock-rule-path").value = rule ? rule.path : ""
+  document.getElementById("mock-rule-status").value = rule ? rule.status : 200
+  document.getElementById("mock-rule-headers").value = rule && rule.headers ? JSON.stringify(rule.headers, null, 2) : ""
+  document.getElementById("mock-rule-body").value = rule ? rule.body || "" : ""
+  document.getElementById("mock-form-error").textContent = ""
 }
 
 function wireTabs(containerId, onSelect) {
@@ -83,16 +637,26 @@ function wireTabs(containerId, onSelect) {
 
 async function poll() {
   try {
-    const [requests, stats, health] = await Promise.all([
+    const [requests, stats, health, timeseries, capture, presence] = await Promise.all([
       fetchJSON(apiURL("requests")),
       fetchJSON(apiURL("stats")),
-      fetchJSON(apiURL("health"))
+      fetchJSON(apiURL("health")),
+      // Charts are optional; older servers don't serve the time series.
+      fetchJSON(apiURL("stats/timeseries")).catch(() => null),
+      fetchJSON(apiURL("capture/pause")).catch(() => null),
+      fetchJSON(apiURL("presence")).catch(() => null)
     ])
 
     state.requests = (Array.isArray(requests) ? requests : []).slice().reverse()
     state.stats = stats || {}
+    state.timeseries = timeseries
+    state.capture = capture
+    state.presence = presence
     state.health = health || {}
     state.lastUpdatedAt = Date.now()
+    if (bodyQuery()) {
+      await refreshBodySearch()
+    }
 
     const hasCurrentSelection = state.requests.some((request) => request.id === state.selectedId)
     if (!hasCurrentSelection) {
@@ -116,6 +680,7 @@ async function fetchJSON(url) {
 
 function render() {
   renderTopMeta()
+  renderCaptureState()
   renderKpis()
   renderRequestList()
   renderDetail()
@@ -131,6 +696,22 @@ function renderTopMeta() {
   target.textContent = `updated ${timeAgo(state.lastUpdatedAt)}`
 }
 
+// renderCaptureState shows the pause button when the server supports it and
+// a notice while capture is paused.
+function renderCaptureState() {
+  const button = document.getElementById("capture-toggle")
+  const notice = document.getElementById("capture-paused")
+  const capture = state.capture
+  button.classList.toggle("hidden", !capture)
+  notice.classList.toggle("hidden", !capture || !capture.paused)
+  if (!capture) {
+    return
+  }
+  button.textContent = capture.paused ? "Resume" : "Pause"
+  const skipped = Number(capture.skipped || 0)
+  notice.textContent = `Capture paused: ${skipped} request${skipped === 1 ? "" : "s"} not recorded. Traffic is still proxied.`
+}
+
 function renderKpis() {
   const stats = state.stats || {}
   const derived = deriveMetrics(state.requests)
@@ -154,13 +735,16 @@ function renderRequestList() {
     const statusCode = Number(request.status_code || request.response?.status_code || 0)
     const statusClass = statusCode >= 400 ? "status-err" : "status-ok"
     const durationMs = nsToMs(request.duration)
+    const annotations = annotationsFor(request.id)
+    const starred = annotations.some((annotation) => annotation.starred)
+    const tags = [...new Set(annotations.flatMap((annotation) => annotation.tags || []))]
     const rowLabel = `${request.method || "-"} ${request.url || "/"} status ${statusCode || "unknown"} duration ${formatMs(durationMs)} milliseconds`
     return `
       <button type="button" class="request-row ${isActive}" data-id="${escapeHtml(request.id)}" aria-pressed="${request.id === state.selectedId}" aria-label="${escapeHtml(rowLabel)}">
         <span class="method-badge">${escapeHtml(request.method || "-")}</span>
         <div class="request-path">${escapeHtml(request.url || "/")}</div>
         <div class="status-pill ${statusClass}">${escapeHtml(String(statusCode || "-"))}</div>
-        <div class="request-meta">${formatMs(durationMs)} ms</div>
+        <div class="request-meta">${formatMs(durationMs)} ms${request.imported ? " · imported" : ""}${request.replay_of ? " · replay" : ""}${request.duplicate_of ? " · duplicate" : ""}${request.schema_changes ? " · schema changed" : ""}${request.oauth ? " · oauth" : ""}${request.chaos ? " · chaos" : ""}${request.scanner ? " · scanner" : ""}${request.geoip?.country ? ` · ${escapeHtml(request.geoip.country)}` : ""}${request.expose ? ` · ${escapeHtml(request.expose)}` : ""}${starred ? " · ★" : ""}${tags.length > 0 ? ` · ${escapeHtml(tags.join(" "))}` : ""}</div>
       </button>
     `
   }).join("")
@@ -179,6 +763,8 @@ function renderDetail() {
   const emptyNode = document.getElementById("empty-detail")
   const detailNode = document.getElementById("detail-content")
 
+  document.getElementById("replay-open").classList.toggle("hidden", !selected)
+  renderAnnotations(selected)
   if (!selected) {
     document.getElementById("selected-title").textContent = "Select a request"
     document.getElementById("selected-meta").textContent = ""
@@ -196,7 +782,13 @@ function renderDetail() {
     statusCode > 0 ? `status ${statusCode}` : "status n/a",
     `${formatMs(nsToMs(selected.duration))} ms`,
     selected.remote_addr || "remote n/a",
-    formatAbsoluteTime(selected.timestamp)
+    formatAbsoluteTime(selected.timestamp),
+    ...(selected.expose ? [`expose ${selected.expose}`] : []),
+    ...(selected.replay_of ? [`replay of ${selected.replay_of}`] : []),
+    ...(selected.duplicate_of ? [`duplicate of ${selected.duplicate_of}`] : []),
+    ...(selected.chaos ? [`chaos ${selected.chaos.join(", ")}`] : []),
+    ...(selected.scanner ? [`scanner: ${selected.scanner}`] : []),
+    ...(selected.geoip ? [geoLabel(selected.geoip)] : [])
   ].join(" • ")
 
   document.getElementById("request-tab-content").innerHTML = renderRequestTab(selected, state.requestTab)
@@ -204,16 +796,22 @@ function renderDetail() {
 }
 
 function renderRequestTab(request, tab) {
-  const requestHeaders = request.headers || {}
+  const requestHeaders = request.headers || []
   switch (tab) {
     case "headers":
-      return renderHeadersBlock(requestHeaders)
+      return renderHeadersBlock(requestHeaders, request.trailers)
     case "raw":
       return `<pre class="mono-block">${escapeHtml(renderRawRequest(request))}</pre>`
     case "body":
-      return `<pre class="mono-block">${escapeHtml(renderRequestBody(request))}</pre>`
+      if (Array.isArray(request.multipart) && request.multipart.length > 0) {
+        return renderMultipartTable(request.multipart)
+      }
+      if (request.body_bytes) {
+        return renderBodyDownload(request, "request", request.body_truncated) + renderHexDump(request.body_bytes, request.size, request.body_truncated)
+      }
+      return renderBodyDownload(request, "request", request.body_truncated) + `<pre class="mono-block">${escapeHtml(renderRequestBody(request))}</pre>`
     default:
-      return renderSummaryGrid([
+      return renderOAuth(request.oauth) + renderSummaryGrid([
         ["ID", request.id || "-"],
         ["Method", request.method || "-"],
         ["URL", request.url || "-"],
@@ -221,29 +819,144 @@ function renderRequestTab(request, tab) {
         ["User-Agent", request.user_agent || "-"],
         ["Content-Type", request.content_type || "-"],
         ["Body Size", `${request.size || 0} bytes`]
-      ])
+      ]) + renderSchemaChanges(request.schema_changes) + renderJWT(request.jwt)
+  }
+}
+
+// renderSchemaChanges lists how a JSON body differed from earlier deliveries
+// to the same endpoint. Removed and retyped fields are breaking.
+function renderSchemaChanges(changes) {
+  if (!Array.isArray(changes) || changes.length === 0) {
+    return ""
+  }
+  const rows = changes.map((change) => {
+    const breaking = change.change !== "added"
+    const detail = {
+      added: `added (${change.now})`,
+      removed: `removed (was ${change.was})`,
+      type: `type ${change.was} → ${change.now}`
+    }[change.change] || change.change
+    return `<tr><td class="schema-field">${escapeHtml(change.field)}</td><td class="${breaking ? "status-err" : "muted"}">${escapeHtml(detail)}</td></tr>`
+  }).join("")
+  return `
+    <div class="schema-block">
+      <strong>Payload schema changed</strong>
+      <table class="schema-changes">${rows}</table>
+    </div>
+  `
+}
+
+function renderOAuth(oauth) {
+  if (!oauth) {
+    return ""
+  }
+  const fields = [
+    ["code", oauth.code],
+    ["state", oauth.state],
+    ["error", oauth.error],
+    ["error_description", oauth.error_description]
+  ].filter(([, value]) => value)
+
+  let token = ""
+  if (oauth.token) {
+    const ok = !oauth.token.error && oauth.token.status_code < 300
+    const status = oauth.token.error ? `exchange failed: ${oauth.token.error}` : `token endpoint returned ${oauth.token.status_code}`
+    const body = oauth.token.response ? JSON.stringify(oauth.token.response, null, 2) : oauth.token.body || ""
+    token = `
+      <div class="${ok ? "status-ok" : "status-err"}">${escapeHtml(status)}</div>
+      ${body ? `<pre class="mono-block">${escapeHtml(body)}</pre>` : ""}
+    `
   }
+
+  return `
+    <div class="oauth-block">
+      <strong class="${oauth.code ? "status-ok" : "status-err"}">${oauth.code ? "OAuth authorization code received" : "OAuth callback without a code"}</strong>
+      <dl class="oauth-fields">
+        ${fields.map(([k, v]) => `<div><dt>${escapeHtml(k)}</dt><dd>${escapeHtml(v)}</dd></div>`).join("")}
+      </dl>
+      ${token}
+    </div>
+  `
+}
+
+function renderJWT(jwt) {
+  if (!jwt) {
+    return ""
+  }
+  const signature = {
+    valid: ["status-ok", "signature valid"],
+    invalid: ["status-err", `signature invalid: ${jwt.signature_error || "unknown error"}`],
+    unverified: ["muted", "signature not verified"]
+  }[jwt.signature] || ["muted", "signature not verified"]
+
+  let expiry = "no expiry"
+  if (jwt.expires_at) {
+    expiry = `${jwt.expired ? "expired" : "expires"} ${new Date(jwt.expires_at).toLocaleString()}`
+  }
+
+  return `
+    <div class="jwt-block">
+      <div class="jwt-status">
+        <strong>Bearer JWT</strong>
+        ${jwt.expired ? `<span class="status-err">EXPIRED</span>` : ""}
+        <span class="muted">${escapeHtml(expiry)}</span>
+        <span class="${signature[0]}">${escapeHtml(signature[1])}</span>
+      </div>
+      <pre class="mono-block">${escapeHtml(JSON.stringify({ header: jwt.header || {}, claims: jwt.claims || {} }, null, 2))}</pre>
+    </div>
+  `
 }
 
 function renderResponseTab(request, tab) {
   const response = request.response || {}
   switch (tab) {
     case "headers":
-      return renderHeadersBlock(response.headers || {})
+      return renderHeadersBlock(response.headers || [], response.trailers, response.informational)
     case "raw":
       return `<pre class="mono-block">${escapeHtml(renderRawResponse(request))}</pre>`
     case "body":
-      return `<pre class="mono-block">${escapeHtml(renderResponseBody(response))}</pre>`
+      if (response.body_bytes) {
+        return renderBodyDownload(request, "response", response.body_truncated) + renderHexDump(response.body_bytes, response.size, response.body_truncated)
+      }
+      return renderBodyDownload(request, "response", response.body_truncated) + `<pre class="mono-block">${escapeHtml(renderResponseBody(response))}</pre>`
     default:
       return renderSummaryGrid([
         ["Status", String(response.status_code || request.status_code || "-")],
         ["Duration", `${formatMs(nsToMs(request.duration))} ms`],
         ["Response Size", `${response.size || 0} bytes`],
-        ["Content-Type", response.headers?.["Content-Type"] || "-"],
-        ["Body Captured", response.body ? "yes" : "no"],
+        ["Content-Type", headerValue(response.headers, "Content-Type") || "-"],
+        ["Body Captured", response.body || response.body_bytes ? "yes" : "no"],
         ["Body Truncated", response.body_truncated ? "yes" : "no"]
-      ])
+      ]) + renderTimingChart(request)
+  }
+}
+
+function renderTimingChart(request) {
+  const timing = request.timing
+  if (!timing) {
+    return ""
   }
+  const total = nsToMs(request.duration)
+  const phases = [
+    ["connect", "Connect", nsToMs(timing.connect)],
+    ["ttfb", "TTFB", nsToMs(timing.ttfb)],
+    ["transfer", "Transfer", nsToMs(timing.transfer)]
+  ]
+  const upstream = phases.reduce((sum, [, , ms]) => sum + ms, 0)
+  phases.push(["overhead", "Portal / tunnel", Math.max(total - upstream, 0)])
+  const scale = Math.max(total, upstream) || 1
+
+  return `
+    <div class="timing-chart" aria-label="Latency breakdown">
+      <div class="timing-bar">
+        ${phases.map(([key, label, ms]) => `<span class="timing-${key}" style="width: ${(ms / scale * 100).toFixed(2)}%" title="${escapeHtml(label)} ${formatMs(ms)} ms"></span>`).join("")}
+      </div>
+      <ul class="timing-legend">
+        ${phases.map(([key, label, ms]) => `<li><span class="timing-swatch timing-${key}"></span>${escapeHtml(label)} ${formatMs(ms)} ms</li>`).join("")}
+      </ul>
+      ${timing.conn_reused ? `<div class="timing-note">Reused keep-alive connection</div>` : ""}
+    </div>
+  `
 }
 
 function renderSummaryGrid(entries) {
@@ -254,41 +967,98 @@ function renderSummaryGrid(entries) {
   `
 }
 
-function renderHeadersBlock(headers) {
-  const entries = Object.entries(headers || {})
-  if (entries.length === 0) {
-    return `<pre class="mono-block">(no headers captured)</pre>`
+// headerEntries returns [name, value] pairs, a pair per value of repeated
+// headers. Entries from older captures are an object of names to values.
+function headerEntries(headers) {
+  if (Array.isArray(headers)) {
+    return headers.map((header) => [header.name, header.value])
   }
-  const lines = entries
-    .sort(([a], [b]) => a.localeCompare(b))
+  return Object.entries(headers || {}).sort(([a], [b]) => a.localeCompare(b))
+}
+
+function headerValue(headers, name) {
+  const lower = name.toLowerCase()
+  const entry = headerEntries(headers).find(([key]) => key.toLowerCase() === lower)
+  return entry ? entry[1] : ""
+}
+
+function formatHeaderLines(headers) {
+  return headerEntries(headers)
     .map(([key, value]) => `${key}: ${value}`)
     .join("\n")
-  return `<pre class="mono-block">${escapeHtml(lines)}</pre>`
+}
+
+// renderHeadersBlock lists headers after any informational (1xx) responses
+// that came before them, and then any trailers.
+function renderHeadersBlock(headers, trailers, informational) {
+  const sections = (informational || []).map((interim) => `${interim.status_code} informational response\n${formatHeaderLines(interim.headers)}`)
+  sections.push(headerEntries(headers).length === 0 ? "(no headers captured)" : formatHeaderLines(headers))
+  if (headerEntries(trailers).length > 0) {
+    sections.push(`Trailers\n${formatHeaderLines(trailers)}`)
+  }
+  return `<pre class="mono-block">${escapeHtml(sections.join("\n\n"))}</pre>`
+}
+
+// renderTrailerLines follows a raw body with its trailers, if it had any.
+function renderTrailerLines(trailers) {
+  return headerEntries(trailers).length > 0 ? `\n\n${formatHeaderLines(trailers)}` : ""
 }
 
 function renderRawRequest(request) {
-  const headerLines = Object.entries(request.headers || {})
-    .map(([key, value]) => `${key}: ${value}`)
-    .join("\n")
   const body = renderRequestBody(request)
-  return `${request.method || "GET"} ${request.url || "/"} HTTP/1.1\n${headerLines}\n\n${body}`
+  return `${request.method || "GET"} ${request.url || "/"} HTTP/1.1\n${formatHeaderLines(request.headers)}\n\n${body}${renderTrailerLines(request.trailers)}`
 }
 
 function renderRawResponse(request) {
   const response = request.response || {}
   const statusCode = response.status_code || request.status_code || 0
-  const headerLines = Object.entries(response.headers || {})
-    .map(([key, value]) => `${key}: ${value}`)
-    .join("\n")
-  return `HTTP/1.1 ${statusCode}\n${headerLines}\n\n${renderResponseBody(response)}`
+  const interim = (response.informational || [])
+    .map((info) => `HTTP/1.1 ${info.status_code}\n${formatHeaderLines(info.headers)}\n\n`)
+    .join("")
+  return `${interim}HTTP/1.1 ${statusCode}\n${formatHeaderLines(response.headers)}\n\n${renderResponseBody(response)}${renderTrailerLines(response.trailers)}`
 }
 
 function renderRequestBody(request) {
+  if (Array.isArray(request.multipart) && request.multipart.length > 0) {
+    return request.multipart
+      .map((part) => part.filename
+        ? `[${part.name}] ${part.filename} (${part.content_type || "unknown type"}, ${part.size} bytes)`
+        : `[${part.name}] ${part.value || ""}`)
+      .join("\n")
+  }
+  if (request.body_omitted) {
+    return `(request body not captured, ${request.size || 0} bytes)`
+  }
+  if (request.body_bytes) {
+    return `(binary body, ${request.size || 0} bytes: see the Body tab)`
+  }
   const body = typeof request.body === "string" ? request.body : ""
   return body === "" ? "(empty request body)" : body
 }
 
+function renderMultipartTable(parts) {
+  const rows = parts.map((part) => `
+    <tr>
+      <td>${escapeHtml(part.name)}</td>
+      <td>${escapeHtml(part.filename || part.value || "")}</td>
+      <td>${escapeHtml(part.content_type || (part.filename ? "-" : "field"))}</td>
+      <td>${escapeHtml(`${part.size} bytes`)}</td>
+    </tr>`).join("")
+  return `
+    <table class="metrics-table">
+      <thead><tr><th>Field</th><th>File / Value</th><th>Content-Type</th><th>Size</th></tr></thead>
+      <tbody>${rows}</tbody>
+    </table>
+  `
+}
+
 function renderResponseBody(response) {
+  if (response.body_omitted) {
+    return `(response body not captured, ${response.size || 0} bytes)`
+  }
+  if (response.body_bytes) {
+    return `(binary body, ${response.size || 0} bytes: see the Body tab)`
+  }
   const body = typeof response.body === "string" ? response.body : ""
   if (body === "") {
     return "(empty or non-captured response body)"
@@ -299,6 +1069,33 @@ function renderResponseBody(response) {
   return body
 }
 
+// renderBodyDownload links to the captured request or response body as a
+// file, when one was captured.
+function renderBodyDownload(request, side, truncated) {
+  const message = side === "request" ? request : request.response || {}
+  if (!request.id || (!message.body && !message.body_bytes)) {
+    return ""
+  }
+  const url = apiURL(`requests/${encodeURIComponent(request.id)}/body/${side}`)
+  const note = truncated ? `<span class="muted">only the captured prefix</span>` : ""
+  return `<div class="body-download"><a href="${escapeHtml(url)}" download>Download ${side} body</a>${note}</div>`
+}
+
+// renderHexDump shows a binary body, sent base64-encoded as body_bytes, as
+// offset, hex and ASCII columns.
+function renderHexDump(encoded, size, truncated) {
+  const binary = atob(encoded)
+  const lines = []
+  for (let offset = 0; offset < binary.length; offset += 16) {
+    const chunk = Array.from(binary.slice(offset, offset + 16), (ch) => ch.charCodeAt(0))
+    const hex = chunk.map((b) => b.toString(16).padStart(2, "0"))
+    const ascii = chunk.map((b) => (b >= 0x20 && b < 0x7f ? String.fromCharCode(b) : ".")).join("")
+    lines.push(`${offset.toString(16).padStart(8, "0")}  ${hex.slice(0, 8).join(" ").padEnd(23)}  ${hex.slice(8).join(" ").padEnd(23)}  |${ascii}|`)
+  }
+  const note = truncated ? `<div class="hex-note">Showing the first ${binary.length} of ${size || "?"} bytes.</div>` : ""
+  return `<pre class="mono-block hex-dump">${escapeHtml(lines.join("\n"))}</pre>${note}`
+}
+
 function renderStatusView() {
   const metrics = deriveMetrics(state.requests)
   const stats = state.stats || {}
@@ -308,6 +1105,10 @@ function renderStatusView() {
   runtimeTable.innerHTML = [
     ["Health", health.status || "unknown"],
     ["Log Provider", String(Boolean(health.log_provider))],
+    ["Upstream", health.upstream === "waiting" ? "waiting for upstream" : (health.upstream || "n/a")],
+    ["HTTPS Certificate", health.cert === "provisioning" ? "provisioning..." : (health.cert || "n/a")],
+    ["Mounts", health.mounts || "none"],
+    ["Exposes", health.exposes || "none"],
     ["Request Count", String(metrics.totalRequests)],
     ["Last Request", metrics.lastRequestAt ? formatAbsoluteTime(metrics.lastRequestAt) : "n/a"],
     ["Uptime", formatUptime(Date.now() - state.bootedAt)]
@@ -324,11 +1125,198 @@ function renderStatusView() {
     ["Requests / 5m", String(metrics.requests5m)],
     ["Requests / 15m", String(metrics.requests15m)],
     ["Unique Clients", String(metrics.uniqueClients)],
-    ["Error Rate", `${formatPercent(metrics.errorRate)}%`]
+    ["Error Rate", `${formatPercent(metrics.errorRate)}%`],
+    ["Limit Violations", formatViolations(stats.limit_violations)],
+    ["Upstream Failures", formatViolations(stats.upstream_failures)],
+    ["Unmatched Routes", formatUnmatched(stats.unmatched_routes)],
+    ["Quotas", formatQuotas(stats.quotas)]
   ].map(([k, v]) => `<tr><td>${escapeHtml(k)}</td><td>${escapeHtml(v)}</td></tr>`).join("")
 
+  renderPresence()
+  renderServiceQR(health.service_url)
+
   document.getElementById("method-breakdown").innerHTML = renderBreakdown(metrics.methodCounts)
   document.getElementById("status-breakdown").innerHTML = renderBreakdown(metrics.statusCounts)
+  document.getElementById("country-breakdown").innerHTML = renderBreakdown(stats.countries)
+  renderCharts()
+}
+
+function renderCharts() {
+  const series = state.timeseries
+  const ids = ["chart-requests", "chart-latency", "chart-status"]
+  if (!series || !Array.isArray(series.requests)) {
+    ids.forEach((id) => { document.getElementById(id).innerHTML = `<div class="empty-state">No data yet.</div>` })
+    document.getElementById("top-paths").innerHTML = `<div class="empty-state">No data yet.</div>`
+    return
+  }
+
+  document.getElementById("chart-requests").innerHTML = renderBarChart(
+    [{ key: "requests", label: "req/s", values: series.requests_per_second || [] }],
+    series, (value) => `${value.toFixed(1)}/s`
+  )
+  document.getElementById("chart-latency").innerHTML = renderLineChart([
+    { key: "p50", label: "p50", values: series.p50_ms || [] },
+    { key: "p90", label: "p90", values: series.p90_ms || [] },
+    { key: "p99", label: "p99", values: series.p99_ms || [] }
+  ], series)
+
+  const classes = {}
+  Object.entries(series.status_codes || {}).forEach(([code, counts]) => {
+    const key = `${String(code)[0]}xx`
+    classes[key] = (classes[key] || new Array(counts.length).fill(0)).map((value, i) => value + counts[i])
+  })
+  document.getElementById("chart-status").innerHTML = renderBarChart(
+    Object.keys(classes).sort().map((key) => ({ key: `s${key}`, label: key, values: classes[key] })),
+    series, (value) => String(value)
+  )
+
+  const topPaths = Object.fromEntries((series.top_paths || []).map(({ path, count }) => [path, count]))
+  document.getElementById("top-paths").innerHTML = renderBreakdown(topPaths)
+}
+
+// renderBarChart draws stacked bars, one per bucket, as an SVG.
+function renderBarChart(layers, series, format) {
+  const buckets = series.requests.length
+  const totals = new Array(buckets).fill(0)
+  layers.forEach(({ values }) => values.forEach((value, i) => { totals[i] += value }))
+  const peak = Math.max(...totals, 0)
+  if (peak === 0) {
+    return `<div class="empty-state">No requests in the last 5 minutes.</div>`
+  }
+
+  const offsets = new Array(buckets).fill(0)
+  const bars = layers.map(({ key, values }) => values.map((value, i) => {
+    if (!value) {
+      return ""
+    }
+    const height = value / peak * 100
+    offsets[i] += height
+    return `<rect class="chart-${key}" x="${i + 0.1}" y="${(100 - offsets[i]).toFixed(2)}" width="0.8" height="${height.toFixed(2)}"><title>${escapeHtml(bucketTime(series, i))}: ${escapeHtml(format(value))}</title></rect>`
+  }).join("")).join("")
+
+  return renderChartFrame(`<svg viewBox="0 0 ${buckets} 100" preserveAspectRatio="none">${bars}</svg>`, layers, format(peak), series)
+}
+
+// renderLineChart draws one line per layer as an SVG. Buckets without
+// requests break the line rather than dropping to zero.
+function renderLineChart(layers, series) {
+  const buckets = series.requests.length
+  const peak = Math.max(...layers.flatMap(({ values }) => values), 0)
+  if (peak === 0) {
+    return `<div class="empty-state">No requests in the last 5 minutes.</div>`
+  }
+
+  const lines = layers.map(({ key, values }) => {
+    let path = ""
+    let drawing = false
+    values.forEach((value, i) => {
+      if (!value) {
+        drawing = false
+        return
+      }
+      path += `${drawing ? "L" : "M"}${i + 0.5} ${(100 - value / peak * 100).toFixed(2)} `
+      drawing = true
+    })
+    return `<path class="chart-line chart-${key}" d="${path.trim()}" vector-effect="non-scaling-stroke" />`
+  }).join("")
+
+  return renderChartFrame(`<svg viewBox="0 0 ${buckets} 100" preserveAspectRatio="none">${lines}</svg>`, layers, `${formatMs(peak)} ms`, series)
+}
+
+function renderChartFrame(svg, layers, maxLabel, series) {
+  const minutes = Math.round(series.requests.length * series.step_seconds / 60)
+  return `
+    <div class="chart-plot">
+      <span class="chart-max">${escapeHtml(maxLabel)}</span>
+      ${svg}
+    </div>
+    <div class="chart-axis"><span>-${minutes}m</span><span>now</span></div>
+    <ul class="timing-legend">
+      ${layers.map(({ key, label }) => `<li><span class="timing-swatch chart-${key}"></span>${escapeHtml(label)}</li>`).join("")}
+    </ul>
+  `
+}
+
+function bucketTime(series, index) {
+  const start = toMs(series.start) + index * series.step_seconds * 1000
+  return new Date(start).toLocaleTimeString()
+}
+
+function renderServiceQR(serviceURL) {
+  const panel = document.getElementById("qr-panel")
+  if (!serviceURL) {
+    panel.classList.add("hidden")
+    return
+  }
+  panel.classList.remove("hidden")
+  const link = document.getElementById("service-qr-url")
+  if (link.getAttribute("href") === serviceURL) {
+    return
+  }
+  link.href = serviceURL
+  link.textContent = serviceURL
+  document.getElementById("service-qr").src = apiURL(`qr.png?u=${encodeURIComponent(serviceURL)}`)
+}
+
+function renderPresence() {
+  const table = document.getElementById("presence-table")
+  const presence = Array.isArray(state.presence) ? state.presence : []
+  if (presence.length === 0) {
+    table.innerHTML = `<tr><td colspan="3" class="muted">No requests from identified clients yet.</td></tr>`
+    return
+  }
+  table.innerHTML = presence.map((entry) => {
+    return `<tr><td>${escapeHtml(formatIdentity(entry.identity || {}))}</td><td>${entry.requests}</td><td>${escapeHtml(timeAgo(toMs(entry.last_seen)))}</td></tr>`
+  }).join("")
+}
+
+// formatIdentity matches model.Identity.Label: the user, else the device's
+// tags, else the address, with the device name and Funnel noted.
+function formatIdentity(identity) {
+  let label = identity.login || (identity.tags || []).join(",") || identity.address || "unknown"
+  if (identity.device && identity.device !== label) {
+    label += ` (${identity.device})`
+  }
+  if (identity.funnel) {
+    label += " via Funnel"
+  }
+  return label
+}
+
+function formatViolations(violations) {
+  const entries = Object.entries(violations || {})
+  if (entries.length === 0) {
+    return "none"
+  }
+  return entries.map(([kind, count]) => `${kind.replaceAll("_", " ")}: ${count}`).join(", ")
+}
+
+function formatUnmatched(unmatched) {
+  const entries = Object.entries(unmatched || {}).sort((a, b) => b[1] - a[1])
+  if (entries.length === 0) {
+    return "none"
+  }
+  return entries.map(([path, count]) => `${path}: ${count}`).join(", ")
+}
+
+// geoLabel describes a client's GeoIP location, such as
+// "Germany, AS20712 Andrews & Arnold Ltd".
+function geoLabel(geo) {
+  const parts = []
+  if (geo.country_name || geo.country) {
+    parts.push(geo.country_name || geo.country)
+  }
+  if (geo.asn) {
+    parts.push(`AS${geo.asn} ${geo.as_org || ""}`.trim())
+  }
+  return parts.join(", ")
+}
+
+function formatQuotas(quotas) {
+  if (!Array.isArray(quotas) || quotas.length === 0) {
+    return "none"
+  }
+  return quotas.map((quota) => `${quota.client} (${quota.match}): ${quota.used}/${quota.limit} per ${quota.period}`).join(", ")
 }
 
 function renderBreakdown(counts) {
@@ -341,25 +1329,89 @@ function renderBreakdown(counts) {
   }).join("")
 }
 
+// filteredRequests applies the filter box and time range. The filter is a
+// list of terms that must all match: field:value terms for method, path
+// (a prefix), status (404, 4xx, >=400), client (user, device or address),
+// tag (anyone's annotation tag), is:starred and is:scanner, and plain text
+// searched for in the method, URL, address, user agent and status.
 function filteredRequests() {
-  const query = state.filter.trim().toLowerCase()
-  if (!query) {
+  const terms = state.filter.trim().toLowerCase().split(/\s+/).filter(Boolean)
+  const since = state.sinceMinutes > 0 ? Date.now() - state.sinceMinutes * 60000 : 0
+  if (terms.length === 0 && !since) {
     return state.requests
   }
 
   return state.requests.filter((request) => {
-    const statusCode = String(request.status_code || request.response?.status_code || "")
+    if (since && !(toMs(request.timestamp) >= since)) {
+      return false
+    }
+    const statusCode = Number(request.status_code || request.response?.status_code || 0)
     const haystack = [
       request.method || "",
       request.url || "",
       request.remote_addr || "",
       request.user_agent || "",
-      statusCode
+      statusCode || ""
     ].join(" ").toLowerCase()
-    return haystack.includes(query)
+    return terms.every((term) => {
+      const [, field, value] = term.match(/^(method|path|status|client|body|tag|is):(.*)$/) || []
+      switch (field) {
+        case "method":
+          return (request.method || "").toLowerCase() === value
+        case "path":
+          return (request.url || "").toLowerCase().startsWith(value)
+        case "status":
+          return statusMatches(statusCode, value)
+        case "client":
+          return clientText(request).includes(value)
+        case "body":
+          return state.bodySearch.query === bodyQuery() && Boolean(state.bodySearch.ids?.has(request.id))
+        case "tag":
+          return annotationsFor(request.id).some((annotation) => (annotation.tags || []).includes(value))
+        case "is":
+          if (value === "scanner") {
+            return Boolean(request.scanner)
+          }
+          return value === "starred" && annotationsFor(request.id).some((annotation) => annotation.starred)
+        default:
+          return haystack.includes(term)
+      }
+    })
   })
 }
 
+// statusMatches reports whether code matches a status term: an exact code,
+// a class such as 4xx, or a comparison such as >=400.
+function statusMatches(code, spec) {
+  const match = spec.match(/^(>=|<=|>|<|=)?(\d{3}|[1-5]xx)$/)
+  if (!match || !code) {
+    return false
+  }
+  const [, op, value] = match
+  if (value.endsWith("xx")) {
+    return Math.floor(code / 100) === Number(value[0])
+  }
+  const limit = Number(value)
+  switch (op) {
+    case ">=":
+      return code >= limit
+    case "<=":
+      return code <= limit
+    case ">":
+      return code > limit
+    case "<":
+      return code < limit
+    default:
+      return code === limit
+  }
+}
+
+function clientText(request) {
+  const identity = request.identity || {}
+  return [identity.login, identity.name, identity.device, identity.address, request.remote_addr]
+    .filter(Boolean).join(" ").toLowerCase()
+}
+
 function currentSelectedRequest() {
   if (!state.selectedId) {
     return null
@@ -495,11 +1547,10 @@ function escapeHtml(value) {
 
 function resolveAPIBasePath() {
   const path = window.location.pathname || "/"
-  if (path.startsWith("/ui/")) {
-    return "/ui/api/"
-  }
-  if (path === "/ui") {
-    return "/ui/api/"
+  // Keep any prefix the UI is mounted under, e.g. /alice/ui/ with --namespace.
+  const match = path.match(/^(.*)\/ui(\/|$)/)
+  if (match) {
+    return `${match[1]}/ui/api/`
   }
   return "/api/"
 }
@@ -507,3 +1558,28 @@ function resolveAPIBasePath() {
 function apiURL(endpoint) {
   return `${apiBasePath}${endpoint}`
 }
+
+// mutate sends a state-changing API request with the saved API token. When
+// the server asks for a token, the user is prompted once and the request is
+// retried.
+async function mutate(url, options = {}) {
+  const send = () => {
+    const headers = { ...(options.headers || {}) }
+    const token = localStorage.getItem("portal.apiToken")
+    if (token) {
+      headers.Authorization = `Bearer ${token}`
+    }
+    return fetch(url, { ...options, headers })
+  }
+
+  const response = await send()
+  if (response.status !== 401) {
+    return response
+  }
+  const token = window.prompt("This portal requires an API token (create one with `portal token create`):")
+  if (!token) {
+    return response
+  }
+  localStorage.setItem("portal.apiToken", token.trim())
+  return send()
+}